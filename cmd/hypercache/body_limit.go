@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"hypercache/pkg/config"
+)
+
+// defaultMaxBodySize is the request body limit when the config has none
+const defaultMaxBodySize = 64 << 20
+
+// maxBodySize returns the configured HTTP request body limit in bytes
+func maxBodySize(cfg *config.Config) int64 {
+	if cfg.Network.HTTPMaxBodySize == "" {
+		return defaultMaxBodySize
+	}
+	size, err := config.ParseSize(cfg.Network.HTTPMaxBodySize)
+	if err != nil || size == 0 {
+		return defaultMaxBodySize
+	}
+	return int64(size)
+}
+
+// limitRequestBody caps every request body at limit bytes, so a client
+// can't exhaust memory with a huge PUT. Reading past the limit fails with
+// *http.MaxBytesError; handlers turn that into a 413 via writeBodyError.
+func limitRequestBody(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeBodyError responds to a request body that couldn't be read or
+// decoded: 413 if it exceeded the size limit, otherwise 400 with msg
+func writeBodyError(w http.ResponseWriter, err error, msg string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, msg, http.StatusBadRequest)
+}
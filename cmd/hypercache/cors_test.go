@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"hypercache/pkg/config"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	served := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := corsMiddleware(config.CORSConfig{
+		Enabled:        true,
+		AllowedOrigins: []string{"https://app.example.com"},
+		MaxAge:         10 * time.Minute,
+	}, next)
+
+	// Preflight from an allowed origin is answered without reaching the API
+	req := httptest.NewRequest(http.MethodOptions, "/api/cache/key", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Preflight status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type, X-Correlation-ID",
+		"Access-Control-Max-Age":       "600",
+		"Vary":                         "Origin",
+	}
+	for header, value := range want {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("Preflight %s = %q, want %q", header, got, value)
+		}
+	}
+	if served != 0 {
+		t.Error("Preflight should not reach the API handler")
+	}
+
+	// Actual requests from the origin get the allow header too
+	req = httptest.NewRequest(http.MethodGet, "/api/cache/key", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" || served != 1 {
+		t.Errorf("GET: Allow-Origin = %q, served = %d", got, served)
+	}
+
+	// Other origins get no CORS headers
+	req = httptest.NewRequest(http.MethodOptions, "/api/cache/key", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Disallowed origin got Allow-Origin %q", got)
+	}
+
+	// Disabled: the API handler is used as-is
+	disabled := corsMiddleware(config.CORSConfig{}, next)
+	req = httptest.NewRequest(http.MethodGet, "/api/cache/key", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	disabled.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Disabled CORS set Allow-Origin %q", got)
+	}
+}
+
+func TestCORSMiddleware_AnyOrigin(t *testing.T) {
+	handler := corsMiddleware(config.CORSConfig{Enabled: true, AllowedMethods: []string{"GET"}},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/cache/key", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET" {
+		t.Errorf("Allow-Methods = %q, want GET", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Max-Age = %q, want none", got)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/storage"
+)
+
+// Keyspace event stream settings
+const (
+	eventStreamBuffer    = 256              // events queued per client before it is dropped
+	eventStreamKeepalive = 15 * time.Second // comment sent on an idle stream
+)
+
+// keyspaceEvent is one key mutation sent on the event stream
+type keyspaceEvent struct {
+	Event     string `json:"event"` // set, del, expire, persist, expired, evicted, ...
+	Key       string `json:"key"`
+	Timestamp int64  `json:"timestamp"` // Unix milliseconds
+}
+
+// handleKeyspaceEvents handles GET /api/cache/events: a Server-Sent Events
+// stream of the store's key mutations, each a JSON keyspaceEvent named
+// after its event. ?prefix= only streams keys with that prefix.
+//
+// Events are queued per client and the store never waits for one. A
+// client that falls eventStreamBuffer events behind is sent an "overflow"
+// event and disconnected, and can reconnect to resume from then on.
+func handleKeyspaceEvents(store *storage.BasicStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		prefix := r.URL.Query().Get("prefix")
+
+		events := make(chan keyspaceEvent, eventStreamBuffer)
+		overflow := make(chan struct{})
+		var overflowOnce sync.Once
+		cancel := store.Watch(func(event, key string) {
+			if !strings.HasPrefix(key, prefix) {
+				return
+			}
+			select {
+			case events <- keyspaceEvent{Event: event, Key: key, Timestamp: time.Now().UnixMilli()}:
+			default:
+				overflowOnce.Do(func() { close(overflow) })
+			}
+		})
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher := http.NewResponseController(w)
+		if err := flusher.Flush(); err != nil {
+			return // Streaming not supported by this connection
+		}
+
+		keepalive := time.NewTicker(eventStreamKeepalive)
+		defer keepalive.Stop()
+		for {
+			var err error
+			select {
+			case <-r.Context().Done():
+				return
+			case <-overflow:
+				logging.Warn(r.Context(), logging.ComponentHTTP, "event_stream", "Dropping slow event stream client", map[string]interface{}{
+					"remote_addr": r.RemoteAddr,
+				})
+				fmt.Fprint(w, "event: overflow\ndata: {}\n\n")
+				_ = flusher.Flush()
+				return
+			case ev := <-events:
+				data, _ := json.Marshal(ev)
+				_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Event, data)
+			case <-keepalive.C:
+				_, err = fmt.Fprint(w, ": keepalive\n\n")
+			}
+			if err == nil {
+				err = flusher.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
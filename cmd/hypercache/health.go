@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"hypercache/internal/cluster"
+)
+
+// Startup steps a node must finish before /health/ready reports ready
+const (
+	readyStoresRecovered = "stores_recovered" // stores created and persistence replayed
+	readyClusterJoined   = "cluster_joined"   // coordinator started and joined the cluster
+	readyServing         = "serving"          // RESP server and HTTP API routes up
+)
+
+// readiness tracks whether the node is ready to take traffic: every startup
+// step has completed and, once attached, the coordinator reports healthy
+type readiness struct {
+	mu          sync.Mutex
+	pending     map[string]bool
+	coordinator cluster.CoordinatorService
+}
+
+// newReadiness creates a tracker waiting for the given startup steps
+func newReadiness(steps ...string) *readiness {
+	pending := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		pending[step] = true
+	}
+	return &readiness{pending: pending}
+}
+
+// done marks a startup step as completed
+func (r *readiness) done(step string) {
+	r.mu.Lock()
+	delete(r.pending, step)
+	r.mu.Unlock()
+}
+
+// watch makes readiness also depend on the coordinator's health
+func (r *readiness) watch(coordinator cluster.CoordinatorService) {
+	r.mu.Lock()
+	r.coordinator = coordinator
+	r.mu.Unlock()
+}
+
+// check returns the reasons the node is not ready, none if it is
+func (r *readiness) check() []string {
+	r.mu.Lock()
+	reasons := make([]string, 0, len(r.pending))
+	for step := range r.pending {
+		reasons = append(reasons, "waiting for "+step)
+	}
+	coordinator := r.coordinator
+	r.mu.Unlock()
+	sort.Strings(reasons)
+
+	if coordinator != nil {
+		if health := coordinator.GetHealth(); !health.Healthy {
+			reasons = append(reasons, "cluster unhealthy")
+			reasons = append(reasons, health.Issues...)
+		}
+	}
+	return reasons
+}
+
+// registerHealthRoutes adds the orchestrator probes: /health/live answers
+// as soon as the process serves HTTP, /health/ready returns 503 until ready
+// reports the node ready to take traffic
+func registerHealthRoutes(mux *http.ServeMux, ready *readiness, nodeID string) {
+	mux.HandleFunc("/health/live", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"alive": true,
+			"node":  nodeID,
+		})
+	})
+
+	mux.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		reasons := ready.check()
+		w.Header().Set("Content-Type", "application/json")
+		if len(reasons) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		response := map[string]interface{}{
+			"ready": len(reasons) == 0,
+			"node":  nodeID,
+		}
+		if len(reasons) > 0 {
+			response["reasons"] = reasons
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+}
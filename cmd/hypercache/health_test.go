@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hypercache/internal/cluster"
+)
+
+// healthStub is a coordinator whose only implemented method is GetHealth
+type healthStub struct {
+	cluster.CoordinatorService
+	health cluster.CoordinatorHealth
+}
+
+func (c *healthStub) GetHealth() cluster.CoordinatorHealth { return c.health }
+
+func probe(t *testing.T, mux *http.ServeMux, path string) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s: invalid JSON %q: %v", path, rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+func TestHealthProbes(t *testing.T) {
+	ready := newReadiness(readyStoresRecovered, readyClusterJoined, readyServing)
+	mux := http.NewServeMux()
+	registerHealthRoutes(mux, ready, "node-1")
+
+	// Still recovering: alive but not ready
+	if code, body := probe(t, mux, "/health/live"); code != http.StatusOK || body["alive"] != true {
+		t.Errorf("/health/live during startup = %d %v, want 200 alive", code, body)
+	}
+	code, body := probe(t, mux, "/health/ready")
+	if code != http.StatusServiceUnavailable || body["ready"] != false {
+		t.Errorf("/health/ready during startup = %d %v, want 503 not ready", code, body)
+	}
+	if reasons, _ := body["reasons"].([]interface{}); len(reasons) != 3 || reasons[2] != "waiting for "+readyStoresRecovered {
+		t.Errorf("reasons = %v, want all three startup steps", body["reasons"])
+	}
+
+	coord := &healthStub{health: cluster.CoordinatorHealth{Healthy: true}}
+	ready.done(readyStoresRecovered)
+	ready.watch(coord)
+	ready.done(readyClusterJoined)
+	if _, body := probe(t, mux, "/health/ready"); len(body["reasons"].([]interface{})) != 1 {
+		t.Errorf("reasons after recovery and join = %v, want only %q", body["reasons"], readyServing)
+	}
+
+	ready.done(readyServing)
+	if code, body := probe(t, mux, "/health/ready"); code != http.StatusOK || body["ready"] != true || body["reasons"] != nil {
+		t.Errorf("/health/ready after startup = %d %v, want 200 ready", code, body)
+	}
+
+	// Losing cluster health makes the node unready but keeps it alive
+	coord.health = cluster.CoordinatorHealth{Healthy: false, Issues: []string{"no quorum"}}
+	code, body = probe(t, mux, "/health/ready")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("/health/ready with unhealthy cluster = %d, want 503", code)
+	}
+	if reasons, _ := body["reasons"].([]interface{}); len(reasons) != 2 || reasons[0] != "cluster unhealthy" || reasons[1] != "no quorum" {
+		t.Errorf("reasons = %v, want cluster unhealthy and its issues", body["reasons"])
+	}
+	if code, _ := probe(t, mux, "/health/live"); code != http.StatusOK {
+		t.Errorf("/health/live with unhealthy cluster = %d, want 200", code)
+	}
+}
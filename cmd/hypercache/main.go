@@ -1,14 +1,17 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -25,7 +28,7 @@ import (
 var (
 	configPath = flag.String("config", "configs/hypercache.yaml", "Path to configuration file")
 	nodeID     = flag.String("node-id", "", "Unique node identifier")
-	protocol   = flag.String("protocol", "internal", "Protocol to use (internal, resp)")
+	protocol   = flag.String("protocol", "internal", "Protocol to use (internal: standalone RESP node without clustering, resp: clustered)")
 	port       = flag.Int("port", 7000, "Port to bind the server")
 )
 
@@ -47,26 +50,21 @@ func main() {
 	}
 
 	// Override with command line flags
-	if *nodeID != "" {
-		cfg.Node.ID = *nodeID
-
-		// Use node-specific data directory, but avoid double-nesting
-		// (e.g., if config already has /tmp/hypercache/node-1 and nodeID is "node-1")
-		if !strings.HasSuffix(cfg.Node.DataDir, "/"+*nodeID) {
-			cfg.Node.DataDir = fmt.Sprintf("%s/%s", cfg.Node.DataDir, *nodeID)
-		}
-	}
+	applyFlagOverrides(cfg)
 
 	// Initialize structured logging system
 	logger, err := logging.InitializeFromConfig(cfg.Node.ID, logging.LogConfig{
-		Level:         cfg.Logging.Level,
-		EnableConsole: cfg.Logging.EnableConsole,
-		EnableFile:    cfg.Logging.EnableFile,
-		LogFile:       cfg.Logging.LogFile,
-		BufferSize:    cfg.Logging.BufferSize,
-		LogDir:        cfg.Logging.LogDir,
-		MaxFileSize:   cfg.Logging.MaxFileSize,
-		MaxFiles:      cfg.Logging.MaxFiles,
+		Level:          cfg.Logging.Level,
+		EnableConsole:  cfg.Logging.EnableConsole,
+		EnableFile:     cfg.Logging.EnableFile,
+		LogFile:        cfg.Logging.LogFile,
+		BufferSize:     cfg.Logging.BufferSize,
+		LogDir:         cfg.Logging.LogDir,
+		MaxFileSize:    cfg.Logging.MaxFileSize,
+		MaxFiles:       cfg.Logging.MaxFiles,
+		RotateInterval: cfg.Logging.RotateInterval,
+		FlushInterval:  cfg.Logging.FlushInterval,
+		SampleRate:     cfg.Logging.SampleRate,
 	})
 	if err != nil {
 		// Early error before logging is fully initialized
@@ -95,23 +93,6 @@ func main() {
 		}
 	}
 
-	// Use port flag if explicitly specified (different from default)
-	if *protocol == "resp" && *port != 7000 {
-		cfg.Network.RESPPort = *port
-		cfg.Network.HTTPPort = *port + 1000 // HTTP on RESP port + 1000
-	}
-	// For RESP protocol, ensure we have valid ports from config if not overridden
-	if *protocol == "resp" && *port == 7000 {
-		// Use config file ports (they should already be loaded correctly)
-		// Just ensure they're reasonable defaults if not set
-		if cfg.Network.RESPPort == 0 {
-			cfg.Network.RESPPort = 7000
-		}
-		if cfg.Network.HTTPPort == 0 {
-			cfg.Network.HTTPPort = 8000
-		}
-	}
-
 	logging.Info(ctx, logging.ComponentMain, logging.ActionStart, "Starting HyperCache Node", map[string]interface{}{
 		"node_id":     cfg.Node.ID,
 		"protocol":    *protocol,
@@ -134,37 +115,30 @@ func main() {
 	shutdownCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// Servers whose settings a config reload may change
+	var storeManager *storage.StoreManager
+	var respServer *resp.Server
+
 	// Start server based on protocol
 	if *protocol == "resp" {
-		// Create StoreManager to manage multiple named stores
-		storeManager := storage.NewStoreManager(storage.StoreManagerConfig{
-			DataDir:           cfg.Node.DataDir,
-			MaxStores:         cfg.Cache.MaxStores,
-			GlobalPersistence: cfg.Persistence,
-			GlobalCacheConfig: cfg.Cache,
-		})
-		defer storeManager.Close()
-
-		// Create stores from config (YAML-defined)
-		for _, storeCfg := range cfg.Stores {
-			if err := storeManager.CreateStore(storeCfg, shutdownCtx); err != nil {
-				logging.Fatal(ctx, logging.ComponentMain, logging.ActionStart, "Failed to create store", err, map[string]interface{}{"store": storeCfg.Name})
-				os.Exit(1)
+		// Serve the health probes first so a slow recovery or cluster join
+		// reads as "alive but not ready" rather than a dead process
+		ready := newReadiness(readyStoresRecovered, readyClusterJoined, readyServing)
+		mux := http.NewServeMux()
+		registerHealthRoutes(mux, ready, cfg.Node.ID)
+		go func() {
+			if err := startHTTPServer(shutdownCtx, mux, cfg.Network.HTTPPort, cfg.Node.ID, cfg); err != nil {
+				logging.Error(ctx, logging.ComponentHTTP, logging.ActionStart, "HTTP API server error", err, nil)
 			}
-		}
+		}()
 
-		// Load runtime-created stores from stores.json
-		if err := storeManager.LoadRegistry(shutdownCtx); err != nil {
-			logging.Warn(ctx, logging.ComponentStorage, logging.ActionRestore, "Failed to load store registry", map[string]interface{}{"error": err.Error()})
+		storeManager, err = openStores(shutdownCtx, cfg)
+		if err != nil {
+			logging.Fatal(ctx, logging.ComponentMain, logging.ActionStart, "Failed to create stores", err)
+			os.Exit(1)
 		}
-
-		// Save registry so any config-defined stores are also tracked
-		storeManager.SaveRegistry()
-
-		logging.Info(ctx, logging.ComponentMain, logging.ActionStart, "Stores initialized", map[string]interface{}{
-			"total_stores": storeManager.StoreCount(),
-			"stores":       storeManager.ListStores(),
-		})
+		defer storeManager.Close()
+		ready.done(readyStoresRecovered)
 
 		// Get default store for backward-compatible endpoints
 		defaultStore := storeManager.GetDefaultStore()
@@ -179,12 +153,21 @@ func main() {
 			BindAddress:             cfg.Network.RESPBindAddr, // Bind to all interfaces for multi-VM
 			BindPort:                cfg.Network.GossipPort,
 			AdvertiseAddress:        cfg.Network.AdvertiseAddr, // VM-specific IP for multi-VM
-			HTTPPort:                cfg.Network.HTTPPort,      // Shared via gossip for inter-node read-repair
+			AdvertiseInterface:      cfg.Network.AdvertiseInterface,
+			HTTPPort:                cfg.Network.HTTPPort, // Shared via gossip for inter-node read-repair
+			RESPPort:                cfg.Network.RESPPort, // Shared via gossip for MOVED redirects
 			SeedNodes:               resolvedSeeds,
 			HashRing:                cluster.DefaultHashRingConfig(), // 256 vnodes, RF=3, xxhash64
 			JoinTimeout:             30,                              // 30 seconds
 			HeartbeatInterval:       5,                               // 5 seconds
 			FailureDetectionTimeout: 15,                              // 15 seconds (must be > heartbeat)
+			Gossip: cluster.GossipConfig{
+				MinInterval:   cfg.Cluster.GossipMinInterval,
+				MaxInterval:   cfg.Cluster.GossipMaxInterval,
+				ExpectedNodes: cfg.Cluster.GossipExpectedNodes,
+			},
+			EventBus:           cluster.DefaultEventBusConfig(), // 1000-event buffers, 10ms backpressure
+			ReplicationBreaker: cluster.DefaultCircuitBreakerConfig(),
 		}
 
 		coord, err := cluster.NewDistributedCoordinator(clusterConfig)
@@ -196,47 +179,83 @@ func main() {
 			// DistributedCoordinator doesn't have Close(), stop via context
 		}()
 
+		// Cluster-wide control commands, broadcast with CLUSTER BROADCAST
+		coord.HandleCommand(cluster.CommandFlushAll, func(ctx context.Context, cmd cluster.ClusterCommand) error {
+			return flushStores(storeManager, cmd.Args["store"])
+		})
+		coord.HandleCommand(cluster.CommandRebalance, func(ctx context.Context, cmd cluster.ClusterCommand) error {
+			return coord.TriggerRebalance(ctx)
+		})
+		// Answers other nodes' CLUSTER DBSIZE
+		coord.SetDBSizeProvider(func(name string) int64 {
+			if store := storeManager.GetStore(name); store != nil {
+				return int64(store.ActiveSize())
+			}
+			return 0
+		})
+
 		// Start coordinator (this handles clustering, replication, and gossip)
 		if err := coord.Start(shutdownCtx); err != nil {
 			logging.Fatal(ctx, logging.ComponentMain, logging.ActionStart, "Failed to start coordinator", err)
 			os.Exit(1)
 		}
+		ready.watch(coord)
+		ready.done(readyClusterJoined)
 
-		// Subscribe to replication events
-		if eventBus := coord.GetEventBus(); eventBus != nil {
-			eventsChan := eventBus.Subscribe(cluster.EventDataOperation)
+		// Subscribe to replication events (a no-op bus never delivers any)
+		eventsChan := cluster.EventBusOf(coord).Subscribe(cluster.EventDataOperation)
 
-			// Start event handler in background
-			go func() {
-				logging.Info(shutdownCtx, logging.ComponentCluster, logging.ActionStart, "Event subscription started for replication", map[string]interface{}{
-					"node_id": cfg.Node.ID,
-				})
+		// Start event handler in background
+		go func() {
+			logging.Info(shutdownCtx, logging.ComponentCluster, logging.ActionStart, "Event subscription started for replication", map[string]interface{}{
+				"node_id": cfg.Node.ID,
+			})
 
-				for {
-					select {
-					case event := <-eventsChan:
-						handleReplicationEvent(shutdownCtx, event, storeManager, cfg.Node.ID, coord)
-					case <-shutdownCtx.Done():
-						logging.Info(shutdownCtx, logging.ComponentCluster, logging.ActionStop, "Event subscription stopping", map[string]interface{}{
-							"node_id": cfg.Node.ID,
-						})
-						return
-					}
+			for {
+				select {
+				case event := <-eventsChan:
+					handleReplicationEvent(shutdownCtx, event, storeManager, cfg.Node.ID, coord)
+				case <-shutdownCtx.Done():
+					logging.Info(shutdownCtx, logging.ComponentCluster, logging.ActionStop, "Event subscription stopping", map[string]interface{}{
+						"node_id": cfg.Node.ID,
+					})
+					return
 				}
-			}()
-		} else {
-			logging.Warn(ctx, logging.ComponentEventBus, logging.ActionStart, "Event bus not available - replication disabled")
+			}
+		}()
+
+		// Key-access audit log, if enabled
+		auditLog, err := openAuditLog(cfg)
+		if err != nil {
+			logging.Fatal(ctx, logging.ComponentMain, logging.ActionStart, "Failed to open audit log", err)
+			os.Exit(1)
+		}
+		if auditLog != nil {
+			defer auditLog.Close()
 		}
 
 		// Create distributed-aware RESP server using configured address
 		respBindAddr := fmt.Sprintf("%s:%d", cfg.Network.RESPBindAddr, cfg.Network.RESPPort)
-		respServer := resp.NewServer(respBindAddr, defaultStore, coord)
+		respServer = resp.NewServer(respBindAddr, defaultStore, coord)
 		respServer.SetStoreManager(storeManager)
+		if auditLog != nil {
+			respServer.SetAuditLog(auditLog)
+		}
+		if cfg.Network.MaxClients > 0 {
+			respServer.SetMaxConnections(cfg.Network.MaxClients)
+		}
+		respServer.SetErrorTokens(cfg.Network.RESPErrorTokens)
 
 		// Create node communicator for hash-ring routing & replication
 		nodeCommunicator := cluster.NewNodeCommunicator(cfg.Node.ID, coord.GetMembership())
+		go nodeCommunicator.WatchMembership(shutdownCtx)
 		respServer.SetNodeCommunicator(nodeCommunicator)
 		respServer.SetConsistencyLevel(cfg.Cluster.ConsistencyLevel)
+		respServer.SetKeyRouting(cfg.Cluster.KeyRouting)
+		metrics.Global().ReplicationLag().SetThreshold(cfg.Cluster.ReplicationLagThreshold)
+		if err := respServer.SetNotifyKeyspaceEvents(cfg.Cache.NotifyKeyspaceEvents); err != nil {
+			logging.Warn(ctx, logging.ComponentRESP, logging.ActionStart, "Invalid notify_keyspace_events, keyspace notifications disabled", map[string]interface{}{"error": err.Error()})
+		}
 
 		// Start RESP server
 		go func() {
@@ -247,22 +266,43 @@ func main() {
 			}
 		}()
 
-		// Start HTTP API server alongside RESP using configured port
-		go func() {
-			if err := startHTTPServer(shutdownCtx, coord, storeManager, cfg.Network.HTTPPort, cfg.Node.ID, cfg, nodeCommunicator); err != nil {
-				logging.Error(ctx, logging.ComponentHTTP, logging.ActionStart, "HTTP API server error", err, nil)
-			}
-		}()
+		// Expose the HTTP API on the already-running server
+		registerAPIRoutes(mux, coord, storeManager, cfg.Node.ID, cfg, nodeCommunicator, auditLog)
+		ready.done(readyServing)
 	} else {
-		// Internal protocol mode (future implementation)
-		logging.Info(ctx, logging.ComponentMain, logging.ActionStart, "HyperCache running in standalone mode")
+		// Internal protocol mode: a single node serving RESP without clustering
+		storeManager, err = openStores(shutdownCtx, cfg)
+		if err != nil {
+			logging.Fatal(ctx, logging.ComponentMain, logging.ActionStart, "Failed to create stores", err)
+			os.Exit(1)
+		}
+		defer storeManager.Close()
+
+		auditLog, err := openAuditLog(cfg)
+		if err != nil {
+			logging.Fatal(ctx, logging.ComponentMain, logging.ActionStart, "Failed to open audit log", err)
+			os.Exit(1)
+		}
+		if auditLog != nil {
+			defer auditLog.Close()
+		}
+
+		respServer, err = startStandalone(ctx, cfg, storeManager, auditLog)
+		if err != nil {
+			logging.Fatal(ctx, logging.ComponentMain, logging.ActionStart, "Failed to start standalone server", err)
+			os.Exit(1)
+		}
+		defer respServer.Stop()
 	}
 
-	// Wait for interrupt signal for graceful shutdown
+	// Reload the config on SIGHUP, until an interrupt signal for graceful shutdown
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
-	<-c
+	current := cfg
+	for sig := <-c; sig == syscall.SIGHUP; sig = <-c {
+		current = reloadConfig(ctx, current, logger, storeManager, respServer)
+	}
 	logging.Info(ctx, logging.ComponentMain, logging.ActionStop, "Shutting down HyperCache node", map[string]interface{}{"node_id": cfg.Node.ID})
 
 	// Cancel context to stop server
@@ -271,9 +311,151 @@ func main() {
 	logging.Info(ctx, logging.ComponentMain, logging.ActionStop, "HyperCache shutdown complete")
 }
 
-// HTTP API Server for REST endpoints
-func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService, storeManager *storage.StoreManager, port int, nodeID string, cfg *config.Config, nodeCommunicator *cluster.NodeCommunicator) error {
-	mux := http.NewServeMux()
+// openAuditLog opens the key-access audit log, or returns nil if auditing is
+// disabled
+func openAuditLog(cfg *config.Config) (*logging.AuditLog, error) {
+	if !cfg.Audit.Enabled {
+		return nil, nil
+	}
+
+	logFile := cfg.Audit.LogFile
+	if logFile == "" {
+		logFile = filepath.Join(cfg.Logging.LogDir, cfg.Node.ID+"-audit.log")
+	}
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	var maxFileSize uint64
+	if cfg.Audit.MaxFileSize != "" {
+		size, err := config.ParseSize(cfg.Audit.MaxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		maxFileSize = size
+	}
+
+	return logging.NewAuditLog(cfg.Node.ID, logging.AuditConfig{
+		LogFile:     logFile,
+		SampleRate:  cfg.Audit.SampleRate,
+		BufferSize:  cfg.Audit.BufferSize,
+		MaxFileSize: int64(maxFileSize),
+		MaxFiles:    cfg.Audit.MaxFiles,
+	})
+}
+
+// auditHTTPAccess records an /api/cache request in the audit log
+func auditHTTPAccess(auditLog *logging.AuditLog, r *http.Request, key string) {
+	var operation string
+	switch r.Method {
+	case http.MethodGet:
+		operation = "GET"
+	case http.MethodPut:
+		operation = "SET"
+	case http.MethodDelete:
+		operation = "DEL"
+	default:
+		return
+	}
+	auditLog.Record(logging.AuditRecord{
+		Operation:     operation,
+		Key:           key,
+		Protocol:      "http",
+		ClientAddr:    r.RemoteAddr,
+		CorrelationID: logging.GetCorrelationID(r.Context()),
+	})
+}
+
+// applyFlagOverrides applies the command line flags on top of a loaded config
+func applyFlagOverrides(cfg *config.Config) {
+	if *nodeID != "" {
+		cfg.Node.ID = *nodeID
+
+		// Use node-specific data directory, but avoid double-nesting
+		// (e.g., if config already has /tmp/hypercache/node-1 and nodeID is "node-1")
+		if !strings.HasSuffix(cfg.Node.DataDir, "/"+*nodeID) {
+			cfg.Node.DataDir = fmt.Sprintf("%s/%s", cfg.Node.DataDir, *nodeID)
+		}
+	}
+
+	// Use port flag if explicitly specified (different from default);
+	// otherwise config.Load has already filled in default ports
+	if *port != 7000 {
+		cfg.Network.RESPPort = *port
+		cfg.Network.HTTPPort = *port + 1000 // HTTP on RESP port + 1000
+	}
+}
+
+// reloadConfig re-reads the config file on SIGHUP and applies the settings
+// that can change live: log level and sampling, max clients, the AOF sync policy and
+// store memory limits. Other changes are logged as needing a restart. On a
+// read or validation error the current config stays in effect. Returns the
+// config to compare the next reload against.
+func reloadConfig(ctx context.Context, cfg *config.Config, logger *logging.Logger, storeManager *storage.StoreManager, respServer *resp.Server) *config.Config {
+	next, changes, err := config.Reload(*configPath, cfg, applyFlagOverrides)
+	if err != nil {
+		logging.Error(ctx, logging.ComponentConfig, logging.ActionValidation, "Config reload failed, keeping current config", err, map[string]interface{}{
+			"config_file": *configPath,
+		})
+		return cfg
+	}
+
+	applied, restart := 0, 0
+	for _, change := range changes {
+		fields := map[string]interface{}{"setting": change.Setting, "old": change.Old, "new": change.New}
+		if !change.Live {
+			restart++
+			logging.Warn(ctx, logging.ComponentConfig, logging.ActionValidation, "Config setting changed; restart to apply", fields)
+			continue
+		}
+
+		var err error
+		switch change.Setting {
+		case "logging.level":
+			logger.SetLevel(logging.LogLevelFromString(change.New))
+		case "logging.sample_rate":
+			logger.SetSampleRate(next.Logging.SampleRate)
+		case "network.max_clients":
+			if respServer != nil {
+				maxClients := next.Network.MaxClients
+				if maxClients == 0 {
+					maxClients = resp.DefaultServerConfig().MaxConnections
+				}
+				respServer.SetMaxConnections(maxClients)
+			}
+		case "network.resp_error_tokens":
+			if respServer != nil {
+				respServer.SetErrorTokens(next.Network.RESPErrorTokens)
+			}
+		case "persistence.sync_policy":
+			if storeManager != nil {
+				storeManager.SetSyncPolicy(change.New)
+			}
+		default: // stores.<name>.max_memory
+			if storeManager != nil {
+				err = storeManager.SetStoreMaxMemory(change.Store, change.New)
+			}
+		}
+		if err != nil {
+			logging.Error(ctx, logging.ComponentConfig, logging.ActionValidation, "Failed to apply config setting", err, fields)
+			continue
+		}
+		applied++
+		logging.Info(ctx, logging.ComponentConfig, logging.ActionValidation, "Config setting applied", fields)
+	}
+
+	logging.Info(ctx, logging.ComponentConfig, logging.ActionValidation, "Config reloaded", map[string]interface{}{
+		"config_file":      *configPath,
+		"applied":          applied,
+		"requires_restart": restart,
+	})
+	return next
+}
+
+// registerAPIRoutes adds the REST endpoints to the HTTP server's mux. It is
+// called once the stores and coordinator are up; until then only the health
+// probes are served.
+func registerAPIRoutes(mux *http.ServeMux, coordinator cluster.CoordinatorService, storeManager *storage.StoreManager, nodeID string, cfg *config.Config, nodeCommunicator *cluster.NodeCommunicator, auditLog *logging.AuditLog) {
+	bodyLimit := maxBodySize(cfg)
 
 	store := storeManager.GetDefaultStore()
 
@@ -329,6 +511,46 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 		json.NewEncoder(w).Encode(response)
 	})
 
+	// Cluster health endpoint: every member's health, gathered with a gossip query
+	mux.HandleFunc("/api/cluster/health", func(w http.ResponseWriter, r *http.Request) {
+		correlationID := logging.GetCorrelationID(r.Context())
+		if correlationID == "" {
+			correlationID = logging.NewCorrelationID()
+			r = r.WithContext(logging.WithCorrelationID(r.Context(), correlationID))
+		}
+
+		logging.Debug(r.Context(), logging.ComponentHTTP, "cluster_health", "Cluster health requested")
+
+		querier, ok := coordinator.(interface {
+			QueryClusterHealth(ctx context.Context) (map[string]cluster.CoordinatorHealth, error)
+		})
+		if !ok {
+			http.Error(w, "Cluster health queries not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		nodes, err := querier.QueryClusterHealth(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Cluster health query failed: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+
+		healthy := len(nodes) > 0
+		for _, health := range nodes {
+			healthy = healthy && health.Healthy
+		}
+		response := map[string]interface{}{
+			"healthy":          healthy,
+			"nodes":            nodes,
+			"responding_nodes": len(nodes),
+			"node":             nodeID,
+			"correlation_id":   correlationID,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Correlation-ID", correlationID)
+		json.NewEncoder(w).Encode(response)
+	})
+
 	// Create read-repairer for cross-node GET during gossip propagation window
 	readRepairer := cluster.NewReadRepairer(coordinator)
 
@@ -352,7 +574,7 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 	})
 
 	// Internal endpoint: receive direct replication from hash-ring owner
-	mux.HandleFunc("/internal/replicate", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/internal/replicate", logging.CorrelationIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -364,9 +586,21 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 			TTL       float64     `json:"ttl"`
 			LamportTS uint64      `json:"lamport_ts"`
 			FromNode  string      `json:"from_node"`
+			WrittenAt int64       `json:"written_at"` // unix ns of the owner's write
 		}
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		// Peers gzip large payloads because we advertise the compression capability
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, "Invalid gzip body", http.StatusBadRequest)
+				return
+			}
+			defer zr.Close()
+			body = http.MaxBytesReader(w, zr, bodyLimit) // bound the decompressed size too
+		}
+		if err := json.NewDecoder(body).Decode(&payload); err != nil {
+			writeBodyError(w, err, "Invalid JSON")
 			return
 		}
 
@@ -380,20 +614,114 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 			coordinator.GetClock().Witness(payload.LamportTS)
 		}
 
+		applyStart := time.Now()
+		operation := "SET"
 		if payload.Value == nil {
-			// This is a DELETE replication
+			// This is a DELETE replication; the key may already be gone
+			operation = "DELETE"
 			_ = store.Delete(payload.Key)
 		} else {
 			ttl := time.Duration(payload.TTL) * time.Second
-			_, _ = store.SetWithTimestamp(r.Context(), payload.Key, payload.Value, "replication", ttl, payload.LamportTS)
+			if _, err := store.SetWithTimestamp(r.Context(), payload.Key, payload.Value, "replication", ttl, payload.LamportTS); err != nil {
+				logging.Error(r.Context(), logging.ComponentCluster, logging.ActionReplication, "Failed to apply direct replication", err, map[string]interface{}{
+					"operation":  operation,
+					"key":        payload.Key,
+					"from_node":  payload.FromNode,
+					"lamport_ts": payload.LamportTS,
+				})
+				http.Error(w, "Failed to apply replication", http.StatusInternalServerError)
+				return
+			}
+			if payload.WrittenAt > 0 && payload.FromNode != "" {
+				metrics.Global().ReplicationLag().Record(payload.FromNode, time.Unix(0, payload.WrittenAt))
+			}
 		}
+		metrics.Global().Latency().Record(metrics.LatencyReplicationApply, time.Since(applyStart))
+
+		logging.DebugSampled(r.Context(), logging.ComponentCluster, logging.ActionReplication, "Applied direct replication", map[string]interface{}{
+			"operation": operation,
+			"key":       payload.Key,
+			"from_node": payload.FromNode,
+		})
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
-	})
+	})))
+
+	// Internal endpoint: receive a batch of keys migrated with MigrateSlotRange
+	mux.Handle("/internal/replicate-batch", logging.CorrelationIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var payload struct {
+			Entries  []cluster.MigrationEntry `json:"entries"`
+			FromNode string                   `json:"from_node"`
+		}
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, "Invalid gzip body", http.StatusBadRequest)
+				return
+			}
+			defer zr.Close()
+			body = http.MaxBytesReader(w, zr, bodyLimit)
+		}
+		if err := json.NewDecoder(body).Decode(&payload); err != nil {
+			writeBodyError(w, err, "Invalid JSON")
+			return
+		}
+
+		// Entries are applied in order up to the first failure; the sender
+		// treats anything short of all of them as a failed batch and retries
+		applyStart := time.Now()
+		applied := 0
+		for _, entry := range payload.Entries {
+			if entry.Key == "" || entry.Value == nil {
+				break
+			}
+			target := store
+			if entry.Store != "" {
+				if target = storeManager.GetStore(entry.Store); target == nil {
+					logging.Warn(r.Context(), logging.ComponentCluster, logging.ActionReplication, "Migrated key for an unknown store", map[string]interface{}{
+						"key":       entry.Key,
+						"store":     entry.Store,
+						"from_node": payload.FromNode,
+					})
+					break
+				}
+			}
+			if coordinator.GetClock() != nil && entry.LamportTS > 0 {
+				coordinator.GetClock().Witness(entry.LamportTS)
+			}
+			ttl := time.Duration(entry.TTL * float64(time.Second))
+			if _, err := target.RestoreRaw(r.Context(), entry.Key, entry.Value, entry.ValueType, entry.SessionID, ttl, entry.LamportTS); err != nil {
+				logging.Error(r.Context(), logging.ComponentCluster, logging.ActionReplication, "Failed to apply migrated key", err, map[string]interface{}{
+					"key":       entry.Key,
+					"store":     entry.Store,
+					"from_node": payload.FromNode,
+				})
+				break
+			}
+			applied++
+		}
+		metrics.Global().Latency().Record(metrics.LatencyReplicationApply, time.Since(applyStart))
+
+		logging.Debug(r.Context(), logging.ComponentCluster, logging.ActionReplication, "Applied migration batch", map[string]interface{}{
+			"keys":      applied,
+			"from_node": payload.FromNode,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"applied": applied})
+	})))
 
 	// Cache operations with middleware
-	mux.Handle("/api/cache/", logging.HTTPMiddleware(http.HandlerFunc(handleCacheRequest(coordinator, store, nodeID, readRepairer, nodeCommunicator, cfg.Cluster.ConsistencyLevel))))
+	mux.Handle("/api/cache", logging.HTTPMiddleware(handleBulkDelete(coordinator, store, nodeID, nodeCommunicator, auditLog)))
+	mux.Handle("/api/cache/events", logging.HTTPMiddleware(handleKeyspaceEvents(store)))
+	mux.Handle("/api/cache/", logging.HTTPMiddleware(http.HandlerFunc(handleCacheRequest(coordinator, store, nodeID, readRepairer, nodeCommunicator, cfg.Cluster.ConsistencyLevel, auditLog))))
 
 	// Cuckoo filter endpoints
 	mux.HandleFunc("/api/filter/stats", func(w http.ResponseWriter, r *http.Request) {
@@ -470,7 +798,7 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 				Persistence    string `json:"persistence"`
 			}
 			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-				http.Error(w, `{"error":"invalid JSON body"}`, http.StatusBadRequest)
+				writeBodyError(w, err, `{"error":"invalid JSON body"}`)
 				return
 			}
 			if body.Name == "" {
@@ -481,6 +809,14 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 				http.Error(w, `{"error":"max_memory is required"}`, http.StatusBadRequest)
 				return
 			}
+			if _, err := config.ParseSize(body.MaxMemory); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"success": false,
+					"error":   err.Error(),
+				})
+				return
+			}
 			if body.EvictionPolicy == "" {
 				body.EvictionPolicy = "lru"
 			}
@@ -564,6 +900,12 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 		fmt.Fprintf(&b, "# TYPE hypercache_evictions_total counter\n")
 		fmt.Fprintf(&b, "hypercache_evictions_total{node=\"%s\"} %d\n", nodeID, stats.EvictionCount)
 
+		fmt.Fprintf(&b, "# HELP hypercache_removals_total Keys removed, by reason (expired, memory, deleted)\n")
+		fmt.Fprintf(&b, "# TYPE hypercache_removals_total counter\n")
+		fmt.Fprintf(&b, "hypercache_removals_total{node=\"%s\",reason=\"expired\"} %d\n", nodeID, stats.ExpiredCount)
+		fmt.Fprintf(&b, "hypercache_removals_total{node=\"%s\",reason=\"memory\"} %d\n", nodeID, stats.MemoryEvictionCount)
+		fmt.Fprintf(&b, "hypercache_removals_total{node=\"%s\",reason=\"deleted\"} %d\n", nodeID, stats.ManualDeleteCount)
+
 		fmt.Fprintf(&b, "# HELP hypercache_errors_total Total errors\n")
 		fmt.Fprintf(&b, "# TYPE hypercache_errors_total counter\n")
 		fmt.Fprintf(&b, "hypercache_errors_total{node=\"%s\"} %d\n", nodeID, stats.ErrorCount)
@@ -572,6 +914,10 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 		fmt.Fprintf(&b, "# TYPE hypercache_hit_rate gauge\n")
 		fmt.Fprintf(&b, "hypercache_hit_rate{node=\"%s\"} %.2f\n", nodeID, stats.HitRate())
 
+		fmt.Fprintf(&b, "# HELP hypercache_recent_hit_rate Cache hit rate percentage over the last minute\n")
+		fmt.Fprintf(&b, "# TYPE hypercache_recent_hit_rate gauge\n")
+		fmt.Fprintf(&b, "hypercache_recent_hit_rate{node=\"%s\"} %.2f\n", nodeID, store.RecentHitRate())
+
 		// Memory pool metrics
 		poolStats := store.GetMemoryPoolStats()
 		if poolStats != nil {
@@ -592,6 +938,14 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 			}
 		}
 
+		// Key TTL distribution
+		fmt.Fprintf(&b, "# HELP hypercache_keys_by_ttl Keys by remaining TTL (sampled on large stores)\n")
+		fmt.Fprintf(&b, "# TYPE hypercache_keys_by_ttl gauge\n")
+		histogram := store.TTLHistogram()
+		for _, bucket := range storage.TTLHistogramBuckets {
+			fmt.Fprintf(&b, "hypercache_keys_by_ttl{node=\"%s\",bucket=\"%s\"} %d\n", nodeID, bucket, histogram[bucket])
+		}
+
 		// Cluster metrics
 		fmt.Fprintf(&b, "# HELP hypercache_cluster_healthy Whether the cluster is healthy (1=yes, 0=no)\n")
 		fmt.Fprintf(&b, "# TYPE hypercache_cluster_healthy gauge\n")
@@ -614,9 +968,15 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 
 		w.Write([]byte(b.String()))
 	})
+}
+
+// startHTTPServer serves the HTTP API from mux until ctx is cancelled.
+// Routes may be added to mux while it is being served.
+func startHTTPServer(ctx context.Context, mux *http.ServeMux, port int, nodeID string, cfg *config.Config) error {
+	bodyLimit := maxBodySize(cfg)
 
 	// Wrap the main handler with CORS and logging middleware
-	handler := logging.CorrelationIDMiddleware(mux)
+	handler := corsMiddleware(cfg.Network.CORS, logging.CorrelationIDMiddleware(limitRequestBody(bodyLimit, mux)))
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
@@ -661,7 +1021,7 @@ func startHTTPServer(ctx context.Context, coordinator cluster.CoordinatorService
 	}
 }
 
-func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.BasicStore, nodeID string, readRepairer *cluster.ReadRepairer, nodeCommunicator *cluster.NodeCommunicator, consistencyLevel string) http.HandlerFunc {
+func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.BasicStore, nodeID string, readRepairer *cluster.ReadRepairer, nodeCommunicator *cluster.NodeCommunicator, consistencyLevel string, auditLog *logging.AuditLog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract key from URL path
 		path := strings.TrimPrefix(r.URL.Path, "/api/cache/")
@@ -675,6 +1035,11 @@ func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.B
 		// Check if this request was already proxied (prevent infinite loops)
 		isProxied := r.Header.Get("X-HyperCache-Proxied") == "true"
 
+		// Proxied requests were audited by the node the client called
+		if auditLog != nil && !isProxied {
+			auditHTTPAccess(auditLog, r, key)
+		}
+
 		// Hash-ring routing: check if this node owns the key
 		if !isProxied && coordinator != nil && coordinator.GetRouting() != nil && nodeCommunicator != nil {
 			routing := coordinator.GetRouting()
@@ -703,13 +1068,20 @@ func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.B
 
 					case http.MethodPut:
 						var requestBody struct {
-							Value interface{} `json:"value"`
+							Value      interface{} `json:"value"`
+							TTLSeconds *int64      `json:"ttl_seconds"`
 						}
 						if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-							http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+							writeBodyError(w, err, "Invalid JSON body")
 							return
 						}
-						err := nodeCommunicator.ProxySet(r.Context(), ownerNode, key, requestBody.Value, 3600)
+						ttl, err := requestTTL(r, requestBody.TTLSeconds)
+						if err != nil {
+							http.Error(w, err.Error(), http.StatusBadRequest)
+							return
+						}
+						// The owner applies its store's DefaultTTL if none was given
+						err = nodeCommunicator.ProxySet(r.Context(), ownerNode, key, requestBody.Value, ttl.Seconds())
 						if err != nil {
 							http.Error(w, fmt.Sprintf("Failed to route SET: %v", err), http.StatusBadGateway)
 							return
@@ -824,21 +1196,27 @@ func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.B
 
 			// Set operation
 			var requestBody struct {
-				Value interface{} `json:"value"`
+				Value      interface{} `json:"value"`
+				TTLSeconds *int64      `json:"ttl_seconds"`
 			}
 
 			if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
 				logging.Error(r.Context(), logging.ComponentCache, "put_request", "Failed to decode PUT request body", err, map[string]interface{}{
 					"key": key,
 				})
-				http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+				writeBodyError(w, err, "Invalid JSON body")
 				return
 			}
 
-			// Store the value with default TTL (1 hour)
-			ttl := time.Hour
+			requested, err := requestTTL(r, requestBody.TTLSeconds)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			ttl := effectiveTTL(requested, store)
+
 			timer := logging.StartTimer(r.Context(), logging.ComponentCache, "set_operation", "Cache SET operation")
-			err := store.Set(key, requestBody.Value, "http-api", ttl)
+			err = store.Set(key, requestBody.Value, "http-api", ttl)
 			timer()
 
 			if err != nil {
@@ -851,8 +1229,8 @@ func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.B
 			}
 
 			logging.Info(r.Context(), logging.ComponentCache, "put_request", "Cache PUT operation successful", map[string]interface{}{
-				"key":       key,
-				"ttl_hours": ttl.Hours(),
+				"key":         key,
+				"ttl_seconds": ttlSeconds(ttl),
 			})
 
 			// Publish SET event to event bus for replication to other nodes
@@ -894,7 +1272,7 @@ func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.B
 					}()
 				}
 
-				logging.Info(r.Context(), logging.ComponentEventBus, logging.ActionReplication, "SET replicated via hash ring", map[string]interface{}{
+				logging.DebugSampled(r.Context(), logging.ComponentEventBus, logging.ActionReplication, "SET replicated via hash ring", map[string]interface{}{
 					"key":      key,
 					"replicas": replicas,
 				})
@@ -904,8 +1282,9 @@ func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.B
 				"success": true,
 				"message": "Key set successfully",
 				"data": map[string]interface{}{
-					"key":   key,
-					"value": requestBody.Value,
+					"key":         key,
+					"value":       requestBody.Value,
+					"ttl_seconds": ttlSeconds(ttl),
 				},
 				"node":           nodeID,
 				"replicated":     true,
@@ -938,36 +1317,8 @@ func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.B
 				})
 			}
 
-			// Publish DELETE event — hash-ring targeted replication (synchronous)
-			// DELETEs are replicated synchronously to ensure consistency before responding.
 			// Always replicate deletes (even if key wasn't found locally, it may exist on replicas)
-			if coordinator != nil && nodeCommunicator != nil && coordinator.GetRouting() != nil {
-				lamportTS := uint64(0)
-				if coordinator.GetClock() != nil {
-					lamportTS = coordinator.GetClock().Tick()
-				}
-
-				replicas := coordinator.GetRouting().GetReplicas(key, 3)
-				for _, replica := range replicas {
-					if replica == nodeID {
-						continue
-					}
-					if err := nodeCommunicator.ReplicateEntry(
-						context.Background(), replica, key, nil, 0, lamportTS,
-					); err != nil {
-						logging.Error(r.Context(), logging.ComponentCluster, logging.ActionReplication, "DELETE replication failed", err, map[string]interface{}{
-							"key": key, "target": replica,
-						})
-					}
-				}
-
-				logging.Info(r.Context(), logging.ComponentEventBus, logging.ActionReplication, "DELETE replicated via hash ring", map[string]interface{}{
-					"key":      key,
-					"replicas": replicas,
-				})
-			}
-
-			replicated := coordinator != nil && coordinator.GetRouting() != nil && nodeCommunicator != nil
+			replicated := replicateDelete(r.Context(), coordinator, nodeCommunicator, nodeID, key)
 
 			response := map[string]interface{}{
 				"success":        true,
@@ -996,6 +1347,90 @@ func handleCacheRequest(coordinator cluster.CoordinatorService, store *storage.B
 	}
 }
 
+// replicateDelete sends a DELETE of key to its hash-ring replicas. DELETEs
+// are replicated synchronously to ensure consistency before responding.
+// Reports whether replication is configured.
+func replicateDelete(ctx context.Context, coordinator cluster.CoordinatorService, nodeCommunicator *cluster.NodeCommunicator, nodeID, key string) bool {
+	if coordinator == nil || nodeCommunicator == nil || coordinator.GetRouting() == nil {
+		return false
+	}
+
+	lamportTS := uint64(0)
+	if coordinator.GetClock() != nil {
+		lamportTS = coordinator.GetClock().Tick()
+	}
+
+	replicas := coordinator.GetRouting().GetReplicas(key, 3)
+	for _, replica := range replicas {
+		if replica == nodeID {
+			continue
+		}
+		if err := nodeCommunicator.ReplicateEntry(
+			context.Background(), replica, key, nil, 0, lamportTS,
+		); err != nil {
+			logging.Error(ctx, logging.ComponentCluster, logging.ActionReplication, "DELETE replication failed", err, map[string]interface{}{
+				"key": key, "target": replica,
+			})
+		}
+	}
+
+	logging.DebugSampled(ctx, logging.ComponentEventBus, logging.ActionReplication, "DELETE replicated via hash ring", map[string]interface{}{
+		"key":      key,
+		"replicas": replicas,
+	})
+	return true
+}
+
+// handleBulkDelete handles DELETE /api/cache?match=pattern: deletes the
+// keys on this node matching the glob pattern (Redis KEYS syntax) and
+// replicates each deletion. A pattern matching every key (empty or only
+// '*') is refused unless confirm=true, to prevent an accidental flush.
+func handleBulkDelete(coordinator cluster.CoordinatorService, store *storage.BasicStore, nodeID string, nodeCommunicator *cluster.NodeCommunicator, auditLog *logging.AuditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		pattern := query.Get("match")
+		if strings.Trim(pattern, "*") == "" && query.Get("confirm") != "true" {
+			http.Error(w, "Pattern matches every key; add confirm=true to delete them all", http.StatusBadRequest)
+			return
+		}
+		if pattern == "" {
+			pattern = "*"
+		}
+
+		timer := logging.StartTimer(r.Context(), logging.ComponentCache, "delete_operation", "Cache bulk DELETE operation")
+		deleted := store.DeleteMatching(pattern)
+		timer()
+
+		for _, key := range deleted {
+			if auditLog != nil {
+				auditHTTPAccess(auditLog, r, key)
+			}
+			replicateDelete(r.Context(), coordinator, nodeCommunicator, nodeID, key)
+		}
+		replicated := coordinator != nil && coordinator.GetRouting() != nil && nodeCommunicator != nil
+
+		logging.Info(r.Context(), logging.ComponentCache, "delete_request", "Cache bulk DELETE operation successful", map[string]interface{}{
+			"pattern": pattern,
+			"deleted": len(deleted),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":        true,
+			"pattern":        pattern,
+			"deleted":        len(deleted),
+			"node":           nodeID,
+			"replicated":     replicated,
+			"correlation_id": logging.GetCorrelationID(r.Context()),
+		})
+	}
+}
+
 // handleStoreRequest handles store-scoped operations:
 //   - GET/DELETE /api/stores/{name} — store info / drop store
 //   - GET/PUT/DELETE /api/stores/{name}/cache/{key} — data operations on a specific store
@@ -1026,11 +1461,12 @@ func handleStoreRequest(w http.ResponseWriter, r *http.Request, storeManager *st
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"store": storeName,
 				"stats": map[string]interface{}{
-					"total_items":  stats.TotalItems,
-					"total_memory": stats.TotalMemory,
-					"hit_count":    stats.HitCount,
-					"miss_count":   stats.MissCount,
-					"hit_rate":     stats.HitRate(),
+					"total_items":     stats.TotalItems,
+					"total_memory":    stats.TotalMemory,
+					"hit_count":       stats.HitCount,
+					"miss_count":      stats.MissCount,
+					"hit_rate":        stats.HitRate(),
+					"recent_hit_rate": s.RecentHitRate(),
 				},
 				"node": nodeID,
 			})
@@ -1092,44 +1528,49 @@ func handleStoreRequest(w http.ResponseWriter, r *http.Request, storeManager *st
 
 	case http.MethodPut:
 		var body struct {
-			Value interface{} `json:"value"`
+			Value      interface{} `json:"value"`
+			TTLSeconds *int64      `json:"ttl_seconds"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, `{"error":"invalid JSON body"}`, http.StatusBadRequest)
+			writeBodyError(w, err, `{"error":"invalid JSON body"}`)
 			return
 		}
-		ttl := time.Hour
+		requested, err := requestTTL(r, body.TTLSeconds)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		ttl := effectiveTTL(requested, s)
 		if err := s.Set(key, body.Value, "http-api", ttl); err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
 			return
 		}
 
 		// Publish replication event with store name
-		if coordinator != nil && coordinator.GetEventBus() != nil {
-			lamportTS := uint64(0)
-			if coordinator.GetClock() != nil {
-				lamportTS = coordinator.GetClock().Tick()
-			}
-			_ = coordinator.GetEventBus().Publish(r.Context(), cluster.ClusterEvent{
-				Type:          cluster.EventDataOperation,
-				NodeID:        nodeID,
-				CorrelationID: logging.GetCorrelationID(r.Context()),
-				Timestamp:     time.Now(),
-				Data: map[string]interface{}{
-					"operation":  "SET",
-					"key":        key,
-					"value":      body.Value,
-					"ttl":        ttl.Seconds(),
-					"store":      storeName,
-					"lamport_ts": lamportTS,
-				},
-			})
+		lamportTS := uint64(0)
+		if coordinator != nil && coordinator.GetClock() != nil {
+			lamportTS = coordinator.GetClock().Tick()
 		}
+		_ = cluster.EventBusOf(coordinator).Publish(r.Context(), cluster.ClusterEvent{
+			Type:          cluster.EventDataOperation,
+			NodeID:        nodeID,
+			CorrelationID: logging.GetCorrelationID(r.Context()),
+			Timestamp:     time.Now(),
+			Data: map[string]interface{}{
+				"operation":  "SET",
+				"key":        key,
+				"value":      body.Value,
+				"ttl":        ttl.Seconds(),
+				"store":      storeName,
+				"lamport_ts": lamportTS,
+			},
+		})
 
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
 			"message": "Key set successfully",
-			"data":    map[string]interface{}{"key": key, "value": body.Value},
+			"data":    map[string]interface{}{"key": key, "value": body.Value, "ttl_seconds": ttlSeconds(ttl)},
 			"store":   storeName,
 			"node":    nodeID,
 		})
@@ -1138,12 +1579,12 @@ func handleStoreRequest(w http.ResponseWriter, r *http.Request, storeManager *st
 		err := s.Delete(key)
 		existed := err == nil
 
-		if existed && coordinator != nil && coordinator.GetEventBus() != nil {
+		if existed {
 			lamportTS := uint64(0)
-			if coordinator.GetClock() != nil {
+			if coordinator != nil && coordinator.GetClock() != nil {
 				lamportTS = coordinator.GetClock().Tick()
 			}
-			_ = coordinator.GetEventBus().Publish(r.Context(), cluster.ClusterEvent{
+			_ = cluster.EventBusOf(coordinator).Publish(r.Context(), cluster.ClusterEvent{
 				Type:          cluster.EventDataOperation,
 				NodeID:        nodeID,
 				CorrelationID: logging.GetCorrelationID(r.Context()),
@@ -1170,6 +1611,69 @@ func handleStoreRequest(w http.ResponseWriter, r *http.Request, storeManager *st
 	}
 }
 
+// requestTTL returns the TTL an HTTP PUT asks for, in whole seconds from the
+// ?ttl= query parameter or else the body's ttl_seconds. 0 means no expiry
+// (storage.NoExpiry); with neither given it returns 0, so the store's
+// DefaultTTL applies.
+func requestTTL(r *http.Request, bodySeconds *int64) (time.Duration, error) {
+	seconds := bodySeconds
+	if q := r.URL.Query().Get("ttl"); q != "" {
+		n, err := strconv.ParseInt(q, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl '%s'", q)
+		}
+		seconds = &n
+	}
+	switch {
+	case seconds == nil:
+		return 0, nil
+	case *seconds < 0:
+		return 0, fmt.Errorf("ttl cannot be negative")
+	case *seconds == 0:
+		return storage.NoExpiry, nil
+	}
+	return time.Duration(*seconds) * time.Second, nil
+}
+
+// effectiveTTL resolves a requested TTL (0 = store default) to the one a
+// write to store gets, storage.NoExpiry if the key won't expire. Replicas
+// are sent this rather than applying their own default.
+func effectiveTTL(requested time.Duration, store *storage.BasicStore) time.Duration {
+	if requested == 0 {
+		requested = store.DefaultTTL()
+	}
+	if requested <= 0 {
+		return storage.NoExpiry
+	}
+	return requested
+}
+
+// ttlSeconds reports a TTL in HTTP responses: whole seconds, 0 = no expiry
+func ttlSeconds(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return int64(ttl / time.Second)
+}
+
+// flushStores clears the named store, or every store when name is empty
+func flushStores(storeManager *storage.StoreManager, name string) error {
+	names := storeManager.ListStores()
+	if name != "" {
+		names = []string{name}
+	}
+	for _, storeName := range names {
+		store := storeManager.GetStore(storeName)
+		if store == nil {
+			return fmt.Errorf("store %s not found", storeName)
+		}
+		if err := store.Clear(); err != nil {
+			return fmt.Errorf("failed to clear store %s: %w", storeName, err)
+		}
+	}
+	return nil
+}
+
 // handleReplicationEvent processes incoming replication events from other nodes
 func handleReplicationEvent(ctx context.Context, event cluster.ClusterEvent, storeManager *storage.StoreManager, nodeID string, coordinator cluster.CoordinatorService) {
 	// Skip events from ourselves — no need to log, the originating request already has full tracing
@@ -1177,10 +1681,11 @@ func handleReplicationEvent(ctx context.Context, event cluster.ClusterEvent, sto
 		return
 	}
 
-	// Create context with correlation ID from the event
+	// Create context with correlation ID from the event. Per-event logs are
+	// sampled debug logs so busy clusters don't flood the output
 	correlationCtx := logging.WithCorrelationID(ctx, event.CorrelationID)
 
-	logging.Info(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Received replication event", map[string]interface{}{
+	logging.DebugSampled(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Received replication event", map[string]interface{}{
 		"event_type":     event.Type,
 		"source_node":    event.NodeID,
 		"target_node":    nodeID,
@@ -1234,6 +1739,11 @@ func handleReplicationEvent(ctx context.Context, event cluster.ClusterEvent, sto
 			coordinator.GetClock().Witness(lamportTS)
 		}
 
+		applyStart := time.Now()
+		defer func() {
+			metrics.Global().Latency().Record(metrics.LatencyReplicationApply, time.Since(applyStart))
+		}()
+
 		switch operation {
 		case "SET":
 			// Pre-populate the Cuckoo filter immediately so concurrent GET requests
@@ -1252,18 +1762,21 @@ func handleReplicationEvent(ctx context.Context, event cluster.ClusterEvent, sto
 			// Use SetWithTimestamp to enforce causal ordering —
 			// only overwrite if the incoming timestamp is newer
 			applied, err := store.SetWithTimestamp(correlationCtx, key, value, "replication", ttl, lamportTS)
+			if err == nil && !event.Timestamp.IsZero() {
+				metrics.Global().ReplicationLag().Record(event.NodeID, event.Timestamp)
+			}
 			if err != nil {
 				logging.Error(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Failed to apply replicated SET", err, map[string]interface{}{
 					"key":        key,
 					"lamport_ts": lamportTS,
 				})
 			} else if applied {
-				logging.Info(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Successfully applied replicated SET", map[string]interface{}{
+				logging.DebugSampled(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Successfully applied replicated SET", map[string]interface{}{
 					"key":        key,
 					"lamport_ts": lamportTS,
 				})
 			} else {
-				logging.Info(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Skipped stale replicated SET (local is newer)", map[string]interface{}{
+				logging.DebugSampled(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Skipped stale replicated SET (local is newer)", map[string]interface{}{
 					"key":       key,
 					"remote_ts": lamportTS,
 					"local_ts":  store.GetTimestamp(key),
@@ -1275,17 +1788,17 @@ func handleReplicationEvent(ctx context.Context, event cluster.ClusterEvent, sto
 			// For deletes, check timestamp: don't delete if a newer SET has occurred locally
 			localTS := store.GetTimestamp(key)
 			if lamportTS > 0 && localTS > lamportTS {
-				logging.Info(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Skipped stale replicated DELETE (local SET is newer)", map[string]interface{}{
+				logging.DebugSampled(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Skipped stale replicated DELETE (local SET is newer)", map[string]interface{}{
 					"key":       key,
 					"remote_ts": lamportTS,
 					"local_ts":  localTS,
 				})
 			} else if err := store.Delete(key); err != nil {
-				logging.Info(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Replicated DELETE (key already absent)", map[string]interface{}{
+				logging.DebugSampled(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Replicated DELETE (key already absent)", map[string]interface{}{
 					"key": key,
 				})
 			} else {
-				logging.Info(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Successfully applied replicated DELETE", map[string]interface{}{
+				logging.DebugSampled(correlationCtx, logging.ComponentCluster, logging.ActionReplication, "Successfully applied replicated DELETE", map[string]interface{}{
 					"key":        key,
 					"lamport_ts": lamportTS,
 				})
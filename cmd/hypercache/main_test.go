@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"hypercache/internal/cluster"
+	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
+	"hypercache/internal/storage"
+	"hypercache/pkg/config"
+)
+
+func TestHTTPPutTTL(t *testing.T) {
+	store, err := storage.NewBasicStore(storage.BasicStoreConfig{
+		Name:       "default",
+		MaxMemory:  1024 * 1024,
+		DefaultTTL: 10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	handler := handleCacheRequest(nil, store, "node-1", nil, nil, "eventual", nil)
+	put := func(path, body string) (int, int64) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusOK {
+			return rec.Code, 0
+		}
+		var resp struct {
+			Data struct {
+				TTLSeconds int64 `json:"ttl_seconds"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return rec.Code, resp.Data.TTLSeconds
+	}
+	expectTTL := func(key string, want time.Duration) {
+		t.Helper()
+		ttl, ok := store.TTL(key)
+		if !ok {
+			t.Fatalf("%s: key missing", key)
+		}
+		if want < 0 {
+			if ttl != -1 {
+				t.Errorf("%s: TTL = %v, want no expiry", key, ttl)
+			}
+			return
+		}
+		if ttl <= want-time.Second || ttl > want {
+			t.Errorf("%s: TTL = %v, want ~%v", key, ttl, want)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		code     int
+		reported int64
+		ttl      time.Duration // -1 = no expiry
+	}{
+		{"body ttl", "/api/cache/a", `{"value":"v","ttl_seconds":5}`, http.StatusOK, 5, 5 * time.Second},
+		{"query ttl", "/api/cache/b?ttl=7", `{"value":"v"}`, http.StatusOK, 7, 7 * time.Second},
+		{"query overrides body", "/api/cache/c?ttl=3", `{"value":"v","ttl_seconds":50}`, http.StatusOK, 3, 3 * time.Second},
+		{"store default", "/api/cache/d", `{"value":"v"}`, http.StatusOK, 600, 10 * time.Minute},
+		{"zero = no expiry", "/api/cache/e", `{"value":"v","ttl_seconds":0}`, http.StatusOK, 0, -1},
+		{"query zero", "/api/cache/f?ttl=0", `{"value":"v"}`, http.StatusOK, 0, -1},
+		{"negative", "/api/cache/g", `{"value":"v","ttl_seconds":-1}`, http.StatusBadRequest, 0, 0},
+		{"not a number", "/api/cache/h?ttl=soon", `{"value":"v"}`, http.StatusBadRequest, 0, 0},
+	}
+	for _, tt := range tests {
+		code, reported := put(tt.path, tt.body)
+		if code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.name, code, tt.code)
+			continue
+		}
+		if code != http.StatusOK {
+			continue
+		}
+		if reported != tt.reported {
+			t.Errorf("%s: reported ttl_seconds = %d, want %d", tt.name, reported, tt.reported)
+		}
+		expectTTL(strings.TrimPrefix(strings.SplitN(tt.path, "?", 2)[0], "/api/cache/"), tt.ttl)
+	}
+}
+
+func TestHTTPPutBodyLimit(t *testing.T) {
+	store, err := storage.NewBasicStore(storage.BasicStoreConfig{Name: "default", MaxMemory: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	handler := limitRequestBody(1024, handleCacheRequest(nil, store, "node-1", nil, nil, "eventual", nil))
+
+	big := `{"value":"` + strings.Repeat("x", 2048) + `"}`
+	req := httptest.NewRequest(http.MethodPut, "/api/cache/big", strings.NewReader(big))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Oversized PUT: status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if _, err := store.Get("big"); err == nil {
+		t.Error("Oversized PUT should not store the key")
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/cache/small", strings.NewReader(`{"value":"ok"}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Small PUT: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/cache/bad", strings.NewReader(`{"value":`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Malformed PUT: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHTTPBulkDelete(t *testing.T) {
+	store, err := storage.NewBasicStore(storage.BasicStoreConfig{Name: "default", MaxMemory: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for _, key := range []string{"temp:1", "temp:2", "temp:3", "user:1", "temporary"} {
+		if err := store.Set(key, "v", "", 0); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+
+	handler := handleBulkDelete(nil, store, "node-1", nil, nil)
+	del := func(query string) (int, int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodDelete, "/api/cache?"+query, nil))
+		var resp struct {
+			Deleted int `json:"deleted"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec.Code, resp.Deleted
+	}
+
+	if code, deleted := del("match=temp:*"); code != http.StatusOK || deleted != 3 {
+		t.Errorf("match=temp:* = %d, deleted %d; want 200, 3", code, deleted)
+	}
+	for _, key := range []string{"user:1", "temporary"} {
+		if _, err := store.Get(key); err != nil {
+			t.Errorf("%s should survive: %v", key, err)
+		}
+	}
+
+	// Deleting everything needs confirmation
+	for _, query := range []string{"", "match=", "match=**"} {
+		if code, _ := del(query); code != http.StatusBadRequest {
+			t.Errorf("%q without confirm: status = %d, want 400", query, code)
+		}
+	}
+	if code, deleted := del("match=*&confirm=true"); code != http.StatusOK || deleted != 2 {
+		t.Errorf("confirmed full delete = %d, deleted %d; want 200, 2", code, deleted)
+	}
+	if n := store.Size(); n != 0 {
+		t.Errorf("Store has %d keys after a confirmed full delete", n)
+	}
+}
+
+func TestHTTPKeyspaceEventStream(t *testing.T) {
+	store, err := storage.NewBasicStore(storage.BasicStoreConfig{Name: "default", MaxMemory: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	server := httptest.NewServer(logging.HTTPMiddleware(handleKeyspaceEvents(store)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/cache/events?prefix=user:")
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// The stream is subscribed once its headers arrive
+	store.Set("session:1", "v", "", 0) // filtered out by the prefix
+	store.Set("user:1", "alice", "", 0)
+	store.Delete("user:1")
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() (string, keyspaceEvent) {
+		t.Helper()
+		var name string
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				var ev keyspaceEvent
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil {
+					t.Fatalf("Malformed event data %q: %v", line, err)
+				}
+				return name, ev
+			}
+		}
+		t.Fatalf("Event stream ended: %v", lines.Err())
+		return "", keyspaceEvent{}
+	}
+
+	for _, want := range []string{"set", "del"} {
+		name, ev := next()
+		if name != want || ev.Event != want || ev.Key != "user:1" || ev.Timestamp == 0 {
+			t.Errorf("Expected a %s event for user:1, got %q %+v", want, name, ev)
+		}
+	}
+}
+
+func TestReplicationEventRecordsLag(t *testing.T) {
+	sm := storage.NewStoreManager(storage.StoreManagerConfig{
+		DataDir:           t.TempDir(),
+		MaxStores:         1,
+		GlobalPersistence: config.PersistenceConfig{Strategy: "disabled"},
+		GlobalCacheConfig: config.CacheConfig{MaxMemory: "1MB", DefaultTTL: "0"},
+	})
+	if err := sm.CreateStore(config.StoreConfig{Name: "default", EvictionPolicy: "lru", MaxMemory: "1MB", DefaultTTL: "0"}, context.Background()); err != nil {
+		t.Fatalf("CreateStore failed: %v", err)
+	}
+	clusterCfg := cluster.DefaultClusterConfig()
+	clusterCfg.NodeID = "node-a"
+	coord, err := cluster.NewSimpleCoordinator(clusterCfg)
+	if err != nil {
+		t.Fatalf("Failed to create coordinator: %v", err)
+	}
+
+	lag := metrics.Global().ReplicationLag()
+	lag.SetThreshold(100 * time.Millisecond)
+	defer lag.SetThreshold(0)
+
+	// The primary wrote the key 250ms before the event is applied here
+	event := cluster.ClusterEvent{
+		Type:      cluster.EventDataOperation,
+		NodeID:    "lag-test-primary",
+		Timestamp: time.Now().Add(-250 * time.Millisecond),
+		Data:      map[string]interface{}{"operation": "SET", "key": "k", "value": "v", "lamport_ts": float64(1)},
+	}
+	handleReplicationEvent(context.Background(), event, sm, "node-a", coord)
+
+	if value, err := sm.GetStore("default").Get("k"); err != nil || value != "v" {
+		t.Fatalf("Expected the replicated SET applied, got %v (%v)", value, err)
+	}
+	_, nodes := lag.Stats()
+	var stats *metrics.ReplicationLagStats
+	for i := range nodes {
+		if nodes[i].Node == "lag-test-primary" {
+			stats = &nodes[i]
+		}
+	}
+	if stats == nil || stats.Count != 1 {
+		t.Fatalf("Expected one write's lag recorded for the primary, got %+v", nodes)
+	}
+	if stats.Latest < 250*time.Millisecond || stats.Latest > 2*time.Second {
+		t.Errorf("Expected a lag of about 250ms, got %v", stats.Latest)
+	}
+	if !stats.Lagging {
+		t.Error("Expected a primary 250ms behind flagged with a 100ms threshold")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/network/resp"
+	"hypercache/internal/storage"
+	"hypercache/pkg/config"
+)
+
+// openStores creates the StoreManager with the stores defined in the config
+// plus those created at runtime (stores.json). The caller must Close it.
+func openStores(ctx context.Context, cfg *config.Config) (*storage.StoreManager, error) {
+	storeManager := storage.NewStoreManager(storage.StoreManagerConfig{
+		DataDir:           cfg.Node.DataDir,
+		MaxStores:         cfg.Cache.MaxStores,
+		GlobalPersistence: cfg.Persistence,
+		GlobalCacheConfig: cfg.Cache,
+		SlotIndex:         cfg.Cluster.SlotIndex,
+	})
+
+	// Create stores from config (YAML-defined)
+	for _, storeCfg := range cfg.Stores {
+		if err := storeManager.CreateStore(storeCfg, ctx); err != nil {
+			storeManager.Close()
+			return nil, fmt.Errorf("failed to create store %s: %w", storeCfg.Name, err)
+		}
+	}
+
+	// Load runtime-created stores from stores.json
+	if err := storeManager.LoadRegistry(ctx); err != nil {
+		logging.Warn(ctx, logging.ComponentStorage, logging.ActionRestore, "Failed to load store registry", map[string]interface{}{"error": err.Error()})
+	}
+
+	// Save registry so any config-defined stores are also tracked
+	storeManager.SaveRegistry()
+
+	if cfg.Cache.CompactInterval != "" {
+		if interval, err := time.ParseDuration(cfg.Cache.CompactInterval); err == nil && interval > 0 {
+			go storeManager.RunCompactor(ctx, interval, cfg.Cache.CompactMinRatio)
+		}
+	}
+
+	logging.Info(ctx, logging.ComponentMain, logging.ActionStart, "Stores initialized", map[string]interface{}{
+		"total_stores": storeManager.StoreCount(),
+		"stores":       storeManager.ListStores(),
+	})
+	return storeManager, nil
+}
+
+// startStandalone serves the stores over RESP on the configured address
+// without a coordinator: no gossip, routing or replication, every key is
+// local. Used for the default (internal) protocol mode.
+func startStandalone(ctx context.Context, cfg *config.Config, storeManager *storage.StoreManager, auditLog *logging.AuditLog) (*resp.Server, error) {
+	bindAddr := fmt.Sprintf("%s:%d", cfg.Network.RESPBindAddr, cfg.Network.RESPPort)
+	server := resp.NewServer(bindAddr, storeManager.GetDefaultStore(), nil)
+	server.SetStoreManager(storeManager)
+	if auditLog != nil {
+		server.SetAuditLog(auditLog)
+	}
+	if cfg.Network.MaxClients > 0 {
+		server.SetMaxConnections(cfg.Network.MaxClients)
+	}
+	server.SetErrorTokens(cfg.Network.RESPErrorTokens)
+	if err := server.SetNotifyKeyspaceEvents(cfg.Cache.NotifyKeyspaceEvents); err != nil {
+		logging.Warn(ctx, logging.ComponentRESP, logging.ActionStart, "Invalid notify_keyspace_events, keyspace notifications disabled", map[string]interface{}{"error": err.Error()})
+	}
+
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	logging.Info(ctx, logging.ComponentMain, logging.ActionStart, "HyperCache running in standalone mode", map[string]interface{}{
+		"bind_addr": server.Addr().String(),
+	})
+	return server, nil
+}
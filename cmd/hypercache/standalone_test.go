@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hypercache/pkg/config"
+)
+
+func TestStandaloneServesRequests(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.Load(filepath.Join(dir, "missing.yaml")) // defaults
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+	cfg.Node.DataDir = dir
+	cfg.Network.RESPBindAddr = "127.0.0.1"
+	cfg.Network.RESPPort = 0
+
+	ctx := context.Background()
+	storeManager, err := openStores(ctx, cfg)
+	if err != nil {
+		t.Fatalf("openStores: %v", err)
+	}
+	defer storeManager.Close()
+
+	server, err := startStandalone(ctx, cfg, storeManager, nil)
+	if err != nil {
+		t.Fatalf("startStandalone: %v", err)
+	}
+	defer server.Stop()
+
+	conn, err := net.DialTimeout("tcp", server.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n", []string{"+OK\r\n"}},
+		{"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", []string{"$5\r\n", "value\r\n"}},
+		{"*2\r\n$3\r\nDEL\r\n$3\r\nkey\r\n", []string{":1\r\n"}},
+	}
+	for _, tt := range tests {
+		if _, err := conn.Write([]byte(tt.command)); err != nil {
+			t.Fatalf("Write %q: %v", tt.command, err)
+		}
+		for _, want := range tt.expected {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Read reply to %q: %v", tt.command, err)
+			}
+			if line != want {
+				t.Errorf("Reply to %q: expected %q, got %q", tt.command, want, line)
+			}
+		}
+	}
+
+	if got, err := storeManager.GetDefaultStore().Get("key"); err == nil {
+		t.Errorf("Expected key to be deleted, got %v", got)
+	}
+}
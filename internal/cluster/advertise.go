@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+
+	"hypercache/internal/logging"
+)
+
+// ResolveAdvertiseAddress returns the address this node advertises to peers.
+// An explicit address always wins. Otherwise the first usable IPv4 address of
+// iface is used, or, if iface is empty, of the first interface that is up.
+// Loopback and link-local addresses are never auto-detected.
+func ResolveAdvertiseAddress(explicit, iface string) (string, error) {
+	if explicit != "" {
+		return explicit, nil
+	}
+
+	var interfaces []net.Interface
+	if iface != "" {
+		ifi, err := net.InterfaceByName(iface)
+		if err != nil {
+			return "", fmt.Errorf("advertise interface %s: %w", iface, err)
+		}
+		interfaces = []net.Interface{*ifi}
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return "", fmt.Errorf("failed to list network interfaces: %w", err)
+		}
+		interfaces = all
+	}
+
+	for _, ifi := range interfaces {
+		if ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		if ip := selectAdvertiseIP(addrs); ip != nil {
+			logging.Info(nil, logging.ComponentCluster, logging.ActionStart, "Auto-detected advertise address", map[string]interface{}{
+				"address":   ip.String(),
+				"interface": ifi.Name,
+			})
+			return ip.String(), nil
+		}
+	}
+
+	if iface != "" {
+		return "", fmt.Errorf("no usable IPv4 address on advertise interface %s", iface)
+	}
+	return "", fmt.Errorf("no usable IPv4 address found to advertise")
+}
+
+// selectAdvertiseIP returns the first IPv4 address in addrs that peers can
+// route to, or nil if there is none
+func selectAdvertiseIP(addrs []net.Addr) net.IP {
+	for _, addr := range addrs {
+		var ip net.IP
+		switch a := addr.(type) {
+		case *net.IPNet:
+			ip = a.IP
+		case *net.IPAddr:
+			ip = a.IP
+		}
+		ip = ip.To4()
+		if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+			continue
+		}
+		return ip
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSelectAdvertiseIP(t *testing.T) {
+	mustCIDR := func(s string) net.Addr {
+		ip, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatalf("ParseCIDR(%s): %v", s, err)
+		}
+		ipnet.IP = ip
+		return ipnet
+	}
+
+	addrs := []net.Addr{
+		mustCIDR("127.0.0.1/8"),
+		mustCIDR("169.254.10.1/16"),
+		mustCIDR("fd00::1/64"),
+		mustCIDR("10.1.2.3/8"),
+		mustCIDR("192.168.1.5/24"),
+	}
+	if ip := selectAdvertiseIP(addrs); ip == nil || ip.String() != "10.1.2.3" {
+		t.Errorf("Expected 10.1.2.3, got %v", ip)
+	}
+
+	if ip := selectAdvertiseIP(addrs[:3]); ip != nil {
+		t.Errorf("Expected no address from loopback, link-local and IPv6 only, got %v", ip)
+	}
+}
+
+func TestResolveAdvertiseAddress(t *testing.T) {
+	// An explicit override wins, even over a bad interface name
+	addr, err := ResolveAdvertiseAddress("203.0.113.7", "no-such-iface0")
+	if err != nil || addr != "203.0.113.7" {
+		t.Errorf("Expected explicit address to win, got %q, %v", addr, err)
+	}
+
+	if _, err := ResolveAdvertiseAddress("", "no-such-iface0"); err == nil {
+		t.Error("Expected an error for an unknown interface")
+	}
+
+	addr, err = ResolveAdvertiseAddress("", "")
+	if err != nil {
+		t.Skipf("No routable IPv4 interface in this environment: %v", err)
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.To4() == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		t.Errorf("Expected a routable IPv4 address, got %q", addr)
+	}
+}
@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Capabilities a node can advertise in its gossip "capabilities" tag
+const (
+	CapabilityFilters     = "filters"
+	CapabilityPersistence = "persistence"
+	CapabilityRESP        = "resp"
+	CapabilityCompression = "compression" // Accepts gzip-encoded replication payloads
+	CapabilityTLS         = "tls"
+)
+
+// localCapabilities is what this build advertises to its peers
+var localCapabilities = []string{CapabilityFilters, CapabilityPersistence, CapabilityRESP, CapabilityCompression}
+
+// ParseCapabilities splits a comma-separated capabilities tag
+func ParseCapabilities(tag string) []string {
+	var capabilities []string
+	for _, c := range strings.Split(tag, ",") {
+		if c = strings.TrimSpace(strings.ToLower(c)); c != "" {
+			capabilities = append(capabilities, c)
+		}
+	}
+	return capabilities
+}
+
+// memberHasCapability reports whether a member advertises capability
+func memberHasCapability(member *ClusterMember, capability string) bool {
+	if member == nil {
+		return false
+	}
+	for _, c := range ParseCapabilities(member.Metadata["capabilities"]) {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// HasCapability reports whether the node advertised capability
+func (n *Node) HasCapability(capability string) bool {
+	for _, c := range n.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// SetNodeCapabilities records the capabilities a node advertised on join
+func (ring *HashRing) SetNodeCapabilities(nodeID string, capabilities []string) error {
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	node, exists := ring.nodes[nodeID]
+	if !exists {
+		return fmt.Errorf("node %s does not exist", nodeID)
+	}
+
+	node.Capabilities = append([]string(nil), capabilities...)
+	node.SupportsFilters = node.HasCapability(CapabilityFilters)
+	node.SupportsCompression = node.HasCapability(CapabilityCompression)
+	node.SupportsTLS = node.HasCapability(CapabilityTLS)
+	return nil
+}
+
+// NodesWithCapability returns the IDs of nodes that advertised capability, sorted
+func (ring *HashRing) NodesWithCapability(capability string) []string {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	var nodes []string
+	for id, node := range ring.nodes {
+		if node.HasCapability(capability) {
+			nodes = append(nodes, id)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
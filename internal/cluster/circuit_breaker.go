@@ -0,0 +1,357 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
+)
+
+// ErrCircuitOpen is returned when replication is skipped because the breaker is open
+var ErrCircuitOpen = errors.New("replication circuit breaker is open")
+
+var (
+	// errPublishInFlight is returned for a publish that timed out but is
+	// still running; its outcome is handled when it completes
+	errPublishInFlight = errors.New("replication publish timed out")
+	// errPublishStalled is returned without publishing while an earlier
+	// publish that timed out is still running
+	errPublishStalled = errors.New("replication backend stalled on an earlier publish")
+)
+
+// BreakerState represents the state of a circuit breaker
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // Requests flow normally
+	BreakerOpen                         // Requests are rejected until the open timeout elapses
+	BreakerHalfOpen                     // A single probe request is allowed through
+)
+
+// String returns the string representation of a breaker state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig holds circuit breaker tuning
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold" json:"failure_threshold"` // Consecutive failures before tripping
+	OpenTimeout      time.Duration `yaml:"open_timeout" json:"open_timeout"`           // Time to stay open before probing
+	PublishTimeout   time.Duration `yaml:"publish_timeout" json:"publish_timeout"`     // Max time a publish may block the caller
+	MaxHints         int           `yaml:"max_hints" json:"max_hints"`                 // Events buffered while open (oldest dropped first)
+}
+
+// DefaultCircuitBreakerConfig returns sensible defaults for the replication breaker
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      5 * time.Second,
+		PublishTimeout:   100 * time.Millisecond,
+		MaxHints:         10000,
+	}
+}
+
+// CircuitBreaker is a consecutive-failure circuit breaker with a single half-open probe
+type CircuitBreaker struct {
+	config   CircuitBreakerConfig
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	mu       sync.Mutex
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultCircuitBreakerConfig().FailureThreshold
+	}
+	return &CircuitBreaker{config: config}
+}
+
+// Allow reports whether a request may proceed. Once the open timeout has
+// elapsed a single probe is let through in the half-open state.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.config.OpenTimeout {
+			return false
+		}
+		cb.state = BreakerHalfOpen
+		cb.probing = true
+		return true
+	case BreakerHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess records a successful request and reports whether it closed the breaker
+func (cb *CircuitBreaker) RecordSuccess() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	recovered := cb.state != BreakerClosed
+	cb.state = BreakerClosed
+	cb.failures = 0
+	cb.probing = false
+	return recovered
+}
+
+// RecordFailure records a failed request and reports whether it tripped the breaker
+func (cb *CircuitBreaker) RecordFailure() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerHalfOpen:
+		// Failed probe: back to open for another timeout period
+		cb.state = BreakerOpen
+		cb.openedAt = time.Now()
+		cb.probing = false
+		return true
+	case BreakerClosed:
+		cb.failures++
+		if cb.failures >= cb.config.FailureThreshold {
+			cb.state = BreakerOpen
+			cb.openedAt = time.Now()
+			return true
+		}
+	}
+	return false
+}
+
+// State returns the current breaker state
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// BreakerEventBus wraps an EventBus with a circuit breaker so a slow or failing
+// backend cannot stall the write path. While the breaker is open, events are
+// buffered as hints and replayed once a half-open probe succeeds.
+type BreakerEventBus struct {
+	inner   EventBus
+	breaker *CircuitBreaker
+	config  CircuitBreakerConfig
+
+	hints     []ClusterEvent
+	hintsMu   sync.Mutex
+	replaying atomic.Bool
+	inFlight  atomic.Int64 // publishes that timed out and are still running
+}
+
+// NewBreakerEventBus wraps inner with a circuit breaker
+func NewBreakerEventBus(inner EventBus, config CircuitBreakerConfig) *BreakerEventBus {
+	b := &BreakerEventBus{
+		inner:   inner,
+		breaker: NewCircuitBreaker(config),
+		config:  config,
+	}
+	b.updateMetrics()
+	return b
+}
+
+// Publish implements EventBus.Publish. When the breaker is open the event is
+// buffered for later replay and ErrCircuitOpen is returned immediately.
+func (b *BreakerEventBus) Publish(ctx context.Context, event ClusterEvent) error {
+	if !b.breaker.Allow() {
+		b.addHint(event)
+		metrics.Global().IncCounter("hypercache_replication_breaker_skipped_total")
+		b.updateMetrics()
+		return ErrCircuitOpen
+	}
+
+	err := b.publishWithTimeout(ctx, event)
+	if err != nil {
+		b.recordFailure(ctx, err)
+		// A publish still in flight is hinted only if it eventually fails
+		if !errors.Is(err, errPublishInFlight) {
+			b.addHint(event)
+		}
+		b.updateMetrics()
+		return err
+	}
+
+	b.recordSuccess(ctx)
+	if b.PendingHints() > 0 && b.replaying.CompareAndSwap(false, true) {
+		go b.replayHints()
+	}
+	b.updateMetrics()
+	return nil
+}
+
+// recordFailure records a failed publish, logging if it tripped the breaker
+func (b *BreakerEventBus) recordFailure(ctx context.Context, err error) {
+	if b.breaker.RecordFailure() {
+		metrics.Global().IncCounter("hypercache_replication_breaker_trips_total")
+		logging.Warn(ctx, logging.ComponentEventBus, logging.ActionReplication, "Replication circuit breaker opened", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// recordSuccess records a successful publish, logging if it closed the breaker
+func (b *BreakerEventBus) recordSuccess(ctx context.Context) {
+	if b.breaker.RecordSuccess() {
+		logging.Info(ctx, logging.ComponentEventBus, logging.ActionReplication, "Replication circuit breaker closed", map[string]interface{}{
+			"pending_hints": b.PendingHints(),
+		})
+	}
+}
+
+// Subscribe implements EventBus.Subscribe
+func (b *BreakerEventBus) Subscribe(eventTypes ...ClusterEventType) <-chan ClusterEvent {
+	return b.inner.Subscribe(eventTypes...)
+}
+
+// Unsubscribe implements EventBus.Unsubscribe
+func (b *BreakerEventBus) Unsubscribe(ch <-chan ClusterEvent) {
+	b.inner.Unsubscribe(ch)
+}
+
+// GetMetrics implements EventBus.GetMetrics
+func (b *BreakerEventBus) GetMetrics() EventBusMetrics {
+	return b.inner.GetMetrics()
+}
+
+// State returns the breaker state
+func (b *BreakerEventBus) State() BreakerState {
+	return b.breaker.State()
+}
+
+// PendingHints returns the number of buffered events awaiting replay
+func (b *BreakerEventBus) PendingHints() int {
+	b.hintsMu.Lock()
+	defer b.hintsMu.Unlock()
+	return len(b.hints)
+}
+
+// publishWithTimeout bounds how long the caller waits on the inner bus. The
+// inner bus may not honour ctx, so a publish that times out is left to
+// finish in the background and hinted only if it then fails: the event is
+// never both delivered and replayed. While such a publish is still running
+// the backend is treated as stalled and further publishes fail at once,
+// rather than piling up goroutines behind it.
+func (b *BreakerEventBus) publishWithTimeout(ctx context.Context, event ClusterEvent) error {
+	if b.config.PublishTimeout <= 0 {
+		return b.inner.Publish(ctx, event)
+	}
+	if b.inFlight.Load() > 0 {
+		return errPublishStalled
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, b.config.PublishTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- b.inner.Publish(ctx, event) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		b.inFlight.Add(1)
+		go b.settle(event, done)
+		return fmt.Errorf("%w: %w", errPublishInFlight, ctx.Err())
+	}
+}
+
+// settle waits for a publish that timed out and hints its event if it failed
+func (b *BreakerEventBus) settle(event ClusterEvent, done <-chan error) {
+	defer b.inFlight.Add(-1)
+	if err := <-done; err != nil {
+		b.addHint(event)
+		b.updateMetrics()
+	}
+}
+
+// addHint buffers an event for replay, dropping the oldest when full
+func (b *BreakerEventBus) addHint(event ClusterEvent) {
+	if b.config.MaxHints <= 0 {
+		return
+	}
+	b.hintsMu.Lock()
+	defer b.hintsMu.Unlock()
+	if len(b.hints) >= b.config.MaxHints {
+		b.hints = b.hints[1:]
+	}
+	b.hints = append(b.hints, event)
+}
+
+// requeueHints puts events that weren't replayed back in front of the
+// hints buffered since, in order, dropping the oldest when full
+func (b *BreakerEventBus) requeueHints(events []ClusterEvent) {
+	if b.config.MaxHints <= 0 || len(events) == 0 {
+		return
+	}
+	b.hintsMu.Lock()
+	defer b.hintsMu.Unlock()
+	hints := make([]ClusterEvent, 0, len(events)+len(b.hints))
+	hints = append(append(hints, events...), b.hints...)
+	if over := len(hints) - b.config.MaxHints; over > 0 {
+		hints = hints[over:]
+	}
+	b.hints = hints
+}
+
+// replayHints republishes buffered events, oldest first, once publishing
+// succeeds again. It stops at the first failure, putting that event and
+// the rest back at the front of the buffer for the next replay.
+func (b *BreakerEventBus) replayHints() {
+	defer b.replaying.Store(false)
+
+	b.hintsMu.Lock()
+	hints := b.hints
+	b.hints = nil
+	b.hintsMu.Unlock()
+
+	ctx := context.Background()
+	for i, event := range hints {
+		if !b.breaker.Allow() {
+			b.requeueHints(hints[i:])
+			b.updateMetrics()
+			return
+		}
+		if err := b.publishWithTimeout(ctx, event); err != nil {
+			b.recordFailure(ctx, err)
+			if errors.Is(err, errPublishInFlight) {
+				i++ // settle hints it if it fails
+			}
+			b.requeueHints(hints[i:])
+			b.updateMetrics()
+			return
+		}
+		b.recordSuccess(ctx)
+	}
+	b.updateMetrics()
+}
+
+// updateMetrics exports breaker state (0=closed, 1=open, 2=half-open) and pending hints
+func (b *BreakerEventBus) updateMetrics() {
+	metrics.Global().SetGauge("hypercache_replication_breaker_state", int64(b.breaker.State()))
+	metrics.Global().SetGauge("hypercache_replication_hints_pending", int64(b.PendingHints()))
+}
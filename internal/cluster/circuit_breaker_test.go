@@ -0,0 +1,306 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyEventBus is an EventBus whose Publish fails while broken is set
+type flakyEventBus struct {
+	mu        sync.Mutex
+	broken    bool
+	published []ClusterEvent
+}
+
+func (f *flakyEventBus) setBroken(broken bool) {
+	f.mu.Lock()
+	f.broken = broken
+	f.mu.Unlock()
+}
+
+func (f *flakyEventBus) publishedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.published)
+}
+
+func (f *flakyEventBus) Publish(ctx context.Context, event ClusterEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.broken {
+		return errors.New("peer unavailable")
+	}
+	f.published = append(f.published, event)
+	return nil
+}
+
+func (f *flakyEventBus) Subscribe(eventTypes ...ClusterEventType) <-chan ClusterEvent {
+	return make(chan ClusterEvent)
+}
+
+func (f *flakyEventBus) Unsubscribe(ch <-chan ClusterEvent) {}
+
+func (f *flakyEventBus) GetMetrics() EventBusMetrics { return EventBusMetrics{} }
+
+func TestBreakerEventBus_OpensAndRecovers(t *testing.T) {
+	inner := &flakyEventBus{}
+	bus := NewBreakerEventBus(inner, CircuitBreakerConfig{
+		FailureThreshold: 3,
+		OpenTimeout:      50 * time.Millisecond,
+		PublishTimeout:   time.Second,
+		MaxHints:         100,
+	})
+	ctx := context.Background()
+	event := ClusterEvent{Type: EventDataOperation, NodeID: "node-1"}
+
+	// Failures below the threshold keep the breaker closed
+	inner.setBroken(true)
+	for i := 0; i < 2; i++ {
+		if err := bus.Publish(ctx, event); err == nil {
+			t.Fatal("Expected publish error from broken backend")
+		}
+	}
+	if bus.State() != BreakerClosed {
+		t.Fatalf("Expected closed breaker, got %s", bus.State())
+	}
+
+	// Hitting the threshold trips it; further publishes are skipped immediately
+	bus.Publish(ctx, event)
+	if bus.State() != BreakerOpen {
+		t.Fatalf("Expected open breaker, got %s", bus.State())
+	}
+	if err := bus.Publish(ctx, event); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if got := bus.PendingHints(); got != 4 {
+		t.Errorf("Expected 4 buffered hints, got %d", got)
+	}
+
+	// A failed half-open probe re-opens the breaker
+	time.Sleep(60 * time.Millisecond)
+	bus.Publish(ctx, event)
+	if bus.State() != BreakerOpen {
+		t.Fatalf("Expected breaker to re-open after failed probe, got %s", bus.State())
+	}
+
+	// Once the backend heals, the probe closes the breaker and hints are replayed
+	inner.setBroken(false)
+	time.Sleep(60 * time.Millisecond)
+	if err := bus.Publish(ctx, event); err != nil {
+		t.Fatalf("Expected probe to succeed, got %v", err)
+	}
+	if bus.State() != BreakerClosed {
+		t.Fatalf("Expected closed breaker after recovery, got %s", bus.State())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for bus.PendingHints() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := bus.PendingHints(); got != 0 {
+		t.Errorf("Expected hints to drain after recovery, %d remaining", got)
+	}
+	// 5 buffered events plus the probe itself
+	if got := inner.publishedCount(); got != 6 {
+		t.Errorf("Expected 6 events delivered after recovery, got %d", got)
+	}
+}
+
+func TestBreakerEventBus_PublishTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	bus := NewBreakerEventBus(&blockingEventBus{block: block}, CircuitBreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+		PublishTimeout:   20 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if err := bus.Publish(context.Background(), ClusterEvent{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Publish blocked for %v despite timeout", elapsed)
+	}
+	if bus.State() != BreakerOpen {
+		t.Errorf("Expected timeout to trip breaker, got %s", bus.State())
+	}
+}
+
+// blockingEventBus is an EventBus whose Publish blocks until block is closed
+type blockingEventBus struct {
+	flakyEventBus
+	block chan struct{}
+}
+
+func (b *blockingEventBus) Publish(ctx context.Context, event ClusterEvent) error {
+	<-b.block
+	return nil
+}
+
+// selectiveEventBus is an EventBus recording the NodeID of each delivered
+// event and failing those whose NodeID is in failing
+type selectiveEventBus struct {
+	flakyEventBus
+	failing   map[string]bool
+	onFailure func(event ClusterEvent) // called before failing an event
+	release   chan struct{}            // if set, the first publish waits for it
+	once      sync.Once
+}
+
+func (s *selectiveEventBus) setFailing(nodeIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = make(map[string]bool)
+	for _, id := range nodeIDs {
+		s.failing[id] = true
+	}
+}
+
+func (s *selectiveEventBus) delivered() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, len(s.published))
+	for i, event := range s.published {
+		ids[i] = event.NodeID
+	}
+	return ids
+}
+
+func (s *selectiveEventBus) Publish(ctx context.Context, event ClusterEvent) error {
+	if s.release != nil {
+		s.once.Do(func() { <-s.release })
+	}
+	s.mu.Lock()
+	failing, onFailure := s.failing[event.NodeID], s.onFailure
+	if !failing {
+		s.published = append(s.published, event)
+	}
+	s.mu.Unlock()
+
+	if !failing {
+		return nil
+	}
+	if onFailure != nil {
+		onFailure(event)
+	}
+	return errors.New("peer unavailable")
+}
+
+func waitForHints(t *testing.T, bus *BreakerEventBus, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for bus.PendingHints() != want || bus.replaying.Load() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d pending hints, have %d", want, bus.PendingHints())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBreakerEventBus_ReplayKeepsOrderAfterFailure(t *testing.T) {
+	inner := &selectiveEventBus{}
+	bus := NewBreakerEventBus(inner, CircuitBreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      20 * time.Millisecond,
+		PublishTimeout:   time.Second,
+		MaxHints:         100,
+	})
+	ctx := context.Background()
+
+	// e0 trips the breaker, e1-e4 are hinted while it is open
+	inner.setFailing("e0")
+	for i := 0; i < 5; i++ {
+		bus.Publish(ctx, ClusterEvent{NodeID: fmt.Sprintf("e%d", i)})
+	}
+	waitForHints(t, bus, 5)
+
+	// The replay after the probe fails at e2. An event that fails while it
+	// does is hinted behind e2-e4, not ahead of them.
+	inner.setFailing("e2", "late")
+	inner.onFailure = func(event ClusterEvent) {
+		if event.NodeID == "e2" {
+			bus.Publish(ctx, ClusterEvent{NodeID: "late"})
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := bus.Publish(ctx, ClusterEvent{NodeID: "probe1"}); err != nil {
+		t.Fatalf("Expected probe to succeed, got %v", err)
+	}
+	waitForHints(t, bus, 4)
+
+	// Once healed, the rest is replayed in its original order
+	inner.setFailing()
+	time.Sleep(30 * time.Millisecond)
+	if err := bus.Publish(ctx, ClusterEvent{NodeID: "probe2"}); err != nil {
+		t.Fatalf("Expected probe to succeed, got %v", err)
+	}
+	waitForHints(t, bus, 0)
+
+	var replayed []string
+	for _, id := range inner.delivered() {
+		if !strings.HasPrefix(id, "probe") {
+			replayed = append(replayed, id)
+		}
+	}
+	if want := []string{"e0", "e1", "e2", "e3", "e4", "late"}; !reflect.DeepEqual(replayed, want) {
+		t.Errorf("Replayed %v, want %v", replayed, want)
+	}
+}
+
+func TestBreakerEventBus_TimedOutPublishIsNotReplayed(t *testing.T) {
+	inner := &selectiveEventBus{release: make(chan struct{})}
+	bus := NewBreakerEventBus(inner, CircuitBreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      time.Minute,
+		PublishTimeout:   20 * time.Millisecond,
+		MaxHints:         100,
+	})
+	ctx := context.Background()
+
+	// The slow publish times out but is still running: not hinted
+	if err := bus.Publish(ctx, ClusterEvent{NodeID: "slow"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if got := bus.PendingHints(); got != 0 {
+		t.Errorf("Expected the in-flight event not to be hinted, got %d hints", got)
+	}
+
+	// Publishes behind it fail fast and are hinted instead of piling up
+	start := time.Now()
+	if err := bus.Publish(ctx, ClusterEvent{NodeID: "queued"}); err == nil {
+		t.Fatal("Expected publish to fail while the backend is stalled")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("Publish behind a stalled one took %v", elapsed)
+	}
+	if got := bus.PendingHints(); got != 1 {
+		t.Errorf("Expected 1 hint, got %d", got)
+	}
+
+	// The slow publish completes; the next success replays only the hint
+	close(inner.release)
+	deadline := time.Now().Add(time.Second)
+	for bus.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := bus.Publish(ctx, ClusterEvent{NodeID: "next"}); err != nil {
+		t.Fatalf("Expected publish to succeed, got %v", err)
+	}
+	waitForHints(t, bus, 0)
+
+	counts := make(map[string]int)
+	for _, id := range inner.delivered() {
+		counts[id]++
+	}
+	if want := map[string]int{"slow": 1, "queued": 1, "next": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("Delivered %v, want each event exactly once", counts)
+	}
+}
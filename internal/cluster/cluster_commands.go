@@ -0,0 +1,222 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
+)
+
+// Cluster-wide control commands. Components register a CommandHandler for
+// each command they implement; other names can be registered freely.
+const (
+	CommandFlushAll  = "flushall"  // Clear every store on every node
+	CommandRebalance = "rebalance" // Trigger a rebalance on every node
+)
+
+// commandEventPrefix marks Serf user events, and the Serf queries of
+// BroadcastCommandSync, that carry a ClusterCommand
+const commandEventPrefix = "cluster-command:"
+
+// DefaultCommandAckTimeout bounds BroadcastCommandSync when ctx has no deadline
+const DefaultCommandAckTimeout = 5 * time.Second
+
+// ClusterCommand is a control command broadcast to every node. Commands travel
+// as Serf user events, separately from the replicated data path.
+type ClusterCommand struct {
+	Name     string            `json:"name"`
+	NodeID   string            `json:"node_id"` // Node that issued the command
+	Args     map[string]string `json:"args,omitempty"`
+	IssuedAt time.Time         `json:"issued_at"`
+}
+
+// commandAck is a node's answer to a command sent by BroadcastCommandSync
+type commandAck struct {
+	NodeID string `json:"node_id"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CommandAcks reports which members confirmed a command sent by
+// BroadcastCommandSync
+type CommandAcks struct {
+	Confirmed []string          // Nodes that ran the command, sorted
+	Failed    map[string]string // Reason by node: the handler's error, or no acknowledgment
+}
+
+// OK reports whether every member confirmed the command
+func (a *CommandAcks) OK() bool {
+	return len(a.Failed) == 0
+}
+
+// CommandHandler executes a cluster command on the local node
+type CommandHandler func(ctx context.Context, cmd ClusterCommand) error
+
+// CommandDispatcher routes incoming cluster commands to local handlers
+type CommandDispatcher struct {
+	mu       sync.RWMutex
+	handlers map[string]CommandHandler
+}
+
+// NewCommandDispatcher creates a dispatcher with no handlers
+func NewCommandDispatcher() *CommandDispatcher {
+	return &CommandDispatcher{handlers: make(map[string]CommandHandler)}
+}
+
+// Handle registers the handler for a command, replacing any previous one
+func (d *CommandDispatcher) Handle(name string, handler CommandHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[strings.ToLower(name)] = handler
+}
+
+// handles reports whether a handler is registered for a command
+func (d *CommandDispatcher) handles(name string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, ok := d.handlers[strings.ToLower(name)]
+	return ok
+}
+
+// Dispatch runs the handler registered for cmd. Commands without a handler
+// are ignored so nodes running older versions tolerate new commands.
+func (d *CommandDispatcher) Dispatch(ctx context.Context, cmd ClusterCommand) error {
+	d.mu.RLock()
+	handler, ok := d.handlers[strings.ToLower(cmd.Name)]
+	d.mu.RUnlock()
+
+	if !ok {
+		logging.Debug(ctx, logging.ComponentCoordinator, "cluster_command", "No handler for cluster command", map[string]interface{}{
+			"command":     cmd.Name,
+			"source_node": cmd.NodeID,
+		})
+		return nil
+	}
+
+	metrics.Global().IncCounter("hypercache_cluster_commands_total")
+	if err := handler(ctx, cmd); err != nil {
+		metrics.Global().IncCounter("hypercache_cluster_command_errors_total")
+		logging.Error(ctx, logging.ComponentCoordinator, "cluster_command", "Cluster command failed", err, map[string]interface{}{
+			"command":     cmd.Name,
+			"source_node": cmd.NodeID,
+		})
+		return err
+	}
+
+	logging.Info(ctx, logging.ComponentCoordinator, "cluster_command", "Cluster command executed", map[string]interface{}{
+		"command":     cmd.Name,
+		"source_node": cmd.NodeID,
+	})
+	return nil
+}
+
+// BroadcastCommand sends a command to every node in the cluster, including
+// this one: Serf delivers user events back to the node that sent them.
+func (deb *DistributedEventBus) BroadcastCommand(ctx context.Context, name string, args map[string]string) error {
+	payload, err := json.Marshal(ClusterCommand{
+		Name:     name,
+		NodeID:   deb.nodeID,
+		Args:     args,
+		IssuedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize cluster command: %w", err)
+	}
+
+	if err := deb.membership.SendUserEvent(commandEventPrefix+name, payload); err != nil {
+		return fmt.Errorf("failed to broadcast cluster command %s: %w", name, err)
+	}
+	return nil
+}
+
+// processIncomingCommand decodes a cluster command user event and dispatches it
+func (deb *DistributedEventBus) processIncomingCommand(payload []byte) {
+	var cmd ClusterCommand
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		logging.Error(nil, logging.ComponentEventBus, "deserialize", "Failed to deserialize cluster command", err, nil)
+		return
+	}
+	_ = deb.commands.Dispatch(context.Background(), cmd)
+}
+
+// BroadcastCommandSync sends a command to every node as a Serf query and
+// waits for each alive member to acknowledge it, until ctx's deadline (or
+// DefaultCommandAckTimeout). A node acknowledges once its handler has run;
+// a node without a handler for the command reports that as a failure.
+// Members that don't answer in time are reported failed too.
+func (deb *DistributedEventBus) BroadcastCommandSync(ctx context.Context, name string, args map[string]string) (*CommandAcks, error) {
+	payload, err := json.Marshal(ClusterCommand{
+		Name:     name,
+		NodeID:   deb.nodeID,
+		Args:     args,
+		IssuedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize cluster command: %w", err)
+	}
+
+	timeout := DefaultCommandAckTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	members := deb.membership.GetAliveNodes()
+	responses, err := deb.membership.Query(commandEventPrefix+name, payload, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to broadcast cluster command %s: %w", name, err)
+	}
+
+	acks := &CommandAcks{Failed: make(map[string]string)}
+	answered := make(map[string]bool, len(responses))
+	for _, response := range responses {
+		var ack commandAck
+		if err := json.Unmarshal(response, &ack); err != nil {
+			logging.Warn(ctx, logging.ComponentEventBus, "deserialize", "Ignoring malformed command acknowledgment", map[string]interface{}{"error": err.Error()})
+			continue
+		}
+		answered[ack.NodeID] = true
+		if ack.Error != "" {
+			acks.Failed[ack.NodeID] = ack.Error
+		} else {
+			acks.Confirmed = append(acks.Confirmed, ack.NodeID)
+		}
+	}
+	for _, member := range members {
+		if !answered[member.NodeID] {
+			acks.Failed[member.NodeID] = "no acknowledgment"
+		}
+	}
+	sort.Strings(acks.Confirmed)
+	return acks, nil
+}
+
+// processIncomingQuery runs a command sent by BroadcastCommandSync and
+// returns its acknowledgment. Other queries are left unanswered.
+func (deb *DistributedEventBus) processIncomingQuery(queryName string, payload []byte) []byte {
+	if !strings.HasPrefix(queryName, commandEventPrefix) {
+		return nil
+	}
+
+	var cmd ClusterCommand
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		logging.Error(nil, logging.ComponentEventBus, "deserialize", "Failed to deserialize cluster command", err, nil)
+		return nil
+	}
+
+	ack := commandAck{NodeID: deb.nodeID}
+	if !deb.commands.handles(cmd.Name) {
+		ack.Error = "no handler for " + cmd.Name
+	} else if err := deb.commands.Dispatch(context.Background(), cmd); err != nil {
+		ack.Error = err.Error()
+	}
+	response, err := json.Marshal(ack)
+	if err != nil {
+		logging.Error(nil, logging.ComponentEventBus, "serialize", "Failed to encode command acknowledgment", err, nil)
+		return nil
+	}
+	return response
+}
@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Connection pool defaults
+const (
+	DefaultPoolMaxIdlePerNode = 16               // idle connections kept per peer
+	DefaultPoolIdleTimeout    = 90 * time.Second // idle connections are closed after this
+	DefaultPoolDialTimeout    = 5 * time.Second  // timeout for opening a connection
+)
+
+// ConnPool keeps connections to peer nodes open between requests, one
+// pool per node address, so proxying, replication and migration don't dial
+// a new TCP connection per request. It is an http.RoundTripper for the
+// NodeCommunicator's HTTP client.
+//
+// A connection is dropped when it has been idle for IdleTimeout or is
+// one too many above MaxIdlePerNode. A request that fails at the network
+// level evicts the idle connections to that address, since they most
+// likely point at the same dead peer, and so does Evict when membership
+// reports the node gone.
+type ConnPool struct {
+	maxIdlePerNode int
+	idleTimeout    time.Duration
+	dialer         net.Dialer
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport // by host:port
+
+	dials     atomic.Int64
+	evictions atomic.Int64
+}
+
+// NewConnPool creates a connection pool. maxIdlePerNode or idleTimeout of 0
+// apply the defaults.
+func NewConnPool(maxIdlePerNode int, idleTimeout time.Duration) *ConnPool {
+	if maxIdlePerNode <= 0 {
+		maxIdlePerNode = DefaultPoolMaxIdlePerNode
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultPoolIdleTimeout
+	}
+	return &ConnPool{
+		maxIdlePerNode: maxIdlePerNode,
+		idleTimeout:    idleTimeout,
+		dialer:         net.Dialer{Timeout: DefaultPoolDialTimeout, KeepAlive: 30 * time.Second},
+		transports:     make(map[string]*http.Transport),
+	}
+}
+
+// RoundTrip implements http.RoundTripper over the pooled connections to
+// the request's host
+func (p *ConnPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := p.transport(req.URL.Host).RoundTrip(req)
+	if err != nil && req.Context().Err() == nil {
+		// The peer is unreachable or dropped the connection; its other
+		// idle connections are unlikely to be any better
+		p.Evict(req.URL.Host)
+	}
+	return resp, err
+}
+
+// transport returns the pool of connections to address, creating it on first use
+func (p *ConnPool) transport(address string) *http.Transport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t, ok := p.transports[address]; ok {
+		return t
+	}
+	t := &http.Transport{
+		DialContext:         p.dialContext,
+		MaxIdleConns:        p.maxIdlePerNode,
+		MaxIdleConnsPerHost: p.maxIdlePerNode,
+		IdleConnTimeout:     p.idleTimeout,
+	}
+	p.transports[address] = t
+	return t
+}
+
+// dialContext opens a new connection, counting it
+func (p *ConnPool) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	p.dials.Add(1)
+	return p.dialer.DialContext(ctx, network, address)
+}
+
+// Evict closes the idle connections to address (host:port) and forgets
+// it. Connections in use finish their request and are then left to time
+// out; the next request to address dials afresh.
+func (p *ConnPool) Evict(address string) {
+	p.mu.Lock()
+	t, ok := p.transports[address]
+	delete(p.transports, address)
+	p.mu.Unlock()
+
+	if ok {
+		t.CloseIdleConnections()
+		p.evictions.Add(1)
+	}
+}
+
+// Dials returns the number of connections opened so far
+func (p *ConnPool) Dials() int64 {
+	return p.dials.Load()
+}
+
+// Evictions returns the number of times a node's connections were evicted
+func (p *ConnPool) Evictions() int64 {
+	return p.evictions.Load()
+}
+
+// Close closes every idle connection in the pool
+func (p *ConnPool) Close() {
+	p.mu.Lock()
+	transports := p.transports
+	p.transports = make(map[string]*http.Transport)
+	p.mu.Unlock()
+
+	for _, t := range transports {
+		t.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,534 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"hypercache/internal/logging"
+)
+
+// DecommissionProgress is the payload of decommission events
+type DecommissionProgress struct {
+	NodeID     string `json:"node_id"`               // Node being decommissioned
+	TargetNode string `json:"target_node,omitempty"` // New owner of the batch just migrated
+	KeysMoved  int    `json:"keys_moved"`            // Keys migrated so far
+	TotalKeys  int    `json:"total_keys"`            // Keys to migrate in total
+}
+
+// DecommissionNode gracefully removes nodeID from the ring. The node is marked
+// NodeLeaving so routing stops selecting it, each of keys it owns is migrated
+// to its new owner with migrator, and the node is then removed from the ring.
+// Keys the node only holds as a replica are left alone. Progress is published
+// on bus (which may be nil). If a migration fails the node is restored to
+// NodeAlive and stays on the ring.
+func DecommissionNode(ctx context.Context, ring *HashRing, bus EventBus, migrator DataMigrator, nodeID string, keys []string) (*RebalanceResponse, error) {
+	start := time.Now()
+	result := &RebalanceResponse{RequestID: fmt.Sprintf("decommission-%s-%d", nodeID, start.UnixNano())}
+
+	owned := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if ring.GetNode(key) == nodeID {
+			owned = append(owned, key)
+		}
+	}
+	keys = owned
+
+	if err := ring.SetNodeStatus(nodeID, NodeLeaving); err != nil {
+		return nil, err
+	}
+	publishDecommissionEvent(ctx, bus, EventDecommissionStarted, DecommissionProgress{NodeID: nodeID, TotalKeys: len(keys)})
+
+	// With the node leaving, the ring already routes its keys to their new owners
+	plan := make(map[string][]string)
+	for _, key := range keys {
+		owner := ring.GetNode(key)
+		if owner == "" {
+			_ = ring.SetNodeStatus(nodeID, NodeAlive)
+			return nil, fmt.Errorf("no remaining node can take key %s: %w", key, ErrInsufficientReplicas)
+		}
+		plan[owner] = append(plan[owner], key)
+	}
+
+	targets := make([]string, 0, len(plan))
+	for target := range plan {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	for _, target := range targets {
+		resp, err := migrator.MigrateKeys(ctx, plan[target], nodeID, target)
+		if resp != nil {
+			result.KeysMoved += resp.KeysMoved
+			result.BytesMoved += resp.BytesMoved
+		}
+		if err != nil {
+			_ = ring.SetNodeStatus(nodeID, NodeAlive)
+			result.Error = err.Error()
+			result.Duration = time.Since(start)
+			return result, fmt.Errorf("migrating keys to %s: %w", target, err)
+		}
+
+		publishDecommissionEvent(ctx, bus, EventDecommissionProgress, DecommissionProgress{
+			NodeID:     nodeID,
+			TargetNode: target,
+			KeysMoved:  result.KeysMoved,
+			TotalKeys:  len(keys),
+		})
+	}
+
+	if err := ring.RemoveNode(nodeID); err != nil {
+		return result, err
+	}
+
+	result.Success = true
+	result.Duration = time.Since(start)
+	result.CompletedAt = time.Now()
+	publishDecommissionEvent(ctx, bus, EventDecommissionCompleted, DecommissionProgress{
+		NodeID:    nodeID,
+		KeysMoved: result.KeysMoved,
+		TotalKeys: len(keys),
+	})
+
+	logging.Info(ctx, logging.ComponentCluster, "decommission", "Node decommissioned", map[string]interface{}{
+		"node_id":     nodeID,
+		"keys_moved":  result.KeysMoved,
+		"bytes_moved": result.BytesMoved,
+		"duration_ms": result.Duration.Milliseconds(),
+	})
+	return result, nil
+}
+
+// publishDecommissionEvent publishes a decommission event if a bus is configured
+func publishDecommissionEvent(ctx context.Context, bus EventBus, eventType ClusterEventType, progress DecommissionProgress) {
+	if bus == nil {
+		return
+	}
+	_ = bus.Publish(ctx, ClusterEvent{
+		Type:      eventType,
+		NodeID:    progress.NodeID,
+		Data:      progress,
+		Timestamp: time.Now(),
+	})
+}
+
+// MigrationSource gives a LocalDataMigrator access to the local stores
+type MigrationSource interface {
+	// ExportKey returns the entries to migrate for key, one per store
+	// holding it, with their values in the stored encoding. No entries
+	// means the key is gone; an error means it can't be migrated and must
+	// not be deleted.
+	ExportKey(key string) ([]MigrationEntry, error)
+
+	// DeleteEntry removes a migrated entry from its store unless the key
+	// was written since it was exported. Reports whether the entry is gone,
+	// false if the key holds a newer write.
+	DeleteEntry(entry MigrationEntry) bool
+}
+
+// LocalDataMigrator implements DataMigrator for keys held by the local node.
+// Each key is pushed to the destination over /internal/replicate-batch and
+// deleted locally once the destination acknowledges it, unless it was
+// written in the meantime, in which case it is sent again.
+type LocalDataMigrator struct {
+	localNodeID string
+	comm        *NodeCommunicator
+	source      MigrationSource
+
+	mu           sync.Mutex
+	progress     map[string]*MigrationProgress
+	cancelled    map[string]bool
+	metrics      MigrationMetrics
+	nextID       int64
+	slotMigrated func(slot uint16, toNode string) error // set by OnSlotMigrated
+}
+
+// NewLocalDataMigrator creates a migrator for keys stored on the local node
+func NewLocalDataMigrator(localNodeID string, comm *NodeCommunicator, source MigrationSource) *LocalDataMigrator {
+	return &LocalDataMigrator{
+		localNodeID: localNodeID,
+		comm:        comm,
+		source:      source,
+		progress:    make(map[string]*MigrationProgress),
+		cancelled:   make(map[string]bool),
+	}
+}
+
+// MigrateKeys implements DataMigrator.MigrateKeys. Only the local node can be the source.
+func (m *LocalDataMigrator) MigrateKeys(ctx context.Context, keys []string, fromNode, toNode string) (*RebalanceResponse, error) {
+	if fromNode != m.localNodeID {
+		return nil, fmt.Errorf("can only migrate keys from the local node %s, not %s", m.localNodeID, fromNode)
+	}
+
+	start := time.Now()
+	requestID, progress := m.begin(len(keys))
+
+	var firstErr error
+	for _, key := range keys {
+		if m.isCancelled(requestID) {
+			firstErr = fmt.Errorf("migration %s cancelled", requestID)
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			firstErr = err
+			break
+		}
+
+		// A key deleted or expired since it was listed has nothing to move
+		h := m.newHandOff(toNode, func() []string { return []string{key} })
+		if err := h.run(ctx, nil); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("key %s: %w", key, err)
+			}
+			m.update(requestID, func(p *MigrationProgress) { p.FailedKeys++ })
+			continue
+		}
+		m.update(requestID, func(p *MigrationProgress) {
+			p.CompletedKeys++
+			p.BytesTransferred += h.bytes
+		})
+	}
+
+	resp := m.finish(requestID, progress, start, firstErr)
+	if firstErr != nil {
+		return resp, fmt.Errorf("%d of %d keys not migrated to %s: %w", len(keys)-resp.KeysMoved, len(keys), toNode, firstErr)
+	}
+	return resp, nil
+}
+
+// SlotSource is a MigrationSource that can list its keys by hash slot, for
+// MigrateSlotRange
+type SlotSource interface {
+	MigrationSource
+
+	// KeysInSlot returns the keys that hash to slot
+	KeysInSlot(slot uint16) []string
+}
+
+// DefaultMigrationBatchSize is how many keys MigrateSlotRange sends per request
+const DefaultMigrationBatchSize = 500
+
+// OnSlotMigrated sets fn to be called by MigrateSlotRange for each slot
+// whose keys toNode has confirmed, before they are deleted locally, e.g. to
+// assign the slot to toNode so requests for it are routed there from then
+// on. An error from fn stops the migration with the slot's keys kept.
+func (m *LocalDataMigrator) OnSlotMigrated(fn func(slot uint16, toNode string) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.slotMigrated = fn
+}
+
+// MigrateSlotRange moves every key in the hash slots startSlot to endSlot,
+// inclusive, to toNode. The source must be a SlotSource. Slots are moved
+// one at a time, their keys sent in batches of DefaultMigrationBatchSize
+// with ReplicateBatch. Once toNode has confirmed all of a slot's keys the
+// slot is marked migrated (see OnSlotMigrated), its keys are deleted
+// locally, and the progress's LastSlot checkpoints it. A failed migration
+// can be resumed from LastSlot+1; the slot it failed on keeps its keys.
+//
+// There is no importing/migrating slot state. Instead a slot's keys are
+// sent again until a pass finds none written since they were sent, and
+// only then is the slot marked migrated. Keys are deleted locally only at
+// the version sent; any written after that, while requests routed before
+// the slot moved were still arriving, are sent again before deletion. A
+// slot that keeps being written fails rather than losing writes.
+func (m *LocalDataMigrator) MigrateSlotRange(ctx context.Context, startSlot, endSlot uint16, toNode string) (*RebalanceResponse, error) {
+	if startSlot > endSlot || endSlot >= HashSlotCount {
+		return nil, fmt.Errorf("invalid slot range %d-%d", startSlot, endSlot)
+	}
+	source, ok := m.source.(SlotSource)
+	if !ok {
+		return nil, fmt.Errorf("migration source can't list keys by slot")
+	}
+
+	start := time.Now()
+	requestID, progress := m.begin(0)
+
+	var firstErr error
+	for slot := int(startSlot); slot <= int(endSlot) && firstErr == nil; slot++ {
+		if m.isCancelled(requestID) {
+			firstErr = fmt.Errorf("migration %s cancelled", requestID)
+			break
+		}
+		firstErr = m.migrateSlot(ctx, requestID, source, uint16(slot), toNode)
+	}
+
+	resp := m.finish(requestID, progress, start, firstErr)
+	if firstErr != nil {
+		next := int(startSlot)
+		if p, err := m.GetProgress(requestID); err == nil && p.LastSlot >= 0 {
+			next = p.LastSlot + 1
+		}
+		return resp, fmt.Errorf("slots %d-%d not migrated to %s: %w", next, endSlot, toNode, firstErr)
+	}
+	return resp, nil
+}
+
+// migrateSlot moves one slot's keys to toNode and checkpoints it
+func (m *LocalDataMigrator) migrateSlot(ctx context.Context, requestID string, source SlotSource, slot uint16, toNode string) error {
+	listed := len(source.KeysInSlot(slot))
+	m.update(requestID, func(p *MigrationProgress) { p.TotalKeys += listed })
+
+	h := m.newHandOff(toNode, func() []string { return source.KeysInSlot(slot) })
+	err := h.run(ctx, func() error {
+		m.mu.Lock()
+		slotMigrated := m.slotMigrated
+		m.mu.Unlock()
+		if slotMigrated == nil {
+			return nil
+		}
+		if err := slotMigrated(slot, toNode); err != nil {
+			return fmt.Errorf("marking slot %d migrated: %w", slot, err)
+		}
+		return nil
+	})
+	if err != nil {
+		m.update(requestID, func(p *MigrationProgress) { p.FailedKeys += h.failed })
+		return err
+	}
+
+	m.update(requestID, func(p *MigrationProgress) {
+		// Keys written to the slot while it moved are moved too
+		if moved := len(h.moved); moved > listed {
+			p.TotalKeys += moved - listed
+		}
+		p.CompletedKeys += len(h.moved)
+		p.BytesTransferred += h.bytes
+		p.CompletedSlots++
+		p.LastSlot = int(slot)
+	})
+	return nil
+}
+
+// maxHandOffPasses bounds how many times a hand-off sends keys that keep
+// being written while they are moved
+const maxHandOffPasses = 5
+
+// handOff moves a set of keys to a node. Entries are tracked at the version
+// sent, so a key written after it was sent is sent again, and a local copy
+// is only deleted while it is the one the node holds.
+type handOff struct {
+	m      *LocalDataMigrator
+	toNode string
+	list   func() []string // the keys to move, as of now
+
+	sent   map[migrationKey]MigrationEntry // awaiting local deletion
+	moved  map[string]struct{}             // keys sent at least once
+	bytes  int64
+	failed int // entries in the batch that failed
+}
+
+// migrationKey identifies an entry by store and key
+type migrationKey struct {
+	store, key string
+}
+
+// newHandOff creates a hand-off of the keys list returns to toNode
+func (m *LocalDataMigrator) newHandOff(toNode string, list func() []string) *handOff {
+	return &handOff{
+		m:      m,
+		toNode: toNode,
+		list:   list,
+		sent:   make(map[migrationKey]MigrationEntry),
+		moved:  make(map[string]struct{}),
+	}
+}
+
+// run sends the keys until a pass finds nothing written since, calls
+// commit (if set) to route the keys to the node, then deletes them
+// locally, sending again any written in the meantime
+func (h *handOff) run(ctx context.Context, commit func() error) error {
+	settled := false
+	for pass := 0; pass < maxHandOffPasses && !settled; pass++ {
+		n, err := h.sendChanged(ctx)
+		if err != nil {
+			return err
+		}
+		settled = n == 0
+	}
+	if !settled {
+		return fmt.Errorf("keys still being written after %d passes", maxHandOffPasses)
+	}
+
+	if commit != nil {
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+
+	for pass := 0; pass < maxHandOffPasses; pass++ {
+		gone := h.deleteSent()
+		n, err := h.sendChanged(ctx)
+		if err != nil {
+			return err
+		}
+		if gone && n == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("keys still being written on %s after %d passes", h.m.localNodeID, maxHandOffPasses)
+}
+
+// sendChanged sends, in batches, every entry of the listed keys that hasn't
+// been sent at its current version. Reports how many entries it sent.
+func (h *handOff) sendChanged(ctx context.Context) (int, error) {
+	count := 0
+	batch := make([]MigrationEntry, 0, DefaultMigrationBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := h.m.comm.ReplicateBatch(ctx, h.toNode, batch); err != nil {
+			h.failed = len(batch)
+			return err
+		}
+		for _, entry := range batch {
+			h.sent[migrationKey{entry.Store, entry.Key}] = entry
+			h.moved[entry.Key] = struct{}{}
+			h.bytes += int64(len(entry.Value))
+		}
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, key := range h.list() {
+		entries, err := h.m.source.ExportKey(key)
+		if err != nil {
+			h.failed = 1
+			return count, fmt.Errorf("key %s: %w", key, err)
+		}
+		for _, entry := range entries {
+			if prev, ok := h.sent[migrationKey{entry.Store, entry.Key}]; ok && prev.Version == entry.Version {
+				continue
+			}
+			batch = append(batch, entry)
+			if len(batch) == DefaultMigrationBatchSize {
+				if err := flush(); err != nil {
+					return count, err
+				}
+			}
+		}
+	}
+	return count, flush()
+}
+
+// deleteSent deletes the local copy of every sent entry still at the
+// version sent. Reports whether all of them are gone.
+func (h *handOff) deleteSent() bool {
+	gone := true
+	for mk, entry := range h.sent {
+		if h.m.source.DeleteEntry(entry) {
+			delete(h.sent, mk)
+		} else {
+			gone = false
+		}
+	}
+	return gone
+}
+
+// begin records a new migration of totalKeys keys
+func (m *LocalDataMigrator) begin(totalKeys int) (string, *MigrationProgress) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	requestID := fmt.Sprintf("migrate-%s-%d", m.localNodeID, m.nextID)
+	progress := &MigrationProgress{
+		RequestID:    requestID,
+		TotalKeys:    totalKeys,
+		StartTime:    time.Now(),
+		CurrentPhase: "migrating",
+		LastSlot:     -1,
+	}
+	m.progress[requestID] = progress
+	m.metrics.ActiveMigrations++
+	return requestID, progress
+}
+
+// finish records the end of a migration, failed if err is set, and returns its result
+func (m *LocalDataMigrator) finish(requestID string, progress *MigrationProgress, start time.Time, err error) *RebalanceResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cancelled, requestID)
+	m.metrics.ActiveMigrations--
+	m.metrics.LastMigrationTime = time.Now()
+
+	resp := &RebalanceResponse{
+		RequestID:   requestID,
+		KeysMoved:   progress.CompletedKeys,
+		BytesMoved:  progress.BytesTransferred,
+		Duration:    time.Since(start),
+		CompletedAt: time.Now(),
+	}
+	m.metrics.TotalKeysMigrated += int64(progress.CompletedKeys)
+	m.metrics.TotalBytesMigrated += progress.BytesTransferred
+
+	if err != nil {
+		progress.CurrentPhase = "failed"
+		m.metrics.FailedMigrations++
+		resp.Error = err.Error()
+		return resp
+	}
+
+	progress.CurrentPhase = "completed"
+	m.metrics.CompletedMigrations++
+	if n := m.metrics.CompletedMigrations; n > 0 {
+		m.metrics.AverageDuration += (resp.Duration - m.metrics.AverageDuration) / time.Duration(n)
+	}
+	resp.Success = true
+	return resp
+}
+
+// GetProgress implements DataMigrator.GetProgress
+func (m *LocalDataMigrator) GetProgress(requestID string) (*MigrationProgress, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	progress, ok := m.progress[requestID]
+	if !ok {
+		return nil, ErrMigrationNotFound
+	}
+	copied := *progress
+	return &copied, nil
+}
+
+// Cancel implements DataMigrator.Cancel. Keys already migrated stay migrated.
+func (m *LocalDataMigrator) Cancel(requestID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	progress, ok := m.progress[requestID]
+	if !ok {
+		return ErrMigrationNotFound
+	}
+	if progress.CurrentPhase == "migrating" {
+		m.cancelled[requestID] = true
+	}
+	return nil
+}
+
+// GetMetrics implements DataMigrator.GetMetrics
+func (m *LocalDataMigrator) GetMetrics() MigrationMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.metrics
+}
+
+// isCancelled reports whether Cancel was called for the migration
+func (m *LocalDataMigrator) isCancelled(requestID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cancelled[requestID]
+}
+
+// update applies fn to a migration's progress under the lock
+func (m *LocalDataMigrator) update(requestID string, fn func(p *MigrationProgress)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(m.progress[requestID])
+}
@@ -0,0 +1,500 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// staticMembership is a fixed member list for NodeCommunicator tests
+type staticMembership struct {
+	members map[string]*ClusterMember
+	events  chan MembershipEvent // returned by Subscribe, nil unless set
+}
+
+func (m *staticMembership) Join(ctx context.Context, seedNodes []string) error { return nil }
+func (m *staticMembership) Leave(ctx context.Context) error                    { return nil }
+func (m *staticMembership) GetMembers() []ClusterMember {
+	members := make([]ClusterMember, 0, len(m.members))
+	for _, member := range m.members {
+		members = append(members, *member)
+	}
+	return members
+}
+func (m *staticMembership) GetMember(nodeID string) (*ClusterMember, bool) {
+	member, ok := m.members[nodeID]
+	return member, ok
+}
+func (m *staticMembership) UpdateMetadata(metadata map[string]string) error { return nil }
+func (m *staticMembership) Subscribe() <-chan MembershipEvent               { return m.events }
+func (m *staticMembership) GetMetrics() MembershipMetrics                   { return MembershipMetrics{} }
+func (m *staticMembership) IsHealthy() bool                                 { return true }
+func (m *staticMembership) GetAliveNodes() []ClusterMember                  { return m.GetMembers() }
+
+// fakePeer records entries received on /internal/replicate-batch
+type fakePeer struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (p *fakePeer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Entries []MigrationEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.mu.Lock()
+	for _, entry := range payload.Entries {
+		p.data[entry.Key] = string(entry.Value)
+	}
+	p.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]int{"applied": len(payload.Entries)})
+}
+
+func (p *fakePeer) get(key string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	value, ok := p.data[key]
+	return value, ok
+}
+
+// mapSource is a MigrationSource backed by a map. Keys in unexportable
+// fail to export.
+type mapSource struct {
+	mu           sync.Mutex
+	data         map[string]string
+	versions     map[string]uint64 // bumped by set
+	unexportable map[string]bool
+}
+
+// set writes a key as a client would, bumping its version
+func (m *mapSource) set(key, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.versions == nil {
+		m.versions = make(map[string]uint64)
+	}
+	m.data[key] = value
+	m.versions[key]++
+}
+
+func (m *mapSource) ExportKey(key string) ([]MigrationEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.unexportable[key] {
+		return nil, fmt.Errorf("can't export %s", key)
+	}
+	value, ok := m.data[key]
+	if !ok {
+		return nil, nil
+	}
+	return []MigrationEntry{{Key: key, Value: []byte(value), ValueType: "string", LamportTS: 1, Version: m.versions[key]}}, nil
+}
+
+func (m *mapSource) DeleteEntry(entry MigrationEntry) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.data[entry.Key]; ok && m.versions[entry.Key] != entry.Version {
+		return false
+	}
+	delete(m.data, entry.Key)
+	return true
+}
+
+func TestDecommissionNode(t *testing.T) {
+	ring := NewHashRing(DefaultHashRingConfig())
+	membership := &staticMembership{members: make(map[string]*ClusterMember)}
+	peers := make(map[string]*fakePeer)
+
+	for _, nodeID := range []string{"node-b", "node-c"} {
+		peer := &fakePeer{data: make(map[string]string)}
+		server := httptest.NewServer(peer)
+		defer server.Close()
+
+		host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+		portNum, _ := strconv.Atoi(port)
+		peers[nodeID] = peer
+		membership.members[nodeID] = &ClusterMember{
+			NodeID:   nodeID,
+			Address:  host,
+			Port:     portNum,
+			Metadata: map[string]string{"http_port": port},
+		}
+		if err := ring.AddNode(nodeID, host, portNum); err != nil {
+			t.Fatalf("AddNode(%s) failed: %v", nodeID, err)
+		}
+	}
+	if err := ring.AddNode("node-a", "127.0.0.1", 1); err != nil {
+		t.Fatalf("AddNode(node-a) failed: %v", err)
+	}
+
+	source := &mapSource{data: make(map[string]string)}
+	var keys, owned []string
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		source.data[key] = "value-" + key
+		keys = append(keys, key)
+		if ring.GetNode(key) == "node-a" {
+			owned = append(owned, key)
+		}
+	}
+	if len(owned) == 0 {
+		t.Fatal("expected node-a to own some keys")
+	}
+
+	bus := &flakyEventBus{}
+	migrator := NewLocalDataMigrator("node-a", NewNodeCommunicator("node-a", membership), source)
+	result, err := DecommissionNode(context.Background(), ring, bus, migrator, "node-a", keys)
+	if err != nil {
+		t.Fatalf("DecommissionNode failed: %v", err)
+	}
+	if !result.Success || result.KeysMoved != len(owned) {
+		t.Errorf("Expected %d keys moved, got %+v", len(owned), result)
+	}
+
+	if _, ok := ring.GetNodes()["node-a"]; ok {
+		t.Error("Expected node-a to be removed from the ring")
+	}
+	for _, key := range owned {
+		owner := ring.GetNode(key)
+		peer, ok := peers[owner]
+		if !ok {
+			t.Fatalf("Key %s routed to unexpected node %q", key, owner)
+		}
+		if value, ok := peer.get(key); !ok || value != "value-"+key {
+			t.Errorf("Key %s not readable on new owner %s (got %q)", key, owner, value)
+		}
+		if _, ok := source.data[key]; ok {
+			t.Errorf("Expected migrated key %s to be deleted locally", key)
+		}
+	}
+
+	if metrics := migrator.GetMetrics(); metrics.TotalKeysMigrated != int64(len(owned)) {
+		t.Errorf("Expected %d keys in migrator metrics, got %d", len(owned), metrics.TotalKeysMigrated)
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if len(bus.published) < 3 {
+		t.Fatalf("Expected started, progress and completed events, got %d events", len(bus.published))
+	}
+	if first := bus.published[0].Type; first != EventDecommissionStarted {
+		t.Errorf("Expected first event %s, got %s", EventDecommissionStarted, first)
+	}
+	if last := bus.published[len(bus.published)-1]; last.Type != EventDecommissionCompleted {
+		t.Errorf("Expected last event %s, got %s", EventDecommissionCompleted, last.Type)
+	}
+}
+
+func TestDecommissionNode_RestoresNodeOnFailure(t *testing.T) {
+	ring := NewHashRing(DefaultHashRingConfig())
+	for _, nodeID := range []string{"node-a", "node-b"} {
+		if err := ring.AddNode(nodeID, "127.0.0.1", 1); err != nil {
+			t.Fatalf("AddNode(%s) failed: %v", nodeID, err)
+		}
+	}
+
+	source := &mapSource{data: make(map[string]string)}
+	var keys []string
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		source.data[key] = "v"
+		keys = append(keys, key)
+	}
+
+	// node-b is not a known member, so every migration fails
+	membership := &staticMembership{members: make(map[string]*ClusterMember)}
+	migrator := NewLocalDataMigrator("node-a", NewNodeCommunicator("node-a", membership), source)
+	if _, err := DecommissionNode(context.Background(), ring, nil, migrator, "node-a", keys); err == nil {
+		t.Fatal("Expected DecommissionNode to fail")
+	}
+
+	node, ok := ring.GetNodes()["node-a"]
+	if !ok || node.Status != NodeAlive {
+		t.Error("Expected node-a to stay on the ring as alive after a failed decommission")
+	}
+	if len(source.data) != len(keys) {
+		t.Errorf("Expected no keys deleted locally, %d left of %d", len(source.data), len(keys))
+	}
+}
+
+func TestDecommissionNode_FailsOnUnexportableKey(t *testing.T) {
+	ring := NewHashRing(DefaultHashRingConfig())
+	membership := &staticMembership{members: make(map[string]*ClusterMember)}
+	peer := &fakePeer{data: make(map[string]string)}
+	server := httptest.NewServer(peer)
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	membership.members["node-b"] = &ClusterMember{NodeID: "node-b", Address: host, Port: portNum, Metadata: map[string]string{"http_port": port}}
+	for _, nodeID := range []string{"node-a", "node-b"} {
+		if err := ring.AddNode(nodeID, host, portNum); err != nil {
+			t.Fatalf("AddNode(%s) failed: %v", nodeID, err)
+		}
+	}
+
+	source := &mapSource{data: make(map[string]string), unexportable: make(map[string]bool)}
+	var keys []string
+	for i := 0; len(keys) < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if ring.GetNode(key) == "node-a" {
+			source.data[key] = "v"
+			keys = append(keys, key)
+		}
+	}
+	stuck := keys[7]
+	source.unexportable[stuck] = true
+
+	migrator := NewLocalDataMigrator("node-a", NewNodeCommunicator("node-a", membership), source)
+	result, err := DecommissionNode(context.Background(), ring, nil, migrator, "node-a", keys)
+	if err == nil {
+		t.Fatal("Expected DecommissionNode to fail on a key it can't export")
+	}
+	if result.Success || result.KeysMoved != len(keys)-1 {
+		t.Errorf("Expected %d keys moved and no success, got %+v", len(keys)-1, result)
+	}
+	if _, ok := source.data[stuck]; !ok {
+		t.Error("Expected the unexported key kept locally")
+	}
+	if node, ok := ring.GetNodes()["node-a"]; !ok || node.Status != NodeAlive {
+		t.Error("Expected node-a to stay on the ring as alive")
+	}
+}
+
+// slotMapSource is a mapSource that lists its keys by hash slot
+type slotMapSource struct {
+	mapSource
+}
+
+func (m *slotMapSource) KeysInSlot(slot uint16) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.data {
+		if GetHashSlot(key) == slot {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// batchPeer records entries received on /internal/replicate-batch and
+// rejects batches holding a key of failSlot. onBatch, if set, runs after
+// each batch is applied.
+type batchPeer struct {
+	mu       sync.Mutex
+	data     map[string]string
+	failSlot int
+	batches  int
+	onBatch  func(batch int)
+}
+
+func (p *batchPeer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Entries []MigrationEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range payload.Entries {
+		if int(GetHashSlot(entry.Key)) == p.failSlot {
+			http.Error(w, "injected failure", http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, entry := range payload.Entries {
+		p.data[entry.Key] = string(entry.Value)
+	}
+	p.batches++
+	if p.onBatch != nil {
+		p.onBatch(p.batches)
+	}
+	json.NewEncoder(w).Encode(map[string]int{"applied": len(payload.Entries)})
+}
+
+func TestLocalDataMigrator_MigrateSlotRange(t *testing.T) {
+	source := &slotMapSource{mapSource{data: make(map[string]string)}}
+	occupied := make(map[uint16]bool)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		source.data[key] = "value-" + key
+		occupied[GetHashSlot(key)] = true
+	}
+	var slots []int
+	for slot := range occupied {
+		slots = append(slots, int(slot))
+	}
+	sort.Ints(slots)
+	endSlot := uint16(slots[9])
+	failSlot := slots[5]
+
+	peer := &batchPeer{data: make(map[string]string), failSlot: failSlot}
+	server := httptest.NewServer(peer)
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	membership := &staticMembership{members: map[string]*ClusterMember{
+		"node-b": {NodeID: "node-b", Address: host, Port: portNum, Metadata: map[string]string{"http_port": port}},
+	}}
+
+	migrator := NewLocalDataMigrator("node-a", NewNodeCommunicator("node-a", membership), source)
+	var migrated []uint16
+	migrator.OnSlotMigrated(func(slot uint16, toNode string) error {
+		if toNode != "node-b" {
+			t.Errorf("Expected slot %d migrated to node-b, got %s", slot, toNode)
+		}
+		migrated = append(migrated, slot)
+		return nil
+	})
+
+	// The batch for failSlot is rejected: the slots before it are
+	// checkpointed and the rest keep their keys
+	result, err := migrator.MigrateSlotRange(context.Background(), 0, endSlot, "node-b")
+	if err == nil {
+		t.Fatal("Expected the migration to fail at the injected slot")
+	}
+	progress, _ := migrator.GetProgress(result.RequestID)
+	if progress.LastSlot != failSlot-1 || progress.CompletedSlots != failSlot {
+		t.Errorf("Expected slots up to %d checkpointed, got last %d, %d completed", failSlot-1, progress.LastSlot, progress.CompletedSlots)
+	}
+	if len(migrated) != failSlot {
+		t.Errorf("Expected %d slots marked migrated, got %d", failSlot, len(migrated))
+	}
+	for key := range source.data {
+		if slot := int(GetHashSlot(key)); slot < failSlot {
+			t.Errorf("Expected key %s of checkpointed slot %d to be deleted locally", key, slot)
+		}
+	}
+
+	// Resuming after the checkpoint moves the rest
+	peer.mu.Lock()
+	peer.failSlot = -1
+	peer.mu.Unlock()
+	if _, err := migrator.MigrateSlotRange(context.Background(), uint16(progress.LastSlot+1), endSlot, "node-b"); err != nil {
+		t.Fatalf("Resumed migration failed: %v", err)
+	}
+	if len(migrated) != int(endSlot)+1 {
+		t.Errorf("Expected %d slots marked migrated, got %d", int(endSlot)+1, len(migrated))
+	}
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		_, local := source.data[key]
+		value, remote := peer.data[key]
+		if int(GetHashSlot(key)) > int(endSlot) {
+			if !local || remote {
+				t.Errorf("Expected key %s outside the range to stay local", key)
+			}
+			continue
+		}
+		if local || value != "value-"+key {
+			t.Errorf("Expected key %s moved to node-b, local=%v remote=%q", key, local, value)
+		}
+	}
+}
+
+func TestLocalDataMigrator_MigrateSlotRangeKeepsConcurrentWrites(t *testing.T) {
+	source := &slotMapSource{mapSource{data: make(map[string]string)}}
+	source.set("{slot}a", "a1")
+	source.set("{slot}b", "b1")
+	slot := GetHashSlot("{slot}a")
+
+	// Clients keep writing to the slot while it is being sent: the first
+	// batch is followed by an overwrite and a new key
+	peer := &batchPeer{data: make(map[string]string), failSlot: -1}
+	peer.onBatch = func(batch int) {
+		if batch == 1 {
+			source.set("{slot}a", "a2")
+			source.set("{slot}c", "c1")
+		}
+	}
+	server := httptest.NewServer(peer)
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	membership := &staticMembership{members: map[string]*ClusterMember{
+		"node-b": {NodeID: "node-b", Address: host, Port: portNum, Metadata: map[string]string{"http_port": port}},
+	}}
+
+	migrator := NewLocalDataMigrator("node-a", NewNodeCommunicator("node-a", membership), source)
+	var assignedWith map[string]string
+	migrator.OnSlotMigrated(func(slot uint16, toNode string) error {
+		peer.mu.Lock()
+		assignedWith = make(map[string]string, len(peer.data))
+		for key, value := range peer.data {
+			assignedWith[key] = value
+		}
+		peer.mu.Unlock()
+
+		// A write routed here before the slot moved lands after it did
+		source.set("{slot}b", "b2")
+		return nil
+	})
+
+	result, err := migrator.MigrateSlotRange(context.Background(), slot, slot, "node-b")
+	if err != nil {
+		t.Fatalf("MigrateSlotRange failed: %v", err)
+	}
+	if result.KeysMoved != 3 {
+		t.Errorf("Expected 3 keys moved, got %d", result.KeysMoved)
+	}
+
+	// The slot was only assigned once the target had every write made
+	// before it, and nothing written later was lost
+	if want := map[string]string{"{slot}a": "a2", "{slot}b": "b1", "{slot}c": "c1"}; !reflect.DeepEqual(assignedWith, want) {
+		t.Errorf("Expected the target to hold %v when the slot was assigned, got %v", want, assignedWith)
+	}
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	if want := map[string]string{"{slot}a": "a2", "{slot}b": "b2", "{slot}c": "c1"}; !reflect.DeepEqual(peer.data, want) {
+		t.Errorf("Expected the target to end with %v, got %v", want, peer.data)
+	}
+	if len(source.data) != 0 {
+		t.Errorf("Expected every key of the slot deleted locally, %v left", source.data)
+	}
+}
+
+func TestLocalDataMigrator_MigrateSlotRangeRefusesBusySlot(t *testing.T) {
+	source := &slotMapSource{mapSource{data: make(map[string]string)}}
+	source.set("{busy}a", "0")
+	slot := GetHashSlot("{busy}a")
+
+	// Every batch is followed by another write, so the slot never settles
+	peer := &batchPeer{data: make(map[string]string), failSlot: -1}
+	peer.onBatch = func(batch int) { source.set("{busy}a", strconv.Itoa(batch)) }
+	server := httptest.NewServer(peer)
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	membership := &staticMembership{members: map[string]*ClusterMember{
+		"node-b": {NodeID: "node-b", Address: host, Port: portNum, Metadata: map[string]string{"http_port": port}},
+	}}
+
+	migrator := NewLocalDataMigrator("node-a", NewNodeCommunicator("node-a", membership), source)
+	assigned := false
+	migrator.OnSlotMigrated(func(slot uint16, toNode string) error {
+		assigned = true
+		return nil
+	})
+	if _, err := migrator.MigrateSlotRange(context.Background(), slot, slot, "node-b"); err == nil {
+		t.Fatal("Expected the migration of a slot that keeps changing to fail")
+	}
+	if assigned {
+		t.Error("Expected the slot not to be assigned while it holds unsent writes")
+	}
+	if _, ok := source.data["{busy}a"]; !ok {
+		t.Error("Expected the key kept locally")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"hypercache/internal/logging"
@@ -19,6 +20,7 @@ type DistributedCoordinator struct {
 	membership *GossipMembership
 	hashRing   *HashRing
 	eventBus   *DistributedEventBus
+	publishBus *BreakerEventBus // eventBus behind the replication circuit breaker
 	clock      *LamportClock
 
 	// State management
@@ -29,6 +31,10 @@ type DistributedCoordinator struct {
 	// Lifecycle monitoring
 	lastHeartbeat time.Time
 	healthMu      sync.RWMutex
+
+	// Bumped on every change to the ring's members, so clients can tell
+	// the topology moved
+	configEpoch atomic.Uint64
 }
 
 // NewDistributedCoordinator creates a new distributed coordinator
@@ -42,12 +48,19 @@ func NewDistributedCoordinator(config ClusterConfig) (*DistributedCoordinator, e
 	if err != nil {
 		return nil, fmt.Errorf("failed to create membership provider: %w", err)
 	}
+	// Use the advertise address membership resolved, so the ring matches what peers see
+	config.AdvertiseAddress = membership.config.AdvertiseAddress
 
 	// Create hash ring
 	hashRing := NewHashRing(config.HashRing)
 
 	// Create event bus
-	eventBus := NewDistributedEventBus(config.NodeID, membership)
+	eventBus := NewDistributedEventBusWithConfig(config.NodeID, membership, config.EventBus)
+
+	breakerCfg := config.ReplicationBreaker
+	if breakerCfg == (CircuitBreakerConfig{}) {
+		breakerCfg = DefaultCircuitBreakerConfig()
+	}
 
 	coordinator := &DistributedCoordinator{
 		config:        config,
@@ -55,9 +68,11 @@ func NewDistributedCoordinator(config ClusterConfig) (*DistributedCoordinator, e
 		membership:    membership,
 		hashRing:      hashRing,
 		eventBus:      eventBus,
+		publishBus:    NewBreakerEventBus(eventBus, breakerCfg),
 		clock:         NewLamportClock(),
 		lastHeartbeat: time.Now(),
 	}
+	membership.SetHealthProvider(coordinator.GetHealth)
 
 	return coordinator, nil
 }
@@ -95,6 +110,8 @@ func (dc *DistributedCoordinator) Start(ctx context.Context) error {
 		_ = dc.eventBus.Stop(ctx)
 		return fmt.Errorf("failed to add local node to hash ring: %w", err)
 	}
+	_ = dc.hashRing.SetNodeCapabilities(dc.localNodeID, localCapabilities)
+	dc.configEpoch.Add(1)
 
 	// Join cluster if seed nodes are provided
 	if len(dc.config.SeedNodes) > 0 {
@@ -175,9 +192,10 @@ func (dc *DistributedCoordinator) GetRouting() RoutingProvider {
 	return &distributedRouting{coordinator: dc}
 }
 
-// GetEventBus implements CoordinatorService.GetEventBus
+// GetEventBus implements CoordinatorService.GetEventBus. Publishing goes
+// through the replication circuit breaker so a slow peer can't stall writes.
 func (dc *DistributedCoordinator) GetEventBus() EventBus {
-	return dc.eventBus
+	return dc.publishBus
 }
 
 // GetClock implements CoordinatorService.GetClock
@@ -248,6 +266,50 @@ func (dc *DistributedCoordinator) GetHealth() CoordinatorHealth {
 	return health
 }
 
+// DefaultHealthQueryTimeout bounds QueryClusterHealth when ctx has no deadline
+const DefaultHealthQueryTimeout = 3 * time.Second
+
+// QueryClusterHealth asks every member for its health over gossip and returns
+// the answers keyed by node ID. Members that don't answer before ctx's
+// deadline (or DefaultHealthQueryTimeout) are missing from the result.
+func (dc *DistributedCoordinator) QueryClusterHealth(ctx context.Context) (map[string]CoordinatorHealth, error) {
+	timeout := DefaultHealthQueryTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return dc.membership.QueryHealth(timeout)
+}
+
+// SetDBSizeProvider sets how this node counts the keys of a store when
+// another node runs QueryClusterDBSize. Until it is set the node doesn't
+// answer, and cluster-wide counts are reported as partial.
+func (dc *DistributedCoordinator) SetDBSizeProvider(provider func(store string) int64) {
+	dc.membership.SetDBSizeProvider(provider)
+}
+
+// QueryClusterDBSize asks every member for its key count in store over
+// gossip and sums the answers. Members that don't answer before ctx's
+// deadline (or DefaultHealthQueryTimeout) are left out of the total and
+// mark the result Partial.
+func (dc *DistributedCoordinator) QueryClusterDBSize(ctx context.Context, store string) (*ClusterDBSize, error) {
+	timeout := DefaultHealthQueryTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	members := len(dc.membership.GetAliveNodes())
+	sizes, err := dc.membership.QueryDBSize(store, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ClusterDBSize{Nodes: sizes, Members: members}
+	for _, keys := range sizes {
+		result.Total += keys
+	}
+	result.Partial = len(sizes) < members
+	return result, nil
+}
+
 // GetMetrics implements CoordinatorService.GetMetrics
 func (dc *DistributedCoordinator) GetMetrics() CoordinatorMetrics {
 	return CoordinatorMetrics{
@@ -291,6 +353,8 @@ func (dc *DistributedCoordinator) syncExistingMembers() {
 			// "already exists" is expected if the subscribe caught it too — ignore
 			continue
 		}
+		dc.configEpoch.Add(1)
+		dc.applyCapabilities(member)
 
 		logging.Info(nil, logging.ComponentCoordinator, "hash_ring", "Synced existing member to hash ring", map[string]interface{}{
 			"node_id": member.NodeID,
@@ -312,6 +376,8 @@ func (dc *DistributedCoordinator) handleMembershipEvent(ctx context.Context, eve
 			logging.Error(nil, logging.ComponentCoordinator, "hash_ring", "Failed to add node to hash ring", err, map[string]interface{}{"node_id": member.NodeID})
 			return
 		}
+		dc.configEpoch.Add(1)
+		dc.applyCapabilities(member)
 
 		logging.Info(nil, logging.ComponentCoordinator, "hash_ring", "Added node to hash ring", map[string]interface{}{"node_id": member.NodeID, "address": member.Address, "port": member.Port})
 
@@ -331,6 +397,7 @@ func (dc *DistributedCoordinator) handleMembershipEvent(ctx context.Context, eve
 			logging.Error(nil, logging.ComponentCoordinator, "hash_ring", "Failed to remove node from hash ring", err, map[string]interface{}{"node_id": member.NodeID})
 			return
 		}
+		dc.configEpoch.Add(1)
 
 		logging.Info(nil, logging.ComponentCoordinator, "hash_ring", "Removed node from hash ring", map[string]interface{}{"node_id": member.NodeID})
 
@@ -354,11 +421,24 @@ func (dc *DistributedCoordinator) handleMembershipEvent(ctx context.Context, eve
 		logging.Info(nil, logging.ComponentCoordinator, "hash_ring", "Node recovered", map[string]interface{}{"node_id": member.NodeID})
 
 	case MemberUpdated:
-		// Node metadata updated - no hash ring changes needed
+		// Node metadata updated - capabilities may have changed
+		dc.applyCapabilities(member)
 		logging.Debug(nil, logging.ComponentCoordinator, "hash_ring", "Node metadata updated", map[string]interface{}{"node_id": member.NodeID})
 	}
 }
 
+// applyCapabilities records a member's advertised capabilities on its ring node
+func (dc *DistributedCoordinator) applyCapabilities(member ClusterMember) {
+	capabilities := ParseCapabilities(member.Metadata["capabilities"])
+	if err := dc.hashRing.SetNodeCapabilities(member.NodeID, capabilities); err != nil {
+		return // Node not on the ring (e.g. update for a departed member)
+	}
+	logging.Debug(nil, logging.ComponentCoordinator, "hash_ring", "Node capabilities recorded", map[string]interface{}{
+		"node_id":      member.NodeID,
+		"capabilities": capabilities,
+	})
+}
+
 // heartbeatLoop runs the background heartbeat
 func (dc *DistributedCoordinator) heartbeatLoop(ctx context.Context) {
 	ticker := time.NewTicker(time.Duration(dc.config.HeartbeatInterval) * time.Second)
@@ -415,6 +495,12 @@ func (dc *DistributedCoordinator) isConnectedToSeeds() bool {
 	return false
 }
 
+// SlotNodes returns the nodes holding a hash slot's keys: its owner, then
+// its replicas
+func (dc *DistributedCoordinator) SlotNodes(slot uint16) []string {
+	return dc.hashRing.SlotReplicas(slot, dc.config.HashRing.ReplicationFactor)
+}
+
 // distributedRouting implements RoutingProvider for the distributed coordinator
 type distributedRouting struct {
 	coordinator *DistributedCoordinator
@@ -461,6 +547,87 @@ func (dr *distributedRouting) GetMetrics() HashRingMetrics {
 	return dr.coordinator.hashRing.GetMetrics()
 }
 
+// Decommission gracefully removes the local node from the cluster: keys are
+// migrated to their new owners, then the node leaves so peers drop it from
+// their rings.
+func (dc *DistributedCoordinator) Decommission(ctx context.Context, migrator DataMigrator, keys []string) (*RebalanceResponse, error) {
+	result, err := DecommissionNode(ctx, dc.hashRing, dc.eventBus, migrator, dc.localNodeID, keys)
+	if err != nil {
+		return result, err
+	}
+	dc.configEpoch.Add(1)
+	if err := dc.membership.Leave(ctx); err != nil {
+		return result, fmt.Errorf("keys migrated but leaving the cluster failed: %w", err)
+	}
+	return result, nil
+}
+
+// MigrateSlotRange moves the local keys in hash slots startSlot to endSlot,
+// inclusive, to toNode with migrator, assigning each slot to toNode in the
+// local hash ring as soon as toNode has confirmed its keys. Other nodes
+// keep routing the slots by ring position until told otherwise.
+func (dc *DistributedCoordinator) MigrateSlotRange(ctx context.Context, migrator *LocalDataMigrator, startSlot, endSlot uint16, toNode string) (*RebalanceResponse, error) {
+	if toNode == dc.localNodeID {
+		return nil, fmt.Errorf("can't migrate slots to the local node")
+	}
+	moved := false
+	migrator.OnSlotMigrated(func(slot uint16, toNode string) error {
+		if err := dc.hashRing.AssignSlots(slot, slot, toNode); err != nil {
+			return err
+		}
+		moved = true
+		return nil
+	})
+	result, err := migrator.MigrateSlotRange(ctx, startSlot, endSlot, toNode)
+	if moved {
+		dc.configEpoch.Add(1)
+	}
+	return result, err
+}
+
+// ForgetNode removes a departed node from the local hash ring. The local node
+// cannot be forgotten; use Decommission instead.
+func (dc *DistributedCoordinator) ForgetNode(nodeID string) error {
+	if nodeID == dc.localNodeID {
+		return fmt.Errorf("can't forget the local node, decommission it instead")
+	}
+	if err := dc.hashRing.RemoveNode(nodeID); err != nil {
+		return err
+	}
+	dc.configEpoch.Add(1)
+	return nil
+}
+
+// ConfigEpoch returns the local node's config epoch, which increases every
+// time a node joins, leaves, fails or is forgotten. Epochs aren't gossiped:
+// each node counts the changes it has seen.
+func (dc *DistributedCoordinator) ConfigEpoch() uint64 {
+	return dc.configEpoch.Load()
+}
+
+// BumpConfigEpoch increments the config epoch without a topology change,
+// to make clients refresh their view of the cluster, and returns the new
+// epoch
+func (dc *DistributedCoordinator) BumpConfigEpoch() uint64 {
+	return dc.configEpoch.Add(1)
+}
+
+// HandleCommand registers the local handler for a cluster-wide command
+func (dc *DistributedCoordinator) HandleCommand(name string, handler CommandHandler) {
+	dc.eventBus.Commands().Handle(name, handler)
+}
+
+// BroadcastCommand runs a command on every node in the cluster, this one included
+func (dc *DistributedCoordinator) BroadcastCommand(ctx context.Context, name string, args map[string]string) error {
+	return dc.eventBus.BroadcastCommand(ctx, name, args)
+}
+
+// BroadcastCommandSync runs a command on every node in the cluster, this
+// one included, and waits for each alive member to confirm it ran
+func (dc *DistributedCoordinator) BroadcastCommandSync(ctx context.Context, name string, args map[string]string) (*CommandAcks, error) {
+	return dc.eventBus.BroadcastCommandSync(ctx, name, args)
+}
+
 // GetHashRing returns the underlying hash ring (for direct routing lookups)
 func (dc *DistributedCoordinator) GetHashRing() *HashRing {
 	return dc.hashRing
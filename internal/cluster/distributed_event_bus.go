@@ -6,23 +6,31 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
 )
 
 // DistributedEventBus implements EventBus using gossip for cluster-wide events
 type DistributedEventBus struct {
 	nodeID     string
 	membership *GossipMembership
+	config     EventBusConfig
+
+	// Cluster-wide control commands received as user events
+	commands *CommandDispatcher
 
 	// Event subscriptions
-	subscribers map[chan ClusterEvent][]ClusterEventType
+	subscribers map[chan ClusterEvent]*eventSubscriber
+	nextSubID   int
 	subsMu      sync.RWMutex
 
 	// Metrics
 	eventsPublished int64
 	eventsReceived  int64
+	eventsDropped   atomic.Int64
 	metricsMu       sync.RWMutex
 
 	// Lifecycle
@@ -30,12 +38,29 @@ type DistributedEventBus struct {
 	runMu   sync.RWMutex
 }
 
-// NewDistributedEventBus creates a new distributed event bus
+// eventSubscriber tracks a subscriber's filter and how many events it has missed
+type eventSubscriber struct {
+	id         int
+	eventTypes []ClusterEventType
+	dropped    atomic.Int64
+}
+
+// NewDistributedEventBus creates a new distributed event bus with default delivery settings
 func NewDistributedEventBus(nodeID string, membership *GossipMembership) *DistributedEventBus {
+	return NewDistributedEventBusWithConfig(nodeID, membership, DefaultEventBusConfig())
+}
+
+// NewDistributedEventBusWithConfig creates a new distributed event bus with the given delivery settings
+func NewDistributedEventBusWithConfig(nodeID string, membership *GossipMembership, config EventBusConfig) *DistributedEventBus {
+	if config.SubscriberBuffer <= 0 {
+		config.SubscriberBuffer = DefaultEventBusConfig().SubscriberBuffer
+	}
 	return &DistributedEventBus{
 		nodeID:      nodeID,
 		membership:  membership,
-		subscribers: make(map[chan ClusterEvent][]ClusterEventType),
+		config:      config,
+		commands:    NewCommandDispatcher(),
+		subscribers: make(map[chan ClusterEvent]*eventSubscriber),
 	}
 }
 
@@ -52,6 +77,7 @@ func (deb *DistributedEventBus) Start(ctx context.Context) error {
 
 	// Register with gossip membership to receive user events
 	deb.membership.SetUserEventHandler(deb.processIncomingGossipEvent)
+	deb.membership.SetQueryHandler(deb.processIncomingQuery)
 
 	// Start listening for gossip events that represent cluster events
 	go deb.listenForGossipEvents(ctx)
@@ -75,7 +101,7 @@ func (deb *DistributedEventBus) Stop(ctx context.Context) error {
 	for ch := range deb.subscribers {
 		close(ch)
 	}
-	deb.subscribers = make(map[chan ClusterEvent][]ClusterEventType)
+	deb.subscribers = make(map[chan ClusterEvent]*eventSubscriber)
 	deb.subsMu.Unlock()
 
 	return nil
@@ -97,10 +123,11 @@ func (deb *DistributedEventBus) Publish(ctx context.Context, event ClusterEvent)
 
 // Subscribe implements EventBus.Subscribe
 func (deb *DistributedEventBus) Subscribe(eventTypes ...ClusterEventType) <-chan ClusterEvent {
-	ch := make(chan ClusterEvent, 100)
+	ch := make(chan ClusterEvent, deb.config.SubscriberBuffer)
 
 	deb.subsMu.Lock()
-	deb.subscribers[ch] = eventTypes
+	deb.nextSubID++
+	deb.subscribers[ch] = &eventSubscriber{id: deb.nextSubID, eventTypes: eventTypes}
 	deb.subsMu.Unlock()
 
 	return ch
@@ -128,12 +155,19 @@ func (deb *DistributedEventBus) GetMetrics() EventBusMetrics {
 	defer deb.subsMu.RUnlock()
 	defer deb.metricsMu.RUnlock()
 
+	subscriberDrops := make(map[int]int64, len(deb.subscribers))
+	for _, sub := range deb.subscribers {
+		subscriberDrops[sub.id] = sub.dropped.Load()
+	}
+
 	return EventBusMetrics{
 		EventsPublished:   deb.eventsPublished,
 		EventsReceived:    deb.eventsReceived,
 		ActiveSubscribers: len(deb.subscribers),
 		LastEventTime:     time.Now(),            // Approximation
 		AverageLatency:    time.Millisecond * 50, // Approximation
+		EventsDropped:     deb.eventsDropped.Load(),
+		SubscriberDrops:   subscriberDrops,
 	}
 }
 
@@ -162,27 +196,52 @@ func (deb *DistributedEventBus) deliverLocalEvent(event ClusterEvent) {
 	deb.subsMu.RLock()
 	defer deb.subsMu.RUnlock()
 
-	for ch, eventTypes := range deb.subscribers {
+	for ch, sub := range deb.subscribers {
 		// Check if subscriber is interested in this event type
 		interested := false
-		for _, eventType := range eventTypes {
+		for _, eventType := range sub.eventTypes {
 			if eventType == event.Type {
 				interested = true
 				break
 			}
 		}
 
-		if interested {
-			select {
-			case ch <- event:
-			default:
-				// Channel is full, skip this subscriber
-				logging.Warn(nil, logging.ComponentEventBus, "channel_full", "Event channel full for subscriber")
-			}
+		if interested && !sendWithTimeout(ch, event, deb.config.DeliveryTimeout) {
+			// Subscriber stayed full past the delivery timeout; count the loss so it is observable
+			dropped := sub.dropped.Add(1)
+			deb.eventsDropped.Add(1)
+			metrics.Global().IncCounter("hypercache_event_bus_events_dropped_total")
+			logging.Warn(nil, logging.ComponentEventBus, "channel_full", "Event channel full for subscriber, event dropped", map[string]interface{}{
+				"subscriber_id": sub.id,
+				"event_type":    string(event.Type),
+				"dropped_total": dropped,
+			})
 		}
 	}
 }
 
+// sendWithTimeout delivers v on ch, waiting up to timeout for space if the
+// channel is full. Returns false if the value was not delivered.
+func sendWithTimeout[T any](ch chan<- T, v T, timeout time.Duration) bool {
+	select {
+	case ch <- v:
+		return true
+	default:
+	}
+	if timeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ch <- v:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
 // listenForGossipEvents processes incoming gossip events and converts them to cluster events
 func (deb *DistributedEventBus) listenForGossipEvents(ctx context.Context) {
 	// This is a simplified approach - in a full implementation, we'd need to
@@ -212,6 +271,11 @@ func (deb *DistributedEventBus) listenForGossipEvents(ctx context.Context) {
 
 // processIncomingGossipEvent handles incoming gossip events from other nodes
 func (deb *DistributedEventBus) processIncomingGossipEvent(eventName string, payload []byte) {
+	if strings.HasPrefix(eventName, commandEventPrefix) {
+		deb.processIncomingCommand(payload)
+		return
+	}
+
 	// Parse the event type from the gossip event name
 	if !strings.HasPrefix(eventName, "cluster-event:") {
 		return // Not a cluster event
@@ -244,6 +308,11 @@ func (deb *DistributedEventBus) processIncomingGossipEvent(eventName string, pay
 	deb.deliverLocalEvent(event)
 }
 
+// Commands returns the dispatcher that runs cluster commands on this node
+func (deb *DistributedEventBus) Commands() *CommandDispatcher {
+	return deb.commands
+}
+
 // QueryCluster sends a query to all nodes and collects responses
 func (deb *DistributedEventBus) QueryCluster(queryName string, data interface{}, timeout time.Duration) ([]interface{}, error) {
 	// Serialize the query data
@@ -305,7 +374,7 @@ func (deb *DistributedEventBus) GetClusterHealth(ctx context.Context) (map[strin
 	_, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
-	responses, err := deb.QueryCluster("health-check", map[string]interface{}{
+	responses, err := deb.QueryCluster(HealthCheckQuery, map[string]interface{}{
 		"requestor": deb.nodeID,
 		"timestamp": time.Now(),
 	}, time.Second*5)
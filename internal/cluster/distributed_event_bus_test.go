@@ -0,0 +1,65 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDistributedEventBus_SlowSubscriberDropsCounted(t *testing.T) {
+	deb := NewDistributedEventBusWithConfig("node-1", nil, EventBusConfig{
+		SubscriberBuffer: 2,
+		DeliveryTimeout:  5 * time.Millisecond,
+	})
+
+	slow := deb.Subscribe(EventDataOperation)
+	fast := deb.Subscribe(EventDataOperation)
+
+	done := make(chan int)
+	go func() {
+		received := 0
+		for range fast {
+			received++
+			if received == 5 {
+				done <- received
+				return
+			}
+		}
+	}()
+
+	// The slow subscriber never reads, so only its buffer fills
+	for i := 0; i < 5; i++ {
+		deb.deliverLocalEvent(ClusterEvent{Type: EventDataOperation, NodeID: "node-2"})
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Fast subscriber did not receive all events")
+	}
+
+	metrics := deb.GetMetrics()
+	if metrics.EventsDropped != 3 {
+		t.Errorf("Expected 3 dropped events, got %d", metrics.EventsDropped)
+	}
+	if len(slow) != 2 {
+		t.Errorf("Expected slow subscriber buffer to hold 2 events, got %d", len(slow))
+	}
+
+	var slowDrops, fastDrops int64 = -1, -1
+	deb.subsMu.RLock()
+	for ch, sub := range deb.subscribers {
+		if (<-chan ClusterEvent)(ch) == slow {
+			slowDrops = metrics.SubscriberDrops[sub.id]
+		} else {
+			fastDrops = metrics.SubscriberDrops[sub.id]
+		}
+	}
+	deb.subsMu.RUnlock()
+
+	if slowDrops != 3 {
+		t.Errorf("Expected 3 drops for slow subscriber, got %d", slowDrops)
+	}
+	if fastDrops != 0 {
+		t.Errorf("Expected no drops for fast subscriber, got %d", fastDrops)
+	}
+}
@@ -2,19 +2,30 @@ package cluster
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
 
 	"github.com/hashicorp/serf/serf"
 )
 
+// HealthCheckQuery is the Serf query members answer with their JSON-encoded CoordinatorHealth
+const HealthCheckQuery = "health-check"
+
+// DBSizeQuery is the Serf query members answer with their JSON-encoded
+// NodeDBSize for the store named in the payload
+const DBSizeQuery = "dbsize"
+
 // GossipMembership implements MembershipProvider using Serf gossip protocol
 type GossipMembership struct {
 	config     ClusterConfig
@@ -29,14 +40,25 @@ type GossipMembership struct {
 	// User event handler
 	userEventHandler func(eventName string, payload []byte)
 
+	// Handler answering queries other than health-check and dbsize; a nil
+	// response leaves the query unanswered
+	queryHandler func(queryName string, payload []byte) []byte
+
+	// Health reported in answer to health-check queries
+	healthProvider func() CoordinatorHealth
+
+	// Key count reported in answer to dbsize queries
+	dbSizeProvider func(store string) int64
+
 	// Synchronization
 	mu     sync.RWMutex
 	subsMu sync.RWMutex
 
 	// Metrics
-	metrics    MembershipMetrics
-	startTime  time.Time
-	eventCount int64
+	metrics       MembershipMetrics
+	startTime     time.Time
+	eventCount    int64
+	eventsDropped atomic.Int64
 }
 
 // NewGossipMembership creates a new gossip-based membership provider
@@ -45,6 +67,16 @@ func NewGossipMembership(config ClusterConfig) (*GossipMembership, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
+	advertise, err := ResolveAdvertiseAddress(config.AdvertiseAddress, config.AdvertiseInterface)
+	if err != nil {
+		if config.AdvertiseInterface != "" {
+			return nil, err
+		}
+		// Fall back to memberlist's own detection; peers still learn our address from gossip
+		logging.Warn(nil, logging.ComponentGossip, logging.ActionStart, "Could not auto-detect advertise address", map[string]interface{}{"error": err.Error()})
+	}
+	config.AdvertiseAddress = advertise
+
 	gm := &GossipMembership{
 		config:    config,
 		eventCh:   make(chan serf.Event, 256),
@@ -61,8 +93,9 @@ func NewGossipMembership(config ClusterConfig) (*GossipMembership, error) {
 		Metadata: map[string]string{
 			"cluster":      config.ClusterName,
 			"version":      "1.0.0",
-			"capabilities": "filters,persistence,resp",
+			"capabilities": strings.Join(localCapabilities, ","),
 			"http_port":    fmt.Sprintf("%d", config.HTTPPort),
+			"resp_port":    fmt.Sprintf("%d", config.RESPPort),
 		},
 		JoinedAt: time.Now(),
 		LastSeen: time.Now(),
@@ -91,8 +124,22 @@ func (gm *GossipMembership) Start(ctx context.Context) error {
 	// Configure event handling
 	conf.EventCh = gm.eventCh
 
-	// Configure gossip intervals
-	conf.MemberlistConfig.GossipInterval = time.Duration(gm.config.HeartbeatInterval) * time.Second
+	// Configure gossip intervals, scaled to the expected cluster size when bounds are set
+	if gm.config.Gossip.MinInterval > 0 {
+		expected := gm.config.Gossip.ExpectedNodes
+		if expected <= 0 {
+			expected = len(gm.config.SeedNodes) + 1
+		}
+		interval := gm.config.Gossip.apply(conf, expected)
+		metrics.Global().SetGauge("hypercache_gossip_interval_ms", interval.Milliseconds())
+		logging.Info(ctx, logging.ComponentGossip, logging.ActionStart, "Gossip interval tuned for cluster size", map[string]interface{}{
+			"expected_nodes":  expected,
+			"gossip_interval": interval.String(),
+			"probe_interval":  conf.MemberlistConfig.ProbeInterval.String(),
+		})
+	} else {
+		conf.MemberlistConfig.GossipInterval = time.Duration(gm.config.HeartbeatInterval) * time.Second
+	}
 
 	// Silence memberlist/serf internal debug logs — they bypass structured logging
 	// and flood stdout with unstructured [DEBUG] memberlist: lines
@@ -277,6 +324,7 @@ func (gm *GossipMembership) GetMetrics() MembershipMetrics {
 	metrics.TotalMembers = len(gm.members)
 	metrics.ClusterAge = time.Since(gm.startTime)
 	metrics.EventCount = gm.eventCount
+	metrics.EventsDropped = gm.eventsDropped.Load()
 
 	// Count members by status
 	for _, member := range gm.members {
@@ -442,11 +490,14 @@ func (gm *GossipMembership) notifySubscribers(event MembershipEvent) {
 	defer gm.subsMu.RUnlock()
 
 	for _, ch := range gm.memberSubs {
-		select {
-		case ch <- event:
-		default:
-			// Channel is full, skip this subscriber
-			logging.Warn(nil, logging.ComponentGossip, "channel_full", "Membership event channel full for subscriber")
+		if !sendWithTimeout(ch, event, gm.config.EventBus.DeliveryTimeout) {
+			// Channel stayed full past the delivery timeout; count the loss so it is observable
+			dropped := gm.eventsDropped.Add(1)
+			metrics.Global().IncCounter("hypercache_membership_events_dropped_total")
+			logging.Warn(nil, logging.ComponentGossip, "channel_full", "Membership event channel full for subscriber, event dropped", map[string]interface{}{
+				"node_id":       event.Member.NodeID,
+				"dropped_total": dropped,
+			})
 		}
 	}
 }
@@ -466,17 +517,134 @@ func (gm *GossipMembership) handleQuery(query *serf.Query) {
 	logging.Debug(nil, logging.ComponentGossip, "query_received", "Query received", map[string]interface{}{"query_name": query.Name})
 	// Queries can be used for cluster-wide operations
 
-	// Example: health check query
-	if query.Name == "health-check" {
-		response := map[string]interface{}{
-			"healthy":   gm.IsHealthy(),
-			"node_id":   gm.config.NodeID,
-			"timestamp": time.Now(),
+	switch query.Name {
+	case HealthCheckQuery:
+		payload, err := json.Marshal(gm.localHealth())
+		if err != nil {
+			logging.Error(nil, logging.ComponentGossip, "query_received", "Failed to encode health response", err, nil)
+			return
+		}
+		if err := query.Respond(payload); err != nil {
+			logging.Warn(nil, logging.ComponentGossip, "query_received", "Failed to answer health check", map[string]interface{}{"error": err.Error()})
 		}
 
-		// Send response (simplified - would normally serialize properly)
-		_ = query.Respond([]byte(fmt.Sprintf("%+v", response)))
+	case DBSizeQuery:
+		gm.mu.RLock()
+		provider := gm.dbSizeProvider
+		gm.mu.RUnlock()
+		if provider == nil {
+			return // No stores on this node; the asker sees a partial result
+		}
+		payload, err := json.Marshal(NodeDBSize{NodeID: gm.config.NodeID, Keys: provider(string(query.Payload))})
+		if err != nil {
+			logging.Error(nil, logging.ComponentGossip, "query_received", "Failed to encode dbsize response", err, nil)
+			return
+		}
+		if err := query.Respond(payload); err != nil {
+			logging.Warn(nil, logging.ComponentGossip, "query_received", "Failed to answer dbsize query", map[string]interface{}{"error": err.Error()})
+		}
+
+	default:
+		gm.mu.RLock()
+		handler := gm.queryHandler
+		gm.mu.RUnlock()
+		if handler == nil {
+			return
+		}
+		// Handlers may take a while (a cluster command runs before it is
+		// acknowledged), so they don't hold up the Serf event loop
+		go func() {
+			response := handler(query.Name, query.Payload)
+			if response == nil {
+				return
+			}
+			if err := query.Respond(response); err != nil {
+				logging.Warn(nil, logging.ComponentGossip, "query_received", "Failed to answer query", map[string]interface{}{
+					"query_name": query.Name,
+					"error":      err.Error(),
+				})
+			}
+		}()
+	}
+}
+
+// localHealth returns the health reported to health-check queries: the
+// registered provider's if set, otherwise the membership's own view
+func (gm *GossipMembership) localHealth() CoordinatorHealth {
+	gm.mu.RLock()
+	provider := gm.healthProvider
+	gm.mu.RUnlock()
+	if provider != nil {
+		return provider()
+	}
+
+	health := CoordinatorHealth{
+		Healthy:       gm.IsHealthy(),
+		LocalNodeID:   gm.config.NodeID,
+		ClusterSize:   len(gm.GetMembers()),
+		LastHeartbeat: time.Now(),
+		Uptime:        time.Since(gm.startTime),
+	}
+	if !health.Healthy {
+		health.Issues = []string{"membership provider unhealthy"}
 	}
+	return health
+}
+
+// SetHealthProvider sets the function whose result answers health-check queries
+func (gm *GossipMembership) SetHealthProvider(provider func() CoordinatorHealth) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.healthProvider = provider
+}
+
+// QueryHealth asks every member for its health and returns the responses by
+// node ID. Members that don't answer within timeout are absent from the map.
+func (gm *GossipMembership) QueryHealth(timeout time.Duration) (map[string]CoordinatorHealth, error) {
+	responses, err := gm.Query(HealthCheckQuery, nil, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	health := make(map[string]CoordinatorHealth, len(responses))
+	for _, response := range responses {
+		var h CoordinatorHealth
+		if err := json.Unmarshal(response, &h); err != nil {
+			logging.Warn(nil, logging.ComponentGossip, "deserialize", "Ignoring malformed health response", map[string]interface{}{"error": err.Error()})
+			continue
+		}
+		health[h.LocalNodeID] = h
+	}
+	return health, nil
+}
+
+// SetDBSizeProvider sets the function whose result answers dbsize queries:
+// the number of live keys in the named store on this node
+func (gm *GossipMembership) SetDBSizeProvider(provider func(store string) int64) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.dbSizeProvider = provider
+}
+
+// QueryDBSize asks every member for its key count in store and returns the
+// responses by node ID. Members that don't answer within timeout are absent
+// from the map.
+func (gm *GossipMembership) QueryDBSize(store string, timeout time.Duration) (map[string]int64, error) {
+	responses, err := gm.Query(DBSizeQuery, []byte(store), timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(responses))
+	for _, response := range responses {
+		var size NodeDBSize
+		if err := json.Unmarshal(response, &size); err != nil {
+			logging.Warn(nil, logging.ComponentGossip, "deserialize", "Ignoring malformed dbsize response", map[string]interface{}{"error": err.Error()})
+			continue
+		}
+		sizes[size.NodeID] = size.Keys
+	}
+	return sizes, nil
 }
 
 // SendUserEvent sends a custom event to the cluster
@@ -506,10 +674,21 @@ func (gm *GossipMembership) Query(name string, payload []byte, timeout time.Dura
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 
-	// Collect responses
+	// Collect responses until every alive member has answered or the query times out
+	expected := 0
+	for _, member := range gm.serf.Members() {
+		if member.Status == serf.StatusAlive {
+			expected++
+		}
+	}
+
 	var responses [][]byte
 	for response := range queryResult.ResponseCh() {
 		responses = append(responses, response.Payload)
+		if len(responses) >= expected {
+			queryResult.Close()
+			break
+		}
 	}
 
 	return responses, nil
@@ -539,6 +718,14 @@ func (gm *GossipMembership) GetAliveNodes() []ClusterMember {
 	return aliveMembers
 }
 
+// SetQueryHandler sets the function answering queries other than
+// health-check and dbsize
+func (gm *GossipMembership) SetQueryHandler(handler func(queryName string, payload []byte) []byte) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.queryHandler = handler
+}
+
 // SetUserEventHandler sets a handler function for user events
 func (gm *GossipMembership) SetUserEventHandler(handler func(eventName string, payload []byte)) {
 	gm.mu.Lock()
@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"math"
+	"time"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+// GossipConfig bounds the adaptive gossip interval. Gossip is fastest for
+// small clusters, where each round is cheap, and backs off logarithmically
+// as the cluster grows since dissemination already takes O(log n) rounds.
+type GossipConfig struct {
+	MinInterval   time.Duration `yaml:"min_interval" json:"min_interval"`     // Interval for clusters of up to 2 nodes
+	MaxInterval   time.Duration `yaml:"max_interval" json:"max_interval"`     // Upper bound for large clusters
+	ExpectedNodes int           `yaml:"expected_nodes" json:"expected_nodes"` // Cluster size to tune for (0 = seed count + 1)
+}
+
+// DefaultGossipConfig returns the default gossip bounds
+func DefaultGossipConfig() GossipConfig {
+	return GossipConfig{
+		MinInterval: 200 * time.Millisecond, // memberlist's LAN default
+		MaxInterval: 2 * time.Second,
+	}
+}
+
+// probeIntervalFactor keeps failure probes at memberlist's default ratio to gossip
+const probeIntervalFactor = 5
+
+// GossipInterval returns the gossip interval for a cluster of n members:
+// MinInterval scaled by ceil(log2(n)), clamped to [MinInterval, MaxInterval].
+func (c GossipConfig) GossipInterval(n int) time.Duration {
+	interval := c.MinInterval
+	if n > 2 {
+		interval = c.MinInterval * time.Duration(math.Ceil(math.Log2(float64(n))))
+	}
+	if interval > c.MaxInterval {
+		interval = c.MaxInterval
+	}
+	return interval
+}
+
+// apply tunes memberlist for a cluster of n members. Memberlist starts its
+// gossip and probe timers once when created, so the interval is chosen at
+// startup from the cluster the node expects to join.
+func (c GossipConfig) apply(conf *serf.Config, n int) time.Duration {
+	interval := c.GossipInterval(n)
+	ml := conf.MemberlistConfig
+	ml.GossipInterval = interval
+	ml.ProbeInterval = interval * probeIntervalFactor
+	// Dead nodes must keep being gossiped about for a few probe rounds
+	if dead := ml.ProbeInterval * 6; dead > ml.GossipToTheDeadTime {
+		ml.GossipToTheDeadTime = dead
+	}
+	return interval
+}
@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/serf/serf"
+)
+
+func TestGossipConfig_GossipInterval(t *testing.T) {
+	config := DefaultGossipConfig()
+
+	if got := config.GossipInterval(1); got != config.MinInterval {
+		t.Errorf("Expected %v for a single node, got %v", config.MinInterval, got)
+	}
+	if got := config.GossipInterval(2); got != config.MinInterval {
+		t.Errorf("Expected %v for 2 nodes, got %v", config.MinInterval, got)
+	}
+
+	small := config.GossipInterval(3)
+	medium := config.GossipInterval(16)
+	large := config.GossipInterval(1000)
+	if !(small < medium && medium < large) {
+		t.Errorf("Expected interval to grow with cluster size: 3=%v 16=%v 1000=%v", small, medium, large)
+	}
+	if medium != 4*config.MinInterval {
+		t.Errorf("Expected 16 nodes to gossip every %v, got %v", 4*config.MinInterval, medium)
+	}
+	if got := config.GossipInterval(1 << 20); got != config.MaxInterval {
+		t.Errorf("Expected interval capped at %v, got %v", config.MaxInterval, got)
+	}
+}
+
+func TestGossipConfig_Apply(t *testing.T) {
+	config := GossipConfig{MinInterval: 100 * time.Millisecond, MaxInterval: time.Second}
+	conf := serf.DefaultConfig()
+
+	interval := config.apply(conf, 8)
+	if interval != 300*time.Millisecond || conf.MemberlistConfig.GossipInterval != interval {
+		t.Errorf("Expected 300ms gossip interval for 8 nodes, got %v", conf.MemberlistConfig.GossipInterval)
+	}
+	if conf.MemberlistConfig.ProbeInterval != interval*probeIntervalFactor {
+		t.Errorf("Expected probe interval %v, got %v", interval*probeIntervalFactor, conf.MemberlistConfig.ProbeInterval)
+	}
+}
+
+func TestValidateConfig_GossipBounds(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "node-1"
+	config.Gossip.MaxInterval = config.Gossip.MinInterval / 2
+
+	if err := ValidateConfig(config); err == nil {
+		t.Error("Expected max_interval below min_interval to be rejected")
+	}
+}
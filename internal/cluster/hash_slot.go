@@ -0,0 +1,59 @@
+package cluster
+
+import "strings"
+
+// HashSlotCount is the number of Redis Cluster compatible hash slots
+const HashSlotCount = 16384
+
+// GetHashSlot returns the Redis Cluster hash slot for a key (CRC16 mod 16384).
+// If the key contains a non-empty {hashtag}, only the tag is hashed so that
+// related keys such as {user1}:a and {user1}:b land in the same slot.
+func GetHashSlot(key string) uint16 {
+	return crc16(hashTag(key)) % HashSlotCount
+}
+
+// SameSlot reports whether all keys hash to the same slot and returns that slot.
+// An empty key list trivially shares slot 0.
+func SameSlot(keys ...string) (uint16, bool) {
+	if len(keys) == 0 {
+		return 0, true
+	}
+
+	slot := GetHashSlot(keys[0])
+	for _, key := range keys[1:] {
+		if GetHashSlot(key) != slot {
+			return slot, false
+		}
+	}
+	return slot, true
+}
+
+// hashTag extracts the portion of the key between the first '{' and the next
+// '}'. The whole key is used when there is no tag or the tag is empty.
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+// crc16 implements CRC16-CCITT (XMODEM), the variant used by Redis Cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,39 @@
+package cluster
+
+import "testing"
+
+func TestGetHashSlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		slot uint16
+	}{
+		{"123456789", 12739}, // CRC16-XMODEM check value 0x31C3
+		{"foo", 12182},
+		{"{user1000}.following", GetHashSlot("user1000")},
+		{"foo{}{bar}", GetHashSlot("foo{}{bar}")}, // empty tag hashes the whole key
+	}
+
+	for _, tt := range tests {
+		if got := GetHashSlot(tt.key); got != tt.slot {
+			t.Errorf("GetHashSlot(%q) = %d, want %d", tt.key, got, tt.slot)
+		}
+	}
+}
+
+func TestSameSlot(t *testing.T) {
+	slot, ok := SameSlot("{user1}:a", "{user1}:b")
+	if !ok {
+		t.Error("Expected {user1}:a and {user1}:b to share a slot")
+	}
+	if slot != GetHashSlot("user1") {
+		t.Errorf("Expected slot %d, got %d", GetHashSlot("user1"), slot)
+	}
+
+	if _, ok := SameSlot("a", "b"); ok {
+		t.Error("Expected a and b to hash to different slots")
+	}
+
+	if _, ok := SameSlot("single"); !ok {
+		t.Error("A single key should always share its own slot")
+	}
+}
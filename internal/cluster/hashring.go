@@ -50,7 +50,8 @@ type Node struct {
 	Load     float64 // Current load metric (0.0 - 1.0)
 	LastSeen time.Time
 
-	// Node capabilities
+	// Node capabilities, as advertised in gossip metadata
+	Capabilities        []string
 	SupportsFilters     bool
 	SupportsCompression bool
 	SupportsTLS         bool
@@ -97,6 +98,10 @@ type HashRing struct {
 	cacheKeys   []string            // LRU cache keys
 	cacheIndex  int                 // Current cache position for LRU
 
+	// Slots moved by MigrateSlotRange, owned by the given node rather
+	// than by ring position
+	slotOwners map[uint16]string
+
 	// Thread safety
 	mu sync.RWMutex
 
@@ -114,6 +119,7 @@ func NewHashRing(config HashRingConfig) *HashRing {
 		config:      config,
 		lookupCache: make(map[string][]string),
 		cacheKeys:   make([]string, config.LookupCacheSize),
+		slotOwners:  make(map[uint16]string),
 	}
 }
 
@@ -150,9 +156,10 @@ func (ring *HashRing) AddNode(nodeID, address string, port int) error {
 		Load:     0.0,
 		LastSeen: time.Now(),
 
-		// Default capabilities
+		// Default capabilities until the node's advertised set is known;
+		// compression is opt-in so older peers never receive gzip payloads
 		SupportsFilters:     true,
-		SupportsCompression: true,
+		SupportsCompression: false,
 		SupportsTLS:         false,
 	}
 
@@ -171,9 +178,7 @@ func (ring *HashRing) AddNode(nodeID, address string, port int) error {
 
 	// Insert virtual nodes and maintain sorted order
 	ring.vnodes = append(ring.vnodes, newVNodes...)
-	sort.Slice(ring.vnodes, func(i, j int) bool {
-		return ring.vnodes[i].Hash < ring.vnodes[j].Hash
-	})
+	sortVNodes(ring.vnodes)
 
 	// Clear lookup cache (ring topology changed)
 	ring.clearLookupCache()
@@ -181,6 +186,22 @@ func (ring *HashRing) AddNode(nodeID, address string, port int) error {
 	return nil
 }
 
+// sortVNodes orders virtual nodes by hash. Colliding hashes are ordered
+// by node ID, then vnode index, so which node owns a key depends only on
+// the set of nodes, not on the order they joined in.
+func sortVNodes(vnodes []VirtualNode) {
+	sort.Slice(vnodes, func(i, j int) bool {
+		a, b := vnodes[i], vnodes[j]
+		if a.Hash != b.Hash {
+			return a.Hash < b.Hash
+		}
+		if a.NodeID != b.NodeID {
+			return a.NodeID < b.NodeID
+		}
+		return a.VNodeID < b.VNodeID
+	})
+}
+
 // RemoveNode removes a physical node from the ring
 func (ring *HashRing) RemoveNode(nodeID string) error {
 	ring.mu.Lock()
@@ -203,6 +224,13 @@ func (ring *HashRing) RemoveNode(nodeID string) error {
 	}
 	ring.vnodes = filteredVNodes
 
+	// Its slots fall back to their ring position
+	for slot, owner := range ring.slotOwners {
+		if owner == nodeID {
+			delete(ring.slotOwners, slot)
+		}
+	}
+
 	// Clear lookup cache
 	ring.clearLookupCache()
 
@@ -255,8 +283,17 @@ func (ring *HashRing) GetReplicas(key string, count int) []string {
 	return replicas[:count]
 }
 
-// computeReplicas computes replica nodes without holding locks
+// computeReplicas computes replica nodes without holding locks. Keys are
+// placed by hash slot, so every key of a slot shares its nodes: clients
+// told MOVED for a slot can send all of its keys to the same node, and
+// multi-key commands on keys sharing a {hashtag} stay on one node.
 func (ring *HashRing) computeReplicas(key string, count int) []string {
+	return ring.SlotReplicas(GetHashSlot(key), count)
+}
+
+// SlotReplicas returns the ordered list of nodes holding a hash slot's
+// keys, its owner first
+func (ring *HashRing) SlotReplicas(slot uint16, count int) []string {
 	ring.mu.RLock()
 	defer ring.mu.RUnlock()
 
@@ -264,12 +301,14 @@ func (ring *HashRing) computeReplicas(key string, count int) []string {
 		return nil
 	}
 
-	// Hash the key to find position on ring
-	keyHash := ring.hashFunction([]byte(key))
+	// The slot, not the key, is hashed to find its position on the ring
+	var slotBytes [2]byte
+	binary.BigEndian.PutUint16(slotBytes[:], slot)
+	slotHash := ring.hashFunction(slotBytes[:])
 
 	// Find starting position using binary search
 	startIdx := sort.Search(len(ring.vnodes), func(i int) bool {
-		return ring.vnodes[i].Hash >= keyHash
+		return ring.vnodes[i].Hash >= slotHash
 	})
 
 	// Wrap around if necessary
@@ -277,9 +316,16 @@ func (ring *HashRing) computeReplicas(key string, count int) []string {
 		startIdx = 0
 	}
 
-	// Collect unique physical nodes
+	// Collect unique physical nodes, starting with the owner the slot was
+	// assigned to, if any
 	seen := make(map[string]bool)
 	replicas := make([]string, 0, count)
+	if owner, ok := ring.slotOwners[slot]; ok {
+		if node, exists := ring.nodes[owner]; exists && node.Status == NodeAlive {
+			seen[owner] = true
+			replicas = append(replicas, owner)
+		}
+	}
 
 	for i := 0; i < len(ring.vnodes) && len(replicas) < count; i++ {
 		idx := (startIdx + i) % len(ring.vnodes)
@@ -336,6 +382,7 @@ func (ring *HashRing) GetNodes() map[string]*Node {
 			Load:     node.Load,
 			LastSeen: node.LastSeen,
 
+			Capabilities:        append([]string(nil), node.Capabilities...),
 			SupportsFilters:     node.SupportsFilters,
 			SupportsCompression: node.SupportsCompression,
 			SupportsTLS:         node.SupportsTLS,
@@ -345,6 +392,36 @@ func (ring *HashRing) GetNodes() map[string]*Node {
 	return nodesCopy
 }
 
+// AssignSlots makes nodeID the owner of the hash slots start to end,
+// inclusive, in place of their ring position, e.g. once MigrateSlotRange
+// has moved their keys. Assignments are local to this ring and dropped when
+// the node is removed.
+func (ring *HashRing) AssignSlots(start, end uint16, nodeID string) error {
+	if start > end || end >= HashSlotCount {
+		return fmt.Errorf("invalid slot range %d-%d", start, end)
+	}
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	if _, exists := ring.nodes[nodeID]; !exists {
+		return fmt.Errorf("node %s does not exist", nodeID)
+	}
+	for slot := int(start); slot <= int(end); slot++ {
+		ring.slotOwners[uint16(slot)] = nodeID
+	}
+	ring.clearLookupCache()
+	return nil
+}
+
+// SlotOwner returns the node a hash slot was assigned to with AssignSlots;
+// ok is false if the slot follows the ring
+func (ring *HashRing) SlotOwner(slot uint16) (nodeID string, ok bool) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	nodeID, ok = ring.slotOwners[slot]
+	return nodeID, ok
+}
+
 // SetNodeStatus updates the status of a node
 func (ring *HashRing) SetNodeStatus(nodeID string, status NodeStatus) error {
 	ring.mu.Lock()
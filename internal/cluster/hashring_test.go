@@ -3,6 +3,7 @@ package cluster
 import (
 	"fmt"
 	"math"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -628,3 +629,156 @@ func ExampleHashRing() {
 	fmt.Printf("Distribution stats: avg=%.1f, load_factor=%.2f\n",
 		stats.AvgLoad, stats.LoadFactor)
 }
+
+func TestHashRing_NodesWithCapability(t *testing.T) {
+	ring := NewHashRing(DefaultHashRingConfig())
+	for _, nodeID := range []string{"node-1", "node-2", "node-3"} {
+		if err := ring.AddNode(nodeID, "127.0.0.1", 7946); err != nil {
+			t.Fatalf("AddNode(%s) failed: %v", nodeID, err)
+		}
+	}
+
+	if nodes := ring.NodesWithCapability(CapabilityCompression); len(nodes) != 0 {
+		t.Errorf("Expected no compression support before negotiation, got %v", nodes)
+	}
+
+	_ = ring.SetNodeCapabilities("node-1", ParseCapabilities("filters, resp,compression"))
+	_ = ring.SetNodeCapabilities("node-3", ParseCapabilities("compression,tls"))
+	_ = ring.SetNodeCapabilities("node-2", ParseCapabilities("filters"))
+
+	if nodes := ring.NodesWithCapability(CapabilityCompression); len(nodes) != 2 || nodes[0] != "node-1" || nodes[1] != "node-3" {
+		t.Errorf("Expected [node-1 node-3] to support compression, got %v", nodes)
+	}
+	if nodes := ring.NodesWithCapability(CapabilityTLS); len(nodes) != 1 || nodes[0] != "node-3" {
+		t.Errorf("Expected [node-3] to support TLS, got %v", nodes)
+	}
+
+	node := ring.GetNodes()["node-2"]
+	if !node.SupportsFilters || node.SupportsCompression || node.SupportsTLS {
+		t.Errorf("Expected node-2 flags to match its advertised capabilities, got %+v", node)
+	}
+
+	if err := ring.SetNodeCapabilities("missing", nil); err == nil {
+		t.Error("Expected an error for an unknown node")
+	}
+}
+
+func TestHashRing_OwnershipIndependentOfJoinOrder(t *testing.T) {
+	orders := [][]string{
+		{"node-a", "node-b", "node-c"},
+		{"node-c", "node-a", "node-b"},
+		{"node-b", "node-c", "node-a"},
+	}
+
+	var want map[string][]string
+	for _, order := range orders {
+		ring := NewHashRing(DefaultHashRingConfig())
+		for _, nodeID := range order {
+			if err := ring.AddNode(nodeID, "127.0.0.1", 7946); err != nil {
+				t.Fatalf("AddNode(%s) failed: %v", nodeID, err)
+			}
+		}
+		// A node leaving and rejoining doesn't move anything either
+		_ = ring.RemoveNode(order[0])
+		_ = ring.AddNode(order[0], "127.0.0.1", 7946)
+
+		owners := make(map[string][]string)
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			owners[key] = ring.GetReplicas(key, 3)
+		}
+		if want == nil {
+			want = owners
+			continue
+		}
+		if !reflect.DeepEqual(owners, want) {
+			t.Errorf("Join order %v changed key ownership", order)
+		}
+	}
+}
+
+func TestSortVNodes_CollisionsOrderedByNode(t *testing.T) {
+	// Colliding hashes, in two different arrival orders
+	first := []VirtualNode{
+		{Hash: 7, NodeID: "node-b", VNodeID: 1},
+		{Hash: 3, NodeID: "node-c", VNodeID: 0},
+		{Hash: 7, NodeID: "node-a", VNodeID: 4},
+		{Hash: 7, NodeID: "node-a", VNodeID: 2},
+	}
+	second := []VirtualNode{first[3], first[0], first[2], first[1]}
+
+	sortVNodes(first)
+	sortVNodes(second)
+
+	want := []VirtualNode{
+		{Hash: 3, NodeID: "node-c", VNodeID: 0},
+		{Hash: 7, NodeID: "node-a", VNodeID: 2},
+		{Hash: 7, NodeID: "node-a", VNodeID: 4},
+		{Hash: 7, NodeID: "node-b", VNodeID: 1},
+	}
+	if !reflect.DeepEqual(first, want) || !reflect.DeepEqual(second, want) {
+		t.Errorf("Expected %v for both orders, got %v and %v", want, first, second)
+	}
+}
+
+func TestHashRing_HashTagsColocate(t *testing.T) {
+	ring := NewHashRing(DefaultHashRingConfig())
+	for _, nodeID := range []string{"node-1", "node-2", "node-3", "node-4"} {
+		if err := ring.AddNode(nodeID, "127.0.0.1", 7946); err != nil {
+			t.Fatalf("AddNode(%s) failed: %v", nodeID, err)
+		}
+	}
+
+	split := 0
+	for i := 0; i < 100; i++ {
+		profile := fmt.Sprintf("{user:%d}:profile", i)
+		prefs := fmt.Sprintf("{user:%d}:prefs", i)
+		if got, want := ring.GetReplicas(profile, 3), ring.GetReplicas(prefs, 3); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s and %s routed apart: %v vs %v", profile, prefs, got, want)
+		}
+		if ring.GetNode(profile) != ring.GetNode(fmt.Sprintf("user:%d", i)) {
+			t.Errorf("%s not routed by its tag", profile)
+		}
+		if ring.GetNode(profile[1:]) != ring.GetNode(prefs[1:]) {
+			split++
+		}
+	}
+	// Without the braces the same keys spread across nodes
+	if split == 0 {
+		t.Error("Expected untagged keys to spread across nodes")
+	}
+}
+
+func TestHashRing_KeysPlacedBySlot(t *testing.T) {
+	ring := NewHashRing(DefaultHashRingConfig())
+	for _, nodeID := range []string{"node-1", "node-2", "node-3"} {
+		if err := ring.AddNode(nodeID, "127.0.0.1", 7946); err != nil {
+			t.Fatalf("AddNode(%s) failed: %v", nodeID, err)
+		}
+	}
+
+	// Every key is held by its slot's nodes, so keys sharing a slot, as
+	// a MOVED redirect assumes, share their owner and replicas
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		if got, want := ring.GetReplicas(key, 2), ring.SlotReplicas(GetHashSlot(key), 2); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s placed on %v, its slot on %v", key, got, want)
+		}
+	}
+
+	// An assigned slot moves with all of its keys
+	slot := GetHashSlot("key:1")
+	target := "node-1"
+	if ring.GetNode("key:1") == target {
+		target = "node-2"
+	}
+	if err := ring.AssignSlots(slot, slot, target); err != nil {
+		t.Fatalf("AssignSlots failed: %v", err)
+	}
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		if GetHashSlot(key) == slot && ring.GetNode(key) != target {
+			t.Errorf("%s in assigned slot %d routed to %s, expected %s", key, slot, ring.GetNode(key), target)
+		}
+	}
+}
@@ -17,7 +17,10 @@ type ClusterConfig struct {
 	BindAddress      string `yaml:"bind_address" json:"bind_address"`
 	BindPort         int    `yaml:"bind_port" json:"bind_port"`
 	AdvertiseAddress string `yaml:"advertise_address" json:"advertise_address"`
-	HTTPPort         int    `yaml:"http_port" json:"http_port"` // Shared via gossip for inter-node read-repair
+	// Interface to auto-detect the advertise address from when it is empty (empty = first usable)
+	AdvertiseInterface string `yaml:"advertise_interface" json:"advertise_interface"`
+	HTTPPort           int    `yaml:"http_port" json:"http_port"` // Shared via gossip for inter-node read-repair
+	RESPPort           int    `yaml:"resp_port" json:"resp_port"` // Shared via gossip for MOVED redirects
 
 	// Seed nodes for bootstrap
 	SeedNodes []string `yaml:"seed_nodes" json:"seed_nodes"`
@@ -30,6 +33,15 @@ type ClusterConfig struct {
 	HeartbeatInterval       int `yaml:"heartbeat_interval_seconds" json:"heartbeat_interval_seconds"`
 	FailureDetectionTimeout int `yaml:"failure_detection_timeout_seconds" json:"failure_detection_timeout_seconds"`
 
+	// Adaptive gossip interval bounds (zero MinInterval = fixed HeartbeatInterval)
+	Gossip GossipConfig `yaml:"gossip" json:"gossip"`
+
+	// Event bus subscriber delivery (buffering and backpressure)
+	EventBus EventBusConfig `yaml:"event_bus" json:"event_bus"`
+
+	// Circuit breaker guarding replication event publishing
+	ReplicationBreaker CircuitBreakerConfig `yaml:"replication_breaker" json:"replication_breaker"`
+
 	// Consensus configuration (for when we add Raft)
 	ConsensusEnabled  bool   `yaml:"consensus_enabled" json:"consensus_enabled"`
 	DataDirectory     string `yaml:"data_directory" json:"data_directory"`
@@ -54,6 +66,10 @@ func DefaultClusterConfig() ClusterConfig {
 		HeartbeatInterval:       5,
 		FailureDetectionTimeout: 30,
 
+		Gossip:             DefaultGossipConfig(),
+		EventBus:           DefaultEventBusConfig(),
+		ReplicationBreaker: DefaultCircuitBreakerConfig(),
+
 		ConsensusEnabled:  false, // Start simple
 		DataDirectory:     "./data",
 		SnapshotThreshold: 1000,
@@ -110,6 +126,10 @@ const (
 	EventConsensusLost      ClusterEventType = "consensus_lost"
 	EventConsensusRestored  ClusterEventType = "consensus_restored"
 	EventDataOperation      ClusterEventType = "data_operation"
+
+	EventDecommissionStarted   ClusterEventType = "decommission_started"
+	EventDecommissionProgress  ClusterEventType = "decommission_progress"
+	EventDecommissionCompleted ClusterEventType = "decommission_completed"
 )
 
 // MembershipProvider defines the interface for cluster membership management
@@ -151,6 +171,7 @@ type MembershipMetrics struct {
 	ClusterAge       time.Duration `json:"cluster_age"`
 	LastEvent        time.Time     `json:"last_event"`
 	EventCount       int64         `json:"event_count"`
+	EventsDropped    int64         `json:"events_dropped"` // Membership events dropped on full subscriber channels
 }
 
 // RoutingProvider defines the interface for key routing and data placement
@@ -199,6 +220,24 @@ type EventBusMetrics struct {
 	ActiveSubscribers int           `json:"active_subscribers"`
 	LastEventTime     time.Time     `json:"last_event_time"`
 	AverageLatency    time.Duration `json:"average_latency"`
+
+	// Events that could not be delivered because a subscriber's channel stayed full
+	EventsDropped   int64         `json:"events_dropped"`
+	SubscriberDrops map[int]int64 `json:"subscriber_drops,omitempty"` // Drops per active subscriber ID
+}
+
+// EventBusConfig controls how events are delivered to local subscribers
+type EventBusConfig struct {
+	SubscriberBuffer int           `yaml:"subscriber_buffer" json:"subscriber_buffer"` // Channel capacity per subscriber
+	DeliveryTimeout  time.Duration `yaml:"delivery_timeout" json:"delivery_timeout"`   // Max wait on a full subscriber before dropping (0 = drop immediately)
+}
+
+// DefaultEventBusConfig returns the default subscriber delivery settings
+func DefaultEventBusConfig() EventBusConfig {
+	return EventBusConfig{
+		SubscriberBuffer: 1000,
+		DeliveryTimeout:  10 * time.Millisecond,
+	}
 }
 
 // CoordinatorService manages the overall cluster coordination
@@ -248,6 +287,20 @@ type CoordinatorHealth struct {
 	Issues           []string      `json:"issues,omitempty"`
 }
 
+// NodeDBSize is one member's answer to a dbsize query
+type NodeDBSize struct {
+	NodeID string `json:"node_id"`
+	Keys   int64  `json:"keys"`
+}
+
+// ClusterDBSize is the cluster-wide key count of one store
+type ClusterDBSize struct {
+	Total   int64            `json:"total"`   // Sum of the answering nodes' key counts
+	Nodes   map[string]int64 `json:"nodes"`   // Key count by node ID
+	Members int              `json:"members"` // Alive members asked
+	Partial bool             `json:"partial"` // Some members didn't answer
+}
+
 // CoordinatorMetrics provides comprehensive coordinator statistics
 type CoordinatorMetrics struct {
 	Membership MembershipMetrics `json:"membership"`
@@ -305,6 +358,8 @@ type MigrationProgress struct {
 	StartTime        time.Time     `json:"start_time"`
 	EstimatedETA     time.Duration `json:"estimated_eta"`
 	CurrentPhase     string        `json:"current_phase"`
+	CompletedSlots   int           `json:"completed_slots,omitempty"` // slot range migrations only
+	LastSlot         int           `json:"last_slot"`                 // last slot fully migrated, -1 if none
 }
 
 // MigrationMetrics provides statistics about data migrations
@@ -358,6 +413,10 @@ func ValidateConfig(config ClusterConfig) error {
 		return fmt.Errorf("failure_detection_timeout must be greater than heartbeat_interval: %w", ErrInvalidConfiguration)
 	}
 
+	if config.Gossip.MinInterval < 0 || config.Gossip.MaxInterval < config.Gossip.MinInterval {
+		return fmt.Errorf("gossip max_interval must be at least min_interval: %w", ErrInvalidConfiguration)
+	}
+
 	return nil
 }
 
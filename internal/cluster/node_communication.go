@@ -2,13 +2,19 @@ package cluster
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
 	"sync"
 	"time"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
 )
 
 // NodeCommunicator handles direct communication between nodes
@@ -16,6 +22,7 @@ type NodeCommunicator struct {
 	localNodeID string
 	membership  MembershipProvider
 	httpClient  *http.Client
+	pool        *ConnPool
 
 	// Request/response tracking
 	pendingRequests map[string]chan *NodeResponse
@@ -65,14 +72,51 @@ const (
 
 // NewNodeCommunicator creates a new node communicator
 func NewNodeCommunicator(localNodeID string, membership MembershipProvider) *NodeCommunicator {
+	pool := NewConnPool(0, 0)
 	return &NodeCommunicator{
 		localNodeID:     localNodeID,
 		membership:      membership,
-		httpClient:      &http.Client{Timeout: time.Second * 30},
+		httpClient:      &http.Client{Timeout: time.Second * 30, Transport: pool},
+		pool:            pool,
 		pendingRequests: make(map[string]chan *NodeResponse),
 	}
 }
 
+// WatchMembership evicts the pooled connections to nodes that leave or
+// fail, until ctx is done
+func (nc *NodeCommunicator) WatchMembership(ctx context.Context) {
+	events := nc.membership.Subscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == MemberLeft || event.Type == MemberFailed {
+				nc.EvictNode(&event.Member)
+			}
+		}
+	}
+}
+
+// EvictNode closes the pooled connections to a node
+func (nc *NodeCommunicator) EvictNode(member *ClusterMember) {
+	nc.pool.Evict(nodeHTTPAddress(member))
+	nc.pool.Evict(net.JoinHostPort(member.Address, fmt.Sprintf("%d", member.Port+1000))) // cluster/request API port
+}
+
+// nodeHTTPAddress returns the host:port of a node's HTTP API, from its
+// http_port metadata or else the gossip port + 1000
+func nodeHTTPAddress(member *ClusterMember) string {
+	httpPort := member.Metadata["http_port"]
+	if httpPort == "" || httpPort == "0" {
+		httpPort = fmt.Sprintf("%d", member.Port+1000)
+	}
+	return net.JoinHostPort(member.Address, httpPort)
+}
+
 // SendRequest sends a request to another node
 func (nc *NodeCommunicator) SendRequest(ctx context.Context, toNodeID string, reqType RequestType, payload interface{}) (*NodeResponse, error) {
 	// Generate request ID
@@ -130,6 +174,7 @@ func (nc *NodeCommunicator) sendHTTPRequest(ctx context.Context, target *Cluster
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-HyperCache-Node-ID", nc.localNodeID)
+	setCorrelationHeader(httpReq)
 
 	// Send request
 	httpResp, err := nc.httpClient.Do(httpReq)
@@ -431,6 +476,8 @@ func (nc *NodeCommunicator) GetMetrics() map[string]int64 {
 		"requests_sent":      nc.requestCount,
 		"responses_received": nc.responseCount,
 		"errors":             nc.errorCount,
+		"pool_dials":         nc.pool.Dials(),
+		"pool_evictions":     nc.pool.Evictions(),
 	}
 }
 
@@ -444,10 +491,27 @@ func (nc *NodeCommunicator) Close() {
 		close(ch)
 	}
 	nc.pendingRequests = make(map[string]chan *NodeResponse)
+	nc.pool.Close()
+}
+
+// NodeRESPAddress returns the RESP address a node advertised over gossip,
+// or "" if the node is unknown or didn't advertise one
+func (nc *NodeCommunicator) NodeRESPAddress(nodeID string) string {
+	member, exists := nc.membership.GetMember(nodeID)
+	if !exists {
+		return ""
+	}
+	respPort := member.Metadata["resp_port"]
+	if respPort == "" || respPort == "0" {
+		return ""
+	}
+	return net.JoinHostPort(member.Address, respPort)
 }
 
 // ReplicateEntry sends a key-value pair directly to a node via HTTP POST /internal/replicate.
 // This is used for hash-ring targeted replication (not gossip broadcast).
+// The write is stamped with the current time, from which the node measures
+// its replication lag.
 func (nc *NodeCommunicator) ReplicateEntry(ctx context.Context, nodeID string, key string, value interface{}, ttlSeconds float64, lamportTS uint64) error {
 	member, exists := nc.membership.GetMember(nodeID)
 	if !exists {
@@ -469,6 +533,7 @@ func (nc *NodeCommunicator) ReplicateEntry(ctx context.Context, nodeID string, k
 		"ttl":        ttlSeconds,
 		"lamport_ts": lamportTS,
 		"from_node":  nc.localNodeID,
+		"written_at": time.Now().UnixNano(),
 	}
 
 	data, err := json.Marshal(payload)
@@ -476,13 +541,27 @@ func (nc *NodeCommunicator) ReplicateEntry(ctx context.Context, nodeID string, k
 		return fmt.Errorf("failed to marshal replication payload: %w", err)
 	}
 
+	// Compress large payloads, but only for peers that advertise they can decode them
+	compressed := false
+	if len(data) >= replicationCompressMinBytes && memberHasCapability(member, CapabilityCompression) {
+		if data, err = gzipBytes(data); err != nil {
+			return fmt.Errorf("failed to compress replication payload: %w", err)
+		}
+		compressed = true
+		metrics.Global().IncCounter("hypercache_replication_compressed_total")
+	}
+
 	url := fmt.Sprintf("http://%s:%s/internal/replicate", member.Address, httpPort)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	req.Header.Set("X-HyperCache-Node-ID", nc.localNodeID)
+	setCorrelationHeader(req)
 
 	resp, err := nc.httpClient.Do(req)
 	if err != nil {
@@ -497,6 +576,96 @@ func (nc *NodeCommunicator) ReplicateEntry(ctx context.Context, nodeID string, k
 	return nil
 }
 
+// MigrationEntry is one key sent by ReplicateBatch. The value is sent in
+// the source store's encoding with its type, so lists, sets, numbers and
+// JSON documents arrive as they were stored.
+type MigrationEntry struct {
+	Store     string  `json:"store,omitempty"` // "" is the default store
+	Key       string  `json:"key"`
+	Value     []byte  `json:"value"`
+	ValueType string  `json:"value_type"`
+	SessionID string  `json:"session_id,omitempty"`
+	TTL       float64 `json:"ttl"` // seconds, 0 = none
+	LamportTS uint64  `json:"lamport_ts"`
+	Version   uint64  `json:"-"` // the source's version of the key, not sent
+}
+
+// ReplicateBatch sends many keys to a node in one request, via HTTP POST
+// /internal/replicate-batch, for bulk migration. The node applies each
+// entry as /internal/replicate does and replies with how many it applied;
+// anything less than all of them is an error.
+func (nc *NodeCommunicator) ReplicateBatch(ctx context.Context, nodeID string, entries []MigrationEntry) error {
+	member, exists := nc.membership.GetMember(nodeID)
+	if !exists {
+		return fmt.Errorf("node %s not found in cluster", nodeID)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"entries":   entries,
+		"from_node": nc.localNodeID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal migration batch: %w", err)
+	}
+	compressed := false
+	if len(data) >= replicationCompressMinBytes && memberHasCapability(member, CapabilityCompression) {
+		if data, err = gzipBytes(data); err != nil {
+			return fmt.Errorf("failed to compress migration batch: %w", err)
+		}
+		compressed = true
+	}
+
+	url := fmt.Sprintf("http://%s/internal/replicate-batch", nodeHTTPAddress(member))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("X-HyperCache-Node-ID", nc.localNodeID)
+	setCorrelationHeader(req)
+
+	resp, err := nc.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("migration batch to %s failed: %w", nodeID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("migration batch to %s returned %d: %s", nodeID, resp.StatusCode, string(body))
+	}
+	var result struct {
+		Applied int `json:"applied"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode migration batch response: %w", err)
+	}
+	if result.Applied != len(entries) {
+		return fmt.Errorf("%s applied %d of %d migrated keys", nodeID, result.Applied, len(entries))
+	}
+	return nil
+}
+
+// replicationCompressMinBytes is the payload size from which replication is
+// gzip-compressed; smaller payloads don't shrink enough to be worth the CPU
+const replicationCompressMinBytes = 1024
+
+// gzipBytes compresses data with gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // ProxyGet forwards a GET request to the owner node and returns the raw value.
 func (nc *NodeCommunicator) ProxyGet(ctx context.Context, nodeID string, key string) (interface{}, bool, error) {
 	member, exists := nc.membership.GetMember(nodeID)
@@ -518,6 +687,7 @@ func (nc *NodeCommunicator) ProxyGet(ctx context.Context, nodeID string, key str
 		return nil, false, err
 	}
 	req.Header.Set("X-HyperCache-Node-ID", nc.localNodeID)
+	setCorrelationHeader(req)
 
 	resp, err := nc.httpClient.Do(req)
 	if err != nil {
@@ -541,7 +711,9 @@ func (nc *NodeCommunicator) ProxyGet(ctx context.Context, nodeID string, key str
 	return body.Value, true, nil
 }
 
-// ProxySet forwards a SET request to the owner node.
+// ProxySet forwards a SET request to the owner node. ttlSeconds 0 applies
+// the owner's default TTL; a negative ttlSeconds stores the key without
+// expiry.
 func (nc *NodeCommunicator) ProxySet(ctx context.Context, nodeID string, key string, value interface{}, ttlSeconds float64) error {
 	member, exists := nc.membership.GetMember(nodeID)
 	if !exists {
@@ -559,6 +731,11 @@ func (nc *NodeCommunicator) ProxySet(ctx context.Context, nodeID string, key str
 	payload := map[string]interface{}{
 		"value": value,
 	}
+	if ttlSeconds > 0 {
+		payload["ttl_seconds"] = int64(math.Ceil(ttlSeconds))
+	} else if ttlSeconds < 0 {
+		payload["ttl_seconds"] = 0
+	}
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -571,6 +748,7 @@ func (nc *NodeCommunicator) ProxySet(ctx context.Context, nodeID string, key str
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-HyperCache-Node-ID", nc.localNodeID)
+	setCorrelationHeader(req)
 	req.Header.Set("X-HyperCache-Proxied", "true") // Prevent infinite proxy loops
 
 	resp, err := nc.httpClient.Do(req)
@@ -607,6 +785,7 @@ func (nc *NodeCommunicator) ProxyDelete(ctx context.Context, nodeID string, key
 		return false, err
 	}
 	req.Header.Set("X-HyperCache-Node-ID", nc.localNodeID)
+	setCorrelationHeader(req)
 	req.Header.Set("X-HyperCache-Proxied", "true")
 
 	resp, err := nc.httpClient.Do(req)
@@ -625,3 +804,11 @@ func (nc *NodeCommunicator) ProxyDelete(ctx context.Context, nodeID string, key
 	_ = json.NewDecoder(resp.Body).Decode(&body)
 	return body.Existed, nil
 }
+
+// setCorrelationHeader forwards the request context's correlation ID so the
+// receiving node logs under the same ID as the originating command
+func setCorrelationHeader(req *http.Request) {
+	if correlationID := logging.GetCorrelationID(req.Context()); correlationID != "" {
+		req.Header.Set("X-Correlation-ID", correlationID)
+	}
+}
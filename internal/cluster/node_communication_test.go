@@ -0,0 +1,147 @@
+package cluster
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// encodingRecorder records the Content-Encoding and decoded value of each replication
+type encodingRecorder struct {
+	mu        sync.Mutex
+	encodings []string
+	values    []string
+}
+
+func (e *encodingRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	var payload struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e.mu.Lock()
+	e.encodings = append(e.encodings, r.Header.Get("Content-Encoding"))
+	e.values = append(e.values, payload.Value)
+	e.mu.Unlock()
+}
+
+func TestNodeCommunicator_CompressionFollowsCapabilities(t *testing.T) {
+	membership := &staticMembership{members: make(map[string]*ClusterMember)}
+	peers := map[string]*encodingRecorder{}
+	capabilities := map[string]string{
+		"modern": "filters,persistence,resp,compression",
+		"legacy": "filters,persistence,resp",
+	}
+
+	for nodeID, caps := range capabilities {
+		peer := &encodingRecorder{}
+		server := httptest.NewServer(peer)
+		defer server.Close()
+
+		host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+		portNum, _ := strconv.Atoi(port)
+		peers[nodeID] = peer
+		membership.members[nodeID] = &ClusterMember{
+			NodeID:   nodeID,
+			Address:  host,
+			Port:     portNum,
+			Metadata: map[string]string{"http_port": port, "capabilities": caps},
+		}
+	}
+
+	nc := NewNodeCommunicator("local", membership)
+	large := strings.Repeat("compressible ", 500)
+
+	for nodeID := range capabilities {
+		if err := nc.ReplicateEntry(context.Background(), nodeID, "key", large, 0, 1); err != nil {
+			t.Fatalf("ReplicateEntry to %s failed: %v", nodeID, err)
+		}
+		if err := nc.ReplicateEntry(context.Background(), nodeID, "small", "v", 0, 2); err != nil {
+			t.Fatalf("ReplicateEntry to %s failed: %v", nodeID, err)
+		}
+	}
+
+	expected := map[string][]string{
+		"modern": {"gzip", ""}, // Small payloads are never compressed
+		"legacy": {"", ""},
+	}
+	for nodeID, want := range expected {
+		peer := peers[nodeID]
+		peer.mu.Lock()
+		if len(peer.encodings) != 2 || peer.encodings[0] != want[0] || peer.encodings[1] != want[1] {
+			t.Errorf("%s: expected encodings %q, got %q", nodeID, want, peer.encodings)
+		}
+		if len(peer.values) != 2 || peer.values[0] != large || peer.values[1] != "v" {
+			t.Errorf("%s: replicated values were not received intact", nodeID)
+		}
+		peer.mu.Unlock()
+	}
+}
+
+func TestNodeCommunicator_ProxyReusesPooledConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": "v"})
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	member := &ClusterMember{NodeID: "peer", Address: host, Metadata: map[string]string{"http_port": port}}
+	membership := &staticMembership{
+		members: map[string]*ClusterMember{"peer": member},
+		events:  make(chan MembershipEvent, 1),
+	}
+
+	nc := NewNodeCommunicator("local", membership)
+	defer nc.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nc.WatchMembership(ctx)
+
+	for i := 0; i < 20; i++ {
+		if _, found, err := nc.ProxyGet(context.Background(), "peer", "key"); err != nil || !found {
+			t.Fatalf("ProxyGet %d: found=%v err=%v", i, found, err)
+		}
+	}
+	if dials := nc.pool.Dials(); dials != 1 {
+		t.Errorf("Expected 20 sequential proxied requests to share 1 connection, dialed %d", dials)
+	}
+
+	// A failed node's connections are evicted, so the next request dials again
+	membership.events <- MembershipEvent{Type: MemberFailed, Member: *member}
+	deadline := time.Now().Add(2 * time.Second)
+	for nc.pool.Evictions() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if evictions := nc.pool.Evictions(); evictions != 1 {
+		t.Fatalf("Expected the failed node's connections to be evicted once, got %d", evictions)
+	}
+	if _, _, err := nc.ProxyGet(context.Background(), "peer", "key"); err != nil {
+		t.Fatalf("ProxyGet after eviction: %v", err)
+	}
+	if dials := nc.pool.Dials(); dials != 2 {
+		t.Errorf("Expected a new connection after eviction, dialed %d in total", dials)
+	}
+}
@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// NoopEventBus is the EventBus of a node running without clustering.
+// Publishes are discarded and subscriptions never receive events, so the
+// write path can publish unconditionally instead of checking for a bus.
+type NoopEventBus struct {
+	discarded atomic.Int64
+}
+
+// NewNoopEventBus creates an event bus that discards everything
+func NewNoopEventBus() *NoopEventBus {
+	return &NoopEventBus{}
+}
+
+// Publish discards the event
+func (b *NoopEventBus) Publish(ctx context.Context, event ClusterEvent) error {
+	b.discarded.Add(1)
+	return nil
+}
+
+// Subscribe returns a channel that never receives an event
+func (b *NoopEventBus) Subscribe(eventTypes ...ClusterEventType) <-chan ClusterEvent {
+	return make(chan ClusterEvent)
+}
+
+// Unsubscribe is a no-op; the channel is left open so receivers don't spin
+func (b *NoopEventBus) Unsubscribe(ch <-chan ClusterEvent) {}
+
+// GetMetrics reports discarded publishes as dropped events
+func (b *NoopEventBus) GetMetrics() EventBusMetrics {
+	return EventBusMetrics{EventsDropped: b.discarded.Load()}
+}
+
+// defaultNoopEventBus is shared by EventBusOf for coordinators without a bus
+var defaultNoopEventBus = NewNoopEventBus()
+
+// EventBusOf returns the coordinator's event bus, or a no-op bus when the
+// coordinator is nil or has no bus (clustering disabled)
+func EventBusOf(coord CoordinatorService) EventBus {
+	if coord != nil {
+		if bus := coord.GetEventBus(); bus != nil {
+			return bus
+		}
+	}
+	return defaultNoopEventBus
+}
@@ -0,0 +1,38 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEventBusOf_NoCoordinator(t *testing.T) {
+	bus := EventBusOf(nil)
+	if _, ok := bus.(*NoopEventBus); !ok {
+		t.Fatalf("Expected a NoopEventBus without a coordinator, got %T", bus)
+	}
+
+	ch := bus.Subscribe(EventDataOperation)
+	if err := bus.Publish(context.Background(), ClusterEvent{Type: EventDataOperation}); err != nil {
+		t.Fatalf("Publish on the no-op bus failed: %v", err)
+	}
+	select {
+	case event := <-ch:
+		t.Fatalf("No-op bus delivered an event: %+v", event)
+	case <-time.After(20 * time.Millisecond):
+	}
+	bus.Unsubscribe(ch)
+}
+
+func TestEventBusOf_Coordinator(t *testing.T) {
+	config := DefaultClusterConfig()
+	config.NodeID = "test-node-1"
+	coordinator, err := NewSimpleCoordinator(config)
+	if err != nil {
+		t.Fatalf("Failed to create coordinator: %v", err)
+	}
+
+	if _, ok := EventBusOf(coordinator).(*NoopEventBus); ok {
+		t.Error("Expected the coordinator's own event bus")
+	}
+}
@@ -33,7 +33,7 @@ type CuckooFilter struct {
 	maxEvictionLength uint32   // Maximum eviction chain length
 
 	// Statistics (atomic for thread safety)
-	size              uint64 // Current number of items
+	size              uint64 // Occupied fingerprint slots
 	capacity          uint64 // Maximum capacity
 	addOps            uint64 // Add operations counter
 	lookupOps         uint64 // Lookup operations counter
@@ -47,13 +47,33 @@ type CuckooFilter struct {
 	maxEvictionLen    uint32 // Longest eviction chain
 	resizeOps         uint64 // Resize operations
 
+	// Measured false positives: lookups the filter passed that the caller
+	// found absent, out of all lookups of absent keys. Reset on rebuild.
+	rejections     atomic.Uint64
+	falsePositives atomic.Uint64
+
 	// Timing
 	createdAt      time.Time
-	lastModified   time.Time
+	lastModified   atomic.Int64 // Unix nanoseconds
 	lastStatsReset time.Time
 
-	// Thread safety
-	mutex sync.RWMutex
+	// Thread safety. Single-bucket operations hold mutex shared plus the
+	// stripe locks of their two buckets, taken in ascending order, so adds
+	// and deletes on different buckets run in parallel. Eviction chains and
+	// Clear hold mutex exclusively: a chain moves fingerprints across many
+	// buckets, and no reader may observe one while it is between buckets.
+	mutex   sync.RWMutex
+	stripes []stripeLock
+}
+
+// cuckooLockStripes is the maximum number of bucket lock stripes
+const cuckooLockStripes = 1024
+
+// stripeLock guards the buckets whose index maps to it, padded to its own
+// cache line so neighbouring stripes don't contend
+type stripeLock struct {
+	sync.RWMutex
+	_ [40]byte
 }
 
 // bucket represents a collection of fingerprint slots in the Cuckoo filter.
@@ -106,9 +126,10 @@ func NewCuckooFilter(config *FilterConfig) (*CuckooFilter, error) {
 		maxEvictionLength: config.MaxEvictionAttempts,
 		capacity:          uint64(float64(numBuckets) * float64(config.BucketSize) * loadFactor),
 		createdAt:         now,
-		lastModified:      now,
 		lastStatsReset:    now,
+		stripes:           make([]stripeLock, min(numBuckets, cuckooLockStripes)),
 	}
+	cf.touch()
 
 	return cf, nil
 }
@@ -128,42 +149,32 @@ func (cf *CuckooFilter) Add(key []byte) error {
 		return ErrFilterFull
 	}
 
-	// Calculate hash and fingerprint
-	hash := cf.hash(key)
-	fingerprint := cf.fingerprint(hash)
-	if fingerprint == 0 {
-		fingerprint = 1 // Avoid zero fingerprints
+	// Fast path: insert into either bucket under their stripe locks
+	cf.mutex.RLock()
+	fingerprint, bucket1, bucket2 := cf.locate(key)
+	cf.lockBuckets(bucket1, bucket2)
+	inserted := cf.insertToBucket(bucket1, fingerprint) || cf.insertToBucket(bucket2, fingerprint)
+	if inserted {
+		// Count the slot before unlocking, so a racing Delete of it can't
+		// decrement size first
+		cf.addSucceeded()
 	}
-
-	// Calculate two possible bucket positions
-	bucket1 := cf.bucketIndex(hash)
-	bucket2 := cf.altBucketIndex(bucket1, fingerprint)
-
-	cf.mutex.Lock()
-	defer cf.mutex.Unlock()
-
-	// Try to insert in bucket1
-	if cf.insertToBucket(bucket1, fingerprint) {
-		atomic.AddUint64(&cf.size, 1)
-		atomic.AddUint64(&cf.successfulAdds, 1)
-		cf.lastModified = time.Now()
+	cf.unlockBuckets(bucket1, bucket2)
+	cf.mutex.RUnlock()
+	if inserted {
 		return nil
 	}
 
-	// Try to insert in bucket2
-	if cf.insertToBucket(bucket2, fingerprint) {
-		atomic.AddUint64(&cf.size, 1)
-		atomic.AddUint64(&cf.successfulAdds, 1)
-		cf.lastModified = time.Now()
-		return nil
-	}
+	// Both buckets are full: evict with the whole table locked. Concurrent
+	// deletes may have freed a slot in the meantime, so retry them first.
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
 
-	// Both buckets are full, start eviction process
-	success := cf.evictAndInsert(bucket1, fingerprint)
-	if success {
-		atomic.AddUint64(&cf.size, 1)
-		atomic.AddUint64(&cf.successfulAdds, 1)
-		cf.lastModified = time.Now()
+	// A rebuild may have changed the fingerprint size meanwhile
+	fingerprint, bucket1, bucket2 = cf.locate(key)
+	if cf.insertToBucket(bucket1, fingerprint) || cf.insertToBucket(bucket2, fingerprint) ||
+		cf.evictAndInsert(bucket1, fingerprint) {
+		cf.addSucceeded()
 		return nil
 	}
 
@@ -179,22 +190,18 @@ func (cf *CuckooFilter) Contains(key []byte) bool {
 
 	atomic.AddUint64(&cf.lookupOps, 1)
 
-	// Calculate hash and fingerprint
-	hash := cf.hash(key)
-	fingerprint := cf.fingerprint(hash)
-	if fingerprint == 0 {
-		fingerprint = 1
-	}
-
-	// Calculate two possible bucket positions
-	bucket1 := cf.bucketIndex(hash)
-	bucket2 := cf.altBucketIndex(bucket1, fingerprint)
-
 	cf.mutex.RLock()
 	defer cf.mutex.RUnlock()
+	fingerprint, bucket1, bucket2 := cf.locate(key)
+	cf.rlockBuckets(bucket1, bucket2)
+	defer cf.runlockBuckets(bucket1, bucket2)
 
 	// Check both buckets
-	return cf.bucketContains(bucket1, fingerprint) || cf.bucketContains(bucket2, fingerprint)
+	if cf.bucketContains(bucket1, fingerprint) || cf.bucketContains(bucket2, fingerprint) {
+		return true
+	}
+	cf.rejections.Add(1)
+	return false
 }
 
 // Delete removes a key from the filter if it exists.
@@ -206,33 +213,19 @@ func (cf *CuckooFilter) Delete(key []byte) bool {
 
 	atomic.AddUint64(&cf.deleteOps, 1)
 
-	// Calculate hash and fingerprint
-	hash := cf.hash(key)
-	fingerprint := cf.fingerprint(hash)
-	if fingerprint == 0 {
-		fingerprint = 1
-	}
-
-	// Calculate two possible bucket positions
-	bucket1 := cf.bucketIndex(hash)
-	bucket2 := cf.altBucketIndex(bucket1, fingerprint)
-
-	cf.mutex.Lock()
-	defer cf.mutex.Unlock()
-
-	// Try to delete from bucket1
-	if cf.deleteFromBucket(bucket1, fingerprint) {
-		atomic.AddUint64(&cf.size, ^uint64(0)) // Atomic decrement
-		atomic.AddUint64(&cf.successfulDeletes, 1)
-		cf.lastModified = time.Now()
-		return true
-	}
-
-	// Try to delete from bucket2
-	if cf.deleteFromBucket(bucket2, fingerprint) {
-		atomic.AddUint64(&cf.size, ^uint64(0)) // Atomic decrement
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+	fingerprint, bucket1, bucket2 := cf.locate(key)
+	cf.lockBuckets(bucket1, bucket2)
+	defer cf.unlockBuckets(bucket1, bucket2)
+
+	// Try to delete from bucket1, then bucket2. Keys sharing a fingerprint
+	// and bucket are indistinguishable, so deleting a key that was never
+	// added removes the other key's slot; size follows the slots either way.
+	if cf.deleteFromBucket(bucket1, fingerprint) || cf.deleteFromBucket(bucket2, fingerprint) {
+		cf.decrementSize()
 		atomic.AddUint64(&cf.successfulDeletes, 1)
-		cf.lastModified = time.Now()
+		cf.touch()
 		return true
 	}
 
@@ -254,7 +247,7 @@ func (cf *CuckooFilter) Clear() error {
 
 	// Reset counters
 	atomic.StoreUint64(&cf.size, 0)
-	cf.lastModified = time.Now()
+	cf.touch()
 
 	return nil
 }
@@ -285,7 +278,11 @@ func (cf *CuckooFilter) GetStats() *FilterStats {
 		Capacity:          cf.capacity,
 		LoadFactor:        cf.LoadFactor(),
 		MemoryUsage:       cf.EstimatedMemoryUsage(),
-		FalsePositiveRate: cf.FalsePositiveRate(),
+		FalsePositiveRate: cf.falsePositiveRate(),
+		CollisionRate:     cf.collisionRate(),
+		FalsePositives:    cf.falsePositives.Load(),
+		MeasuredFPR:       cf.MeasuredFalsePositiveRate(),
+		FingerprintSize:   cf.fingerprintSize,
 		AddOperations:     atomic.LoadUint64(&cf.addOps),
 		LookupOperations:  atomic.LoadUint64(&cf.lookupOps),
 		DeleteOperations:  atomic.LoadUint64(&cf.deleteOps),
@@ -298,7 +295,7 @@ func (cf *CuckooFilter) GetStats() *FilterStats {
 		MaxEvictionLength: atomic.LoadUint32(&cf.maxEvictionLen),
 		ResizeOperations:  atomic.LoadUint64(&cf.resizeOps),
 		CreatedAt:         cf.createdAt,
-		LastModified:      cf.lastModified,
+		LastModified:      time.Unix(0, cf.lastModified.Load()),
 		LastStatsReset:    cf.lastStatsReset,
 	}
 }
@@ -319,12 +316,199 @@ func (cf *CuckooFilter) EstimatedMemoryUsage() uint64 {
 
 // FalsePositiveRate returns the theoretical false positive rate.
 func (cf *CuckooFilter) FalsePositiveRate() float64 {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+	return cf.falsePositiveRate()
+}
+
+// falsePositiveRate is FalsePositiveRate for callers holding mutex
+func (cf *CuckooFilter) falsePositiveRate() float64 {
 	// For Cuckoo filters: FPR ≈ 2^(-fingerprintSize) × bucketSize
 	return float64(cf.bucketSize) / math.Pow(2, float64(cf.fingerprintSize))
 }
 
+// RecordFalsePositive records that a key the filter passed was not found
+func (cf *CuckooFilter) RecordFalsePositive() {
+	cf.falsePositives.Add(1)
+}
+
+// MeasuredFalsePositiveRate returns the observed share of lookups of absent
+// keys the filter passed, as reported through RecordFalsePositive
+func (cf *CuckooFilter) MeasuredFalsePositiveRate() float64 {
+	fp := cf.falsePositives.Load()
+	negatives := fp + cf.rejections.Load()
+	if negatives == 0 {
+		return 0
+	}
+	return float64(fp) / float64(negatives)
+}
+
+// Fingerprint rebuild policy: rebuild once the measured FPR is more than
+// fprDriftFactor times the target over at least fprMinSamples absent lookups
+const (
+	fprDriftFactor        = 2.0
+	fprMinSamples         = 10000
+	maxFingerprintSize    = 16 // fingerprints are stored as uint16
+	fingerprintGrowthBits = 2
+)
+
+// NeedsLargerFingerprint reports whether auto-resize is enabled and the
+// measured false-positive rate has drifted above the configured target, and
+// if so the fingerprint size a rebuild should use
+func (cf *CuckooFilter) NeedsLargerFingerprint() (uint8, bool) {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	if !cf.config.EnableAutoResize || cf.fingerprintSize >= maxFingerprintSize {
+		return 0, false
+	}
+	fp := cf.falsePositives.Load()
+	if fp+cf.rejections.Load() < fprMinSamples {
+		return 0, false
+	}
+	if cf.MeasuredFalsePositiveRate() <= cf.config.FalsePositiveRate*fprDriftFactor {
+		return 0, false
+	}
+
+	size := max(cf.fingerprintSize+fingerprintGrowthBits, calculateOptimalFingerprintSize(cf.config.FalsePositiveRate, cf.bucketSize))
+	return min(size, maxFingerprintSize), true
+}
+
+// Rebuild empties the filter, switches to fingerprintSize bits and re-adds
+// every key passed to add by keys. Lookups and updates wait until it is
+// done; keys that no longer fit are counted as failed adds.
+func (cf *CuckooFilter) Rebuild(fingerprintSize uint8, keys func(add func(key []byte))) {
+	fingerprintSize = max(1, min(fingerprintSize, maxFingerprintSize))
+
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	for i := range cf.buckets {
+		cf.buckets[i] = bucket{}
+	}
+	atomic.StoreUint64(&cf.size, 0)
+	cf.fingerprintSize = fingerprintSize
+	cf.fingerprintMask = 1<<fingerprintSize - 1
+
+	keys(func(key []byte) {
+		if len(key) == 0 || atomic.LoadUint64(&cf.size) >= cf.capacity {
+			atomic.AddUint64(&cf.failedAdds, 1)
+			return
+		}
+		fingerprint, bucket1, _ := cf.locate(key)
+		if cf.insertToBucket(bucket1, fingerprint) || cf.insertToBucket(cf.altBucketIndex(bucket1, fingerprint), fingerprint) ||
+			cf.evictAndInsert(bucket1, fingerprint) {
+			atomic.AddUint64(&cf.size, 1)
+			return
+		}
+		atomic.AddUint64(&cf.failedAdds, 1)
+	})
+
+	cf.falsePositives.Store(0)
+	cf.rejections.Store(0)
+	atomic.AddUint64(&cf.resizeOps, 1)
+	cf.touch()
+}
+
+// CollisionRate estimates the chance that a key shares its fingerprint and
+// one of its buckets with another stored key, at the current load. Deleting
+// a key that is absent from the filter, for example because its Add failed,
+// removes such a colliding key's slot and makes it a false negative.
+func (cf *CuckooFilter) CollisionRate() float64 {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+	return cf.collisionRate()
+}
+
+// collisionRate is CollisionRate for callers holding mutex
+func (cf *CuckooFilter) collisionRate() float64 {
+	// Each key sees 2 buckets × bucketSize slots, occupied at the load
+	// factor, each matching a random fingerprint with probability 2^-f
+	others := 2 * float64(cf.bucketSize) * cf.LoadFactor()
+	return 1 - math.Pow(1-math.Pow(2, -float64(cf.fingerprintSize)), others)
+}
+
 // Internal helper methods
 
+// addSucceeded records a successful add
+func (cf *CuckooFilter) addSucceeded() {
+	atomic.AddUint64(&cf.size, 1)
+	atomic.AddUint64(&cf.successfulAdds, 1)
+	cf.touch()
+}
+
+// decrementSize decrements size for a freed slot, never wrapping below zero
+func (cf *CuckooFilter) decrementSize() {
+	for {
+		size := atomic.LoadUint64(&cf.size)
+		if size == 0 || atomic.CompareAndSwapUint64(&cf.size, size, size-1) {
+			return
+		}
+	}
+}
+
+// touch records the time of the latest modification
+func (cf *CuckooFilter) touch() {
+	cf.lastModified.Store(time.Now().UnixNano())
+}
+
+// stripeIndexes returns the stripes of two buckets in ascending order, the
+// order every caller must lock them in. ok is false if they share a stripe.
+func (cf *CuckooFilter) stripeIndexes(b1, b2 uint64) (lo, hi uint64, ok bool) {
+	n := uint64(len(cf.stripes))
+	lo, hi = b1%n, b2%n
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	return lo, hi, lo != hi
+}
+
+// lockBuckets write-locks the stripes of two buckets; the caller holds mutex shared
+func (cf *CuckooFilter) lockBuckets(b1, b2 uint64) {
+	lo, hi, two := cf.stripeIndexes(b1, b2)
+	cf.stripes[lo].Lock()
+	if two {
+		cf.stripes[hi].Lock()
+	}
+}
+
+func (cf *CuckooFilter) unlockBuckets(b1, b2 uint64) {
+	lo, hi, two := cf.stripeIndexes(b1, b2)
+	if two {
+		cf.stripes[hi].Unlock()
+	}
+	cf.stripes[lo].Unlock()
+}
+
+// rlockBuckets read-locks the stripes of two buckets; the caller holds mutex shared
+func (cf *CuckooFilter) rlockBuckets(b1, b2 uint64) {
+	lo, hi, two := cf.stripeIndexes(b1, b2)
+	cf.stripes[lo].RLock()
+	if two {
+		cf.stripes[hi].RLock()
+	}
+}
+
+func (cf *CuckooFilter) runlockBuckets(b1, b2 uint64) {
+	lo, hi, two := cf.stripeIndexes(b1, b2)
+	if two {
+		cf.stripes[hi].RUnlock()
+	}
+	cf.stripes[lo].RUnlock()
+}
+
+// locate returns a key's fingerprint and its two candidate buckets. The
+// caller holds mutex, which guards the fingerprint size.
+func (cf *CuckooFilter) locate(key []byte) (fingerprint uint32, bucket1, bucket2 uint64) {
+	hash := cf.hash(key)
+	fingerprint = cf.fingerprint(hash)
+	if fingerprint == 0 {
+		fingerprint = 1 // Avoid zero fingerprints
+	}
+	bucket1 = cf.bucketIndex(hash)
+	return fingerprint, bucket1, cf.altBucketIndex(bucket1, fingerprint)
+}
+
 // hash computes the hash of a key using xxHash
 func (cf *CuckooFilter) hash(key []byte) uint64 {
 	return xxhash.Sum64(key)
@@ -407,7 +591,8 @@ func (cf *CuckooFilter) deleteFromBucket(bucketIdx uint64, fingerprint uint32) b
 	return false
 }
 
-// evictAndInsert performs the cuckoo eviction process
+// evictAndInsert performs the cuckoo eviction process. The caller holds
+// mutex exclusively.
 func (cf *CuckooFilter) evictAndInsert(bucketIdx uint64, fingerprint uint32) bool {
 	atomic.AddUint64(&cf.evictionChains, 1)
 
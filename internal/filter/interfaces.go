@@ -45,6 +45,10 @@ type ProbabilisticFilter interface {
 
 	// FalsePositiveRate returns the theoretical false positive rate.
 	FalsePositiveRate() float64
+
+	// RecordFalsePositive reports that a key Contains passed was absent,
+	// so the filter can measure its real false positive rate.
+	RecordFalsePositive()
 }
 
 // FilterStats contains detailed statistics about filter performance and state.
@@ -55,6 +59,10 @@ type FilterStats struct {
 	LoadFactor        float64 `json:"load_factor"`         // Current load factor
 	MemoryUsage       uint64  `json:"memory_usage"`        // Memory usage in bytes
 	FalsePositiveRate float64 `json:"false_positive_rate"` // Theoretical FP rate
+	CollisionRate     float64 `json:"collision_rate"`      // Estimated chance a key shares its fingerprint and bucket with another
+	FalsePositives    uint64  `json:"false_positives"`     // Passed lookups reported as misses
+	MeasuredFPR       float64 `json:"measured_fpr"`        // Observed false positive rate
+	FingerprintSize   uint8   `json:"fingerprint_size"`    // Current bits per fingerprint
 
 	// Operational metrics
 	AddOperations    uint64 `json:"add_operations"`    // Total add operations
@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hypercache/internal/metrics"
+)
+
+// defaultAuditBufferSize is how many audit records may wait to be written
+const defaultAuditBufferSize = 10000
+
+// AuditRecord is one key access in the audit log
+type AuditRecord struct {
+	Timestamp     time.Time `json:"@timestamp"`
+	NodeID        string    `json:"node_id,omitempty"`
+	Operation     string    `json:"operation"` // GET, SET or DEL
+	Key           string    `json:"key"`
+	Store         string    `json:"store,omitempty"`
+	Protocol      string    `json:"protocol"` // resp or http
+	ClientID      string    `json:"client_id,omitempty"`
+	ClientName    string    `json:"client_name,omitempty"`
+	ClientAddr    string    `json:"client_addr,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+}
+
+// AuditConfig configures an AuditLog
+type AuditConfig struct {
+	LogFile     string
+	SampleRate  int   // Record 1 in N accesses (0 or 1 = all)
+	BufferSize  int   // Records waiting to be written; more are dropped (0 = 10000)
+	MaxFileSize int64 // Rotate at this size (0 = no limit)
+	MaxFiles    int   // Files to keep, including the current one (0 = all)
+}
+
+// AuditLog writes key-access records as JSON lines to a dedicated file,
+// separate from the operational log. Recording never blocks: records are
+// queued for a background writer, and dropped and counted when the queue is
+// full.
+type AuditLog struct {
+	nodeID     string
+	sampleRate uint64
+	seen       atomic.Uint64
+	dropped    atomic.Uint64
+
+	records chan AuditRecord
+	file    *rotatingFile
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewAuditLog opens the audit log file and starts its writer
+func NewAuditLog(nodeID string, config AuditConfig) (*AuditLog, error) {
+	if config.LogFile == "" {
+		return nil, fmt.Errorf("audit log file is required")
+	}
+	file, err := openRotatingFile(config.LogFile, config.MaxFileSize, 0, config.MaxFiles, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	bufferSize := config.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultAuditBufferSize
+	}
+	a := &AuditLog{
+		nodeID:  nodeID,
+		records: make(chan AuditRecord, bufferSize),
+		file:    file,
+		done:    make(chan struct{}),
+	}
+	if config.SampleRate > 1 {
+		a.sampleRate = uint64(config.SampleRate)
+	}
+
+	a.wg.Add(1)
+	go a.processRecords()
+	return a, nil
+}
+
+// Record queues a key access. Timestamp and NodeID are filled in if unset.
+func (a *AuditLog) Record(record AuditRecord) {
+	if a.sampleRate > 1 && a.seen.Add(1)%a.sampleRate != 1 {
+		return
+	}
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now().UTC()
+	}
+	if record.NodeID == "" {
+		record.NodeID = a.nodeID
+	}
+
+	select {
+	case a.records <- record:
+	default:
+		a.dropped.Add(1)
+		metrics.Global().IncCounter("hypercache_audit_dropped_total")
+	}
+}
+
+// Dropped returns how many records were dropped because the queue was full
+func (a *AuditLog) Dropped() uint64 {
+	return a.dropped.Load()
+}
+
+// processRecords writes queued records, flushing whenever the queue empties
+func (a *AuditLog) processRecords() {
+	defer a.wg.Done()
+
+	for {
+		select {
+		case record := <-a.records:
+			a.write(record)
+			if len(a.records) == 0 {
+				_ = a.file.Flush()
+			}
+		case <-a.done:
+			for {
+				select {
+				case record := <-a.records:
+					a.write(record)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write appends one record to the file
+func (a *AuditLog) write(record AuditRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal audit record: %v\n", err)
+		return
+	}
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write audit record: %v\n", err)
+	}
+}
+
+// Close writes the queued records and closes the file
+func (a *AuditLog) Close() error {
+	close(a.done)
+	a.wg.Wait()
+	return a.file.Close()
+}
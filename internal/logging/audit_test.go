@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestAuditLog_SamplingAndBound checks that 1-in-N sampling keeps a tenth of
+// the records and that a full queue drops records rather than blocking
+func TestAuditLog_SamplingAndBound(t *testing.T) {
+	dir := t.TempDir()
+
+	sampled, err := NewAuditLog("node-1", AuditConfig{LogFile: filepath.Join(dir, "sampled.log"), SampleRate: 10})
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		sampled.Record(AuditRecord{Operation: "GET", Key: "k", Protocol: "resp"})
+	}
+	if err := sampled.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n := countLines(t, sampled.file.path); n != 100 {
+		t.Errorf("Expected 100 of 1000 records with 1-in-10 sampling, got %d", n)
+	}
+
+	// Stop the writer so the queue can't drain, then overfill it
+	bounded, err := NewAuditLog("node-1", AuditConfig{LogFile: filepath.Join(dir, "bounded.log"), BufferSize: 5})
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	close(bounded.done)
+	bounded.wg.Wait()
+	for i := 0; i < 8; i++ {
+		bounded.Record(AuditRecord{Operation: "SET", Key: "k", Protocol: "resp"})
+	}
+	if dropped := bounded.Dropped(); dropped != 3 {
+		t.Errorf("Expected 3 records dropped past a queue of 5, got %d", dropped)
+	}
+	_ = bounded.file.Close()
+}
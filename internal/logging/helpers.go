@@ -5,6 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"hypercache/pkg/config"
 )
 
 // LogLevelFromString converts string to LogLevel
@@ -44,31 +47,69 @@ func InitializeFromConfig(nodeID string, logConfig LogConfig) (*Logger, error) {
 		}
 	}
 
-	config := Config{
+	var maxFileSize uint64
+	if logConfig.MaxFileSize != "" {
+		size, err := config.ParseSize(logConfig.MaxFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_file_size: %v", err)
+		}
+		maxFileSize = size
+	}
+	rotateInterval, err := parseInterval("rotate_interval", logConfig.RotateInterval)
+	if err != nil {
+		return nil, err
+	}
+	flushInterval, err := parseInterval("flush_interval", logConfig.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	loggerConfig := Config{
 		Level:         LogLevelFromString(logConfig.Level),
 		NodeID:        nodeID,
 		LogFile:       logFile,
 		EnableConsole: logConfig.EnableConsole,
 		EnableFile:    logConfig.EnableFile,
 		BufferSize:    logConfig.BufferSize,
+		SampleRate:    logConfig.SampleRate,
+
+		MaxFileSize:    int64(maxFileSize),
+		RotateInterval: rotateInterval,
+		MaxFiles:       logConfig.MaxFiles,
+		FlushInterval:  flushInterval,
 	}
 
-	logger := NewLogger(config)
+	logger := NewLogger(loggerConfig)
 	SetGlobalLogger(logger)
 
 	return logger, nil
 }
 
+// parseInterval parses an interval setting; empty or "0" means disabled
+func parseInterval(setting, value string) (time.Duration, error) {
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", setting, err)
+	}
+	return interval, nil
+}
+
 // LogConfig represents logging configuration (matching the YAML structure)
 type LogConfig struct {
-	Level         string `yaml:"level"`
-	EnableConsole bool   `yaml:"enable_console"`
-	EnableFile    bool   `yaml:"enable_file"`
-	LogFile       string `yaml:"log_file"`
-	BufferSize    int    `yaml:"buffer_size"`
-	LogDir        string `yaml:"log_dir"`
-	MaxFileSize   string `yaml:"max_file_size"`
-	MaxFiles      int    `yaml:"max_files"`
+	Level          string `yaml:"level"`
+	EnableConsole  bool   `yaml:"enable_console"`
+	EnableFile     bool   `yaml:"enable_file"`
+	LogFile        string `yaml:"log_file"`
+	BufferSize     int    `yaml:"buffer_size"`
+	LogDir         string `yaml:"log_dir"`
+	MaxFileSize    string `yaml:"max_file_size"`
+	MaxFiles       int    `yaml:"max_files"`
+	RotateInterval string `yaml:"rotate_interval"`
+	FlushInterval  string `yaml:"flush_interval"`
+	SampleRate     int    `yaml:"sample_rate"`
 }
 
 // ComponentNames for structured logging
@@ -8,9 +8,12 @@ import (
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+
+	"hypercache/internal/metrics"
 )
 
 // LogLevel represents the severity of a log entry
@@ -65,13 +68,21 @@ type LogEntry struct {
 
 // Logger represents the structured logger
 type Logger struct {
-	level   LogLevel
+	level   atomic.Int32 // LogLevel; changed live by SetLevel
 	nodeID  string
 	writers []io.Writer
 	mu      sync.RWMutex
 	logChan chan LogEntry
+	flushCh chan chan struct{} // Flush requests, answered by processLogs
 	done    chan struct{}
 	wg      sync.WaitGroup
+
+	flushInterval time.Duration
+
+	// Sampling of high-frequency events: 1 in sampleRate occurrences of
+	// each message is logged; sampleCounts maps message to *atomic.Uint64
+	sampleRate   atomic.Int64
+	sampleCounts sync.Map
 }
 
 // Config for logger initialization
@@ -82,18 +93,35 @@ type Config struct {
 	EnableConsole bool
 	EnableFile    bool
 	BufferSize    int
+	SampleRate    int // Log 1 in N sampled events (0 or 1 = all)
+
+	// File rotation: the log file rolls over at MaxFileSize bytes or every
+	// RotateInterval, whichever comes first, keeping MaxFiles files. Zero
+	// disables each limit.
+	MaxFileSize    int64
+	RotateInterval time.Duration
+	MaxFiles       int
+
+	// FlushInterval buffers file output, flushing it at this interval as
+	// well as on Flush, Fatal and Close. 0 writes every entry through.
+	FlushInterval time.Duration
 }
 
 // NewLogger creates a new structured logger instance
 func NewLogger(config Config) *Logger {
 	logger := &Logger{
-		level:   config.Level,
 		nodeID:  config.NodeID,
 		writers: make([]io.Writer, 0),
 		logChan: make(chan LogEntry, config.BufferSize),
+		flushCh: make(chan chan struct{}),
 		done:    make(chan struct{}),
+
+		flushInterval: config.FlushInterval,
 	}
 
+	logger.level.Store(int32(config.Level))
+	logger.sampleRate.Store(int64(config.SampleRate))
+
 	// Add console writer if enabled
 	if config.EnableConsole {
 		logger.writers = append(logger.writers, os.Stdout)
@@ -101,7 +129,7 @@ func NewLogger(config Config) *Logger {
 
 	// Add file writer if enabled
 	if config.EnableFile && config.LogFile != "" {
-		if file, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+		if file, err := openRotatingFile(config.LogFile, config.MaxFileSize, config.RotateInterval, config.MaxFiles, config.FlushInterval > 0); err == nil {
 			logger.writers = append(logger.writers, file)
 		} else {
 			fmt.Printf("Failed to open log file %s: %v\n", config.LogFile, err)
@@ -115,28 +143,70 @@ func NewLogger(config Config) *Logger {
 	return logger
 }
 
-// processLogs handles asynchronous log writing
+// processLogs handles asynchronous log writing and periodic flushing
 func (l *Logger) processLogs() {
 	defer l.wg.Done()
 
+	var tick <-chan time.Time
+	if l.flushInterval > 0 {
+		ticker := time.NewTicker(l.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
 	for {
 		select {
 		case entry := <-l.logChan:
 			l.writeEntry(entry)
+		case <-tick:
+			l.flushWriters()
+		case reply := <-l.flushCh:
+			l.drain()
+			l.flushWriters()
+			close(reply)
 		case <-l.done:
-			// Flush remaining entries
-			for {
-				select {
-				case entry := <-l.logChan:
-					l.writeEntry(entry)
-				default:
-					return
-				}
-			}
+			l.drain()
+			l.flushWriters()
+			return
+		}
+	}
+}
+
+// drain writes the entries waiting in the log channel
+func (l *Logger) drain() {
+	for {
+		select {
+		case entry := <-l.logChan:
+			l.writeEntry(entry)
+		default:
+			return
+		}
+	}
+}
+
+// flushWriters flushes writers that buffer output
+func (l *Logger) flushWriters() {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, writer := range l.writers {
+		if f, ok := writer.(interface{ Flush() error }); ok {
+			_ = f.Flush()
 		}
 	}
 }
 
+// Flush blocks until every entry logged so far has been written and any
+// buffered output flushed. It returns immediately once the logger is closed.
+func (l *Logger) Flush() {
+	reply := make(chan struct{})
+	select {
+	case l.flushCh <- reply:
+		<-reply
+	case <-l.done:
+	}
+}
+
 // writeEntry writes a log entry to all configured writers
 func (l *Logger) writeEntry(entry LogEntry) {
 	data, err := json.Marshal(entry)
@@ -145,12 +215,14 @@ func (l *Logger) writeEntry(entry LogEntry) {
 		return
 	}
 
+	// One write per entry, so a file never rotates mid-line
+	data = append(data, '\n')
+
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
 	for _, writer := range l.writers {
 		_, _ = writer.Write(data)
-		_, _ = writer.Write([]byte("\n"))
 	}
 }
 
@@ -177,7 +249,7 @@ func GetCorrelationID(ctx context.Context) string {
 
 // log is the internal logging method
 func (l *Logger) log(ctx context.Context, level LogLevel, component, action, message string, fields map[string]interface{}, err error, duration *time.Duration) {
-	if level < l.level {
+	if level < l.Level() {
 		return
 	}
 
@@ -244,6 +316,45 @@ func (l *Logger) Debug(ctx context.Context, component, action, message string, f
 	l.log(ctx, DEBUG, component, action, message, f, nil, nil)
 }
 
+// DebugSampled logs a debug message for a high-frequency event, such as a
+// replicated write. Only 1 in SampleRate occurrences of each message is
+// logged, with the rate added to its fields.
+func (l *Logger) DebugSampled(ctx context.Context, component, action, message string, fields ...map[string]interface{}) {
+	if DEBUG < l.Level() {
+		return
+	}
+	rate := l.sampleRate.Load()
+	if rate > 1 && !l.sample(message, uint64(rate)) {
+		return
+	}
+
+	f := make(map[string]interface{}, 1)
+	if len(fields) > 0 {
+		for k, v := range fields[0] {
+			f[k] = v
+		}
+	}
+	if rate > 1 {
+		f["sample_rate"] = rate
+	}
+	l.log(ctx, DEBUG, component, action, message, f, nil, nil)
+}
+
+// sample reports whether this occurrence of message is the 1 in rate to log
+func (l *Logger) sample(message string, rate uint64) bool {
+	counter, ok := l.sampleCounts.Load(message)
+	if !ok {
+		counter, _ = l.sampleCounts.LoadOrStore(message, new(atomic.Uint64))
+	}
+	return counter.(*atomic.Uint64).Add(1)%rate == 1
+}
+
+// SetSampleRate changes how many occurrences of a sampled event are logged:
+// 1 in rate (0 or 1 = all)
+func (l *Logger) SetSampleRate(rate int) {
+	l.sampleRate.Store(int64(rate))
+}
+
 // Info logs an info message
 func (l *Logger) Info(ctx context.Context, component, action, message string, fields ...map[string]interface{}) {
 	var f map[string]interface{}
@@ -278,6 +389,8 @@ func (l *Logger) Fatal(ctx context.Context, component, action, message string, e
 		f = fields[0]
 	}
 	l.log(ctx, FATAL, component, action, message, f, err, nil)
+	// Callers usually exit next, skipping deferred Close
+	l.Flush()
 }
 
 // WithDuration logs with timing information
@@ -289,11 +402,13 @@ func (l *Logger) WithDuration(ctx context.Context, level LogLevel, component, ac
 	l.log(ctx, level, component, action, message, f, nil, &duration)
 }
 
-// StartTimer returns a function that logs duration when called
+// StartTimer returns a function that logs duration when called. The duration
+// is also recorded under action in the latency monitor.
 func (l *Logger) StartTimer(ctx context.Context, component, action, message string) func() {
 	start := time.Now()
 	return func() {
 		duration := time.Since(start)
+		metrics.Global().Latency().Record(action, duration)
 		l.WithDuration(ctx, INFO, component, action, message, duration)
 	}
 }
@@ -314,6 +429,16 @@ func (l *Logger) Close() {
 	}
 }
 
+// Level returns the minimum level the logger writes
+func (l *Logger) Level() LogLevel {
+	return LogLevel(l.level.Load())
+}
+
+// SetLevel changes the minimum level the logger writes
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level.Store(int32(level))
+}
+
 // AddWriter adds a new writer to the logger
 func (l *Logger) AddWriter(writer io.Writer) {
 	l.mu.Lock()
@@ -346,6 +471,12 @@ func Debug(ctx context.Context, component, action, message string, fields ...map
 	}
 }
 
+func DebugSampled(ctx context.Context, component, action, message string, fields ...map[string]interface{}) {
+	if logger := GetGlobalLogger(); logger != nil {
+		logger.DebugSampled(ctx, component, action, message, fields...)
+	}
+}
+
 func Info(ctx context.Context, component, action, message string, fields ...map[string]interface{}) {
 	if logger := GetGlobalLogger(); logger != nil {
 		logger.Info(ctx, component, action, message, fields...)
@@ -374,5 +505,6 @@ func StartTimer(ctx context.Context, component, action, message string) func() {
 	if logger := GetGlobalLogger(); logger != nil {
 		return logger.StartTimer(ctx, component, action, message)
 	}
-	return func() {}
+	start := time.Now()
+	return func() { metrics.Global().Latency().Record(action, time.Since(start)) }
 }
@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLogger_DebugSampled checks that with 1-in-10 sampling about a tenth
+// of a high-frequency event's occurrences are logged, counted per message
+func TestLogger_DebugSampled(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(Config{Level: DEBUG, BufferSize: 1000, SampleRate: 10})
+	logger.AddWriter(&buf)
+
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		logger.DebugSampled(ctx, ComponentCluster, ActionReplication, "Applied replicated SET", map[string]interface{}{"i": i})
+	}
+	for i := 0; i < 50; i++ {
+		logger.DebugSampled(ctx, ComponentCluster, ActionReplication, "Applied replicated DELETE")
+	}
+	logger.Debug(ctx, ComponentCluster, ActionReplication, "Unsampled event")
+	logger.Close()
+
+	counts := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse log line %q: %v", line, err)
+		}
+		counts[entry.Message]++
+		if entry.Message != "Unsampled event" && entry.Fields["sample_rate"] != float64(10) {
+			t.Errorf("Expected sample_rate 10 on sampled entry, got %v", entry.Fields["sample_rate"])
+		}
+	}
+
+	if n := counts["Applied replicated SET"]; n < 90 || n > 110 {
+		t.Errorf("Expected about 100 of 1000 sampled events logged, got %d", n)
+	}
+	if n := counts["Applied replicated DELETE"]; n != 5 {
+		t.Errorf("Expected 5 of 50 events of a second message logged, got %d", n)
+	}
+	if n := counts["Unsampled event"]; n != 1 {
+		t.Errorf("Expected unsampled debug logs to be kept, got %d", n)
+	}
+}
+
+// TestLogger_DebugSampledLevel checks that sampled events respect the level
+// and that SetSampleRate(1) logs every occurrence
+func TestLogger_DebugSampledLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(Config{Level: INFO, BufferSize: 1000, SampleRate: 10})
+	logger.AddWriter(&buf)
+
+	ctx := context.Background()
+	logger.DebugSampled(ctx, ComponentCluster, ActionReplication, "Filtered by level")
+	logger.SetLevel(DEBUG)
+	logger.SetSampleRate(1)
+	for i := 0; i < 20; i++ {
+		logger.DebugSampled(ctx, ComponentCluster, ActionReplication, "Every occurrence")
+	}
+	logger.Close()
+
+	if got := strings.Count(buf.String(), "Filtered by level"); got != 0 {
+		t.Errorf("Expected debug events below the level to be dropped, got %d", got)
+	}
+	if got := strings.Count(buf.String(), "Every occurrence"); got != 20 {
+		t.Errorf("Expected all 20 events logged without sampling, got %d", got)
+	}
+}
+
+// countLines returns the number of lines in the file at path
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+// TestLogger_CloseDrainsBufferedFile checks that entries still queued or
+// held in the file buffer all reach the file on Close
+func TestLogger_CloseDrainsBufferedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node-1.log")
+	logger := NewLogger(Config{Level: INFO, LogFile: path, EnableFile: true, BufferSize: 1000, FlushInterval: time.Hour})
+
+	ctx := context.Background()
+	for i := 0; i < 500; i++ {
+		logger.Info(ctx, ComponentMain, ActionStop, "Shutting down", map[string]interface{}{"i": i})
+	}
+	if n := countLines(t, path); n >= 500 {
+		t.Fatalf("Expected entries to be buffered before Close, found %d in the file", n)
+	}
+
+	logger.Close()
+	if n := countLines(t, path); n != 500 {
+		t.Errorf("Expected all 500 entries in the file after Close, got %d", n)
+	}
+}
+
+// TestLogger_FlushInterval checks the periodic background flush and that
+// Flush and Fatal make entries durable without closing the logger
+func TestLogger_FlushInterval(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	periodic := filepath.Join(dir, "periodic.log")
+	logger := NewLogger(Config{Level: INFO, LogFile: periodic, EnableFile: true, BufferSize: 100, FlushInterval: 20 * time.Millisecond})
+	defer logger.Close()
+	logger.Info(ctx, ComponentMain, ActionStart, "Flushed in the background")
+	deadline := time.Now().Add(2 * time.Second)
+	for countLines(t, periodic) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the periodic flush")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	explicit := filepath.Join(dir, "explicit.log")
+	held := NewLogger(Config{Level: INFO, LogFile: explicit, EnableFile: true, BufferSize: 100, FlushInterval: time.Hour})
+	defer held.Close()
+	held.Info(ctx, ComponentMain, ActionStart, "Flushed on request")
+	held.Flush()
+	if n := countLines(t, explicit); n != 1 {
+		t.Errorf("Expected Flush to write the entry, found %d lines", n)
+	}
+	held.Fatal(ctx, ComponentMain, ActionStart, "Flushed before exit", errors.New("boom"))
+	if n := countLines(t, explicit); n != 2 {
+		t.Errorf("Expected Fatal to flush its entry, found %d lines", n)
+	}
+}
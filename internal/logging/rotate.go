@@ -0,0 +1,223 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the timestamp in rotated file names; it sorts
+// chronologically and is safe in file names on every platform
+const rotatedTimeFormat = "20060102T150405.000"
+
+// logFileBufferSize is the write buffer of a buffered log file
+const logFileBufferSize = 64 * 1024
+
+// rotatingFile is a log file that rolls over when it reaches maxSize bytes or
+// at the next multiple of interval (UTC), whichever comes first. The rolled
+// file is renamed with the rotation time, e.g. node-1.log becomes
+// node-1-20260102T000000.000.log, and only the newest maxFiles files
+// (including the current one) are kept. A buffered file holds writes in
+// memory until Flush, Close or a full buffer.
+type rotatingFile struct {
+	path     string
+	maxSize  int64         // 0 = no size limit
+	interval time.Duration // 0 = no time-based rotation
+	maxFiles int           // 0 = keep all
+	now      func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	buf      *bufio.Writer // nil when unbuffered
+	size     int64
+	rotateAt time.Time
+}
+
+// openRotatingFile opens (or appends to) the log file at path
+func openRotatingFile(path string, maxSize int64, interval time.Duration, maxFiles int, buffered bool) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:     path,
+		maxSize:  maxSize,
+		interval: interval,
+		maxFiles: maxFiles,
+		now:      time.Now,
+	}
+	if buffered {
+		r.buf = bufio.NewWriterSize(nil, logFileBufferSize)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current file and schedules its time-based rotation
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	if r.buf != nil {
+		r.buf.Reset(file)
+	}
+	if r.interval > 0 {
+		// Align to interval boundaries so e.g. daily files roll at midnight
+		// UTC, also across restarts
+		r.rotateAt = r.now().UTC().Truncate(r.interval).Add(r.interval)
+	}
+	return nil
+}
+
+// Write writes p to the current file, rotating first if p would take it past
+// maxSize or the rotation interval has elapsed
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.dueForRotation(len(p)) {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the current file rather than dropping entries
+			fmt.Fprintf(os.Stderr, "Failed to rotate log file %s: %v\n", r.path, err)
+		}
+	}
+
+	var n int
+	var err error
+	if r.buf != nil {
+		n, err = r.buf.Write(p)
+	} else {
+		n, err = r.file.Write(p)
+	}
+	r.size += int64(n)
+	return n, err
+}
+
+// Flush writes buffered entries to the file
+func (r *rotatingFile) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil || r.buf == nil {
+		return nil
+	}
+	return r.buf.Flush()
+}
+
+// dueForRotation reports whether the current file should be rolled before
+// writing n more bytes. An empty file is never rolled.
+func (r *rotatingFile) dueForRotation(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+int64(n) > r.maxSize {
+		return true
+	}
+	return r.interval > 0 && !r.now().Before(r.rotateAt)
+}
+
+// rotate renames the current file with a timestamp, opens a fresh one and
+// removes rolled files beyond maxFiles
+func (r *rotatingFile) rotate() error {
+	rotated := r.rotatedName(r.now().UTC())
+	if r.buf != nil {
+		if err := r.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(r.path, rotated)
+	// Reopen even if the rename failed, appending to the old file
+	if err := r.open(); err != nil {
+		r.file = nil
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	return r.removeOldFiles()
+}
+
+// rotatedName returns the path a file rolled at t is renamed to
+func (r *rotatingFile) rotatedName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, ext), t.Format(rotatedTimeFormat), ext)
+}
+
+// rotatedFiles lists this log's rolled files, oldest first
+func (r *rotatingFile) rotatedFiles() ([]string, error) {
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(r.path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only files named with a rotation timestamp: another node's log such
+	// as node-1-b.log shares the prefix
+	files := matches[:0]
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, prefix), ext)
+		if _, err := time.Parse(rotatedTimeFormat, stamp); err == nil {
+			files = append(files, m)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// removeOldFiles deletes the oldest rolled files so that at most maxFiles
+// files remain, counting the current one
+func (r *rotatingFile) removeOldFiles() error {
+	if r.maxFiles <= 0 {
+		return nil
+	}
+	files, err := r.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for len(files) > r.maxFiles-1 {
+		if err := os.Remove(files[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Close flushes and closes the current file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	var err error
+	if r.buf != nil {
+		err = r.buf.Flush()
+	}
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.file = nil
+	return err
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestRotatingFile opens a rotating file in a temp dir with a controllable clock
+func newTestRotatingFile(t *testing.T, maxSize int64, interval time.Duration, maxFiles int, now *time.Time) *rotatingFile {
+	t.Helper()
+	r := &rotatingFile{
+		path:     filepath.Join(t.TempDir(), "node-1.log"),
+		maxSize:  maxSize,
+		interval: interval,
+		maxFiles: maxFiles,
+		now:      func() time.Time { return *now },
+	}
+	if err := r.open(); err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func writeLine(t *testing.T, r *rotatingFile, line string) {
+	t.Helper()
+	if _, err := r.Write([]byte(line + "\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile_RotatesOnInterval(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	r := newTestRotatingFile(t, 0, 24*time.Hour, 0, &now)
+
+	writeLine(t, r, "day one")
+	now = now.Add(8 * time.Hour) // still before midnight
+	writeLine(t, r, "day one, later")
+
+	if files, _ := r.rotatedFiles(); len(files) != 0 {
+		t.Fatalf("Expected no rotation within the interval, got %v", files)
+	}
+
+	now = time.Date(2026, 1, 3, 0, 0, 1, 0, time.UTC)
+	writeLine(t, r, "day two")
+
+	files, err := r.rotatedFiles()
+	if err != nil {
+		t.Fatalf("Failed to list rotated files: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected one rotated file after the interval, got %v", files)
+	}
+	if want := r.rotatedName(now); files[0] != want {
+		t.Errorf("Expected rotated file %s, got %s", want, files[0])
+	}
+	if got := readFile(t, files[0]); got != "day one\nday one, later\n" {
+		t.Errorf("Unexpected rotated file content %q", got)
+	}
+	if got := readFile(t, r.path); got != "day two\n" {
+		t.Errorf("Unexpected current file content %q", got)
+	}
+}
+
+func TestRotatingFile_SizeAndRetention(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	r := newTestRotatingFile(t, 20, time.Hour, 3, &now)
+
+	// Each 16-byte line fills a file, so every further write rotates
+	for i := 0; i < 5; i++ {
+		writeLine(t, r, "entry-"+string(rune('a'+i))+"-padding")
+		now = now.Add(time.Second)
+	}
+
+	files, err := r.rotatedFiles()
+	if err != nil {
+		t.Fatalf("Failed to list rotated files: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected max_files=3 to keep 2 rotated files, got %v", files)
+	}
+	if got := readFile(t, files[0]); got != "entry-c-padding\n" {
+		t.Errorf("Expected the oldest files removed first, oldest kept has %q", got)
+	}
+	if got := readFile(t, r.path); got != "entry-e-padding\n" {
+		t.Errorf("Unexpected current file content %q", got)
+	}
+
+	// An unrelated log sharing the prefix is never counted or removed
+	other := filepath.Join(filepath.Dir(r.path), "node-1-b.log")
+	if err := os.WriteFile(other, []byte("x\n"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", other, err)
+	}
+	now = now.Add(time.Hour)
+	writeLine(t, r, "entry-f")
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Expected %s to be left alone: %v", other, err)
+	}
+}
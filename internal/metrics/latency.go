@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Latency events recorded outside of RecordOp and logging.StartTimer
+const (
+	LatencyReplicationApply = "replication-apply"
+)
+
+// Each power of two is split into 16 linear sub-buckets, so percentiles are
+// within ~6% of the true value
+const (
+	latencySubBits     = 4
+	latencySubBuckets  = 1 << latencySubBits
+	latencyBucketCount = (64 - latencySubBits) * latencySubBuckets
+)
+
+// latencyHistoryLen is how many per-second samples LATENCY HISTORY keeps per event
+const latencyHistoryLen = 160
+
+// LatencyHistogram is an HDR-style log-linear histogram of durations in
+// microseconds. Recording is a single atomic add; memory is fixed.
+type LatencyHistogram struct {
+	counts [latencyBucketCount]atomic.Int64
+	total  atomic.Int64
+}
+
+// latencyBucket returns the bucket index of a value in microseconds
+func latencyBucket(us uint64) int {
+	if us < 2*latencySubBuckets {
+		return int(us)
+	}
+	shift := bits.Len64(us) - latencySubBits - 1
+	return (shift+1)<<latencySubBits + int(us>>shift) - latencySubBuckets
+}
+
+// latencyBucketMax returns the highest value in microseconds that falls in a bucket
+func latencyBucketMax(idx int) uint64 {
+	if idx < 2*latencySubBuckets {
+		return uint64(idx)
+	}
+	shift := idx>>latencySubBits - 1
+	sub := uint64(idx&(latencySubBuckets-1) + latencySubBuckets)
+	return (sub+1)<<shift - 1
+}
+
+// Record adds a duration to the histogram
+func (h *LatencyHistogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[latencyBucket(uint64(d.Microseconds()))].Add(1)
+	h.total.Add(1)
+}
+
+// Count returns the number of recorded durations
+func (h *LatencyHistogram) Count() int64 {
+	return h.total.Load()
+}
+
+// Percentile returns the duration at or below which p percent (0-100) of the
+// recorded durations fall, rounded up to its bucket's upper bound
+func (h *LatencyHistogram) Percentile(p float64) time.Duration {
+	total := h.total.Load()
+	if total == 0 {
+		return 0
+	}
+	target := int64(float64(total)*p/100 + 0.5)
+	if target < 1 {
+		target = 1
+	}
+
+	var seen int64
+	for i := range h.counts {
+		seen += h.counts[i].Load()
+		if seen >= target {
+			return time.Duration(latencyBucketMax(i)) * time.Microsecond
+		}
+	}
+	return time.Duration(latencyBucketMax(latencyBucketCount-1)) * time.Microsecond
+}
+
+// LatencySample is the worst latency of an event within one second
+type LatencySample struct {
+	Timestamp time.Time
+	Max       time.Duration
+}
+
+// LatencyStats summarizes an event for LATENCY LATEST
+type LatencyStats struct {
+	Event    string
+	LatestAt time.Time     // when the latest duration was recorded
+	Latest   time.Duration // latest recorded duration
+	Max      time.Duration // all-time maximum since the last reset
+	P50      time.Duration
+	P99      time.Duration
+	Count    int64
+}
+
+// latencySecond aggregates the maximum latency of one second
+type latencySecond struct {
+	unix int64
+	max  atomic.Int64
+}
+
+// latencyEvent holds the latency data of one event
+type latencyEvent struct {
+	hist     LatencyHistogram
+	latest   atomic.Int64 // ns
+	latestAt atomic.Int64 // unix ns
+	max      atomic.Int64 // ns
+
+	current atomic.Pointer[latencySecond] // second being aggregated
+	mu      sync.Mutex                    // guards history and rolling current over
+	history []LatencySample               // completed seconds, oldest first
+}
+
+// record adds a duration recorded at now
+func (e *latencyEvent) record(d time.Duration, now time.Time) {
+	e.hist.Record(d)
+	e.latest.Store(int64(d))
+	e.latestAt.Store(now.UnixNano())
+	storeMax(&e.max, int64(d))
+
+	unix := now.Unix()
+	if cur := e.current.Load(); cur != nil && cur.unix == unix {
+		storeMax(&cur.max, int64(d))
+		return
+	}
+
+	e.mu.Lock()
+	cur := e.current.Load()
+	if cur == nil || cur.unix != unix {
+		if cur != nil {
+			e.history = append(e.history, LatencySample{Timestamp: time.Unix(cur.unix, 0), Max: time.Duration(cur.max.Load())})
+			if len(e.history) > latencyHistoryLen {
+				e.history = e.history[len(e.history)-latencyHistoryLen:]
+			}
+		}
+		cur = &latencySecond{unix: unix}
+		e.current.Store(cur)
+	}
+	e.mu.Unlock()
+	storeMax(&cur.max, int64(d))
+}
+
+// storeMax raises v to n if n is larger
+func storeMax(v *atomic.Int64, n int64) {
+	for {
+		old := v.Load()
+		if n <= old || v.CompareAndSwap(old, n) {
+			return
+		}
+	}
+}
+
+// LatencyMonitor tracks latency histograms and per-second history for named
+// events such as "get", "set" or "replication-apply"
+type LatencyMonitor struct {
+	mu     sync.RWMutex
+	events map[string]*latencyEvent
+}
+
+// NewLatencyMonitor creates an empty latency monitor
+func NewLatencyMonitor() *LatencyMonitor {
+	return &LatencyMonitor{events: make(map[string]*latencyEvent)}
+}
+
+// Record adds a duration for event
+func (m *LatencyMonitor) Record(event string, d time.Duration) {
+	m.mu.RLock()
+	e, ok := m.events[event]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.Lock()
+		e, ok = m.events[event]
+		if !ok {
+			e = &latencyEvent{}
+			m.events[event] = e
+		}
+		m.mu.Unlock()
+	}
+	e.record(d, time.Now())
+}
+
+// Latest returns a summary of every event, sorted by name
+func (m *LatencyMonitor) Latest() []LatencyStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make([]LatencyStats, 0, len(m.events))
+	for name, e := range m.events {
+		stats = append(stats, LatencyStats{
+			Event:    name,
+			LatestAt: time.Unix(0, e.latestAt.Load()),
+			Latest:   time.Duration(e.latest.Load()),
+			Max:      time.Duration(e.max.Load()),
+			P50:      e.hist.Percentile(50),
+			P99:      e.hist.Percentile(99),
+			Count:    e.hist.Count(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Event < stats[j].Event })
+	return stats
+}
+
+// History returns the per-second maximum latencies of event, oldest first,
+// including the second in progress. ok is false for unknown events.
+func (m *LatencyMonitor) History(event string) ([]LatencySample, bool) {
+	m.mu.RLock()
+	e, ok := m.events[event]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	samples := make([]LatencySample, len(e.history), len(e.history)+1)
+	copy(samples, e.history)
+	if cur := e.current.Load(); cur != nil {
+		samples = append(samples, LatencySample{Timestamp: time.Unix(cur.unix, 0), Max: time.Duration(cur.max.Load())})
+	}
+	return samples, true
+}
+
+// Reset discards the data of the given events, or of all events if none are
+// given, and returns how many events were reset
+func (m *LatencyMonitor) Reset(events ...string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(events) == 0 {
+		n := len(m.events)
+		m.events = make(map[string]*latencyEvent)
+		return n
+	}
+	n := 0
+	for _, event := range events {
+		if _, ok := m.events[event]; ok {
+			delete(m.events, event)
+			n++
+		}
+	}
+	return n
+}
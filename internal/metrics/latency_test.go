@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyMonitor_Percentiles(t *testing.T) {
+	monitor := NewLatencyMonitor()
+
+	for i := 0; i < 100; i++ {
+		monitor.Record("get", 50*time.Microsecond)
+	}
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		time.Sleep(20 * time.Millisecond)
+		monitor.Record("get", time.Since(start))
+	}
+
+	latest := monitor.Latest()
+	if len(latest) != 1 || latest[0].Event != "get" {
+		t.Fatalf("Expected a single get event, got %+v", latest)
+	}
+	stats := latest[0]
+	if stats.Count != 103 {
+		t.Errorf("Expected 103 samples, got %d", stats.Count)
+	}
+	if stats.P99 < 20*time.Millisecond {
+		t.Errorf("Expected p99 of at least 20ms, got %v", stats.P99)
+	}
+	if stats.P50 < 50*time.Microsecond || stats.P50 > 55*time.Microsecond {
+		t.Errorf("Expected p50 close to 50µs, got %v", stats.P50)
+	}
+	if stats.Max < 20*time.Millisecond || stats.Latest < 20*time.Millisecond {
+		t.Errorf("Expected latest and max of at least 20ms, got %v and %v", stats.Latest, stats.Max)
+	}
+
+	history, ok := monitor.History("get")
+	if !ok || len(history) == 0 || history[len(history)-1].Max < 20*time.Millisecond {
+		t.Errorf("Expected history ending with the slow samples, got %+v", history)
+	}
+
+	if n := monitor.Reset("get", "missing"); n != 1 {
+		t.Errorf("Expected 1 event reset, got %d", n)
+	}
+	if _, ok := monitor.History("get"); ok {
+		t.Error("Expected get to be gone after RESET")
+	}
+}
+
+func TestLatencyBuckets(t *testing.T) {
+	for _, us := range []uint64{0, 1, 15, 31, 32, 33, 100, 1000, 123456, 1 << 40} {
+		idx := latencyBucket(us)
+		if idx >= latencyBucketCount {
+			t.Fatalf("Bucket %d for %dµs out of range", idx, us)
+		}
+		upper := latencyBucketMax(idx)
+		if upper < us || float64(upper-us) > float64(us)/16+1 {
+			t.Errorf("%dµs: bucket %d upper bound %d is not a tight bound", us, idx, upper)
+		}
+	}
+}
@@ -22,6 +22,8 @@ type Collector struct {
 	counters   map[string]*atomic.Int64
 	gauges     map[string]*atomic.Int64
 	histograms map[string]*Histogram
+	latency    *LatencyMonitor
+	replLag    *ReplicationLag
 	mu         sync.RWMutex
 }
 
@@ -31,6 +33,8 @@ func NewCollector() *Collector {
 		counters:   make(map[string]*atomic.Int64),
 		gauges:     make(map[string]*atomic.Int64),
 		histograms: make(map[string]*Histogram),
+		latency:    NewLatencyMonitor(),
+		replLag:    NewReplicationLag(),
 	}
 	// Pre-register latency histograms for hot-path operations
 	// Buckets in seconds: 10µs, 50µs, 100µs, 250µs, 500µs, 1ms, 2.5ms, 5ms, 10ms, 25ms, 50ms, 100ms, 250ms, 500ms, 1s
@@ -48,6 +52,11 @@ func NewCollector() *Collector {
 
 // IncCounter increments a counter by 1.
 func (c *Collector) IncCounter(name string) {
+	c.AddCounter(name, 1)
+}
+
+// AddCounter increments a counter by n.
+func (c *Collector) AddCounter(name string, n int64) {
 	c.mu.RLock()
 	ctr, ok := c.counters[name]
 	c.mu.RUnlock()
@@ -60,7 +69,7 @@ func (c *Collector) IncCounter(name string) {
 		}
 		c.mu.Unlock()
 	}
-	ctr.Add(1)
+	ctr.Add(n)
 }
 
 // SetGauge sets a gauge value.
@@ -95,8 +104,15 @@ func (c *Collector) RecordOp(op string, start time.Time) {
 	d := time.Since(start)
 	c.IncCounter("hypercache_operations_total_" + op)
 	c.ObserveLatency("hypercache_operation_duration_seconds_"+op, d)
+	c.latency.Record(op, d)
 }
 
+// Latency returns the latency monitor behind the LATENCY command.
+func (c *Collector) Latency() *LatencyMonitor { return c.latency }
+
+// ReplicationLag returns the tracker of replicated writes' lag.
+func (c *Collector) ReplicationLag() *ReplicationLag { return c.replLag }
+
 // WritePrometheus writes all metrics in Prometheus text exposition format.
 func (c *Collector) WritePrometheus(b *strings.Builder, nodeID string) {
 	c.mu.RLock()
@@ -118,6 +134,8 @@ func (c *Collector) WritePrometheus(b *strings.Builder, nodeID string) {
 	for name, h := range c.histograms {
 		h.WritePrometheus(b, name, nodeID)
 	}
+
+	c.replLag.WritePrometheus(b, nodeID)
 }
 
 // Histogram is a fixed-bucket histogram using atomic counters.
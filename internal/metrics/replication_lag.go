@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultReplicationLagThreshold is the lag above which a primary is flagged
+// as lagging
+const DefaultReplicationLagThreshold = time.Second
+
+// ReplicationLag tracks how long replicated writes take to be applied on
+// this node: the time from the primary's write to the local apply, per
+// primary and over all of them. Lag is measured against the primary's wall
+// clock, so clock skew between nodes shows up as lag; a negative lag from a
+// primary running ahead is counted as 0.
+type ReplicationLag struct {
+	threshold atomic.Int64 // ns
+
+	mu      sync.RWMutex
+	sources map[string]*lagSource // by primary node ID
+	all     lagSource
+}
+
+// lagSource holds the lag of the writes replicated from one primary
+type lagSource struct {
+	hist   LatencyHistogram
+	latest atomic.Int64 // ns
+	max    atomic.Int64 // ns
+}
+
+// record adds one replicated write's lag
+func (s *lagSource) record(lag time.Duration) {
+	s.hist.Record(lag)
+	s.latest.Store(int64(lag))
+	storeMax(&s.max, int64(lag))
+}
+
+// stats summarizes the source
+func (s *lagSource) stats(node string, threshold time.Duration) ReplicationLagStats {
+	latest := time.Duration(s.latest.Load())
+	return ReplicationLagStats{
+		Node:    node,
+		Latest:  latest,
+		Max:     time.Duration(s.max.Load()),
+		P50:     s.hist.Percentile(50),
+		P99:     s.hist.Percentile(99),
+		Count:   s.hist.Count(),
+		Lagging: s.hist.Count() > 0 && latest > threshold,
+	}
+}
+
+// ReplicationLagStats summarizes the replication lag from one primary, or
+// from all of them when Node is empty
+type ReplicationLagStats struct {
+	Node    string
+	Latest  time.Duration // lag of the most recent replicated write
+	Max     time.Duration
+	P50     time.Duration
+	P99     time.Duration
+	Count   int64
+	Lagging bool // Latest is above the threshold
+}
+
+// NewReplicationLag creates a tracker with the default threshold
+func NewReplicationLag() *ReplicationLag {
+	t := &ReplicationLag{sources: make(map[string]*lagSource)}
+	t.threshold.Store(int64(DefaultReplicationLagThreshold))
+	return t
+}
+
+// SetThreshold sets the lag above which a primary is flagged as lagging.
+// 0 or less applies the default.
+func (t *ReplicationLag) SetThreshold(d time.Duration) {
+	if d <= 0 {
+		d = DefaultReplicationLagThreshold
+	}
+	t.threshold.Store(int64(d))
+}
+
+// Threshold returns the lag above which a primary is flagged as lagging
+func (t *ReplicationLag) Threshold() time.Duration {
+	return time.Duration(t.threshold.Load())
+}
+
+// Record adds the lag of a write replicated from fromNode, written on it at
+// writtenAt and applied here now
+func (t *ReplicationLag) Record(fromNode string, writtenAt time.Time) {
+	lag := time.Since(writtenAt)
+	if lag < 0 {
+		lag = 0
+	}
+
+	t.mu.RLock()
+	source, ok := t.sources[fromNode]
+	t.mu.RUnlock()
+	if !ok {
+		t.mu.Lock()
+		source, ok = t.sources[fromNode]
+		if !ok {
+			source = &lagSource{}
+			t.sources[fromNode] = source
+		}
+		t.mu.Unlock()
+	}
+	source.record(lag)
+	t.all.record(lag)
+}
+
+// Stats returns the lag over all primaries and per primary, by node ID
+func (t *ReplicationLag) Stats() (all ReplicationLagStats, nodes []ReplicationLagStats) {
+	threshold := t.Threshold()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	nodes = make([]ReplicationLagStats, 0, len(t.sources))
+	for node, source := range t.sources {
+		nodes = append(nodes, source.stats(node, threshold))
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+	return t.all.stats("", threshold), nodes
+}
+
+// WritePrometheus writes the lag percentiles as summaries, over all
+// primaries and per primary, and a lagging flag per primary
+func (t *ReplicationLag) WritePrometheus(b *strings.Builder, nodeID string) {
+	all, nodes := t.Stats()
+	if all.Count == 0 {
+		return
+	}
+
+	b.WriteString("# TYPE hypercache_replication_lag_seconds summary\n")
+	writeLagSummary(b, "hypercache_replication_lag_seconds", fmt.Sprintf("node=\"%s\"", nodeID), all)
+	b.WriteString("# TYPE hypercache_replication_lag_by_source_seconds summary\n")
+	for _, stats := range nodes {
+		writeLagSummary(b, "hypercache_replication_lag_by_source_seconds", fmt.Sprintf("node=\"%s\",source=\"%s\"", nodeID, stats.Node), stats)
+	}
+	b.WriteString("# TYPE hypercache_replication_lagging gauge\n")
+	for _, stats := range nodes {
+		lagging := 0
+		if stats.Lagging {
+			lagging = 1
+		}
+		fmt.Fprintf(b, "hypercache_replication_lagging{node=\"%s\",source=\"%s\"} %d\n", nodeID, stats.Node, lagging)
+	}
+}
+
+// writeLagSummary writes one summary's quantiles and count
+func writeLagSummary(b *strings.Builder, name, labels string, stats ReplicationLagStats) {
+	fmt.Fprintf(b, "%s{%s,quantile=\"0.5\"} %s\n", name, labels, formatFloat(stats.P50.Seconds()))
+	fmt.Fprintf(b, "%s{%s,quantile=\"0.99\"} %s\n", name, labels, formatFloat(stats.P99.Seconds()))
+	fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, stats.Count)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplicationLag(t *testing.T) {
+	lag := NewReplicationLag()
+	lag.SetThreshold(100 * time.Millisecond)
+
+	// node-b's writes arrive promptly, node-c's 300ms after they were made
+	for i := 0; i < 10; i++ {
+		lag.Record("node-b", time.Now())
+		lag.Record("node-c", time.Now().Add(-300*time.Millisecond))
+	}
+	// A primary whose clock runs ahead doesn't produce negative lag
+	lag.Record("node-d", time.Now().Add(time.Minute))
+
+	all, nodes := lag.Stats()
+	if all.Count != 21 || len(nodes) != 3 {
+		t.Fatalf("Expected 21 writes from 3 nodes, got %d from %d", all.Count, len(nodes))
+	}
+	b, c, d := nodes[0], nodes[1], nodes[2]
+	if b.Node != "node-b" || c.Node != "node-c" || d.Node != "node-d" {
+		t.Fatalf("Expected nodes by ID, got %s, %s, %s", b.Node, c.Node, d.Node)
+	}
+	if b.P99 > 10*time.Millisecond || b.Lagging {
+		t.Errorf("Expected node-b to keep up, got p99 %v lagging %v", b.P99, b.Lagging)
+	}
+	if c.P50 < 300*time.Millisecond || c.P50 > 330*time.Millisecond || !c.Lagging {
+		t.Errorf("Expected node-c flagged with a p50 near 300ms, got %v lagging %v", c.P50, c.Lagging)
+	}
+	if d.Max != 0 || d.Lagging {
+		t.Errorf("Expected node-d's lag clamped to 0, got %v", d.Max)
+	}
+	if all.P99 < 300*time.Millisecond || all.Max < 300*time.Millisecond {
+		t.Errorf("Expected the overall p99 and max to reflect node-c, got %v and %v", all.P99, all.Max)
+	}
+
+	// Once node-c catches up it's no longer flagged
+	lag.Record("node-c", time.Now())
+	if _, nodes := lag.Stats(); nodes[1].Lagging {
+		t.Error("Expected node-c unflagged after a prompt write")
+	}
+
+	var out strings.Builder
+	lag.WritePrometheus(&out, "node-a")
+	for _, want := range []string{
+		`hypercache_replication_lag_seconds{node="node-a",quantile="0.99"}`,
+		`hypercache_replication_lag_by_source_seconds_count{node="node-a",source="node-c"} 11`,
+		`hypercache_replication_lagging{node="node-a",source="node-b"} 0`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package resp
+
+import (
+	"strconv"
+	"strings"
+
+	"hypercache/internal/logging"
+)
+
+// SetAuditLog enables key-access auditing of GET, SET and DEL. With no audit
+// log set (the default) commands are not audited.
+func (s *Server) SetAuditLog(audit *logging.AuditLog) {
+	s.audit = audit
+}
+
+// auditAccess records the keys a GET, SET or DEL command accesses. DEL
+// produces one record per key. Other commands are not audited.
+func (s *Server) auditAccess(clientConn *ClientConn, cmd Command) {
+	operation := strings.ToUpper(cmd.Name)
+	keys := cmd.Args
+	switch operation {
+	case "GET", "SET":
+		if len(keys) == 0 {
+			return
+		}
+		keys = keys[:1]
+	case "DEL", "DELETE":
+		operation = "DEL"
+	default:
+		return
+	}
+
+	store := clientConn.selectedStore
+	if store == "" {
+		store = "default"
+	}
+	record := logging.AuditRecord{
+		Operation:     operation,
+		Store:         store,
+		Protocol:      "resp",
+		ClientID:      strconv.FormatUint(clientConn.id, 10),
+		ClientName:    clientConn.name,
+		CorrelationID: logging.GetCorrelationID(clientConn.requestContext()),
+	}
+	if clientConn.conn != nil {
+		record.ClientAddr = clientConn.conn.RemoteAddr().String()
+	}
+	for _, key := range keys {
+		record.Key = key
+		s.audit.Record(record)
+	}
+}
@@ -0,0 +1,106 @@
+package resp
+
+import (
+	"fmt"
+	"strconv"
+
+	"hypercache/internal/storage"
+)
+
+// parseBitOffset parses a SETBIT/GETBIT offset argument
+func parseBitOffset(arg string) (uint64, error) {
+	offset, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil || offset > storage.MaxBitOffset {
+		return 0, storage.ErrBitOffset
+	}
+	return offset, nil
+}
+
+// handleSetBit sets or clears a bit and returns its previous value
+func (s *Server) handleSetBit(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 3 {
+		return nil, fmt.Errorf("wrong number of arguments for SETBIT")
+	}
+
+	key := cmd.Args[0]
+	offset, err := parseBitOffset(cmd.Args[1])
+	if err != nil {
+		return nil, err
+	}
+	var on bool
+	switch cmd.Args[2] {
+	case "1":
+		on = true
+	case "0":
+		on = false
+	default:
+		return nil, fmt.Errorf("bit is not an integer or out of range")
+	}
+	if err := s.checkOwnedKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	prev, err := store.SetBit(key, offset, on)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(prev)), nil
+}
+
+// handleGetBit returns the bit at an offset
+func (s *Server) handleGetBit(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for GETBIT")
+	}
+
+	key := cmd.Args[0]
+	offset, err := parseBitOffset(cmd.Args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	bit, err := store.GetBit(key, offset)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(bit)), nil
+}
+
+// handleBitCount counts set bits, optionally within a byte range
+func (s *Server) handleBitCount(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 && len(cmd.Args) != 3 {
+		return nil, fmt.Errorf("wrong number of arguments for BITCOUNT")
+	}
+
+	key := cmd.Args[0]
+	start, end := 0, -1
+	if len(cmd.Args) == 3 {
+		var err1, err2 error
+		start, err1 = strconv.Atoi(cmd.Args[1])
+		end, err2 = strconv.Atoi(cmd.Args[2])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("value is not an integer or out of range")
+		}
+	}
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	count, err := store.BitCount(key, start, end)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(count)), nil
+}
@@ -0,0 +1,487 @@
+package resp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"hypercache/internal/cluster"
+	"hypercache/internal/storage"
+)
+
+// decommissioner is implemented by coordinators that support graceful node removal
+type decommissioner interface {
+	Decommission(ctx context.Context, migrator cluster.DataMigrator, keys []string) (*cluster.RebalanceResponse, error)
+	ForgetNode(nodeID string) error
+}
+
+// commandBroadcaster is implemented by coordinators that can run control
+// commands cluster-wide
+type commandBroadcaster interface {
+	BroadcastCommand(ctx context.Context, name string, args map[string]string) error
+}
+
+// syncCommandBroadcaster is implemented by coordinators that can run a
+// control command cluster-wide and wait for every node to confirm it
+type syncCommandBroadcaster interface {
+	BroadcastCommandSync(ctx context.Context, name string, args map[string]string) (*cluster.CommandAcks, error)
+}
+
+// dbSizeQuerier is implemented by coordinators that can count keys
+// cluster-wide
+type dbSizeQuerier interface {
+	QueryClusterDBSize(ctx context.Context, store string) (*cluster.ClusterDBSize, error)
+}
+
+// slotMigrator is implemented by coordinators that can move a range of
+// hash slots to another node
+type slotMigrator interface {
+	MigrateSlotRange(ctx context.Context, migrator *cluster.LocalDataMigrator, startSlot, endSlot uint16, toNode string) (*cluster.RebalanceResponse, error)
+}
+
+// configEpochTracker is implemented by coordinators that count topology
+// changes in a config epoch
+type configEpochTracker interface {
+	ConfigEpoch() uint64
+	BumpConfigEpoch() uint64
+}
+
+// slotRouter is implemented by coordinators that place keys by hash slot
+type slotRouter interface {
+	SlotNodes(slot uint16) []string
+}
+
+// storeMigrationSource exports keys from every store on this node for
+// migration, with their values in the stored encoding
+type storeMigrationSource struct {
+	stores map[string]*storage.BasicStore // by store name, "" for the only store without a manager
+}
+
+// migrationSource returns a source over every store on this node
+func (s *Server) migrationSource() storeMigrationSource {
+	if s.storeManager == nil {
+		return storeMigrationSource{stores: map[string]*storage.BasicStore{"": s.store}}
+	}
+	stores := make(map[string]*storage.BasicStore)
+	for _, name := range s.storeManager.ListStores() {
+		if store := s.storeManager.GetStore(name); store != nil {
+			stores[name] = store
+		}
+	}
+	return storeMigrationSource{stores: stores}
+}
+
+// ExportKey implements cluster.MigrationSource
+func (m storeMigrationSource) ExportKey(key string) ([]cluster.MigrationEntry, error) {
+	var entries []cluster.MigrationEntry
+	for name, store := range m.stores {
+		item, ok := store.Export(key)
+		if !ok {
+			continue
+		}
+		entries = append(entries, cluster.MigrationEntry{
+			Store:     name,
+			Key:       key,
+			Value:     item.Value,
+			ValueType: item.ValueType,
+			SessionID: item.SessionID,
+			TTL:       item.TTL.Seconds(),
+			LamportTS: item.LamportTS,
+			Version:   item.Version,
+		})
+	}
+	return entries, nil
+}
+
+// DeleteEntry implements cluster.MigrationSource
+func (m storeMigrationSource) DeleteEntry(entry cluster.MigrationEntry) bool {
+	store, ok := m.stores[entry.Store]
+	if !ok || store.DeleteVersion(entry.Key, entry.Version) {
+		return true
+	}
+	_, exists := store.Version(entry.Key)
+	return !exists
+}
+
+// KeysInSlot implements cluster.SlotSource
+func (m storeMigrationSource) KeysInSlot(slot uint16) []string {
+	return m.uniqueKeys(func(store *storage.BasicStore) []string { return store.KeysInSlot(slot, 0) })
+}
+
+// Keys returns the keys held in any store
+func (m storeMigrationSource) Keys() []string {
+	return m.uniqueKeys((*storage.BasicStore).Keys)
+}
+
+// uniqueKeys merges the keys list returns for each store
+func (m storeMigrationSource) uniqueKeys(list func(store *storage.BasicStore) []string) []string {
+	if len(m.stores) == 1 {
+		for _, store := range m.stores {
+			return list(store)
+		}
+	}
+	seen := make(map[string]struct{})
+	var keys []string
+	for _, store := range m.stores {
+		for _, key := range list(store) {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// handleDecommission migrates the keys this node owns to their new owners and
+// leaves the cluster. Replies with the number of keys moved.
+func (s *Server) handleDecommission(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for DECOMMISSION")
+	}
+	return s.decommissionLocal(clientConn)
+}
+
+// handleCluster handles CLUSTER FORGET, BROADCAST, DBSIZE, INFO, NODES,
+// SLOTS, SHARDS, BUMPEPOCH and MIGRATESLOTS. Forgetting the local node
+// decommissions it.
+func (s *Server) handleCluster(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for CLUSTER")
+	}
+
+	switch strings.ToUpper(cmd.Args[0]) {
+	case "FORGET":
+		if len(cmd.Args) != 2 {
+			return nil, fmt.Errorf("wrong number of arguments for CLUSTER FORGET")
+		}
+		dc, ok := s.coord.(decommissioner)
+		if !ok {
+			return nil, fmt.Errorf("this node is not running in cluster mode")
+		}
+
+		nodeID := cmd.Args[1]
+		if nodeID == s.coord.GetLocalNodeID() {
+			return s.decommissionLocal(clientConn)
+		}
+		if err := dc.ForgetNode(nodeID); err != nil {
+			return nil, err
+		}
+		formatter := NewFormatter()
+		return formatter.FormatSimpleString("OK"), nil
+
+	case "BROADCAST":
+		// CLUSTER BROADCAST command [arg value ...] [SYNC]. With SYNC the
+		// reply waits until every alive node has run the command.
+		confirm := len(cmd.Args)%2 != 0 && strings.EqualFold(cmd.Args[len(cmd.Args)-1], "SYNC")
+		if confirm {
+			cmd.Args = cmd.Args[:len(cmd.Args)-1]
+		}
+		if len(cmd.Args) < 2 || len(cmd.Args)%2 != 0 {
+			return nil, fmt.Errorf("wrong number of arguments for CLUSTER BROADCAST")
+		}
+
+		name := strings.ToLower(cmd.Args[1])
+		args := make(map[string]string)
+		for i := 2; i < len(cmd.Args); i += 2 {
+			args[strings.ToLower(cmd.Args[i])] = cmd.Args[i+1]
+		}
+		if confirm {
+			return s.broadcastSync(clientConn, name, args)
+		}
+
+		broadcaster, ok := s.coord.(commandBroadcaster)
+		if !ok {
+			return nil, fmt.Errorf("this node is not running in cluster mode")
+		}
+		if err := broadcaster.BroadcastCommand(clientConn.requestContext(), name, args); err != nil {
+			return nil, err
+		}
+		formatter := NewFormatter()
+		return formatter.FormatSimpleString("OK"), nil
+
+	case "DBSIZE":
+		// Replies with the selected store's key count summed across the
+		// cluster, and 1 if some nodes didn't answer and aren't counted
+		if len(cmd.Args) != 1 {
+			return nil, fmt.Errorf("wrong number of arguments for CLUSTER DBSIZE")
+		}
+		querier, ok := s.coord.(dbSizeQuerier)
+		if !ok {
+			return nil, fmt.Errorf("this node is not running in cluster mode")
+		}
+
+		store := clientConn.selectedStore
+		if store == "" {
+			store = "default"
+		}
+		size, err := querier.QueryClusterDBSize(clientConn.requestContext(), store)
+		if err != nil {
+			return nil, err
+		}
+		partial := int64(0)
+		if size.Partial {
+			partial = 1
+		}
+		formatter := NewFormatter()
+		return formatter.FormatArray([][]byte{
+			formatter.FormatInteger(size.Total),
+			formatter.FormatInteger(partial),
+		}), nil
+
+	case "INFO", "NODES", "BUMPEPOCH":
+		if len(cmd.Args) != 1 {
+			return nil, fmt.Errorf("wrong number of arguments for CLUSTER %s", strings.ToUpper(cmd.Args[0]))
+		}
+		tracker, ok := s.coord.(configEpochTracker)
+		if !ok || s.coord.GetMembership() == nil {
+			return nil, fmt.Errorf("this node is not running in cluster mode")
+		}
+		formatter := NewFormatter()
+		switch strings.ToUpper(cmd.Args[0]) {
+		case "INFO":
+			return formatter.FormatBulkString(s.clusterInfo(tracker.ConfigEpoch())), nil
+		case "NODES":
+			return formatter.FormatBulkString(s.clusterNodes(tracker.ConfigEpoch())), nil
+		default:
+			// Makes clients watching the epoch refresh their topology
+			return formatter.FormatSimpleString(fmt.Sprintf("BUMPED %d", tracker.BumpConfigEpoch())), nil
+		}
+
+	case "SLOTS", "SHARDS":
+		if len(cmd.Args) != 1 {
+			return nil, fmt.Errorf("wrong number of arguments for CLUSTER %s", strings.ToUpper(cmd.Args[0]))
+		}
+		router, ok := s.coord.(slotRouter)
+		if !ok || s.coord.GetMembership() == nil {
+			return nil, fmt.Errorf("this node is not running in cluster mode")
+		}
+		ranges := slotRanges(router)
+		if strings.EqualFold(cmd.Args[0], "SLOTS") {
+			return s.clusterSlots(ranges), nil
+		}
+		return s.clusterShards(ranges), nil
+
+	case "MIGRATESLOTS":
+		// CLUSTER MIGRATESLOTS start end node moves the default store's
+		// keys in the slot range to node and replies with the number moved
+		if len(cmd.Args) != 4 {
+			return nil, fmt.Errorf("wrong number of arguments for CLUSTER MIGRATESLOTS")
+		}
+		startSlot, err1 := strconv.ParseUint(cmd.Args[1], 10, 16)
+		endSlot, err2 := strconv.ParseUint(cmd.Args[2], 10, 16)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid slot range")
+		}
+		return s.migrateSlots(clientConn, uint16(startSlot), uint16(endSlot), cmd.Args[3])
+
+	default:
+		return nil, fmt.Errorf("unknown CLUSTER subcommand '%s'", cmd.Args[0])
+	}
+}
+
+// clusterInfo is CLUSTER INFO's reply, the subset of Redis's fields that
+// apply without slot assignment
+func (s *Server) clusterInfo(epoch uint64) string {
+	membership := s.coord.GetMembership()
+	state := "ok"
+	if !membership.IsHealthy() {
+		state = "fail"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "cluster_state:%s\r\n", state)
+	fmt.Fprintf(&b, "cluster_known_nodes:%d\r\n", len(membership.GetMembers()))
+	fmt.Fprintf(&b, "cluster_size:%d\r\n", len(membership.GetAliveNodes()))
+	fmt.Fprintf(&b, "cluster_current_epoch:%d\r\n", epoch)
+	fmt.Fprintf(&b, "cluster_my_epoch:%d\r\n", epoch)
+	return b.String()
+}
+
+// clusterNodes is CLUSTER NODES's reply: a line per member in Redis's
+// format, by node ID. Every node is a master without slots, addressed by
+// the RESP port it advertised and its gossip port. Epochs aren't gossiped,
+// so every line carries the local config epoch.
+func (s *Server) clusterNodes(epoch uint64) string {
+	members := s.coord.GetMembership().GetMembers()
+	sort.Slice(members, func(i, j int) bool { return members[i].NodeID < members[j].NodeID })
+
+	var b strings.Builder
+	for _, member := range members {
+		flags, link := "master", "connected"
+		switch {
+		case member.NodeID == s.coord.GetLocalNodeID():
+			flags = "myself,master"
+		case member.Status == cluster.NodeSuspected:
+			flags = "master,pfail"
+		case member.Status == cluster.NodeDead:
+			flags, link = "master,fail", "disconnected"
+		}
+		respPort := member.Metadata["resp_port"]
+		if respPort == "" {
+			respPort = "0"
+		}
+		fmt.Fprintf(&b, "%s %s:%s@%d %s - 0 %d %d %s\n",
+			member.NodeID, member.Address, respPort, member.Port, flags, member.LastSeen.UnixMilli(), epoch, link)
+	}
+	return b.String()
+}
+
+// slotRange is a run of consecutive hash slots held by the same nodes
+type slotRange struct {
+	start, end uint16
+	nodes      []string // owner first, then replicas
+}
+
+// slotRanges groups every hash slot into runs held by the same nodes
+func slotRanges(router slotRouter) []slotRange {
+	var ranges []slotRange
+	for slot := 0; slot < cluster.HashSlotCount; slot++ {
+		nodes := router.SlotNodes(uint16(slot))
+		if len(nodes) == 0 {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].end == uint16(slot-1) && slices.Equal(ranges[n-1].nodes, nodes) {
+			ranges[n-1].end = uint16(slot)
+			continue
+		}
+		ranges = append(ranges, slotRange{start: uint16(slot), end: uint16(slot), nodes: nodes})
+	}
+	return ranges
+}
+
+// nodeEndpoint returns a node's address and advertised RESP port, 0 if it
+// didn't advertise one
+func (s *Server) nodeEndpoint(nodeID string) (string, int64) {
+	member, ok := s.coord.GetMembership().GetMember(nodeID)
+	if !ok {
+		return "", 0
+	}
+	port, _ := strconv.ParseInt(member.Metadata["resp_port"], 10, 64)
+	return member.Address, port
+}
+
+// clusterSlots is CLUSTER SLOTS's reply: each slot range with its owner and
+// then its replicas, as address, RESP port and node ID
+func (s *Server) clusterSlots(ranges []slotRange) []byte {
+	formatter := NewFormatter()
+	entries := make([][]byte, len(ranges))
+	for i, r := range ranges {
+		entry := [][]byte{
+			formatter.FormatInteger(int64(r.start)),
+			formatter.FormatInteger(int64(r.end)),
+		}
+		for _, nodeID := range r.nodes {
+			host, port := s.nodeEndpoint(nodeID)
+			entry = append(entry, formatter.FormatArray([][]byte{
+				formatter.FormatBulkString(host),
+				formatter.FormatInteger(port),
+				formatter.FormatBulkString(nodeID),
+			}))
+		}
+		entries[i] = formatter.FormatArray(entry)
+	}
+	return formatter.FormatArray(entries)
+}
+
+// clusterShards is CLUSTER SHARDS's reply: a shard per owner with the
+// slot ranges it owns, by node ID. A node's replicas differ from slot to
+// slot, so each shard lists only its owner; CLUSTER SLOTS has the replicas.
+func (s *Server) clusterShards(ranges []slotRange) []byte {
+	owned := make(map[string][][]byte)
+	formatter := NewFormatter()
+	for _, r := range ranges {
+		owner := r.nodes[0]
+		owned[owner] = append(owned[owner], formatter.FormatInteger(int64(r.start)), formatter.FormatInteger(int64(r.end)))
+	}
+	owners := make([]string, 0, len(owned))
+	for owner := range owned {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	shards := make([][]byte, len(owners))
+	for i, owner := range owners {
+		host, port := s.nodeEndpoint(owner)
+		health := "online"
+		if member, ok := s.coord.GetMembership().GetMember(owner); !ok || member.Status != cluster.NodeAlive {
+			health = "failed"
+		}
+		node := formatter.FormatArray([][]byte{
+			formatter.FormatBulkString("id"), formatter.FormatBulkString(owner),
+			formatter.FormatBulkString("port"), formatter.FormatInteger(port),
+			formatter.FormatBulkString("ip"), formatter.FormatBulkString(host),
+			formatter.FormatBulkString("endpoint"), formatter.FormatBulkString(host),
+			formatter.FormatBulkString("role"), formatter.FormatBulkString("master"),
+			formatter.FormatBulkString("replication-offset"), formatter.FormatInteger(0),
+			formatter.FormatBulkString("health"), formatter.FormatBulkString(health),
+		})
+		shards[i] = formatter.FormatArray([][]byte{
+			formatter.FormatBulkString("slots"), formatter.FormatArray(owned[owner]),
+			formatter.FormatBulkString("nodes"), formatter.FormatArray([][]byte{node}),
+		})
+	}
+	return formatter.FormatArray(shards)
+}
+
+// broadcastSync runs a cluster command on every node and replies OK once
+// all alive nodes have confirmed it, or with an error naming those that
+// didn't and why
+func (s *Server) broadcastSync(clientConn *ClientConn, name string, args map[string]string) ([]byte, error) {
+	broadcaster, ok := s.coord.(syncCommandBroadcaster)
+	if !ok {
+		return nil, fmt.Errorf("this node is not running in cluster mode")
+	}
+	acks, err := broadcaster.BroadcastCommandSync(clientConn.requestContext(), name, args)
+	if err != nil {
+		return nil, err
+	}
+	if !acks.OK() {
+		failed := make([]string, 0, len(acks.Failed))
+		for nodeID, reason := range acks.Failed {
+			failed = append(failed, fmt.Sprintf("%s (%s)", nodeID, reason))
+		}
+		sort.Strings(failed)
+		return nil, fmt.Errorf("%s not confirmed by %s", name, strings.Join(failed, ", "))
+	}
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("OK"), nil
+}
+
+// decommissionLocal hands off the keys of every store and leaves the cluster
+func (s *Server) decommissionLocal(clientConn *ClientConn) ([]byte, error) {
+	dc, ok := s.coord.(decommissioner)
+	if !ok || s.nodeCommunicator == nil {
+		return nil, fmt.Errorf("this node is not running in cluster mode")
+	}
+
+	source := s.migrationSource()
+	migrator := cluster.NewLocalDataMigrator(s.coord.GetLocalNodeID(), s.nodeCommunicator, source)
+	result, err := dc.Decommission(clientConn.requestContext(), migrator, source.Keys())
+	if err != nil {
+		return nil, fmt.Errorf("decommission failed: %w", err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(result.KeysMoved)), nil
+}
+
+// migrateSlots moves the keys in a slot range, from every store, to toNode
+func (s *Server) migrateSlots(clientConn *ClientConn, startSlot, endSlot uint16, toNode string) ([]byte, error) {
+	sm, ok := s.coord.(slotMigrator)
+	if !ok || s.nodeCommunicator == nil {
+		return nil, fmt.Errorf("this node is not running in cluster mode")
+	}
+
+	source := s.migrationSource()
+	migrator := cluster.NewLocalDataMigrator(s.coord.GetLocalNodeID(), s.nodeCommunicator, source)
+	result, err := sm.MigrateSlotRange(clientConn.requestContext(), migrator, startSlot, endSlot, toNode)
+	if err != nil {
+		return nil, fmt.Errorf("slot migration failed: %w", err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(result.KeysMoved)), nil
+}
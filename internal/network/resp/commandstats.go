@@ -0,0 +1,108 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errUnknownCommand is returned for command names routeCommand doesn't
+// know. They aren't counted in the command stats, so clients can't grow
+// the stats without bound by sending made-up names.
+var errUnknownCommand = errors.New("unknown command")
+
+// commandStat counts the calls of one command and the time spent in them
+type commandStat struct {
+	calls  atomic.Uint64
+	failed atomic.Uint64
+	usec   atomic.Uint64
+}
+
+// commandStats holds a commandStat per command name, created on first use.
+// The zero value is ready to use.
+type commandStats struct {
+	byName sync.Map // lower-case command name -> *commandStat
+}
+
+// CommandStat is a snapshot of one command's counters
+type CommandStat struct {
+	Name        string
+	Calls       uint64  // Times the command ran
+	FailedCalls uint64  // Calls that returned an error
+	Usec        uint64  // Total time spent running it, in microseconds
+	UsecPerCall float64 // Usec / Calls
+}
+
+// record counts a call of command that took elapsed
+func (cs *commandStats) record(command string, elapsed time.Duration, failed bool) {
+	name := strings.ToLower(command)
+	v, ok := cs.byName.Load(name)
+	if !ok {
+		v, _ = cs.byName.LoadOrStore(name, &commandStat{})
+	}
+	stat := v.(*commandStat)
+	stat.calls.Add(1)
+	stat.usec.Add(uint64(elapsed.Microseconds()))
+	if failed {
+		stat.failed.Add(1)
+	}
+}
+
+// snapshot returns the counters of every command called so far, by name
+func (cs *commandStats) snapshot() []CommandStat {
+	var stats []CommandStat
+	cs.byName.Range(func(k, v interface{}) bool {
+		stat := v.(*commandStat)
+		s := CommandStat{
+			Name:        k.(string),
+			Calls:       stat.calls.Load(),
+			FailedCalls: stat.failed.Load(),
+			Usec:        stat.usec.Load(),
+		}
+		if s.Calls > 0 {
+			s.UsecPerCall = float64(s.Usec) / float64(s.Calls)
+		}
+		stats = append(stats, s)
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// CommandStats returns per-command call counts and timings, by name
+func (s *Server) CommandStats() []CommandStat {
+	return s.cmdStats.snapshot()
+}
+
+// commandStatsInfo is INFO's commandstats section, in Redis's format
+func (s *Server) commandStatsInfo() string {
+	var b strings.Builder
+	b.WriteString("# Commandstats\n")
+	for _, stat := range s.cmdStats.snapshot() {
+		fmt.Fprintf(&b, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,failed_calls=%d\n",
+			stat.Name, stat.Calls, stat.Usec, stat.UsecPerCall, stat.FailedCalls)
+	}
+	return b.String()
+}
+
+// formatCommandStats is COMMAND STATS's reply: one entry per command of
+// [name, calls, usec, usec_per_call, failed_calls], by name
+func (s *Server) formatCommandStats() []byte {
+	formatter := NewFormatter()
+	stats := s.cmdStats.snapshot()
+	entries := make([][]byte, 0, len(stats))
+	for _, stat := range stats {
+		entries = append(entries, formatter.FormatArray([][]byte{
+			formatter.FormatBulkString(stat.Name),
+			formatter.FormatInteger(int64(stat.Calls)),
+			formatter.FormatInteger(int64(stat.Usec)),
+			formatter.FormatBulkString(fmt.Sprintf("%.2f", stat.UsecPerCall)),
+			formatter.FormatInteger(int64(stat.FailedCalls)),
+		}))
+	}
+	return formatter.FormatArray(entries)
+}
@@ -0,0 +1,66 @@
+package resp
+
+import "fmt"
+
+// handlePFAdd adds elements to a HyperLogLog
+func (s *Server) handlePFAdd(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for PFADD")
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkOwnedKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	changed, err := store.PFAdd(key, cmd.Args[1:]...)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	if changed {
+		return formatter.FormatInteger(1), nil
+	}
+	return formatter.FormatInteger(0), nil
+}
+
+// handlePFCount returns the approximate cardinality of one or more HyperLogLogs
+func (s *Server) handlePFCount(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for PFCOUNT")
+	}
+
+	if crossSlot, err := s.checkLocalKeys(false, cmd.Args...); err != nil || crossSlot != nil {
+		return crossSlot, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	count, err := store.PFCount(cmd.Args...)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(count)), nil
+}
+
+// handlePFMerge merges HyperLogLogs into a destination key
+func (s *Server) handlePFMerge(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for PFMERGE")
+	}
+
+	if crossSlot, err := s.checkLocalKeys(true, cmd.Args...); err != nil || crossSlot != nil {
+		return crossSlot, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	if err := store.PFMerge(cmd.Args[0], cmd.Args[1:]...); err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("OK"), nil
+}
@@ -0,0 +1,64 @@
+package resp
+
+import (
+	"fmt"
+	"strings"
+
+	"hypercache/internal/metrics"
+)
+
+// handleLatency implements LATENCY LATEST, LATENCY HISTORY event and
+// LATENCY RESET [event ...]. Durations are reported in microseconds, since
+// most operations complete well under a millisecond.
+//
+// LATENCY LATEST replies with one entry per event:
+// [event, unix time of latest sample, latest, max, p50, p99].
+// LATENCY HISTORY replies with [unix time, max] per second, oldest first.
+func (s *Server) handleLatency(cmd Command) ([]byte, error) {
+	if len(cmd.Args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for LATENCY")
+	}
+
+	monitor := metrics.Global().Latency()
+	formatter := NewFormatter()
+
+	switch strings.ToUpper(cmd.Args[0]) {
+	case "LATEST":
+		if len(cmd.Args) != 1 {
+			return nil, fmt.Errorf("wrong number of arguments for LATENCY LATEST")
+		}
+		latest := monitor.Latest()
+		entries := make([][]byte, 0, len(latest))
+		for _, stats := range latest {
+			entries = append(entries, formatter.FormatArray([][]byte{
+				formatter.FormatBulkString(stats.Event),
+				formatter.FormatInteger(stats.LatestAt.Unix()),
+				formatter.FormatInteger(stats.Latest.Microseconds()),
+				formatter.FormatInteger(stats.Max.Microseconds()),
+				formatter.FormatInteger(stats.P50.Microseconds()),
+				formatter.FormatInteger(stats.P99.Microseconds()),
+			}))
+		}
+		return formatter.FormatArray(entries), nil
+
+	case "HISTORY":
+		if len(cmd.Args) != 2 {
+			return nil, fmt.Errorf("wrong number of arguments for LATENCY HISTORY")
+		}
+		samples, _ := monitor.History(cmd.Args[1])
+		entries := make([][]byte, 0, len(samples))
+		for _, sample := range samples {
+			entries = append(entries, formatter.FormatArray([][]byte{
+				formatter.FormatInteger(sample.Timestamp.Unix()),
+				formatter.FormatInteger(sample.Max.Microseconds()),
+			}))
+		}
+		return formatter.FormatArray(entries), nil
+
+	case "RESET":
+		return formatter.FormatInteger(int64(monitor.Reset(cmd.Args[1:]...))), nil
+
+	default:
+		return nil, fmt.Errorf("unknown LATENCY subcommand '%s'", cmd.Args[0])
+	}
+}
@@ -0,0 +1,102 @@
+package resp
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// handlePush handles LPUSH and RPUSH
+func (s *Server) handlePush(clientConn *ClientConn, cmd Command, left bool) ([]byte, error) {
+	if len(cmd.Args) < 2 {
+		return nil, fmt.Errorf("wrong number of arguments for %s", cmd.Name)
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkOwnedKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	length, err := store.ListPush(key, left, cmd.Args[1:]...)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(length)), nil
+}
+
+// handlePop handles LPOP and RPOP
+func (s *Server) handlePop(clientConn *ClientConn, cmd Command, left bool) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for %s", cmd.Name)
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkOwnedKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	elem, ok, err := store.ListPop(key, left)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	if !ok {
+		return formatter.FormatNull(), nil
+	}
+	return formatter.FormatBulkString(elem), nil
+}
+
+// handleLRange returns a range of list elements
+func (s *Server) handleLRange(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 3 {
+		return nil, fmt.Errorf("wrong number of arguments for LRANGE")
+	}
+
+	key := cmd.Args[0]
+	start, err1 := strconv.Atoi(cmd.Args[1])
+	stop, err2 := strconv.Atoi(cmd.Args[2])
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	elems, err := store.ListRange(key, start, stop)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	items := make([][]byte, len(elems))
+	for i, elem := range elems {
+		items[i] = formatter.FormatBulkString(elem)
+	}
+	return formatter.FormatArray(items), nil
+}
+
+// handleLLen returns the length of a list
+func (s *Server) handleLLen(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for LLEN")
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	length, err := store.ListLen(key)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(length)), nil
+}
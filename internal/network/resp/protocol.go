@@ -162,7 +162,7 @@ func (p *Parser) parseBulkString() (*Value, error) {
 	}
 
 	length, err := strconv.Atoi(line)
-	if err != nil {
+	if err != nil || length < -1 {
 		return nil, fmt.Errorf("invalid bulk string length: %s", line)
 	}
 
@@ -175,43 +175,20 @@ func (p *Parser) parseBulkString() (*Value, error) {
 		}, nil
 	}
 
-	// Handle empty bulk string
-	if length == 0 {
-		// Still need to consume the \r\n
-		_, err := p.readLine()
-		if err != nil {
-			return nil, err
-		}
-		return &Value{
-			Type: TypeBulkString,
-			Str:  "",
-			Raw:  []byte("$0\r\n\r\n"),
-		}, nil
-	}
-
-	// Read the string data
-	data := make([]byte, length)
-	_, err = io.ReadFull(p.reader, data)
-	if err != nil {
-		return nil, err
-	}
-
-	// Read the trailing \r\n
-	crlf := make([]byte, 2)
-	_, err = io.ReadFull(p.reader, crlf)
-	if err != nil {
+	// Read the string data and its trailing CRLF straight into the raw value
+	raw := appendHeader(make([]byte, 0, length+16), TypeBulkString, int64(length))
+	header := len(raw)
+	raw = raw[:header+length+2]
+	if _, err := io.ReadFull(p.reader, raw[header:]); err != nil {
 		return nil, err
 	}
-
-	if crlf[0] != '\r' || crlf[1] != '\n' {
+	if raw[len(raw)-2] != '\r' || raw[len(raw)-1] != '\n' {
 		return nil, fmt.Errorf("expected CRLF after bulk string")
 	}
 
-	raw := []byte(fmt.Sprintf("$%d\r\n%s\r\n", length, data))
-
 	return &Value{
 		Type: TypeBulkString,
-		Str:  string(data),
+		Str:  string(raw[header : header+length]),
 		Raw:  raw,
 	}, nil
 }
@@ -224,7 +201,7 @@ func (p *Parser) parseArray() (*Value, error) {
 	}
 
 	length, err := strconv.Atoi(line)
-	if err != nil {
+	if err != nil || length < -1 {
 		return nil, fmt.Errorf("invalid array length: %s", line)
 	}
 
@@ -248,22 +225,20 @@ func (p *Parser) parseArray() (*Value, error) {
 
 	// Parse array elements
 	elements := make([]Value, length)
-	var rawBuilder strings.Builder
-	rawBuilder.WriteString(fmt.Sprintf("*%d\r\n", length))
-
+	raw := appendHeader(make([]byte, 0, 64), TypeArray, int64(length))
 	for i := 0; i < length; i++ {
 		element, err := p.parseValue()
 		if err != nil {
 			return nil, err
 		}
 		elements[i] = *element
-		rawBuilder.Write(element.Raw)
+		raw = append(raw, element.Raw...)
 	}
 
 	return &Value{
 		Type:  TypeArray,
 		Array: elements,
-		Raw:   []byte(rawBuilder.String()),
+		Raw:   raw,
 	}, nil
 }
 
@@ -282,64 +257,117 @@ func (p *Parser) readLine() (string, error) {
 	return line[:len(line)-2], nil
 }
 
-// Formatter handles RESP response formatting
+// Formatter handles RESP response formatting. It is stateless, so all
+// callers share one instance. The Append methods append a reply to a buffer
+// the caller reuses; the Format methods allocate a buffer for each reply.
 type Formatter struct{}
 
-// NewFormatter creates a new RESP formatter
+// sharedFormatter is the instance returned by NewFormatter
+var sharedFormatter = &Formatter{}
+
+// NewFormatter returns the RESP formatter. It does not allocate, so handlers
+// may call it freely.
 func NewFormatter() *Formatter {
-	return &Formatter{}
+	return sharedFormatter
+}
+
+// appendHeader appends a type prefix, a length or integer and CRLF
+func appendHeader(buf []byte, prefix byte, n int64) []byte {
+	buf = append(buf, prefix)
+	buf = strconv.AppendInt(buf, n, 10)
+	return append(buf, '\r', '\n')
+}
+
+// AppendSimpleString appends a simple string response to dst
+func (f *Formatter) AppendSimpleString(dst []byte, s string) []byte {
+	dst = append(dst, '+')
+	dst = append(dst, s...)
+	return append(dst, '\r', '\n')
+}
+
+// AppendError appends an error response to dst
+func (f *Formatter) AppendError(dst []byte, err string) []byte {
+	dst = append(dst, '-')
+	dst = append(dst, err...)
+	return append(dst, '\r', '\n')
+}
+
+// AppendInteger appends an integer response to dst
+func (f *Formatter) AppendInteger(dst []byte, i int64) []byte {
+	return appendHeader(dst, ':', i)
+}
+
+// AppendBulkString appends a bulk string response to dst
+func (f *Formatter) AppendBulkString(dst []byte, s string) []byte {
+	dst = appendHeader(dst, '$', int64(len(s)))
+	dst = append(dst, s...)
+	return append(dst, '\r', '\n')
+}
+
+// AppendBulkBytes appends a bulk bytes response to dst
+func (f *Formatter) AppendBulkBytes(dst []byte, data []byte) []byte {
+	dst = appendHeader(dst, '$', int64(len(data)))
+	dst = append(dst, data...)
+	return append(dst, '\r', '\n')
+}
+
+// AppendNull appends a null bulk string response to dst
+func (f *Formatter) AppendNull(dst []byte) []byte {
+	return append(dst, "$-1\r\n"...)
+}
+
+// AppendArrayHeader appends the header of an array of n elements to dst,
+// for the caller to append the elements after
+func (f *Formatter) AppendArrayHeader(dst []byte, n int) []byte {
+	return appendHeader(dst, '*', int64(n))
 }
 
 // FormatSimpleString formats a simple string response
 func (f *Formatter) FormatSimpleString(s string) []byte {
-	return []byte(fmt.Sprintf("+%s\r\n", s))
+	return f.AppendSimpleString(make([]byte, 0, len(s)+3), s)
 }
 
 // FormatError formats an error response
 func (f *Formatter) FormatError(err string) []byte {
-	return []byte(fmt.Sprintf("-%s\r\n", err))
+	return f.AppendError(make([]byte, 0, len(err)+3), err)
 }
 
 // FormatInteger formats an integer response
 func (f *Formatter) FormatInteger(i int64) []byte {
-	return []byte(fmt.Sprintf(":%d\r\n", i))
+	return f.AppendInteger(make([]byte, 0, 24), i)
 }
 
 // FormatBulkString formats a bulk string response
 func (f *Formatter) FormatBulkString(s string) []byte {
-	if s == "" {
-		return []byte("$0\r\n\r\n")
-	}
-	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(s), s))
+	return f.AppendBulkString(make([]byte, 0, len(s)+16), s)
 }
 
 // FormatBulkBytes formats bulk bytes response
 func (f *Formatter) FormatBulkBytes(data []byte) []byte {
-	if len(data) == 0 {
-		return []byte("$0\r\n\r\n")
-	}
-	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(data), data))
+	return f.AppendBulkBytes(make([]byte, 0, len(data)+16), data)
 }
 
 // FormatNull formats a null bulk string response
 func (f *Formatter) FormatNull() []byte {
-	return []byte("$-1\r\n")
+	return f.AppendNull(make([]byte, 0, 5))
+}
+
+// FormatNullArray formats a null array response
+func (f *Formatter) FormatNullArray() []byte {
+	return []byte("*-1\r\n")
 }
 
 // FormatArray formats an array response
 func (f *Formatter) FormatArray(elements [][]byte) []byte {
-	if len(elements) == 0 {
-		return []byte("*0\r\n")
+	size := 16
+	for _, element := range elements {
+		size += len(element)
 	}
-
-	var result strings.Builder
-	result.WriteString(fmt.Sprintf("*%d\r\n", len(elements)))
-
+	buf := f.AppendArrayHeader(make([]byte, 0, size), len(elements))
 	for _, element := range elements {
-		result.Write(element)
+		buf = append(buf, element...)
 	}
-
-	return []byte(result.String())
+	return buf
 }
 
 // Command represents a parsed Redis command
@@ -361,6 +361,42 @@ func TestFormatter_Array(t *testing.T) {
 	}
 }
 
+// replySink keeps formatted replies on the heap, as queued replies are
+var replySink []byte
+
+func TestFormatter_Allocations(t *testing.T) {
+	data := []byte("hello")
+	allocs := testing.AllocsPerRun(100, func() {
+		replySink = NewFormatter().FormatBulkBytes(data)
+	})
+	if allocs != 1 {
+		t.Errorf("FormatBulkBytes allocated %v times per reply, want 1", allocs)
+	}
+
+	allocs = testing.AllocsPerRun(100, func() {
+		replySink = NewFormatter().FormatArray([][]byte{data, data})
+	})
+	if allocs != 1 {
+		t.Errorf("FormatArray allocated %v times per reply, want 1", allocs)
+	}
+
+	// Appending to a reused buffer allocates nothing once it has grown
+	buf := make([]byte, 0, 64)
+	allocs = testing.AllocsPerRun(100, func() {
+		formatter := NewFormatter()
+		buf = formatter.AppendArrayHeader(buf[:0], 3)
+		buf = formatter.AppendBulkBytes(buf, data)
+		buf = formatter.AppendInteger(buf, 42)
+		buf = formatter.AppendNull(buf)
+	})
+	if allocs != 0 {
+		t.Errorf("Append methods allocated %v times per reply, want 0", allocs)
+	}
+	if want := "*3\r\n$5\r\nhello\r\n:42\r\n$-1\r\n"; string(buf) != want {
+		t.Errorf("Appended reply = %q, want %q", buf, want)
+	}
+}
+
 func TestValue_TypeCheckers(t *testing.T) {
 	tests := []struct {
 		value    Value
@@ -496,3 +532,33 @@ func BenchmarkFormatter_BulkString(b *testing.B) {
 		formatter.FormatBulkString("hello world test data")
 	}
 }
+
+func BenchmarkFormatter_BulkBytes(b *testing.B) {
+	data := []byte(strings.Repeat("x", 64))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewFormatter().FormatBulkBytes(data)
+	}
+}
+
+func BenchmarkFormatter_Integer(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewFormatter().FormatInteger(int64(i))
+	}
+}
+
+func BenchmarkFormatter_Array(b *testing.B) {
+	formatter := NewFormatter()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		formatter.FormatArray([][]byte{
+			formatter.FormatBulkString("total_connections:10"),
+			formatter.FormatBulkString("active_connections:2"),
+			formatter.FormatInteger(42),
+			formatter.FormatNull(),
+		})
+	}
+}
@@ -0,0 +1,432 @@
+package resp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"hypercache/internal/logging"
+)
+
+// DefaultPubSubBuffer is how many messages may wait for a subscriber that
+// isn't reading before it is disconnected, as Redis's pubsub client output
+// buffer limit
+const DefaultPubSubBuffer = 1024
+
+// subscriberOutbox queues the messages for one subscriber. Publishers only
+// ever enqueue without blocking; a goroutine per subscriber writes them to
+// the connection, so a stalled subscriber can't hold up the store writes
+// that publish keyspace events.
+type subscriberOutbox struct {
+	messages chan []byte
+	done     chan struct{}
+	once     sync.Once
+}
+
+// stop ends delivery to the subscriber
+func (o *subscriberOutbox) stop() {
+	o.once.Do(func() { close(o.done) })
+}
+
+// deliver writes queued messages to the subscriber until the outbox is
+// stopped or a write fails
+func (o *subscriberOutbox) deliver(clientConn *ClientConn) {
+	for {
+		select {
+		case msg := <-o.messages:
+			if _, err := clientConn.write(msg); err != nil {
+				o.stop()
+				clientConn.conn.Close()
+				return
+			}
+		case <-o.done:
+			return
+		}
+	}
+}
+
+// PubSub tracks channel subscriptions and fans out published messages
+// to subscribed client connections.
+type PubSub struct {
+	channels map[string]map[*ClientConn]struct{}
+	mu       sync.RWMutex
+}
+
+// NewPubSub creates an empty pub/sub hub
+func NewPubSub() *PubSub {
+	return &PubSub{
+		channels: make(map[string]map[*ClientConn]struct{}),
+	}
+}
+
+// Subscribe adds the client to a channel and returns the client's subscription count
+func (ps *PubSub) Subscribe(clientConn *ClientConn, channel string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	subs, ok := ps.channels[channel]
+	if !ok {
+		subs = make(map[*ClientConn]struct{})
+		ps.channels[channel] = subs
+	}
+	subs[clientConn] = struct{}{}
+
+	if clientConn.subscriptions == nil {
+		clientConn.subscriptions = make(map[string]struct{})
+	}
+	clientConn.subscriptions[channel] = struct{}{}
+	if clientConn.outbox == nil {
+		clientConn.outbox = &subscriberOutbox{
+			messages: make(chan []byte, DefaultPubSubBuffer),
+			done:     make(chan struct{}),
+		}
+		go clientConn.outbox.deliver(clientConn)
+	}
+	return len(clientConn.subscriptions)
+}
+
+// Unsubscribe removes the client from a channel and returns the client's remaining subscription count
+func (ps *PubSub) Unsubscribe(clientConn *ClientConn, channel string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if subs, ok := ps.channels[channel]; ok {
+		delete(subs, clientConn)
+		if len(subs) == 0 {
+			delete(ps.channels, channel)
+		}
+	}
+	delete(clientConn.subscriptions, channel)
+	return len(clientConn.subscriptions)
+}
+
+// Channels returns the channels the client is subscribed to
+func (ps *PubSub) Channels(clientConn *ClientConn) []string {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	channels := make([]string, 0, len(clientConn.subscriptions))
+	for channel := range clientConn.subscriptions {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// SubscriptionCount returns how many channels the client is subscribed to
+func (ps *PubSub) SubscriptionCount(clientConn *ClientConn) int {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return len(clientConn.subscriptions)
+}
+
+// UnsubscribeAll removes the client from every channel and stops delivery
+// to it (used on disconnect and RESET)
+func (ps *PubSub) UnsubscribeAll(clientConn *ClientConn) {
+	for _, channel := range ps.Channels(clientConn) {
+		ps.Unsubscribe(clientConn, channel)
+	}
+
+	ps.mu.Lock()
+	outbox := clientConn.outbox
+	clientConn.outbox = nil
+	ps.mu.Unlock()
+	if outbox != nil {
+		outbox.stop()
+	}
+}
+
+// Publish queues a message for every subscriber of channel and returns the
+// receiver count. It never blocks: a subscriber with DefaultPubSubBuffer
+// messages already waiting is disconnected instead, and isn't counted.
+func (ps *PubSub) Publish(channel, message string) int {
+	type subscriber struct {
+		clientConn *ClientConn
+		outbox     *subscriberOutbox
+	}
+	ps.mu.RLock()
+	subs := make([]subscriber, 0, len(ps.channels[channel]))
+	for clientConn := range ps.channels[channel] {
+		if clientConn.outbox != nil {
+			subs = append(subs, subscriber{clientConn, clientConn.outbox})
+		}
+	}
+	ps.mu.RUnlock()
+
+	if len(subs) == 0 {
+		return 0
+	}
+
+	formatter := NewFormatter()
+	payload := formatter.FormatArray([][]byte{
+		formatter.FormatBulkString("message"),
+		formatter.FormatBulkString(channel),
+		formatter.FormatBulkString(message),
+	})
+
+	delivered := 0
+	for _, sub := range subs {
+		select {
+		case <-sub.outbox.done:
+		case sub.outbox.messages <- payload:
+			delivered++
+		default:
+			// Closing the connection unblocks a pending write and ends
+			// its command loop, which unsubscribes it
+			logging.Warn(nil, logging.ComponentRESP, logging.ActionDisconnect, "Subscriber fell too far behind, disconnecting it", map[string]interface{}{
+				"remote_addr": sub.clientConn.conn.RemoteAddr().String(),
+				"channel":     channel,
+			})
+			sub.outbox.stop()
+			sub.clientConn.conn.Close()
+		}
+	}
+	return delivered
+}
+
+// Keyspace notification classes (subset of Redis notify-keyspace-events)
+const (
+	notifyKeyspace = 1 << iota // K: __keyspace@<db>__:<key> channel
+	notifyKeyevent             // E: __keyevent@<db>__:<event> channel
+	notifyGeneric              // g: del
+	notifyString               // $: set
+	notifyExpired              // x: expired
+	notifyEvicted              // e: evicted
+)
+
+// parseKeyspaceEventFlags parses a notify-keyspace-events flag string such as "KEA".
+// An empty string disables notifications.
+func parseKeyspaceEventFlags(flags string) (int, error) {
+	mask := 0
+	for _, c := range flags {
+		switch c {
+		case 'K':
+			mask |= notifyKeyspace
+		case 'E':
+			mask |= notifyKeyevent
+		case 'g':
+			mask |= notifyGeneric
+		case '$':
+			mask |= notifyString
+		case 'x':
+			mask |= notifyExpired
+		case 'e':
+			mask |= notifyEvicted
+		case 'A':
+			mask |= notifyGeneric | notifyString | notifyExpired | notifyEvicted
+		default:
+			return 0, fmt.Errorf("invalid notify-keyspace-events flag '%c'", c)
+		}
+	}
+
+	// Without K or E nothing is ever published
+	if mask&(notifyKeyspace|notifyKeyevent) == 0 {
+		return 0, nil
+	}
+	return mask, nil
+}
+
+// keyspaceEventClass maps a store mutation event to its notification class
+func keyspaceEventClass(event string) int {
+	switch event {
+	case "set":
+		return notifyString
+	case "del", "expire", "persist":
+		return notifyGeneric
+	case "expired":
+		return notifyExpired
+	case "evicted":
+		return notifyEvicted
+	default:
+		return 0
+	}
+}
+
+// keyspaceDB returns the database label used in notification channel names.
+// The default store maps to db 0; other stores use their name.
+func keyspaceDB(storeName string) string {
+	if storeName == "" || storeName == "default" {
+		return "0"
+	}
+	return storeName
+}
+
+// SetNotifyKeyspaceEvents configures keyspace notifications using Redis
+// notify-keyspace-events flags (e.g. "KEA"). An empty string disables them.
+// Notifications are wired at the store-mutation boundary, so writes arriving
+// over HTTP or replication are published as well as RESP writes.
+func (s *Server) SetNotifyKeyspaceEvents(flags string) error {
+	mask, err := parseKeyspaceEventFlags(flags)
+	if err != nil {
+		return err
+	}
+	s.notifyFlags.Store(int64(mask))
+	s.notifyFlagsRaw.Store(flags)
+	s.updateKeyspaceNotifier()
+	return nil
+}
+
+// updateKeyspaceNotifier installs the store mutation callback while keyspace
+// notifications are enabled or replicas are attached, and removes it otherwise
+func (s *Server) updateKeyspaceNotifier() {
+	if s.notifyFlags.Load() == 0 && s.replicaCount() == 0 {
+		if s.storeManager != nil {
+			s.storeManager.SetKeyspaceNotifier(nil)
+		}
+		if s.store != nil {
+			s.store.SetKeyspaceNotifier(nil)
+		}
+		return
+	}
+
+	if s.storeManager != nil {
+		s.storeManager.SetKeyspaceNotifier(s.handleKeyspaceEvent)
+	}
+	if s.store != nil && (s.storeManager == nil || s.storeManager.GetStore("default") != s.store) {
+		s.store.SetKeyspaceNotifier(func(event, key string) { s.handleKeyspaceEvent("default", event, key) })
+	}
+}
+
+// handleKeyspaceEvent receives every store mutation: it is published to
+// keyspace subscribers and, for the default store, queued for replicas
+func (s *Server) handleKeyspaceEvent(storeName, event, key string) {
+	s.publishKeyspaceEvent(storeName, event, key)
+	if storeName == "default" {
+		s.feedReplicas(event, key)
+	}
+}
+
+// publishKeyspaceEvent publishes a store mutation to the keyspace/keyevent channels
+func (s *Server) publishKeyspaceEvent(storeName, event, key string) {
+	mask := int(s.notifyFlags.Load())
+	if mask&keyspaceEventClass(event) == 0 {
+		return
+	}
+
+	db := keyspaceDB(storeName)
+	if mask&notifyKeyspace != 0 {
+		s.pubsub.Publish("__keyspace@"+db+"__:"+key, event)
+	}
+	if mask&notifyKeyevent != 0 {
+		s.pubsub.Publish("__keyevent@"+db+"__:"+event, key)
+	}
+}
+
+// handleSubscribe subscribes the client to one or more channels
+func (s *Server) handleSubscribe(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for SUBSCRIBE")
+	}
+
+	formatter := NewFormatter()
+	var out []byte
+	for _, channel := range cmd.Args {
+		count := s.pubsub.Subscribe(clientConn, channel)
+		out = append(out, formatter.FormatArray([][]byte{
+			formatter.FormatBulkString("subscribe"),
+			formatter.FormatBulkString(channel),
+			formatter.FormatInteger(int64(count)),
+		})...)
+	}
+	return out, nil
+}
+
+// handleUnsubscribe unsubscribes the client from the given channels, or all channels if none are given
+func (s *Server) handleUnsubscribe(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	formatter := NewFormatter()
+
+	channels := cmd.Args
+	if len(channels) == 0 {
+		channels = s.pubsub.Channels(clientConn)
+	}
+
+	if len(channels) == 0 {
+		return formatter.FormatArray([][]byte{
+			formatter.FormatBulkString("unsubscribe"),
+			formatter.FormatNull(),
+			formatter.FormatInteger(0),
+		}), nil
+	}
+
+	var out []byte
+	for _, channel := range channels {
+		count := s.pubsub.Unsubscribe(clientConn, channel)
+		out = append(out, formatter.FormatArray([][]byte{
+			formatter.FormatBulkString("unsubscribe"),
+			formatter.FormatBulkString(channel),
+			formatter.FormatInteger(int64(count)),
+		})...)
+	}
+	return out, nil
+}
+
+// handlePublish publishes a message and returns the number of receivers
+func (s *Server) handlePublish(cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for PUBLISH")
+	}
+
+	receivers := s.pubsub.Publish(cmd.Args[0], cmd.Args[1])
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(receivers)), nil
+}
+
+// allowedInSubscribedMode reports whether a command may run on a connection with active subscriptions
+func allowedInSubscribedMode(name string) bool {
+	switch strings.ToUpper(name) {
+	case "SUBSCRIBE", "UNSUBSCRIBE", "PING", "QUIT", "RESET":
+		return true
+	}
+	return false
+}
+
+// write sends a response to the client immediately, along with any queued
+// replies ahead of it, serializing with asynchronous pub/sub deliveries
+func (c *ClientConn) write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.writer == nil {
+		return c.conn.Write(b)
+	}
+	n, err := c.writer.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, c.writer.Flush()
+}
+
+// queue buffers a reply without sending it. Queued replies go out when the
+// connection next waits for input (see flushingReader) or on the next write,
+// so a pipelined batch is answered with as few syscalls as possible.
+func (c *ClientConn) queue(b []byte) (int, error) {
+	if c.writer == nil {
+		return c.write(b)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writer.Write(b)
+}
+
+// flush sends any queued replies
+func (c *ClientConn) flush() error {
+	if c.writer == nil {
+		return nil
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writer.Flush()
+}
+
+// flushingReader reads from a client connection, first sending any queued
+// replies. The command reader only reads from the network once its buffer of
+// pipelined commands is empty, so replies are never held while the server
+// waits for the client.
+type flushingReader struct {
+	c *ClientConn
+}
+
+func (r flushingReader) Read(p []byte) (int, error) {
+	if err := r.c.flush(); err != nil {
+		return 0, err
+	}
+	return r.c.conn.Read(p)
+}
@@ -0,0 +1,51 @@
+package resp
+
+import (
+	"fmt"
+
+	"hypercache/internal/cluster"
+)
+
+// Key routing modes: how a node answers for keys another node owns
+const (
+	KeyRoutingProxy    = "proxy"    // run the command on the owner and relay its reply (default)
+	KeyRoutingRedirect = "redirect" // reply MOVED so a cluster-aware client retries at the owner
+)
+
+// MovedError redirects a client to the node owning a key, as Redis
+// Cluster's MOVED. It is sent as is, without the ERR prefix.
+type MovedError struct {
+	Slot    uint16
+	Address string // owner's RESP address
+}
+
+// Error formats the redirect as Redis Cluster does
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("MOVED %d %s", e.Slot, e.Address)
+}
+
+// SetKeyRouting sets how keys owned by another node are handled, proxy
+// (the default) or redirect. Proxying costs a hop but works with clients
+// that don't understand MOVED.
+func (s *Server) SetKeyRouting(mode string) {
+	s.keyRouting = mode
+}
+
+// redirect returns a *MovedError for a key owned by another node when the
+// server redirects, nil otherwise. The ring places keys by slot, so the
+// owner holds every key in the slot the error names. An owner that didn't advertise its RESP
+// address can't be redirected to, so its keys are handled as in proxy mode.
+func (s *Server) redirect(key string) error {
+	if s.keyRouting != KeyRoutingRedirect || s.nodeCommunicator == nil {
+		return nil
+	}
+	routing := s.coord.GetRouting()
+	if routing.IsLocal(key) || routing.IsReplica(key) {
+		return nil
+	}
+	address := s.nodeCommunicator.NodeRESPAddress(routing.RouteKey(key))
+	if address == "" {
+		return nil
+	}
+	return &MovedError{Slot: cluster.GetHashSlot(key), Address: address}
+}
@@ -0,0 +1,629 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
+	"hypercache/internal/storage"
+)
+
+const (
+	// replicaFeedBuffer is how many pending key changes a replica may fall
+	// behind by before the primary drops it; the replica then resyncs
+	replicaFeedBuffer = 10000
+
+	// replicaPingInterval keeps the replica's connection inside the primary's
+	// command timeout while no commands are being sent
+	replicaPingInterval = 10 * time.Second
+
+	// replicaReadTimeout is how long a replica waits for data from its primary
+	// before treating the link as down
+	replicaReadTimeout = 30 * time.Second
+
+	// replicaRetryInterval is the pause between reconnection attempts
+	replicaRetryInterval = time.Second
+)
+
+// ErrReadOnlyReplica is returned for client writes while the node is a replica
+var ErrReadOnlyReplica = errors.New("READONLY You can't write against a read only replica.")
+
+// replicaFeed streams the primary's changes to one replica connection.
+// Changes are queued as keys; the current state of each key is sent when it
+// is dequeued, so a burst of writes to one key collapses into its final value.
+type replicaFeed struct {
+	clientConn    *ClientConn
+	listeningPort string             // announced by the replica with REPLCONF
+	events        chan replicaChange // pending changes
+	done          chan struct{}
+	closeOnce     sync.Once
+
+	online atomic.Bool  // snapshot sent, streaming live changes
+	offset atomic.Int64 // replication offset of the last change sent
+}
+
+// replicaChange is a change of the default store queued for a replica
+type replicaChange struct {
+	key    string // changed key; "" means the store was flushed
+	offset int64  // replication offset of the change
+}
+
+// close stops the feed and drops the replica connection
+func (f *replicaFeed) close() {
+	f.closeOnce.Do(func() {
+		close(f.done)
+		f.clientConn.conn.Close()
+	})
+}
+
+// replicaLink is this node's connection to its primary
+type replicaLink struct {
+	host   string
+	port   string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	up      atomic.Bool  // connected and receiving the stream
+	syncing atomic.Bool  // initial snapshot not fully received yet
+	lastIO  atomic.Int64 // unix time of the last data from the primary
+	applied atomic.Int64 // commands applied from the primary since the last resync
+}
+
+// replicationStore returns the store that is replicated: the default store
+func (s *Server) replicationStore() *storage.BasicStore {
+	if s.storeManager != nil {
+		if st := s.storeManager.GetStore("default"); st != nil {
+			return st
+		}
+	}
+	return s.store
+}
+
+// isReplica reports whether the node is replicating from a primary
+func (s *Server) isReplica() bool {
+	s.replMu.Lock()
+	defer s.replMu.Unlock()
+	return s.primary != nil
+}
+
+// isWriteCommand reports whether a command modifies data and must be
+// rejected on a replica
+func isWriteCommand(name string) bool {
+	switch strings.ToUpper(name) {
+	case "SET", "CAS", "DEL", "DELETE", "GETDEL", "DELSESSION", "EXPIRE", "PERSIST", "PIN", "UNPIN",
+		"LPUSH", "RPUSH", "LPOP", "RPOP",
+		"SADD", "SREM", "SINTERSTORE", "SUNIONSTORE", "SDIFFSTORE",
+		"SETBIT", "PFADD", "PFMERGE", "FLUSHALL":
+		return true
+	}
+	return false
+}
+
+// handleSync turns the connection into a replication stream. The primary
+// replies +FULLRESYNC, sends every key of the default store as commands,
+// replies +CONTINUE once the snapshot is complete and then sends each change
+// as it happens. The replica may keep sending PING to hold the connection open.
+func (s *Server) handleSync(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for SYNC")
+	}
+
+	s.replMu.Lock()
+	if _, ok := s.replicas[clientConn]; ok {
+		s.replMu.Unlock()
+		return nil, fmt.Errorf("replication stream already started")
+	}
+	feed := &replicaFeed{
+		clientConn:    clientConn,
+		listeningPort: clientConn.replicaPort,
+		events:        make(chan replicaChange, replicaFeedBuffer),
+		done:          make(chan struct{}),
+	}
+	feed.offset.Store(s.replOffset.Load())
+	if s.replicas == nil {
+		s.replicas = make(map[*ClientConn]*replicaFeed)
+	}
+	s.replicas[clientConn] = feed
+	s.replMu.Unlock()
+
+	// Changes are captured from here on, so nothing written during the
+	// snapshot is missed
+	s.updateKeyspaceNotifier()
+
+	formatter := NewFormatter()
+	if _, err := clientConn.write(formatter.FormatSimpleString("FULLRESYNC")); err != nil {
+		s.detachReplica(clientConn)
+		return nil, fmt.Errorf("failed to start replication: %w", err)
+	}
+
+	logging.Info(clientConn.requestContext(), logging.ComponentRESP, logging.ActionReplication, "Replica attached", map[string]interface{}{
+		"remote_addr": clientConn.conn.RemoteAddr().String(),
+	})
+
+	s.wg.Add(1)
+	go s.runReplicaFeed(feed)
+
+	// The stream is written by the feed; there is no direct reply
+	return nil, nil
+}
+
+// runReplicaFeed sends the snapshot and then the live changes to a replica
+func (s *Server) runReplicaFeed(feed *replicaFeed) {
+	defer s.wg.Done()
+
+	store := s.replicationStore()
+	formatter := NewFormatter()
+
+	for _, key := range store.Keys() {
+		if !s.sendKeyState(feed, store, key) {
+			return
+		}
+	}
+	if _, err := feed.clientConn.write(formatter.FormatSimpleString("CONTINUE")); err != nil {
+		feed.close()
+		return
+	}
+	feed.online.Store(true)
+
+	for {
+		select {
+		case <-feed.done:
+			return
+		case <-s.ctx.Done():
+			return
+		case change := <-feed.events:
+			if change.key == "" {
+				if _, err := feed.clientConn.write(commandBytes("FLUSHALL")); err != nil {
+					feed.close()
+					return
+				}
+			} else if !s.sendKeyState(feed, store, change.key) {
+				return
+			}
+			feed.offset.Store(change.offset)
+		}
+	}
+}
+
+// sendKeyState writes the commands recreating key's current state to the replica
+func (s *Server) sendKeyState(feed *replicaFeed, store *storage.BasicStore, key string) bool {
+	var out []byte
+	for _, args := range keyStateCommands(store, key) {
+		out = append(out, commandBytes(args...)...)
+	}
+	if _, err := feed.clientConn.write(out); err != nil {
+		feed.close()
+		return false
+	}
+	return true
+}
+
+// keyStateCommands returns the commands that recreate key on a replica: a
+// RESTORE of its stored value with its type, session and remaining TTL in
+// milliseconds (0 = none), so numbers, documents, bitmaps and every other
+// type arrive as they were stored. A missing key is deleted.
+func keyStateCommands(store *storage.BasicStore, key string) [][]string {
+	item, ok := store.Export(key)
+	if !ok {
+		return [][]string{{"DEL", key}}
+	}
+	ttl := strconv.FormatInt(item.TTL.Milliseconds(), 10)
+	return [][]string{{"RESTORE", key, ttl, string(item.Value), item.ValueType, item.SessionID}}
+}
+
+// commandBytes encodes a command as a RESP array of bulk strings
+func commandBytes(args ...string) []byte {
+	formatter := NewFormatter()
+	elements := make([][]byte, len(args))
+	for i, arg := range args {
+		elements[i] = formatter.FormatBulkString(arg)
+	}
+	return formatter.FormatArray(elements)
+}
+
+// feedReplicas queues a change of the default store for every replica and
+// advances the replication offset. It runs inside store mutations (possibly
+// under the store's typed-value lock), so it never blocks: a replica whose
+// queue is full is dropped and resyncs.
+func (s *Server) feedReplicas(event, key string) {
+	if event == "flushall" {
+		key = ""
+	}
+
+	s.replMu.Lock()
+	defer s.replMu.Unlock()
+	if len(s.replicas) == 0 {
+		return
+	}
+	change := replicaChange{key: key, offset: s.replOffset.Add(1)}
+	for _, feed := range s.replicas {
+		select {
+		case <-feed.done:
+			continue
+		default:
+		}
+		select {
+		case feed.events <- change:
+		default:
+			logging.Warn(nil, logging.ComponentRESP, logging.ActionReplication, "Replica fell too far behind, dropping it", map[string]interface{}{
+				"remote_addr": feed.clientConn.conn.RemoteAddr().String(),
+			})
+			feed.close()
+		}
+	}
+}
+
+// detachReplica stops the replication feed of a connection, if any
+func (s *Server) detachReplica(clientConn *ClientConn) {
+	s.replMu.Lock()
+	feed, ok := s.replicas[clientConn]
+	delete(s.replicas, clientConn)
+	s.replMu.Unlock()
+
+	if ok {
+		feed.close()
+		s.updateKeyspaceNotifier()
+	}
+}
+
+// replicaCount returns the number of attached replicas
+func (s *Server) replicaCount() int {
+	s.replMu.Lock()
+	defer s.replMu.Unlock()
+	return len(s.replicas)
+}
+
+// handleReplconf records replica settings sent before SYNC. Only
+// listening-port is used; other options are accepted and ignored.
+func (s *Server) handleReplconf(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) == 0 || len(cmd.Args)%2 != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for REPLCONF")
+	}
+	for i := 0; i < len(cmd.Args); i += 2 {
+		if strings.EqualFold(cmd.Args[i], "listening-port") {
+			clientConn.replicaPort = cmd.Args[i+1]
+		}
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("OK"), nil
+}
+
+// handleReplicaOf implements REPLICAOF host port and REPLICAOF NO ONE (and
+// the SLAVEOF alias). Attaching discards the local data of the default store
+// and replaces it with the primary's; detaching keeps the data and makes the
+// node writable again.
+func (s *Server) handleReplicaOf(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for %s", cmd.Name)
+	}
+	formatter := NewFormatter()
+
+	if strings.EqualFold(cmd.Args[0], "NO") && strings.EqualFold(cmd.Args[1], "ONE") {
+		s.replMu.Lock()
+		link := s.primary
+		s.primary = nil
+		s.replMu.Unlock()
+
+		if link != nil {
+			link.cancel()
+			logging.Info(clientConn.requestContext(), logging.ComponentRESP, logging.ActionReplication, "Detached from primary", map[string]interface{}{
+				"primary": net.JoinHostPort(link.host, link.port),
+			})
+		}
+		return formatter.FormatSimpleString("OK"), nil
+	}
+
+	host, port := cmd.Args[0], cmd.Args[1]
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("Invalid master port")
+	}
+
+	s.replMu.Lock()
+	if s.primary != nil && s.primary.host == host && s.primary.port == port {
+		s.replMu.Unlock()
+		return formatter.FormatSimpleString("OK Already connected to specified master"), nil
+	}
+	if s.primary != nil {
+		s.primary.cancel()
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	link := &replicaLink{host: host, port: port, ctx: ctx, cancel: cancel}
+	s.primary = link
+	s.replMu.Unlock()
+
+	logging.Info(clientConn.requestContext(), logging.ComponentRESP, logging.ActionReplication, "Replicating from primary", map[string]interface{}{
+		"primary": net.JoinHostPort(host, port),
+	})
+
+	s.wg.Add(1)
+	go s.runReplicaLink(link)
+
+	return formatter.FormatSimpleString("OK"), nil
+}
+
+// runReplicaLink keeps the link to the primary up until it is cancelled
+func (s *Server) runReplicaLink(link *replicaLink) {
+	defer s.wg.Done()
+
+	for {
+		err := s.syncFromPrimary(link)
+		link.up.Store(false)
+		link.syncing.Store(false)
+		if link.ctx.Err() != nil {
+			return
+		}
+
+		logging.Warn(nil, logging.ComponentRESP, logging.ActionReplication, "Replication link down, reconnecting", map[string]interface{}{
+			"primary": net.JoinHostPort(link.host, link.port),
+			"error":   err.Error(),
+		})
+
+		select {
+		case <-link.ctx.Done():
+			return
+		case <-time.After(replicaRetryInterval):
+		}
+	}
+}
+
+// syncFromPrimary connects to the primary, requests the replication stream
+// and applies it until the connection fails or the link is cancelled
+func (s *Server) syncFromPrimary(link *replicaLink) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(link.ctx, "tcp", net.JoinHostPort(link.host, link.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-link.ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	// Announce our port so the primary can list us in INFO, then start the stream
+	handshake := commandBytes("SYNC")
+	if _, port, err := net.SplitHostPort(s.listener.Addr().String()); err == nil {
+		handshake = append(commandBytes("REPLCONF", "listening-port", port), handshake...)
+	}
+	if _, err := conn.Write(handshake); err != nil {
+		return err
+	}
+
+	// Keep the connection alive on the primary while no writes are flowing
+	go func() {
+		ticker := time.NewTicker(replicaPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := conn.Write(commandBytes("PING")); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	store := s.replicationStore()
+	parser := NewParser(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(replicaReadTimeout))
+		value, err := parser.Parse()
+		if err != nil {
+			return err
+		}
+		link.lastIO.Store(time.Now().Unix())
+
+		switch value.Type {
+		case TypeError:
+			return fmt.Errorf("primary replied: %s", value.Str)
+		case TypeSimpleString:
+			switch value.Str {
+			case "FULLRESYNC":
+				if err := store.Clear(); err != nil {
+					return fmt.Errorf("failed to clear store for resync: %w", err)
+				}
+				link.up.Store(true)
+				link.syncing.Store(true)
+				link.applied.Store(0)
+			case "CONTINUE":
+				link.syncing.Store(false)
+			}
+			// Anything else is a reply to our PINGs
+			continue
+		}
+
+		cmd, err := ParseCommand(value)
+		if err != nil {
+			return fmt.Errorf("invalid replication stream: %w", err)
+		}
+		link.applied.Add(1)
+		applyStart := time.Now()
+		err = applyReplicatedCommand(store, *cmd)
+		metrics.Global().Latency().Record(metrics.LatencyReplicationApply, time.Since(applyStart))
+		if err != nil {
+			logging.Warn(nil, logging.ComponentRESP, logging.ActionReplication, "Failed to apply replicated command", map[string]interface{}{
+				"command": cmd.Name,
+				"error":   err.Error(),
+			})
+		}
+	}
+}
+
+// applyReplicatedCommand applies one command of the replication stream.
+// SET, RPUSH, SADD and PEXPIRE are still accepted from primaries that
+// predate RESTORE.
+func applyReplicatedCommand(store *storage.BasicStore, cmd Command) error {
+	switch cmd.Name {
+	case "RESTORE":
+		if len(cmd.Args) != 5 {
+			return fmt.Errorf("wrong number of arguments for RESTORE")
+		}
+		millis, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid expire time")
+		}
+		_, err = store.RestoreRaw(context.Background(), cmd.Args[0], []byte(cmd.Args[2]), cmd.Args[3], cmd.Args[4], time.Duration(millis)*time.Millisecond, 0)
+		return err
+	case "SET":
+		if len(cmd.Args) != 2 && len(cmd.Args) != 4 {
+			return fmt.Errorf("wrong number of arguments for SET")
+		}
+		var ttl time.Duration
+		if len(cmd.Args) == 4 {
+			millis, err := strconv.ParseInt(cmd.Args[3], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid expire time")
+			}
+			ttl = time.Duration(millis) * time.Millisecond
+		}
+		if err := store.Set(cmd.Args[0], []byte(cmd.Args[1]), "", ttl); err != nil {
+			return err
+		}
+		if ttl == 0 {
+			// Don't let the replica's default TTL expire a persistent key
+			store.Persist(cmd.Args[0])
+		}
+		return nil
+	case "DEL":
+		for _, key := range cmd.Args {
+			_ = store.Delete(key)
+		}
+		return nil
+	case "RPUSH":
+		if len(cmd.Args) < 2 {
+			return fmt.Errorf("wrong number of arguments for RPUSH")
+		}
+		_, err := store.ListPush(cmd.Args[0], false, cmd.Args[1:]...)
+		return err
+	case "SADD":
+		if len(cmd.Args) < 2 {
+			return fmt.Errorf("wrong number of arguments for SADD")
+		}
+		_, err := store.SetAdd(cmd.Args[0], cmd.Args[1:]...)
+		return err
+	case "PEXPIRE":
+		if len(cmd.Args) != 2 {
+			return fmt.Errorf("wrong number of arguments for PEXPIRE")
+		}
+		millis, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid expire time")
+		}
+		store.Expire(cmd.Args[0], time.Duration(millis)*time.Millisecond)
+		return nil
+	case "FLUSHALL":
+		return store.Clear()
+	default:
+		return fmt.Errorf("unsupported replicated command %s", cmd.Name)
+	}
+}
+
+// replicationInfo renders the # Replication section of INFO: the node's
+// role, its attached replicas and, on a replica, the state of the link to
+// its primary. Offsets count changes of the default store streamed to
+// replicas; a replica reports the commands it has applied since its last
+// full resync.
+func (s *Server) replicationInfo() string {
+	s.replMu.Lock()
+	link := s.primary
+	feeds := make([]*replicaFeed, 0, len(s.replicas))
+	for _, feed := range s.replicas {
+		feeds = append(feeds, feed)
+	}
+	s.replMu.Unlock()
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].clientConn.id < feeds[j].clientConn.id })
+
+	var b strings.Builder
+	b.WriteString("# Replication\n")
+	if link == nil {
+		b.WriteString("role:master\n")
+	} else {
+		status := "down"
+		if link.up.Load() {
+			status = "up"
+		}
+		lastIO := int64(-1)
+		if ts := link.lastIO.Load(); ts > 0 {
+			lastIO = time.Now().Unix() - ts
+		}
+		syncing := 0
+		if link.syncing.Load() {
+			syncing = 1
+		}
+
+		b.WriteString("role:slave\n")
+		fmt.Fprintf(&b, "master_host:%s\n", link.host)
+		fmt.Fprintf(&b, "master_port:%s\n", link.port)
+		fmt.Fprintf(&b, "master_link_status:%s\n", status)
+		fmt.Fprintf(&b, "master_last_io_seconds_ago:%d\n", lastIO)
+		fmt.Fprintf(&b, "master_sync_in_progress:%d\n", syncing)
+		fmt.Fprintf(&b, "slave_repl_offset:%d\n", link.applied.Load())
+	}
+
+	fmt.Fprintf(&b, "connected_slaves:%d\n", len(feeds))
+	for i, feed := range feeds {
+		ip, _, _ := net.SplitHostPort(feed.clientConn.conn.RemoteAddr().String())
+		state := "send_bulk"
+		if feed.online.Load() {
+			state = "online"
+		}
+		fmt.Fprintf(&b, "slave%d:ip=%s,port=%s,state=%s,offset=%d\n", i, ip, feed.listeningPort, state, feed.offset.Load())
+	}
+
+	offset := s.replOffset.Load()
+	if link != nil {
+		offset = link.applied.Load()
+	}
+	fmt.Fprintf(&b, "master_repl_offset:%d\n", offset)
+	writeReplicationLag(&b, metrics.Global().ReplicationLag())
+	return b.String()
+}
+
+// writeReplicationLag adds the lag of writes replicated to this node from
+// cluster peers, overall and per primary, in milliseconds. Nothing is added
+// before the first replicated write.
+func writeReplicationLag(b *strings.Builder, lag *metrics.ReplicationLag) {
+	all, nodes := lag.Stats()
+	if all.Count == 0 {
+		return
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+	lagging := 0
+	for _, stats := range nodes {
+		if stats.Lagging {
+			lagging++
+		}
+	}
+	fmt.Fprintf(b, "repl_lag_p50_ms:%.2f\n", ms(all.P50))
+	fmt.Fprintf(b, "repl_lag_p99_ms:%.2f\n", ms(all.P99))
+	fmt.Fprintf(b, "repl_lag_max_ms:%.2f\n", ms(all.Max))
+	fmt.Fprintf(b, "repl_lag_threshold_ms:%.2f\n", ms(lag.Threshold()))
+	fmt.Fprintf(b, "repl_lagging_nodes:%d\n", lagging)
+	for i, stats := range nodes {
+		flag := 0
+		if stats.Lagging {
+			flag = 1
+		}
+		fmt.Fprintf(b, "repl_lag_node%d:node=%s,latest_ms=%.2f,p50_ms=%.2f,p99_ms=%.2f,max_ms=%.2f,writes=%d,lagging=%d\n",
+			i, stats.Node, ms(stats.Latest), ms(stats.P50), ms(stats.P99), ms(stats.Max), stats.Count, flag)
+	}
+}
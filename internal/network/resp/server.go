@@ -3,6 +3,7 @@ package resp
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -12,9 +13,13 @@ import (
 	"time"
 
 	"hypercache/internal/cluster"
+	"hypercache/internal/logging"
 	"hypercache/internal/storage"
 )
 
+// ErrMaxClients is sent to a client connecting while MaxConnections are open
+var ErrMaxClients = errors.New("ERR max number of clients reached")
+
 // Server represents a RESP protocol server that handles Redis-compatible commands
 type Server struct {
 	address  string
@@ -28,10 +33,36 @@ type Server struct {
 	// Node communicator for hash-ring proxy/replication
 	nodeCommunicator *cluster.NodeCommunicator
 
+	// Key-access audit log; nil = auditing disabled
+	audit *logging.AuditLog
+
 	// Consistency level: "eventual" (default, async replication) or "quorum" (wait for majority ACKs)
 	consistencyLevel string
 
+	// Keys owned by another node: KeyRoutingProxy (default) or KeyRoutingRedirect
+	keyRouting string
+
+	// Append the connection and correlation IDs to error replies, so a
+	// client-reported error can be found in the server logs
+	errorTokens atomic.Bool
+
+	// Pub/sub and keyspace notifications
+	pubsub         *PubSub
+	notifyFlags    atomic.Int64 // parsed notify-keyspace-events mask
+	notifyFlagsRaw atomic.Value // notify-keyspace-events as configured (string)
+
+	// Primary/replica replication (REPLICAOF, SYNC)
+	replMu     sync.Mutex
+	primary    *replicaLink                 // link to our primary; nil when not a replica
+	replicas   map[*ClientConn]*replicaFeed // attached replicas
+	replOffset atomic.Int64                 // changes streamed to replicas so far
+
+	// Transactions: held exclusively by EXEC and shared by every other
+	// client write, so a transaction's watches can't change while it runs
+	execMu sync.RWMutex
+
 	// Connection management
+	listeners   []net.Listener // extra SO_REUSEPORT listeners besides listener
 	connections map[net.Conn]*ClientConn
 	connMutex   sync.RWMutex
 	connIDSeq   uint64
@@ -46,7 +77,8 @@ type Server struct {
 	config ServerConfig
 
 	// Statistics
-	stats ServerStats
+	stats    ServerStats
+	cmdStats commandStats // per-command calls and timings
 }
 
 // ServerConfig holds server configuration
@@ -59,6 +91,19 @@ type ServerConfig struct {
 	KeepAlivePeriod  time.Duration
 	EnablePipelining bool
 	MaxPipelineDepth int
+
+	// AcceptWorkers is the number of goroutines accepting connections
+	// (0 = 1). With ReusePort each worker gets its own SO_REUSEPORT
+	// listener on the same address, so the kernel spreads new connections
+	// across them; otherwise the workers share one listener.
+	AcceptWorkers int
+	ReusePort     bool
+
+	// IdleCheckInterval is how often connections idle for IdleTimeout are
+	// closed (0 = every minute). With IdleTimeoutReply they are sent
+	// "-ERR idle timeout" first, so clients can tell reaping from a crash.
+	IdleCheckInterval time.Duration
+	IdleTimeoutReply  bool
 }
 
 // ServerStats holds server statistics
@@ -69,6 +114,10 @@ type ServerStats struct {
 	ErrorsEncountered uint64
 	BytesSent         uint64
 	BytesReceived     uint64
+	// Connections closed on accept because MaxConnections was reached
+	RejectedConnections uint64
+	// Connections closed after IdleTimeout without a command
+	ReapedConnections uint64
 }
 
 // ClientConn represents a client connection
@@ -76,12 +125,32 @@ type ClientConn struct {
 	id            uint64
 	conn          net.Conn
 	reader        *bufio.Reader
+	writer        *bufio.Writer // queued replies, reused across commands
 	parser        *Parser
 	formatter     *Formatter
-	lastUsed      time.Time
-	selectedStore string // per-connection store selection; empty = "default"
+	lastUsed      atomic.Int64 // UnixNano of the last command, read by the idle reaper
+	selectedStore string       // per-connection store selection; empty = "default"
+	name          string       // set by CLIENT SETNAME
+
+	writeMu       sync.Mutex          // serializes replies with pub/sub pushes
+	subscriptions map[string]struct{} // subscribed channels, guarded by PubSub.mu
+	outbox        *subscriberOutbox   // pub/sub deliveries while subscribed, guarded by PubSub.mu
+
+	ctx context.Context // current command's context, carrying its correlation ID
+
+	inMulti atomic.Bool // between MULTI and EXEC/DISCARD; read by the idle reaper
+	queued  []Command   // commands queued by MULTI
+	watches []watch     // keys watched for the next EXEC
+
+	replicaPort string // listening port announced by a replica (REPLCONF)
+
+	reply []byte // reused reply buffer, see replyBuffer
 }
 
+// maxReusedReply is the largest reply buffer a connection keeps for reuse,
+// so one large reply doesn't pin its memory for the connection's lifetime
+const maxReusedReply = 64 * 1024
+
 // DefaultServerConfig returns default server configuration
 func DefaultServerConfig() ServerConfig {
 	return ServerConfig{
@@ -105,6 +174,7 @@ func NewServer(address string, store *storage.BasicStore, coord cluster.Coordina
 		store:       store,
 		coord:       coord,
 		connections: make(map[net.Conn]*ClientConn),
+		pubsub:      NewPubSub(),
 		ctx:         ctx,
 		cancel:      cancel,
 		config:      DefaultServerConfig(),
@@ -126,6 +196,21 @@ func (s *Server) SetConsistencyLevel(level string) {
 	s.consistencyLevel = level
 }
 
+// SetErrorTokens makes error replies end with "[conn=<id> cid=<correlation>]",
+// the IDs the failure is logged under. Off by default; safe to change while
+// serving.
+func (s *Server) SetErrorTokens(enabled bool) {
+	s.errorTokens.Store(enabled)
+}
+
+// SetMaxConnections changes the client limit (maxclients). Connections over
+// a lowered limit are kept; new ones are refused until the count drops.
+func (s *Server) SetMaxConnections(n int) {
+	s.connMutex.Lock()
+	s.config.MaxConnections = n
+	s.connMutex.Unlock()
+}
+
 // NewServerWithConfig creates a new RESP server with custom configuration
 func NewServerWithConfig(address string, store *storage.BasicStore, coord cluster.CoordinatorService, config ServerConfig) *Server {
 	server := NewServer(address, store, coord)
@@ -139,12 +224,33 @@ func (s *Server) Start() error {
 		return fmt.Errorf("server is already running")
 	}
 
-	listener, err := net.Listen("tcp", s.address)
+	workers := s.config.AcceptWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	listener, err := s.listen(s.address)
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", s.address, err)
 	}
-
 	s.listener = listener
+
+	// Extra SO_REUSEPORT listeners bind the port the first one got, which
+	// matters when the configured port is 0
+	listeners := []net.Listener{listener}
+	if s.config.ReusePort {
+		for i := 1; i < workers; i++ {
+			l, err := s.listen(listener.Addr().String())
+			if err != nil {
+				for _, l := range listeners {
+					l.Close()
+				}
+				return fmt.Errorf("failed to listen on %s: %w", listener.Addr(), err)
+			}
+			listeners = append(listeners, l)
+		}
+		s.listeners = listeners[1:]
+	}
 	s.running.Store(true)
 
 	// Start connection cleanup goroutine
@@ -152,8 +258,10 @@ func (s *Server) Start() error {
 	go s.connectionCleaner()
 
 	// Start accepting connections
-	s.wg.Add(1)
-	go s.acceptConnections()
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.acceptConnections(listeners[i%len(listeners)])
+	}
 
 	// Note: cluster event replication is handled by main.go's handleReplicationEvent
 	// to avoid duplicate processing of gossip events
@@ -161,6 +269,14 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// Addr returns the address the server listens on, or nil before Start
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
 // Stop stops the RESP server
 func (s *Server) Stop() error {
 	if !s.running.Load() {
@@ -170,10 +286,13 @@ func (s *Server) Stop() error {
 	s.running.Store(false)
 	s.cancel()
 
-	// Close listener
+	// Close listeners
 	if s.listener != nil {
 		s.listener.Close()
 	}
+	for _, l := range s.listeners {
+		l.Close()
+	}
 
 	// Close all connections
 	s.connMutex.Lock()
@@ -201,11 +320,24 @@ func (s *Server) GetStats() ServerStats {
 		ErrorsEncountered: atomic.LoadUint64(&s.stats.ErrorsEncountered),
 		BytesSent:         atomic.LoadUint64(&s.stats.BytesSent),
 		BytesReceived:     atomic.LoadUint64(&s.stats.BytesReceived),
+
+		RejectedConnections: atomic.LoadUint64(&s.stats.RejectedConnections),
+		ReapedConnections:   atomic.LoadUint64(&s.stats.ReapedConnections),
+	}
+}
+
+// listen opens a TCP listener, with SO_REUSEPORT if configured
+func (s *Server) listen(address string) (net.Listener, error) {
+	if !s.config.ReusePort {
+		return net.Listen("tcp", address)
 	}
+	lc := net.ListenConfig{Control: reusePortControl}
+	return lc.Listen(context.Background(), "tcp", address)
 }
 
-// acceptConnections accepts new client connections
-func (s *Server) acceptConnections() {
+// acceptConnections accepts new client connections from one listener. Several
+// may run at once; the connection limit is enforced under connMutex.
+func (s *Server) acceptConnections(listener net.Listener) {
 	defer s.wg.Done()
 
 	for {
@@ -215,7 +347,7 @@ func (s *Server) acceptConnections() {
 		default:
 		}
 
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			if s.running.Load() {
 				// Only log if we're still supposed to be running
@@ -224,17 +356,6 @@ func (s *Server) acceptConnections() {
 			return
 		}
 
-		// Check connection limits
-		s.connMutex.RLock()
-		connCount := len(s.connections)
-		s.connMutex.RUnlock()
-
-		if connCount >= s.config.MaxConnections {
-			conn.Close()
-			atomic.AddUint64(&s.stats.ErrorsEncountered, 1)
-			continue
-		}
-
 		// Configure connection
 		if tcpConn, ok := conn.(*net.TCPConn); ok && s.config.KeepAlive {
 			tcpConn.SetKeepAlive(true)
@@ -245,14 +366,21 @@ func (s *Server) acceptConnections() {
 		clientConn := &ClientConn{
 			id:        atomic.AddUint64(&s.connIDSeq, 1),
 			conn:      conn,
-			reader:    bufio.NewReaderSize(conn, s.config.BufferSize),
+			writer:    bufio.NewWriterSize(conn, s.config.BufferSize),
 			formatter: NewFormatter(),
-			lastUsed:  time.Now(),
 		}
+		clientConn.reader = bufio.NewReaderSize(flushingReader{clientConn}, s.config.BufferSize)
 		clientConn.parser = NewParser(clientConn.reader)
+		clientConn.lastUsed.Store(time.Now().UnixNano())
 
-		// Track connection
+		// Check the connection limit and track the connection in one critical
+		// section, so concurrent accept workers cannot overshoot it
 		s.connMutex.Lock()
+		if len(s.connections) >= s.config.MaxConnections {
+			s.connMutex.Unlock()
+			s.rejectConnection(conn)
+			continue
+		}
 		s.connections[conn] = clientConn
 		s.connMutex.Unlock()
 
@@ -264,10 +392,25 @@ func (s *Server) acceptConnections() {
 	}
 }
 
+// rejectConnection tells a client over the connection limit why, as Redis
+// does, and closes its connection
+func (s *Server) rejectConnection(conn net.Conn) {
+	atomic.AddUint64(&s.stats.RejectedConnections, 1)
+	// A fresh socket's send buffer takes the reply at once; the deadline
+	// only guards the accept loop against a peer that can't receive
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write(NewFormatter().FormatError(ErrMaxClients.Error()))
+	conn.Close()
+}
+
 // handleConnection handles a client connection
 func (s *Server) handleConnection(clientConn *ClientConn) {
 	defer s.wg.Done()
 	defer func() {
+		s.pubsub.UnsubscribeAll(clientConn)
+		s.unwatchAll(clientConn)
+		s.detachReplica(clientConn)
+		clientConn.flush()
 		clientConn.conn.Close()
 		s.connMutex.Lock()
 		delete(s.connections, clientConn.conn)
@@ -292,19 +435,19 @@ func (s *Server) handleConnection(clientConn *ClientConn) {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				// Send timeout error
 				response := clientConn.formatter.FormatError("ERR timeout")
-				clientConn.conn.Write(response)
+				clientConn.write(response)
 			}
 			return
 		}
 
-		clientConn.lastUsed = time.Now()
+		clientConn.lastUsed.Store(time.Now().UnixNano())
 
 		// Process command
 		err = s.processCommand(clientConn, *value)
 		if err != nil {
 			// Send error response
-			response := clientConn.formatter.FormatError(fmt.Sprintf("ERR %s", err.Error()))
-			clientConn.conn.Write(response)
+			response := clientConn.formatter.FormatError(fmt.Sprintf("ERR %s", err.Error()) + s.errorToken(clientConn))
+			clientConn.queue(response)
 			atomic.AddUint64(&s.stats.ErrorsEncountered, 1)
 		}
 
@@ -312,22 +455,75 @@ func (s *Server) handleConnection(clientConn *ClientConn) {
 	}
 }
 
+// requestContext returns the context of the command being processed
+func (c *ClientConn) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// replyBuffer returns the connection's reply buffer, emptied, for a handler
+// to append its reply to instead of allocating one. The reply must be used
+// before the connection's next command: processCommand queues it, and EXEC
+// detaches each queued command's reply from the buffer.
+func (c *ClientConn) replyBuffer() []byte {
+	return c.reply[:0]
+}
+
+// keepReply keeps the buffer of a reply appended to replyBuffer, grown if
+// need be, for the next command to reuse, and returns the reply
+func (c *ClientConn) keepReply(reply []byte) []byte {
+	if cap(reply) <= maxReusedReply {
+		c.reply = reply
+	}
+	return reply
+}
+
 // processCommand processes a Redis command
 func (s *Server) processCommand(clientConn *ClientConn, value Value) error {
+	// Give each command its own correlation ID so proxied and replicated
+	// writes, and any error, can be traced across nodes
+	clientConn.ctx = logging.WithCorrelationID(context.Background(), logging.NewCorrelationID())
+
 	// Parse command from value
 	cmd, err := ParseCommand(&value)
 	if err != nil {
+		s.logErrorReply(clientConn, "", "ERR "+err.Error())
 		return err
 	}
 
 	// Route command
+	exclusive, shared := s.execLockMode(clientConn, cmd.Name)
+	if exclusive {
+		s.execMu.Lock()
+	} else if shared {
+		s.execMu.RLock()
+	}
 	response, err := s.routeCommand(clientConn, *cmd)
+	if exclusive {
+		s.execMu.Unlock()
+	} else if shared {
+		s.execMu.RUnlock()
+	}
+	var moved *MovedError
+	if errors.As(err, &moved) {
+		response, err = clientConn.formatter.FormatError(moved.Error()), nil
+	}
 	if err != nil {
+		s.logErrorReply(clientConn, cmd.Name, "ERR "+err.Error())
 		return err
 	}
+	if len(response) > 0 && response[0] == '-' {
+		message := strings.TrimSuffix(string(response[1:]), "\r\n")
+		s.logErrorReply(clientConn, cmd.Name, message)
+		if s.errorTokens.Load() {
+			response = clientConn.formatter.FormatError(message + s.errorToken(clientConn))
+		}
+	}
 
-	// Send response
-	_, err = clientConn.conn.Write(response)
+	// Queue response; it is flushed before the next read from the client
+	_, err = clientConn.queue(response)
 	if err != nil {
 		return fmt.Errorf("failed to send response: %w", err)
 	}
@@ -336,30 +532,169 @@ func (s *Server) processCommand(clientConn *ClientConn, value Value) error {
 	return nil
 }
 
+// logErrorReply logs an error sent to a client with the connection ID and,
+// via the command's context, its correlation ID
+func (s *Server) logErrorReply(clientConn *ClientConn, command, message string) {
+	logging.Warn(clientConn.requestContext(), logging.ComponentRESP, logging.ActionResponse, "RESP command failed", map[string]interface{}{
+		"conn_id": clientConn.id,
+		"command": strings.ToUpper(command),
+		"error":   message,
+		"remote":  clientConn.conn.RemoteAddr().String(),
+	})
+}
+
+// errorToken is appended to error replies when error tokens are enabled
+func (s *Server) errorToken(clientConn *ClientConn) string {
+	if !s.errorTokens.Load() {
+		return ""
+	}
+	cid := logging.GetCorrelationID(clientConn.requestContext())
+	if len(cid) > 8 {
+		cid = cid[:8]
+	}
+	return fmt.Sprintf(" [conn=%d cid=%s]", clientConn.id, cid)
+}
+
 // routeCommand routes a command to the appropriate handler
-func (s *Server) routeCommand(clientConn *ClientConn, cmd Command) ([]byte, error) {
+func (s *Server) routeCommand(clientConn *ClientConn, cmd Command) (response []byte, err error) {
+	if s.pubsub.SubscriptionCount(clientConn) > 0 && !allowedInSubscribedMode(cmd.Name) {
+		return nil, fmt.Errorf("Can't execute '%s': only SUBSCRIBE / UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", strings.ToLower(cmd.Name))
+	}
+	if clientConn.inMulti.Load() && !isTransactionCommand(cmd.Name) {
+		return s.queueCommand(clientConn, cmd)
+	}
+	if isWriteCommand(cmd.Name) && s.isReplica() {
+		formatter := NewFormatter()
+		return formatter.FormatError(ErrReadOnlyReplica.Error()), nil
+	}
+
+	if s.audit != nil {
+		s.auditAccess(clientConn, cmd)
+	}
+
+	// Commands queued by MULTI are counted when EXEC runs them
+	start := time.Now()
+	defer func() {
+		if !errors.Is(err, errUnknownCommand) {
+			failed := err != nil || (len(response) > 0 && response[0] == '-')
+			s.cmdStats.record(cmd.Name, time.Since(start), failed)
+		}
+	}()
+
 	switch strings.ToUpper(cmd.Name) {
 	// Key-value commands
 	case "GET":
 		return s.handleGet(clientConn, cmd)
+	case "GETMETA":
+		return s.handleGetMeta(clientConn, cmd)
 	case "SET":
 		return s.handleSet(clientConn, cmd)
+	case "CAS":
+		return s.handleCAS(clientConn, cmd)
 	case "DEL", "DELETE":
 		return s.handleDel(clientConn, cmd)
+	case "GETDEL":
+		return s.handleGetDel(clientConn, cmd)
+	case "DELSESSION":
+		return s.handleDelSession(clientConn, cmd)
 	case "EXISTS":
 		return s.handleExists(clientConn, cmd)
 	case "TTL":
-		return s.handleTTL(cmd)
+		return s.handleTTL(clientConn, cmd)
 	case "EXPIRE":
-		return s.handleExpire(cmd)
+		return s.handleExpire(clientConn, cmd)
+	case "PERSIST":
+		return s.handlePersist(clientConn, cmd)
+	case "PIN":
+		return s.handlePin(clientConn, cmd, true)
+	case "UNPIN":
+		return s.handlePin(clientConn, cmd, false)
+	case "TYPE":
+		return s.handleType(clientConn, cmd)
+	case "SCAN":
+		return s.handleScan(clientConn, cmd)
+	case "OBJECT":
+		return s.handleObject(clientConn, cmd)
+	case "SORT":
+		return s.handleSort(clientConn, cmd)
+
+	// List commands
+	case "LPUSH":
+		return s.handlePush(clientConn, cmd, true)
+	case "RPUSH":
+		return s.handlePush(clientConn, cmd, false)
+	case "LPOP":
+		return s.handlePop(clientConn, cmd, true)
+	case "RPOP":
+		return s.handlePop(clientConn, cmd, false)
+	case "LRANGE":
+		return s.handleLRange(clientConn, cmd)
+	case "LLEN":
+		return s.handleLLen(clientConn, cmd)
+
+	// Set commands
+	case "SADD":
+		return s.handleSetModify(clientConn, cmd, true)
+	case "SREM":
+		return s.handleSetModify(clientConn, cmd, false)
+	case "SMEMBERS":
+		return s.handleSMembers(clientConn, cmd)
+	case "SISMEMBER":
+		return s.handleSIsMember(clientConn, cmd)
+	case "SCARD":
+		return s.handleSCard(clientConn, cmd)
+	case "SINTER":
+		return s.handleSetAlgebra(clientConn, cmd, storage.SetOpInter, false)
+	case "SUNION":
+		return s.handleSetAlgebra(clientConn, cmd, storage.SetOpUnion, false)
+	case "SDIFF":
+		return s.handleSetAlgebra(clientConn, cmd, storage.SetOpDiff, false)
+	case "SINTERSTORE":
+		return s.handleSetAlgebra(clientConn, cmd, storage.SetOpInter, true)
+	case "SUNIONSTORE":
+		return s.handleSetAlgebra(clientConn, cmd, storage.SetOpUnion, true)
+	case "SDIFFSTORE":
+		return s.handleSetAlgebra(clientConn, cmd, storage.SetOpDiff, true)
+
+	// Bitmap commands
+	case "SETBIT":
+		return s.handleSetBit(clientConn, cmd)
+	case "GETBIT":
+		return s.handleGetBit(clientConn, cmd)
+	case "BITCOUNT":
+		return s.handleBitCount(clientConn, cmd)
+
+	// HyperLogLog commands
+	case "PFADD":
+		return s.handlePFAdd(clientConn, cmd)
+	case "PFCOUNT":
+		return s.handlePFCount(clientConn, cmd)
+	case "PFMERGE":
+		return s.handlePFMerge(clientConn, cmd)
+
+	// Transaction commands
+	case "MULTI":
+		return s.handleMulti(clientConn, cmd)
+	case "EXEC":
+		return s.handleExec(clientConn, cmd)
+	case "DISCARD":
+		return s.handleDiscard(clientConn, cmd)
+	case "WATCH":
+		return s.handleWatch(clientConn, cmd)
+	case "UNWATCH":
+		return s.handleUnwatch(clientConn, cmd)
 
 	// Info commands
 	case "PING":
 		return s.handlePing(cmd)
+	case "ECHO":
+		return s.handleEcho(cmd)
 	case "INFO":
-		return s.handleInfo(cmd)
+		return s.handleInfo(clientConn, cmd)
 	case "STATS":
-		return s.handleStats(cmd)
+		return s.handleStats(clientConn, cmd)
+	case "LATENCY":
+		return s.handleLatency(cmd)
 
 	// Administrative commands
 	case "FLUSHALL":
@@ -373,6 +708,39 @@ func (s *Server) routeCommand(clientConn *ClientConn, cmd Command) ([]byte, erro
 	case "STORES":
 		return s.handleStores(cmd)
 
+	// Connection commands
+	case "CLIENT":
+		return s.handleClient(clientConn, cmd)
+	case "RESET":
+		return s.handleReset(clientConn, cmd)
+
+	case "MEMORY":
+		return s.handleMemory(clientConn, cmd)
+	case "DEBUG":
+		return s.handleDebug(clientConn, cmd)
+
+	// Cluster administration
+	case "DECOMMISSION":
+		return s.handleDecommission(clientConn, cmd)
+	case "CLUSTER":
+		return s.handleCluster(clientConn, cmd)
+
+	// Replication
+	case "REPLICAOF", "SLAVEOF":
+		return s.handleReplicaOf(clientConn, cmd)
+	case "SYNC":
+		return s.handleSync(clientConn, cmd)
+	case "REPLCONF":
+		return s.handleReplconf(clientConn, cmd)
+
+	// Pub/sub commands
+	case "SUBSCRIBE":
+		return s.handleSubscribe(clientConn, cmd)
+	case "UNSUBSCRIBE":
+		return s.handleUnsubscribe(clientConn, cmd)
+	case "PUBLISH":
+		return s.handlePublish(cmd)
+
 	// Compatibility stubs (redis-benchmark, redis-cli)
 	case "CONFIG":
 		return s.handleConfig(cmd)
@@ -380,7 +748,7 @@ func (s *Server) routeCommand(clientConn *ClientConn, cmd Command) ([]byte, erro
 		return s.handleCommand(cmd)
 
 	default:
-		return nil, fmt.Errorf("unknown command: %s", cmd.Name)
+		return nil, fmt.Errorf("%w: %s", errUnknownCommand, cmd.Name)
 	}
 }
 
@@ -399,6 +767,53 @@ func (s *Server) getActiveStore(clientConn *ClientConn) *storage.BasicStore {
 	return s.store // fallback to default
 }
 
+// crossSlotReply returns a Redis Cluster style CROSSSLOT reply if the node
+// routes keys and keys span several hash slots, nil otherwise. Multi-key
+// commands are rejected this way whichever nodes own the keys, so they run
+// atomically on one node and reply the same whatever the topology.
+func (s *Server) crossSlotReply(keys []string) []byte {
+	if s.coord == nil || s.coord.GetRouting() == nil {
+		return nil
+	}
+	if _, ok := cluster.SameSlot(keys...); ok {
+		return nil
+	}
+	formatter := NewFormatter()
+	return formatter.FormatError("CROSSSLOT Keys in request don't hash to the same slot")
+}
+
+// routeKeys resolves the owner of every key of a multi-key command up front.
+// Keys owned by another node are returned as key -> owner for proxying. Keys
+// spanning several hash slots get a CROSSSLOT reply, and if a remote key
+// cannot be proxied an error naming it is returned, either way before any
+// data is touched.
+func (s *Server) routeKeys(keys []string) (map[string]string, []byte, error) {
+	remoteOwners := make(map[string]string)
+	if s.coord == nil || s.coord.GetRouting() == nil {
+		return remoteOwners, nil, nil
+	}
+	if crossSlot := s.crossSlotReply(keys); crossSlot != nil {
+		return nil, crossSlot, nil
+	}
+
+	routing := s.coord.GetRouting()
+	for _, key := range keys {
+		if routing.IsLocal(key) || routing.IsReplica(key) {
+			continue
+		}
+		if err := s.redirect(key); err != nil {
+			return nil, nil, err
+		}
+		ownerNode := routing.RouteKey(key)
+		if ownerNode == "" || s.nodeCommunicator == nil {
+			return nil, nil, fmt.Errorf("no route to owner node for key '%s'", key)
+		}
+		remoteOwners[key] = ownerNode
+	}
+
+	return remoteOwners, nil, nil
+}
+
 // Command handlers
 
 func (s *Server) handleGet(clientConn *ClientConn, cmd Command) ([]byte, error) {
@@ -417,35 +832,79 @@ func (s *Server) handleGet(clientConn *ClientConn, cmd Command) ([]byte, error)
 		// Check if this node owns or replicates this key
 		if routing.IsLocal(key) || routing.IsReplica(key) {
 			// Fast path: use GetRawBytes to skip deserialization for strings
-			rawBytes, _, err := store.GetRawBytes(key)
+			rawBytes, valueType, err := store.GetRawBytes(key)
 			if err == nil {
-				return formatter.FormatBulkBytes(rawBytes), nil
+				if err := storage.CheckValueKind(valueType, storage.TypeString); err != nil {
+					return typedReply(err)
+				}
+				return clientConn.keepReply(formatter.AppendBulkBytes(clientConn.replyBuffer(), rawBytes)), nil
 			}
 			// Local miss on a key we should have — return null (replication lag)
-			return formatter.FormatNull(), nil
+			return clientConn.keepReply(formatter.AppendNull(clientConn.replyBuffer())), nil
 		}
 
-		// Key belongs to another node — proxy to the owner
+		// Key belongs to another node — redirect or proxy to the owner
+		if err := s.redirect(key); err != nil {
+			return nil, err
+		}
 		if s.nodeCommunicator != nil {
 			ownerNode := routing.RouteKey(key)
 			if ownerNode != "" {
-				value, found, err := s.nodeCommunicator.ProxyGet(context.Background(), ownerNode, key)
+				value, found, err := s.nodeCommunicator.ProxyGet(clientConn.requestContext(), ownerNode, key)
 				if err == nil && found {
 					return s.formatGetValue(formatter, value), nil
 				}
 			}
 		}
 
-		return formatter.FormatNull(), nil
+		return clientConn.keepReply(formatter.AppendNull(clientConn.replyBuffer())), nil
 	}
 
 	// Standalone mode — fast path via raw bytes
-	rawBytes, _, err := store.GetRawBytes(key)
+	rawBytes, valueType, err := store.GetRawBytes(key)
 	if err != nil {
-		return formatter.FormatNull(), nil
+		return clientConn.keepReply(formatter.AppendNull(clientConn.replyBuffer())), nil
+	}
+	if err := storage.CheckValueKind(valueType, storage.TypeString); err != nil {
+		return typedReply(err)
 	}
 
-	return formatter.FormatBulkBytes(rawBytes), nil
+	return clientConn.keepReply(formatter.AppendBulkBytes(clientConn.replyBuffer(), rawBytes)), nil
+}
+
+// handleGetMeta is a HyperCache-specific GET that also returns the key's
+// metadata: [value, ttl_seconds, access_count, created_at_unix]. ttl_seconds
+// follows TTL (-1 for no expiry); a missing key returns a null array.
+func (s *Server) handleGetMeta(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for GETMETA")
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	formatter := NewFormatter()
+	rawBytes, valueType, meta, err := store.GetWithMeta(key)
+	if err != nil {
+		return formatter.FormatNullArray(), nil
+	}
+	if err := storage.CheckValueKind(valueType, storage.TypeString); err != nil {
+		return typedReply(err)
+	}
+
+	ttl := int64(-1)
+	if meta.TTL >= 0 {
+		ttl = int64((meta.TTL + time.Second - 1) / time.Second)
+	}
+	return formatter.FormatArray([][]byte{
+		formatter.FormatBulkBytes(rawBytes),
+		formatter.FormatInteger(ttl),
+		formatter.FormatInteger(int64(meta.AccessCount)),
+		formatter.FormatInteger(meta.CreatedAt.Unix()),
+	}), nil
 }
 
 // formatGetValue converts a value to RESP bulk string bytes
@@ -469,15 +928,26 @@ func (s *Server) handleSet(clientConn *ClientConn, cmd Command) ([]byte, error)
 	value := []byte(cmd.Args[1]) // Store as []byte — Redis-native binary-safe storage
 	store := s.getActiveStore(clientConn)
 
-	// Parse optional arguments (EX, PX, NX, XX, etc.)
+	// Parse optional arguments (EX, PX, NX, XX, KEEPTTL, PIN). Without EX,
+	// PX or KEEPTTL the write replaces any existing expiry, as in Redis.
 	var ttl time.Duration
+	pin, keepTTL, expiry := false, false, false
 
 	for i := 2; i < len(cmd.Args); i += 2 {
+		option := strings.ToUpper(cmd.Args[i])
+		if option == "PIN" {
+			pin = true
+			i-- // PIN takes no argument
+			continue
+		}
+		if option == "KEEPTTL" {
+			keepTTL = true
+			i-- // nor does KEEPTTL
+			continue
+		}
 		if i+1 >= len(cmd.Args) {
 			return nil, fmt.Errorf("syntax error")
 		}
-
-		option := strings.ToUpper(cmd.Args[i])
 		arg := cmd.Args[i+1]
 
 		switch option {
@@ -487,89 +957,208 @@ func (s *Server) handleSet(clientConn *ClientConn, cmd Command) ([]byte, error)
 				return nil, fmt.Errorf("invalid expire time")
 			}
 			ttl = time.Duration(seconds) * time.Second
+			expiry = true
 		case "PX":
 			millis, err := strconv.Atoi(arg)
 			if err != nil {
 				return nil, fmt.Errorf("invalid expire time")
 			}
 			ttl = time.Duration(millis) * time.Millisecond
+			expiry = true
 		case "NX", "XX":
 			// TODO: Implement conditional sets
 		default:
 			return nil, fmt.Errorf("syntax error")
 		}
 	}
+	if keepTTL {
+		if expiry {
+			return nil, fmt.Errorf("syntax error")
+		}
+		// The expiry kept is the owner's, which a proxied write can't
+		// carry, so SET ... KEEPTTL must be sent to the key's owner
+		if err := s.checkLocalKey(key); err != nil {
+			return nil, err
+		}
+		ttl = storage.KeepTTL
+	}
 
 	formatter := NewFormatter()
 
+	write := store.Set
+	if pin {
+		// Pins are local to a node and aren't proxied, so SET ... PIN must
+		// be sent to the key's owner
+		if err := s.checkLocalKey(key); err != nil {
+			return nil, err
+		}
+		write = store.SetPinned
+	}
+
 	// DISTRIBUTED SET with hash-ring routing
 	if s.coord != nil && s.coord.GetRouting() != nil {
 		routing := s.coord.GetRouting()
 
 		if !routing.IsLocal(key) && !routing.IsReplica(key) {
-			// This node is NOT the owner — proxy to the owner transparently
+			// This node is NOT the owner — redirect, or proxy to the owner transparently
+			if err := s.redirect(key); err != nil {
+				return nil, err
+			}
 			if s.nodeCommunicator != nil {
 				ownerNode := routing.RouteKey(key)
 				if ownerNode != "" {
-					err := s.nodeCommunicator.ProxySet(context.Background(), ownerNode, key, string(value), ttl.Seconds())
+					err := s.nodeCommunicator.ProxySet(clientConn.requestContext(), ownerNode, key, string(value), ttl.Seconds())
 					if err != nil {
 						return nil, fmt.Errorf("failed to proxy SET to owner %s: %w", ownerNode, err)
 					}
-					return formatter.FormatSimpleString("OK"), nil
+					return clientConn.keepReply(formatter.AppendSimpleString(clientConn.replyBuffer(), "OK")), nil
 				}
 			}
 			return nil, fmt.Errorf("cannot route key: no owner found")
 		}
 
 		// We ARE the owner (or a replica) — write locally
-		err := store.Set(key, value, "", ttl)
+		err := write(key, value, "", ttl)
+		if errors.Is(err, storage.ErrOOM) {
+			return typedReply(err)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to set key locally: %w", err)
 		}
 
-		// Replicate to hash-ring replicas
-		if s.nodeCommunicator != nil {
-			lamportTS := uint64(0)
-			if s.coord.GetClock() != nil {
-				lamportTS = s.coord.GetClock().Tick()
-			}
-
-			replicas := routing.GetReplicas(key, 3) // replication factor
-
-			if s.consistencyLevel == "quorum" {
-				// Quorum mode: wait for majority ACKs before returning OK
-				quorumSize := len(replicas)/2 + 1
-				acks, err := s.nodeCommunicator.ReplicateToReplicasQuorum(
-					context.Background(), replicas, key, string(value), ttl.Seconds(), lamportTS, quorumSize,
-				)
-				if err != nil {
-					return nil, fmt.Errorf("quorum write failed: %d/%d ACKs: %w", acks, quorumSize, err)
-				}
-			} else {
-				// Eventual mode: async fire-and-forget replication
-				go func() {
-					for _, replica := range replicas {
-						if replica == s.coord.GetLocalNodeID() {
-							continue
-						}
-						_ = s.nodeCommunicator.ReplicateEntry(
-							context.Background(), replica, key, string(value), ttl.Seconds(), lamportTS,
-						)
-					}
-				}()
+		// Replicate to hash-ring replicas, with the expiry the key kept
+		if keepTTL {
+			ttl = storage.NoExpiry
+			if remaining, ok := store.TTL(key); ok && remaining > 0 {
+				ttl = remaining
 			}
 		}
+		if err := s.replicateSet(clientConn, key, value, ttl); err != nil {
+			return nil, err
+		}
 
-		return formatter.FormatSimpleString("OK"), nil
+		return clientConn.keepReply(formatter.AppendSimpleString(clientConn.replyBuffer(), "OK")), nil
 	}
 
 	// Standalone mode — just write locally
-	err := store.Set(key, value, "", ttl)
+	err := write(key, value, "", ttl)
+	if errors.Is(err, storage.ErrOOM) {
+		return typedReply(err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to set key locally: %w", err)
 	}
 
-	return formatter.FormatSimpleString("OK"), nil
+	return clientConn.keepReply(formatter.AppendSimpleString(clientConn.replyBuffer(), "OK")), nil
+}
+
+// handleCAS handles CAS key expected new [EX seconds]: set new only if the
+// current value equals expected. The key must be owned or replicated locally;
+// successful swaps are replicated like SET.
+func (s *Server) handleCAS(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 3 && len(cmd.Args) != 5 {
+		return nil, fmt.Errorf("wrong number of arguments for CAS")
+	}
+
+	key := cmd.Args[0]
+	expected := []byte(cmd.Args[1])
+	value := []byte(cmd.Args[2])
+
+	var ttl time.Duration
+	if len(cmd.Args) == 5 {
+		if strings.ToUpper(cmd.Args[3]) != "EX" {
+			return nil, fmt.Errorf("syntax error")
+		}
+		seconds, err := strconv.Atoi(cmd.Args[4])
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid expire time")
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	swapped, err := store.CompareAndSet(key, expected, value, ttl)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	if !swapped {
+		return formatter.FormatInteger(0), nil
+	}
+
+	if s.coord != nil && s.coord.GetRouting() != nil {
+		if err := s.replicateSet(clientConn, key, value, ttl); err != nil {
+			return nil, err
+		}
+	}
+	return formatter.FormatInteger(1), nil
+}
+
+// replicateSet copies a locally written value to the key's hash-ring replicas.
+// In quorum mode it waits for a majority of ACKs; otherwise replication is
+// asynchronous and never fails the write.
+func (s *Server) replicateSet(clientConn *ClientConn, key string, value []byte, ttl time.Duration) error {
+	if s.nodeCommunicator == nil {
+		return nil
+	}
+
+	lamportTS := uint64(0)
+	if s.coord.GetClock() != nil {
+		lamportTS = s.coord.GetClock().Tick()
+	}
+
+	replicas := s.coord.GetRouting().GetReplicas(key, 3) // replication factor
+
+	if s.consistencyLevel == "quorum" {
+		// Quorum mode: wait for majority ACKs before returning OK
+		quorumSize := len(replicas)/2 + 1
+		acks, err := s.nodeCommunicator.ReplicateToReplicasQuorum(
+			clientConn.requestContext(), replicas, key, string(value), ttl.Seconds(), lamportTS, quorumSize,
+		)
+		if err != nil {
+			return fmt.Errorf("quorum write failed: %d/%d ACKs: %w", acks, quorumSize, err)
+		}
+	} else {
+		// Eventual mode: async fire-and-forget replication
+		ctx := clientConn.requestContext()
+		go func() {
+			for _, replica := range replicas {
+				if replica == s.coord.GetLocalNodeID() {
+					continue
+				}
+				s.replicateEntry(ctx, replica, key, string(value), ttl.Seconds(), lamportTS)
+			}
+		}()
+	}
+	return nil
+}
+
+// replicateEntry sends a write (nil value = delete) to one replica and logs
+// the outcome under the command's correlation ID. Failures are not returned:
+// asynchronous replication never fails the client's write.
+func (s *Server) replicateEntry(ctx context.Context, replica, key string, value interface{}, ttlSeconds float64, lamportTS uint64) {
+	operation := "SET"
+	if value == nil {
+		operation = "DELETE"
+	}
+	fields := map[string]interface{}{
+		"operation":  operation,
+		"key":        key,
+		"replica":    replica,
+		"lamport_ts": lamportTS,
+	}
+
+	if err := s.nodeCommunicator.ReplicateEntry(ctx, replica, key, value, ttlSeconds, lamportTS); err != nil {
+		fields["error"] = err.Error()
+		logging.Warn(ctx, logging.ComponentRESP, logging.ActionReplication, "Replication to replica failed", fields)
+		return
+	}
+	logging.DebugSampled(ctx, logging.ComponentRESP, logging.ActionReplication, "Replicated "+operation+" to replica", fields)
 }
 
 func (s *Server) handleDel(clientConn *ClientConn, cmd Command) ([]byte, error) {
@@ -580,46 +1169,27 @@ func (s *Server) handleDel(clientConn *ClientConn, cmd Command) ([]byte, error)
 	deleted := int64(0)
 	store := s.getActiveStore(clientConn)
 
+	// Resolve the owner of every key before mutating anything, so a key we
+	// cannot route never leaves the earlier keys half-deleted.
+	remoteOwners, crossSlot, err := s.routeKeys(cmd.Args)
+	if err != nil || crossSlot != nil {
+		return crossSlot, err
+	}
+
 	for _, key := range cmd.Args {
-		// Hash-ring routing for DEL
-		if s.coord != nil && s.coord.GetRouting() != nil {
-			routing := s.coord.GetRouting()
-			if !routing.IsLocal(key) && !routing.IsReplica(key) {
-				// Proxy to owner
-				if s.nodeCommunicator != nil {
-					ownerNode := routing.RouteKey(key)
-					if ownerNode != "" {
-						existed, err := s.nodeCommunicator.ProxyDelete(context.Background(), ownerNode, key)
-						if err == nil && existed {
-							deleted++
-						}
-						continue
-					}
-				}
-				continue
+		// Proxy keys owned by another node
+		if ownerNode, remote := remoteOwners[key]; remote {
+			existed, err := s.nodeCommunicator.ProxyDelete(clientConn.requestContext(), ownerNode, key)
+			if err == nil && existed {
+				deleted++
 			}
+			continue
 		}
 
 		err := store.Delete(key)
 		if err == nil {
 			deleted++
-
-			// Replicate DELETE to hash-ring replicas (synchronous for consistency)
-			if s.coord != nil && s.nodeCommunicator != nil && s.coord.GetRouting() != nil {
-				lamportTS := uint64(0)
-				if s.coord.GetClock() != nil {
-					lamportTS = s.coord.GetClock().Tick()
-				}
-				replicas := s.coord.GetRouting().GetReplicas(key, 3)
-				for _, replica := range replicas {
-					if replica == s.coord.GetLocalNodeID() {
-						continue
-					}
-					_ = s.nodeCommunicator.ReplicateEntry(
-						context.Background(), replica, key, nil, 0, lamportTS,
-					)
-				}
-			}
+			s.replicateDelete(clientConn, key)
 		}
 	}
 
@@ -627,6 +1197,96 @@ func (s *Server) handleDel(clientConn *ClientConn, cmd Command) ([]byte, error)
 	return formatter.FormatInteger(deleted), nil
 }
 
+// replicateDelete sends a DELETE of key to its hash-ring replicas
+// (synchronous for consistency)
+func (s *Server) replicateDelete(clientConn *ClientConn, key string) {
+	if s.coord == nil || s.nodeCommunicator == nil || s.coord.GetRouting() == nil {
+		return
+	}
+	lamportTS := uint64(0)
+	if s.coord.GetClock() != nil {
+		lamportTS = s.coord.GetClock().Tick()
+	}
+	replicas := s.coord.GetRouting().GetReplicas(key, 3)
+	for _, replica := range replicas {
+		if replica == s.coord.GetLocalNodeID() {
+			continue
+		}
+		s.replicateEntry(clientConn.requestContext(), replica, key, nil, 0, lamportTS)
+	}
+}
+
+// replicateExpiry sends key's value with its new TTL to its hash-ring
+// replicas after EXPIRE or PERSIST. The value goes with its type and
+// session, so the replicas' copy changes only in its expiry. Synchronous,
+// like replicateDelete.
+func (s *Server) replicateExpiry(clientConn *ClientConn, store *storage.BasicStore, key string) {
+	if s.coord == nil || s.nodeCommunicator == nil || s.coord.GetRouting() == nil {
+		return
+	}
+	exported, ok := store.Export(key)
+	if !ok {
+		return
+	}
+	lamportTS := uint64(0)
+	if s.coord.GetClock() != nil {
+		lamportTS = s.coord.GetClock().Tick()
+	}
+	entry := cluster.MigrationEntry{
+		Key:       key,
+		Value:     exported.Value,
+		ValueType: exported.ValueType,
+		SessionID: exported.SessionID,
+		TTL:       exported.TTL.Seconds(),
+		LamportTS: lamportTS,
+	}
+	if store != s.store {
+		entry.Store = clientConn.selectedStore
+	}
+
+	ctx := clientConn.requestContext()
+	for _, replica := range s.coord.GetRouting().GetReplicas(key, 3) {
+		if replica == s.coord.GetLocalNodeID() {
+			continue
+		}
+		if err := s.nodeCommunicator.ReplicateBatch(ctx, replica, []cluster.MigrationEntry{entry}); err != nil {
+			logging.Warn(ctx, logging.ComponentRESP, logging.ActionReplication, "Replication to replica failed", map[string]interface{}{
+				"operation": "EXPIRE",
+				"key":       key,
+				"replica":   replica,
+				"error":     err.Error(),
+			})
+		}
+	}
+}
+
+// handleGetDel handles GETDEL key: return the value and delete the key in
+// one step, so a one-time token is consumed exactly once. The key must be
+// owned by this node.
+func (s *Server) handleGetDel(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for GETDEL")
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	formatter := NewFormatter()
+	value, found, err := store.GetDel(key)
+	if err != nil {
+		return typedReply(err)
+	}
+	if !found {
+		return formatter.FormatNull(), nil
+	}
+
+	s.replicateDelete(clientConn, key)
+	return s.formatGetValue(formatter, value), nil
+}
+
 func (s *Server) handleExists(clientConn *ClientConn, cmd Command) ([]byte, error) {
 	if len(cmd.Args) == 0 {
 		return nil, fmt.Errorf("wrong number of arguments for EXISTS")
@@ -635,26 +1295,21 @@ func (s *Server) handleExists(clientConn *ClientConn, cmd Command) ([]byte, erro
 	count := int64(0)
 	store := s.getActiveStore(clientConn)
 
+	remoteOwners, crossSlot, err := s.routeKeys(cmd.Args)
+	if err != nil || crossSlot != nil {
+		return crossSlot, err
+	}
+
 	for _, key := range cmd.Args {
-		// Hash-ring routing
-		if s.coord != nil && s.coord.GetRouting() != nil {
-			routing := s.coord.GetRouting()
-			if !routing.IsLocal(key) && !routing.IsReplica(key) {
-				if s.nodeCommunicator != nil {
-					ownerNode := routing.RouteKey(key)
-					if ownerNode != "" {
-						val, found, err := s.nodeCommunicator.ProxyGet(context.Background(), ownerNode, key)
-						if err == nil && found && val != nil {
-							count++
-						}
-					}
-				}
-				continue
+		if ownerNode, remote := remoteOwners[key]; remote {
+			val, found, err := s.nodeCommunicator.ProxyGet(clientConn.requestContext(), ownerNode, key)
+			if err == nil && found && val != nil {
+				count++
 			}
+			continue
 		}
 
-		_, err := store.Get(key)
-		if err == nil {
+		if store.Has(key) {
 			count++
 		}
 	}
@@ -663,27 +1318,130 @@ func (s *Server) handleExists(clientConn *ClientConn, cmd Command) ([]byte, erro
 	return formatter.FormatInteger(count), nil
 }
 
-func (s *Server) handleTTL(cmd Command) ([]byte, error) {
+func (s *Server) handleTTL(clientConn *ClientConn, cmd Command) ([]byte, error) {
 	if len(cmd.Args) != 1 {
 		return nil, fmt.Errorf("wrong number of arguments for TTL")
 	}
 
-	// Direct store access (simplified - TTL not implemented yet)
+	key := cmd.Args[0]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
 	formatter := NewFormatter()
-	return formatter.FormatInteger(-2), nil // Not implemented
+	ttl, ok := store.TTL(key)
+	if !ok {
+		return formatter.FormatInteger(-2), nil
+	}
+	if ttl < 0 {
+		return formatter.FormatInteger(-1), nil
+	}
+	return formatter.FormatInteger(int64((ttl + time.Second - 1) / time.Second)), nil
 }
 
-func (s *Server) handleExpire(cmd Command) ([]byte, error) {
+func (s *Server) handleExpire(clientConn *ClientConn, cmd Command) ([]byte, error) {
 	if len(cmd.Args) != 2 {
 		return nil, fmt.Errorf("wrong number of arguments for EXPIRE")
 	}
 
-	// Direct store access (simplified)
+	key := cmd.Args[0]
+	seconds, err := strconv.Atoi(cmd.Args[1])
+	if err != nil {
+		return nil, fmt.Errorf("value is not an integer or out of range")
+	}
+	if err := s.checkOwnedKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	formatter := NewFormatter()
+
+	// A non-positive TTL deletes the key, as in Redis
+	if seconds <= 0 {
+		if store.Delete(key) != nil {
+			return formatter.FormatInteger(0), nil
+		}
+		s.replicateDelete(clientConn, key)
+		return formatter.FormatInteger(1), nil
+	}
+
+	if !store.Expire(key, time.Duration(seconds)*time.Second) {
+		return formatter.FormatInteger(0), nil
+	}
+	s.replicateExpiry(clientConn, store, key)
+	return formatter.FormatInteger(1), nil
+}
+
+func (s *Server) handlePersist(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for PERSIST")
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkOwnedKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
 	formatter := NewFormatter()
-	return formatter.FormatInteger(0), nil // Not implemented
+	if !store.Persist(key) {
+		return formatter.FormatInteger(0), nil
+	}
+	s.replicateExpiry(clientConn, store, key)
+	return formatter.FormatInteger(1), nil
+}
+
+// handleDelSession handles DELSESSION sessionid: delete every key of the
+// selected store written under the session, e.g. on logout. Returns the number
+// of keys deleted. Only this node's keys are deleted; they are not replicated.
+func (s *Server) handleDelSession(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for DELSESSION")
+	}
+
+	store := s.getActiveStore(clientConn)
+	deleted, err := store.DeleteSession(cmd.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(deleted)), nil
+}
+
+// handlePin handles PIN key and UNPIN key. Pinned keys are never evicted
+// under memory pressure; once memory is taken up by pinned keys, writes fail
+// with OOM. Returns 1 if the key exists, 0 otherwise.
+func (s *Server) handlePin(clientConn *ClientConn, cmd Command, pin bool) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for %s", strings.ToUpper(cmd.Name))
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	formatter := NewFormatter()
+	var exists bool
+	if pin {
+		exists = store.Pin(key)
+	} else {
+		exists = store.Unpin(key)
+	}
+	if exists {
+		return formatter.FormatInteger(1), nil
+	}
+	return formatter.FormatInteger(0), nil
 }
 
 func (s *Server) handlePing(cmd Command) ([]byte, error) {
+	if len(cmd.Args) > 1 {
+		return nil, fmt.Errorf("wrong number of arguments for PING")
+	}
+
 	formatter := NewFormatter()
 
 	if len(cmd.Args) == 0 {
@@ -694,8 +1452,25 @@ func (s *Server) handlePing(cmd Command) ([]byte, error) {
 	return formatter.FormatBulkString(cmd.Args[0]), nil
 }
 
-func (s *Server) handleInfo(cmd Command) ([]byte, error) {
+func (s *Server) handleEcho(cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for ECHO")
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatBulkString(cmd.Args[0]), nil
+}
+
+func (s *Server) handleInfo(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	formatter := NewFormatter()
+	if len(cmd.Args) == 1 && strings.EqualFold(cmd.Args[0], "commandstats") {
+		return formatter.FormatBulkString(s.commandStatsInfo()), nil
+	}
+
 	stats := s.GetStats()
+	s.connMutex.RLock()
+	maxClients := s.config.MaxConnections
+	s.connMutex.RUnlock()
 
 	info := fmt.Sprintf("# Server\n"+
 		"redis_version:7.0.0\n"+
@@ -711,37 +1486,86 @@ func (s *Server) handleInfo(cmd Command) ([]byte, error) {
 		"\n"+
 		"# Stats\n"+
 		"total_connections_received:%d\n"+
+		"rejected_connections:%d\n"+
 		"total_commands_processed:%d\n"+
 		"instantaneous_ops_per_sec:0\n"+
 		"total_net_input_bytes:%d\n"+
-		"total_net_output_bytes:%d\n",
+		"total_net_output_bytes:%d\n"+
+		"\n"+
+		"%s"+
+		"\n"+
+		"%s"+
+		"\n"+
+		"%s",
 		0, // Process ID placeholder
 		s.address,
 		stats.ActiveConnections,
-		s.config.MaxConnections,
+		maxClients,
 		stats.TotalConnections,
+		stats.RejectedConnections,
 		stats.CommandsProcessed,
 		stats.BytesReceived,
 		stats.BytesSent,
+		s.memoryInfo(clientConn),
+		s.replicationInfo(),
+		s.commandStatsInfo(),
 	)
 
-	formatter := NewFormatter()
 	return formatter.FormatBulkString(info), nil
 }
 
-func (s *Server) handleStats(cmd Command) ([]byte, error) {
+// memoryInfo is INFO's memory section: the selected store's memory and
+// Go heap fragmentation, under Redis's allocator_* field names
+func (s *Server) memoryInfo(clientConn *ClientConn) string {
+	frag := storage.ReadHeapFragmentation()
+	return fmt.Sprintf("# Memory\n"+
+		"used_memory:%d\n"+
+		"allocator_allocated:%d\n"+
+		"allocator_active:%d\n"+
+		"allocator_resident:%d\n"+
+		"allocator_frag_bytes:%d\n"+
+		"mem_fragmentation_ratio:%.2f\n",
+		s.getActiveStore(clientConn).Memory(),
+		frag.Allocated,
+		frag.Active,
+		frag.Resident,
+		frag.Free,
+		frag.Ratio,
+	)
+}
+
+func (s *Server) handleStats(clientConn *ClientConn, cmd Command) ([]byte, error) {
 	stats := s.GetStats()
+	formatter := NewFormatter()
 
 	result := [][]byte{
-		NewFormatter().FormatBulkString(fmt.Sprintf("total_connections:%d", stats.TotalConnections)),
-		NewFormatter().FormatBulkString(fmt.Sprintf("active_connections:%d", stats.ActiveConnections)),
-		NewFormatter().FormatBulkString(fmt.Sprintf("commands_processed:%d", stats.CommandsProcessed)),
-		NewFormatter().FormatBulkString(fmt.Sprintf("errors_encountered:%d", stats.ErrorsEncountered)),
-		NewFormatter().FormatBulkString(fmt.Sprintf("bytes_sent:%d", stats.BytesSent)),
-		NewFormatter().FormatBulkString(fmt.Sprintf("bytes_received:%d", stats.BytesReceived)),
+		formatter.FormatBulkString(fmt.Sprintf("total_connections:%d", stats.TotalConnections)),
+		formatter.FormatBulkString(fmt.Sprintf("active_connections:%d", stats.ActiveConnections)),
+		formatter.FormatBulkString(fmt.Sprintf("rejected_connections:%d", stats.RejectedConnections)),
+		formatter.FormatBulkString(fmt.Sprintf("reaped_connections:%d", stats.ReapedConnections)),
+		formatter.FormatBulkString(fmt.Sprintf("commands_processed:%d", stats.CommandsProcessed)),
+		formatter.FormatBulkString(fmt.Sprintf("errors_encountered:%d", stats.ErrorsEncountered)),
+		formatter.FormatBulkString(fmt.Sprintf("bytes_sent:%d", stats.BytesSent)),
+		formatter.FormatBulkString(fmt.Sprintf("bytes_received:%d", stats.BytesReceived)),
+	}
+
+	// Hit rates and key removals of the selected store
+	store := s.getActiveStore(clientConn)
+	storeStats := store.Stats()
+	result = append(result,
+		formatter.FormatBulkString(fmt.Sprintf("hit_rate:%.2f", storeStats.HitRate())),
+		formatter.FormatBulkString(fmt.Sprintf("recent_hit_rate:%.2f", store.RecentHitRate())),
+		formatter.FormatBulkString(fmt.Sprintf("expired_keys:%d", storeStats.ExpiredCount)),
+		formatter.FormatBulkString(fmt.Sprintf("evicted_keys:%d", storeStats.MemoryEvictionCount)),
+		formatter.FormatBulkString(fmt.Sprintf("deleted_keys:%d", storeStats.ManualDeleteCount)),
+	)
+
+	// Keys of the selected store by remaining TTL
+	histogram := store.TTLHistogram()
+	for _, bucket := range storage.TTLHistogramBuckets {
+		result = append(result, formatter.FormatBulkString(fmt.Sprintf("ttl_%s:%d", bucket, histogram[bucket])))
 	}
 
-	formatter := NewFormatter()
 	return formatter.FormatArray(result), nil
 }
 
@@ -755,11 +1579,21 @@ func (s *Server) handleConfig(cmd Command) ([]byte, error) {
 		// For unknown params, return the param with an empty value.
 		var result [][]byte
 		for _, param := range cmd.Args[1:] {
+			value := ""
+			if strings.ToLower(param) == "notify-keyspace-events" {
+				value, _ = s.notifyFlagsRaw.Load().(string)
+			}
 			result = append(result, formatter.FormatBulkString(param))
-			result = append(result, formatter.FormatBulkString(""))
+			result = append(result, formatter.FormatBulkString(value))
 		}
 		return formatter.FormatArray(result), nil
 	}
+	if len(cmd.Args) == 3 && strings.ToUpper(cmd.Args[0]) == "SET" &&
+		strings.ToLower(cmd.Args[1]) == "notify-keyspace-events" {
+		if err := s.SetNotifyKeyspaceEvents(cmd.Args[2]); err != nil {
+			return nil, err
+		}
+	}
 	return formatter.FormatSimpleString("OK"), nil
 }
 
@@ -770,6 +1604,10 @@ func (s *Server) handleCommand(cmd Command) ([]byte, error) {
 		// COMMAND DOCS: return empty map-like array (no docs available)
 		return formatter.FormatArray(nil), nil
 	}
+	if len(cmd.Args) > 0 && strings.ToUpper(cmd.Args[0]) == "STATS" {
+		// COMMAND STATS: per-command calls and timings, as INFO commandstats
+		return s.formatCommandStats(), nil
+	}
 	// COMMAND with no args: return empty array (command list)
 	return formatter.FormatArray(nil), nil
 }
@@ -785,14 +1623,241 @@ func (s *Server) handleFlushAll(clientConn *ClientConn, cmd Command) ([]byte, er
 	return formatter.FormatSimpleString("OK"), nil
 }
 
+// handleMemory implements MEMORY USAGE <key> and MEMORY STATS for the active store
+func (s *Server) handleMemory(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for MEMORY")
+	}
+
+	store := s.getActiveStore(clientConn)
+	formatter := NewFormatter()
+
+	switch strings.ToUpper(cmd.Args[0]) {
+	case "USAGE":
+		// MEMORY USAGE key [SAMPLES count] — SAMPLES is accepted for compatibility
+		if len(cmd.Args) != 2 && len(cmd.Args) != 4 {
+			return nil, fmt.Errorf("wrong number of arguments for MEMORY USAGE")
+		}
+		usage, ok := store.MemoryUsage(cmd.Args[1])
+		if !ok {
+			return formatter.FormatNull(), nil
+		}
+		return formatter.FormatInteger(int64(usage)), nil
+
+	case "STATS":
+		stats := store.Stats()
+		poolStats := store.GetMemoryPoolStats()
+		poolInt := func(name string) int64 {
+			v, _ := poolStats[name].(int64)
+			return v
+		}
+		pressure, _ := poolStats["memory_pressure"].(float64)
+		allocations, _ := poolStats["active_allocations"].(int)
+
+		return formatter.FormatArray([][]byte{
+			formatter.FormatBulkString("keys.count"),
+			formatter.FormatInteger(int64(store.ActiveSize())),
+			formatter.FormatBulkString("dataset.bytes"),
+			formatter.FormatInteger(int64(stats.TotalMemory)),
+			formatter.FormatBulkString("pool.used"),
+			formatter.FormatInteger(poolInt("current_usage")),
+			formatter.FormatBulkString("pool.max"),
+			formatter.FormatInteger(poolInt("max_size")),
+			formatter.FormatBulkString("pool.allocations"),
+			formatter.FormatInteger(int64(allocations)),
+			formatter.FormatBulkString("pool.pressure"),
+			formatter.FormatBulkString(strconv.FormatFloat(pressure, 'f', 4, 64)),
+			formatter.FormatBulkString("evictions"),
+			formatter.FormatInteger(int64(stats.EvictionCount)),
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("unknown MEMORY subcommand '%s'", cmd.Args[0])
+	}
+}
+
+// handleDebug implements DEBUG subcommands. Currently:
+//
+//	DEBUG TOPKEYS n [SAMPLES count] — the n largest keys as [key, bytes, ttl_seconds]
+//	                                  (ttl -1 = no expiry; SAMPLES 0 scans every key)
+//	DEBUG RELOAD                    — snapshot the store, clear it and reload it from
+//	                                  the snapshot; errors listing keys that changed
+//	DEBUG SET-ACTIVE-EXPIRE 0|1     — pause or resume removal of expired keys in every
+//	                                  store (they still read as missing)
+func (s *Server) handleDebug(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for DEBUG")
+	}
+
+	formatter := NewFormatter()
+
+	switch strings.ToUpper(cmd.Args[0]) {
+	case "TOPKEYS":
+		if len(cmd.Args) != 2 && len(cmd.Args) != 4 {
+			return nil, fmt.Errorf("wrong number of arguments for DEBUG TOPKEYS")
+		}
+		n, err := strconv.Atoi(cmd.Args[1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid count '%s'", cmd.Args[1])
+		}
+		samples := storage.DefaultTopKeysSampleSize
+		if len(cmd.Args) == 4 {
+			if strings.ToUpper(cmd.Args[2]) != "SAMPLES" {
+				return nil, fmt.Errorf("syntax error")
+			}
+			samples, err = strconv.Atoi(cmd.Args[3])
+			if err != nil || samples < 0 {
+				return nil, fmt.Errorf("invalid sample count '%s'", cmd.Args[3])
+			}
+		}
+
+		store := s.getActiveStore(clientConn)
+		top := store.TopKeys(n, samples)
+		entries := make([][]byte, 0, len(top))
+		for _, info := range top {
+			ttl := int64(-1)
+			if info.TTL > 0 {
+				ttl = int64(info.TTL.Seconds())
+			}
+			entries = append(entries, formatter.FormatArray([][]byte{
+				formatter.FormatBulkString(info.Key),
+				formatter.FormatInteger(int64(info.Size)),
+				formatter.FormatInteger(ttl),
+			}))
+		}
+		return formatter.FormatArray(entries), nil
+
+	case "RELOAD":
+		if len(cmd.Args) != 1 {
+			return nil, fmt.Errorf("wrong number of arguments for DEBUG RELOAD")
+		}
+		failed, err := s.getActiveStore(clientConn).DebugReload()
+		if err != nil {
+			return nil, fmt.Errorf("DEBUG RELOAD failed: %v", err)
+		}
+		if len(failed) > 0 {
+			return nil, fmt.Errorf("DEBUG RELOAD: %d keys did not round-trip: %s", len(failed), strings.Join(failed, " "))
+		}
+		return formatter.FormatSimpleString("OK"), nil
+
+	case "POPULATE":
+		// DEBUG POPULATE count [prefix] writes straight to the selected
+		// store, without replication or key routing, for load tests
+		if len(cmd.Args) != 2 && len(cmd.Args) != 3 {
+			return nil, fmt.Errorf("wrong number of arguments for DEBUG POPULATE")
+		}
+		count, err := strconv.Atoi(cmd.Args[1])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid count '%s'", cmd.Args[1])
+		}
+		prefix := "key"
+		if len(cmd.Args) == 3 {
+			prefix = cmd.Args[2]
+		}
+		if err := s.debugPopulate(clientConn, s.getActiveStore(clientConn), count, prefix); err != nil {
+			return nil, err
+		}
+		return formatter.FormatSimpleString("OK"), nil
+
+	case "SET-ACTIVE-EXPIRE":
+		if len(cmd.Args) != 2 || (cmd.Args[1] != "0" && cmd.Args[1] != "1") {
+			return nil, fmt.Errorf("DEBUG SET-ACTIVE-EXPIRE takes 0 or 1")
+		}
+		enabled := cmd.Args[1] == "1"
+		if s.storeManager != nil {
+			s.storeManager.SetActiveExpire(enabled)
+		} else {
+			s.store.SetActiveExpire(enabled)
+		}
+		return formatter.FormatSimpleString("OK"), nil
+
+	default:
+		return nil, fmt.Errorf("unknown DEBUG subcommand '%s'", cmd.Args[0])
+	}
+}
+
+// debugPopulate sets prefix:0 to prefix:count-1 to value:0 to
+// value:count-1, as Redis's DEBUG POPULATE, and logs how long it took.
+// Keys that already exist are left as they are.
+func (s *Server) debugPopulate(clientConn *ClientConn, store *storage.BasicStore, count int, prefix string) error {
+	start := time.Now()
+	added := 0
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("%s:%d", prefix, i)
+		if store.Has(key) {
+			continue
+		}
+		if err := store.Set(key, fmt.Sprintf("value:%d", i), "", 0); err != nil {
+			return fmt.Errorf("DEBUG POPULATE stopped after %d keys: %v", added, err)
+		}
+		added++
+	}
+
+	logging.Info(clientConn.requestContext(), logging.ComponentRESP, logging.ActionRequest, "Populated store", map[string]interface{}{
+		"prefix":      prefix,
+		"keys":        added,
+		"skipped":     count - added,
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+	return nil
+}
+
 func (s *Server) handleDBSize(clientConn *ClientConn, cmd Command) ([]byte, error) {
 	store := s.getActiveStore(clientConn)
-	size := store.Size() // BasicStore.Size() returns uint64
+	size := store.ActiveSize() // excludes expired keys not yet swept
 	formatter := NewFormatter()
 	return formatter.FormatInteger(int64(size)), nil
 }
 
 // handleSelect switches the connection to a different store (SELECT <store_name>)
+// handleClient handles CLIENT SETNAME, GETNAME and ID
+func (s *Server) handleClient(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for CLIENT")
+	}
+
+	formatter := NewFormatter()
+	switch strings.ToUpper(cmd.Args[0]) {
+	case "SETNAME":
+		if len(cmd.Args) != 2 {
+			return nil, fmt.Errorf("wrong number of arguments for CLIENT SETNAME")
+		}
+		if strings.ContainsAny(cmd.Args[1], " \n") {
+			return nil, fmt.Errorf("Client names cannot contain spaces, newlines or special characters.")
+		}
+		clientConn.name = cmd.Args[1]
+		return formatter.FormatSimpleString("OK"), nil
+	case "GETNAME":
+		if clientConn.name == "" {
+			return formatter.FormatNull(), nil
+		}
+		return formatter.FormatBulkString(clientConn.name), nil
+	case "ID":
+		return formatter.FormatInteger(int64(clientConn.id)), nil
+	default:
+		return nil, fmt.Errorf("unknown CLIENT subcommand '%s'", cmd.Args[0])
+	}
+}
+
+// handleReset returns the connection to its initial state so pooled clients
+// can reuse it: unsubscribes from all channels, discards any transaction and
+// watched keys, deselects the active store and clears the client name.
+func (s *Server) handleReset(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for RESET")
+	}
+
+	s.pubsub.UnsubscribeAll(clientConn)
+	s.unwatchAll(clientConn)
+	clientConn.inMulti.Store(false)
+	clientConn.queued = nil
+	clientConn.selectedStore = ""
+	clientConn.name = ""
+
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("RESET"), nil
+}
+
 func (s *Server) handleSelect(clientConn *ClientConn, cmd Command) ([]byte, error) {
 	if len(cmd.Args) != 1 {
 		return nil, fmt.Errorf("wrong number of arguments for SELECT")
@@ -844,7 +1909,11 @@ func (s *Server) handleStores(cmd Command) ([]byte, error) {
 func (s *Server) connectionCleaner() {
 	defer s.wg.Done()
 
-	ticker := time.NewTicker(time.Minute)
+	interval := s.config.IdleCheckInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -857,22 +1926,34 @@ func (s *Server) connectionCleaner() {
 	}
 }
 
-// clusterEventListener listens for cluster events and applies data operations
-// cleanupIdleConnections removes idle connections
+// cleanupIdleConnections removes idle connections. Subscribers, which wait
+// for messages without sending commands, and connections in the middle of
+// a MULTI are left alone.
 func (s *Server) cleanupIdleConnections() {
 	if s.config.IdleTimeout <= 0 {
 		return
 	}
 
-	now := time.Now()
+	cutoff := time.Now().Add(-s.config.IdleTimeout).UnixNano()
 
 	s.connMutex.Lock()
 	defer s.connMutex.Unlock()
 
 	for conn, clientConn := range s.connections {
-		if now.Sub(clientConn.lastUsed) > s.config.IdleTimeout {
-			conn.Close()
-			delete(s.connections, conn)
+		if clientConn.lastUsed.Load() >= cutoff {
+			continue
+		}
+		if clientConn.inMulti.Load() || s.pubsub.SubscriptionCount(clientConn) > 0 {
+			continue
+		}
+		if s.config.IdleTimeoutReply {
+			// The connection's goroutine is blocked reading, so nothing
+			// else is writing; the deadline covers a peer not reading
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			conn.Write(NewFormatter().FormatError("ERR idle timeout"))
 		}
+		conn.Close()
+		delete(s.connections, conn)
+		atomic.AddUint64(&s.stats.ReapedConnections, 1)
 	}
 }
@@ -2,15 +2,26 @@ package resp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"hypercache/internal/cluster"
+	"hypercache/internal/logging"
 	"hypercache/internal/storage"
+	"hypercache/pkg/config"
 )
 
 // Mock coordinator for testing (minimal implementation)
@@ -30,6 +41,135 @@ func (m *mockCoordinator) GetMetrics() cluster.CoordinatorMetrics {
 	return cluster.CoordinatorMetrics{}
 }
 
+// Mock routing that treats keys prefixed with "remote:" as owned by another node
+type mockRouting struct{}
+
+func (r *mockRouting) RouteKey(key string) string {
+	if strings.HasPrefix(key, "remote:") {
+		return "other-node"
+	}
+	return "test-node"
+}
+func (r *mockRouting) GetReplicas(key string, count int) []string { return []string{r.RouteKey(key)} }
+func (r *mockRouting) IsLocal(key string) bool                    { return r.RouteKey(key) == "test-node" }
+func (r *mockRouting) IsReplica(key string) bool                  { return false }
+func (r *mockRouting) GetKeysForNode(nodeID string, allKeys []string) []string {
+	return nil
+}
+func (r *mockRouting) AnalyzeDistribution(keys []string) cluster.DistributionStats {
+	return cluster.DistributionStats{}
+}
+func (r *mockRouting) GetMetrics() cluster.HashRingMetrics { return cluster.HashRingMetrics{} }
+
+// Mock coordinator with routing but no peers reachable
+type mockRoutedCoordinator struct {
+	mockCoordinator
+}
+
+func (m *mockRoutedCoordinator) GetRouting() cluster.RoutingProvider { return &mockRouting{} }
+
+// Mock routing that replicates every local key to "replica-node"
+type mockReplicatedRouting struct {
+	mockRouting
+}
+
+func (r *mockReplicatedRouting) GetReplicas(key string, count int) []string {
+	return []string{r.RouteKey(key), "replica-node"}
+}
+
+// Mock coordinator whose local keys are replicated to "replica-node"
+type mockReplicatedCoordinator struct {
+	mockCoordinator
+}
+
+func (m *mockReplicatedCoordinator) GetRouting() cluster.RoutingProvider {
+	return &mockReplicatedRouting{}
+}
+
+// Mock routing that also treats keys prefixed with "replica:" as owned by
+// another node and replicated here
+type mockReplicaOfRouting struct {
+	mockRouting
+}
+
+func (r *mockReplicaOfRouting) RouteKey(key string) string {
+	if strings.HasPrefix(key, "replica:") {
+		return "other-node"
+	}
+	return r.mockRouting.RouteKey(key)
+}
+func (r *mockReplicaOfRouting) IsLocal(key string) bool { return r.RouteKey(key) == "test-node" }
+func (r *mockReplicaOfRouting) IsReplica(key string) bool {
+	return strings.HasPrefix(key, "replica:")
+}
+
+// Mock coordinator holding replicas of "replica:" keys
+type mockReplicaOfCoordinator struct {
+	mockCoordinator
+}
+
+func (m *mockReplicaOfCoordinator) GetRouting() cluster.RoutingProvider {
+	return &mockReplicaOfRouting{}
+}
+
+// Mock coordinator routing keys with a real hash ring of "test-node" and
+// "other-node"; no peers are reachable
+type mockRingCoordinator struct {
+	mockCoordinator
+	ring       *cluster.HashRing
+	membership cluster.MembershipProvider
+}
+
+func (m *mockRingCoordinator) GetRouting() cluster.RoutingProvider {
+	return &mockRingRouting{ring: m.ring}
+}
+
+func (m *mockRingCoordinator) GetMembership() cluster.MembershipProvider { return m.membership }
+
+func (m *mockRingCoordinator) SlotNodes(slot uint16) []string { return m.ring.SlotReplicas(slot, 2) }
+
+// MigrateSlotRange assigns each migrated slot in the ring, as the
+// distributed coordinator does
+func (m *mockRingCoordinator) MigrateSlotRange(ctx context.Context, migrator *cluster.LocalDataMigrator, startSlot, endSlot uint16, toNode string) (*cluster.RebalanceResponse, error) {
+	migrator.OnSlotMigrated(func(slot uint16, toNode string) error {
+		return m.ring.AssignSlots(slot, slot, toNode)
+	})
+	return migrator.MigrateSlotRange(ctx, startSlot, endSlot, toNode)
+}
+
+// Decommission removes "test-node" from the ring, migrating its keys
+func (m *mockRingCoordinator) Decommission(ctx context.Context, migrator cluster.DataMigrator, keys []string) (*cluster.RebalanceResponse, error) {
+	return cluster.DecommissionNode(ctx, m.ring, nil, migrator, "test-node", keys)
+}
+
+func (m *mockRingCoordinator) ForgetNode(nodeID string) error { return m.ring.RemoveNode(nodeID) }
+
+type mockRingRouting struct {
+	mockRouting
+	ring *cluster.HashRing
+}
+
+func (r *mockRingRouting) RouteKey(key string) string { return r.ring.GetNode(key) }
+func (r *mockRingRouting) IsLocal(key string) bool    { return r.RouteKey(key) == "test-node" }
+
+// Mock membership with a fixed set of members
+type mockMembership struct {
+	members map[string]*cluster.ClusterMember
+}
+
+func (m *mockMembership) Join(ctx context.Context, seedNodes []string) error { return nil }
+func (m *mockMembership) Leave(ctx context.Context) error                    { return nil }
+func (m *mockMembership) GetMembers() []cluster.ClusterMember                { return nil }
+func (m *mockMembership) GetMember(nodeID string) (*cluster.ClusterMember, bool) {
+	member, ok := m.members[nodeID]
+	return member, ok
+}
+func (m *mockMembership) UpdateMetadata(metadata map[string]string) error { return nil }
+func (m *mockMembership) Subscribe() <-chan cluster.MembershipEvent       { return nil }
+func (m *mockMembership) GetMetrics() cluster.MembershipMetrics           { return cluster.MembershipMetrics{} }
+func (m *mockMembership) IsHealthy() bool                                 { return true }
+func (m *mockMembership) GetAliveNodes() []cluster.ClusterMember          { return nil }
+
 func newTestServer(t *testing.T) (*Server, func()) {
 	// Create BasicStore directly
 	config := storage.BasicStoreConfig{
@@ -131,6 +271,45 @@ func TestServer_BasicCommands(t *testing.T) {
 	if response != expectedPingMsg {
 		t.Errorf("PING with message: expected %q, got %q", expectedPingMsg, response)
 	}
+
+	// Test PING with too many arguments
+	sendCommand(t, conn, "*3\r\n$4\r\nPING\r\n$1\r\na\r\n$1\r\nb\r\n")
+	response = readResponse(t, conn)
+	if !strings.HasPrefix(response, "-ERR wrong number of arguments") {
+		t.Errorf("PING with two arguments: expected arity error, got %q", response)
+	}
+}
+
+func TestServer_Echo(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	payloads := []string{
+		"hello",
+		"",
+		"line1\r\nline2",
+		"\x00\xff\r\n\x01binary",
+	}
+	for _, payload := range payloads {
+		sendCommand(t, conn, fmt.Sprintf("*2\r\n$4\r\nECHO\r\n$%d\r\n%s\r\n", len(payload), payload))
+		response := readResponse(t, conn)
+		expected := fmt.Sprintf("$%d\r\n%s\r\n", len(payload), payload)
+		if response != expected {
+			t.Errorf("ECHO %q: expected %q, got %q", payload, expected, response)
+		}
+	}
+
+	// ECHO requires exactly one argument
+	sendCommand(t, conn, "*1\r\n$4\r\nECHO\r\n")
+	if response := readResponse(t, conn); !strings.HasPrefix(response, "-ERR wrong number of arguments") {
+		t.Errorf("ECHO without argument: expected arity error, got %q", response)
+	}
 }
 
 func TestServer_KeyValueCommands(t *testing.T) {
@@ -304,6 +483,9 @@ func TestServer_InfoCommand(t *testing.T) {
 	if !strings.Contains(response, "# Stats") {
 		t.Error("INFO should contain Stats section")
 	}
+	if !strings.Contains(response, "# Memory") || !strings.Contains(response, "mem_fragmentation_ratio:") {
+		t.Error("INFO should contain Memory section with the fragmentation ratio")
+	}
 }
 
 func TestServer_StatsCommand(t *testing.T) {
@@ -336,6 +518,75 @@ func TestServer_StatsCommand(t *testing.T) {
 	if !strings.Contains(response, "commands_processed") {
 		t.Error("STATS should contain commands_processed")
 	}
+	if !strings.Contains(response, "ttl_never:1\r\n") {
+		t.Errorf("STATS should count the key without a TTL, got: %s", response)
+	}
+}
+
+func TestServer_DBSizeExcludesExpiredKeys(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Three short-lived keys and one persistent key
+	for i := 1; i <= 3; i++ {
+		key := fmt.Sprintf("ttl_%d", i)
+		cmd := fmt.Sprintf("*5\r\n$3\r\nSET\r\n$%d\r\n%s\r\n$5\r\nvalue\r\n$2\r\nPX\r\n$2\r\n50\r\n", len(key), key)
+		sendCommand(t, conn, cmd)
+		readResponse(t, conn)
+	}
+	sendCommand(t, conn, "*3\r\n$3\r\nSET\r\n$4\r\nkeep\r\n$5\r\nvalue\r\n")
+	readResponse(t, conn)
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The cleanup loop runs once a minute, so the expired keys are still stored
+	if size := server.store.Size(); size != 4 {
+		t.Fatalf("Expected 4 unswept items before cleanup, got %d", size)
+	}
+
+	sendCommand(t, conn, "*1\r\n$6\r\nDBSIZE\r\n")
+	response := readResponse(t, conn)
+	if response != ":1\r\n" {
+		t.Errorf("DBSIZE after expiry: expected %q, got %q", ":1\r\n", response)
+	}
+}
+
+func TestServer_MemoryCommand(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	sendCommand(t, conn, "*3\r\n$3\r\nSET\r\n$4\r\nkey1\r\n$6\r\nvalue1\r\n")
+	readResponse(t, conn)
+
+	sendCommand(t, conn, "*3\r\n$6\r\nMEMORY\r\n$5\r\nUSAGE\r\n$4\r\nkey1\r\n")
+	response := readResponse(t, conn)
+	usage, _ := server.store.MemoryUsage("key1")
+	if expected := fmt.Sprintf(":%d\r\n", usage); response != expected {
+		t.Errorf("MEMORY USAGE: expected %q, got %q", expected, response)
+	}
+
+	sendCommand(t, conn, "*3\r\n$6\r\nMEMORY\r\n$5\r\nUSAGE\r\n$7\r\nmissing\r\n")
+	if response := readResponse(t, conn); response != "$-1\r\n" {
+		t.Errorf("MEMORY USAGE missing key: expected null, got %q", response)
+	}
+
+	sendCommand(t, conn, "*2\r\n$6\r\nMEMORY\r\n$5\r\nSTATS\r\n")
+	response = readResponse(t, conn)
+	if !strings.HasPrefix(response, "*") || !strings.Contains(response, "dataset.bytes") {
+		t.Errorf("MEMORY STATS: unexpected response %q", response)
+	}
 }
 
 func TestServer_FlushAllCommand(t *testing.T) {
@@ -464,112 +715,2683 @@ func TestServer_InvalidCommands(t *testing.T) {
 	}
 }
 
-// Helper functions
+func TestServer_DelMixedKeysNoPartialDelete(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
 
-func sendCommand(t *testing.T, conn net.Conn, cmd string) {
-	_, err := conn.Write([]byte(cmd))
+	// Route through a coordinator that owns only non-"remote:" keys and has
+	// no node communicator to proxy the rest
+	server.coord = &mockRoutedCoordinator{}
+
+	server.store.Set("local1", "value1", "test", 0)
+	server.store.Set("local2", "value2", "test", 0)
+
+	conn, err := net.Dial("tcp", server.address)
 	if err != nil {
-		t.Fatalf("Failed to send command: %v", err)
+		t.Fatalf("Failed to connect to server: %v", err)
 	}
-}
+	defer conn.Close()
 
-func readResponse(t *testing.T, conn net.Conn) string {
-	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	// Local keys first, then a key owned by another node
+	sendCommand(t, conn, "*4\r\n$3\r\nDEL\r\n$6\r\nlocal1\r\n$6\r\nlocal2\r\n$10\r\nremote:key\r\n")
+	response := readResponse(t, conn)
+	if !strings.HasPrefix(response, "-CROSSSLOT") {
+		t.Errorf("DEL mixed keys: expected CROSSSLOT error, got %q", response)
+	}
 
-	parser := NewParser(conn)
-	value, err := parser.Parse()
-	if err != nil {
-		t.Fatalf("Failed to read response: %v", err)
+	// Nothing should have been deleted
+	if !server.store.Has("local1") || !server.store.Has("local2") {
+		t.Error("DEL with unroutable key left a partial delete behind")
 	}
 
-	return string(value.Raw)
+	// Only remote keys and no way to reach the owner
+	sendCommand(t, conn, "*2\r\n$3\r\nDEL\r\n$10\r\nremote:key\r\n")
+	response = readResponse(t, conn)
+	if !strings.HasPrefix(response, "-ERR") {
+		t.Errorf("DEL remote key: expected error, got %q", response)
+	}
+
+	// Local keys in different slots are rejected too
+	sendCommand(t, conn, "*3\r\n$3\r\nDEL\r\n$6\r\nlocal1\r\n$6\r\nlocal2\r\n")
+	response = readResponse(t, conn)
+	if !strings.HasPrefix(response, "-CROSSSLOT") {
+		t.Errorf("DEL local keys across slots: expected CROSSSLOT error, got %q", response)
+	}
+	if !server.store.Has("local1") || !server.store.Has("local2") {
+		t.Error("DEL across slots deleted local keys")
+	}
+
+	// Local keys sharing a slot through a hash tag are deleted
+	server.store.Set("{t}1", "value1", "test", 0)
+	server.store.Set("{t}2", "value2", "test", 0)
+	sendCommand(t, conn, string(commandBytes("DEL", "{t}1", "{t}2")))
+	response = readResponse(t, conn)
+	if response != ":2\r\n" {
+		t.Errorf("DEL local keys: expected %q, got %q", ":2\r\n", response)
+	}
 }
 
-// Benchmark tests
+func TestServer_KeyspaceNotifications(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
 
-func BenchmarkServer_PingCommand(b *testing.B) {
-	config := storage.BasicStoreConfig{
-		Name:      "bench-store",
-		MaxMemory: 1024 * 1024,
+	if err := server.SetNotifyKeyspaceEvents("E$"); err != nil {
+		t.Fatalf("SetNotifyKeyspaceEvents failed: %v", err)
 	}
 
-	store, err := storage.NewBasicStore(config)
+	sub, err := net.Dial("tcp", server.address)
 	if err != nil {
-		b.Fatal(err)
+		t.Fatalf("Failed to connect subscriber: %v", err)
 	}
-	defer store.Close()
+	defer sub.Close()
 
-	coord := &mockCoordinator{}
-
-	server := NewServer(":0", store, coord)
-	server.Start()
-	defer server.Stop()
+	sendCommand(t, sub, "*2\r\n$9\r\nSUBSCRIBE\r\n$18\r\n__keyevent@0__:set\r\n")
+	subParser := NewParser(sub)
+	sub.SetReadDeadline(time.Now().Add(5 * time.Second))
+	value, err := subParser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to read SUBSCRIBE reply: %v", err)
+	}
+	if !strings.Contains(string(value.Raw), "subscribe") {
+		t.Fatalf("SUBSCRIBE: unexpected reply %q", string(value.Raw))
+	}
 
-	server.address = server.listener.Addr().String()
+	// Non pub/sub commands are rejected while subscribed
+	sendCommand(t, sub, "*2\r\n$3\r\nGET\r\n$4\r\nkey1\r\n")
+	value, err = subParser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to read GET reply: %v", err)
+	}
+	if !strings.HasPrefix(string(value.Raw), "-ERR") {
+		t.Errorf("GET in subscribed mode: expected error, got %q", string(value.Raw))
+	}
 
 	conn, err := net.Dial("tcp", server.address)
 	if err != nil {
-		b.Fatal(err)
+		t.Fatalf("Failed to connect to server: %v", err)
 	}
 	defer conn.Close()
 
-	cmd := "*1\r\n$4\r\nPING\r\n"
-	parser := NewParser(conn)
+	sendCommand(t, conn, "*3\r\n$3\r\nSET\r\n$4\r\nkey1\r\n$6\r\nvalue1\r\n")
+	if response := readResponse(t, conn); response != "+OK\r\n" {
+		t.Fatalf("SET: expected +OK, got %q", response)
+	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		conn.Write([]byte(cmd))
-		_, err := parser.Parse()
-		if err != nil {
-			b.Fatal(err)
-		}
+	value, err = subParser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to read keyspace notification: %v", err)
+	}
+	expected := "*3\r\n$7\r\nmessage\r\n$18\r\n__keyevent@0__:set\r\n$4\r\nkey1\r\n"
+	if string(value.Raw) != expected {
+		t.Errorf("Notification: expected %q, got %q", expected, string(value.Raw))
 	}
-}
 
-func BenchmarkServer_SetGetCommand(b *testing.B) {
-	config := storage.BasicStoreConfig{
-		Name:      "bench-store",
-		MaxMemory: 1024 * 1024,
+	// Plain PUBLISH reaches the same subscriber
+	sendCommand(t, conn, "*3\r\n$7\r\nPUBLISH\r\n$18\r\n__keyevent@0__:set\r\n$4\r\ndone\r\n")
+	if response := readResponse(t, conn); response != ":1\r\n" {
+		t.Errorf("PUBLISH: expected :1, got %q", response)
 	}
+	value, err = subParser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to read published message: %v", err)
+	}
+	if !strings.HasSuffix(string(value.Raw), "$4\r\ndone\r\n") {
+		t.Errorf("Published message: unexpected payload %q", string(value.Raw))
+	}
+}
 
-	store, err := storage.NewBasicStore(config)
+func TestServer_SlowSubscriberDoesNotBlockPublish(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Subscriber that never reads after the SUBSCRIBE reply
+	sub, err := net.Dial("tcp", server.address)
 	if err != nil {
-		b.Fatal(err)
+		t.Fatalf("Failed to connect subscriber: %v", err)
+	}
+	defer sub.Close()
+	sendCommand(t, sub, "*2\r\n$9\r\nSUBSCRIBE\r\n$4\r\nnews\r\n")
+	if response := readResponse(t, sub); !strings.Contains(response, "subscribe") {
+		t.Fatalf("SUBSCRIBE: unexpected reply %q", response)
 	}
-	defer store.Close()
 
-	coord := &mockCoordinator{}
+	// Enough data to fill the socket buffers and then the queue
+	message := strings.Repeat("x", 64*1024)
+	done := make(chan int)
+	go func() {
+		last := -1
+		for i := 0; i < 4*DefaultPubSubBuffer && last != 0; i++ {
+			last = server.pubsub.Publish("news", message)
+		}
+		done <- last
+	}()
 
-	server := NewServer(":0", store, coord)
-	server.Start()
-	defer server.Stop()
+	select {
+	case last := <-done:
+		if last != 0 {
+			t.Errorf("Expected the slow subscriber to be dropped, last Publish reached %d", last)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Publish blocked on a subscriber that doesn't read")
+	}
 
-	server.address = server.listener.Addr().String()
+	// Its connection was closed
+	sub.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(io.Discard, sub); err != nil {
+		t.Errorf("Expected the server to close the subscriber, got %v", err)
+	}
+}
+
+// Helper functions
+
+func TestServer_SetReplicationCarriesCorrelationID(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Fake replica capturing the correlation ID of each replication request
+	correlationIDs := make(chan string, 2)
+	replica := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal/replicate" {
+			correlationIDs <- r.Header.Get("X-Correlation-ID")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer replica.Close()
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(replica.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to parse replica address: %v", err)
+	}
+
+	server.coord = &mockReplicatedCoordinator{}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"replica-node": {NodeID: "replica-node", Address: host, Metadata: map[string]string{"http_port": port}},
+		},
+	}))
 
 	conn, err := net.Dial("tcp", server.address)
 	if err != nil {
-		b.Fatal(err)
+		t.Fatalf("Failed to connect to server: %v", err)
 	}
 	defer conn.Close()
 
-	setCmd := "*3\r\n$3\r\nSET\r\n$4\r\nkey1\r\n$6\r\nvalue1\r\n"
-	getCmd := "*2\r\n$3\r\nGET\r\n$4\r\nkey1\r\n"
-	parser := NewParser(conn)
+	var ids []string
+	for i := 0; i < 2; i++ {
+		sendCommand(t, conn, "*3\r\n$3\r\nSET\r\n$4\r\nkey1\r\n$6\r\nvalue1\r\n")
+		if response := readResponse(t, conn); response != "+OK\r\n" {
+			t.Fatalf("SET: expected +OK, got %q", response)
+		}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		// SET
-		conn.Write([]byte(setCmd))
-		_, err := parser.Parse()
-		if err != nil {
-			b.Fatal(err)
+		select {
+		case id := <-correlationIDs:
+			if id == "" {
+				t.Fatal("Replication request carried no correlation ID")
+			}
+			ids = append(ids, id)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for replication request")
 		}
+	}
 
-		// GET
-		conn.Write([]byte(getCmd))
-		_, err = parser.Parse()
-		if err != nil {
-			b.Fatal(err)
+	if ids[0] == ids[1] {
+		t.Errorf("Expected a fresh correlation ID per command, got %q twice", ids[0])
+	}
+}
+
+func TestServer_ExpireAndPersistReplicate(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Fake replica recording TTL changes and deletes
+	type replicated struct {
+		key     string
+		ttl     float64
+		deleted bool
+	}
+	received := make(chan replicated, 10)
+	replica := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal/replicate-batch":
+			var payload struct {
+				Entries []cluster.MigrationEntry `json:"entries"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			for _, entry := range payload.Entries {
+				received <- replicated{key: entry.Key, ttl: entry.TTL}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"applied": len(payload.Entries)})
+		case "/internal/replicate":
+			var payload map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload["value"] == nil {
+				received <- replicated{key: payload["key"].(string), deleted: true}
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer replica.Close()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(replica.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to parse replica address: %v", err)
+	}
+	server.coord = &mockReplicatedCoordinator{}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"replica-node": {NodeID: "replica-node", Address: host, Metadata: map[string]string{"http_port": port}},
+		},
+	}))
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	run := func(expected string, args ...string) {
+		t.Helper()
+		sendCommand(t, conn, string(commandBytes(args...)))
+		if response := readResponse(t, conn); response != expected {
+			t.Fatalf("%v: expected %q, got %q", args, expected, response)
 		}
 	}
+	next := func() replicated {
+		t.Helper()
+		select {
+		case r := <-received:
+			return r
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for replication request")
+		}
+		return replicated{}
+	}
+
+	run("+OK\r\n", "SET", "key1", "value1")
+	run(":1\r\n", "EXPIRE", "key1", "100")
+	if r := next(); r.key != "key1" || r.ttl <= 99 || r.ttl > 100 {
+		t.Errorf("EXPIRE replicated %+v, want key1 with a 100s TTL", r)
+	}
+	run(":1\r\n", "PERSIST", "key1")
+	if r := next(); r.key != "key1" || r.ttl != 0 || r.deleted {
+		t.Errorf("PERSIST replicated %+v, want key1 with no TTL", r)
+	}
+	run(":1\r\n", "EXPIRE", "key1", "0")
+	if r := next(); r.key != "key1" || !r.deleted {
+		t.Errorf("EXPIRE 0 replicated %+v, want a delete of key1", r)
+	}
+}
+
+// logCapture is a concurrency-safe writer collecting structured log lines
+type logCapture struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (c *logCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+// waitForEntry returns the entry with message logged under correlationID,
+// polling until timeout. Matching on the ID ignores late entries from
+// earlier tests' replication goroutines.
+func (c *logCapture) waitForEntry(t *testing.T, message, correlationID string) logging.LogEntry {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		lines := strings.Split(c.buf.String(), "\n")
+		c.mu.Unlock()
+		for _, line := range lines {
+			var entry logging.LogEntry
+			if json.Unmarshal([]byte(line), &entry) == nil && entry.Message == message && entry.CorrelationID == correlationID {
+				return entry
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for log entry %q with correlation ID %q", message, correlationID)
+	return logging.LogEntry{}
+}
+
+func TestServer_ErrorRepliesLogConnectionID(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	capture := &logCapture{}
+	logger := logging.NewLogger(logging.Config{Level: logging.DEBUG, BufferSize: 100})
+	logger.AddWriter(capture)
+	previous := logging.GetGlobalLogger()
+	logging.SetGlobalLogger(logger)
+	defer func() {
+		logging.SetGlobalLogger(previous)
+		logger.Close()
+	}()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Without error tokens the reply is unchanged
+	sendCommand(t, conn, "*1\r\n$3\r\nGET\r\n")
+	if response := readResponse(t, conn); response != "-ERR wrong number of arguments for GET\r\n" {
+		t.Errorf("GET arity: got %q", response)
+	}
+
+	sendCommand(t, conn, "*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$1\r\na\r\n")
+	readResponse(t, conn)
+
+	server.SetErrorTokens(true)
+	for _, tc := range []struct {
+		name, command, message string
+	}{
+		{"handler error", "*1\r\n$3\r\nGET\r\n", "ERR wrong number of arguments for GET"},
+		{"error reply", "*2\r\n$3\r\nGET\r\n$4\r\nlist\r\n", "WRONGTYPE Operation against a key holding the wrong kind of value"},
+	} {
+		sendCommand(t, conn, tc.command)
+		response := readResponse(t, conn)
+		var connID uint64
+		var cid string
+		if _, err := fmt.Sscanf(response, "-"+tc.message+" [conn=%d cid=%8s]\r\n", &connID, &cid); err != nil {
+			t.Fatalf("%s: reply %q lacks an error token: %v", tc.name, response, err)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		var found *logging.LogEntry
+		for found == nil && time.Now().Before(deadline) {
+			capture.mu.Lock()
+			lines := strings.Split(capture.buf.String(), "\n")
+			capture.mu.Unlock()
+			for _, line := range lines {
+				var entry logging.LogEntry
+				if json.Unmarshal([]byte(line), &entry) == nil && entry.Message == "RESP command failed" && strings.HasPrefix(entry.CorrelationID, cid) {
+					found = &entry
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if found == nil {
+			t.Fatalf("%s: no log entry for correlation ID %s", tc.name, cid)
+		}
+		if id, _ := found.Fields["conn_id"].(float64); uint64(id) != connID {
+			t.Errorf("%s: logged conn_id = %v, want %d", tc.name, found.Fields["conn_id"], connID)
+		}
+		if found.Fields["error"] != tc.message {
+			t.Errorf("%s: logged error = %v, want %q", tc.name, found.Fields["error"], tc.message)
+		}
+	}
+}
+
+func TestServer_ReplicationLogsCarryCorrelationID(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	capture := &logCapture{}
+	logger := logging.NewLogger(logging.Config{Level: logging.DEBUG, BufferSize: 100})
+	logger.AddWriter(capture)
+	previous := logging.GetGlobalLogger()
+	logging.SetGlobalLogger(logger)
+	defer func() {
+		logging.SetGlobalLogger(previous)
+		logger.Close()
+	}()
+
+	// Fake replica that accepts key1 and fails everything else
+	correlationIDs := make(chan string, 2)
+	replica := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Key string `json:"key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		correlationIDs <- r.Header.Get("X-Correlation-ID")
+		if payload.Key != "key1" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer replica.Close()
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(replica.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to parse replica address: %v", err)
+	}
+	server.coord = &mockReplicatedCoordinator{}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"replica-node": {NodeID: "replica-node", Address: host, Metadata: map[string]string{"http_port": port}},
+		},
+	}))
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	for _, tc := range []struct {
+		key, message, level string
+	}{
+		{"key1", "Replicated SET to replica", "DEBUG"},
+		{"key2", "Replication to replica failed", "WARN"},
+	} {
+		sendCommand(t, conn, fmt.Sprintf("*3\r\n$3\r\nSET\r\n$4\r\n%s\r\n$6\r\nvalue1\r\n", tc.key))
+		if response := readResponse(t, conn); response != "+OK\r\n" {
+			t.Fatalf("SET %s: expected +OK, got %q", tc.key, response)
+		}
+
+		var sent string
+		select {
+		case sent = <-correlationIDs:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for replication request")
+		}
+
+		if sent == "" {
+			t.Fatalf("%s: replication request carried no correlation ID", tc.key)
+		}
+
+		entry := capture.waitForEntry(t, tc.message, sent)
+		if entry.Level != tc.level || entry.Component != logging.ComponentRESP || entry.Action != logging.ActionReplication {
+			t.Errorf("%s: unexpected level/component/action %s/%s/%s", tc.key, entry.Level, entry.Component, entry.Action)
+		}
+		if entry.Fields["key"] != tc.key || entry.Fields["replica"] != "replica-node" {
+			t.Errorf("%s: unexpected fields %v", tc.key, entry.Fields)
+		}
+	}
+}
+
+func TestServer_CASReplicatesOnlyOnSuccess(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	replicated := make(chan string, 4)
+	replica := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal/replicate" {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			replicated <- fmt.Sprint(body["value"])
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer replica.Close()
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(replica.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to parse replica address: %v", err)
+	}
+
+	server.coord = &mockReplicatedCoordinator{}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"replica-node": {NodeID: "replica-node", Address: host, Metadata: map[string]string{"http_port": port}},
+		},
+	}))
+	server.store.Set("lock", []byte("v1"), "", 0)
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Stale expected value: no swap, nothing replicated
+	sendCommand(t, conn, "*4\r\n$3\r\nCAS\r\n$4\r\nlock\r\n$5\r\nstale\r\n$2\r\nv2\r\n")
+	if response := readResponse(t, conn); response != ":0\r\n" {
+		t.Errorf("CAS with stale value: expected :0, got %q", response)
+	}
+
+	sendCommand(t, conn, "*6\r\n$3\r\nCAS\r\n$4\r\nlock\r\n$2\r\nv1\r\n$2\r\nv2\r\n$2\r\nEX\r\n$2\r\n60\r\n")
+	if response := readResponse(t, conn); response != ":1\r\n" {
+		t.Errorf("CAS with current value: expected :1, got %q", response)
+	}
+
+	select {
+	case value := <-replicated:
+		if value != "v2" {
+			t.Errorf("Replicated value = %q, want v2", value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for replication of successful CAS")
+	}
+	select {
+	case value := <-replicated:
+		t.Errorf("Unexpected extra replication of %q", value)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sendCommand(t, conn, "*2\r\n$3\r\nGET\r\n$4\r\nlock\r\n")
+	if response := readResponse(t, conn); response != "$2\r\nv2\r\n" {
+		t.Errorf("GET after CAS: expected v2, got %q", response)
+	}
+}
+
+func TestServer_Transactions(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"EXEC without MULTI", "*1\r\n$4\r\nEXEC\r\n", "-ERR EXEC without MULTI\r\n"},
+		{"MULTI", "*1\r\n$5\r\nMULTI\r\n", "+OK\r\n"},
+		{"queue SET", "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n", "+QUEUED\r\n"},
+		{"queue GET", "*2\r\n$3\r\nGET\r\n$1\r\na\r\n", "+QUEUED\r\n"},
+		{"WATCH inside MULTI", "*2\r\n$5\r\nWATCH\r\n$1\r\na\r\n", "-ERR WATCH inside MULTI is not allowed\r\n"},
+		{"EXEC", "*1\r\n$4\r\nEXEC\r\n", "*2\r\n+OK\r\n$1\r\n1\r\n"},
+		{"MULTI again", "*1\r\n$5\r\nMULTI\r\n", "+OK\r\n"},
+		{"queue DEL", "*2\r\n$3\r\nDEL\r\n$1\r\na\r\n", "+QUEUED\r\n"},
+		{"DISCARD", "*1\r\n$7\r\nDISCARD\r\n", "+OK\r\n"},
+		{"GET after DISCARD", "*2\r\n$3\r\nGET\r\n$1\r\na\r\n", "$1\r\n1\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_WatchAbortsExecOnConcurrentWrite(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	other, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer other.Close()
+
+	run := func(c net.Conn, cmd, expected string) {
+		t.Helper()
+		sendCommand(t, c, cmd)
+		if response := readResponse(t, c); response != expected {
+			t.Fatalf("%q: expected %q, got %q", cmd, expected, response)
+		}
+	}
+
+	watchBalance := "*2\r\n$5\r\nWATCH\r\n$7\r\nbalance\r\n"
+	multi := "*1\r\n$5\r\nMULTI\r\n"
+	setBalance := "*3\r\n$3\r\nSET\r\n$7\r\nbalance\r\n$2\r\n90\r\n"
+	exec := "*1\r\n$4\r\nEXEC\r\n"
+
+	run(conn, "*3\r\n$3\r\nSET\r\n$7\r\nbalance\r\n$3\r\n100\r\n", "+OK\r\n")
+
+	// Another connection modifies the watched key before EXEC: abort
+	run(conn, watchBalance, "+OK\r\n")
+	run(conn, multi, "+OK\r\n")
+	run(conn, setBalance, "+QUEUED\r\n")
+	run(other, "*3\r\n$3\r\nSET\r\n$7\r\nbalance\r\n$2\r\n50\r\n", "+OK\r\n")
+	run(conn, exec, "*-1\r\n")
+	run(conn, "*2\r\n$3\r\nGET\r\n$7\r\nbalance\r\n", "$2\r\n50\r\n")
+
+	// EXEC released the watch, so the retry succeeds
+	run(conn, watchBalance, "+OK\r\n")
+	run(conn, multi, "+OK\r\n")
+	run(conn, setBalance, "+QUEUED\r\n")
+	run(conn, exec, "*1\r\n+OK\r\n")
+	run(conn, "*2\r\n$3\r\nGET\r\n$7\r\nbalance\r\n", "$2\r\n90\r\n")
+
+	// UNWATCH forgets the key, so later writes no longer abort
+	run(conn, watchBalance, "+OK\r\n")
+	run(conn, "*1\r\n$7\r\nUNWATCH\r\n", "+OK\r\n")
+	run(other, "*2\r\n$3\r\nDEL\r\n$7\r\nbalance\r\n", ":1\r\n")
+	run(conn, multi, "+OK\r\n")
+	run(conn, setBalance, "+QUEUED\r\n")
+	run(conn, exec, "*1\r\n+OK\r\n")
+}
+
+func TestServer_ExecIsolatedFromConcurrentWrites(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Owner of "remote:" keys that holds proxied writes until released, so
+	// EXEC can be paused between its watch check and its last command
+	release := make(chan struct{})
+	proxied := make(chan struct{}, 1)
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- struct{}{}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	}))
+	defer owner.Close()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(owner.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to parse owner address: %v", err)
+	}
+	server.coord = &mockRoutedCoordinator{}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"other-node": {NodeID: "other-node", Address: host, Metadata: map[string]string{"http_port": port}},
+		},
+	}))
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	other, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer other.Close()
+
+	run := func(c net.Conn, expected string, args ...string) {
+		t.Helper()
+		sendCommand(t, c, string(commandBytes(args...)))
+		if response := readResponse(t, c); response != expected {
+			t.Fatalf("%v: expected %q, got %q", args, expected, response)
+		}
+	}
+	run(conn, "+OK\r\n", "WATCH", "balance")
+	run(conn, "+OK\r\n", "MULTI")
+	run(conn, "+QUEUED\r\n", "SET", "remote:key", "v")
+	run(conn, "+QUEUED\r\n", "SET", "balance", "from-exec")
+	sendCommand(t, conn, string(commandBytes("EXEC")))
+
+	// EXEC has passed its watch check and is stuck in the proxied write.
+	// A write from another connection must wait for the transaction rather
+	// than land before its SET and be overwritten by it.
+	select {
+	case <-proxied:
+	case <-time.After(5 * time.Second):
+		t.Fatal("EXEC never proxied its first command")
+	}
+	sendCommand(t, other, string(commandBytes("SET", "balance", "from-other")))
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	if response := readResponse(t, conn); response != "*2\r\n+OK\r\n+OK\r\n" {
+		t.Fatalf("EXEC: expected both commands to run, got %q", response)
+	}
+	if response := readResponse(t, other); response != "+OK\r\n" {
+		t.Fatalf("SET from other connection: expected OK, got %q", response)
+	}
+	if got, _ := server.store.Get("balance"); fmt.Sprintf("%s", got) != "from-other" {
+		t.Errorf("balance = %s, want the later write from the other connection", got)
+	}
+}
+
+func TestServer_ExpireAndPersist(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"TTL missing", "*2\r\n$3\r\nTTL\r\n$1\r\nk\r\n", ":-2\r\n"},
+		{"EXPIRE missing", "*3\r\n$6\r\nEXPIRE\r\n$1\r\nk\r\n$2\r\n60\r\n", ":0\r\n"},
+		{"SET", "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n", "+OK\r\n"},
+		{"TTL persistent", "*2\r\n$3\r\nTTL\r\n$1\r\nk\r\n", ":-1\r\n"},
+		{"EXPIRE", "*3\r\n$6\r\nEXPIRE\r\n$1\r\nk\r\n$2\r\n60\r\n", ":1\r\n"},
+		{"TTL after EXPIRE", "*2\r\n$3\r\nTTL\r\n$1\r\nk\r\n", ":60\r\n"},
+		{"GET keeps value", "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", "$1\r\nv\r\n"},
+		{"PERSIST", "*2\r\n$7\r\nPERSIST\r\n$1\r\nk\r\n", ":1\r\n"},
+		{"PERSIST again", "*2\r\n$7\r\nPERSIST\r\n$1\r\nk\r\n", ":0\r\n"},
+		{"TTL after PERSIST", "*2\r\n$3\r\nTTL\r\n$1\r\nk\r\n", ":-1\r\n"},
+		{"EXPIRE zero deletes", "*3\r\n$6\r\nEXPIRE\r\n$1\r\nk\r\n$1\r\n0\r\n", ":1\r\n"},
+		{"GET after delete", "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", "$-1\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_SetKeepTTL(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"SET EX", []string{"SET", "k", "v1", "EX", "60"}, "+OK\r\n"},
+		{"SET clears expiry", []string{"SET", "k", "v2"}, "+OK\r\n"},
+		{"TTL cleared", []string{"TTL", "k"}, ":-1\r\n"},
+		{"SET EX again", []string{"SET", "k", "v3", "EX", "60"}, "+OK\r\n"},
+		{"SET KEEPTTL", []string{"SET", "k", "v4", "KEEPTTL"}, "+OK\r\n"},
+		{"TTL kept", []string{"TTL", "k"}, ":60\r\n"},
+		{"GET new value", []string{"GET", "k"}, "$2\r\nv4\r\n"},
+		{"EXPIRE overrides", []string{"EXPIRE", "k", "30"}, ":1\r\n"},
+		{"SET keepttl after EXPIRE", []string{"SET", "k", "v5", "keepttl"}, "+OK\r\n"},
+		{"TTL from EXPIRE kept", []string{"TTL", "k"}, ":30\r\n"},
+		{"SET KEEPTTL new key", []string{"SET", "new", "v", "KEEPTTL"}, "+OK\r\n"},
+		{"TTL new key", []string{"TTL", "new"}, ":-1\r\n"},
+		{"SET KEEPTTL with EX", []string{"SET", "k", "v", "KEEPTTL", "EX", "10"}, "-ERR syntax error\r\n"},
+		{"SET EX with KEEPTTL", []string{"SET", "k", "v", "PX", "10", "KEEPTTL"}, "-ERR syntax error\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, string(commandBytes(tt.args...)))
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_PinAndUnpin(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"PIN missing", "*2\r\n$3\r\nPIN\r\n$1\r\nk\r\n", ":0\r\n"},
+		{"SET PIN", "*4\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n$3\r\nPIN\r\n", "+OK\r\n"},
+		{"SET EX PIN", "*6\r\n$3\r\nSET\r\n$1\r\nj\r\n$1\r\nv\r\n$2\r\nEX\r\n$2\r\n60\r\n$3\r\npin\r\n", "+OK\r\n"},
+		{"TTL with PIN", "*2\r\n$3\r\nTTL\r\n$1\r\nj\r\n", ":60\r\n"},
+		{"UNPIN", "*2\r\n$5\r\nUNPIN\r\n$1\r\nk\r\n", ":1\r\n"},
+		{"PIN existing", "*2\r\n$3\r\nPIN\r\n$1\r\nk\r\n", ":1\r\n"},
+		{"PIN arity", "*1\r\n$3\r\nPIN\r\n", "-ERR wrong number of arguments for PIN\r\n"},
+		{"SET dangling EX", "*5\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n$3\r\nPIN\r\n$2\r\nEX\r\n", "-ERR syntax error\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+
+	store := server.getActiveStore(&ClientConn{})
+	if !store.IsPinned("k") || !store.IsPinned("j") {
+		t.Error("Expected k and j to be pinned in the store")
+	}
+}
+
+func TestServer_AuditLog(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := logging.NewAuditLog("test-node", logging.AuditConfig{LogFile: path})
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	server.SetAuditLog(audit)
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	for _, command := range []string{
+		"*3\r\n$3\r\nSET\r\n$7\r\nsecret1\r\n$1\r\nv\r\n",
+		"*2\r\n$3\r\nGET\r\n$7\r\nsecret1\r\n",
+		"*1\r\n$4\r\nPING\r\n",
+		"*3\r\n$3\r\nDEL\r\n$7\r\nsecret1\r\n$7\r\nsecret2\r\n",
+	} {
+		sendCommand(t, conn, command)
+		readResponse(t, conn)
+	}
+	if err := audit.Close(); err != nil {
+		t.Fatalf("Failed to close audit log: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	var records []logging.AuditRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record logging.AuditRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to parse audit record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+
+	expected := []struct{ operation, key string }{
+		{"SET", "secret1"}, {"GET", "secret1"}, {"DEL", "secret1"}, {"DEL", "secret2"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d audit records, got %d: %s", len(expected), len(records), data)
+	}
+	for i, want := range expected {
+		record := records[i]
+		if record.Operation != want.operation || record.Key != want.key {
+			t.Errorf("Record %d: expected %s %s, got %s %s", i, want.operation, want.key, record.Operation, record.Key)
+		}
+		if record.Protocol != "resp" || record.Store != "default" || record.NodeID != "test-node" ||
+			record.ClientID == "" || record.ClientAddr != conn.LocalAddr().String() || record.Timestamp.IsZero() {
+			t.Errorf("Record %d: missing client or context details: %+v", i, record)
+		}
+	}
+}
+
+func TestServer_DelSession(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	store := server.getActiveStore(&ClientConn{})
+	for _, key := range []string{"a1", "a2"} {
+		if err := store.Set(key, "v", "session-a", 0); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+	if err := store.Set("b1", "v", "session-b", 0); err != nil {
+		t.Fatalf("Set b1: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"DELSESSION", "*2\r\n$10\r\nDELSESSION\r\n$9\r\nsession-a\r\n", ":2\r\n"},
+		{"DELSESSION again", "*2\r\n$10\r\nDELSESSION\r\n$9\r\nsession-a\r\n", ":0\r\n"},
+		{"other session kept", "*2\r\n$3\r\nGET\r\n$2\r\nb1\r\n", "$1\r\nv\r\n"},
+		{"DELSESSION arity", "*1\r\n$10\r\nDELSESSION\r\n", "-ERR wrong number of arguments for DELSESSION\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_GetDel(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"SET token", "*3\r\n$3\r\nSET\r\n$5\r\ntoken\r\n$6\r\n123456\r\n", "+OK\r\n"},
+		{"GETDEL", "*2\r\n$6\r\nGETDEL\r\n$5\r\ntoken\r\n", "$6\r\n123456\r\n"},
+		{"key gone", "*2\r\n$6\r\nEXISTS\r\n$5\r\ntoken\r\n", ":0\r\n"},
+		{"GETDEL again", "*2\r\n$6\r\nGETDEL\r\n$5\r\ntoken\r\n", "$-1\r\n"},
+		{"RPUSH list", "*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$1\r\na\r\n", ":1\r\n"},
+		{"GETDEL list", "*2\r\n$6\r\nGETDEL\r\n$4\r\nlist\r\n", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"list kept", "*2\r\n$4\r\nLLEN\r\n$4\r\nlist\r\n", ":1\r\n"},
+		{"GETDEL arity", "*1\r\n$6\r\nGETDEL\r\n", "-ERR wrong number of arguments for GETDEL\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_ScanType(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Mixed keyspace: strings, lists and sets under two prefixes
+	wantStrings := map[string]bool{}
+	for i := 0; i < 30; i++ {
+		prefix := "user"
+		if i%2 == 1 {
+			prefix = "temp"
+		}
+		var command []byte
+		switch i % 3 {
+		case 0:
+			key := fmt.Sprintf("%s:str:%d", prefix, i)
+			command = commandBytes("SET", key, "v")
+			if prefix == "user" {
+				wantStrings[key] = true
+			}
+		case 1:
+			command = commandBytes("RPUSH", fmt.Sprintf("%s:list:%d", prefix, i), "a")
+		case 2:
+			command = commandBytes("SADD", fmt.Sprintf("%s:set:%d", prefix, i), "m")
+		}
+		sendCommand(t, conn, string(command))
+		if response := readResponse(t, conn); strings.HasPrefix(response, "-") {
+			t.Fatalf("%q: %q", command, response)
+		}
+	}
+
+	// Iterate to completion with TYPE, MATCH and a small COUNT
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	parser := NewParser(conn)
+	got := map[string]bool{}
+	cursor := "0"
+	for calls := 0; ; calls++ {
+		if calls > 1000 {
+			t.Fatal("SCAN did not finish")
+		}
+		sendCommand(t, conn, string(commandBytes("SCAN", cursor, "MATCH", "user:*", "COUNT", "3", "TYPE", "string")))
+		reply, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if len(reply.Array) != 2 {
+			t.Fatalf("SCAN: unexpected reply %q", reply.Raw)
+		}
+		for _, key := range reply.Array[1].Array {
+			if got[key.Str] {
+				t.Errorf("SCAN returned %q twice", key.Str)
+			}
+			got[key.Str] = true
+		}
+		if cursor = reply.Array[0].Str; cursor == "0" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, wantStrings) {
+		t.Errorf("SCAN TYPE string MATCH user:* = %v, want %v", got, wantStrings)
+	}
+
+	tests := []struct {
+		name     string
+		command  []byte
+		expected string
+	}{
+		{"bad cursor", commandBytes("SCAN", "x"), "-ERR invalid cursor"},
+		{"bad count", commandBytes("SCAN", "0", "COUNT", "0"), "-ERR syntax error"},
+		{"unknown option", commandBytes("SCAN", "0", "LIMIT", "5"), "-ERR syntax error"},
+		{"missing value", commandBytes("SCAN", "0", "TYPE"), "-ERR wrong number"},
+	}
+	for _, tt := range tests {
+		sendCommand(t, conn, string(tt.command))
+		if response := readResponse(t, conn); !strings.HasPrefix(response, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_ObjectEncoding(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	members := []string{"SADD", "tags"}
+	for i := 0; i < storage.ListpackMaxEntries; i++ {
+		members = append(members, fmt.Sprintf("tag-%d", i))
+	}
+
+	tests := []struct {
+		name     string
+		command  []byte
+		expected string
+	}{
+		{"fill set", commandBytes(members...), fmt.Sprintf(":%d\r\n", storage.ListpackMaxEntries)},
+		{"small set", commandBytes("OBJECT", "ENCODING", "tags"), "$8\r\nlistpack\r\n"},
+		{"cross threshold", commandBytes("SADD", "tags", "extra"), ":1\r\n"},
+		{"large set", commandBytes("OBJECT", "ENCODING", "tags"), "$9\r\nhashtable\r\n"},
+		{"string", commandBytes("SET", "n", "42"), "+OK\r\n"},
+		{"int encoding", commandBytes("OBJECT", "ENCODING", "n"), "$3\r\nint\r\n"},
+		{"refcount", commandBytes("OBJECT", "REFCOUNT", "n"), ":1\r\n"},
+		{"missing key", commandBytes("OBJECT", "ENCODING", "nope"), "$-1\r\n"},
+		{"unknown subcommand", commandBytes("OBJECT", "FREQ", "n"), "-ERR unknown OBJECT subcommand"},
+	}
+	for _, tt := range tests {
+		sendCommand(t, conn, string(tt.command))
+		if response := readResponse(t, conn); !strings.HasPrefix(response, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_CommandStats(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	mix := [][]string{
+		{"SET", "a", "1"}, {"SET", "b", "2"}, {"SET", "c", "3"},
+		{"GET", "a"}, {"GET", "missing"},
+		{"DEL", "b"},
+		{"GET"}, // wrong arity: a failed call
+		{"NOSUCHCOMMAND"},
+	}
+	for _, args := range mix {
+		sendCommand(t, conn, string(commandBytes(args...)))
+		readResponse(t, conn)
+	}
+
+	want := map[string][2]uint64{ // calls, failed calls
+		"set": {3, 0},
+		"get": {3, 1},
+		"del": {1, 0},
+	}
+	stats := server.CommandStats()
+	if len(stats) != len(want) {
+		t.Errorf("Expected stats for %d commands, got %+v", len(want), stats)
+	}
+	for _, stat := range stats {
+		if w := want[stat.Name]; stat.Calls != w[0] || stat.FailedCalls != w[1] {
+			t.Errorf("%s: expected %d calls (%d failed), got %d (%d failed)", stat.Name, w[0], w[1], stat.Calls, stat.FailedCalls)
+		}
+	}
+
+	sendCommand(t, conn, string(commandBytes("INFO", "commandstats")))
+	info := readResponse(t, conn)
+	for _, line := range []string{"# Commandstats", "cmdstat_set:calls=3,", "cmdstat_get:calls=3,", "cmdstat_del:calls=1,"} {
+		if !strings.Contains(info, line) {
+			t.Errorf("INFO commandstats missing %q:\n%s", line, info)
+		}
+	}
+
+	// INFO itself is counted by now
+	sendCommand(t, conn, string(commandBytes("COMMAND", "STATS")))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := NewParser(conn).Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse COMMAND STATS reply: %v", err)
+	}
+	calls := make(map[string]int64)
+	for _, entry := range reply.Array {
+		calls[entry.Array[0].Str] = entry.Array[1].Int
+	}
+	if calls["set"] != 3 || calls["get"] != 3 || calls["del"] != 1 || calls["info"] != 1 {
+		t.Errorf("COMMAND STATS: unexpected calls %v", calls)
+	}
+}
+
+func TestServer_GetMeta(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	before := time.Now().Unix()
+	setup := []struct {
+		command  string
+		expected string
+	}{
+		{"*5\r\n$3\r\nSET\r\n$4\r\nuser\r\n$5\r\nalice\r\n$2\r\nEX\r\n$3\r\n100\r\n", "+OK\r\n"},
+		{"*2\r\n$3\r\nGET\r\n$4\r\nuser\r\n", "$5\r\nalice\r\n"},
+		{"*2\r\n$3\r\nGET\r\n$4\r\nuser\r\n", "$5\r\nalice\r\n"},
+		{"*3\r\n$3\r\nSET\r\n$7\r\nforever\r\n$1\r\nx\r\n", "+OK\r\n"},
+	}
+	for _, tt := range setup {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Fatalf("%q: expected %q, got %q", tt.command, tt.expected, response)
+		}
+	}
+
+	// Two prior GETs plus GETMETA itself; TTL matches the EX given to SET
+	sendCommand(t, conn, "*2\r\n$7\r\nGETMETA\r\n$4\r\nuser\r\n")
+	response := readResponse(t, conn)
+	prefix := "*4\r\n$5\r\nalice\r\n:100\r\n:3\r\n:"
+	if !strings.HasPrefix(response, prefix) {
+		t.Fatalf("GETMETA: expected prefix %q, got %q", prefix, response)
+	}
+	createdAt, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(response, prefix), "\r\n"), 10, 64)
+	if err != nil || createdAt < before || createdAt > time.Now().Unix() {
+		t.Errorf("GETMETA created_at = %q, want a time during the test", response[len(prefix):])
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"no expiry", "*2\r\n$7\r\nGETMETA\r\n$7\r\nforever\r\n", "*4\r\n$1\r\nx\r\n:-1\r\n:1\r\n:"},
+		{"missing key", "*2\r\n$7\r\nGETMETA\r\n$7\r\nmissing\r\n", "*-1\r\n"},
+		{"RPUSH list", "*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$1\r\na\r\n", ":1\r\n"},
+		{"GETMETA list", "*2\r\n$7\r\nGETMETA\r\n$4\r\nlist\r\n", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{"GETMETA arity", "*1\r\n$7\r\nGETMETA\r\n", "-ERR wrong number of arguments for GETMETA\r\n"},
+	}
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); !strings.HasPrefix(response, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_DebugSetActiveExpire(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"pause", "*3\r\n$5\r\nDEBUG\r\n$17\r\nSET-ACTIVE-EXPIRE\r\n$1\r\n0\r\n", "+OK\r\n"},
+		{"SET PX", "*5\r\n$3\r\nSET\r\n$3\r\nkey\r\n$1\r\nv\r\n$2\r\nPX\r\n$2\r\n10\r\n", "+OK\r\n"},
+	}
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	store := server.getActiveStore(&ClientConn{})
+	tests = []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"expired GET", "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", "$-1\r\n"},
+		{"bad flag", "*3\r\n$5\r\nDEBUG\r\n$17\r\nSET-ACTIVE-EXPIRE\r\n$3\r\nyes\r\n", "-ERR DEBUG SET-ACTIVE-EXPIRE takes 0 or 1\r\n"},
+	}
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+	if store.Size() != 1 {
+		t.Errorf("Store size with active expire paused = %d, want 1", store.Size())
+	}
+
+	sendCommand(t, conn, "*3\r\n$5\r\nDEBUG\r\n$17\r\nSET-ACTIVE-EXPIRE\r\n$1\r\n1\r\n")
+	readResponse(t, conn)
+	sendCommand(t, conn, "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n")
+	readResponse(t, conn)
+	if store.Size() != 0 {
+		t.Errorf("Store size after resuming active expire = %d, want 0", store.Size())
+	}
+}
+
+func TestServer_DebugPopulate(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// An existing key is left as it is
+	sendCommand(t, conn, string(commandBytes("SET", "key:7", "mine")))
+	readResponse(t, conn)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"populate", []string{"DEBUG", "POPULATE", "1000"}, "+OK\r\n"},
+		{"DBSIZE", []string{"DBSIZE"}, ":1000\r\n"},
+		{"populated value", []string{"GET", "key:999"}, "$9\r\nvalue:999\r\n"},
+		{"existing key", []string{"GET", "key:7"}, "$4\r\nmine\r\n"},
+		{"prefix", []string{"DEBUG", "POPULATE", "10", "bench"}, "+OK\r\n"},
+		{"prefixed value", []string{"GET", "bench:3"}, "$7\r\nvalue:3\r\n"},
+		{"DBSIZE with prefix", []string{"DBSIZE"}, ":1010\r\n"},
+		{"bad count", []string{"DEBUG", "POPULATE", "-1"}, "-ERR invalid count '-1'\r\n"},
+	}
+	for _, tt := range tests {
+		sendCommand(t, conn, string(commandBytes(tt.args...)))
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_Standalone(t *testing.T) {
+	store, err := storage.NewBasicStore(storage.BasicStoreConfig{
+		Name:            "standalone-store",
+		MaxMemory:       1024 * 1024,
+		CleanupInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create basic store: %v", err)
+	}
+	defer store.Close()
+
+	// No coordinator: clustering is disabled. A node communicator is still
+	// set so the replication paths are reached.
+	server := NewServerWithConfig(":0", store, nil, ServerConfig{
+		MaxConnections: 10,
+		IdleTimeout:    time.Minute,
+		CommandTimeout: 5 * time.Second,
+		BufferSize:     1024,
+	})
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("standalone", &mockMembership{}))
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"SET", "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n", "+OK\r\n"},
+		{"GET", "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", "$5\r\nvalue\r\n"},
+		{"DEL", "*2\r\n$3\r\nDEL\r\n$3\r\nkey\r\n", ":1\r\n"},
+		{"GET deleted", "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", "$-1\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_ResetClearsConnectionState(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	sm := storage.NewStoreManager(storage.StoreManagerConfig{
+		DataDir:           t.TempDir(),
+		MaxStores:         4,
+		GlobalPersistence: config.PersistenceConfig{Enabled: false, Strategy: "disabled"},
+		GlobalCacheConfig: config.CacheConfig{MaxMemory: "64MB", DefaultTTL: "0", MaxStores: 4},
+	})
+	defer sm.Close()
+	if err := sm.CreateStore(config.StoreConfig{Name: "default", MaxMemory: "16MB", EvictionPolicy: "lru"}, context.Background()); err != nil {
+		t.Fatalf("Failed to create default store: %v", err)
+	}
+	if err := sm.CreateStore(config.StoreConfig{Name: "sessions", MaxMemory: "16MB", EvictionPolicy: "lru"}, context.Background()); err != nil {
+		t.Fatalf("Failed to create sessions store: %v", err)
+	}
+	server.SetStoreManager(sm)
+	sm.GetStore("default").Set("k", "from-default", "test", 0)
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Dirty the connection: selected store, client name and a subscription
+	sendCommand(t, conn, "*2\r\n$6\r\nSELECT\r\n$8\r\nsessions\r\n")
+	if response := readResponse(t, conn); response != "+OK\r\n" {
+		t.Fatalf("SELECT: expected +OK, got %q", response)
+	}
+	sendCommand(t, conn, "*3\r\n$6\r\nCLIENT\r\n$7\r\nSETNAME\r\n$6\r\npooled\r\n")
+	if response := readResponse(t, conn); response != "+OK\r\n" {
+		t.Fatalf("CLIENT SETNAME: expected +OK, got %q", response)
+	}
+	sendCommand(t, conn, "*2\r\n$9\r\nSUBSCRIBE\r\n$4\r\nnews\r\n")
+	if response := readResponse(t, conn); !strings.Contains(response, "subscribe") {
+		t.Fatalf("SUBSCRIBE: unexpected reply %q", response)
+	}
+
+	// RESET is allowed while subscribed
+	sendCommand(t, conn, "*1\r\n$5\r\nRESET\r\n")
+	if response := readResponse(t, conn); response != "+RESET\r\n" {
+		t.Fatalf("RESET: expected +RESET, got %q", response)
+	}
+
+	// No longer subscribed: regular commands work and publishes aren't delivered
+	if got := server.pubsub.Publish("news", "hello"); got != 0 {
+		t.Errorf("Expected no subscribers after RESET, got %d", got)
+	}
+
+	// Client name cleared
+	sendCommand(t, conn, "*2\r\n$6\r\nCLIENT\r\n$7\r\nGETNAME\r\n")
+	if response := readResponse(t, conn); response != "$-1\r\n" {
+		t.Errorf("CLIENT GETNAME after RESET: expected null, got %q", response)
+	}
+
+	// Back on the default store
+	sendCommand(t, conn, "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n")
+	if response := readResponse(t, conn); response != "$12\r\nfrom-default\r\n" {
+		t.Errorf("GET after RESET: expected value from default store, got %q", response)
+	}
+}
+
+func TestServer_WrongType(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	sendCommand(t, conn, "*4\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$1\r\na\r\n$1\r\nb\r\n")
+	if response := readResponse(t, conn); response != ":2\r\n" {
+		t.Fatalf("RPUSH: expected :2, got %q", response)
+	}
+	sendCommand(t, conn, "*4\r\n$6\r\nLRANGE\r\n$4\r\nlist\r\n$1\r\n0\r\n$2\r\n-1\r\n")
+	if response := readResponse(t, conn); response != "*2\r\n$1\r\na\r\n$1\r\nb\r\n" {
+		t.Errorf("LRANGE: unexpected reply %q", response)
+	}
+	sendCommand(t, conn, "*2\r\n$4\r\nTYPE\r\n$4\r\nlist\r\n")
+	if response := readResponse(t, conn); response != "+list\r\n" {
+		t.Errorf("TYPE list: expected +list, got %q", response)
+	}
+
+	// GET on a list key
+	sendCommand(t, conn, "*2\r\n$3\r\nGET\r\n$4\r\nlist\r\n")
+	if response := readResponse(t, conn); !strings.HasPrefix(response, "-WRONGTYPE") {
+		t.Errorf("GET on list: expected WRONGTYPE, got %q", response)
+	}
+
+	// LPUSH on a string key
+	sendCommand(t, conn, "*3\r\n$3\r\nSET\r\n$3\r\nstr\r\n$5\r\nvalue\r\n")
+	readResponse(t, conn)
+	sendCommand(t, conn, "*3\r\n$5\r\nLPUSH\r\n$3\r\nstr\r\n$1\r\nx\r\n")
+	if response := readResponse(t, conn); !strings.HasPrefix(response, "-WRONGTYPE") {
+		t.Errorf("LPUSH on string: expected WRONGTYPE, got %q", response)
+	}
+
+	// The string was left untouched
+	sendCommand(t, conn, "*2\r\n$3\r\nGET\r\n$3\r\nstr\r\n")
+	if response := readResponse(t, conn); response != "$5\r\nvalue\r\n" {
+		t.Errorf("GET after rejected LPUSH: expected value, got %q", response)
+	}
+}
+
+func TestServer_SetAlgebra(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	sendCommand(t, conn, "*5\r\n$4\r\nSADD\r\n$2\r\ns1\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n")
+	if response := readResponse(t, conn); response != ":3\r\n" {
+		t.Fatalf("SADD s1: expected :3, got %q", response)
+	}
+	sendCommand(t, conn, "*4\r\n$4\r\nSADD\r\n$2\r\ns2\r\n$1\r\nb\r\n$1\r\nd\r\n")
+	readResponse(t, conn)
+
+	sendCommand(t, conn, "*3\r\n$6\r\nSINTER\r\n$2\r\ns1\r\n$2\r\ns2\r\n")
+	if response := readResponse(t, conn); response != "*1\r\n$1\r\nb\r\n" {
+		t.Errorf("SINTER: unexpected reply %q", response)
+	}
+
+	sendCommand(t, conn, "*3\r\n$5\r\nSDIFF\r\n$2\r\ns1\r\n$2\r\ns2\r\n")
+	if response := readResponse(t, conn); response != "*2\r\n$1\r\na\r\n$1\r\nc\r\n" {
+		t.Errorf("SDIFF: unexpected reply %q", response)
+	}
+
+	sendCommand(t, conn, "*4\r\n$11\r\nSUNIONSTORE\r\n$3\r\ndst\r\n$2\r\ns1\r\n$2\r\ns2\r\n")
+	if response := readResponse(t, conn); response != ":4\r\n" {
+		t.Errorf("SUNIONSTORE: expected :4, got %q", response)
+	}
+	sendCommand(t, conn, "*2\r\n$8\r\nSMEMBERS\r\n$3\r\ndst\r\n")
+	if response := readResponse(t, conn); response != "*4\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\nd\r\n" {
+		t.Errorf("SMEMBERS dst: unexpected reply %q", response)
+	}
+
+	// Keys owned by another node that span slots are rejected up front
+	server.coord = &mockRoutedCoordinator{}
+	sendCommand(t, conn, "*3\r\n$6\r\nSUNION\r\n$2\r\ns1\r\n$10\r\nremote:key\r\n")
+	if response := readResponse(t, conn); !strings.HasPrefix(response, "-CROSSSLOT") {
+		t.Errorf("SUNION across nodes: expected CROSSSLOT, got %q", response)
+	}
+}
+
+func TestServer_HashTagKeysRouteTogether(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	ring := cluster.NewHashRing(cluster.DefaultHashRingConfig())
+	ring.AddNode("test-node", "127.0.0.1", 7946)
+	ring.AddNode("other-node", "127.0.0.2", 7946)
+	server.coord = &mockRingCoordinator{ring: ring}
+	routing := server.coord.GetRouting()
+
+	// Find a user whose keys this node owns
+	user := -1
+	for i := 0; i < 100 && user < 0; i++ {
+		if routing.IsLocal(fmt.Sprintf("{user:%d}:profile", i)) {
+			user = i
+		}
+	}
+	if user < 0 {
+		t.Fatal("Expected some user's keys to be owned locally")
+	}
+	profile := fmt.Sprintf("{user:%d}:profile", user)
+	prefs := fmt.Sprintf("{user:%d}:prefs", user)
+	if !routing.IsLocal(prefs) {
+		t.Fatalf("%s and %s routed to different nodes", profile, prefs)
+	}
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// A multi-key command across the tagged keys runs on this node
+	sendCommand(t, conn, string(commandBytes("SADD", profile, "name")))
+	readResponse(t, conn)
+	sendCommand(t, conn, string(commandBytes("SADD", prefs, "theme")))
+	readResponse(t, conn)
+	sendCommand(t, conn, string(commandBytes("SUNIONSTORE", fmt.Sprintf("{user:%d}:all", user), profile, prefs)))
+	if response := readResponse(t, conn); response != ":2\r\n" {
+		t.Errorf("SUNIONSTORE across tagged keys: expected :2, got %q", response)
+	}
+	sendCommand(t, conn, string(commandBytes("DEL", profile, prefs)))
+	if response := readResponse(t, conn); response != ":2\r\n" {
+		t.Errorf("DEL across tagged keys: expected :2, got %q", response)
+	}
+}
+
+func TestServer_Sort(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	sendCommand(t, conn, "*5\r\n$5\r\nRPUSH\r\n$1\r\nl\r\n$2\r\n10\r\n$1\r\n9\r\n$3\r\n100\r\n")
+	readResponse(t, conn)
+
+	sendCommand(t, conn, "*2\r\n$4\r\nSORT\r\n$1\r\nl\r\n")
+	if response := readResponse(t, conn); response != "*3\r\n$1\r\n9\r\n$2\r\n10\r\n$3\r\n100\r\n" {
+		t.Errorf("SORT numeric: unexpected reply %q", response)
+	}
+
+	sendCommand(t, conn, "*3\r\n$4\r\nSORT\r\n$1\r\nl\r\n$5\r\nALPHA\r\n")
+	if response := readResponse(t, conn); response != "*3\r\n$2\r\n10\r\n$3\r\n100\r\n$1\r\n9\r\n" {
+		t.Errorf("SORT ALPHA: unexpected reply %q", response)
+	}
+
+	sendCommand(t, conn, "*6\r\n$4\r\nSORT\r\n$1\r\nl\r\n$5\r\nLIMIT\r\n$1\r\n0\r\n$1\r\n1\r\n$4\r\nDESC\r\n")
+	if response := readResponse(t, conn); response != "*1\r\n$3\r\n100\r\n" {
+		t.Errorf("SORT LIMIT 0 1 DESC: unexpected reply %q", response)
+	}
+
+	sendCommand(t, conn, "*3\r\n$4\r\nSORT\r\n$1\r\nl\r\n$5\r\nSTORE\r\n")
+	if response := readResponse(t, conn); !strings.HasPrefix(response, "-ERR syntax error") {
+		t.Errorf("SORT STORE: expected syntax error, got %q", response)
+	}
+}
+
+func TestServer_TypedWritesRejectedOnReplica(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+	server.coord = &mockReplicaOfCoordinator{}
+
+	if _, err := server.store.ListPush("replica:list", false, "a"); err != nil {
+		t.Fatalf("ListPush error = %v", err)
+	}
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Writes on a replica would never reach the owner
+	for _, args := range [][]string{
+		{"LPUSH", "replica:list", "x"},
+		{"RPOP", "replica:list"},
+		{"SADD", "replica:set", "m"},
+		{"SETBIT", "replica:bits", "1", "1"},
+		{"PFADD", "replica:hll", "e"},
+		{"PFMERGE", "replica:{t}hll", "{t}hll"},
+		{"SUNIONSTORE", "replica:{t}set", "{t}set"},
+	} {
+		sendCommand(t, conn, string(commandBytes(args...)))
+		if response := readResponse(t, conn); !strings.HasPrefix(response, "-") {
+			t.Errorf("%v on a replica: expected an error, got %q", args, response)
+		}
+	}
+
+	// Reads are served from the replica's copy
+	sendCommand(t, conn, string(commandBytes("LRANGE", "replica:list", "0", "-1")))
+	if response := readResponse(t, conn); response != "*1\r\n$1\r\na\r\n" {
+		t.Errorf("LRANGE on a replica: expected [a], got %q", response)
+	}
+
+	// The list was left alone
+	if got, _ := server.store.ListRange("replica:list", 0, -1); fmt.Sprint(got) != "[a]" {
+		t.Errorf("replica:list = %v, want [a]", got)
+	}
+}
+
+func TestServer_Bitmap(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"SETBIT new", "*4\r\n$6\r\nSETBIT\r\n$1\r\nb\r\n$7\r\n1000000\r\n$1\r\n1\r\n", ":0\r\n"},
+		{"SETBIT again", "*4\r\n$6\r\nSETBIT\r\n$1\r\nb\r\n$7\r\n1000000\r\n$1\r\n1\r\n", ":1\r\n"},
+		{"SETBIT low", "*4\r\n$6\r\nSETBIT\r\n$1\r\nb\r\n$1\r\n3\r\n$1\r\n1\r\n", ":0\r\n"},
+		{"GETBIT set", "*3\r\n$6\r\nGETBIT\r\n$1\r\nb\r\n$1\r\n3\r\n", ":1\r\n"},
+		{"GETBIT unset", "*3\r\n$6\r\nGETBIT\r\n$1\r\nb\r\n$1\r\n4\r\n", ":0\r\n"},
+		{"BITCOUNT", "*2\r\n$8\r\nBITCOUNT\r\n$1\r\nb\r\n", ":2\r\n"},
+		{"BITCOUNT range", "*4\r\n$8\r\nBITCOUNT\r\n$1\r\nb\r\n$1\r\n1\r\n$2\r\n-1\r\n", ":1\r\n"},
+		{"SETBIT bad bit", "*4\r\n$6\r\nSETBIT\r\n$1\r\nb\r\n$1\r\n0\r\n$1\r\n2\r\n", "-ERR bit is not an integer or out of range\r\n"},
+		{"GETBIT bad offset", "*3\r\n$6\r\nGETBIT\r\n$1\r\nb\r\n$2\r\n-1\r\n", "-ERR bit offset is not an integer or out of range\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_HyperLogLog(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{"PFADD new", "*4\r\n$5\r\nPFADD\r\n$2\r\nh1\r\n$1\r\na\r\n$1\r\nb\r\n", ":1\r\n"},
+		{"PFADD existing", "*3\r\n$5\r\nPFADD\r\n$2\r\nh1\r\n$1\r\na\r\n", ":0\r\n"},
+		{"PFADD other", "*4\r\n$5\r\nPFADD\r\n$2\r\nh2\r\n$1\r\nb\r\n$1\r\nc\r\n", ":1\r\n"},
+		{"PFCOUNT", "*2\r\n$7\r\nPFCOUNT\r\n$2\r\nh1\r\n", ":2\r\n"},
+		{"PFCOUNT union", "*3\r\n$7\r\nPFCOUNT\r\n$2\r\nh1\r\n$2\r\nh2\r\n", ":3\r\n"},
+		{"PFMERGE", "*4\r\n$7\r\nPFMERGE\r\n$2\r\nh3\r\n$2\r\nh1\r\n$2\r\nh2\r\n", "+OK\r\n"},
+		{"PFCOUNT merged", "*2\r\n$7\r\nPFCOUNT\r\n$2\r\nh3\r\n", ":3\r\n"},
+		{"SET plain", "*3\r\n$3\r\nSET\r\n$1\r\ns\r\n$1\r\nv\r\n", "+OK\r\n"},
+		{"PFADD plain", "*3\r\n$5\r\nPFADD\r\n$1\r\ns\r\n$1\r\na\r\n", "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n"},
+	}
+
+	for _, tt := range tests {
+		sendCommand(t, conn, tt.command)
+		if response := readResponse(t, conn); response != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, response)
+		}
+	}
+}
+
+func TestServer_Latency(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	sendCommand(t, conn, "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n")
+	readResponse(t, conn)
+	sendCommand(t, conn, "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n")
+	readResponse(t, conn)
+
+	sendCommand(t, conn, "*2\r\n$7\r\nLATENCY\r\n$6\r\nLATEST\r\n")
+	if response := readResponse(t, conn); !strings.Contains(response, "$3\r\nget\r\n") || !strings.Contains(response, "$3\r\nset\r\n") {
+		t.Errorf("Expected LATENCY LATEST to report get and set, got %q", response)
+	}
+	sendCommand(t, conn, "*3\r\n$7\r\nLATENCY\r\n$7\r\nHISTORY\r\n$3\r\nget\r\n")
+	if response := readResponse(t, conn); !strings.HasPrefix(response, "*") || response == "*0\r\n" {
+		t.Errorf("Expected LATENCY HISTORY get to return samples, got %q", response)
+	}
+	sendCommand(t, conn, "*3\r\n$7\r\nLATENCY\r\n$5\r\nRESET\r\n$3\r\nget\r\n")
+	if response := readResponse(t, conn); response != ":1\r\n" {
+		t.Errorf("Expected LATENCY RESET get to reset 1 event, got %q", response)
+	}
+	sendCommand(t, conn, "*3\r\n$7\r\nLATENCY\r\n$7\r\nHISTORY\r\n$3\r\nget\r\n")
+	if response := readResponse(t, conn); response != "*0\r\n" {
+		t.Errorf("Expected no history after RESET, got %q", response)
+	}
+}
+
+func TestServer_InfoReplication(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	sendCommand(t, conn, "*1\r\n$4\r\nINFO\r\n")
+	info := readResponse(t, conn)
+	for _, want := range []string{"# Replication\n", "role:master\n", "connected_slaves:0\n", "master_repl_offset:0\n"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("Expected INFO to contain %q, got %q", want, info)
+		}
+	}
+	if strings.Contains(info, "slave0:") || strings.Contains(info, "master_link_status") {
+		t.Errorf("Expected no replica or link lines on a standalone node, got %q", info)
+	}
+}
+
+func TestServer_ReplicaOf(t *testing.T) {
+	primary, cleanupPrimary := newTestServer(t)
+	defer cleanupPrimary()
+	replica, cleanupReplica := newTestServer(t)
+	defer cleanupReplica()
+
+	primaryConn, err := net.Dial("tcp", primary.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to primary: %v", err)
+	}
+	defer primaryConn.Close()
+	replicaConn, err := net.Dial("tcp", replica.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to replica: %v", err)
+	}
+	defer replicaConn.Close()
+
+	// Written before the replica attaches: arrives with the snapshot
+	sendCommand(t, primaryConn, string(commandBytes("SET", "before", "1")))
+	readResponse(t, primaryConn)
+	sendCommand(t, primaryConn, string(commandBytes("RPUSH", "list", "a", "b")))
+	readResponse(t, primaryConn)
+
+	host, port, _ := net.SplitHostPort(primary.listener.Addr().String())
+	sendCommand(t, replicaConn, string(commandBytes("REPLICAOF", host, port)))
+	if response := readResponse(t, replicaConn); response != "+OK\r\n" {
+		t.Fatalf("REPLICAOF: expected +OK, got %q", response)
+	}
+
+	// Written after the replica attaches: arrives on the live stream
+	sendCommand(t, primaryConn, string(commandBytes("SET", "after", "2")))
+	readResponse(t, primaryConn)
+	primary.store.Set("num", 42, "sess-1", 0)
+	primary.store.Set("doc", map[string]interface{}{"name": "widget"}, "", 0)
+	if _, err := primary.store.SetBit("bits", 3, true); err != nil {
+		t.Fatalf("SetBit failed: %v", err)
+	}
+	sendCommand(t, primaryConn, string(commandBytes("DEL", "before")))
+	readResponse(t, primaryConn)
+
+	waitFor := func(desc string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", desc)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("key written after attaching", func() bool {
+		value, _, err := replica.store.GetRawBytes("after")
+		return err == nil && string(value) == "2"
+	})
+	waitFor("deleted key to be removed", func() bool {
+		_, ok := replica.store.TTL("before")
+		return !ok
+	})
+	if list, err := replica.store.ListRange("list", 0, -1); err != nil || len(list) != 2 || list[0] != "a" || list[1] != "b" {
+		t.Errorf("Expected snapshot list [a b] on replica, got %v (%v)", list, err)
+	}
+
+	// Every type arrives as stored, not as a string
+	waitFor("bitmap to reach the replica", func() bool {
+		return replica.store.Type("bits") == primary.store.Type("bits")
+	})
+	if got, err := replica.store.Get("num"); err != nil || got != 42 {
+		t.Errorf("Number on replica: expected int 42, got %#v (%v)", got, err)
+	}
+	if n, err := replica.store.DeleteSession("sess-1"); err != nil || n != 1 {
+		t.Errorf("Expected the number to keep its session on the replica, deleted %d (%v)", n, err)
+	}
+	if got, err := replica.store.Get("doc"); err != nil || !reflect.DeepEqual(got, map[string]interface{}{"name": "widget"}) {
+		t.Errorf("Document on replica: got %#v (%v)", got, err)
+	}
+	if bit, err := replica.store.GetBit("bits", 3); err != nil || bit != 1 {
+		t.Errorf("Bitmap on replica: bit 3 = %d (%v), want 1", bit, err)
+	}
+
+	// TTL changes are streamed too
+	sendCommand(t, primaryConn, string(commandBytes("EXPIRE", "after", "100")))
+	readResponse(t, primaryConn)
+	waitFor("EXPIRE to reach the replica", func() bool {
+		ttl, ok := replica.store.TTL("after")
+		return ok && ttl > 90*time.Second
+	})
+	sendCommand(t, primaryConn, string(commandBytes("PERSIST", "after")))
+	readResponse(t, primaryConn)
+	waitFor("PERSIST to reach the replica", func() bool {
+		ttl, ok := replica.store.TTL("after")
+		return ok && ttl == -1
+	})
+
+	sendCommand(t, replicaConn, string(commandBytes("SET", "x", "1")))
+	if response := readResponse(t, replicaConn); !strings.HasPrefix(response, "-READONLY") {
+		t.Errorf("Expected READONLY for a write on the replica, got %q", response)
+	}
+
+	sendCommand(t, replicaConn, string(commandBytes("INFO")))
+	info := readResponse(t, replicaConn)
+	for _, want := range []string{"# Replication", "role:slave", "master_port:" + port, "master_link_status:up"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("Expected INFO to contain %q, got %q", want, info)
+		}
+	}
+
+	_, replicaPort, _ := net.SplitHostPort(replica.listener.Addr().String())
+	sendCommand(t, primaryConn, string(commandBytes("INFO")))
+	info = readResponse(t, primaryConn)
+	for _, want := range []string{"role:master", "connected_slaves:1", "slave0:ip=", ",port=" + replicaPort + ",state=online"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("Expected primary INFO to contain %q, got %q", want, info)
+		}
+	}
+
+	sendCommand(t, replicaConn, string(commandBytes("REPLICAOF", "NO", "ONE")))
+	if response := readResponse(t, replicaConn); response != "+OK\r\n" {
+		t.Fatalf("REPLICAOF NO ONE: expected +OK, got %q", response)
+	}
+	sendCommand(t, replicaConn, string(commandBytes("SET", "x", "1")))
+	if response := readResponse(t, replicaConn); response != "+OK\r\n" {
+		t.Errorf("Expected writes to be accepted after detaching, got %q", response)
+	}
+	if _, _, err := replica.store.GetRawBytes("after"); err != nil {
+		t.Errorf("Expected replicated data to be kept after detaching: %v", err)
+	}
+}
+
+func sendCommand(t *testing.T, conn net.Conn, cmd string) {
+	_, err := conn.Write([]byte(cmd))
+	if err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+}
+
+func readResponse(t *testing.T, conn net.Conn) string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	parser := NewParser(conn)
+	value, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	return string(value.Raw)
+}
+
+func TestServer_Pipelining(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Replies to a pipelined batch are queued and flushed together, in order
+	sendCommand(t, conn, "*1\r\n$4\r\nPING\r\n"+
+		"*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"+
+		"*1\r\n$7\r\nUNKNOWN\r\n"+
+		"*2\r\n$3\r\nGET\r\n$1\r\nk\r\n")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	parser := NewParser(conn)
+	for _, want := range []string{"+PONG\r\n", "+OK\r\n", "-ERR", "$1\r\nv\r\n"} {
+		value, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if !strings.HasPrefix(string(value.Raw), want) {
+			t.Errorf("Expected reply %q, got %q", want, value.Raw)
+		}
+	}
+
+	// A command split across writes is answered once it is complete
+	sendCommand(t, conn, "*2\r\n$3\r\nGET")
+	time.Sleep(20 * time.Millisecond)
+	sendCommand(t, conn, "\r\n$1\r\nk\r\n")
+	if value, err := parser.Parse(); err != nil || string(value.Raw) != "$1\r\nv\r\n" {
+		t.Errorf("Expected reply to split command, got %v, %v", value, err)
+	}
+}
+
+func TestServer_ConcurrentAccept(t *testing.T) {
+	const maxConns = 64
+
+	for _, reusePort := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reuseport=%v", reusePort), func(t *testing.T) {
+			store, err := storage.NewBasicStore(storage.BasicStoreConfig{
+				Name:      "test-store",
+				MaxMemory: 1024 * 1024,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create basic store: %v", err)
+			}
+			defer store.Close()
+
+			config := DefaultServerConfig()
+			config.MaxConnections = maxConns
+			config.AcceptWorkers = 4
+			config.ReusePort = reusePort
+			server := NewServerWithConfig("127.0.0.1:0", store, &mockCoordinator{}, config)
+			if err := server.Start(); err != nil {
+				t.Fatalf("Failed to start server: %v", err)
+			}
+			defer server.Stop()
+			address := server.listener.Addr().String()
+
+			// Open MaxConnections connections at once; every one must be served
+			conns := make([]net.Conn, maxConns)
+			errs := make(chan error, maxConns)
+			var wg sync.WaitGroup
+			for i := range conns {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					conn, err := net.Dial("tcp", address)
+					if err != nil {
+						errs <- err
+						return
+					}
+					conns[i] = conn
+					conn.SetDeadline(time.Now().Add(5 * time.Second))
+					conn.Write([]byte("*1\r\n$4\r\nPING\r\n"))
+					value, err := NewParser(conn).Parse()
+					if err != nil {
+						errs <- fmt.Errorf("connection %d: %w", i, err)
+						return
+					}
+					if string(value.Raw) != "+PONG\r\n" {
+						errs <- fmt.Errorf("connection %d: got %q", i, value.Raw)
+					}
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+			defer func() {
+				for _, conn := range conns {
+					if conn != nil {
+						conn.Close()
+					}
+				}
+			}()
+
+			if got := server.GetStats().ActiveConnections; got != maxConns {
+				t.Errorf("Expected %d active connections, got %d", maxConns, got)
+			}
+
+			// One more is over the limit: it's told why, then closed
+			conn, err := net.Dial("tcp", address)
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			reply, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatalf("Expected connection over MaxConnections to be closed: %v", err)
+			}
+			if string(reply) != "-ERR max number of clients reached\r\n" {
+				t.Errorf("Expected max clients error, got %q", reply)
+			}
+			if got := server.GetStats().RejectedConnections; got != 1 {
+				t.Errorf("Expected 1 rejected connection, got %d", got)
+			}
+		})
+	}
+}
+
+func TestServer_IdleConnectionsReaped(t *testing.T) {
+	store, err := storage.NewBasicStore(storage.BasicStoreConfig{
+		Name:      "test-store",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create basic store: %v", err)
+	}
+	defer store.Close()
+
+	config := DefaultServerConfig()
+	config.IdleTimeout = 100 * time.Millisecond
+	config.IdleCheckInterval = 20 * time.Millisecond
+	config.IdleTimeoutReply = true
+	server := NewServerWithConfig("127.0.0.1:0", store, &mockCoordinator{}, config)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+	address := server.listener.Addr().String()
+
+	subscriber, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer subscriber.Close()
+	sendCommand(t, subscriber, string(commandBytes("SUBSCRIBE", "news")))
+	readResponse(t, subscriber)
+
+	idle, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer idle.Close()
+
+	// The idle connection is told why and closed
+	idle.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := io.ReadAll(idle)
+	if err != nil {
+		t.Fatalf("Expected idle connection to be closed: %v", err)
+	}
+	if string(reply) != "-ERR idle timeout\r\n" {
+		t.Errorf("Expected idle timeout error, got %q", reply)
+	}
+
+	// The subscriber, idle just as long, is still served
+	time.Sleep(2 * config.IdleTimeout)
+	sendCommand(t, subscriber, string(commandBytes("PING")))
+	if response := readResponse(t, subscriber); response != "+PONG\r\n" {
+		t.Errorf("Expected subscriber to survive reaping, got %q", response)
+	}
+
+	if got := server.GetStats().ReapedConnections; got != 1 {
+		t.Errorf("Expected 1 reaped connection, got %d", got)
+	}
+}
+
+// Benchmark tests
+
+func BenchmarkServer_PingCommand(b *testing.B) {
+	config := storage.BasicStoreConfig{
+		Name:      "bench-store",
+		MaxMemory: 1024 * 1024,
+	}
+
+	store, err := storage.NewBasicStore(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	coord := &mockCoordinator{}
+
+	server := NewServer(":0", store, coord)
+	server.Start()
+	defer server.Stop()
+
+	server.address = server.listener.Addr().String()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	cmd := "*1\r\n$4\r\nPING\r\n"
+	parser := NewParser(conn)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn.Write([]byte(cmd))
+		_, err := parser.Parse()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServer_SetGetCommand(b *testing.B) {
+	config := storage.BasicStoreConfig{
+		Name:      "bench-store",
+		MaxMemory: 1024 * 1024,
+	}
+
+	store, err := storage.NewBasicStore(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	coord := &mockCoordinator{}
+
+	server := NewServer(":0", store, coord)
+	server.Start()
+	defer server.Stop()
+
+	server.address = server.listener.Addr().String()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	setCmd := "*3\r\n$3\r\nSET\r\n$4\r\nkey1\r\n$6\r\nvalue1\r\n"
+	getCmd := "*2\r\n$3\r\nGET\r\n$4\r\nkey1\r\n"
+	parser := NewParser(conn)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// SET
+		conn.Write([]byte(setCmd))
+		_, err := parser.Parse()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		// GET
+		conn.Write([]byte(getCmd))
+		_, err = parser.Parse()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServer_PipelinedSetGet(b *testing.B) {
+	config := storage.BasicStoreConfig{
+		Name:      "bench-store",
+		MaxMemory: 1024 * 1024,
+	}
+
+	store, err := storage.NewBasicStore(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	coord := &mockCoordinator{}
+
+	server := NewServer(":0", store, coord)
+	server.Start()
+	defer server.Stop()
+
+	server.address = server.listener.Addr().String()
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 50 SET/GET pairs sent in one write
+	const pairs = 50
+	var batch []byte
+	for i := 0; i < pairs; i++ {
+		batch = append(batch, "*3\r\n$3\r\nSET\r\n$4\r\nkey1\r\n$6\r\nvalue1\r\n"...)
+		batch = append(batch, "*2\r\n$3\r\nGET\r\n$4\r\nkey1\r\n"...)
+	}
+	parser := NewParser(conn)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn.Write(batch)
+		for j := 0; j < 2*pairs; j++ {
+			if _, err := parser.Parse(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestServer_CrossSlotInProxyMode(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Fake owner of "remote:" keys, counting the requests proxied to it
+	var proxied atomic.Int64
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": "from-owner"})
+	}))
+	defer owner.Close()
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(owner.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to parse owner address: %v", err)
+	}
+
+	server.coord = &mockRoutedCoordinator{}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"other-node": {NodeID: "other-node", Address: host, Metadata: map[string]string{"http_port": port}},
+		},
+	}))
+	server.store.Set("local1", "value1", "test", 0)
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Every key could be proxied, but spanning slots still rejects the
+	// command before any key is touched
+	for _, args := range [][]string{
+		{"DEL", "local1", "remote:key"},
+		{"EXISTS", "local1", "remote:key"},
+		{"EXISTS", "remote:key", "remote:other"},
+	} {
+		sendCommand(t, conn, string(commandBytes(args...)))
+		if response := readResponse(t, conn); !strings.HasPrefix(response, "-CROSSSLOT") {
+			t.Errorf("%v in proxy mode: expected CROSSSLOT, got %q", args, response)
+		}
+	}
+	if n := proxied.Load(); n != 0 {
+		t.Errorf("Cross-slot commands proxied %d requests, want none", n)
+	}
+	if !server.store.Has("local1") {
+		t.Error("Cross-slot DEL deleted a local key")
+	}
+
+	// Remote keys sharing a slot are proxied
+	sendCommand(t, conn, string(commandBytes("EXISTS", "remote:{t}a", "remote:{t}b")))
+	if response := readResponse(t, conn); response != ":2\r\n" {
+		t.Errorf("EXISTS within a slot in proxy mode: expected :2, got %q", response)
+	}
+	if n := proxied.Load(); n != 2 {
+		t.Errorf("EXISTS within a slot proxied %d requests, want 2", n)
+	}
+}
+
+func TestServer_KeyRoutingRedirect(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Fake owner of "remote:" keys
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": "from-owner"})
+	}))
+	defer owner.Close()
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(owner.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to parse owner address: %v", err)
+	}
+
+	server.coord = &mockRoutedCoordinator{}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"other-node": {NodeID: "other-node", Address: host, Metadata: map[string]string{"http_port": port, "resp_port": "8080"}},
+		},
+	}))
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Proxy mode (the default) answers for the owner
+	sendCommand(t, conn, string(commandBytes("GET", "remote:key")))
+	if response := readResponse(t, conn); response != "$10\r\nfrom-owner\r\n" {
+		t.Errorf("GET in proxy mode: expected the owner's value, got %q", response)
+	}
+
+	// Redirect mode sends the client to the owner
+	server.SetKeyRouting(KeyRoutingRedirect)
+	moved := fmt.Sprintf("-MOVED %d %s\r\n", cluster.GetHashSlot("remote:key"), net.JoinHostPort(host, "8080"))
+	for _, args := range [][]string{
+		{"GET", "remote:key"},
+		{"SET", "remote:key", "v"},
+		{"LPUSH", "remote:key", "v"},
+		{"DEL", "remote:key"},
+	} {
+		sendCommand(t, conn, string(commandBytes(args...)))
+		if response := readResponse(t, conn); response != moved {
+			t.Errorf("%s in redirect mode: expected %q, got %q", args[0], moved, response)
+		}
+	}
+
+	// Remote keys spanning slots can't be redirected to one node
+	sendCommand(t, conn, string(commandBytes("DEL", "remote:a", "remote:b")))
+	if response := readResponse(t, conn); !strings.HasPrefix(response, "-CROSSSLOT") {
+		t.Errorf("DEL across slots in redirect mode: expected CROSSSLOT, got %q", response)
+	}
+
+	// Local keys are still served
+	sendCommand(t, conn, string(commandBytes("SET", "local", "v")))
+	if response := readResponse(t, conn); response != "+OK\r\n" {
+		t.Errorf("SET of a local key in redirect mode: expected +OK, got %q", response)
+	}
+}
+
+func TestServer_KeyRoutingBySlot(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Fake owner answering every proxied read
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": "from-owner"})
+	}))
+	defer owner.Close()
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(owner.URL, "http://"))
+
+	ring := cluster.NewHashRing(cluster.DefaultHashRingConfig())
+	ring.AddNode("test-node", "127.0.0.1", 1)
+	ring.AddNode("other-node", host, 2)
+	membership := &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"test-node":  {NodeID: "test-node", Address: "127.0.0.1", Status: cluster.NodeAlive, Metadata: map[string]string{"resp_port": "7000"}},
+			"other-node": {NodeID: "other-node", Address: host, Status: cluster.NodeAlive, Metadata: map[string]string{"http_port": port, "resp_port": "8080"}},
+		},
+	}
+	server.coord = &mockRingCoordinator{ring: ring, membership: membership}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", membership))
+
+	// Two unrelated keys sharing a slot owned by the other node
+	bySlot := make(map[uint16]string)
+	var first, second string
+	for i := 0; second == ""; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		slot := cluster.GetHashSlot(key)
+		if ring.GetNode(key) != "other-node" {
+			continue
+		}
+		if other, ok := bySlot[slot]; ok {
+			first, second = other, key
+		}
+		bySlot[slot] = key
+	}
+	slot := cluster.GetHashSlot(first)
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	// Proxy mode reaches the ring's owner
+	sendCommand(t, conn, string(commandBytes("GET", first)))
+	if response := readResponse(t, conn); response != "$10\r\nfrom-owner\r\n" {
+		t.Errorf("GET in proxy mode: expected the owner's value, got %q", response)
+	}
+
+	// Every key in the slot is redirected to the same node
+	server.SetKeyRouting(KeyRoutingRedirect)
+	moved := fmt.Sprintf("-MOVED %d %s\r\n", slot, net.JoinHostPort(host, "8080"))
+	for _, key := range []string{first, second} {
+		sendCommand(t, conn, string(commandBytes("GET", key)))
+		if response := readResponse(t, conn); response != moved {
+			t.Errorf("GET %s in redirect mode: expected %q, got %q", key, moved, response)
+		}
+	}
+
+	// CLUSTER SLOTS lists the slot under the node MOVED pointed to
+	sendCommand(t, conn, string(commandBytes("CLUSTER", "SLOTS")))
+	reply, err := NewParser(conn).Parse()
+	if err != nil {
+		t.Fatalf("CLUSTER SLOTS: %v", err)
+	}
+	var covered int
+	found := false
+	for _, value := range reply.Array {
+		start, end := value.Array[0].Int, value.Array[1].Int
+		covered += int(end-start) + 1
+		if start <= int64(slot) && int64(slot) <= end {
+			node := value.Array[2].Array
+			found = node[0].Str == host && node[1].Int == 8080 && node[2].Str == "other-node"
+		}
+	}
+	if covered != cluster.HashSlotCount {
+		t.Errorf("CLUSTER SLOTS: expected every slot covered once, got %d", covered)
+	}
+	if !found {
+		t.Errorf("CLUSTER SLOTS: expected slot %d owned by other-node at %s:8080", slot, host)
+	}
+
+	// CLUSTER SHARDS has one shard per owner
+	sendCommand(t, conn, string(commandBytes("CLUSTER", "SHARDS")))
+	reply, err = NewParser(conn).Parse()
+	if err != nil {
+		t.Fatalf("CLUSTER SHARDS: %v", err)
+	}
+	if len(reply.Array) != 2 {
+		t.Fatalf("CLUSTER SHARDS: expected 2 shards, got %d", len(reply.Array))
+	}
+	for _, shard := range reply.Array {
+		node := shard.Array[3].Array[0].Array
+		if node[1].Str != "test-node" && node[1].Str != "other-node" {
+			t.Errorf("CLUSTER SHARDS: unexpected node %q", node[1].Str)
+		}
+		if node[13].Str != "online" {
+			t.Errorf("CLUSTER SHARDS: expected %s online, got %q", node[1].Str, node[13].Str)
+		}
+	}
+}
+
+// newMigrationPeer serves /internal/replicate-batch as a cluster node does,
+// restoring each migrated entry into the store of its name
+func newMigrationPeer(stores map[string]*storage.BasicStore) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/replicate-batch" {
+			http.NotFound(w, r)
+			return
+		}
+		var payload struct {
+			Entries []cluster.MigrationEntry `json:"entries"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, entry := range payload.Entries {
+			store, ok := stores[entry.Store]
+			if !ok {
+				http.Error(w, "unknown store "+entry.Store, http.StatusBadRequest)
+				return
+			}
+			ttl := time.Duration(entry.TTL * float64(time.Second))
+			if _, err := store.RestoreRaw(r.Context(), entry.Key, entry.Value, entry.ValueType, entry.SessionID, ttl, entry.LamportTS); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]int{"applied": len(payload.Entries)})
+	}))
+}
+
+func TestServer_DecommissionMovesEveryStoreAndType(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	newStores := func() *storage.StoreManager {
+		sm := storage.NewStoreManager(storage.StoreManagerConfig{
+			DataDir:           t.TempDir(),
+			MaxStores:         4,
+			GlobalPersistence: config.PersistenceConfig{Enabled: false, Strategy: "disabled"},
+			GlobalCacheConfig: config.CacheConfig{MaxMemory: "64MB", DefaultTTL: "0", MaxStores: 4},
+		})
+		for _, name := range []string{"default", "sessions"} {
+			if err := sm.CreateStore(config.StoreConfig{Name: name, MaxMemory: "16MB", EvictionPolicy: "lru"}, context.Background()); err != nil {
+				t.Fatalf("Failed to create %s store: %v", name, err)
+			}
+		}
+		return sm
+	}
+	local, remote := newStores(), newStores()
+	defer local.Close()
+	defer remote.Close()
+	server.store = local.GetStore("default")
+	server.SetStoreManager(local)
+
+	peer := newMigrationPeer(map[string]*storage.BasicStore{
+		"default":  remote.GetStore("default"),
+		"sessions": remote.GetStore("sessions"),
+	})
+	defer peer.Close()
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(peer.URL, "http://"))
+
+	ring := cluster.NewHashRing(cluster.DefaultHashRingConfig())
+	ring.AddNode("test-node", "127.0.0.1", 1)
+	ring.AddNode("other-node", host, 2)
+	server.coord = &mockRingCoordinator{ring: ring}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"other-node": {NodeID: "other-node", Address: host, Metadata: map[string]string{"http_port": port}},
+		},
+	}))
+
+	// Key names this node owns, so decommissioning moves them
+	ownedKey := func(prefix string) string {
+		for i := 0; ; i++ {
+			if key := fmt.Sprintf("%s:%d", prefix, i); ring.GetNode(key) == "test-node" {
+				return key
+			}
+		}
+	}
+	defaults, sessions := local.GetStore("default"), local.GetStore("sessions")
+	str, num, doc, list, set, token := ownedKey("str"), ownedKey("num"), ownedKey("doc"), ownedKey("list"), ownedKey("set"), ownedKey("token")
+	defaults.Set(str, "hello", "", time.Hour)
+	defaults.Set(num, 42, "", 0)
+	defaults.Set(doc, map[string]interface{}{"name": "widget", "qty": float64(3)}, "", 0)
+	if _, err := defaults.ListPush(list, false, "a", "b", "c"); err != nil {
+		t.Fatalf("ListPush failed: %v", err)
+	}
+	if _, err := defaults.SetAdd(set, "x", "y"); err != nil {
+		t.Fatalf("SetAdd failed: %v", err)
+	}
+	sessions.Set(token, "secret", "sess-1", 0)
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	sendCommand(t, conn, string(commandBytes("DECOMMISSION")))
+	if response := readResponse(t, conn); response != ":6\r\n" {
+		t.Fatalf("DECOMMISSION: expected 6 keys moved, got %q", response)
+	}
+
+	// Every value arrived with its type, store, session and TTL
+	target := remote.GetStore("default")
+	if got, err := target.Get(str); err != nil || got != "hello" {
+		t.Errorf("String: got %v (%v)", got, err)
+	}
+	if ttl, ok := target.TTL(str); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Expected the string to keep its TTL, got %v", ttl)
+	}
+	if got, err := target.Get(num); err != nil || got != 42 {
+		t.Errorf("Number: expected int 42, got %#v (%v)", got, err)
+	}
+	if ttl, ok := target.TTL(num); !ok || ttl != -1 {
+		t.Errorf("Expected the number to keep no expiry, got %v", ttl)
+	}
+	if got, err := target.Get(doc); err != nil || !reflect.DeepEqual(got, map[string]interface{}{"name": "widget", "qty": float64(3)}) {
+		t.Errorf("Document: got %#v (%v)", got, err)
+	}
+	if got, err := target.ListRange(list, 0, -1); err != nil || !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("List: got %v (%v)", got, err)
+	}
+	if got, err := target.SetMembers(set); err != nil || len(got) != 2 {
+		t.Errorf("Set: got %v (%v)", got, err)
+	}
+	if got, err := remote.GetStore("sessions").Get(token); err != nil || got != "secret" {
+		t.Errorf("Key of the sessions store: got %v (%v)", got, err)
+	}
+	if _, err := target.Get(token); err == nil {
+		t.Error("Expected the sessions key to stay out of the default store")
+	}
+	if n, err := remote.GetStore("sessions").DeleteSession("sess-1"); err != nil || n != 1 {
+		t.Errorf("Expected the migrated key to keep its session, deleted %d (%v)", n, err)
+	}
+
+	for _, key := range []string{str, num, doc, list, set} {
+		if defaults.Has(key) {
+			t.Errorf("Expected %s deleted locally", key)
+		}
+	}
+	if sessions.Has(token) {
+		t.Error("Expected the sessions key deleted locally")
+	}
+}
+
+func TestServer_ClusterMigrateSlots(t *testing.T) {
+	server, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// The target node applies migrated batches to its own store
+	target, err := storage.NewBasicStore(storage.BasicStoreConfig{Name: "target", MaxMemory: 1024 * 1024, CleanupInterval: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to create target store: %v", err)
+	}
+	defer target.Close()
+	peer := newMigrationPeer(map[string]*storage.BasicStore{"": target})
+	defer peer.Close()
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(peer.URL, "http://"))
+
+	ring := cluster.NewHashRing(cluster.DefaultHashRingConfig())
+	ring.AddNode("test-node", "127.0.0.1", 1)
+	ring.AddNode("other-node", host, 2)
+	server.coord = &mockRingCoordinator{ring: ring}
+	server.SetNodeCommunicator(cluster.NewNodeCommunicator("test-node", &mockMembership{
+		members: map[string]*cluster.ClusterMember{
+			"other-node": {NodeID: "other-node", Address: host, Metadata: map[string]string{"http_port": port, "resp_port": "8080"}},
+		},
+	}))
+
+	// Keys across a 100-slot range, one with a TTL, and one outside it
+	const startSlot, endSlot = 1000, 1099
+	values := make(map[string]string)
+	for i := 0; len(values) < 300; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		if slot := cluster.GetHashSlot(key); slot >= startSlot && slot <= endSlot {
+			values[key] = fmt.Sprintf("value-%d", i)
+			server.store.Set(key, values[key], "", 0)
+		}
+	}
+	var ttlKey string
+	for key := range values {
+		ttlKey = key
+		server.store.Set(key, values[key], "", time.Hour)
+		break
+	}
+	outside := "outside"
+	if slot := cluster.GetHashSlot(outside); slot >= startSlot && slot <= endSlot {
+		t.Fatalf("Expected %q outside the migrated range", outside)
+	}
+	server.store.Set(outside, "stays", "", 0)
+
+	conn, err := net.Dial("tcp", server.address)
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	sendCommand(t, conn, string(commandBytes("CLUSTER", "MIGRATESLOTS", "1000", "1099", "other-node")))
+	if response := readResponse(t, conn); response != fmt.Sprintf(":%d\r\n", len(values)) {
+		t.Fatalf("CLUSTER MIGRATESLOTS: expected %d keys moved, got %q", len(values), response)
+	}
+
+	// Every key arrived intact and left this node
+	for key, want := range values {
+		if got, err := target.Get(key); err != nil || got != want {
+			t.Errorf("Key %s on the target: expected %q, got %v (%v)", key, want, got, err)
+		}
+		if _, err := server.store.Get(key); err == nil {
+			t.Errorf("Expected migrated key %s to be deleted locally", key)
+		}
+	}
+	if ttl, ok := target.TTL(ttlKey); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Expected %s to keep its TTL on the target, got %v", ttlKey, ttl)
+	}
+	if _, err := server.store.Get(outside); err != nil {
+		t.Error("Expected the key outside the range to stay local")
+	}
+
+	// The slots now belong to the target, so clients are redirected there
+	server.SetKeyRouting(KeyRoutingRedirect)
+	for _, slot := range []uint16{startSlot, endSlot} {
+		if owner, ok := ring.SlotOwner(slot); !ok || owner != "other-node" {
+			t.Errorf("Expected slot %d assigned to other-node, got %q", slot, owner)
+		}
+	}
+	for key := range values {
+		moved := fmt.Sprintf("-MOVED %d %s\r\n", cluster.GetHashSlot(key), net.JoinHostPort(host, "8080"))
+		sendCommand(t, conn, string(commandBytes("GET", key)))
+		if response := readResponse(t, conn); response != moved {
+			t.Errorf("GET %s after migration: expected %q, got %q", key, moved, response)
+		}
+	}
+
+	sendCommand(t, conn, string(commandBytes("CLUSTER", "MIGRATESLOTS", "10", "5", "other-node")))
+	if response := readResponse(t, conn); !strings.HasPrefix(response, "-ERR") {
+		t.Errorf("CLUSTER MIGRATESLOTS with an inverted range: expected an error, got %q", response)
+	}
 }
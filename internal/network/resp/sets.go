@@ -0,0 +1,141 @@
+package resp
+
+import (
+	"fmt"
+	"strings"
+
+	"hypercache/internal/storage"
+)
+
+// formatMembers formats set members as a RESP array of bulk strings
+func formatMembers(formatter *Formatter, members []string) []byte {
+	items := make([][]byte, len(members))
+	for i, m := range members {
+		items[i] = formatter.FormatBulkString(m)
+	}
+	return formatter.FormatArray(items)
+}
+
+// handleSetModify handles SADD and SREM
+func (s *Server) handleSetModify(clientConn *ClientConn, cmd Command, add bool) ([]byte, error) {
+	if len(cmd.Args) < 2 {
+		return nil, fmt.Errorf("wrong number of arguments for %s", cmd.Name)
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkOwnedKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	var n int
+	var err error
+	if add {
+		n, err = store.SetAdd(key, cmd.Args[1:]...)
+	} else {
+		n, err = store.SetRemove(key, cmd.Args[1:]...)
+	}
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(n)), nil
+}
+
+// handleSMembers returns all members of a set
+func (s *Server) handleSMembers(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for SMEMBERS")
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	members, err := store.SetMembers(key)
+	if err != nil {
+		return typedReply(err)
+	}
+	return formatMembers(NewFormatter(), members), nil
+}
+
+// handleSIsMember reports whether a member belongs to a set
+func (s *Server) handleSIsMember(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for SISMEMBER")
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	ok, err := store.SetIsMember(key, cmd.Args[1])
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	if ok {
+		return formatter.FormatInteger(1), nil
+	}
+	return formatter.FormatInteger(0), nil
+}
+
+// handleSCard returns the number of members in a set
+func (s *Server) handleSCard(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for SCARD")
+	}
+
+	key := cmd.Args[0]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	n, err := store.SetCard(key)
+	if err != nil {
+		return typedReply(err)
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatInteger(int64(n)), nil
+}
+
+// handleSetAlgebra handles SINTER, SUNION, SDIFF and their STORE variants.
+// All keys, including the destination, must be local to this node.
+func (s *Server) handleSetAlgebra(clientConn *ClientConn, cmd Command, op storage.SetOp, store bool) ([]byte, error) {
+	minArgs := 1
+	if store {
+		minArgs = 2
+	}
+	if len(cmd.Args) < minArgs {
+		return nil, fmt.Errorf("wrong number of arguments for %s", strings.ToUpper(cmd.Name))
+	}
+
+	if crossSlot, err := s.checkLocalKeys(store, cmd.Args...); err != nil || crossSlot != nil {
+		return crossSlot, err
+	}
+
+	st := s.getActiveStore(clientConn)
+	formatter := NewFormatter()
+
+	if store {
+		n, err := st.SetCombineStore(op, cmd.Args[0], cmd.Args[1:]...)
+		if err != nil {
+			return typedReply(err)
+		}
+		return formatter.FormatInteger(int64(n)), nil
+	}
+
+	members, err := st.SetCombine(op, cmd.Args...)
+	if err != nil {
+		return typedReply(err)
+	}
+	return formatMembers(formatter, members), nil
+}
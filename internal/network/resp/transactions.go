@@ -0,0 +1,165 @@
+package resp
+
+import (
+	"fmt"
+	"strings"
+
+	"hypercache/internal/storage"
+)
+
+// watch is a key watched by a connection, with its version at WATCH time
+type watch struct {
+	store   *storage.BasicStore
+	key     string
+	version uint64
+}
+
+// isTransactionCommand reports whether a command runs immediately inside
+// MULTI instead of being queued
+func isTransactionCommand(name string) bool {
+	switch strings.ToUpper(name) {
+	case "MULTI", "EXEC", "DISCARD", "WATCH", "UNWATCH", "RESET":
+		return true
+	}
+	return false
+}
+
+// queueCommand queues a command issued between MULTI and EXEC
+func (s *Server) queueCommand(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	clientConn.queued = append(clientConn.queued, cmd)
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("QUEUED"), nil
+}
+
+// handleMulti starts a transaction
+func (s *Server) handleMulti(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for MULTI")
+	}
+	if clientConn.inMulti.Load() {
+		return nil, fmt.Errorf("MULTI calls can not be nested")
+	}
+
+	clientConn.inMulti.Store(true)
+	clientConn.queued = nil
+
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("OK"), nil
+}
+
+// execLockMode reports how a command holds execMu: exclusively for EXEC,
+// from checking its watches until its last queued command has run, and
+// shared for client writes run outside a transaction. Writes applied by the
+// cluster itself, such as replication and expiry, don't take it.
+func (s *Server) execLockMode(clientConn *ClientConn, name string) (exclusive, shared bool) {
+	if strings.EqualFold(name, "EXEC") {
+		return true, false
+	}
+	return false, isWriteCommand(name) && !clientConn.inMulti.Load()
+}
+
+// handleExec runs the queued commands and returns their replies as an array.
+// If any watched key was modified since WATCH, nothing runs and EXEC returns
+// a null array. The caller holds execMu exclusively, so no other client
+// write can land between the watch check and the queued commands.
+func (s *Server) handleExec(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for EXEC")
+	}
+	if !clientConn.inMulti.Load() {
+		return nil, fmt.Errorf("EXEC without MULTI")
+	}
+
+	queued := clientConn.queued
+	clientConn.inMulti.Store(false)
+	clientConn.queued = nil
+
+	formatter := NewFormatter()
+	if !s.watchesIntact(clientConn) {
+		s.unwatchAll(clientConn)
+		return formatter.FormatNullArray(), nil
+	}
+	s.unwatchAll(clientConn)
+
+	replies := make([][]byte, len(queued))
+	for i, queuedCmd := range queued {
+		// Each reply is kept until all are sent, so none may share the
+		// connection's reply buffer
+		clientConn.reply = nil
+		reply, err := s.routeCommand(clientConn, queuedCmd)
+		if err != nil {
+			reply = formatter.FormatError(fmt.Sprintf("ERR %s", err.Error()))
+		}
+		replies[i] = reply
+	}
+	return formatter.FormatArray(replies), nil
+}
+
+// handleDiscard abandons a transaction
+func (s *Server) handleDiscard(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for DISCARD")
+	}
+	if !clientConn.inMulti.Load() {
+		return nil, fmt.Errorf("DISCARD without MULTI")
+	}
+
+	clientConn.inMulti.Store(false)
+	clientConn.queued = nil
+	s.unwatchAll(clientConn)
+
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("OK"), nil
+}
+
+// handleWatch snapshots the versions of keys so EXEC can detect changes
+func (s *Server) handleWatch(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) == 0 {
+		return nil, fmt.Errorf("wrong number of arguments for WATCH")
+	}
+	if clientConn.inMulti.Load() {
+		return nil, fmt.Errorf("WATCH inside MULTI is not allowed")
+	}
+
+	store := s.getActiveStore(clientConn)
+	for _, key := range cmd.Args {
+		clientConn.watches = append(clientConn.watches, watch{
+			store:   store,
+			key:     key,
+			version: store.WatchKey(key),
+		})
+	}
+
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("OK"), nil
+}
+
+// handleUnwatch forgets all watched keys
+func (s *Server) handleUnwatch(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for UNWATCH")
+	}
+
+	s.unwatchAll(clientConn)
+
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString("OK"), nil
+}
+
+// watchesIntact reports whether no watched key has been modified since WATCH
+func (s *Server) watchesIntact(clientConn *ClientConn) bool {
+	for _, w := range clientConn.watches {
+		if w.store.KeyVersion(w.key) != w.version {
+			return false
+		}
+	}
+	return true
+}
+
+// unwatchAll releases every watch held by the connection
+func (s *Server) unwatchAll(clientConn *ClientConn) {
+	for _, w := range clientConn.watches {
+		w.store.UnwatchKey(w.key)
+	}
+	clientConn.watches = nil
+}
@@ -0,0 +1,197 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"hypercache/internal/storage"
+)
+
+// typedReply converts store errors into RESP replies. WRONGTYPE and OOM errors
+// are returned with their own error prefix rather than wrapped in ERR.
+func typedReply(err error) ([]byte, error) {
+	if errors.Is(err, storage.ErrWrongType) || errors.Is(err, storage.ErrNotHyperLogLog) || errors.Is(err, storage.ErrOOM) {
+		formatter := NewFormatter()
+		return formatter.FormatError(err.Error()), nil
+	}
+	return nil, err
+}
+
+// checkLocalKey rejects keys this node neither owns nor replicates. Typed
+// reads run against the local store only; they are not proxied.
+func (s *Server) checkLocalKey(key string) error {
+	return s.checkKeyHolder(key, true)
+}
+
+// checkOwnedKey rejects keys owned by another node. Typed writes run against
+// the local store only and are neither proxied nor replicated, so one
+// accepted on a replica would diverge from the owner's copy.
+func (s *Server) checkOwnedKey(key string) error {
+	return s.checkKeyHolder(key, false)
+}
+
+// checkKeyHolder rejects keys this node doesn't own, or replicate if
+// replicas is set, redirecting the client if so configured
+func (s *Server) checkKeyHolder(key string, replicas bool) error {
+	if s.coord == nil || s.coord.GetRouting() == nil {
+		return nil
+	}
+	routing := s.coord.GetRouting()
+	if routing.IsLocal(key) || (replicas && routing.IsReplica(key)) {
+		return nil
+	}
+	if err := s.redirect(key); err != nil {
+		return err
+	}
+	return fmt.Errorf("key '%s' is owned by node %s", key, routing.RouteKey(key))
+}
+
+// checkLocalKeys is checkLocalKey for multi-key typed commands, with the
+// first key checked by checkOwnedKey instead if the command writes it. Keys
+// that span hash slots get a CROSSSLOT reply, matching routeKeys.
+func (s *Server) checkLocalKeys(writesFirst bool, keys ...string) ([]byte, error) {
+	if crossSlot := s.crossSlotReply(keys); crossSlot != nil {
+		return crossSlot, nil
+	}
+	for i, key := range keys {
+		check := s.checkLocalKey
+		if i == 0 && writesFirst {
+			check = s.checkOwnedKey
+		}
+		if err := check(key); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// handleType returns the kind of value stored at a key
+func (s *Server) handleType(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for TYPE")
+	}
+
+	store := s.getActiveStore(clientConn)
+	formatter := NewFormatter()
+	return formatter.FormatSimpleString(store.Type(cmd.Args[0])), nil
+}
+
+// handleObject handles OBJECT ENCODING key and OBJECT REFCOUNT key,
+// replying nil for a missing key
+func (s *Server) handleObject(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments for OBJECT")
+	}
+	key := cmd.Args[1]
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	formatter := NewFormatter()
+	switch strings.ToUpper(cmd.Args[0]) {
+	case "ENCODING":
+		encoding := store.Encoding(key)
+		if encoding == "" {
+			return formatter.FormatNull(), nil
+		}
+		return formatter.FormatBulkString(encoding), nil
+	case "REFCOUNT":
+		refs := store.RefCount(key)
+		if refs == 0 {
+			return formatter.FormatNull(), nil
+		}
+		return formatter.FormatInteger(int64(refs)), nil
+	default:
+		return nil, fmt.Errorf("unknown OBJECT subcommand '%s'", cmd.Args[0])
+	}
+}
+
+// handleScan handles SCAN cursor [MATCH pattern] [COUNT count] [TYPE type],
+// iterating the keys of the selected store held by this node. Replies with
+// the next cursor (0 when done) and the keys found.
+func (s *Server) handleScan(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) < 1 || len(cmd.Args)%2 != 1 {
+		return nil, fmt.Errorf("wrong number of arguments for SCAN")
+	}
+
+	cursor, err := strconv.ParseUint(cmd.Args[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var opts storage.ScanOptions
+	for i := 1; i < len(cmd.Args); i += 2 {
+		value := cmd.Args[i+1]
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "MATCH":
+			opts.Match = value
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			if count < 1 {
+				return nil, fmt.Errorf("syntax error")
+			}
+			opts.Count = count
+		case "TYPE":
+			opts.Type = strings.ToLower(value)
+		default:
+			return nil, fmt.Errorf("syntax error")
+		}
+	}
+
+	next, keys := s.getActiveStore(clientConn).Scan(cursor, opts)
+	formatter := NewFormatter()
+	return formatter.FormatArray([][]byte{
+		formatter.FormatBulkString(strconv.FormatUint(next, 10)),
+		formatMembers(formatter, keys),
+	}), nil
+}
+
+// handleSort handles SORT key [ASC|DESC] [ALPHA] [LIMIT offset count] on lists and sets.
+// SORT is read-only: BY, GET and STORE are not supported.
+func (s *Server) handleSort(clientConn *ClientConn, cmd Command) ([]byte, error) {
+	if len(cmd.Args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments for SORT")
+	}
+
+	key := cmd.Args[0]
+	opts := storage.SortOptions{Count: -1}
+	for i := 1; i < len(cmd.Args); i++ {
+		switch strings.ToUpper(cmd.Args[i]) {
+		case "ASC":
+			opts.Desc = false
+		case "DESC":
+			opts.Desc = true
+		case "ALPHA":
+			opts.Alpha = true
+		case "LIMIT":
+			if i+2 >= len(cmd.Args) {
+				return nil, fmt.Errorf("syntax error")
+			}
+			offset, err1 := strconv.Atoi(cmd.Args[i+1])
+			count, err2 := strconv.Atoi(cmd.Args[i+2])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("value is not an integer or out of range")
+			}
+			opts.Offset, opts.Count = offset, count
+			i += 2
+		default:
+			return nil, fmt.Errorf("syntax error")
+		}
+	}
+
+	if err := s.checkLocalKey(key); err != nil {
+		return nil, err
+	}
+
+	store := s.getActiveStore(clientConn)
+	elems, err := store.Sort(key, opts)
+	if err != nil {
+		return typedReply(err)
+	}
+	return formatMembers(NewFormatter(), elems), nil
+}
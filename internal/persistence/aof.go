@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -36,6 +37,11 @@ type LogEntry struct {
 	Value     []byte    `json:"value,omitempty"`
 	TTL       int64     `json:"ttl,omitempty"`
 	SessionID string    `json:"session_id,omitempty"`
+
+	// Set for entries restored from a snapshot, which record the stored
+	// value type and exact expiry; the AOF file format carries neither
+	ValueType string    `json:"value_type,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
 }
 
 // NewAOFManager creates a new AOF manager
@@ -94,6 +100,14 @@ func (aof *AOFManager) Close() error {
 	return nil
 }
 
+// SetSyncPolicy changes when writes are flushed and fsynced ("always",
+// "everysec" or "no"), starting with the next write
+func (aof *AOFManager) SetSyncPolicy(policy string) {
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+	aof.config.SyncPolicy = policy
+}
+
 // LogSet logs a SET operation to AOF
 func (aof *AOFManager) LogSet(key string, value []byte, ttl time.Duration, sessionID string) error {
 	entry := LogEntry{
@@ -410,7 +424,14 @@ func (aof *AOFManager) convertToLogEntry(key string, value interface{}) LogEntry
 	}
 
 	// Convert value to bytes (simplified)
-	if str, ok := value.(string); ok {
+	if snap, ok := value.(SnapshotEntry); ok {
+		entry.Value = snap.Value
+		entry.SessionID = snap.SessionID
+		if !snap.ExpiresAt.IsZero() {
+			// Round up so the key doesn't expire early on replay
+			entry.TTL = int64(math.Ceil(time.Until(snap.ExpiresAt).Seconds()))
+		}
+	} else if str, ok := value.(string); ok {
 		entry.Value = []byte(str)
 	} else {
 		entry.Value = []byte(fmt.Sprintf("%v", value))
@@ -131,6 +131,13 @@ func (he *HybridEngine) SetSnapshotDataFunc(fn SnapshotDataFunc) {
 	he.snapshotDataFn = fn
 }
 
+// SetSyncPolicy changes the AOF sync policy of a running engine.
+func (he *HybridEngine) SetSyncPolicy(policy string) {
+	if he.aofManager != nil {
+		he.aofManager.SetSyncPolicy(policy)
+	}
+}
+
 // Start initializes the persistence engine and starts background workers
 func (he *HybridEngine) Start(ctx context.Context) error {
 	he.mu.Lock()
@@ -285,11 +292,21 @@ func (he *HybridEngine) ReadEntries() ([]*LogEntry, error) {
 
 			// Convert snapshot data to log entries
 			for key, value := range data {
+				snap, ok := value.(SnapshotEntry)
+				if !ok {
+					continue
+				}
 				entry := &LogEntry{
-					Timestamp: time.Now(),
+					Timestamp: snap.CreatedAt,
 					Operation: "SET",
 					Key:       key,
-					Value:     []byte(fmt.Sprintf("%v", value)),
+					Value:     snap.Value,
+					SessionID: snap.SessionID,
+					ExpiresAt: snap.ExpiresAt,
+				}
+				// "unknown" marks a value the snapshot stored as text
+				if snap.ValueType != "unknown" {
+					entry.ValueType = snap.ValueType
 				}
 				allEntries = append(allEntries, entry)
 			}
@@ -226,9 +226,15 @@ func (sm *SnapshotManager) LoadSnapshot(ctx context.Context) (map[string]interfa
 
 // Helper methods
 
+// convertToSnapshotEntry converts a value of the snapshot data. A store
+// passes SnapshotEntry values, which are written as is; other values are
+// stored as strings.
 func (sm *SnapshotManager) convertToSnapshotEntry(key string, value interface{}) SnapshotEntry {
-	// This is a simplified conversion - in real implementation,
-	// we'd need to properly handle the CacheItem structure
+	if entry, ok := value.(SnapshotEntry); ok {
+		entry.Key = key
+		return entry
+	}
+
 	entry := SnapshotEntry{
 		Key:          key,
 		CreatedAt:    time.Now(),
@@ -251,20 +257,11 @@ func (sm *SnapshotManager) convertToSnapshotEntry(key string, value interface{})
 	return entry
 }
 
+// convertFromSnapshotEntry returns the loaded entry as a snapshot data
+// value: the SnapshotEntry itself, with the raw value, type, expiry and
+// session needed to restore the key exactly
 func (sm *SnapshotManager) convertFromSnapshotEntry(entry SnapshotEntry) interface{} {
-	// Convert back to appropriate format
-	// This would need to match your BasicStore's CacheItem structure
-	return map[string]interface{}{
-		"key":           entry.Key,
-		"value":         entry.Value,
-		"value_type":    entry.ValueType,
-		"created_at":    entry.CreatedAt,
-		"expires_at":    entry.ExpiresAt,
-		"session_id":    entry.SessionID,
-		"access_count":  entry.AccessCount,
-		"last_accessed": entry.LastAccessed,
-		"size":          entry.Size,
-	}
+	return entry
 }
 
 func (sm *SnapshotManager) findLatestSnapshot() (string, error) {
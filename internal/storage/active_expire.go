@@ -0,0 +1,33 @@
+package storage
+
+// SetActiveExpire pauses (false) or resumes the removal of expired keys:
+// the cleanup sweeper and the reclaiming of expired keys found by reads.
+// While paused, expired keys still read as missing but stay in the store,
+// counted by Size, until SweepExpired or expiry is resumed. The background
+// evictor still drops expired keys under memory pressure. For tests (DEBUG
+// SET-ACTIVE-EXPIRE); enabled by default.
+func (s *BasicStore) SetActiveExpire(enabled bool) {
+	s.activeExpireOff.Store(!enabled)
+}
+
+// SweepExpired removes every expired key now, even while active expiry is
+// paused, and returns how many were removed
+func (s *BasicStore) SweepExpired() int {
+	removed := 0
+	now := s.now()
+	for _, key := range s.data.CollectExpired(func(item *CacheItem) bool { return item.IsExpired(now) }) {
+		if s.deleteIf(key, "expired", func(item *CacheItem) bool { return s.expired(item) }) == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// reclaimExpired removes an expired key found by a read, unless active
+// expiry is paused
+func (s *BasicStore) reclaimExpired(key string) {
+	if s.activeExpireOff.Load() {
+		return
+	}
+	_ = s.deleteWithEvent(key, "expired")
+}
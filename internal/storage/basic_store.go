@@ -1,15 +1,18 @@
 package storage
 
 import (
+	"bytes"
+	"container/heap"
 	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"reflect"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"hypercache/internal/cache"
 	"hypercache/internal/filter"
@@ -27,9 +30,37 @@ type CacheItem struct {
 	CreatedAt        time.Time
 	ExpiresAt        time.Time
 	SessionID        string
-	AccessCount      uint64
-	LastAccessed     time.Time
 	LamportTimestamp uint64 // Logical clock value when this item was last written
+	Version          uint64 // Store-wide monotonic version, bumped on every mutation of the key
+
+	// Access stats are atomics so a read hit updates them without the shard write lock
+	accessCount  atomic.Uint64
+	lastAccessed atomic.Int64 // Unix nanoseconds
+
+	// Pinned items are never evicted under memory pressure. Atomic so the
+	// background evictor can check it without the shard lock.
+	pinned atomic.Bool
+}
+
+// AccessCount returns how many times the item has been read
+func (item *CacheItem) AccessCount() uint64 {
+	return item.accessCount.Load()
+}
+
+// LastAccessed returns when the item was last read, or written if never read
+func (item *CacheItem) LastAccessed() time.Time {
+	return time.Unix(0, item.lastAccessed.Load())
+}
+
+// Pinned reports whether the item is protected from eviction
+func (item *CacheItem) Pinned() bool {
+	return item.pinned.Load()
+}
+
+// touch records a read and returns the new access count
+func (item *CacheItem) touch() uint64 {
+	item.lastAccessed.Store(time.Now().UnixNano())
+	return item.accessCount.Add(1)
 }
 
 // GetValue deserializes and returns the actual value from allocated memory
@@ -50,9 +81,9 @@ func (item *CacheItem) IsStringType() bool {
 	return item.ValueType == "string" || item.ValueType == "[]uint8"
 }
 
-// IsExpired checks if the item has expired
-func (item *CacheItem) IsExpired() bool {
-	return !item.ExpiresAt.IsZero() && time.Now().After(item.ExpiresAt)
+// IsExpired checks if the item has expired at now
+func (item *CacheItem) IsExpired(now time.Time) bool {
+	return !item.ExpiresAt.IsZero() && now.After(item.ExpiresAt)
 }
 
 // BasicStoreConfig holds configuration for BasicStore
@@ -64,18 +95,126 @@ type BasicStoreConfig struct {
 	CleanupInterval   time.Duration
 	FilterConfig      *filter.FilterConfig           // Optional filter configuration (nil = no filter)
 	PersistenceConfig *persistence.PersistenceConfig // Optional persistence configuration (nil = no persistence)
+
+	// FilterSkipDelete leaves deleted keys in the filter. Deleting a key
+	// whose fingerprint is missing from the filter (its Add failed because
+	// the filter was full) can remove a colliding key's fingerprint instead,
+	// so that key's Gets wrongly miss. Skipping deletes rules this out at the
+	// cost of stale entries: deleted keys keep passing the filter and cost a
+	// map lookup, and the filter only empties on Clear, so size it for every
+	// distinct key written rather than the live key count.
+	FilterSkipDelete bool
+
+	// Lazy free: reclaim large allocations on a background goroutine when a
+	// key is deleted, overwritten, expired or evicted (like Redis lazyfree-*)
+	LazyFree          bool
+	LazyFreeThreshold uint64 // Minimum value size to free lazily (0 = DefaultLazyFreeThreshold)
+
+	// TTL jitter: extend each key's TTL by a random amount in [0, TTL*TTLJitter),
+	// capped at TTLJitterMax when set, so keys written together don't all expire together
+	TTLJitter    float64       // Fraction of the TTL (0 = disabled, max 1)
+	TTLJitterMax time.Duration // Upper bound on the added jitter (0 = no cap)
+
+	// Load shedding: once the memory pool hits panic pressure, reject writes
+	// with ErrOOM instead of evicting aggressively, until pressure falls below ShedLowWater
+	LoadShedding bool
+	ShedLowWater float64 // Pressure at which writes resume (0 = DefaultShedLowWater)
+
+	// Value interning: store identical values of at least InternMinSize
+	// bytes once, shared by every key holding them
+	InternValues  bool
+	InternMinSize uint64 // Smallest value shared (0 = DefaultInternMinSize)
+
+	// Prefetch: keep deserialized values of up to PrefetchKeys hot keys so Get
+	// skips decoding them. Prefetched values are shared between callers and
+	// must not be modified.
+	PrefetchKeys      int    // Maximum keys held (0 = disabled)
+	PrefetchMinAccess uint64 // Reads before a key is prefetched (0 = DefaultPrefetchMinAccess)
+
+	// Shards is the number of lock-striped partitions of the keyspace, rounded
+	// up to a power of two (0 = DefaultShards)
+	Shards int
+
+	// SlotIndex maintains a hash slot to keys index so KeysInSlot costs
+	// O(keys in the slot) rather than a scan of the store. Only worth its
+	// memory and write overhead in cluster mode.
+	SlotIndex bool
+
+	// SessionIdleTimeout expires a session as a whole: once none of its keys
+	// has been written or read for this long, all of them are deleted
+	// (0 = disabled)
+	SessionIdleTimeout time.Duration
+
+	// Clock is the time source of TTLs and expiry (nil = RealClock)
+	Clock Clock
+
+	// HitRateWindow is the span RecentHitRate covers, at one-second
+	// resolution (0 = DefaultHitRateWindow)
+	HitRateWindow time.Duration
+
+	// Key validation on write: MaxKeySize is the longest key accepted in
+	// bytes (0 = no limit); StrictKeys also rejects keys with a NUL byte
+	MaxKeySize int
+	StrictKeys bool
+
+	// Eviction marks, as memory pressure: the memory pool signals the
+	// background evictor from EvictionHighWater (warning), through
+	// EvictionCriticalWater, to EvictionPanicWater, and the evictor then
+	// reclaims down to EvictionLowWater, EvictionBatchSize keys per round.
+	// 0 = the DefaultEviction* value.
+	EvictionHighWater     float64
+	EvictionCriticalWater float64
+	EvictionPanicWater    float64
+	EvictionLowWater      float64
+	EvictionBatchSize     int
+
+	// Read-through and write-behind caching (nil = disabled). On a Get
+	// miss, Loader fetches the key from the backing store and the value is
+	// cached; concurrent misses of a key share one Loader call. Writer gets
+	// every Set, and a tombstone for every key deleted or expired (but not
+	// evicted, flushed or handed off to another node), batched: at most
+	// WriteBehindBatch keys per call (0 = DefaultWriteBehindBatch), at least
+	// every WriteBehindInterval (0 = DefaultWriteBehindInterval). At most
+	// WriteBehindMaxPending keys wait (0 = DefaultWriteBehindMaxPending);
+	// writes of other keys are dropped and counted, see WriteBehindDropped.
+	Loader                Loader
+	Writer                Writer
+	WriteBehindInterval   time.Duration
+	WriteBehindBatch      int
+	WriteBehindMaxPending int
+
+	// CoalesceMisses makes a Get that misses while a GetOrCompute of the
+	// same key is in flight wait for it and share its value, instead of
+	// missing (always the case with a Loader)
+	CoalesceMisses bool
+
+	// VerifyInterval runs Verify this often and logs any drift between the
+	// item and memory counters and the items held (0 = disabled). A
+	// debugging aid: each check walks the whole store.
+	VerifyInterval time.Duration
 }
 
+// DefaultLazyFreeThreshold is the value size above which lazy free kicks in
+const DefaultLazyFreeThreshold = 64 * 1024
+
+// lazyFreeQueueSize bounds the background free queue; when full, frees happen inline
+const lazyFreeQueueSize = 1024
+
 // BasicStoreStats holds statistics for the BasicStore
 type BasicStoreStats struct {
 	TotalItems    uint64
 	TotalMemory   uint64
 	HitCount      uint64
 	MissCount     uint64
-	EvictionCount uint64
+	EvictionCount uint64 // Keys removed by the store itself: ExpiredCount + MemoryEvictionCount
 	ErrorCount    uint64
 	CreatedAt     time.Time
 	LastAccess    time.Time
+
+	// Key removals by reason
+	ExpiredCount        uint64 // TTL or session idle expiry
+	MemoryEvictionCount uint64 // Evicted under memory pressure
+	ManualDeleteCount   uint64 // Deleted by a client (DEL, GETDEL, emptied list or set, ...)
 }
 
 // HitRate calculates the cache hit rate
@@ -87,20 +226,28 @@ func (s *BasicStoreStats) HitRate() float64 {
 	return float64(s.HitCount) / float64(total) * 100.0
 }
 
+// KeyspaceNotifier is called after a key is mutated. event is one of
+// "set", "del", "expire", "persist", "expired" or "evicted", or "flushall"
+// with an empty key after Clear.
+type KeyspaceNotifier func(event, key string)
+
 // BasicStore implements the Store interface with integrated MemoryPool, EvictionPolicy, and optional Filter
 type BasicStore struct {
 	config        BasicStoreConfig
+	clock         Clock       // Time source of TTLs and expiry
 	data          *ShardedMap // Sharded concurrent map (replaces items + allocatedPtrs + tombstones)
 	memPool       *MemoryPool
 	evictPolicy   cache.EvictionPolicy
 	filter        filter.ProbabilisticFilter    // Optional Cuckoo/Bloom filter for negative lookups
 	persistEngine persistence.PersistenceEngine // Optional persistence layer
 	mutex         sync.RWMutex                  // Protects stats only (not data — that's sharded)
-	stats         BasicStoreStats
+	stats         BasicStoreStats               // Hit/miss counts live in the shards
+	lastAccess    atomic.Int64                  // Unix nanoseconds of the last read or write
 	stopCleanup   chan bool
 
 	// Background eviction
 	evictSignal chan struct{} // Signal background evictor to run
+	evictStop   chan struct{} // Closed by Close to stop the background evictor
 	evictDone   chan struct{} // Closed when background evictor exits
 	closing     atomic.Bool   // Set to true during Close() to prevent sends on closed channels
 
@@ -108,6 +255,67 @@ type BasicStore struct {
 	aofChan      chan *persistence.LogEntry // Buffered channel for async AOF writes
 	aofDone      chan struct{}              // Closed when AOF goroutine exits
 	aofCloseOnce sync.Once                  // Ensures aofChan is closed exactly once
+
+	// Keyspace notifications (nil = disabled)
+	notifier atomic.Pointer[KeyspaceNotifier]
+
+	// Callbacks registered with Watch
+	watchers keyspaceWatchers
+
+	// Callbacks registered with OnExpire
+	expireCallbacks expireCallbacks
+
+	// Loader and GetOrCompute populates in flight, by key
+	loadMu  sync.Mutex
+	loading map[string]*loadCall
+
+	// Write-behind queue (nil when Writer is not set)
+	writeBehind *writeBehind
+
+	// Background lazy free (nil channels when LazyFree is disabled)
+	lazyFreeChan chan []byte   // Allocations waiting to be returned to the memory pool
+	lazyFreeStop chan struct{} // Closed to stop the lazy free goroutine
+	lazyFreeDone chan struct{} // Closed when the lazy free goroutine exits
+
+	// Load shedding state, set by the panic pressure handler when LoadShedding is enabled
+	shedding atomic.Bool
+
+	// Shares identical values between keys (nil = InternValues disabled)
+	interner *valueInterner
+
+	// Session expiry (nil channels when SessionIdleTimeout is disabled)
+	sessions    sync.Map      // Session ID -> *atomic.Int64 Unix nanoseconds of last activity
+	sessionStop chan struct{} // Closed to stop the session sweeper
+	sessionDone chan struct{} // Closed when the session sweeper exits
+
+	// Periodic accounting check (nil channels when VerifyInterval is disabled)
+	verifyStop chan struct{} // Closed to stop the accounting check
+	verifyDone chan struct{} // Closed when the accounting check exits
+
+	// Recent hit/miss counts for RecentHitRate
+	hitWindow *hitWindow
+
+	// Set while active expiry is paused (SetActiveExpire)
+	activeExpireOff atomic.Bool
+
+	// Set while a background filter rebuild is running
+	filterRebuilding atomic.Bool
+
+	// Source of CacheItem.Version; store-wide so a key's version keeps
+	// increasing even across delete and re-create
+	versionClock atomic.Uint64
+
+	// WATCH support: modification versions of watched keys
+	watchMu    sync.Mutex
+	watched    map[string]*watchedKey
+	watchCount atomic.Int64  // Number of watched keys, for a lock-free fast path on writes
+	watchClock atomic.Uint64 // Source of modification versions
+
+	// Serializes read-modify-write commands on typed values (lists)
+	typedMu sync.Mutex
+
+	// Deserialized values of hot keys (nil when prefetch is disabled)
+	prefetch *prefetchCache
 }
 
 // serializeValue converts interface{} values to []byte for storage in allocated memory
@@ -115,6 +323,8 @@ func serializeValue(value interface{}) ([]byte, string, error) {
 	valueType := reflect.TypeOf(value).String()
 
 	switch v := value.(type) {
+	case serializedValue:
+		return v.data, v.valueType, nil
 	case string:
 		return []byte(v), valueType, nil
 	case []byte:
@@ -144,19 +354,33 @@ func serializeValue(value interface{}) ([]byte, string, error) {
 		return data, valueType, nil
 	case float32:
 		data := make([]byte, 4)
-		binary.LittleEndian.PutUint32(data, *(*uint32)(unsafe.Pointer(&v)))
+		binary.LittleEndian.PutUint32(data, math.Float32bits(v))
 		return data, valueType, nil
 	case float64:
 		data := make([]byte, 8)
-		binary.LittleEndian.PutUint64(data, *(*uint64)(unsafe.Pointer(&v)))
+		binary.LittleEndian.PutUint64(data, math.Float64bits(v))
 		return data, valueType, nil
 	case bool:
 		if v {
 			return []byte{1}, valueType, nil
 		}
 		return []byte{0}, valueType, nil
+	case ListValue:
+		return encodeList(v), listValueType, nil
+	case SetValue:
+		return encodeSet(v), setValueType, nil
+	case []string:
+		return encodeStringSlice(v), valueType, nil
+	case map[string]string:
+		return encodeStringMap(v), valueType, nil
+	case map[string]interface{}:
+		data, err := encodeObject(v)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode value: %w", err)
+		}
+		return data, valueType, nil
 	default:
-		// Use JSON encoding for complex types (maps, slices, structs)
+		// Use JSON encoding for other complex types (maps, slices, structs)
 		// JSON roundtrips cleanly with interface{} unlike gob
 		data, err := json.Marshal(v)
 		if err != nil {
@@ -210,18 +434,48 @@ func deserializeValue(data []byte, valueType string) (interface{}, error) {
 			return nil, fmt.Errorf("insufficient data for float32 deserialization")
 		}
 		bits := binary.LittleEndian.Uint32(data)
-		return *(*float32)(unsafe.Pointer(&bits)), nil
+		return math.Float32frombits(bits), nil
 	case "float64":
 		if len(data) < 8 {
 			return nil, fmt.Errorf("insufficient data for float64 deserialization")
 		}
 		bits := binary.LittleEndian.Uint64(data)
-		return *(*float64)(unsafe.Pointer(&bits)), nil
+		return math.Float64frombits(bits), nil
 	case "bool":
 		if len(data) < 1 {
 			return nil, fmt.Errorf("insufficient data for bool deserialization")
 		}
 		return data[0] != 0, nil
+	case listValueType:
+		list, err := decodeList(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode list: %w", err)
+		}
+		return list, nil
+	case setValueType:
+		set, err := decodeSet(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode set: %w", err)
+		}
+		return set, nil
+	case stringSliceValueType:
+		v, err := decodeStringSlice(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode value: %w", err)
+		}
+		return v, nil
+	case stringMapValueType:
+		v, err := decodeStringMap(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode value: %w", err)
+		}
+		return v, nil
+	case objectValueType:
+		v, err := decodeObject(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode value: %w", err)
+		}
+		return v, nil
 	default:
 		// Use JSON decoding for complex types
 		var result interface{}
@@ -240,19 +494,35 @@ func NewBasicStore(config BasicStoreConfig) (*BasicStore, error) {
 	if config.MaxMemory == 0 {
 		return nil, fmt.Errorf("max memory must be greater than 0")
 	}
+	if err := resolveEvictionMarks(&config); err != nil {
+		return nil, err
+	}
 
 	// Create MemoryPool
 	memPool := NewMemoryPool(config.Name, int64(config.MaxMemory))
+	if err := memPool.SetPressureThresholds(config.EvictionHighWater, config.EvictionCriticalWater, config.EvictionPanicWater); err != nil {
+		return nil, fmt.Errorf("invalid eviction marks: %w", err)
+	}
+
+	clock := config.Clock
+	if clock == nil {
+		clock = RealClock
+	}
+	data := NewShardedMapN(config.Shards)
+	data.clock = clock
 
 	store := &BasicStore{
 		config:      config,
-		data:        NewShardedMap(),
+		clock:       clock,
+		data:        data,
 		memPool:     memPool,
 		stopCleanup: make(chan bool),
 		evictSignal: make(chan struct{}, 1),
+		evictStop:   make(chan struct{}),
 		evictDone:   make(chan struct{}),
 		aofChan:     make(chan *persistence.LogEntry, 10000),
 		aofDone:     make(chan struct{}),
+		loading:     make(map[string]*loadCall),
 		stats: BasicStoreStats{
 			CreatedAt: time.Now(),
 		},
@@ -282,11 +552,19 @@ func NewBasicStore(config BasicStoreConfig) (*BasicStore, error) {
 		store.persistEngine = persistEngine
 	}
 
-	// Set up memory pressure callbacks — signal background evictor
+	// Set up memory pressure callbacks — signal background evictor, or shed
+	// writes at panic pressure when load shedding is enabled
+	onPanic := func(usage float64) { store.signalEviction() }
+	if config.LoadShedding {
+		if store.config.ShedLowWater == 0 {
+			store.config.ShedLowWater = DefaultShedLowWater
+		}
+		onPanic = store.enterShedding
+	}
 	memPool.SetPressureHandlers(
 		func(usage float64) { store.signalEviction() }, // Warning
 		func(usage float64) { store.signalEviction() }, // Critical
-		func(usage float64) { store.signalEviction() }, // Panic
+		onPanic, // Panic
 	)
 
 	// Start background evictor goroutine
@@ -295,6 +573,55 @@ func NewBasicStore(config BasicStoreConfig) (*BasicStore, error) {
 	// Start background AOF goroutine
 	go store.backgroundAOFWriter()
 
+	// Start background lazy free goroutine
+	if config.LazyFree {
+		if store.config.LazyFreeThreshold == 0 {
+			store.config.LazyFreeThreshold = DefaultLazyFreeThreshold
+		}
+		store.lazyFreeChan = make(chan []byte, lazyFreeQueueSize)
+		store.lazyFreeStop = make(chan struct{})
+		store.lazyFreeDone = make(chan struct{})
+		go store.backgroundLazyFree()
+	}
+
+	if config.SlotIndex {
+		store.data.EnableSlotIndex()
+	}
+
+	if config.InternValues {
+		store.interner = newValueInterner(memPool, config.InternMinSize)
+	}
+
+	if config.PrefetchKeys > 0 {
+		store.prefetch = newPrefetchCache(config.PrefetchKeys, config.PrefetchMinAccess)
+	}
+
+	// Start session sweeper goroutine
+	if config.SessionIdleTimeout > 0 {
+		store.sessionStop = make(chan struct{})
+		store.sessionDone = make(chan struct{})
+		go store.sweepIdleSessions()
+	}
+
+	if config.Writer != nil {
+		store.writeBehind = newWriteBehind(config.Writer, config.WriteBehindInterval, config.WriteBehindBatch, config.WriteBehindMaxPending)
+	}
+
+	// Start the periodic memory accounting check
+	if config.VerifyInterval > 0 {
+		store.verifyStop = make(chan struct{})
+		store.verifyDone = make(chan struct{})
+		go store.verifyAccounting()
+	}
+
+	// Start sampling hit and miss counts for RecentHitRate
+	hitRateWindow := config.HitRateWindow
+	if hitRateWindow <= 0 {
+		hitRateWindow = DefaultHitRateWindow
+	}
+	store.hitWindow = newHitWindow(hitRateWindow)
+	go store.sampleHitWindow()
+
 	// Start cleanup goroutine for expired items
 	if config.CleanupInterval > 0 {
 		go store.cleanupExpiredItems()
@@ -303,6 +630,21 @@ func NewBasicStore(config BasicStoreConfig) (*BasicStore, error) {
 	return store, nil
 }
 
+// NoExpiry as the ttl of a write stores the key without an expiry, even if
+// the store has a DefaultTTL (a zero ttl means "use DefaultTTL"). Any
+// negative ttl does the same; NoExpiry is a whole second so it survives
+// replication, which sends TTLs in seconds.
+const NoExpiry = -time.Second
+
+// KeepTTL as the ttl of a write keeps the expiry the key already has, as
+// Redis's SET ... KEEPTTL; a key without an expiry stays without one. For a
+// missing key it means the same as a zero ttl.
+//
+// Any other write replaces the key's expiry with one from its own ttl, as
+// a plain Redis SET does: a zero ttl clears an existing expiry unless the
+// store has a DefaultTTL, which is then applied afresh.
+const KeepTTL = time.Duration(math.MinInt64)
+
 // SetWithContext adds or updates an item in the cache with correlation context
 func (s *BasicStore) SetWithContext(ctx context.Context, key string, value interface{}, sessionID string, ttl time.Duration) error {
 	return s.setWithContextInternal(ctx, key, value, sessionID, ttl, 0)
@@ -313,6 +655,13 @@ func (s *BasicStore) Set(key string, value interface{}, sessionID string, ttl ti
 	return s.setWithContextInternal(nil, key, value, sessionID, ttl, 0)
 }
 
+// SetPinned is like Set, but also pins the key so it is never evicted. Once
+// memory is taken up by pinned keys, writes fail with ErrOOM instead.
+func (s *BasicStore) SetPinned(key string, value interface{}, sessionID string, ttl time.Duration) error {
+	_, err := s.setIf(nil, key, value, sessionID, ttl, 0, true, nil)
+	return err
+}
+
 // SetWithTimestamp writes a value only if the Lamport timestamp is newer than the existing one.
 func (s *BasicStore) SetWithTimestamp(ctx context.Context, key string, value interface{}, sessionID string, ttl time.Duration, lamportTS uint64) (bool, error) {
 	if existing, ok := s.data.Get(key); ok && existing.LamportTimestamp >= lamportTS {
@@ -326,6 +675,139 @@ func (s *BasicStore) SetWithTimestamp(ctx context.Context, key string, value int
 	return true, nil
 }
 
+// CompareAndSet atomically replaces the value at key with value if its current
+// raw bytes equal expected. Missing or expired keys never match. Reports
+// whether the value was written.
+func (s *BasicStore) CompareAndSet(key string, expected, value []byte, ttl time.Duration) (bool, error) {
+	wrongType := false
+	ok, err := s.setIf(nil, key, value, "", ttl, 0, false, func(existing *CacheItem) bool {
+		if existing == nil || s.expired(existing) {
+			return false
+		}
+		if existing.Kind() != TypeString {
+			wrongType = true
+			return false
+		}
+		return bytes.Equal(existing.GetRawBytes(), expected)
+	})
+	if wrongType {
+		return false, ErrWrongType
+	}
+	return ok, err
+}
+
+// Version returns the modification version of key. Versions increase on
+// every write, including in-place TTL changes; ok is false if the key does
+// not exist.
+func (s *BasicStore) Version(key string) (uint64, bool) {
+	s.data.LockShard(key)
+	defer s.data.UnlockShard(key)
+	item, ok := s.data.getShard(key).items[key]
+	if !ok || s.expired(item) {
+		return 0, false
+	}
+	return item.Version, true
+}
+
+// TTL returns the remaining time to live of key, or -1 if it has no expiry.
+// ok is false if the key does not exist.
+func (s *BasicStore) TTL(key string) (time.Duration, bool) {
+	s.data.LockShard(key)
+	defer s.data.UnlockShard(key)
+	item, ok := s.data.getShard(key).items[key]
+	if !ok || s.expired(item) {
+		return 0, false
+	}
+	if item.ExpiresAt.IsZero() {
+		return -1, true
+	}
+	return item.ExpiresAt.Sub(s.now()), true
+}
+
+// Expire sets a new TTL on an existing key in place, keeping its value.
+// Reports whether the key exists.
+func (s *BasicStore) Expire(key string, ttl time.Duration) bool {
+	return s.updateExpiry(key, s.now().Add(ttl), "expire")
+}
+
+// Persist removes the TTL from an existing key. Reports whether the key
+// exists and had a TTL.
+func (s *BasicStore) Persist(key string) bool {
+	return s.updateExpiry(key, time.Time{}, "persist")
+}
+
+// updateExpiry changes a live key's expiry, logging the change to
+// persistence
+func (s *BasicStore) updateExpiry(key string, expiresAt time.Time, event string) bool {
+	if !s.setExpiry(key, expiresAt) {
+		return false
+	}
+
+	if s.persistEngine != nil {
+		now := s.now()
+		logEntry := &persistence.LogEntry{
+			Timestamp: now, // replay expires the key at Timestamp + TTL
+			Operation: "EXPIRE",
+			Key:       key,
+			TTL:       aofTTL(KeepTTL, expiresAt, now),
+		}
+		select {
+		case s.aofChan <- logEntry:
+		default:
+		}
+	}
+
+	s.touchKey(key)
+	s.invalidatePrefetch(key)
+	s.notify(event, key)
+	return true
+}
+
+// setExpiry changes a live key's expiry under its shard lock and bumps its
+// version. A zero expiresAt removes the expiry; it reports false if the key
+// had none.
+func (s *BasicStore) setExpiry(key string, expiresAt time.Time) bool {
+	s.data.LockShard(key)
+	defer s.data.UnlockShard(key)
+	item, ok := s.data.getShard(key).items[key]
+	if !ok || s.expired(item) || (expiresAt.IsZero() && item.ExpiresAt.IsZero()) {
+		return false
+	}
+	item.ExpiresAt = expiresAt
+	item.Version = s.versionClock.Add(1)
+	return true
+}
+
+// Pin protects a live key from eviction. Reports whether the key exists.
+func (s *BasicStore) Pin(key string) bool {
+	return s.setPinned(key, true)
+}
+
+// Unpin makes a pinned key evictable again. Reports whether the key exists.
+func (s *BasicStore) Unpin(key string) bool {
+	return s.setPinned(key, false)
+}
+
+// setPinned sets a live key's pinned flag under its shard lock
+func (s *BasicStore) setPinned(key string, pinned bool) bool {
+	s.data.LockShard(key)
+	defer s.data.UnlockShard(key)
+	item, ok := s.data.getShard(key).items[key]
+	if !ok || s.expired(item) {
+		return false
+	}
+	item.pinned.Store(pinned)
+	return true
+}
+
+// IsPinned reports whether key exists and is pinned
+func (s *BasicStore) IsPinned(key string) bool {
+	s.data.LockShard(key)
+	defer s.data.UnlockShard(key)
+	item, ok := s.data.getShard(key).items[key]
+	return ok && !s.expired(item) && item.Pinned()
+}
+
 // GetTimestamp returns the Lamport timestamp for a key, or 0 if not found.
 func (s *BasicStore) GetTimestamp(key string) uint64 {
 	if item, ok := s.data.Get(key); ok {
@@ -336,49 +818,98 @@ func (s *BasicStore) GetTimestamp(key string) uint64 {
 
 // setWithContextInternal is the internal implementation that accepts an optional context
 func (s *BasicStore) setWithContextInternal(ctx context.Context, key string, value interface{}, sessionID string, ttl time.Duration, lamportTS uint64) error {
+	_, err := s.setIf(ctx, key, value, sessionID, ttl, lamportTS, false, nil)
+	return err
+}
+
+// aofTTL is the TTL in seconds logged for a write with ttl that set expiresAt
+func aofTTL(ttl time.Duration, expiresAt, now time.Time) int64 {
+	if ttl != KeepTTL {
+		return int64(ttl.Seconds())
+	}
+	if expiresAt.IsZero() {
+		return int64(NoExpiry.Seconds())
+	}
+	return int64(math.Ceil(expiresAt.Sub(now).Seconds()))
+}
+
+// setIf writes the value only if cond, evaluated against the current item
+// (nil if the key is absent) under the shard lock, returns true. A nil cond
+// always writes. The key is pinned if pin is set or it was already pinned.
+// Reports whether the value was written.
+func (s *BasicStore) setIf(ctx context.Context, key string, value interface{}, sessionID string, ttl time.Duration, lamportTS uint64, pin bool, cond func(existing *CacheItem) bool) (bool, error) {
 	start := time.Now()
 	defer metrics.Global().RecordOp("set", start)
 
 	if key == "" {
 		s.incrementErrorCount()
-		return fmt.Errorf("key cannot be empty")
+		return false, fmt.Errorf("key cannot be empty")
+	}
+	if err := s.checkKey(key); err != nil {
+		s.incrementErrorCount()
+		return false, err
+	}
+
+	if s.checkShedding() {
+		s.incrementErrorCount()
+		return false, ErrOOM
 	}
 
 	// Serialize the value first to get actual memory requirements
 	serializedData, valueType, err := serializeValue(value)
 	if err != nil {
 		s.incrementErrorCount()
-		return fmt.Errorf("failed to serialize value: %w", err)
+		return false, fmt.Errorf("failed to serialize value: %w", err)
 	}
 
 	size := uint64(len(serializedData))
 
-	// Check if we can allocate memory (no global lock needed — memPool is atomic)
-	if s.memPool.AvailableSpace() < int64(size) {
+	// Check if we can allocate memory (no global lock needed — memPool is atomic).
+	// If eviction can't free enough, e.g. because only pinned keys are left,
+	// the write fails with OOM.
+	required := int64(size) + PerKeyOverhead
+	if s.memPool.AvailableSpace() < required {
 		s.signalEviction()
 		time.Sleep(500 * time.Microsecond)
-		if s.memPool.AvailableSpace() < int64(size) {
+		if s.memPool.AvailableSpace() < required {
 			s.incrementErrorCount()
-			return fmt.Errorf("insufficient memory: need %d bytes, available %d", size, s.memPool.AvailableSpace())
+			return false, fmt.Errorf("%w: need %d bytes, available %d", ErrOOM, required, s.memPool.AvailableSpace())
 		}
 	}
 
+	// Record session activity before the write, so a concurrent sweep of an
+	// idle session either sees it or keeps this key (written after the sweep
+	// started)
+	s.touchSession(sessionID)
+
 	// Allocate memory
-	allocatedMemory, err := s.memPool.Allocate(int64(size))
+	allocatedMemory, err := s.allocateValue(serializedData)
 	if err != nil {
 		s.incrementErrorCount()
-		return fmt.Errorf("failed to allocate memory: %w", err)
+		return false, fmt.Errorf("failed to allocate memory: %w", err)
 	}
-	copy(allocatedMemory, serializedData)
 
 	// Lock only the shard for this key
 	s.data.LockShard(key)
 	sh := s.data.getShard(key)
 
+	if cond != nil && !cond(sh.items[key]) {
+		s.data.UnlockShard(key)
+		s.freeValue(allocatedMemory)
+		return false, nil
+	}
+
 	// Handle existing item
-	if existingItem, exists := sh.items[key]; exists {
+	overwriting := false    // a live key is being overwritten
+	var oldExpiry time.Time // and this was its expiry
+	existingItem, existed := sh.items[key]
+	if existed {
+		pin = pin || existingItem.Pinned()
+		if !s.expired(existingItem) {
+			overwriting, oldExpiry = true, existingItem.ExpiresAt
+		}
 		if oldPtr, ptrExists := sh.allocatedPtrs[key]; ptrExists {
-			_ = s.memPool.Free(oldPtr)
+			s.freeAllocation(oldPtr)
 		}
 		oldEntry := s.itemToEntry(key, existingItem)
 		s.evictPolicy.OnDelete(oldEntry)
@@ -389,10 +920,24 @@ func (s *BasicStore) setWithContextInternal(ctx context.Context, key string, val
 	}
 
 	expiresAt := time.Time{}
+	if ttl == KeepTTL {
+		if overwriting {
+			expiresAt = oldExpiry
+		} else {
+			ttl = 0
+		}
+	}
 	if ttl > 0 {
-		expiresAt = time.Now().Add(ttl)
-	} else if s.config.DefaultTTL > 0 {
-		expiresAt = time.Now().Add(s.config.DefaultTTL)
+		expiresAt = s.now().Add(s.jitterTTL(ttl))
+	} else if ttl == 0 && s.config.DefaultTTL > 0 {
+		expiresAt = s.now().Add(s.jitterTTL(s.config.DefaultTTL))
+	}
+	if !oldExpiry.IsZero() {
+		if ttl == KeepTTL {
+			metrics.Global().IncCounter("hypercache_set_ttl_preserved_total")
+		} else if expiresAt.IsZero() {
+			metrics.Global().IncCounter("hypercache_set_ttl_cleared_total")
+		}
 	}
 
 	item := &CacheItem{
@@ -400,15 +945,17 @@ func (s *BasicStore) setWithContextInternal(ctx context.Context, key string, val
 		ValuePtr:         allocatedMemory,
 		ValueType:        valueType,
 		Size:             size,
-		CreatedAt:        time.Now(),
+		CreatedAt:        s.now(),
 		ExpiresAt:        expiresAt,
 		SessionID:        sessionID,
-		AccessCount:      0,
-		LastAccessed:     time.Now(),
 		LamportTimestamp: lamportTS,
+		Version:          s.versionClock.Add(1),
 	}
+	item.lastAccessed.Store(item.CreatedAt.UnixNano())
+	item.pinned.Store(pin)
 
 	sh.items[key] = item
+	s.data.indexKey(key)
 	sh.allocatedPtrs[key] = allocatedMemory
 	delete(sh.tombstones, key)
 	s.data.UnlockShard(key)
@@ -416,23 +963,26 @@ func (s *BasicStore) setWithContextInternal(ctx context.Context, key string, val
 	s.updateStats(func() {
 		s.stats.TotalItems++
 		s.stats.TotalMemory += size
-		s.stats.LastAccess = time.Now()
 	})
+	s.touchLastAccess()
 
 	entry := s.itemToEntry(key, item)
 	s.evictPolicy.OnInsert(entry)
 
-	if s.filter != nil {
+	// A key already held, even expired, keeps the fingerprint added when it
+	// was first written; adding another would leave a stale one behind
+	// after the key is deleted
+	if s.filter != nil && !existed {
 		_ = s.filter.Add([]byte(key))
 	}
 
 	if s.persistEngine != nil {
 		logEntry := &persistence.LogEntry{
-			Timestamp: time.Now(),
+			Timestamp: s.now(), // replay expires the key at Timestamp + TTL
 			Operation: "SET",
 			Key:       key,
 			Value:     serializedData,
-			TTL:       int64(ttl.Seconds()),
+			TTL:       aofTTL(ttl, expiresAt, s.now()),
 			SessionID: sessionID,
 		}
 		select {
@@ -441,7 +991,57 @@ func (s *BasicStore) setWithContextInternal(ctx context.Context, key string, val
 		}
 	}
 
-	return nil
+	if s.writeBehind != nil && (ctx == nil || ctx.Value(loaderWriteKey{}) == nil) {
+		if sv, ok := value.(serializedValue); ok {
+			value, _ = deserializeValue(sv.data, sv.valueType)
+		}
+		s.writeBehind.add(WriteBehind{Key: key, Value: value})
+	}
+
+	s.touchKey(key)
+	s.invalidatePrefetch(key)
+	s.notify("set", key)
+	return true, nil
+}
+
+// SetKeyspaceNotifier installs a callback invoked after every key mutation.
+// Pass nil to disable notifications.
+func (s *BasicStore) SetKeyspaceNotifier(fn KeyspaceNotifier) {
+	if fn == nil {
+		s.notifier.Store(nil)
+		return
+	}
+	s.notifier.Store(&fn)
+}
+
+// notify invokes the keyspace notifier if one is installed, and the watchers
+func (s *BasicStore) notify(event, key string) {
+	if fn := s.notifier.Load(); fn != nil {
+		(*fn)(event, key)
+	}
+	s.watchers.notify(event, key)
+}
+
+// invalidatePrefetch drops the prefetched value of a mutated key
+func (s *BasicStore) invalidatePrefetch(key string) {
+	if s.prefetch != nil {
+		s.prefetch.invalidate(key)
+	}
+}
+
+// jitterTTL extends ttl by a random amount within the configured jitter window
+func (s *BasicStore) jitterTTL(ttl time.Duration) time.Duration {
+	if s.config.TTLJitter <= 0 {
+		return ttl
+	}
+	window := time.Duration(float64(ttl) * s.config.TTLJitter)
+	if s.config.TTLJitterMax > 0 && window > s.config.TTLJitterMax {
+		window = s.config.TTLJitterMax
+	}
+	if window <= 0 {
+		return ttl
+	}
+	return ttl + rand.N(window)
 }
 
 // updateStats safely updates store stats under the stats mutex
@@ -464,47 +1064,124 @@ func (s *BasicStore) Get(key string) (interface{}, error) {
 
 // GetRawBytes retrieves the raw stored bytes for a key without deserialization.
 // Returns (bytes, valueType, error). For string/[]byte values this is zero-copy
-// and avoids the string(data) allocation that Get() performs.
+// and avoids the string(data) allocation that Get() performs. Containers with a
+// compact encoding are returned as JSON.
 // The RESP handler should use this instead of Get() for maximum throughput.
 func (s *BasicStore) GetRawBytes(key string) ([]byte, string, error) {
+	raw, valueType, _, err := s.getRawItem(key)
+	return raw, valueType, err
+}
+
+// ItemMeta is the metadata returned alongside a value by GetWithMeta
+type ItemMeta struct {
+	TTL         time.Duration // Remaining time to live, -1 if the key has no expiry
+	AccessCount uint64        // Reads of the key, including this one
+	CreatedAt   time.Time     // When the current value was written
+}
+
+// GetWithMeta is GetRawBytes that also returns the key's TTL, access count
+// and creation time, read from the same item as the value. Counts as a read
+// like GetRawBytes.
+func (s *BasicStore) GetWithMeta(key string) ([]byte, string, ItemMeta, error) {
+	raw, valueType, item, err := s.getRawItem(key)
+	if err != nil {
+		return nil, "", ItemMeta{}, err
+	}
+
+	// EXPIRE and PERSIST change ExpiresAt in place under the shard lock
+	s.data.LockShard(key)
+	expiresAt := item.ExpiresAt
+	s.data.UnlockShard(key)
+
+	meta := ItemMeta{TTL: -1, AccessCount: item.AccessCount(), CreatedAt: item.CreatedAt}
+	if !expiresAt.IsZero() {
+		meta.TTL = max(expiresAt.Sub(s.now()), 0)
+	}
+	return raw, valueType, meta, nil
+}
+
+// getRawItem implements GetRawBytes, also returning the item read
+func (s *BasicStore) getRawItem(key string) ([]byte, string, *CacheItem, error) {
 	start := time.Now()
 	defer metrics.Global().RecordOp("get", start)
 
 	if key == "" {
-		return nil, "", fmt.Errorf("key cannot be empty")
+		return nil, "", nil, fmt.Errorf("key cannot be empty")
 	}
 
 	if s.filter != nil {
 		if !s.filter.Contains([]byte(key)) {
-			s.incrementMissCount()
-			return nil, "", fmt.Errorf("key not found: %s", key)
+			s.incrementMissCount(key)
+			return nil, "", nil, fmt.Errorf("key not found: %s", key)
 		}
 	}
 
 	item, exists := s.data.Get(key)
 	if !exists {
-		s.incrementMissCount()
-		return nil, "", fmt.Errorf("key not found: %s", key)
+		s.filterFalsePositive()
+		s.incrementMissCount(key)
+		return nil, "", nil, fmt.Errorf("key not found: %s", key)
 	}
 
-	if item.IsExpired() {
-		_ = s.Delete(key)
-		s.incrementMissCount()
-		return nil, "", fmt.Errorf("key expired: %s", key)
+	if s.expired(item) {
+		s.reclaimExpired(key)
+		s.incrementMissCount(key)
+		return nil, "", nil, fmt.Errorf("key expired: %s", key)
 	}
 
-	// Update access stats
-	s.data.LockShard(key)
-	item.AccessCount++
-	item.LastAccessed = time.Now()
-	s.data.UnlockShard(key)
-	s.updateStats(func() { s.stats.LastAccess = time.Now() })
+	item.touch()
+	s.touchSession(item.SessionID)
+	s.touchLastAccess()
 
 	entry := s.itemToEntry(key, item)
 	s.evictPolicy.OnAccess(entry)
 
-	s.incrementHitCount()
-	return item.GetRawBytes(), item.ValueType, nil
+	s.incrementHitCount(key)
+	if isCompactContainer(item.ValueType) {
+		// Readers of raw bytes expect JSON for containers, not the compact encoding
+		value, err := item.GetValue()
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to deserialize value from memory: %w", err)
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to encode value: %w", err)
+		}
+		return data, item.ValueType, item, nil
+	}
+	return item.GetRawBytes(), item.ValueType, item, nil
+}
+
+// GetRaw returns a copy of the stored bytes for a key and its value type
+// without deserializing the value. ok is false if the key is missing or
+// expired. Unlike GetRawBytes the result is owned by the caller and may be
+// retained or modified.
+func (s *BasicStore) GetRaw(key string) ([]byte, string, bool) {
+	raw, valueType, err := s.GetRawBytes(key)
+	if err != nil {
+		return nil, "", false
+	}
+	if isCompactContainer(valueType) {
+		// Already freshly encoded as JSON
+		return raw, valueType, true
+	}
+	return bytes.Clone(raw), valueType, true
+}
+
+// Has reports whether a live (non-expired) key exists without deserializing
+// the value. Unlike Get it does not update access stats, hit/miss counters or
+// the eviction policy, so presence checks don't skew LRU/LFU ordering.
+// Expired keys are left for Get or the cleanup loop to reclaim.
+func (s *BasicStore) Has(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	if s.filter != nil && !s.filter.Contains([]byte(key)) {
+		return false
+	}
+
+	return s.data.ExistsLive(key)
 }
 
 // getInternal is the internal implementation that accepts an optional context
@@ -517,49 +1194,122 @@ func (s *BasicStore) getInternal(ctx context.Context, key string) (interface{},
 	// Check filter first for early negative lookup (if filter is enabled)
 	if s.filter != nil {
 		if !s.filter.Contains([]byte(key)) {
-			s.incrementMissCount()
-			return nil, fmt.Errorf("key not found: %s", key)
+			s.incrementMissCount(key)
+			return s.loadOnMiss(key, fmt.Errorf("key not found: %s", key))
 		}
 	}
 
 	item, exists := s.data.Get(key)
 
 	if !exists {
-		s.incrementMissCount()
-		return nil, fmt.Errorf("key not found: %s", key)
+		s.filterFalsePositive()
+		s.incrementMissCount(key)
+		return s.loadOnMiss(key, fmt.Errorf("key not found: %s", key))
 	}
 
 	// Check expiration
-	if item.IsExpired() {
-		_ = s.Delete(key)
-		s.incrementMissCount()
-		return nil, fmt.Errorf("key expired: %s", key)
+	if s.expired(item) {
+		s.reclaimExpired(key)
+		s.incrementMissCount(key)
+		return s.loadOnMiss(key, fmt.Errorf("key expired: %s", key))
 	}
 
-	// Update access statistics (non-critical, shard-local)
-	s.data.LockShard(key)
-	item.AccessCount++
-	item.LastAccessed = time.Now()
-	s.data.UnlockShard(key)
-	s.updateStats(func() { s.stats.LastAccess = time.Now() })
+	// Update access statistics lock-free; only the prefetch cache needs the
+	// version, which Expire/Persist may change under the shard write lock
+	accessCount := item.touch()
+	s.touchSession(item.SessionID)
+	var version uint64
+	if s.prefetch != nil {
+		s.data.RLockShard(key)
+		version = item.Version
+		s.data.RUnlockShard(key)
+	}
+	s.touchLastAccess()
 
 	// Update eviction policy
 	entry := s.itemToEntry(key, item)
 	s.evictPolicy.OnAccess(entry)
 
+	// Hot keys may already have a decoded copy
+	if s.prefetch != nil {
+		if value, ok := s.prefetch.get(key, version); ok {
+			s.incrementHitCount(key)
+			return value, nil
+		}
+	}
+
 	// Deserialize value from allocated memory - THIS IS THE MAGIC!
 	value, err := item.GetValue()
 	if err != nil {
 		s.incrementErrorCount()
 		return nil, fmt.Errorf("failed to deserialize value from memory: %w", err)
 	}
+	if s.prefetch != nil && prefetchable(item) {
+		s.prefetch.offer(key, version, accessCount, value)
+	}
 
-	s.incrementHitCount()
+	s.incrementHitCount(key)
 	return value, nil
 }
 
 // Delete removes an item from the cache
 func (s *BasicStore) Delete(key string) error {
+	return s.deleteWithEvent(key, "del")
+}
+
+// GetDel atomically returns the value at key and deletes it, so of several
+// concurrent callers exactly one gets the value (e.g. consuming a one-time
+// token). found is false if the key is missing or expired. Keys holding a
+// list or set are left alone and return ErrWrongType.
+func (s *BasicStore) GetDel(key string) (interface{}, bool, error) {
+	if key == "" {
+		s.incrementErrorCount()
+		return nil, false, fmt.Errorf("key cannot be empty")
+	}
+
+	var value interface{}
+	var decodeErr, wrongType error
+	err := s.deleteIf(key, "del", func(item *CacheItem) bool {
+		if s.expired(item) {
+			return false
+		}
+		if item.Kind() != TypeString {
+			wrongType = ErrWrongType
+			return false
+		}
+		// Decode under the shard lock, before the allocation is freed
+		value, decodeErr = item.GetValue()
+		return decodeErr == nil
+	})
+	switch {
+	case wrongType != nil:
+		return nil, false, wrongType
+	case decodeErr != nil:
+		s.incrementErrorCount()
+		return nil, false, fmt.Errorf("failed to deserialize value from memory: %w", decodeErr)
+	case err != nil:
+		s.incrementMissCount(key)
+		return nil, false, nil
+	}
+	s.incrementHitCount(key)
+	return value, true, nil
+}
+
+// deleteWithEvent removes an item and reports it to the keyspace notifier as event
+func (s *BasicStore) deleteWithEvent(key string, event string) error {
+	return s.deleteIf(key, event, nil)
+}
+
+// deleteIf is deleteWithEvent, but only removes the item if cond (nil =
+// always), evaluated under the shard lock, returns true
+func (s *BasicStore) deleteIf(key string, event string, cond func(item *CacheItem) bool) error {
+	return s.removeIf(key, event, cond, event != "evicted")
+}
+
+// removeIf is deleteIf, passing a tombstone to the Writer if writeBack is
+// set. Evictions and hand-offs to another node leave the backing store's
+// copy alone.
+func (s *BasicStore) removeIf(key string, event string, cond func(item *CacheItem) bool, writeBack bool) error {
 	start := time.Now()
 	defer metrics.Global().RecordOp("del", start)
 
@@ -568,14 +1318,21 @@ func (s *BasicStore) Delete(key string) error {
 		return fmt.Errorf("key cannot be empty")
 	}
 
-	item, allocPtr, existed := s.data.Delete(key)
+	var item *CacheItem
+	var allocPtr []byte
+	var existed bool
+	if cond != nil {
+		item, allocPtr, existed = s.data.DeleteIf(key, cond)
+	} else {
+		item, allocPtr, existed = s.data.Delete(key)
+	}
 	if !existed {
 		return fmt.Errorf("key not found: %s", key)
 	}
 
-	// Free memory
+	// Free memory (the key is already invisible; large values may be freed lazily)
 	if allocPtr != nil {
-		_ = s.memPool.Free(allocPtr)
+		s.freeAllocation(allocPtr)
 	}
 
 	// Remove from eviction policy
@@ -585,11 +1342,21 @@ func (s *BasicStore) Delete(key string) error {
 	s.updateStats(func() {
 		s.stats.TotalItems--
 		s.stats.TotalMemory -= item.Size
-		s.stats.LastAccess = time.Now()
+		switch event {
+		case "expired":
+			s.stats.ExpiredCount++
+			s.stats.EvictionCount++
+		case "evicted":
+			s.stats.MemoryEvictionCount++
+			s.stats.EvictionCount++
+		default:
+			s.stats.ManualDeleteCount++
+		}
 	})
+	s.touchLastAccess()
 
 	// Remove from filter if available
-	if s.filter != nil {
+	if s.filter != nil && !s.config.FilterSkipDelete {
 		s.filter.Delete([]byte(key))
 	}
 
@@ -608,13 +1375,23 @@ func (s *BasicStore) Delete(key string) error {
 		}
 	}
 
+	if writeBack && s.writeBehind != nil {
+		s.writeBehind.add(WriteBehind{Key: key, Deleted: true})
+	}
+
+	s.touchKey(key)
+	s.invalidatePrefetch(key)
+	s.notify(event, key)
+	if event == "expired" {
+		s.expireCallbacks.expire(key, item)
+	}
 	return nil
 }
 
 // signalEviction sends a non-blocking signal to the background evictor
 func (s *BasicStore) signalEviction() {
 	if s.closing.Load() {
-		return // Store is shutting down, don't send on closed channel
+		return // Store is shutting down
 	}
 	select {
 	case s.evictSignal <- struct{}{}:
@@ -628,56 +1405,127 @@ func (s *BasicStore) backgroundEvictor() {
 	defer close(s.evictDone)
 	for {
 		select {
-		case _, ok := <-s.evictSignal:
-			if !ok {
-				return
+		case <-s.evictSignal:
+			// Reclaim down to the low-water mark rather than just below the
+			// warning threshold, so writes don't push the store straight
+			// back into pressure
+			targetPressure := s.config.EvictionLowWater
+			if s.memPool.MemoryPressure() <= targetPressure {
+				continue
 			}
-			targetPressure := 0.75
-			for s.memPool.MemoryPressure() > targetPressure {
-				// Collect expired keys first
-				expired := s.data.CollectExpired(func(item *CacheItem) bool { return item.IsExpired() })
-				for _, key := range expired {
-					_ = s.Delete(key)
-				}
 
-				if s.memPool.MemoryPressure() <= targetPressure {
-					break
-				}
+			// Reclaim expired keys first, scanning once per signal rather
+			// than once per batch: the low-water mark spaces signals out, so
+			// the scan is paid once per reclaim instead of once per key
+			now := s.now()
+			expired := s.data.CollectExpired(func(item *CacheItem) bool { return item.IsExpired(now) })
+			for _, key := range expired {
+				_ = s.deleteWithEvent(key, "expired")
+			}
 
-				// Probabilistic eviction sampling (Redis-style):
-				// Sample 5 random keys and evict the least-recently-accessed one.
-				// This is O(1) per round instead of O(n) linked-list walk.
+			for s.memPool.MemoryPressure() > targetPressure {
+				// Probabilistic eviction sampling (Redis-style): per key, sample
+				// 5 random keys and evict the least-recently-accessed one.
+				// This is O(1) per key instead of O(n) linked-list walk.
 				evicted := uint64(0)
-				samples := s.data.SampleKeys(5)
-				if len(samples) == 0 {
-					break
-				}
-				var bestKey string
-				var bestTime time.Time
-				for _, key := range samples {
-					item, ok := s.data.Get(key)
-					if !ok {
-						continue
+				empty := false
+				for i := 0; i < s.config.EvictionBatchSize; i++ {
+					bestKey, sampled := s.sampleEvictionCandidate()
+					if !sampled {
+						empty = true
+						break
 					}
-					if bestKey == "" || item.LastAccessed.Before(bestTime) {
-						bestKey = key
-						bestTime = item.LastAccessed
+					if bestKey != "" {
+						_ = s.deleteWithEvent(bestKey, "evicted")
+						evicted++
+					}
+					if s.memPool.MemoryPressure() <= targetPressure {
+						break
 					}
 				}
-				if bestKey != "" {
-					_ = s.Delete(bestKey)
-					evicted++
-				}
-				if evicted == 0 && len(expired) == 0 {
+				if empty || evicted == 0 {
 					break
 				}
 			}
-		case <-s.stopCleanup:
+		case <-s.evictStop:
 			return
 		}
 	}
 }
 
+// evictionSampleSize is how many keys the background evictor samples per round
+const evictionSampleSize = 5
+
+// maxPinnedResamples bounds how often the evictor resamples when a sample
+// holds only pinned keys before concluding nothing is left to evict
+const maxPinnedResamples = 8
+
+// sampleEvictionCandidate samples keys and returns the least-recently-accessed
+// one that isn't pinned. Pinned keys are never returned; if samples hold only
+// pinned keys it resamples a bounded number of times. sampled is false when
+// the store is empty.
+func (s *BasicStore) sampleEvictionCandidate() (key string, sampled bool) {
+	for attempt := 0; attempt <= maxPinnedResamples; attempt++ {
+		samples := s.data.SampleKeys(evictionSampleSize)
+		if len(samples) == 0 {
+			return "", false
+		}
+		var bestTime time.Time
+		for _, sample := range samples {
+			item, ok := s.data.Get(sample)
+			if !ok || item.Pinned() {
+				continue
+			}
+			if lastAccessed := item.LastAccessed(); key == "" || lastAccessed.Before(bestTime) {
+				key = sample
+				bestTime = lastAccessed
+			}
+		}
+		if key != "" {
+			return key, true
+		}
+	}
+	return "", true
+}
+
+// freeAllocation returns an allocation to the memory pool. With LazyFree
+// enabled, allocations at or above LazyFreeThreshold are queued for the
+// background goroutine; if the queue is full they are freed inline.
+func (s *BasicStore) freeAllocation(ptr []byte) {
+	if s.interner != nil && s.interner.release(ptr) {
+		return
+	}
+	if s.lazyFreeChan != nil && uint64(len(ptr)) >= s.config.LazyFreeThreshold {
+		select {
+		case s.lazyFreeChan <- ptr:
+			return
+		default:
+		}
+	}
+	_ = s.memPool.Free(ptr)
+}
+
+// backgroundLazyFree returns queued allocations to the memory pool off the request path
+func (s *BasicStore) backgroundLazyFree() {
+	defer close(s.lazyFreeDone)
+	for {
+		select {
+		case ptr := <-s.lazyFreeChan:
+			_ = s.memPool.Free(ptr)
+		case <-s.lazyFreeStop:
+			// Drain whatever is still queued before exiting
+			for {
+				select {
+				case ptr := <-s.lazyFreeChan:
+					_ = s.memPool.Free(ptr)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
 // backgroundAOFWriter drains the AOF channel and writes entries to persistence
 func (s *BasicStore) backgroundAOFWriter() {
 	defer close(s.aofDone)
@@ -696,23 +1544,29 @@ func (s *BasicStore) Clear() error {
 	// (we're clearing everything, no need to maintain eviction order)
 	s.data.RangeAll(func(key string, item *CacheItem) bool {
 		if ptr, ok := s.data.GetAllocatedPtr(key); ok {
-			_ = s.memPool.Free(ptr)
+			s.freeValue(ptr)
 		}
 		return true
 	})
 
 	s.data.Clear()
+	s.sessions.Clear()
+	s.touchAllKeys()
+	if s.prefetch != nil {
+		s.prefetch.clear()
+	}
 
 	s.mutex.Lock()
 	s.stats.TotalItems = 0
 	s.stats.TotalMemory = 0
-	s.stats.LastAccess = time.Now()
 	s.mutex.Unlock()
+	s.touchLastAccess()
 
 	if s.filter != nil {
 		_ = s.filter.Clear()
 	}
 
+	s.notify("flushall", "")
 	return nil
 }
 
@@ -721,6 +1575,107 @@ func (s *BasicStore) Size() uint64 {
 	return uint64(s.data.Size())
 }
 
+// ActiveSize returns the number of non-expired items. Size counts items whose
+// TTL has lapsed but that haven't been swept yet; ActiveSize does not.
+func (s *BasicStore) ActiveSize() uint64 {
+	return uint64(s.data.LiveSize())
+}
+
+// Keys returns the names of all non-expired keys, in no particular order
+func (s *BasicStore) Keys() []string {
+	keys := make([]string, 0, s.data.LiveSize())
+	now := s.now()
+	s.data.RangeAll(func(key string, item *CacheItem) bool {
+		if !item.IsExpired(now) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// MemoryUsage returns the approximate bytes a key consumes: the stored value,
+// the key name and the per-entry overhead charged by the memory pool.
+// Returns false if the key doesn't exist or has expired.
+func (s *BasicStore) MemoryUsage(key string) (uint64, bool) {
+	item, exists := s.data.Get(key)
+	if !exists || s.expired(item) {
+		return 0, false
+	}
+	return item.Size + uint64(len(key)) + PerKeyOverhead, true
+}
+
+// DefaultTopKeysSampleSize is the number of keys TopKeys inspects when no sample size is given
+const DefaultTopKeysSampleSize = 10000
+
+// KeySizeInfo describes a key reported by TopKeys
+type KeySizeInfo struct {
+	Key  string
+	Size uint64
+	TTL  time.Duration // 0 = no expiry
+}
+
+// keySizeHeap is a min-heap on Size so the smallest of the current top-n is evicted first
+type keySizeHeap []KeySizeInfo
+
+func (h keySizeHeap) Len() int            { return len(h) }
+func (h keySizeHeap) Less(i, j int) bool  { return h[i].Size < h[j].Size }
+func (h keySizeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *keySizeHeap) Push(x interface{}) { *h = append(*h, x.(KeySizeInfo)) }
+func (h *keySizeHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// TopKeys returns up to n of the largest live keys by value size, largest first.
+// It inspects a random sample of sampleSize keys (sampleSize <= 0 scans every key)
+// and keeps only a bounded min-heap of n entries, so memory stays O(n).
+func (s *BasicStore) TopKeys(n int, sampleSize int) []KeySizeInfo {
+	if n <= 0 {
+		return nil
+	}
+
+	h := make(keySizeHeap, 0, n)
+	now := s.now()
+	consider := func(key string, item *CacheItem) {
+		if item.IsExpired(now) {
+			return
+		}
+		if len(h) == n && item.Size <= h[0].Size {
+			return
+		}
+		info := KeySizeInfo{Key: key, Size: item.Size}
+		if !item.ExpiresAt.IsZero() {
+			info.TTL = item.ExpiresAt.Sub(now)
+		}
+		if len(h) == n {
+			heap.Pop(&h)
+		}
+		heap.Push(&h, info)
+	}
+
+	if sampleSize <= 0 {
+		s.data.RangeAll(func(key string, item *CacheItem) bool {
+			consider(key, item)
+			return true
+		})
+	} else {
+		for _, key := range s.data.SampleKeys(sampleSize) {
+			if item, ok := s.data.Get(key); ok {
+				consider(key, item)
+			}
+		}
+	}
+
+	result := make([]KeySizeInfo, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(&h).(KeySizeInfo)
+	}
+	return result
+}
+
 // Memory returns the total memory usage
 func (s *BasicStore) Memory() uint64 {
 	s.mutex.RLock()
@@ -731,8 +1686,14 @@ func (s *BasicStore) Memory() uint64 {
 // Stats returns cache statistics
 func (s *BasicStore) Stats() BasicStoreStats {
 	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-	return s.stats
+	stats := s.stats
+	s.mutex.RUnlock()
+
+	stats.HitCount, stats.MissCount = s.data.ReadCounts()
+	if ns := s.lastAccess.Load(); ns != 0 {
+		stats.LastAccess = time.Unix(0, ns)
+	}
+	return stats
 }
 
 // GetMemoryPoolStats returns memory pool statistics for metrics.
@@ -740,7 +1701,25 @@ func (s *BasicStore) GetMemoryPoolStats() map[string]interface{} {
 	if s.memPool == nil {
 		return nil
 	}
-	return s.memPool.GetStats()
+	stats := s.memPool.GetStats()
+	stats["load_shedding"] = s.IsShedding()
+	return stats
+}
+
+// MaxMemory returns the store's current memory limit in bytes
+func (s *BasicStore) MaxMemory() uint64 {
+	return uint64(s.memPool.MaxSize())
+}
+
+// DefaultTTL returns the TTL given to writes without one (0 = no expiry)
+func (s *BasicStore) DefaultTTL() time.Duration {
+	return s.config.DefaultTTL
+}
+
+// SetMaxMemory changes the store's memory limit. It fails rather than evict
+// if the store already uses more than maxMemory.
+func (s *BasicStore) SetMaxMemory(maxMemory uint64) error {
+	return s.memPool.Resize(int64(maxMemory))
 }
 
 // FilterStats returns filter statistics if filter is enabled
@@ -751,6 +1730,37 @@ func (s *BasicStore) FilterStats() *filter.FilterStats {
 	return s.filter.GetStats()
 }
 
+// filterFalsePositive reports a key the filter passed but the store doesn't
+// hold. Once the measured false-positive rate drifts well above the target,
+// a Cuckoo filter with auto-resize enabled is rebuilt in the background with
+// larger fingerprints.
+func (s *BasicStore) filterFalsePositive() {
+	if s.filter == nil {
+		return
+	}
+	s.filter.RecordFalsePositive()
+
+	cf, ok := s.filter.(*filter.CuckooFilter)
+	if !ok {
+		return
+	}
+	size, needed := cf.NeedsLargerFingerprint()
+	if !needed || !s.filterRebuilding.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.filterRebuilding.Store(false)
+		// Keys pre-populated through FilterAdd are dropped; they are
+		// re-added when the key itself arrives
+		cf.Rebuild(size, func(add func(key []byte)) {
+			s.data.RangeAll(func(key string, _ *CacheItem) bool {
+				add([]byte(key))
+				return true
+			})
+		})
+	}()
+}
+
 // FilterContains checks if the cuckoo filter thinks a key might exist (probabilistic)
 func (s *BasicStore) FilterContains(key string) bool {
 	if s.filter == nil {
@@ -776,17 +1786,21 @@ func (s *BasicStore) IsTombstoned(key string) bool {
 
 // Close shuts down the store and cleans up resources
 func (s *BasicStore) Close() error {
-	// Mark as closing to prevent signalEviction from sending on closed channel
+	// Mark as closing so signalEviction stops waking the evictor
 	s.closing.Store(true)
+	if s.shedding.CompareAndSwap(true, false) {
+		metrics.Global().SetGauge("hypercache_memory_shedding_stores", sheddingStores.Add(-1))
+	}
 
-	// Stop cleanup goroutine and background evictor
+	// Stop cleanup goroutine
 	select {
 	case s.stopCleanup <- true:
 	default:
 	}
 
-	// Close eviction signal channel to stop background evictor
-	close(s.evictSignal)
+	// Stop the background evictor. evictSignal stays open: memory pressure
+	// callbacks run on their own goroutines and may still signal it.
+	close(s.evictStop)
 	<-s.evictDone
 
 	// Close AOF channel and wait for background writer to drain all entries
@@ -798,41 +1812,121 @@ func (s *BasicStore) Close() error {
 		_ = s.persistEngine.Flush()
 	}
 
+	if s.sessionStop != nil {
+		close(s.sessionStop)
+		<-s.sessionDone
+	}
+
+	if s.verifyStop != nil {
+		close(s.verifyStop)
+		<-s.verifyDone
+	}
+
+	s.expireCallbacks.close()
+
+	// Hand the last writes to the Writer
+	if s.writeBehind != nil {
+		s.writeBehind.close()
+	}
+
+	close(s.hitWindow.stop)
+	<-s.hitWindow.stopped
+
+	// Stop lazy free after draining pending frees
+	if s.lazyFreeStop != nil {
+		close(s.lazyFreeStop)
+		<-s.lazyFreeDone
+	}
+
 	// Clear all items
 	_ = s.Clear()
 
 	return nil
 }
 
-// cleanupExpiredItems runs periodic cleanup of expired items
+// Active expiration tuning (modelled on Redis activeExpireCycle)
+const (
+	activeExpireSampleSize   = 20                     // keys sampled per round
+	activeExpireRepeatRatio  = 0.25                   // sample again while more than this fraction was expired
+	activeExpireMaxRounds    = 16                     // hard cap on rounds per cycle
+	activeExpireTimeBudget   = 25 * time.Millisecond  // soft cap on time spent per cycle
+	activeExpireFastInterval = 100 * time.Millisecond // next cycle delay while expired keys remain plentiful
+)
+
+// cleanupExpiredItems runs periodic active expiration. Instead of scanning the
+// whole map it samples a bounded number of keys per cycle; while the sample
+// keeps turning up expired keys it runs again quickly, otherwise it backs off
+// to the configured CleanupInterval.
 func (s *BasicStore) cleanupExpiredItems() {
-	ticker := time.NewTicker(s.config.CleanupInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(s.config.CleanupInterval)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			expired := s.data.CollectExpired(func(item *CacheItem) bool { return item.IsExpired() })
-			for _, key := range expired {
-				_ = s.Delete(key)
+		case <-timer.C:
+			more := false
+			if !s.activeExpireOff.Load() {
+				_, _, more = s.activeExpireCycle()
 			}
+			next := s.config.CleanupInterval
+			if more && activeExpireFastInterval < next {
+				next = activeExpireFastInterval
+			}
+			timer.Reset(next)
 		case <-s.stopCleanup:
 			return
 		}
 	}
 }
 
+// activeExpireCycle samples random keys and deletes the expired ones, repeating
+// while the expired fraction stays above activeExpireRepeatRatio. It returns
+// the number of keys sampled and expired, and whether the cycle stopped on its
+// round/time budget while expired keys were still being found.
+func (s *BasicStore) activeExpireCycle() (sampled int, expired int, more bool) {
+	start := time.Now()
+	for round := 0; round < activeExpireMaxRounds; round++ {
+		keys := s.data.SampleKeys(activeExpireSampleSize)
+		if len(keys) == 0 {
+			return sampled, expired, false
+		}
+
+		roundExpired := 0
+		for _, key := range keys {
+			item, ok := s.data.Get(key)
+			if ok && s.expired(item) {
+				if s.deleteWithEvent(key, "expired") == nil {
+					roundExpired++
+				}
+			}
+		}
+		sampled += len(keys)
+		expired += roundExpired
+
+		if float64(roundExpired) <= float64(len(keys))*activeExpireRepeatRatio {
+			return sampled, expired, false
+		}
+		if time.Since(start) > activeExpireTimeBudget {
+			break
+		}
+	}
+	return sampled, expired, true
+}
+
 // Helper methods for thread-safe statistics updates
-func (s *BasicStore) incrementHitCount() {
-	s.mutex.Lock()
-	s.stats.HitCount++
-	s.mutex.Unlock()
+// incrementHitCount counts a hit on key's shard, keeping reads off the stats mutex
+func (s *BasicStore) incrementHitCount(key string) {
+	s.data.RecordHit(key)
 }
 
-func (s *BasicStore) incrementMissCount() {
-	s.mutex.Lock()
-	s.stats.MissCount++
-	s.mutex.Unlock()
+// incrementMissCount counts a miss on key's shard
+func (s *BasicStore) incrementMissCount(key string) {
+	s.data.RecordMiss(key)
+}
+
+// touchLastAccess records the time of the latest read or write
+func (s *BasicStore) touchLastAccess() {
+	s.lastAccess.Store(time.Now().UnixNano())
 }
 
 func (s *BasicStore) incrementErrorCount() {
@@ -841,21 +1935,13 @@ func (s *BasicStore) incrementErrorCount() {
 	s.mutex.Unlock()
 }
 
-// itemToEntry converts a CacheItem to an Entry for the eviction policy
+// itemToEntry converts a CacheItem to an Entry for the eviction policy.
+// Policies only track keys, so the stored bytes are passed as-is rather than
+// decoding the value on every access.
 func (s *BasicStore) itemToEntry(key string, item *CacheItem) *cache.Entry {
-	// Get the actual value for the entry (used by eviction policy)
-	value, err := item.GetValue()
-	var valueBytes []byte
-	if err != nil {
-		// If deserialization fails, just use the raw bytes
-		valueBytes = item.ValuePtr[:item.Size]
-	} else {
-		valueBytes = []byte(fmt.Sprintf("%v", value))
-	}
-
 	return &cache.Entry{
 		Key:       []byte(key),
-		Value:     valueBytes,
+		Value:     item.ValuePtr,
 		TTL:       0, // TTL not used in this context
 		Version:   0, // Version not used in this context
 		Timestamp: item.CreatedAt.Unix(),
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -238,3 +239,40 @@ func BenchmarkBasicStoreWithoutFilter_GetMiss(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkCuckooFilter_ParallelChurn benchmarks concurrent Add/Delete pairs,
+// the filter traffic of a store under write-heavy key churn
+func BenchmarkCuckooFilter_ParallelChurn(b *testing.B) {
+	cf, err := filter.NewCuckooFilter(&filter.FilterConfig{
+		Name:                "bench-churn",
+		FilterType:          "cuckoo",
+		ExpectedItems:       1 << 20,
+		FalsePositiveRate:   0.001,
+		BucketSize:          4,
+		MaxEvictionAttempts: 500,
+	})
+	if err != nil {
+		b.Fatalf("Failed to create filter: %v", err)
+	}
+
+	// Half full, so inserts mostly find a free slot without evicting
+	for i := 0; i < 1<<19; i++ {
+		_ = cf.Add([]byte(fmt.Sprintf("base_%d", i)))
+	}
+
+	var seq atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id := seq.Add(1)
+		i := 0
+		for pb.Next() {
+			key := []byte(fmt.Sprintf("churn_%d_%d", id, i%1024))
+			if i&1024 == 0 {
+				_ = cf.Add(key)
+			} else {
+				cf.Delete(key)
+			}
+			i++
+		}
+	})
+}
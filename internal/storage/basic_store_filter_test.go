@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -370,3 +372,340 @@ func TestBasicStoreInvalidFilterType(t *testing.T) {
 		t.Fatalf("Expected non-empty error message")
 	}
 }
+
+// TestCuckooFilter_ConcurrentAddDelete checks that concurrent adds, deletes
+// and lookups, including eviction chains near capacity, never lose a key
+func TestCuckooFilter_ConcurrentAddDelete(t *testing.T) {
+	cf, err := filter.NewCuckooFilter(&filter.FilterConfig{
+		Name:                "concurrent",
+		FilterType:          "cuckoo",
+		ExpectedItems:       4096,
+		FalsePositiveRate:   0.001,
+		BucketSize:          4,
+		MaxEvictionAttempts: 500,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+
+	const workers, perWorker = 8, 400
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				kept := []byte(fmt.Sprintf("kept_%d_%d", w, i))
+				temp := []byte(fmt.Sprintf("temp_%d_%d", w, i))
+				if err := cf.Add(kept); err != nil {
+					t.Errorf("Add failed: %v", err)
+					return
+				}
+				_ = cf.Add(temp)
+				if !cf.Contains(kept) {
+					t.Errorf("Key %s missing right after Add", kept)
+				}
+				cf.Delete(temp)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 0; w < workers; w++ {
+		for i := 0; i < perWorker; i++ {
+			if key := fmt.Sprintf("kept_%d_%d", w, i); !cf.Contains([]byte(key)) {
+				t.Fatalf("Key %s lost after concurrent adds and deletes", key)
+			}
+		}
+	}
+	if size := cf.Size(); size != workers*perWorker {
+		t.Errorf("Size = %d, want %d", size, workers*perWorker)
+	}
+}
+
+// TestCuckooFilter_SharedFingerprintDelete checks that size tracks occupied
+// slots when two keys share a fingerprint and bucket, and never wraps
+func TestCuckooFilter_SharedFingerprintDelete(t *testing.T) {
+	cf, err := filter.NewCuckooFilter(&filter.FilterConfig{
+		Name:                "shared-fingerprint",
+		FilterType:          "cuckoo",
+		ExpectedItems:       16,
+		FalsePositiveRate:   0.01,
+		FingerprintSize:     8,
+		BucketSize:          4,
+		MaxEvictionAttempts: 100,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+
+	key1 := []byte("key1")
+	if err := cf.Add(key1); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// A false positive for key1's filter is a key with the same fingerprint
+	// in one of its buckets
+	var key2 []byte
+	for i := 0; i < 1000000 && key2 == nil; i++ {
+		if candidate := []byte(fmt.Sprintf("candidate-%d", i)); cf.Contains(candidate) {
+			key2 = candidate
+		}
+	}
+	if key2 == nil {
+		t.Fatal("Failed to find a key sharing key1's fingerprint")
+	}
+
+	// Both added: two slots
+	if err := cf.Add(key2); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if size := cf.Size(); size != 2 {
+		t.Fatalf("Size after two adds = %d, want 2", size)
+	}
+	if !cf.Delete(key2) || cf.Size() != 1 || !cf.Contains(key1) {
+		t.Fatalf("After deleting one of two shared keys: size %d, key1 present %v", cf.Size(), cf.Contains(key1))
+	}
+
+	// Deleting the absent key2 takes key1's slot; a further delete finds nothing
+	if !cf.Delete(key2) {
+		t.Fatal("Expected delete of a shared fingerprint to remove a slot")
+	}
+	if cf.Delete(key1) {
+		t.Error("Expected no slot left to delete")
+	}
+	if size := cf.Size(); size != 0 {
+		t.Errorf("Size = %d, want 0", size)
+	}
+	if lf := cf.LoadFactor(); lf != 0 {
+		t.Errorf("LoadFactor = %v, want 0", lf)
+	}
+
+	// Racing adds and deletes of one key never wrap size
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				_ = cf.Add(key1)
+				cf.Delete(key1)
+				if size := cf.Size(); size > 4 {
+					t.Errorf("Size wrapped to %d", size)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if size := cf.Size(); size != 0 {
+		t.Errorf("Size after balanced adds and deletes = %d, want 0", size)
+	}
+}
+
+// TestBasicStore_FilterSkipDelete shows how deleting a key whose fingerprint
+// never made it into a full filter removes a colliding key's fingerprint, and
+// that FilterSkipDelete avoids the resulting false negative
+func TestBasicStore_FilterSkipDelete(t *testing.T) {
+	for _, skipDelete := range []bool{false, true} {
+		t.Run(fmt.Sprintf("skip_delete=%v", skipDelete), func(t *testing.T) {
+			store, err := NewBasicStore(BasicStoreConfig{
+				Name:      "filter-skip-delete",
+				MaxMemory: 1024 * 1024,
+				FilterConfig: &filter.FilterConfig{
+					FilterType:          "cuckoo",
+					ExpectedItems:       4, // 2 buckets, full at 6 keys
+					FalsePositiveRate:   0.01,
+					FingerprintSize:     8,
+					BucketSize:          4,
+					MaxEvictionAttempts: 10,
+				},
+				FilterSkipDelete: skipDelete,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			defer store.Close()
+
+			// Fill the filter
+			for i := 0; store.FilterStats().Size < store.FilterStats().Capacity; i++ {
+				if err := store.Set(fmt.Sprintf("key-%d", i), "v", "", 0); err != nil {
+					t.Fatalf("Set failed: %v", err)
+				}
+			}
+			if rate := store.FilterStats().CollisionRate; rate <= 0 {
+				t.Errorf("Expected a positive collision rate on a full filter, got %v", rate)
+			}
+
+			// A key sharing a stored key's fingerprint; its own Add fails
+			var colliding string
+			for i := 0; colliding == ""; i++ {
+				if key := fmt.Sprintf("other-%d", i); store.FilterContains(key) {
+					colliding = key
+				}
+			}
+			if err := store.Set(colliding, "v", "", 0); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if err := store.Delete(colliding); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+
+			// Count stored keys the filter now wrongly rejects
+			falseNegatives := 0
+			for i := 0; i < int(store.FilterStats().Capacity); i++ {
+				if _, err := store.Get(fmt.Sprintf("key-%d", i)); err != nil {
+					falseNegatives++
+				}
+			}
+			if skipDelete && falseNegatives != 0 {
+				t.Errorf("Expected no false negatives with FilterSkipDelete, got %d", falseNegatives)
+			}
+			if !skipDelete && falseNegatives != 1 {
+				t.Errorf("Expected the colliding key's delete to cause 1 false negative, got %d", falseNegatives)
+			}
+		})
+	}
+}
+
+// newFPRTestStore returns a store holding n keys behind a Cuckoo filter
+func newFPRTestStore(t *testing.T, n int, fingerprintSize uint8, autoResize bool) *BasicStore {
+	t.Helper()
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "filter-fpr",
+		MaxMemory: 64 * 1024 * 1024,
+		FilterConfig: &filter.FilterConfig{
+			FilterType:          "cuckoo",
+			ExpectedItems:       uint64(n),
+			FalsePositiveRate:   0.01,
+			FingerprintSize:     fingerprintSize,
+			BucketSize:          4,
+			MaxEvictionAttempts: 500,
+			EnableAutoResize:    autoResize,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		if err := store.Set(fmt.Sprintf("key-%d", i), "v", "", 0); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	return store
+}
+
+// TestBasicStore_FilterMeasuredFPR checks the measured false-positive rate
+// over a large keyspace against the configured target
+func TestBasicStore_FilterMeasuredFPR(t *testing.T) {
+	store := newFPRTestStore(t, 20000, 0, false)
+	defer store.Close()
+
+	for i := 0; i < 200000; i++ {
+		_, _ = store.Get(fmt.Sprintf("absent-%d", i))
+	}
+
+	stats := store.FilterStats()
+	if stats.FalsePositives == 0 {
+		t.Fatalf("Expected some false positives over 200000 absent lookups")
+	}
+	if stats.MeasuredFPR > 2*0.01 {
+		t.Errorf("Measured FPR %.4f is more than twice the 0.01 target", stats.MeasuredFPR)
+	}
+	t.Logf("fingerprint=%d bits measured FPR=%.4f theoretical=%.4f",
+		stats.FingerprintSize, stats.MeasuredFPR, stats.FalsePositiveRate)
+}
+
+// TestBasicStore_FilterFingerprintRebuild checks that a filter configured
+// with too small fingerprints is rebuilt with larger ones once its measured
+// false-positive rate drifts above the target, without losing keys
+func TestBasicStore_FilterFingerprintRebuild(t *testing.T) {
+	const n = 20000
+	store := newFPRTestStore(t, n, 4, true)
+	defer store.Close()
+
+	// Look up absent keys until the rebuild has run
+	deadline := time.Now().Add(10 * time.Second)
+	var before float64
+	for i := 0; store.FilterStats().ResizeOperations == 0; i++ {
+		if time.Now().After(deadline) {
+			t.Fatalf("Filter was not rebuilt; stats %+v", store.FilterStats())
+		}
+		if i%1000 == 0 {
+			before = store.FilterStats().MeasuredFPR
+		}
+		_, _ = store.Get(fmt.Sprintf("absent-%d", i))
+	}
+	for store.filterRebuilding.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := store.FilterStats()
+	if stats.FingerprintSize <= 4 {
+		t.Fatalf("Expected a larger fingerprint after rebuild, got %d bits", stats.FingerprintSize)
+	}
+	if stats.Size != n {
+		t.Errorf("Expected %d keys in the rebuilt filter, got %d", n, stats.Size)
+	}
+	for i := 0; i < n; i++ {
+		if !store.FilterContains(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("Rebuilt filter rejects stored key key-%d", i)
+		}
+	}
+
+	for i := 0; i < 100000; i++ {
+		_, _ = store.Get(fmt.Sprintf("absent-again-%d", i))
+	}
+	after := store.FilterStats().MeasuredFPR
+	if after >= before || after > 2*0.01 {
+		t.Errorf("Expected measured FPR to drop from %.4f to within twice the target, got %.4f", before, after)
+	}
+	t.Logf("fingerprint 4 -> %d bits, measured FPR %.4f -> %.4f", stats.FingerprintSize, before, after)
+}
+
+func TestBasicStore_FilterOverwriteAddsOnce(t *testing.T) {
+	clock := NewMockClock(time.Now())
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "filter-overwrite",
+		MaxMemory: 1024 * 1024,
+		Clock:     clock,
+		FilterConfig: &filter.FilterConfig{
+			FilterType:          "cuckoo",
+			ExpectedItems:       1000,
+			FalsePositiveRate:   0.001,
+			FingerprintSize:     12,
+			BucketSize:          4,
+			MaxEvictionAttempts: 500,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	store.SetActiveExpire(false)
+
+	// Overwrites of a live key, then of the same key once expired but
+	// still held, keep one fingerprint
+	for i := 0; i < 20; i++ {
+		if err := store.Set("key", fmt.Sprintf("v%d", i), "", time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	clock.Advance(2 * time.Minute)
+	if err := store.Set("key", "fresh", "", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if size := store.FilterStats().Size; size != 1 {
+		t.Errorf("Filter size after overwrites = %d, want 1", size)
+	}
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if size := store.FilterStats().Size; size != 0 {
+		t.Errorf("Filter size after delete = %d, want 0", size)
+	}
+	if store.FilterContains("key") {
+		t.Error("Filter still contains a deleted key")
+	}
+}
@@ -157,3 +157,156 @@ func TestBasicStore_PersistenceDisabled(t *testing.T) {
 
 	t.Logf("Persistence disabled test completed successfully")
 }
+
+func TestBasicStore_DebugReload(t *testing.T) {
+	persistConfig := persistence.DefaultPersistenceConfig()
+	persistConfig.Enabled = true
+	persistConfig.DataDirectory = t.TempDir()
+
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:              "test-debug-reload",
+		MaxMemory:         1024 * 1024,
+		CleanupInterval:   time.Minute,
+		PersistenceConfig: &persistConfig,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	if err := store.StartPersistence(context.Background()); err != nil {
+		t.Fatalf("Failed to start persistence: %v", err)
+	}
+	defer store.StopPersistence()
+
+	if _, err := store.DebugReload(); err != nil {
+		t.Fatalf("DebugReload of an empty store: %v", err)
+	}
+
+	mustSet := func(key string, value interface{}, sessionID string, ttl time.Duration) {
+		t.Helper()
+		if err := store.Set(key, value, sessionID, ttl); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+	mustSet("string", "hello", "", 0)
+	mustSet("bytes", []byte{0, 1, 2, 0xff}, "", 0)
+	mustSet("int", 42, "", 0)
+	mustSet("float", 3.5, "", 0)
+	mustSet("ttl", "expiring", "", time.Hour)
+	mustSet("session", "owned", "user-1", 0)
+	if _, err := store.ListPush("list", false, "a", "b", "c"); err != nil {
+		t.Fatalf("ListPush: %v", err)
+	}
+	if _, err := store.SetAdd("set", "x", "y"); err != nil {
+		t.Fatalf("SetAdd: %v", err)
+	}
+
+	before := make(map[string]SnapshotItem)
+	for _, item := range store.data.SnapshotRawData() {
+		before[item.Key] = item
+	}
+
+	failed, err := store.DebugReload()
+	if err != nil {
+		t.Fatalf("DebugReload: %v", err)
+	}
+	if len(failed) != 0 {
+		t.Fatalf("Keys failed to round-trip: %v", failed)
+	}
+
+	after := store.data.SnapshotRawData()
+	if len(after) != len(before) {
+		t.Fatalf("Expected %d keys after reload, got %d", len(before), len(after))
+	}
+	for _, got := range after {
+		want, ok := before[got.Key]
+		if !ok {
+			t.Errorf("Unexpected key %q after reload", got.Key)
+			continue
+		}
+		if string(got.RawBytes) != string(want.RawBytes) || got.ValueType != want.ValueType {
+			t.Errorf("%s: value changed from %q (%s) to %q (%s)", got.Key, want.RawBytes, want.ValueType, got.RawBytes, got.ValueType)
+		}
+		if !got.ExpiresAt.Equal(want.ExpiresAt) {
+			t.Errorf("%s: expiry changed from %v to %v", got.Key, want.ExpiresAt, got.ExpiresAt)
+		}
+		if got.SessionID != want.SessionID {
+			t.Errorf("%s: session changed from %q to %q", got.Key, want.SessionID, got.SessionID)
+		}
+	}
+
+	if v, err := store.Get("int"); err != nil || v != 42 {
+		t.Errorf("Get int = %v, %v; want 42", v, err)
+	}
+	if items, err := store.ListRange("list", 0, -1); err != nil || len(items) != 3 || items[2] != "c" {
+		t.Errorf("ListRange = %v, %v; want [a b c]", items, err)
+	}
+	if ok, err := store.SetIsMember("set", "y"); err != nil || !ok {
+		t.Errorf("SetIsMember = %v, %v; want true", ok, err)
+	}
+	if ttl, ok := store.TTL("ttl"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL = %v, %v; want within an hour", ttl, ok)
+	}
+}
+
+func TestBasicStore_DebugReloadWithoutPersistence(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{Name: "test-debug-reload-off", MaxMemory: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.DebugReload(); err == nil {
+		t.Error("Expected DebugReload to fail when persistence is disabled")
+	}
+}
+
+func TestBasicStore_ExpireAndPersistSurviveRecovery(t *testing.T) {
+	persistConfig := persistence.DefaultPersistenceConfig()
+	persistConfig.Enabled = true
+	persistConfig.EnableAOF = true
+	persistConfig.DataDirectory = t.TempDir()
+	config := BasicStoreConfig{
+		Name:              "expire-recovery-test",
+		MaxMemory:         1024 * 1024,
+		PersistenceConfig: &persistConfig,
+	}
+	ctx := context.Background()
+
+	store, err := NewBasicStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.StartPersistence(ctx); err != nil {
+		t.Fatalf("Failed to start persistence: %v", err)
+	}
+	store.Set("persisted", "v", "", time.Minute)
+	store.Set("extended", "v", "", time.Minute)
+	if !store.Persist("persisted") {
+		t.Fatal("Persist(persisted) = false")
+	}
+	if !store.Expire("extended", time.Hour) {
+		t.Fatal("Expire(extended) = false")
+	}
+	if err := store.StopPersistence(); err != nil {
+		t.Fatalf("Failed to stop persistence: %v", err)
+	}
+	store.Close()
+
+	recovered, err := NewBasicStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create second store: %v", err)
+	}
+	defer recovered.Close()
+	if err := recovered.StartPersistence(ctx); err != nil {
+		t.Fatalf("Failed to start persistence on second store: %v", err)
+	}
+	defer recovered.StopPersistence()
+
+	if ttl, ok := recovered.TTL("persisted"); !ok || ttl != -1 {
+		t.Errorf("TTL(persisted) after recovery = (%v, %v), want no expiry", ttl, ok)
+	}
+	if ttl, ok := recovered.TTL("extended"); !ok || ttl <= time.Minute {
+		t.Errorf("TTL(extended) after recovery = (%v, %v), want about an hour", ttl, ok)
+	}
+}
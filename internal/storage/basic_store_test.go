@@ -1,10 +1,22 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"hypercache/internal/cluster"
 )
 
 func TestBasicStore_NewBasicStore(t *testing.T) {
@@ -312,6 +324,290 @@ func TestBasicStore_Delete(t *testing.T) {
 	}
 }
 
+func TestBasicStore_GetDel(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "getdel-store",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Set("otp", "123456", "", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Of many concurrent consumers exactly one gets the token
+	var winners atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, found, err := store.GetDel("otp")
+			if err != nil {
+				t.Errorf("GetDel() error = %v", err)
+				return
+			}
+			if found {
+				if value != "123456" {
+					t.Errorf("GetDel() = %v, want 123456", value)
+				}
+				winners.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := winners.Load(); got != 1 {
+		t.Errorf("GetDel() returned the value %d times, want once", got)
+	}
+	if store.Has("otp") || store.Size() != 0 {
+		t.Error("Key survived GetDel")
+	}
+
+	if _, found, err := store.GetDel("otp"); found || err != nil {
+		t.Errorf("Second GetDel() = found %v, err %v; want a miss", found, err)
+	}
+
+	if _, err := store.ListPush("queue", false, "job"); err != nil {
+		t.Fatalf("ListPush() error = %v", err)
+	}
+	if _, _, err := store.GetDel("queue"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("GetDel() on a list error = %v, want ErrWrongType", err)
+	}
+	if !store.Has("queue") {
+		t.Error("GetDel() deleted a list")
+	}
+}
+
+// checkSlotIndex fails the test unless the slot index holds exactly the keys in the map
+func checkSlotIndex(t *testing.T, sm *ShardedMap) {
+	t.Helper()
+	indexed := 0
+	for slot := range sm.slots.slots {
+		indexed += len(sm.slots.slots[slot])
+	}
+	if size := sm.Size(); indexed != size {
+		t.Errorf("Slot index holds %d keys, map holds %d", indexed, size)
+	}
+	sm.RangeAll(func(key string, item *CacheItem) bool {
+		if _, ok := sm.slots.slots[cluster.GetHashSlot(key)][key]; !ok {
+			t.Errorf("Key %s missing from the slot index", key)
+		}
+		return true
+	})
+}
+
+func TestBasicStore_SlotIndex(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "slot-index-store",
+		MaxMemory: 1024 * 1024,
+		SlotIndex: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Keys sharing a hash tag share a slot
+	slot := cluster.GetHashSlot("{user1}")
+	for _, key := range []string{"{user1}:cart", "{user1}:profile", "{user1}:token", "{user2}:cart", "other"} {
+		if err := store.Set(key, "v", "", 0); err != nil {
+			t.Fatalf("Set(%s) error = %v", key, err)
+		}
+	}
+	if err := store.Set("{user1}:cart", "overwritten", "", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	checkSlotIndex(t, store.data)
+
+	keys := store.KeysInSlot(slot, 0)
+	sort.Strings(keys)
+	if want := []string{"{user1}:cart", "{user1}:profile", "{user1}:token"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("KeysInSlot() = %v, want %v", keys, want)
+	}
+	if got := store.KeysInSlot(slot, 2); len(got) != 2 {
+		t.Errorf("KeysInSlot() with count 2 returned %d keys", len(got))
+	}
+
+	// Deletes, GETDEL and expiry all leave the index
+	if err := store.Delete("{user1}:profile"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, found, _ := store.GetDel("{user1}:token"); !found {
+		t.Fatal("GetDel() missed")
+	}
+	if err := store.Set("{user1}:short", "v", "", time.Millisecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if got := store.CountKeysInSlot(slot); got != 1 {
+		t.Errorf("CountKeysInSlot() = %d, want 1 (expired keys are not listed)", got)
+	}
+	if _, err := store.Get("{user1}:short"); err == nil {
+		t.Fatal("Get() of an expired key should fail")
+	}
+	checkSlotIndex(t, store.data)
+
+	// Without the index the same keys are found by scanning
+	scanned := NewShardedMap()
+	store.data.RangeAll(func(key string, item *CacheItem) bool {
+		scanned.Set(key, item, nil)
+		return true
+	})
+	if got := scanned.KeysInSlot(slot, 0); !reflect.DeepEqual(got, []string{"{user1}:cart"}) {
+		t.Errorf("KeysInSlot() without the index = %v", got)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	checkSlotIndex(t, store.data)
+	if got := store.CountKeysInSlot(cluster.GetHashSlot("other")); got != 0 {
+		t.Errorf("CountKeysInSlot() after Clear = %d", got)
+	}
+}
+
+func TestBasicStore_DeleteSession(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "session-store",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := store.Set(fmt.Sprintf("alice-%d", i), "cart item", "alice", 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if err := store.Set(fmt.Sprintf("bob-%d", i), "cart item", "bob", 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	if err := store.Set("shared", "no session", "", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	// A key rewritten under another session belongs to that session now
+	if err := store.Set("alice-4", "taken over", "bob", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	memoryBefore := store.Memory()
+
+	deleted, err := store.DeleteSession("alice")
+	if err != nil {
+		t.Fatalf("DeleteSession() error = %v", err)
+	}
+	if deleted != 4 {
+		t.Errorf("DeleteSession() deleted %d keys, want 4", deleted)
+	}
+	for i := 0; i < 4; i++ {
+		if store.Has(fmt.Sprintf("alice-%d", i)) {
+			t.Errorf("alice-%d survived DeleteSession", i)
+		}
+	}
+	for _, key := range []string{"bob-0", "bob-4", "alice-4", "shared"} {
+		if !store.Has(key) {
+			t.Errorf("%s was deleted with another session", key)
+		}
+	}
+	if got := store.Size(); got != 7 {
+		t.Errorf("Size() after DeleteSession = %d, want 7", got)
+	}
+	if store.Memory() >= memoryBefore {
+		t.Errorf("Memory() after DeleteSession = %d, want less than %d", store.Memory(), memoryBefore)
+	}
+
+	if deleted, _ := store.DeleteSession("alice"); deleted != 0 {
+		t.Errorf("Second DeleteSession() deleted %d keys, want 0", deleted)
+	}
+	if _, err := store.DeleteSession(""); err == nil {
+		t.Error("DeleteSession() with an empty session ID should fail")
+	}
+}
+
+func TestBasicStore_SessionIdleTimeout(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:               "session-expiry-store",
+		MaxMemory:          1024 * 1024,
+		SessionIdleTimeout: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := store.Set(fmt.Sprintf("alice-%d", i), "cart item", "alice", 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if err := store.Set(fmt.Sprintf("bob-%d", i), "cart item", "bob", 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	if err := store.Set("shared", "no session", "", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Both sessions went quiet two hours ago, but bob has since read a key
+	for _, session := range []string{"alice", "bob"} {
+		last, ok := store.sessions.Load(session)
+		if !ok {
+			t.Fatalf("Session %s was not registered", session)
+		}
+		last.(*atomic.Int64).Store(time.Now().Add(-2 * time.Hour).UnixNano())
+	}
+	if _, err := store.Get("bob-1"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if expired := store.expireIdleSessions(time.Now()); expired != 1 {
+		t.Errorf("expireIdleSessions() expired %d sessions, want 1", expired)
+	}
+	for i := 0; i < 3; i++ {
+		if store.Has(fmt.Sprintf("alice-%d", i)) {
+			t.Errorf("alice-%d survived its session expiring", i)
+		}
+		if !store.Has(fmt.Sprintf("bob-%d", i)) {
+			t.Errorf("bob-%d was deleted although bob is active", i)
+		}
+	}
+	if !store.Has("shared") {
+		t.Error("A key without a session was deleted")
+	}
+
+	// Writing again starts a fresh session
+	if err := store.Set("alice-0", "new cart", "alice", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if expired := store.expireIdleSessions(time.Now()); expired != 0 {
+		t.Errorf("expireIdleSessions() expired %d active sessions", expired)
+	}
+
+	// The background sweeper removes idle sessions on its own
+	swept, err := NewBasicStore(BasicStoreConfig{
+		Name:               "session-sweep-store",
+		MaxMemory:          1024 * 1024,
+		SessionIdleTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer swept.Close()
+	if err := swept.Set("carol-0", "cart item", "carol", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for swept.Has("carol-0") {
+		if time.Now().After(deadline) {
+			t.Fatal("Idle session was not swept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func TestBasicStore_Clear(t *testing.T) {
 	store, err := NewBasicStore(BasicStoreConfig{
 		Name:      "test-store",
@@ -390,6 +686,40 @@ func TestBasicStore_UpdateExistingKey(t *testing.T) {
 	}
 }
 
+func TestBasicStore_MaxKeySize(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:       "test-store",
+		MaxMemory:  1024 * 1024,
+		MaxKeySize: 16,
+		StrictKeys: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Set("normal-key", "value", "", 0); err != nil {
+		t.Errorf("Set() of a %d-byte key error = %v", len("normal-key"), err)
+	}
+	if err := store.Set(strings.Repeat("k", 16), "value", "", 0); err != nil {
+		t.Errorf("Set() of a key at the limit error = %v", err)
+	}
+
+	long := strings.Repeat("k", 17)
+	if err := store.Set(long, "value", "", 0); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("Set() of an over-length key error = %v, want ErrKeyTooLarge", err)
+	}
+	if _, err := store.ListPush(long, false, "a"); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("ListPush() of an over-length key error = %v, want ErrKeyTooLarge", err)
+	}
+	if err := store.Set("nul\x00key", "value", "", 0); !errors.Is(err, ErrKeyInvalid) {
+		t.Errorf("Set() of a key with a NUL byte error = %v, want ErrKeyInvalid", err)
+	}
+	if store.Size() != 2 {
+		t.Errorf("Store size = %d, want only the 2 accepted keys", store.Size())
+	}
+}
+
 func TestBasicStore_ConcurrentOperations(t *testing.T) {
 	store, err := NewBasicStore(BasicStoreConfig{
 		Name:      "test-store",
@@ -509,167 +839,3069 @@ func TestBasicStore_MemoryPressureHandling(t *testing.T) {
 	}
 }
 
-func TestBasicStore_Statistics(t *testing.T) {
+func TestBasicStore_EvictionMarks(t *testing.T) {
 	store, err := NewBasicStore(BasicStoreConfig{
-		Name:             "stats-test",
-		MaxMemory:        1024 * 1024,
-		EnableStatistics: true,
+		Name:                  "eviction-marks-test",
+		MaxMemory:             64 * 1024,
+		EvictionHighWater:     0.5,
+		EvictionCriticalWater: 0.6,
+		EvictionPanicWater:    0.7,
+		EvictionLowWater:      0.2,
+		EvictionBatchSize:     4,
 	})
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
 	}
 	defer store.Close()
 
-	// Test initial stats
-	stats := store.Stats()
-	if stats.TotalItems != 0 {
-		t.Errorf("Initial total items = %v, want 0", stats.TotalItems)
-	}
-	if stats.HitCount != 0 {
-		t.Errorf("Initial hit count = %v, want 0", stats.HitCount)
-	}
-	if stats.MissCount != 0 {
-		t.Errorf("Initial miss count = %v, want 0", stats.MissCount)
+	// Fill until the high-water mark triggers a pressure event
+	value := string(make([]byte, 1024))
+	for i := 0; store.memPool.MemoryPressure() < 0.5; i++ {
+		if err := store.Set(fmt.Sprintf("key-%d", i), value, "", 0); err != nil {
+			t.Fatalf("Set key-%d: %v", i, err)
+		}
 	}
 
-	// Add an item
-	err = store.Set("stats-key", "stats-value", "session1", 0)
-	if err != nil {
-		t.Fatalf("Set() error = %v", err)
+	// One pressure event reclaims down to the low-water mark, not just below high
+	deadline := time.Now().Add(2 * time.Second)
+	for store.memPool.MemoryPressure() > 0.2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
 	}
-
-	// Get the item (hit)
-	_, err = store.Get("stats-key")
-	if err != nil {
-		t.Errorf("Get() error = %v", err)
+	if pressure := store.memPool.MemoryPressure(); pressure > 0.2 {
+		t.Fatalf("Pressure after eviction = %.2f, want <= low water 0.2", pressure)
 	}
-
-	// Try to get non-existent item (miss)
-	_, err = store.Get("non-existent")
-	if err == nil {
-		t.Errorf("Get() non-existent should have failed")
+	evicted := store.Stats().MemoryEvictionCount
+	if evicted == 0 {
+		t.Fatal("Expected evictions after crossing the high-water mark")
 	}
 
-	// Check updated stats
-	stats = store.Stats()
-	if stats.TotalItems != 1 {
-		t.Errorf("Total items = %v, want 1", stats.TotalItems)
-	}
-	if stats.HitCount != 1 {
-		t.Errorf("Hit count = %v, want 1", stats.HitCount)
+	// Further writes stay below the high-water mark and evict nothing
+	for i := 0; i < 5; i++ {
+		if err := store.Set(fmt.Sprintf("after-%d", i), value, "", 0); err != nil {
+			t.Fatalf("Set after-%d: %v", i, err)
+		}
 	}
-	if stats.MissCount != 1 {
-		t.Errorf("Miss count = %v, want 1", stats.MissCount)
+	time.Sleep(50 * time.Millisecond)
+	if got := store.Stats().MemoryEvictionCount; got != evicted {
+		t.Errorf("Writes after reclaim evicted %d more keys, want none", got-evicted)
 	}
+}
 
-	// Test hit rate calculation
-	expectedHitRate := 50.0 // 1 hit out of 2 total attempts
-	if stats.HitRate() != expectedHitRate {
-		t.Errorf("Hit rate = %v, want %v", stats.HitRate(), expectedHitRate)
+func TestBasicStore_EvictionMarksValidated(t *testing.T) {
+	_, err := NewBasicStore(BasicStoreConfig{
+		Name:              "bad-marks",
+		MaxMemory:         1024,
+		EvictionHighWater: 0.5,
+		EvictionLowWater:  0.6,
+	})
+	if err == nil {
+		t.Error("Expected an error for a low-water mark above the high-water mark")
 	}
 }
 
-func TestBasicStore_CleanupExpiredItems(t *testing.T) {
+func TestBasicStore_EvictionReclaimsExpiredFirst(t *testing.T) {
+	clock := NewMockClock(time.Now())
 	store, err := NewBasicStore(BasicStoreConfig{
-		Name:            "cleanup-test",
-		MaxMemory:       1024 * 1024,
-		CleanupInterval: 50 * time.Millisecond, // Fast cleanup
+		Name:              "eviction-expired-test",
+		MaxMemory:         1024 * 1024,
+		Clock:             clock,
+		EvictionHighWater: 0.5,
+		EvictionLowWater:  0.2,
 	})
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
 	}
 	defer store.Close()
+	// Leave the expired keys to the evictor
+	store.SetActiveExpire(false)
 
-	// Add items with short TTL
-	for i := 0; i < 5; i++ {
-		key := fmt.Sprintf("expire-key-%d", i)
-		value := fmt.Sprintf("expire-value-%d", i)
-		err = store.Set(key, value, "session1", 75*time.Millisecond)
-		if err != nil {
-			t.Fatalf("Set() error = %v", err)
+	value := string(make([]byte, 1024))
+	for i := 0; i < 300; i++ {
+		if err := store.Set(fmt.Sprintf("expiring-%d", i), value, "", time.Minute); err != nil {
+			t.Fatalf("Set expiring-%d: %v", i, err)
 		}
 	}
+	clock.Advance(2 * time.Minute)
 
-	// Verify items exist
-	if store.Size() != 5 {
-		t.Errorf("Store size = %v, want 5", store.Size())
+	var live []string
+	for i := 0; store.memPool.MemoryPressure() < 0.5; i++ {
+		key := fmt.Sprintf("live-%d", i)
+		if err := store.Set(key, value, "", 0); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+		live = append(live, key)
 	}
 
-	// Wait for cleanup to run
-	time.Sleep(150 * time.Millisecond)
-
-	// Items should be cleaned up
-	if store.Size() != 0 {
-		t.Errorf("Store size after cleanup = %v, want 0", store.Size())
+	deadline := time.Now().Add(2 * time.Second)
+	for store.memPool.MemoryPressure() > 0.2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if pressure := store.memPool.MemoryPressure(); pressure > 0.2 {
+		t.Fatalf("Pressure after eviction = %.2f, want <= low water 0.2", pressure)
+	}
+	if evicted := store.Stats().MemoryEvictionCount; evicted != 0 {
+		t.Errorf("Evicted %d live keys while expired keys were reclaimable", evicted)
+	}
+	for _, key := range live {
+		if !store.Has(key) {
+			t.Errorf("Live key %s was evicted", key)
+		}
 	}
 }
 
-// Benchmark tests
-func BenchmarkBasicStore_Set(b *testing.B) {
+func TestCompactHeap_ReducesFragmentation(t *testing.T) {
 	store, err := NewBasicStore(BasicStoreConfig{
-		Name:      "benchmark-store",
-		MaxMemory: 100 * 1024 * 1024, // 100MB
+		Name:      "fragmentation-test",
+		MaxMemory: 64 * 1024 * 1024,
 	})
 	if err != nil {
-		b.Fatalf("Failed to create store: %v", err)
+		t.Fatalf("Failed to create store: %v", err)
 	}
 	defer store.Close()
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		key := fmt.Sprintf("key-%d", i)
-		value := fmt.Sprintf("value-%d", i)
-		store.Set(key, value, "session1", 0)
+	// Churn: write a batch of values, delete most of them, keep a few
+	value := string(make([]byte, 1024))
+	for round := 0; round < 4; round++ {
+		for i := 0; i < 2000; i++ {
+			if err := store.Set(fmt.Sprintf("churn-%d-%d", round, i), value, "", 0); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+		}
+		for i := 0; i < 2000; i++ {
+			if i%100 != 0 {
+				_ = store.Delete(fmt.Sprintf("churn-%d-%d", round, i))
+			}
+		}
+	}
+	runtime.GC()
+
+	before, after := CompactHeap()
+	if before.Allocated == 0 || before.Resident < before.Allocated || before.Ratio < 1 {
+		t.Fatalf("Fragmentation not reported: %+v", before)
+	}
+	if before.Free != before.Resident-before.Allocated {
+		t.Errorf("Free = %d, want resident - allocated = %d", before.Free, before.Resident-before.Allocated)
+	}
+	if after.Ratio >= before.Ratio {
+		t.Errorf("Compaction didn't reduce fragmentation: ratio %.2f -> %.2f", before.Ratio, after.Ratio)
+	}
+	if store.Size() != 80 {
+		t.Errorf("Size after compaction = %d, want 80", store.Size())
 	}
 }
 
-func BenchmarkBasicStore_Get(b *testing.B) {
+func TestBasicStore_VerifyNoDrift(t *testing.T) {
+	clock := NewMockClock(time.Now())
 	store, err := NewBasicStore(BasicStoreConfig{
-		Name:      "benchmark-store",
-		MaxMemory: 100 * 1024 * 1024, // 100MB
+		Name:         "verify-test",
+		MaxMemory:    256 * 1024,
+		Clock:        clock,
+		LazyFree:     true,
+		InternValues: true,
 	})
 	if err != nil {
-		b.Fatalf("Failed to create store: %v", err)
+		t.Fatalf("Failed to create store: %v", err)
 	}
 	defer store.Close()
 
-	// Pre-populate store
-	for i := 0; i < 1000; i++ {
-		key := fmt.Sprintf("key-%d", i)
-		value := fmt.Sprintf("value-%d", i)
+	large := strings.Repeat("x", 8*1024)
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 200; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			switch i % 5 {
+			case 0:
+				// Shared (interned) and distinct large values, enough to
+				// push the store into eviction
+				value := large
+				if i%10 == 5 {
+					value = large + key
+				}
+				_ = store.Set(key, value, "session", 0)
+			case 1:
+				_ = store.Set(key, fmt.Sprintf("v-%d-%d", round, i), "", time.Second)
+			case 2:
+				_, _ = store.ListPush(key, true, "a", "b")
+				_, _, _ = store.ListPop(key, false)
+			case 3:
+				_, _ = store.SetAdd(key, "m1", "m2")
+				_, _ = store.SetRemove(key, "m1")
+			case 4:
+				_, _ = store.SetBit(key, uint64(round*8), true)
+				_, _ = store.PFAdd(key+"-hll", key)
+			}
+		}
+		for i := 0; i < 200; i += 3 {
+			_ = store.Delete(fmt.Sprintf("key-%d", i))
+		}
+		_, _, _ = store.GetDel("key-7")
+
+		// Expire the TTL'd keys, half lazily on read, the rest actively
+		clock.Advance(2 * time.Second)
+		for i := 1; i < 100; i += 5 {
+			_, _ = store.Get(fmt.Sprintf("key-%d", i))
+		}
+		store.SweepExpired()
+
+		if err := verifyQuiescent(store); err != nil {
+			t.Fatalf("Round %d: %v", round, err)
+		}
+	}
+	if store.Stats().MemoryEvictionCount == 0 {
+		t.Error("Expected the large values to cause evictions")
+	}
+
+	if _, err := store.DeleteSession("session"); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if err := verifyQuiescent(store); err != nil {
+		t.Errorf("After DeleteSession: %v", err)
+	}
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if err := verifyQuiescent(store); err != nil {
+		t.Errorf("After Clear: %v", err)
+	}
+}
+
+// verifyQuiescent retries Verify for a while, so drift that is only the
+// background evictor racing with the walk doesn't fail a test
+func verifyQuiescent(store *BasicStore) error {
+	var err error
+	for i := 0; i < 50; i++ {
+		if err = store.Verify(); err == nil {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+func TestBasicStore_VerifyReportsDrift(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{Name: "drift-test", MaxMemory: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Set("a", "value", "", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	store.updateStats(func() { store.stats.TotalMemory += 10 })
+
+	var drift *AccountingDrift
+	if err := store.Verify(); !errors.As(err, &drift) {
+		t.Fatalf("Verify() = %v, want *AccountingDrift", err)
+	}
+	if drift.ActualItems != 1 || drift.CountedItems != 1 || drift.CountedMemory != drift.ActualMemory+10 {
+		t.Errorf("Unexpected drift: %+v", drift)
+	}
+}
+
+func TestBasicStore_OnExpire(t *testing.T) {
+	clock := NewMockClock(time.Now())
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "on-expire-test",
+		MaxMemory: 1024 * 1024,
+		Clock:     clock,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	type expiry struct {
+		key   string
+		value interface{}
+	}
+	expired := make(chan expiry, 10)
+	store.OnExpire(func(key string, value interface{}) {
+		expired <- expiry{key, value}
+	})
+
+	if err := store.Set("lazy", "v1", "", time.Second); err != nil {
+		t.Fatalf("Set lazy: %v", err)
+	}
+	if err := store.Set("swept", "v2", "", time.Second); err != nil {
+		t.Fatalf("Set swept: %v", err)
+	}
+	if err := store.Set("deleted", "v3", "", time.Second); err != nil {
+		t.Fatalf("Set deleted: %v", err)
+	}
+	if err := store.Delete("deleted"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	clock.Advance(2 * time.Second)
+
+	wait := func() expiry {
+		t.Helper()
+		select {
+		case e := <-expired:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("OnExpire callback not called")
+			return expiry{}
+		}
+	}
+
+	// Expired lazily on access
+	if _, err := store.Get("lazy"); err == nil {
+		t.Fatal("Get of an expired key succeeded")
+	}
+	if got := wait(); got.key != "lazy" || got.value != "v1" {
+		t.Errorf("Lazy expiry callback = %+v, want lazy/v1", got)
+	}
+
+	// Expired by the active sweep
+	store.SweepExpired()
+	if got := wait(); got.key != "swept" || got.value != "v2" {
+		t.Errorf("Active expiry callback = %+v, want swept/v2", got)
+	}
+
+	// A client delete is not an expiry
+	select {
+	case got := <-expired:
+		t.Errorf("Unexpected callback for %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBasicStore_LoaderSingleFlight(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "loader-test",
+		MaxMemory: 1024 * 1024,
+		Loader: func(key string) (interface{}, time.Duration, error) {
+			loads.Add(1)
+			<-release // hold the load until every Get has missed
+			if key == "absent" {
+				return nil, 0, nil
+			}
+			return "loaded:" + key, 0, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	const readers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := store.Get("user:1")
+			if err == nil && value != "loaded:user:1" {
+				err = fmt.Errorf("got %v", value)
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Get: %v", err)
+	}
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Loader called %d times, want 1", n)
+	}
+	if !store.Has("user:1") {
+		t.Error("Loaded value was not cached")
+	}
+	if _, err := store.Get("user:1"); err != nil || loads.Load() != 1 {
+		t.Errorf("Cached Get: err=%v, loads=%d", err, loads.Load())
+	}
+
+	// A key the backing store doesn't have stays a miss
+	if _, err := store.Get("absent"); err == nil {
+		t.Error("Get of a key the loader doesn't have succeeded")
+	}
+}
+
+func TestBasicStore_GetOrComputeSingleFlight(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:           "compute-test",
+		MaxMemory:      1024 * 1024,
+		CoalesceMisses: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	var computes atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	compute := func() (interface{}, error) {
+		if computes.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return "computed", nil
+	}
+
+	const callers = 100
+	var wg sync.WaitGroup
+	results := make(chan interface{}, callers+1)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := store.GetOrCompute("cold", compute)
+			if err != nil {
+				value = err
+			}
+			results <- value
+		}()
+	}
+
+	// A plain Get joins the compute in flight rather than missing
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		value, err := store.Get("cold")
+		if err != nil {
+			value = err
+		}
+		results <- value
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := computes.Load(); n != 1 {
+		t.Errorf("compute ran %d times, want 1", n)
+	}
+	for value := range results {
+		if value != "computed" {
+			t.Errorf("Caller got %v, want computed", value)
+		}
+	}
+	if value, err := store.Get("cold"); err != nil || value != "computed" {
+		t.Errorf("Get after compute = %v, %v; want the cached value", value, err)
+	}
+
+	// A failed compute caches nothing
+	if _, err := store.GetOrCompute("broken", func() (interface{}, error) {
+		return nil, errors.New("backend down")
+	}); err == nil || store.Has("broken") {
+		t.Errorf("Failed compute: err=%v, cached=%v", err, store.Has("broken"))
+	}
+}
+
+func TestBasicStore_GetOrComputeFailureNotShared(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fail func() (interface{}, error)
+	}{
+		{"error", func() (interface{}, error) { return nil, errors.New("backend down") }},
+		{"panic", func() (interface{}, error) { panic("backend exploded") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := NewBasicStore(BasicStoreConfig{Name: "compute-fail-test", MaxMemory: 1024 * 1024})
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			defer store.Close()
+
+			// The first compute fails once the waiters have joined it; the
+			// rest succeed
+			var computes atomic.Int32
+			started := make(chan struct{})
+			release := make(chan struct{})
+			compute := func() (interface{}, error) {
+				if computes.Add(1) == 1 {
+					close(started)
+					<-release
+					return tc.fail()
+				}
+				return "computed", nil
+			}
+
+			failed := make(chan interface{}, 1)
+			go func() {
+				defer func() { failed <- recover() }()
+				_, err := store.GetOrCompute("cold", compute)
+				failed <- err
+			}()
+			<-started
+
+			const waiters = 20
+			var wg sync.WaitGroup
+			results := make(chan interface{}, waiters)
+			for i := 0; i < waiters; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					value, err := store.GetOrCompute("cold", compute)
+					if err != nil {
+						value = err
+					}
+					results <- value
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+
+			// Only the failed caller sees the failure, a panic as a panic
+			if got := <-failed; got == nil {
+				t.Errorf("Failed compute's caller got no %s", tc.name)
+			}
+			wg.Wait()
+			close(results)
+			for value := range results {
+				if value != "computed" {
+					t.Errorf("Waiter got %v, want computed", value)
+				}
+			}
+			if n := computes.Load(); n != 2 {
+				t.Errorf("compute ran %d times, want 2", n)
+			}
+			if value, err := store.Get("cold"); err != nil || value != "computed" {
+				t.Errorf("Get after compute = %v, %v; want the cached value", value, err)
+			}
+		})
+	}
+}
+
+func TestBasicStore_WriteBehind(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]WriteBehind
+	written := make(chan struct{}, 10)
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:                "write-behind-test",
+		MaxMemory:           1024 * 1024,
+		WriteBehindInterval: time.Hour, // only full batches and Close flush
+		WriteBehindBatch:    3,
+		Writer: func(batch []WriteBehind) error {
+			mu.Lock()
+			batches = append(batches, batch)
+			mu.Unlock()
+			written <- struct{}{}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Rewrites of a pending key are coalesced
+	for _, kv := range [][2]string{{"a", "1"}, {"b", "1"}, {"a", "2"}, {"c", "1"}} {
+		if err := store.Set(kv[0], kv[1], "", 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	select {
+	case <-written:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Full batch was not flushed")
+	}
+
+	if err := store.Set("d", "1", "", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	store.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := [][]WriteBehind{
+		{{Key: "a", Value: "2"}, {Key: "b", Value: "1"}, {Key: "c", Value: "1"}},
+		{{Key: "d", Value: "1"}},
+	}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("Writer batches = %v, want %v", batches, want)
+	}
+}
+
+func TestBasicStore_WriteBehindTombstones(t *testing.T) {
+	var mu sync.Mutex
+	var writes []WriteBehind
+	clock := NewMockClock(time.Now())
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:                "write-behind-tombstone-test",
+		MaxMemory:           1024 * 1024,
+		Clock:               clock,
+		WriteBehindInterval: time.Hour, // only Close flushes
+		Writer: func(batch []WriteBehind) error {
+			mu.Lock()
+			writes = append(writes, batch...)
+			mu.Unlock()
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	for _, key := range []string{"deleted", "taken", "kept"} {
+		if err := store.Set(key, "v", "", 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if err := store.Set("session", "v", "s1", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set("expiring", "v", "", time.Second); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// A delete, GETDEL, session delete and expiry each leave a tombstone
+	if err := store.Delete("deleted"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, found, err := store.GetDel("taken"); !found || err != nil {
+		t.Fatalf("GetDel: found=%v, err=%v", found, err)
+	}
+	if n, err := store.DeleteSession("s1"); n != 1 || err != nil {
+		t.Fatalf("DeleteSession: %d, %v", n, err)
+	}
+	clock.Advance(2 * time.Second)
+	if n := store.SweepExpired(); n != 1 {
+		t.Fatalf("SweepExpired removed %d keys, want 1", n)
+	}
+
+	// A key handed off to another node keeps its backing store copy
+	exported, _ := store.Export("kept")
+	if !store.DeleteVersion("kept", exported.Version) {
+		t.Fatal("DeleteVersion failed")
+	}
+	store.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []WriteBehind{
+		{Key: "deleted", Deleted: true},
+		{Key: "taken", Deleted: true},
+		{Key: "kept", Value: "v"},
+		{Key: "session", Deleted: true},
+		{Key: "expiring", Deleted: true},
+	}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("Writer got %v, want %v", writes, want)
+	}
+}
+
+func TestBasicStore_WriteBehindMaxPending(t *testing.T) {
+	var store *BasicStore
+	var mu sync.Mutex
+	var written []string
+	failures := 1
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:                  "write-behind-cap-test",
+		MaxMemory:             1024 * 1024,
+		WriteBehindInterval:   time.Hour, // only explicit flushes and Close
+		WriteBehindMaxPending: 3,
+		Writer: func(batch []WriteBehind) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if failures > 0 {
+				// A new key arrives while the failed batch is out
+				failures--
+				if err := store.Set("x", "v", "", 0); err != nil {
+					t.Errorf("Set: %v", err)
+				}
+				return errors.New("backend down")
+			}
+			for _, write := range batch {
+				written = append(written, write.Key)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// New keys past the cap are dropped; a pending key can still change
+	for _, key := range []string{"a", "b", "c", "d", "e", "a"} {
+		if err := store.Set(key, "v", "", 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if n := store.WriteBehindDropped(); n != 2 {
+		t.Errorf("WriteBehindDropped = %d, want 2", n)
+	}
+
+	// A failed batch is put back only as far as it fits beside the new key
+	store.writeBehind.flush()
+	if n := store.WriteBehindDropped(); n != 3 {
+		t.Errorf("WriteBehindDropped after a failed flush = %d, want 3", n)
+	}
+	store.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"a", "b", "x"}; !reflect.DeepEqual(written, want) {
+		t.Errorf("Writer got %v, want %v", written, want)
+	}
+}
+
+func TestBasicStore_Encoding(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{Name: "encoding-test", MaxMemory: 4 * 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// A set switches encoding once it crosses the listpack entry threshold
+	for i := 0; i < ListpackMaxEntries; i++ {
+		if _, err := store.SetAdd("tags", fmt.Sprintf("tag-%d", i)); err != nil {
+			t.Fatalf("SetAdd: %v", err)
+		}
+	}
+	if got := store.Encoding("tags"); got != EncodingListpack {
+		t.Errorf("Set of %d members: encoding %q, want %q", ListpackMaxEntries, got, EncodingListpack)
+	}
+	if _, err := store.SetAdd("tags", "one-too-many"); err != nil {
+		t.Fatalf("SetAdd: %v", err)
+	}
+	if got := store.Encoding("tags"); got != EncodingHashtable {
+		t.Errorf("Set of %d members: encoding %q, want %q", ListpackMaxEntries+1, got, EncodingHashtable)
+	}
+
+	// ... and so does a list with an element past the size threshold
+	if _, err := store.ListPush("queue", false, "a", "b"); err != nil {
+		t.Fatalf("ListPush: %v", err)
+	}
+	if got := store.Encoding("queue"); got != EncodingListpack {
+		t.Errorf("Small list: encoding %q, want %q", got, EncodingListpack)
+	}
+	if _, err := store.ListPush("queue", false, strings.Repeat("x", ListpackMaxValue+1)); err != nil {
+		t.Fatalf("ListPush: %v", err)
+	}
+	if got := store.Encoding("queue"); got != EncodingQuicklist {
+		t.Errorf("List with a long element: encoding %q, want %q", got, EncodingQuicklist)
+	}
+
+	if _, err := store.SetAdd("ids", "1", "2", "3"); err != nil {
+		t.Fatalf("SetAdd: %v", err)
+	}
+	tests := map[string]string{
+		"ids":     EncodingIntset,
+		"counter": EncodingInt,
+		"short":   EncodingEmbstr,
+		"long":    EncodingRaw,
+		"missing": "",
+	}
+	_ = store.Set("counter", "12345", "", 0)
+	_ = store.Set("short", "hello", "", 0)
+	_ = store.Set("long", strings.Repeat("y", EmbstrMaxSize+1), "", 0)
+	for key, want := range tests {
+		if got := store.Encoding(key); got != want {
+			t.Errorf("Encoding(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestBasicStore_ContainerEncodings(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{Name: "container-encoding-test", MaxMemory: 16 * 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// A list past the listpack threshold is stored as a quicklist, read
+	// across its nodes, and goes back to a listpack once it shrinks
+	var want []string
+	for i := 0; i < 3*ListpackMaxEntries+5; i++ {
+		want = append(want, fmt.Sprintf("item-%d", i))
+	}
+	if _, err := store.ListPush("queue", false, want...); err != nil {
+		t.Fatalf("ListPush: %v", err)
+	}
+	if got := store.Encoding("queue"); got != EncodingQuicklist {
+		t.Errorf("Large list: encoding %q, want %q", got, EncodingQuicklist)
+	}
+	if n, err := store.ListLen("queue"); n != len(want) || err != nil {
+		t.Errorf("ListLen = %d, %v; want %d", n, err, len(want))
+	}
+	for _, r := range [][2]int{{0, -1}, {5, 10}, {ListpackMaxEntries - 2, ListpackMaxEntries + 1}, {-3, -1}, {2 * ListpackMaxEntries, 2 * ListpackMaxEntries}} {
+		got, err := store.ListRange("queue", r[0], r[1])
+		start, stop := r[0], r[1]
+		if start < 0 {
+			start += len(want)
+		}
+		if stop < 0 {
+			stop += len(want)
+		}
+		if err != nil || !reflect.DeepEqual(got, want[start:stop+1]) {
+			t.Errorf("ListRange(%d, %d) = %v, %v; want %v", r[0], r[1], got, err, want[start:stop+1])
+		}
+	}
+	for len(want) > ListpackMaxEntries {
+		if _, _, err := store.ListPop("queue", true); err != nil {
+			t.Fatalf("ListPop: %v", err)
+		}
+		want = want[1:]
+	}
+	if got := store.Encoding("queue"); got != EncodingListpack {
+		t.Errorf("Shrunk list: encoding %q, want %q", got, EncodingListpack)
+	}
+	if got, err := store.ListRange("queue", 0, -1); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ListRange of the shrunk list = %v, %v", got, err)
+	}
+
+	// Only integers written canonically fit an intset
+	if _, err := store.SetAdd("ids", "3", "-12", "9000000000"); err != nil {
+		t.Fatalf("SetAdd: %v", err)
+	}
+	if _, err := store.SetAdd("padded", "1", "007"); err != nil {
+		t.Fatalf("SetAdd: %v", err)
+	}
+	for key, want := range map[string]string{"ids": EncodingIntset, "padded": EncodingListpack} {
+		if got := store.Encoding(key); got != want {
+			t.Errorf("Encoding(%q) = %q, want %q", key, got, want)
+		}
+	}
+	if members, err := store.SetMembers("padded"); err != nil || !reflect.DeepEqual(members, []string{"007", "1"}) {
+		t.Errorf("SetMembers(padded) = %v, %v", members, err)
+	}
+	for member, want := range map[string]bool{"-12": true, "9000000000": true, "4": false, "03": false, "x": false} {
+		if got, err := store.SetIsMember("ids", member); got != want || err != nil {
+			t.Errorf("SetIsMember(ids, %q) = %v, %v; want %v", member, got, err, want)
+		}
+	}
+
+	// A large set is a hashtable, probed without decoding it
+	members := make([]string, 1000)
+	for i := range members {
+		members[i] = fmt.Sprintf("member-%d", i)
+	}
+	if _, err := store.SetAdd("big", members...); err != nil {
+		t.Fatalf("SetAdd: %v", err)
+	}
+	if got := store.Encoding("big"); got != EncodingHashtable {
+		t.Errorf("Large set: encoding %q, want %q", got, EncodingHashtable)
+	}
+	for _, member := range members {
+		if ok, err := store.SetIsMember("big", member); !ok || err != nil {
+			t.Fatalf("SetIsMember(big, %q) = %v, %v", member, ok, err)
+		}
+	}
+	if ok, err := store.SetIsMember("big", "member-1000"); ok || err != nil {
+		t.Errorf("SetIsMember of a non-member = %v, %v", ok, err)
+	}
+	if n, err := store.SetCard("big"); n != len(members) || err != nil {
+		t.Errorf("SetCard = %d, %v; want %d", n, err, len(members))
+	}
+	sort.Strings(members)
+	if got, err := store.SetMembers("big"); err != nil || !reflect.DeepEqual(got, members) {
+		t.Errorf("SetMembers of the hashtable differ: %v", err)
+	}
+
+	// Lists and sets stored as JSON before encodings existed still read
+	ctx := context.Background()
+	if _, err := store.RestoreRaw(ctx, "old-list", []byte(`["a","b","c"]`), "list", "", 0, 0); err != nil {
+		t.Fatalf("RestoreRaw list: %v", err)
+	}
+	if _, err := store.RestoreRaw(ctx, "old-set", []byte(`["x","y"]`), "set", "", 0, 0); err != nil {
+		t.Fatalf("RestoreRaw set: %v", err)
+	}
+	if got, err := store.ListRange("old-list", 1, -1); err != nil || !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("ListRange of a JSON list = %v, %v", got, err)
+	}
+	if ok, err := store.SetIsMember("old-set", "y"); !ok || err != nil {
+		t.Errorf("SetIsMember of a JSON set = %v, %v", ok, err)
+	}
+	if _, err := store.RestoreRaw(ctx, "bad", []byte("h\x05"), "set", "", 0, 0); err == nil {
+		t.Error("RestoreRaw accepted a truncated hashtable")
+	}
+}
+
+func TestBasicStore_RemovalReasons(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "removal-test",
+		MaxMemory: 8 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Client delete
+	if err := store.Set("doomed", "v", "", 0); err != nil {
+		t.Fatalf("Set doomed: %v", err)
+	}
+	if err := store.Delete("doomed"); err != nil {
+		t.Fatalf("Delete doomed: %v", err)
+	}
+	stats := store.Stats()
+	if stats.ManualDeleteCount != 1 || stats.ExpiredCount != 0 || stats.MemoryEvictionCount != 0 {
+		t.Errorf("After Delete: deleted=%d expired=%d evicted=%d, want 1/0/0", stats.ManualDeleteCount, stats.ExpiredCount, stats.MemoryEvictionCount)
+	}
+
+	// TTL expiry, reclaimed by a read
+	if err := store.Set("fleeting", "v", "", time.Millisecond); err != nil {
+		t.Fatalf("Set fleeting: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := store.Get("fleeting"); err == nil {
+		t.Fatal("Expected expired key to miss")
+	}
+	stats = store.Stats()
+	if stats.ExpiredCount != 1 || stats.ManualDeleteCount != 1 || stats.MemoryEvictionCount != 0 {
+		t.Errorf("After expiry: deleted=%d expired=%d evicted=%d, want 1/1/0", stats.ManualDeleteCount, stats.ExpiredCount, stats.MemoryEvictionCount)
+	}
+
+	// Memory pressure: far more data than fits
+	value := strings.Repeat("x", 200)
+	for i := 0; i < 500; i++ {
+		_ = store.Set(fmt.Sprintf("bulk-%d", i), value, "", 0)
+	}
+	time.Sleep(50 * time.Millisecond)
+	stats = store.Stats()
+	if stats.MemoryEvictionCount == 0 {
+		t.Error("Expected memory evictions to be counted")
+	}
+	if stats.ExpiredCount != 1 || stats.ManualDeleteCount != 1 {
+		t.Errorf("Eviction changed other counters: deleted=%d expired=%d, want 1/1", stats.ManualDeleteCount, stats.ExpiredCount)
+	}
+	if stats.EvictionCount != stats.ExpiredCount+stats.MemoryEvictionCount {
+		t.Errorf("EvictionCount = %d, want expired + evicted = %d", stats.EvictionCount, stats.ExpiredCount+stats.MemoryEvictionCount)
+	}
+}
+
+func TestBasicStore_PinnedKeysSurviveEviction(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "pin-test",
+		MaxMemory: 8 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	value := strings.Repeat("x", 200)
+	var pinned []string
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("config-%d", i)
+		if err := store.SetPinned(key, value, "", 0); err != nil {
+			t.Fatalf("SetPinned %s: %v", key, err)
+		}
+		pinned = append(pinned, key)
+	}
+	if err := store.Set("flag", value, "", 0); err != nil {
+		t.Fatalf("Set flag: %v", err)
+	}
+	if !store.Pin("flag") || !store.IsPinned("flag") {
+		t.Fatal("Expected PIN of an existing key to succeed")
+	}
+	pinned = append(pinned, "flag")
+	if store.Pin("missing") {
+		t.Error("Expected PIN of a missing key to report false")
+	}
+
+	// Overwriting a pinned key keeps it pinned
+	if err := store.Set("flag", value, "", 0); err != nil {
+		t.Fatalf("Overwrite flag: %v", err)
+	}
+	if !store.IsPinned("flag") {
+		t.Error("Expected an overwritten key to stay pinned")
+	}
+
+	// Far more unpinned data than fits: the evictor has to make room
+	for i := 0; i < 500; i++ {
+		_ = store.Set(fmt.Sprintf("bulk-%d", i), value, "", 0)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	for _, key := range pinned {
+		if _, err := store.Get(key); err != nil {
+			t.Errorf("Pinned key %s was evicted: %v", key, err)
+		}
+	}
+	if size := store.Size(); size >= 500 {
+		t.Errorf("Expected unpinned keys to be evicted, store holds %d keys", size)
+	}
+
+	// Once only pinned keys are left, writes fail with OOM instead of evicting them
+	store.Unpin("flag")
+	var oomErr error
+	for i := 0; i < 100 && oomErr == nil; i++ {
+		key := fmt.Sprintf("pinned-%d", i)
+		if oomErr = store.SetPinned(key, value, "", 0); oomErr == nil {
+			pinned = append(pinned, key)
+		}
+	}
+	if !errors.Is(oomErr, ErrOOM) {
+		t.Fatalf("Expected ErrOOM once memory is pinned, got %v", oomErr)
+	}
+	for _, key := range pinned {
+		if key != "flag" && !store.IsPinned(key) {
+			t.Errorf("Pinned key %s was evicted under OOM", key)
+		}
+	}
+}
+
+func TestBasicStore_Statistics(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:             "stats-test",
+		MaxMemory:        1024 * 1024,
+		EnableStatistics: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Test initial stats
+	stats := store.Stats()
+	if stats.TotalItems != 0 {
+		t.Errorf("Initial total items = %v, want 0", stats.TotalItems)
+	}
+	if stats.HitCount != 0 {
+		t.Errorf("Initial hit count = %v, want 0", stats.HitCount)
+	}
+	if stats.MissCount != 0 {
+		t.Errorf("Initial miss count = %v, want 0", stats.MissCount)
+	}
+
+	// Add an item
+	err = store.Set("stats-key", "stats-value", "session1", 0)
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Get the item (hit)
+	_, err = store.Get("stats-key")
+	if err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+
+	// Try to get non-existent item (miss)
+	_, err = store.Get("non-existent")
+	if err == nil {
+		t.Errorf("Get() non-existent should have failed")
+	}
+
+	// Check updated stats
+	stats = store.Stats()
+	if stats.TotalItems != 1 {
+		t.Errorf("Total items = %v, want 1", stats.TotalItems)
+	}
+	if stats.HitCount != 1 {
+		t.Errorf("Hit count = %v, want 1", stats.HitCount)
+	}
+	if stats.MissCount != 1 {
+		t.Errorf("Miss count = %v, want 1", stats.MissCount)
+	}
+
+	// Test hit rate calculation
+	expectedHitRate := 50.0 // 1 hit out of 2 total attempts
+	if stats.HitRate() != expectedHitRate {
+		t.Errorf("Hit rate = %v, want %v", stats.HitRate(), expectedHitRate)
+	}
+}
+
+func TestBasicStore_CleanupExpiredItems(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:            "cleanup-test",
+		MaxMemory:       1024 * 1024,
+		CleanupInterval: 50 * time.Millisecond, // Fast cleanup
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Add items with short TTL
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("expire-key-%d", i)
+		value := fmt.Sprintf("expire-value-%d", i)
+		err = store.Set(key, value, "session1", 75*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	// Verify items exist
+	if store.Size() != 5 {
+		t.Errorf("Store size = %v, want 5", store.Size())
+	}
+
+	// Wait for cleanup to run
+	time.Sleep(150 * time.Millisecond)
+
+	// Items should be cleaned up
+	if store.Size() != 0 {
+		t.Errorf("Store size after cleanup = %v, want 0", store.Size())
+	}
+}
+
+func TestBasicStore_MockClockExpiry(t *testing.T) {
+	clock := NewMockClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "clock-test",
+		MaxMemory: 1024 * 1024,
+		Clock:     clock,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Set("session", "value", "", time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if ttl, ok := store.TTL("session"); !ok || ttl != time.Hour {
+		t.Errorf("TTL() = %v, %v, want exactly 1h on a stopped clock", ttl, ok)
+	}
+
+	clock.Advance(59 * time.Minute)
+	if _, err := store.Get("session"); err != nil {
+		t.Errorf("Get() a minute before expiry error = %v", err)
+	}
+	if ttl, _ := store.TTL("session"); ttl != time.Minute {
+		t.Errorf("TTL() after 59m = %v, want 1m", ttl)
+	}
+
+	clock.Advance(time.Minute + time.Nanosecond)
+	if _, err := store.Get("session"); err == nil {
+		t.Error("Expected Get() to miss once virtual time passes the TTL")
+	}
+	if store.Size() != 0 {
+		t.Errorf("Store size after expiry = %d, want 0", store.Size())
+	}
+}
+
+func TestBasicStore_SetActiveExpire(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:            "active-expire-toggle-test",
+		MaxMemory:       1024 * 1024,
+		CleanupInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.SetActiveExpire(false)
+	if err := store.Set("lapsed", "value", "", 20*time.Millisecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	time.Sleep(80 * time.Millisecond) // several sweeper intervals past the TTL
+
+	// Expired keys read as missing but are neither swept nor reclaimed by reads
+	if _, err := store.Get("lapsed"); err == nil {
+		t.Error("Expected Get of an expired key to miss")
+	}
+	if store.Has("lapsed") {
+		t.Error("Expected Has of an expired key to report false")
+	}
+	if store.Size() != 1 {
+		t.Errorf("Store size with active expire off = %d, want 1 (the expired key)", store.Size())
+	}
+
+	if removed := store.SweepExpired(); removed != 1 {
+		t.Errorf("SweepExpired() = %d, want 1", removed)
+	}
+	if store.Size() != 0 {
+		t.Errorf("Store size after SweepExpired = %d, want 0", store.Size())
+	}
+
+	// Re-enabled: a read reclaims an expired key again
+	store.SetActiveExpire(true)
+	if err := store.Set("lapsed", "value", "", time.Millisecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	store.Get("lapsed")
+	if store.Size() != 0 {
+		t.Errorf("Store size after reading an expired key = %d, want 0", store.Size())
+	}
+}
+
+func TestBasicStore_ActiveExpireSampling(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "active-expire-test",
+		MaxMemory: 10 * 1024 * 1024,
+		// No CleanupInterval: cycles are driven manually below
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	const total = 2000
+	for i := 0; i < total; i++ {
+		if err := store.Set(fmt.Sprintf("ttl-key-%d", i), "value", "session1", 20*time.Millisecond); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		store.Set(fmt.Sprintf("keep-key-%d", i), "value", "session1", 0)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	maxPerCycle := activeExpireSampleSize * activeExpireMaxRounds
+	cycles := 0
+	for store.Size() > 10 && cycles < 1000 {
+		sampled, _, _ := store.activeExpireCycle()
+		if sampled > maxPerCycle {
+			t.Fatalf("Cycle sampled %d keys, want at most %d", sampled, maxPerCycle)
+		}
+		cycles++
+	}
+
+	if store.Size() != 10 {
+		t.Errorf("Store size after active expiration = %v, want 10", store.Size())
+	}
+	if cycles < 2 {
+		t.Errorf("Expected reclamation to span several bounded cycles, took %d", cycles)
+	}
+}
+
+func TestBasicStore_LazyFree(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "lazy-free-test",
+		MaxMemory: 16 * 1024 * 1024,
+		LazyFree:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	large := strings.Repeat("x", 2*1024*1024)
+	if err := store.Set("big", large, "session1", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if store.memPool.CurrentUsage() == 0 {
+		t.Fatal("Expected memory usage after storing large value")
+	}
+
+	if err := store.Delete("big"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	// The key must be invisible as soon as Delete returns
+	if store.Has("big") {
+		t.Error("Deleted key still visible")
+	}
+	if _, err := store.Get("big"); err == nil {
+		t.Error("Get() on deleted key should fail")
+	}
+
+	// Memory is eventually returned to the pool by the background goroutine
+	deadline := time.Now().Add(time.Second)
+	for store.memPool.CurrentUsage() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if usage := store.memPool.CurrentUsage(); usage != 0 {
+		t.Errorf("Memory usage after lazy free = %v, want 0", usage)
+	}
+
+	// Overwrites reclaim the old value the same way, small values stay inline
+	store.Set("big", large, "session1", 0)
+	store.Set("big", "small", "session1", 0)
+	deadline = time.Now().Add(time.Second)
+	want := int64(len("small") + PerKeyOverhead)
+	for store.memPool.CurrentUsage() != want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if usage := store.memPool.CurrentUsage(); usage != want {
+		t.Errorf("Memory usage after overwrite = %v, want %v", usage, want)
+	}
+}
+
+func TestBasicStore_LazyFreeOffRequestPath(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "lazy-free-blocked-test",
+		MaxMemory: 16 * 1024 * 1024,
+		LazyFree:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Park the lazy free goroutine so nothing drains the queue until it's
+	// restarted below
+	close(store.lazyFreeStop)
+	<-store.lazyFreeDone
+	store.lazyFreeStop = make(chan struct{})
+	store.lazyFreeDone = make(chan struct{})
+
+	large := strings.Repeat("x", 2*1024*1024)
+	if err := store.Set("big", large, "session1", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	accounted := store.memPool.CurrentUsage()
+
+	deleted := make(chan error, 1)
+	go func() { deleted <- store.Delete("big") }()
+	select {
+	case err := <-deleted:
+		if err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Delete() blocked on the lazy free goroutine")
+	}
+
+	// Delete returned with the key gone but its memory not yet reclaimed
+	if store.Has("big") {
+		t.Error("Deleted key still visible")
+	}
+	if usage := store.memPool.CurrentUsage(); usage != accounted {
+		t.Errorf("Memory usage before lazy free = %v, want %v", usage, accounted)
+	}
+
+	go store.backgroundLazyFree()
+	deadline := time.Now().Add(time.Second)
+	for store.memPool.CurrentUsage() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if usage := store.memPool.CurrentUsage(); usage != 0 {
+		t.Errorf("Memory usage after lazy free = %v, want 0", usage)
+	}
+}
+
+func TestBasicStore_InternValues(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:         "intern-test",
+		MaxMemory:    16 * 1024 * 1024,
+		InternValues: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	blob := strings.Repeat("config blob ", 64*1024/12)
+	for i := 0; i < 100; i++ {
+		if err := store.Set(fmt.Sprintf("tenant-%d", i), blob, "", 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	// One stored copy plus each key's overhead
+	want := int64(len(blob) + 100*PerKeyOverhead)
+	if usage := store.memPool.CurrentUsage(); usage != want {
+		t.Errorf("Memory usage with 100 identical values = %d, want %d (one copy)", usage, want)
+	}
+	if refs := store.RefCount("tenant-0"); refs != 100 {
+		t.Errorf("RefCount() = %d, want 100", refs)
+	}
+	if got, err := store.Get("tenant-99"); err != nil || got != blob {
+		t.Errorf("Get() of a shared value = %.20q, %v", got, err)
+	}
+
+	// The copy survives until the last key holding it is gone
+	for i := 0; i < 99; i++ {
+		if err := store.Delete(fmt.Sprintf("tenant-%d", i)); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	}
+	if err := store.Set("tenant-99", "own value", "", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if refs := store.RefCount("tenant-99"); refs != 1 {
+		t.Errorf("RefCount() after overwrite = %d, want 1", refs)
+	}
+	if usage, want := store.memPool.CurrentUsage(), int64(len("own value")+PerKeyOverhead); usage != want {
+		t.Errorf("Memory usage after releasing every reference = %d, want %d", usage, want)
+	}
+	if len(store.interner.byPtr) != 0 {
+		t.Errorf("Interner still tracks %d values", len(store.interner.byPtr))
+	}
+}
+
+func TestBasicStore_MemoryUsage(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "memory-usage-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.Set("small", "x", "session1", 0)
+	store.Set("large", strings.Repeat("x", 4096), "session1", 0)
+
+	small, ok := store.MemoryUsage("small")
+	if !ok {
+		t.Fatal("MemoryUsage(small) reported missing key")
+	}
+	large, ok := store.MemoryUsage("large")
+	if !ok {
+		t.Fatal("MemoryUsage(large) reported missing key")
+	}
+
+	if large <= small {
+		t.Errorf("MemoryUsage(large) = %v, want more than MemoryUsage(small) = %v", large, small)
+	}
+	if want := uint64(1 + len("small") + PerKeyOverhead); small != want {
+		t.Errorf("MemoryUsage(small) = %v, want %v", small, want)
+	}
+
+	if _, ok := store.MemoryUsage("missing"); ok {
+		t.Error("MemoryUsage(missing) should report false")
+	}
+}
+
+func TestBasicStore_TTLHistogram(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "test-store",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ttls := map[string]time.Duration{
+		"a": 30 * time.Second,
+		"b": 45 * time.Second,
+		"c": 10 * time.Minute,
+		"d": 2 * time.Hour,
+		"e": 48 * time.Hour,
+		"f": 0,
+		"g": 0,
+		"h": 0,
+	}
+	for key, ttl := range ttls {
+		if err := store.Set(key, "v", "", ttl); err != nil {
+			t.Fatalf("Set(%s) error = %v", key, err)
+		}
+	}
+	if err := store.Set("gone", "v", "", time.Millisecond); err != nil {
+		t.Fatalf("Set(gone) error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	want := map[string]uint64{
+		TTLBucketUnder1m:  2,
+		TTLBucketUnder1h:  1,
+		TTLBucketUnder1d:  1,
+		TTLBucketOver1d:   1,
+		TTLBucketNoExpiry: 3,
+	}
+	if got := store.TTLHistogram(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TTLHistogram() = %v, want %v", got, want)
+	}
+}
+
+func TestBasicStore_RecentHitRate(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:          "test-store",
+		MaxMemory:     1024 * 1024,
+		HitRateWindow: 3 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Cold start: every read misses and fills the key
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if _, err := store.Get(key); err == nil {
+			t.Fatalf("Get(%s) hit on a cold cache", key)
+		}
+		if err := store.Set(key, "v", "", 0); err != nil {
+			t.Fatalf("Set(%s) error = %v", key, err)
+		}
+	}
+	if got := store.RecentHitRate(); got != 0 {
+		t.Errorf("RecentHitRate() while cold = %.1f, want 0", got)
+	}
+
+	// The cold second rolls out of the window (the sampler's ticks, made
+	// directly instead of waiting for them)
+	for i := 0; i < 3; i++ {
+		store.hitWindow.record(store.data.ReadCounts())
+	}
+
+	// Warm: every read hits
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 100; i++ {
+			if _, err := store.Get(fmt.Sprintf("key-%d", i)); err != nil {
+				t.Fatalf("Get(key-%d) error = %v", i, err)
+			}
+		}
+	}
+
+	lifetime := store.Stats()
+	if got := lifetime.HitRate(); got != 75 {
+		t.Errorf("Lifetime HitRate() = %.1f, want 75 (still counting the cold misses)", got)
+	}
+	if got := store.RecentHitRate(); got != 100 {
+		t.Errorf("RecentHitRate() after warming = %.1f, want 100", got)
+	}
+}
+
+func TestBasicStore_TopKeys(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "top-keys-test",
+		MaxMemory: 10 * 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 200; i++ {
+		store.Set(fmt.Sprintf("small-%d", i), strings.Repeat("s", 10+i), "session1", 0)
+	}
+	store.Set("big-1", strings.Repeat("b", 50000), "session1", time.Hour)
+	store.Set("big-2", strings.Repeat("b", 40000), "session1", 0)
+	store.Set("big-3", strings.Repeat("b", 30000), "session1", 0)
+
+	for _, samples := range []int{0, 1000} {
+		top := store.TopKeys(3, samples)
+		if len(top) != 3 {
+			t.Fatalf("TopKeys(3, %d) returned %d keys, want 3", samples, len(top))
+		}
+		for i, want := range []string{"big-1", "big-2", "big-3"} {
+			if top[i].Key != want {
+				t.Errorf("TopKeys(3, %d)[%d] = %s, want %s", samples, i, top[i].Key, want)
+			}
+		}
+		if top[0].TTL <= 0 || top[1].TTL != 0 {
+			t.Errorf("TopKeys TTLs = %v, %v; want positive then zero", top[0].TTL, top[1].TTL)
+		}
+	}
+}
+
+func TestBasicStore_HasDoesNotTouchAccessStats(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "test-store",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Set("key1", "value1", "session1", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := store.Set("short-lived", "value", "session1", 50*time.Millisecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	item, _ := store.data.Get("key1")
+	lastAccessed := item.LastAccessed()
+
+	if !store.Has("key1") {
+		t.Error("Has(key1) = false, want true")
+	}
+	if store.Has("missing") {
+		t.Error("Has(missing) = true, want false")
+	}
+	if store.Has("") {
+		t.Error("Has(\"\") = true, want false")
+	}
+
+	if item.AccessCount() != 0 {
+		t.Errorf("AccessCount after Has = %v, want 0", item.AccessCount())
+	}
+	if !item.LastAccessed().Equal(lastAccessed) {
+		t.Errorf("LastAccessed changed by Has")
+	}
+
+	stats := store.Stats()
+	if stats.HitCount != 0 || stats.MissCount != 0 {
+		t.Errorf("Has touched hit/miss counters: hits=%v misses=%v", stats.HitCount, stats.MissCount)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if store.Has("short-lived") {
+		t.Error("Has(short-lived) = true after expiry, want false")
+	}
+}
+
+// Benchmark tests
+func BenchmarkBasicStore_Set(b *testing.B) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "benchmark-store",
+		MaxMemory: 100 * 1024 * 1024, // 100MB
+	})
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		value := fmt.Sprintf("value-%d", i)
+		store.Set(key, value, "session1", 0)
+	}
+}
+
+func BenchmarkBasicStore_Get(b *testing.B) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "benchmark-store",
+		MaxMemory: 100 * 1024 * 1024, // 100MB
+	})
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Pre-populate store
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		value := fmt.Sprintf("value-%d", i)
 		store.Set(key, value, "session1", 0)
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		key := fmt.Sprintf("key-%d", i%1000)
-		store.Get(key)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := fmt.Sprintf("key-%d", i%1000)
+		store.Get(key)
+	}
+}
+
+func BenchmarkBasicStore_ConcurrentSetGet(b *testing.B) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "benchmark-store",
+		MaxMemory: 100 * 1024 * 1024, // 100MB
+	})
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%2 == 0 {
+				key := fmt.Sprintf("key-%d", i)
+				value := fmt.Sprintf("value-%d", i)
+				store.Set(key, value, "session1", 0)
+			} else {
+				key := fmt.Sprintf("key-%d", i-1)
+				store.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+// BenchmarkBasicStore_ParallelGetHotKeys measures read hits on a small set of
+// hot keys from many goroutines, where per-read bookkeeping contends most
+func BenchmarkBasicStore_ParallelGetHotKeys(b *testing.B) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "benchmark-store",
+		MaxMemory: 100 * 1024 * 1024, // 100MB
+	})
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	keys := make([]string, 8)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("hot-%d", i)
+		if err := store.Set(keys[i], "value", "session1", 0); err != nil {
+			b.Fatalf("Failed to set key: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			store.Get(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+func BenchmarkBasicStore_HasLargeValue(b *testing.B) {
+	store := newLargeValueBenchStore(b)
+	defer store.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Has("large-key")
+	}
+}
+
+func BenchmarkBasicStore_GetLargeValue(b *testing.B) {
+	store := newLargeValueBenchStore(b)
+	defer store.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Get("large-key")
+	}
+}
+
+func BenchmarkBasicStore_GetRawLargeValue(b *testing.B) {
+	store := newLargeValueBenchStore(b)
+	defer store.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.GetRaw("large-key")
+	}
+}
+
+func BenchmarkBasicStore_GetRawBytesLargeValue(b *testing.B) {
+	store := newLargeValueBenchStore(b)
+	defer store.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.GetRawBytes("large-key")
+	}
+}
+
+// newLargeValueBenchStore creates a store holding a single 64KB value
+func newLargeValueBenchStore(b *testing.B) *BasicStore {
+	b.Helper()
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "benchmark-store",
+		MaxMemory: 100 * 1024 * 1024, // 100MB
+	})
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Set("large-key", make([]byte, 64*1024), "session1", 0); err != nil {
+		b.Fatalf("Failed to set large value: %v", err)
+	}
+	return store
+}
+
+func TestBasicStore_NoExpiryOverridesDefaultTTL(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:       "no-expiry-test",
+		MaxMemory:  1024 * 1024,
+		DefaultTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if got := store.DefaultTTL(); got != time.Hour {
+		t.Errorf("DefaultTTL() = %v, want 1h", got)
+	}
+
+	if err := store.Set("default", "v", "", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if ttl, ok := store.TTL("default"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL(default) = %v, %v; want the 1h default", ttl, ok)
+	}
+
+	if err := store.Set("forever", "v", "", NoExpiry); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if ttl, ok := store.TTL("forever"); !ok || ttl != -1 {
+		t.Errorf("TTL(forever) = %v, %v; want no expiry", ttl, ok)
+	}
+}
+
+func TestBasicStore_OverwriteTTL(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{Name: "overwrite-ttl-test", MaxMemory: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// A plain overwrite clears the expiry, as Redis's SET does
+	store.Set("cleared", "v1", "", time.Hour)
+	store.Set("cleared", "v2", "", 0)
+	if ttl, ok := store.TTL("cleared"); !ok || ttl != -1 {
+		t.Errorf("TTL after plain overwrite = %v, %v; want no expiry", ttl, ok)
+	}
+
+	// KeepTTL keeps it, and the new value
+	store.Set("kept", "v1", "", time.Hour)
+	if err := store.Set("kept", "v2", "", KeepTTL); err != nil {
+		t.Fatalf("Set(KeepTTL) error = %v", err)
+	}
+	if ttl, ok := store.TTL("kept"); !ok || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL after KeepTTL overwrite = %v, %v; want about 1h", ttl, ok)
+	}
+	if value, _ := store.Get("kept"); value != "v2" {
+		t.Errorf("Get after KeepTTL overwrite = %v, want v2", value)
+	}
+
+	// A key without an expiry stays without one
+	store.Set("forever", "v1", "", 0)
+	store.Set("forever", "v2", "", KeepTTL)
+	if ttl, ok := store.TTL("forever"); !ok || ttl != -1 {
+		t.Errorf("TTL after KeepTTL overwrite of a persistent key = %v, %v; want no expiry", ttl, ok)
+	}
+
+	// An explicit TTL or Expire after the overwrite still replaces it
+	store.Set("kept", "v3", "", time.Minute)
+	if ttl, _ := store.TTL("kept"); ttl > time.Minute {
+		t.Errorf("TTL after overwrite with a TTL = %v, want at most 1m", ttl)
+	}
+	store.Set("kept", "v4", "", KeepTTL)
+	store.Expire("kept", 10*time.Second)
+	if ttl, _ := store.TTL("kept"); ttl > 10*time.Second {
+		t.Errorf("TTL after Expire = %v, want at most 10s", ttl)
+	}
+}
+
+func TestBasicStore_KeepTTLOnMissingKeyUsesDefault(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:       "keepttl-default-test",
+		MaxMemory:  1024 * 1024,
+		DefaultTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.Set("new", "v", "", KeepTTL)
+	if ttl, ok := store.TTL("new"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL(new) = %v, %v; want the 1h default", ttl, ok)
+	}
+
+	// A plain overwrite applies the default afresh; KeepTTL doesn't
+	store.Set("persistent", "v", "", NoExpiry)
+	store.Set("persistent", "v", "", KeepTTL)
+	if ttl, _ := store.TTL("persistent"); ttl != -1 {
+		t.Errorf("TTL after KeepTTL overwrite = %v, want no expiry", ttl)
+	}
+	store.Set("persistent", "v", "", 0)
+	if ttl, _ := store.TTL("persistent"); ttl <= 0 {
+		t.Errorf("TTL after plain overwrite = %v, want the 1h default", ttl)
+	}
+}
+
+func TestBasicStore_TTLJitter(t *testing.T) {
+	const (
+		numKeys = 1000
+		ttl     = time.Hour
+		jitter  = 0.1
+		buckets = 10
+	)
+	window := time.Duration(float64(ttl) * jitter)
+
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "ttl-jitter-test",
+		MaxMemory: 16 * 1024 * 1024,
+		TTLJitter: jitter,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	start := time.Now()
+	for i := 0; i < numKeys; i++ {
+		if err := store.Set(fmt.Sprintf("key%d", i), "v", "session1", ttl); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	end := time.Now()
+
+	// Every expiry must land within [start+ttl, end+ttl+window)
+	var counts [buckets]int
+	for i := 0; i < numKeys; i++ {
+		item, ok := store.data.Get(fmt.Sprintf("key%d", i))
+		if !ok {
+			t.Fatalf("key%d missing", i)
+		}
+		if item.ExpiresAt.Before(start.Add(ttl)) || !item.ExpiresAt.Before(end.Add(ttl+window)) {
+			t.Fatalf("key%d expiry %v outside jitter window", i, item.ExpiresAt.Sub(start))
+		}
+		offset := item.ExpiresAt.Sub(start.Add(ttl))
+		b := int(offset * buckets / window)
+		if b >= buckets {
+			b = buckets - 1
+		}
+		counts[b]++
+	}
+
+	// Expiries should be spread across the whole window, not clumped together
+	for i, c := range counts {
+		if c == 0 {
+			t.Errorf("No keys expire in jitter bucket %d: %v", i, counts)
+		}
+	}
+
+	// TTLJitterMax bounds the absolute jitter regardless of the fraction
+	capped, err := NewBasicStore(BasicStoreConfig{
+		Name:         "ttl-jitter-cap-test",
+		MaxMemory:    16 * 1024 * 1024,
+		TTLJitter:    1,
+		TTLJitterMax: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer capped.Close()
+
+	for i := 0; i < 100; i++ {
+		if got := capped.jitterTTL(ttl); got < ttl || got >= ttl+time.Second {
+			t.Fatalf("jitterTTL(%v) = %v, want within [%v, %v)", ttl, got, ttl, ttl+time.Second)
+		}
+	}
+}
+
+func TestBasicStore_ListsAndTypeCheck(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "list-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if n, err := store.ListPush("queue", false, "b", "c"); err != nil || n != 2 {
+		t.Fatalf("RPUSH: got (%d, %v), want (2, nil)", n, err)
+	}
+	if n, err := store.ListPush("queue", true, "a0", "a"); err != nil || n != 4 {
+		t.Fatalf("LPUSH: got (%d, %v), want (4, nil)", n, err)
+	}
+
+	got, err := store.ListRange("queue", 0, -1)
+	if err != nil {
+		t.Fatalf("ListRange error = %v", err)
+	}
+	if want := []string{"a", "a0", "b", "c"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ListRange = %v, want %v", got, want)
+	}
+	if got, _ := store.ListRange("queue", -2, 100); fmt.Sprint(got) != "[b c]" {
+		t.Errorf("ListRange(-2, 100) = %v, want [b c]", got)
+	}
+
+	if elem, ok, _ := store.ListPop("queue", false); !ok || elem != "c" {
+		t.Errorf("RPOP = %q, want c", elem)
+	}
+	if store.Type("queue") != TypeList {
+		t.Errorf("Type(queue) = %s, want list", store.Type("queue"))
+	}
+
+	// Popping the last element removes the key
+	for i := 0; i < 3; i++ {
+		store.ListPop("queue", true)
+	}
+	if store.Type("queue") != TypeNone {
+		t.Errorf("Expected empty list to be deleted, got type %s", store.Type("queue"))
+	}
+
+	// Cross-type access is rejected
+	store.Set("str", "value", "session1", 0)
+	if _, err := store.ListPush("str", true, "x"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("ListPush on string key: expected ErrWrongType, got %v", err)
+	}
+	if err := store.CheckType("str", TypeList); !errors.Is(err, ErrWrongType) {
+		t.Errorf("CheckType(str, list): expected ErrWrongType, got %v", err)
+	}
+	if err := store.CheckType("missing", TypeList); err != nil {
+		t.Errorf("CheckType on missing key should pass, got %v", err)
+	}
+}
+
+func TestBasicStore_ListPushDoesNotOverwriteConcurrentSet(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "list-race-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// A SET always replaces the key, so whichever order the two land in the
+	// string must survive: a push before it is overwritten, one after it
+	// fails with WRONGTYPE
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("race-%d", i)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			store.Set(key, "value", "", 0)
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := store.ListPush(key, false, "elem"); err != nil && !errors.Is(err, ErrWrongType) {
+				t.Errorf("ListPush(%s) error = %v", key, err)
+			}
+		}()
+		wg.Wait()
+
+		if got, err := store.Get(key); err != nil || got != "value" {
+			t.Fatalf("Get(%s) = (%v, %v), want the concurrent SET's value", key, got, err)
+		}
+	}
+}
+
+func TestBasicStore_ListPushKeepsSessionAndTTL(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "list-meta-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	raw, valueType, err := serializeValue(ListValue{"a"})
+	if err != nil {
+		t.Fatalf("serializeValue error = %v", err)
+	}
+	if _, err := store.RestoreRaw(context.Background(), "queue", raw, valueType, "sess-1", time.Hour, 1); err != nil {
+		t.Fatalf("RestoreRaw error = %v", err)
+	}
+
+	if _, err := store.ListPush("queue", false, "b"); err != nil {
+		t.Fatalf("RPUSH error = %v", err)
+	}
+	if _, _, err := store.ListPop("queue", true); err != nil {
+		t.Fatalf("LPOP error = %v", err)
+	}
+
+	exported, ok := store.Export("queue")
+	if !ok {
+		t.Fatal("Expected queue to exist")
+	}
+	if exported.SessionID != "sess-1" {
+		t.Errorf("SessionID = %q, want sess-1", exported.SessionID)
+	}
+	if exported.TTL <= 0 || exported.TTL > time.Hour {
+		t.Errorf("TTL = %v, want the original hour's remainder", exported.TTL)
+	}
+}
+
+func TestBasicStore_SetAlgebra(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "set-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.SetAdd("tags:a", "go", "cache", "redis", "db")
+	store.SetAdd("tags:b", "cache", "redis", "queue")
+	store.SetAdd("tags:c", "redis", "cache", "lua")
+
+	if n, _ := store.SetAdd("tags:a", "go", "new"); n != 1 {
+		t.Errorf("SetAdd with one duplicate: added %d, want 1", n)
+	}
+
+	inter, err := store.SetCombine(SetOpInter, "tags:a", "tags:b", "tags:c")
+	if err != nil {
+		t.Fatalf("SetCombine(inter) error = %v", err)
+	}
+	if fmt.Sprint(inter) != "[cache redis]" {
+		t.Errorf("Intersection = %v, want [cache redis]", inter)
+	}
+
+	diff, _ := store.SetCombine(SetOpDiff, "tags:a", "tags:b")
+	if fmt.Sprint(diff) != "[db go new]" {
+		t.Errorf("Difference = %v, want [db go new]", diff)
+	}
+
+	// Intersecting with a missing key yields the empty set
+	if empty, _ := store.SetCombine(SetOpInter, "tags:a", "missing"); len(empty) != 0 {
+		t.Errorf("Intersection with missing key = %v, want empty", empty)
+	}
+
+	n, err := store.SetCombineStore(SetOpUnion, "tags:all", "tags:a", "tags:b", "tags:c")
+	if err != nil || n != 7 {
+		t.Fatalf("SetCombineStore(union) = (%d, %v), want (7, nil)", n, err)
+	}
+	if members, _ := store.SetMembers("tags:all"); fmt.Sprint(members) != "[cache db go lua new queue redis]" {
+		t.Errorf("Stored union = %v", members)
+	}
+	if store.Type("tags:all") != TypeSet {
+		t.Errorf("Type(tags:all) = %s, want set", store.Type("tags:all"))
+	}
+
+	// An empty result removes the destination
+	if n, _ := store.SetCombineStore(SetOpInter, "tags:all", "tags:b", "missing"); n != 0 || store.Type("tags:all") != TypeNone {
+		t.Errorf("Empty STORE result should delete destination, got size %d type %s", n, store.Type("tags:all"))
+	}
+
+	store.Set("str", "value", "session1", 0)
+	if _, err := store.SetCombine(SetOpUnion, "tags:a", "str"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("SetCombine with string key: expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestBasicStore_Sort(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "sort-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.ListPush("scores", false, "10", "2", "33", "-1", "2.5")
+	store.SetAdd("names", "bob", "alice", "carol", "10")
+
+	tests := []struct {
+		name string
+		key  string
+		opts SortOptions
+		want string
+	}{
+		{"numeric asc", "scores", SortOptions{Count: -1}, "[-1 2 2.5 10 33]"},
+		{"numeric desc", "scores", SortOptions{Desc: true, Count: -1}, "[33 10 2.5 2 -1]"},
+		{"alpha on numbers", "scores", SortOptions{Alpha: true, Count: -1}, "[-1 10 2 2.5 33]"},
+		{"alpha set", "names", SortOptions{Alpha: true, Count: -1}, "[10 alice bob carol]"},
+		{"limit window", "scores", SortOptions{Offset: 1, Count: 2}, "[2 2.5]"},
+		{"limit past end", "scores", SortOptions{Offset: 4, Count: 10}, "[33]"},
+		{"offset beyond size", "scores", SortOptions{Offset: 10, Count: 1}, "[]"},
+		{"missing key", "missing", SortOptions{Count: -1}, "[]"},
+	}
+	for _, tt := range tests {
+		got, err := store.Sort(tt.key, tt.opts)
+		if err != nil {
+			t.Errorf("%s: Sort error = %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("%s: Sort = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Numeric sort on non-numeric members fails unless ALPHA is given
+	if _, err := store.Sort("names", SortOptions{Count: -1}); !errors.Is(err, ErrSortNotNumeric) {
+		t.Errorf("Numeric sort of names: expected ErrSortNotNumeric, got %v", err)
+	}
+
+	// Sort is read-only
+	if list, _ := store.ListRange("scores", 0, -1); fmt.Sprint(list) != "[10 2 33 -1 2.5]" {
+		t.Errorf("Sort modified the stored list: %v", list)
+	}
+
+	store.Set("str", "value", "session1", 0)
+	if _, err := store.Sort("str", SortOptions{Count: -1}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Sort on string: expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestBasicStore_Bitmap(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "bitmap-test",
+		MaxMemory: 16 * 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Sparse bits, the last one far enough out to grow the value to ~1MB
+	offsets := []uint64{0, 7, 8, 100, 65535, 8_000_000}
+	for _, off := range offsets {
+		prev, err := store.SetBit("dau", off, true)
+		if err != nil {
+			t.Fatalf("SetBit(%d) error = %v", off, err)
+		}
+		if prev != 0 {
+			t.Errorf("SetBit(%d) previous = %d, want 0", off, prev)
+		}
+	}
+	if prev, _ := store.SetBit("dau", 100, true); prev != 1 {
+		t.Errorf("SetBit on a set bit: previous = %d, want 1", prev)
+	}
+
+	raw, _, err := store.GetRawBytes("dau")
+	if err != nil {
+		t.Fatalf("GetRawBytes error = %v", err)
+	}
+	if want := 8_000_000/8 + 1; len(raw) != want {
+		t.Errorf("Bitmap length = %d, want %d", len(raw), want)
+	}
+
+	for _, off := range offsets {
+		if bit, _ := store.GetBit("dau", off); bit != 1 {
+			t.Errorf("GetBit(%d) = %d, want 1", off, bit)
+		}
+	}
+	if bit, _ := store.GetBit("dau", 1); bit != 0 {
+		t.Errorf("GetBit(1) = %d, want 0", bit)
+	}
+	if bit, _ := store.GetBit("dau", MaxBitOffset); bit != 0 {
+		t.Errorf("GetBit past end = %d, want 0", bit)
+	}
+
+	tests := []struct {
+		start, end int
+		want       int
+	}{
+		{0, -1, 6},
+		{0, 0, 2},  // bits 0 and 7
+		{1, 12, 2}, // bits 8 and 100
+		{-1, -1, 1},
+		{13, 8191, 1}, // bit 65535
+		{5, 2, 0},
+	}
+	for _, tt := range tests {
+		if got, _ := store.BitCount("dau", tt.start, tt.end); got != tt.want {
+			t.Errorf("BitCount(%d, %d) = %d, want %d", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	// Clearing returns the old bit and lowers the count
+	if prev, _ := store.SetBit("dau", 7, false); prev != 1 {
+		t.Errorf("Clearing bit 7: previous = %d, want 1", prev)
+	}
+	if got, _ := store.BitCount("dau", 0, -1); got != 5 {
+		t.Errorf("BitCount after clear = %d, want 5", got)
+	}
+
+	// Existing strings are treated as raw bytes: "a" is 0x61
+	store.Set("str", "a", "session1", 0)
+	if got, _ := store.BitCount("str", 0, -1); got != 3 {
+		t.Errorf("BitCount(\"a\") = %d, want 3", got)
+	}
+
+	if _, err := store.SetBit("dau", MaxBitOffset+1, true); !errors.Is(err, ErrBitOffset) {
+		t.Errorf("SetBit past max offset: expected ErrBitOffset, got %v", err)
+	}
+	store.ListPush("list", false, "x")
+	if _, err := store.GetBit("list", 0); !errors.Is(err, ErrWrongType) {
+		t.Errorf("GetBit on list: expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestBasicStore_HyperLogLog(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "hll-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	const n = 100000
+	elements := make([]string, 0, 1000)
+	for i := 0; i < n; i++ {
+		elements = append(elements, fmt.Sprintf("user:%d", i))
+		if len(elements) == cap(elements) {
+			if _, err := store.PFAdd("visitors", elements...); err != nil {
+				t.Fatalf("PFAdd error = %v", err)
+			}
+			elements = elements[:0]
+		}
+	}
+
+	count, err := store.PFCount("visitors")
+	if err != nil {
+		t.Fatalf("PFCount error = %v", err)
+	}
+	if diff := math.Abs(float64(count)-n) / n; diff > 0.03 {
+		t.Errorf("PFCount = %d, want %d within 3%% (off by %.2f%%)", count, n, diff*100)
+	}
+
+	// Re-adding a known element leaves the sketch unchanged
+	if changed, _ := store.PFAdd("visitors", "user:42"); changed {
+		t.Error("PFAdd of an existing element should report no change")
+	}
+	// Creating an empty sketch counts as a change
+	if changed, _ := store.PFAdd("empty"); !changed {
+		t.Error("PFAdd creating a key should report a change")
+	}
+	if count, _ := store.PFCount("empty", "missing"); count != 0 {
+		t.Errorf("PFCount of empty sketches = %d, want 0", count)
+	}
+
+	// Small cardinalities are exact enough to compare directly
+	store.PFAdd("a", "x", "y", "z")
+	store.PFAdd("b", "y", "z", "w")
+	if count, _ := store.PFCount("a", "b"); count != 4 {
+		t.Errorf("PFCount(a, b) = %d, want 4", count)
+	}
+	if err := store.PFMerge("ab", "a", "b"); err != nil {
+		t.Fatalf("PFMerge error = %v", err)
+	}
+	if count, _ := store.PFCount("ab"); count != 4 {
+		t.Errorf("PFCount(ab) = %d, want 4", count)
+	}
+
+	store.Set("str", "plain", "session1", 0)
+	if _, err := store.PFAdd("str", "x"); !errors.Is(err, ErrNotHyperLogLog) {
+		t.Errorf("PFAdd on a plain string: expected ErrNotHyperLogLog, got %v", err)
+	}
+	store.SetAdd("set", "x")
+	if _, err := store.PFCount("set"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("PFCount on a set: expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestBasicStore_LoadShedding(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:         "shed-test",
+		MaxMemory:    100 * 1024,
+		LoadShedding: true,
+		ShedLowWater: 0.5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := store.Set(fmt.Sprintf("key%d", i), strings.Repeat("x", 100), "session1", 0); err != nil {
+			t.Fatalf("Set key%d: %v", i, err)
+		}
+	}
+	// One large write takes the pool straight past panic pressure
+	if err := store.Set("big", strings.Repeat("x", 95000), "session1", 0); err != nil {
+		t.Fatalf("Set big: %v", err)
+	}
+
+	// The pressure handler runs asynchronously
+	deadline := time.Now().Add(time.Second)
+	for !store.IsShedding() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !store.IsShedding() {
+		t.Fatal("Store did not enter shedding state at high pressure")
+	}
+
+	if err := store.Set("new", "v", "session1", 0); !errors.Is(err, ErrOOM) {
+		t.Errorf("Set while shedding: expected ErrOOM, got %v", err)
+	}
+	if _, err := store.ListPush("list", false, "v"); !errors.Is(err, ErrOOM) {
+		t.Errorf("ListPush while shedding: expected ErrOOM, got %v", err)
+	}
+	// Reads still work, and nothing was evicted to make room
+	if _, err := store.Get("key0"); err != nil {
+		t.Errorf("Get while shedding: %v", err)
+	}
+	if got := store.Size(); got != 6 {
+		t.Errorf("Store size while shedding = %d, want 6", got)
+	}
+
+	// Freeing memory while still above the low-water mark keeps shedding
+	store.Delete("key0")
+	if err := store.Set("new", "v", "session1", 0); !errors.Is(err, ErrOOM) {
+		t.Errorf("Set above low water: expected ErrOOM, got %v", err)
+	}
+
+	// Dropping below the low-water mark resumes writes
+	store.Delete("big")
+	if err := store.Set("new", "v", "session1", 0); err != nil {
+		t.Errorf("Set after memory recovered: %v", err)
+	}
+	if store.IsShedding() {
+		t.Error("Store still shedding after memory recovered")
+	}
+}
+
+func TestBasicStore_CompareAndSet(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "cas-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if ok, err := store.CompareAndSet("lock", []byte("v1"), []byte("v2"), 0); err != nil || ok {
+		t.Errorf("CAS on missing key = %v, %v; want false, nil", ok, err)
+	}
+
+	store.Set("lock", []byte("v1"), "", 0)
+
+	if ok, err := store.CompareAndSet("lock", []byte("stale"), []byte("v2"), 0); err != nil || ok {
+		t.Errorf("CAS with stale value = %v, %v; want false, nil", ok, err)
+	}
+	if raw, _, _ := store.GetRawBytes("lock"); string(raw) != "v1" {
+		t.Errorf("Value after failed CAS = %q, want v1", raw)
+	}
+
+	if ok, err := store.CompareAndSet("lock", []byte("v1"), []byte("v2"), time.Minute); err != nil || !ok {
+		t.Errorf("CAS with current value = %v, %v; want true, nil", ok, err)
+	}
+	if raw, _, _ := store.GetRawBytes("lock"); string(raw) != "v2" {
+		t.Errorf("Value after CAS = %q, want v2", raw)
+	}
+
+	// Concurrent swaps from the same expected value: exactly one wins
+	store.Set("counter", "0", "", 0)
+	var wg sync.WaitGroup
+	var wins int64
+	var mu sync.Mutex
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if ok, _ := store.CompareAndSet("counter", []byte("0"), []byte(fmt.Sprint(i+1)), 0); ok {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Errorf("Concurrent CAS winners = %d, want 1", wins)
+	}
+
+	store.ListPush("list", false, "v1")
+	if _, err := store.CompareAndSet("list", []byte("v1"), []byte("v2"), 0); !errors.Is(err, ErrWrongType) {
+		t.Errorf("CAS on list: expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestBasicStore_WatchVersions(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "watch-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	v := store.WatchKey("k")
+	if store.KeyVersion("k") != v {
+		t.Fatal("Version changed without a mutation")
+	}
+
+	store.Set("other", "x", "", 0)
+	if store.KeyVersion("k") != v {
+		t.Error("Writing another key changed the watched version")
+	}
+
+	store.Set("k", "x", "", 0)
+	v2 := store.KeyVersion("k")
+	if v2 == v {
+		t.Error("Set did not bump the watched version")
+	}
+	store.Delete("k")
+	if store.KeyVersion("k") == v2 {
+		t.Error("Delete did not bump the watched version")
+	}
+
+	v3 := store.KeyVersion("k")
+	store.Clear()
+	if store.KeyVersion("k") == v3 {
+		t.Error("Clear did not bump the watched version")
+	}
+
+	// Versions are dropped once the last watch is released
+	store.UnwatchKey("k")
+	if store.KeyVersion("k") != 0 {
+		t.Error("Version still tracked after unwatch")
 	}
 }
 
-func BenchmarkBasicStore_ConcurrentSetGet(b *testing.B) {
+func TestBasicStore_Version(t *testing.T) {
 	store, err := NewBasicStore(BasicStoreConfig{
-		Name:      "benchmark-store",
-		MaxMemory: 100 * 1024 * 1024, // 100MB
+		Name:      "version-test",
+		MaxMemory: 1024 * 1024,
 	})
 	if err != nil {
-		b.Fatalf("Failed to create store: %v", err)
+		t.Fatalf("Failed to create store: %v", err)
 	}
 	defer store.Close()
 
-	b.ResetTimer()
-	b.RunParallel(func(pb *testing.PB) {
-		i := 0
-		for pb.Next() {
-			if i%2 == 0 {
-				key := fmt.Sprintf("key-%d", i)
-				value := fmt.Sprintf("value-%d", i)
-				store.Set(key, value, "session1", 0)
-			} else {
-				key := fmt.Sprintf("key-%d", i-1)
-				store.Get(key)
+	if _, ok := store.Version("k"); ok {
+		t.Fatal("Version reported for a missing key")
+	}
+
+	var last uint64
+	bumped := func(step string) {
+		t.Helper()
+		v, ok := store.Version("k")
+		if !ok {
+			t.Fatalf("%s: key missing", step)
+		}
+		if v <= last {
+			t.Errorf("%s: version %d did not increase from %d", step, v, last)
+		}
+		last = v
+	}
+
+	store.Set("k", "v1", "", 0)
+	bumped("set")
+	store.Set("k", "v2", "", 0)
+	bumped("overwrite")
+	if !store.Expire("k", time.Minute) {
+		t.Fatal("Expire on existing key returned false")
+	}
+	bumped("expire")
+	if !store.Persist("k") {
+		t.Fatal("Persist on key with TTL returned false")
+	}
+	bumped("persist")
+
+	// No-op mutations leave the version alone
+	if store.Persist("k") {
+		t.Error("Persist on key without TTL returned true")
+	}
+	if v, _ := store.Version("k"); v != last {
+		t.Errorf("No-op persist changed version from %d to %d", last, v)
+	}
+	store.Set("other", "x", "", 0)
+	if v, _ := store.Version("k"); v != last {
+		t.Errorf("Writing another key changed version from %d to %d", last, v)
+	}
+
+	// The value survives in-place TTL changes
+	if value, _ := store.Get("k"); value != "v2" {
+		t.Errorf("Value after expire/persist = %v, want v2", value)
+	}
+
+	// Versions keep increasing across delete and re-create
+	store.Delete("k")
+	if _, ok := store.Version("k"); ok {
+		t.Error("Version reported for a deleted key")
+	}
+	store.Set("k", "v3", "", 0)
+	bumped("re-create")
+
+	if store.Expire("missing", time.Minute) {
+		t.Error("Expire on missing key returned true")
+	}
+}
+
+func TestBasicStore_Prefetch(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:              "prefetch-test",
+		MaxMemory:         1024 * 1024,
+		PrefetchKeys:      2,
+		PrefetchMinAccess: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	doc := map[string]interface{}{"name": "v1"}
+	if err := store.Set("doc", doc, "", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		store.Get("doc")
+	}
+	if n := store.prefetch.len(); n != 1 {
+		t.Fatalf("Expected hot key to be prefetched, got %d entries", n)
+	}
+	first, _ := store.Get("doc")
+	second, _ := store.Get("doc")
+	if fmt.Sprintf("%p", first) != fmt.Sprintf("%p", second) {
+		t.Error("Expected repeated reads of a hot key to share the prefetched value")
+	}
+
+	// A mutation drops the prefetched copy and reads see the new value
+	if err := store.Set("doc", map[string]interface{}{"name": "v2"}, "", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if n := store.prefetch.len(); n != 0 {
+		t.Errorf("Expected mutation to invalidate the prefetched value, %d entries left", n)
+	}
+	value, _ := store.Get("doc")
+	if m, ok := value.(map[string]interface{}); !ok || m["name"] != "v2" {
+		t.Errorf("Expected updated value after mutation, got %v", value)
+	}
+
+	// Strings are never prefetched; deletes and expiry invalidate
+	store.Set("s", "plain", "", 0)
+	for i := 0; i < 3; i++ {
+		store.Get("s")
+		store.Get("doc")
+	}
+	if _, ok := store.prefetch.get("s", 0); ok || store.prefetch.len() != 1 {
+		t.Errorf("Expected only doc to be prefetched, got %d entries", store.prefetch.len())
+	}
+	store.Expire("doc", time.Millisecond)
+	if n := store.prefetch.len(); n != 0 {
+		t.Errorf("Expected TTL change to invalidate the prefetched value, %d entries left", n)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := store.Get("doc"); err == nil {
+		t.Error("Expected expired key to miss instead of returning a prefetched value")
+	}
+}
+
+func TestBasicStore_ContainerRoundTrip(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "container-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"string slice", []string{"a", "", "ü", "long value with spaces"}, []string{"a", "", "ü", "long value with spaces"}},
+		{"empty string slice", []string{}, []string{}},
+		{"string map", map[string]string{"a": "1", "": "empty key", "b": ""}, map[string]string{"a": "1", "": "empty key", "b": ""}},
+		{"object", map[string]interface{}{
+			"s":      "text",
+			"n":      42,
+			"f":      1.5,
+			"big":    int64(1 << 40),
+			"t":      true,
+			"nil":    nil,
+			"nested": map[string]interface{}{"list": []interface{}{"x", 2.0, false, nil}},
+			"other":  []int{1, 2},
+		}, map[string]interface{}{
+			"s":      "text",
+			"n":      42.0,
+			"f":      1.5,
+			"big":    float64(1 << 40),
+			"t":      true,
+			"nil":    nil,
+			"nested": map[string]interface{}{"list": []interface{}{"x", 2.0, false, nil}},
+			"other":  []interface{}{1.0, 2.0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Set("k", tt.value, "", 0); err != nil {
+				t.Fatalf("Set failed: %v", err)
 			}
-			i++
+			got, err := store.Get("k")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Round trip = %#v, want %#v", got, tt.want)
+			}
+
+			// Raw readers still see JSON
+			raw, _, err := store.GetRawBytes("k")
+			if err != nil {
+				t.Fatalf("GetRawBytes failed: %v", err)
+			}
+			expected, _ := json.Marshal(tt.want)
+			if !jsonEqual(raw, expected) {
+				t.Errorf("GetRawBytes = %s, want %s", raw, expected)
+			}
+		})
+	}
+
+	// The compact encoding is smaller than JSON and rejects corrupt input
+	doc := map[string]interface{}{"name": "hypercache", "port": 8080.0, "tags": []interface{}{"a", "b"}}
+	compact, _ := encodeObject(doc)
+	asJSON, _ := json.Marshal(doc)
+	if len(compact) >= len(asJSON) {
+		t.Errorf("Expected compact encoding (%d bytes) to be smaller than JSON (%d bytes)", len(compact), len(asJSON))
+	}
+	if _, err := deserializeValue(compact[:len(compact)-3], objectValueType); err == nil {
+		t.Error("Expected truncated data to fail to decode")
+	}
+	if _, _, err := serializeValue(map[string]interface{}{"nan": math.NaN()}); err == nil {
+		t.Error("Expected NaN to be rejected like JSON does")
+	}
+}
+
+// jsonEqual compares two JSON documents ignoring key order
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestSerializeValue_Floats(t *testing.T) {
+	values := []interface{}{
+		float64(3.14159), math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), math.MaxFloat64, math.SmallestNonzeroFloat64,
+		float32(2.5), float32(math.NaN()), float32(math.Inf(1)), float32(math.Copysign(0, -1)),
+	}
+
+	for _, value := range values {
+		data, valueType, err := serializeValue(value)
+		if err != nil {
+			t.Fatalf("serializeValue(%v) failed: %v", value, err)
+		}
+		got, err := deserializeValue(data, valueType)
+		if err != nil {
+			t.Fatalf("deserializeValue(%v) failed: %v", value, err)
+		}
+
+		// Compare bit patterns so NaN and -0.0 are checked exactly
+		switch v := value.(type) {
+		case float64:
+			if math.Float64bits(got.(float64)) != math.Float64bits(v) {
+				t.Errorf("Round trip of %v = %v", v, got)
+			}
+		case float32:
+			if math.Float32bits(got.(float32)) != math.Float32bits(v) {
+				t.Errorf("Round trip of %v = %v", v, got)
+			}
+		}
+	}
+
+	// The encoding stays little-endian IEEE 754 so persisted data still decodes
+	data, _, _ := serializeValue(1.0)
+	if want := []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f}; !bytes.Equal(data, want) {
+		t.Errorf("Encoding of 1.0 = %x, want %x", data, want)
+	}
+	data, _, _ = serializeValue(float32(1.0))
+	if want := []byte{0, 0, 0x80, 0x3f}; !bytes.Equal(data, want) {
+		t.Errorf("Encoding of float32 1.0 = %x, want %x", data, want)
+	}
+}
+
+func TestBasicStore_GetRaw(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "getraw-test",
+		MaxMemory: 1024 * 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Set("bytes", []byte("hello"), "", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := store.Set("number", int64(42), "", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	raw, valueType, ok := store.GetRaw("bytes")
+	if !ok || string(raw) != "hello" || valueType != "[]uint8" {
+		t.Fatalf("GetRaw(bytes) = %q, %q, %v", raw, valueType, ok)
+	}
+
+	// The result is a copy; modifying it must not change the stored value
+	raw[0] = 'j'
+	if got, _, _ := store.GetRaw("bytes"); string(got) != "hello" {
+		t.Errorf("Stored value changed through GetRaw result: %q", got)
+	}
+
+	if _, valueType, ok := store.GetRaw("number"); !ok || valueType != "int64" {
+		t.Errorf("GetRaw(number) type = %q, %v", valueType, ok)
+	}
+	if _, _, ok := store.GetRaw("missing"); ok {
+		t.Error("Expected GetRaw of a missing key to report not found")
+	}
+}
+
+func TestBasicStore_Shards(t *testing.T) {
+	tests := []struct {
+		shards int
+		want   int
+	}{
+		{0, DefaultShards},
+		{1, 1},
+		{5, 8},
+		{64, 64},
+	}
+
+	for _, tt := range tests {
+		store, err := NewBasicStore(BasicStoreConfig{
+			Name:      "shards-test",
+			MaxMemory: 1024 * 1024,
+			Shards:    tt.shards,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		if got := store.data.ShardCount(); got != tt.want {
+			t.Errorf("Shards %d: got %d partitions, want %d", tt.shards, got, tt.want)
+		}
+
+		// Hits and misses are counted per shard and summed by Stats
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if err := store.Set(key, "value", "", 0); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if _, err := store.Get(key); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			_, _ = store.Get(key + "-missing")
+		}
+		stats := store.Stats()
+		if stats.HitCount != 20 || stats.MissCount != 20 || stats.TotalItems != 20 {
+			t.Errorf("Shards %d: stats = %d hits, %d misses, %d items, want 20 each",
+				tt.shards, stats.HitCount, stats.MissCount, stats.TotalItems)
+		}
+		if stats.LastAccess.IsZero() {
+			t.Errorf("Shards %d: expected LastAccess to be set", tt.shards)
+		}
+		store.Close()
+	}
+}
+
+func TestBasicStore_ConcurrentAccessCount(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:         "access-count-test",
+		MaxMemory:    1024 * 1024,
+		PrefetchKeys: 4,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Set("hot", int64(7), "", 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	const goroutines, reads = 8, 200
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < reads; i++ {
+				if _, err := store.Get("hot"); err != nil {
+					t.Errorf("Get failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	item, _ := store.data.Get("hot")
+	if got := item.AccessCount(); got != goroutines*reads {
+		t.Errorf("AccessCount = %d, want %d", got, goroutines*reads)
+	}
+	if time.Since(item.LastAccessed()) > time.Minute {
+		t.Errorf("LastAccessed = %v, expected a recent read", item.LastAccessed())
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"*", "anything", true},
+		{"*", "", true},
+		{"temp:*", "temp:1", true},
+		{"temp:*", "temp:", true},
+		{"temp:*", "tmp:1", false},
+		{"*:session", "user/42:session", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"key[0-9]", "key7", true},
+		{"key[0-9]", "keyx", false},
+		{`literal\*`, "literal*", true},
+		{`literal\*`, "literalx", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestBasicStore_DeleteMatching(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{Name: "delete-matching-test", MaxMemory: 16 * 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	const temps = 2500 // more than one batch
+	for i := 0; i < temps; i++ {
+		if err := store.Set(fmt.Sprintf("temp:%d", i), "v", "", 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	for _, key := range []string{"keep:1", "keep:2", "temporary"} {
+		if err := store.Set(key, "v", "", 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
 		}
+	}
+
+	deleted := store.DeleteMatching("temp:*")
+	if len(deleted) != temps {
+		t.Errorf("DeleteMatching deleted %d keys, want %d", len(deleted), temps)
+	}
+	keys := store.Keys()
+	sort.Strings(keys)
+	if want := []string{"keep:1", "keep:2", "temporary"}; fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("Keys after DeleteMatching = %v, want %v", keys, want)
+	}
+}
+
+func TestBasicStore_Watch(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{Name: "watch-test", MaxMemory: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	var notified, watched []string
+	store.SetKeyspaceNotifier(func(event, key string) { notified = append(notified, event+" "+key) })
+	cancel := store.Watch(func(event, key string) { watched = append(watched, event+" "+key) })
+
+	store.Set("k", "v", "", 0)
+	store.Delete("k")
+	cancel()
+	cancel() // idempotent
+	store.Set("k", "v", "", 0)
+
+	if !reflect.DeepEqual(watched, []string{"set k", "del k"}) {
+		t.Errorf("Watcher saw %q, want set and del before cancel only", watched)
+	}
+	if len(notified) != 3 {
+		t.Errorf("Keyspace notifier saw %q, want all 3 mutations alongside the watcher", notified)
+	}
+}
+
+func TestBasicStore_ScanConcurrentModification(t *testing.T) {
+	store, err := NewBasicStore(BasicStoreConfig{
+		Name:      "test-store",
+		MaxMemory: 16 * 1024 * 1024,
 	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	const stable = 2000
+	for i := 0; i < stable; i++ {
+		store.Set(fmt.Sprintf("stable:%d", i), "v", "", 0)
+	}
+	// Keys deleted while the scan runs
+	for i := 0; i < 500; i++ {
+		store.Set(fmt.Sprintf("doomed:%d", i), "v", "", 0)
+	}
+
+	// Add, delete and overwrite keys for as long as the scan runs
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			store.Set(fmt.Sprintf("churn:%d", i), "v", "", 0)
+			store.Delete(fmt.Sprintf("churn:%d", i-50))
+			store.Delete(fmt.Sprintf("doomed:%d", i%500))
+			store.Set(fmt.Sprintf("stable:%d", i%stable), fmt.Sprintf("v%d", i), "", 0)
+		}
+	}()
+
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for calls := 0; ; calls++ {
+		var keys []string
+		cursor, keys = store.Scan(cursor, ScanOptions{Count: 5})
+		for _, key := range keys {
+			seen[key]++
+		}
+		if cursor == 0 {
+			break
+		}
+		if calls > 100000 {
+			t.Fatal("Scan did not terminate")
+		}
+		runtime.Gosched()
+	}
+	close(done)
+	wg.Wait()
+
+	// Keys present for the whole scan are returned exactly once; keys added
+	// or removed meanwhile may or may not be
+	for i := 0; i < stable; i++ {
+		if n := seen[fmt.Sprintf("stable:%d", i)]; n != 1 {
+			t.Errorf("stable:%d returned %d times, want 1", i, n)
+		}
+	}
+	for key, n := range seen {
+		if !strings.HasPrefix(key, "stable:") && n != 1 {
+			t.Errorf("%s returned %d times, want at most once", key, n)
+		}
+	}
+}
+
+func TestBasicStore_ExportRestoreRaw(t *testing.T) {
+	newStore := func() *BasicStore {
+		store, err := NewBasicStore(BasicStoreConfig{Name: "test-store", MaxMemory: 1024 * 1024, DefaultTTL: time.Minute})
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		return store
+	}
+	source, target := newStore(), newStore()
+	defer source.Close()
+	defer target.Close()
+
+	source.Set("num", 42, "", NoExpiry)
+	source.ListPush("list", false, "a", "b")
+	source.Set("doc", map[string]interface{}{"n": float64(1)}, "sess", time.Hour)
+
+	for _, key := range []string{"num", "list", "doc"} {
+		item, ok := source.Export(key)
+		if !ok {
+			t.Fatalf("Export(%s): key missing", key)
+		}
+		if ok, err := target.RestoreRaw(context.Background(), key, item.Value, item.ValueType, item.SessionID, item.TTL, item.LamportTS); !ok || err != nil {
+			t.Fatalf("RestoreRaw(%s) = %v, %v", key, ok, err)
+		}
+	}
+	if got, _ := target.Get("num"); got != 42 {
+		t.Errorf("Expected int 42, got %#v", got)
+	}
+	if ttl, _ := target.TTL("num"); ttl != -1 {
+		t.Errorf("Expected no expiry despite the default TTL, got %v", ttl)
+	}
+	if got, _ := target.ListRange("list", 0, -1); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Expected list [a b], got %v", got)
+	}
+	if ttl, _ := target.TTL("doc"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("Expected the document's TTL kept, got %v", ttl)
+	}
+
+	// A newer write on the target wins over a restore
+	target.SetWithTimestamp(context.Background(), "clock", "newer", "", 0, 10)
+	if ok, _ := target.RestoreRaw(context.Background(), "clock", []byte("older"), "string", "", 0, 5); ok {
+		t.Error("Expected a restore older than the stored write to be skipped")
+	}
+	if ok, _ := target.RestoreRaw(context.Background(), "clock", []byte("same"), "string", "", 0, 10); !ok {
+		t.Error("Expected a restore at the same timestamp to overwrite")
+	}
+	if _, err := target.RestoreRaw(context.Background(), "bad", []byte{1}, "int", "", 0, 0); err == nil {
+		t.Error("Expected a value that doesn't decode as its type to be rejected")
+	}
+
+	// DeleteVersion only deletes the version it was given
+	item, _ := source.Export("num")
+	source.Set("num", 43, "", 0)
+	if source.DeleteVersion("num", item.Version) {
+		t.Error("Expected DeleteVersion to keep a key written since its export")
+	}
+	version, _ := source.Version("num")
+	if !source.DeleteVersion("num", version) || source.Has("num") {
+		t.Error("Expected DeleteVersion to delete the current version")
+	}
 }
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// MaxBitOffset is the highest bit offset SETBIT accepts (a 512MB bitmap)
+const MaxBitOffset = 1<<32 - 1
+
+// ErrBitOffset is returned for a bit offset outside 0..MaxBitOffset
+var ErrBitOffset = errors.New("bit offset is not an integer or out of range")
+
+// bitmapValue returns the raw bytes held by item. No item is an empty
+// bitmap; lists and sets are rejected with ErrWrongType.
+func bitmapValue(item *CacheItem) ([]byte, error) {
+	if item == nil {
+		return nil, nil
+	}
+	if item.Kind() != TypeString {
+		return nil, ErrWrongType
+	}
+	return item.GetRawBytes(), nil
+}
+
+// getBitmap returns the raw bytes stored at key. A missing key yields an
+// empty bitmap.
+func (s *BasicStore) getBitmap(key string) ([]byte, error) {
+	return bitmapValue(s.liveItem(key))
+}
+
+// SetBit sets or clears the bit at offset in the value at key and returns the
+// previous bit. The value is zero-padded as needed, so setting a high offset
+// grows the allocation taken from the store's MemoryPool.
+func (s *BasicStore) SetBit(key string, offset uint64, on bool) (int, error) {
+	if offset > MaxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	byteIndex := offset / 8
+	mask := byte(0x80) >> (offset % 8)
+
+	prev := 0
+	err := s.updateTyped(key, func(item *CacheItem) (interface{}, bool, error) {
+		current, err := bitmapValue(item)
+		if err != nil {
+			return nil, false, err
+		}
+
+		size := uint64(len(current))
+		if byteIndex >= size {
+			size = byteIndex + 1
+		}
+		bitmap := make([]byte, size)
+		copy(bitmap, current)
+
+		prev = 0
+		if bitmap[byteIndex]&mask != 0 {
+			prev = 1
+		}
+		if on {
+			bitmap[byteIndex] |= mask
+		} else {
+			bitmap[byteIndex] &^= mask
+		}
+		return bitmap, true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return prev, nil
+}
+
+// GetBit returns the bit at offset in the value at key. Offsets past the end
+// of the value, and missing keys, read as 0.
+func (s *BasicStore) GetBit(key string, offset uint64) (int, error) {
+	if offset > MaxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	bitmap, err := s.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+
+	byteIndex := offset / 8
+	if byteIndex >= uint64(len(bitmap)) {
+		return 0, nil
+	}
+	if bitmap[byteIndex]&(byte(0x80)>>(offset%8)) != 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// BitCount returns the number of set bits in the bytes between start and end
+// inclusive. Negative indexes count from the end of the value, as in BITCOUNT.
+func (s *BasicStore) BitCount(key string, start, end int) (int, error) {
+	bitmap, err := s.getBitmap(key)
+	if err != nil {
+		return 0, err
+	}
+
+	n := len(bitmap)
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= n {
+		end = n - 1
+	}
+
+	count := 0
+	for i := start; i <= end; i++ {
+		count += bits.OnesCount8(bitmap[i])
+	}
+	return count, nil
+}
@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Compact encoding for common container values. Strings are written as a
+// uvarint length followed by the bytes, containers as a uvarint element
+// count followed by their elements. []string and map[string]string decode
+// to their own types. Values inside a map[string]interface{} carry a one-byte
+// tag and decode to the types encoding/json would produce, so documents read
+// back exactly as they did when they were stored as JSON.
+
+// ValueType strings of the containers with a compact encoding
+const (
+	stringSliceValueType = "[]string"
+	stringMapValueType   = "map[string]string"
+	objectValueType      = "map[string]interface {}"
+)
+
+// Tags of values inside an encoded map[string]interface{}
+const (
+	tagNull   = 'n'
+	tagFalse  = 'F'
+	tagTrue   = 'T'
+	tagNumber = 'f' // float64 bits, little endian
+	tagString = 's'
+	tagObject = 'm' // nested map[string]interface{}
+	tagArray  = 'a' // []interface{}
+	tagJSON   = 'j' // anything else, JSON-encoded
+)
+
+var errTruncated = errors.New("truncated container value")
+
+// isCompactContainer reports whether valueType is stored with the compact encoding
+func isCompactContainer(valueType string) bool {
+	switch valueType {
+	case stringSliceValueType, stringMapValueType, objectValueType:
+		return true
+	}
+	return false
+}
+
+// appendString appends a length-prefixed string
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// encodeStringSlice encodes a []string
+func encodeStringSlice(v []string) []byte {
+	size := binary.MaxVarintLen64
+	for _, s := range v {
+		size += binary.MaxVarintLen64 + len(s)
+	}
+	buf := binary.AppendUvarint(make([]byte, 0, size), uint64(len(v)))
+	for _, s := range v {
+		buf = appendString(buf, s)
+	}
+	return buf
+}
+
+// encodeStringMap encodes a map[string]string
+func encodeStringMap(v map[string]string) []byte {
+	size := binary.MaxVarintLen64
+	for k, s := range v {
+		size += 2*binary.MaxVarintLen64 + len(k) + len(s)
+	}
+	buf := binary.AppendUvarint(make([]byte, 0, size), uint64(len(v)))
+	for k, s := range v {
+		buf = appendString(buf, k)
+		buf = appendString(buf, s)
+	}
+	return buf
+}
+
+// encodeObject encodes a map[string]interface{}
+func encodeObject(v map[string]interface{}) ([]byte, error) {
+	return appendObject(make([]byte, 0, 64*len(v)+binary.MaxVarintLen64), v)
+}
+
+func appendObject(buf []byte, v map[string]interface{}) ([]byte, error) {
+	buf = binary.AppendUvarint(buf, uint64(len(v)))
+	for k, elem := range v {
+		buf = appendString(buf, k)
+		var err error
+		if buf, err = appendTagged(buf, elem); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// appendTagged appends a tagged value of a map[string]interface{} or []interface{}
+func appendTagged(buf []byte, v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case nil:
+		return append(buf, tagNull), nil
+	case bool:
+		if x {
+			return append(buf, tagTrue), nil
+		}
+		return append(buf, tagFalse), nil
+	case string:
+		return appendString(append(buf, tagString), x), nil
+	case float64:
+		return appendNumber(buf, x)
+	case int:
+		return appendNumber(buf, float64(x))
+	case int64:
+		return appendNumber(buf, float64(x))
+	case map[string]interface{}:
+		return appendObject(append(buf, tagObject), x)
+	case []interface{}:
+		buf = binary.AppendUvarint(append(buf, tagArray), uint64(len(x)))
+		for _, elem := range x {
+			var err error
+			if buf, err = appendTagged(buf, elem); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		data, err := json.Marshal(x)
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(append(buf, tagJSON), uint64(len(data)))
+		return append(buf, data...), nil
+	}
+}
+
+// appendNumber appends a number, rejecting the values JSON cannot represent
+func appendNumber(buf []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported number %v", f)
+	}
+	return binary.LittleEndian.AppendUint64(append(buf, tagNumber), math.Float64bits(f)), nil
+}
+
+// containerReader decodes the compact container encoding
+type containerReader struct {
+	data []byte
+	off  int
+}
+
+func (r *containerReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.off:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.off += n
+	return v, nil
+}
+
+// count reads an element count, bounded by the bytes left so corrupt input
+// cannot trigger a huge allocation
+func (r *containerReader) count() (int, error) {
+	n, err := r.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(r.data)-r.off) {
+		return 0, errTruncated
+	}
+	return int(n), nil
+}
+
+func (r *containerReader) bytes() ([]byte, error) {
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+func (r *containerReader) string() (string, error) {
+	b, err := r.bytes()
+	return string(b), err
+}
+
+// decodeStringSlice decodes a []string
+func decodeStringSlice(data []byte) ([]string, error) {
+	r := &containerReader{data: data}
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	v := make([]string, n)
+	for i := range v {
+		if v[i], err = r.string(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// decodeStringMap decodes a map[string]string
+func decodeStringMap(data []byte) (map[string]string, error) {
+	r := &containerReader{data: data}
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	v := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		if v[k], err = r.string(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// decodeObject decodes a map[string]interface{}
+func decodeObject(data []byte) (map[string]interface{}, error) {
+	r := &containerReader{data: data}
+	return r.object()
+}
+
+func (r *containerReader) object() (map[string]interface{}, error) {
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	v := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		if v[k], err = r.tagged(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (r *containerReader) tagged() (interface{}, error) {
+	if r.off >= len(r.data) {
+		return nil, errTruncated
+	}
+	tag := r.data[r.off]
+	r.off++
+
+	switch tag {
+	case tagNull:
+		return nil, nil
+	case tagFalse:
+		return false, nil
+	case tagTrue:
+		return true, nil
+	case tagString:
+		return r.string()
+	case tagNumber:
+		if len(r.data)-r.off < 8 {
+			return nil, errTruncated
+		}
+		bits := binary.LittleEndian.Uint64(r.data[r.off:])
+		r.off += 8
+		return math.Float64frombits(bits), nil
+	case tagObject:
+		return r.object()
+	case tagArray:
+		n, err := r.count()
+		if err != nil {
+			return nil, err
+		}
+		v := make([]interface{}, n)
+		for i := range v {
+			if v[i], err = r.tagged(); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case tagJSON:
+		data, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unknown value tag %q", tag)
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// BenchmarkSerializeValue_Map compares a round trip of a 100-field map through
+// serializeValue/deserializeValue with encoding/json and encoding/gob
+func BenchmarkSerializeValue_Map(b *testing.B) {
+	doc := make(map[string]interface{}, 100)
+	strs := make(map[string]string, 100)
+	for i := 0; i < 100; i++ {
+		doc[fmt.Sprintf("field-%d", i)] = fmt.Sprintf("value-%d", i)
+		strs[fmt.Sprintf("field-%d", i)] = fmt.Sprintf("value-%d", i)
+	}
+
+	b.Run("compact/object", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, valueType, err := serializeValue(doc)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := deserializeValue(data, valueType); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("compact/string-map", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, valueType, err := serializeValue(strs)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := deserializeValue(data, valueType); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.Marshal(doc)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var out map[string]interface{}
+			if err := json.Unmarshal(data, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("gob", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(strs); err != nil {
+				b.Fatal(err)
+			}
+			var out map[string]string
+			if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
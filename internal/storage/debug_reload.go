@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/persistence"
+)
+
+// DebugReload round-trips the whole store through its persistence engine,
+// like Redis DEBUG RELOAD: it writes a snapshot, empties the store and
+// restores every key from the snapshot just written. The snapshot is loaded
+// before anything is cleared, so a failure to load leaves the store intact.
+// Returns the keys whose value, type, expiry or session differ afterwards
+// (nil when the round trip is exact). Writes that race with the reload may
+// be lost; it is a debugging aid, not an online operation.
+func (s *BasicStore) DebugReload() ([]string, error) {
+	if s.persistEngine == nil {
+		return nil, fmt.Errorf("persistence not enabled")
+	}
+
+	before := s.data.SnapshotRawData()
+	data := make(map[string]interface{}, len(before))
+	for _, item := range before {
+		data[item.Key] = snapshotEntry(item)
+	}
+	if err := s.persistEngine.CreateSnapshot(data); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	loaded, err := s.persistEngine.LoadSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	s.clearForReload()
+
+	failed := make(map[string]bool)
+	for key, value := range loaded {
+		entry, ok := value.(persistence.SnapshotEntry)
+		if !ok {
+			failed[key] = true
+			continue
+		}
+		if err := s.restoreInternal(key, entry.Value, entry.ValueType, entry.SessionID, entry.ExpiresAt); err != nil {
+			failed[key] = true
+		}
+	}
+
+	// Compare with the state before the reload, including keys that only
+	// appeared afterwards
+	for _, want := range before {
+		item, ok := s.data.Get(want.Key)
+		if !ok || !bytes.Equal(item.ValuePtr, want.RawBytes) || item.ValueType != want.ValueType ||
+			!item.ExpiresAt.Equal(want.ExpiresAt) || item.SessionID != want.SessionID {
+			failed[want.Key] = true
+		}
+	}
+	s.data.RangeAll(func(key string, item *CacheItem) bool {
+		if _, ok := data[key]; !ok {
+			failed[key] = true
+		}
+		return true
+	})
+
+	keys := make([]string, 0, len(failed))
+	for key := range failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	logging.Info(nil, logging.ComponentStorage, logging.ActionRestore, "DEBUG RELOAD complete", map[string]interface{}{
+		"store":  s.config.Name,
+		"keys":   len(before),
+		"failed": len(keys),
+	})
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return keys, nil
+}
+
+// snapshotEntry converts a copied item to the form persisted in snapshots
+func snapshotEntry(item SnapshotItem) persistence.SnapshotEntry {
+	return persistence.SnapshotEntry{
+		Key:          item.Key,
+		Value:        item.RawBytes,
+		ValueType:    item.ValueType,
+		CreatedAt:    item.CreatedAt,
+		ExpiresAt:    item.ExpiresAt,
+		SessionID:    item.SessionID,
+		LastAccessed: item.CreatedAt,
+		Size:         item.Size,
+	}
+}
+
+// clearForReload empties the store ahead of DebugReload restoring it.
+// Unlike Clear it neither notifies keyspace subscribers nor invalidates
+// WATCHes, since the same keys come straight back.
+func (s *BasicStore) clearForReload() {
+	s.data.RangeAll(func(key string, item *CacheItem) bool {
+		if ptr, ok := s.data.GetAllocatedPtr(key); ok {
+			s.freeValue(ptr)
+		}
+		s.evictPolicy.OnDelete(s.itemToEntry(key, item))
+		return true
+	})
+	s.data.Clear()
+	if s.prefetch != nil {
+		s.prefetch.clear()
+	}
+	if s.filter != nil {
+		_ = s.filter.Clear()
+	}
+
+	s.mutex.Lock()
+	s.stats.TotalItems = 0
+	s.stats.TotalMemory = 0
+	s.mutex.Unlock()
+}
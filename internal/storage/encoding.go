@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// Encoding names reported by Encoding, as Redis's OBJECT ENCODING
+const (
+	EncodingInt       = "int"       // string holding a 64-bit integer
+	EncodingEmbstr    = "embstr"    // short string
+	EncodingRaw       = "raw"       // long string or other scalar
+	EncodingListpack  = "listpack"  // small list or set
+	EncodingQuicklist = "quicklist" // large list
+	EncodingIntset    = "intset"    // small set of integers
+	EncodingHashtable = "hashtable" // large set
+)
+
+// Encoding thresholds, Redis's defaults. Lists and sets are stored in the
+// compact encoding below them and the full one above.
+const (
+	EmbstrMaxSize      = 44  // longest embstr string in bytes
+	ListpackMaxEntries = 128 // most elements of a listpack list or set
+	ListpackMaxValue   = 64  // longest listpack element in bytes
+	IntsetMaxEntries   = 512 // most members of an intset
+)
+
+// Leading byte of a stored list or set, naming its encoding. Lists and sets
+// written before encodings existed are JSON arrays, starting with '[', and
+// are read as quicklist and hashtable.
+const (
+	listpackTag  = 'l' // element count, then each element length-prefixed
+	quicklistTag = 'q' // element count, node count, then each listpack node length-prefixed
+	intsetTag    = 'i' // members as sorted little-endian int64s
+	hashtableTag = 'h' // member count, bucket count, each bucket's end offset, then the buckets' members
+)
+
+// Encoding returns the encoding of the value at key in Redis's terms, or ""
+// if the key does not exist. Lists and sets report the encoding they are
+// stored in: listpack, or intset for a set of integers, while small, and
+// quicklist or hashtable once past Redis's thresholds. Strings report
+// theirs by shape.
+func (s *BasicStore) Encoding(key string) string {
+	item, ok := s.data.Get(key)
+	if !ok || s.expired(item) {
+		return ""
+	}
+
+	switch item.Kind() {
+	case TypeList:
+		if len(item.ValuePtr) > 0 && item.ValuePtr[0] == listpackTag {
+			return EncodingListpack
+		}
+		return EncodingQuicklist
+
+	case TypeSet:
+		if len(item.ValuePtr) > 0 {
+			switch item.ValuePtr[0] {
+			case intsetTag:
+				return EncodingIntset
+			case listpackTag:
+				return EncodingListpack
+			}
+		}
+		return EncodingHashtable
+	}
+
+	switch item.ValueType {
+	case "string", "[]uint8":
+		raw := item.ValuePtr
+		if _, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			return EncodingInt
+		}
+		if len(raw) <= EmbstrMaxSize {
+			return EncodingEmbstr
+		}
+		return EncodingRaw
+	case "int", "int32", "int64", "uint32":
+		return EncodingInt
+	default:
+		return EncodingRaw
+	}
+}
+
+// fitsListpack reports whether elements are few and short enough for a listpack
+func fitsListpack(elements []string) bool {
+	if len(elements) > ListpackMaxEntries {
+		return false
+	}
+	for _, e := range elements {
+		if len(e) > ListpackMaxValue {
+			return false
+		}
+	}
+	return true
+}
+
+// parseIntsetMember parses a member an intset can hold: a 64-bit integer
+// written the way FormatInt writes it, so the member reads back unchanged
+func parseIntsetMember(member string) (int64, bool) {
+	n, err := strconv.ParseInt(member, 10, 64)
+	return n, err == nil && strconv.FormatInt(n, 10) == member
+}
+
+// encodeList encodes a list as a listpack, or as a quicklist of listpack
+// nodes once it is too large for one
+func encodeList(list ListValue) []byte {
+	if fitsListpack(list) {
+		return append([]byte{listpackTag}, encodeStringSlice(list)...)
+	}
+	nodes := (len(list) + ListpackMaxEntries - 1) / ListpackMaxEntries
+	buf := binary.AppendUvarint([]byte{quicklistTag}, uint64(len(list)))
+	buf = binary.AppendUvarint(buf, uint64(nodes))
+	for start := 0; start < len(list); start += ListpackMaxEntries {
+		node := encodeStringSlice(list[start:min(start+ListpackMaxEntries, len(list))])
+		buf = binary.AppendUvarint(buf, uint64(len(node)))
+		buf = append(buf, node...)
+	}
+	return buf
+}
+
+// decodeList decodes a list in any encoding
+func decodeList(data []byte) (ListValue, error) {
+	switch data[0] {
+	case '[':
+		var list ListValue
+		err := json.Unmarshal(data, &list)
+		return list, err
+	case listpackTag:
+		return decodeStringSlice(data[1:])
+	case quicklistTag:
+		return listNodes(data, 0, -1)
+	}
+	return nil, fmt.Errorf("unknown list encoding %q", data[0])
+}
+
+// listLen returns the length of an encoded list
+func listLen(data []byte) (int, error) {
+	if data[0] == '[' {
+		list, err := decodeList(data)
+		return len(list), err
+	}
+	r := &containerReader{data: data, off: 1}
+	return r.count()
+}
+
+// listRange returns the elements of an encoded list between start and stop
+// inclusive, both within the list. Only the quicklist nodes holding them
+// are decoded.
+func listRange(data []byte, start, stop int) ([]string, error) {
+	if data[0] == quicklistTag {
+		return listNodes(data, start, stop)
+	}
+	list, err := decodeList(data)
+	if err != nil {
+		return nil, err
+	}
+	if stop >= len(list) {
+		return nil, errTruncated
+	}
+	return list[start : stop+1], nil
+}
+
+// listNodes decodes the elements from start to stop inclusive (-1 = the
+// last) of a quicklist, skipping the nodes before and after them
+func listNodes(data []byte, start, stop int) ([]string, error) {
+	r := &containerReader{data: data, off: 1}
+	total, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	if stop < 0 {
+		stop = total - 1
+	}
+	nodes, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, max(stop-start+1, 0))
+	index := 0
+	for ; nodes > 0 && index <= stop; nodes-- {
+		node, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		n, err := (&containerReader{data: node}).count()
+		if err != nil {
+			return nil, err
+		}
+		if index+n > start {
+			elements, err := decodeStringSlice(node)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, elements[max(start-index, 0):min(stop-index+1, n)]...)
+		}
+		index += n
+	}
+	if len(list) != stop-start+1 {
+		return nil, errTruncated
+	}
+	return list, nil
+}
+
+// encodeSet encodes a set as an intset if its members are all integers,
+// as a listpack otherwise, or as a hashtable once it is too large for
+// either
+func encodeSet(set SetValue) []byte {
+	if len(set) <= IntsetMaxEntries {
+		ints := make([]int64, 0, len(set))
+		for member := range set {
+			n, ok := parseIntsetMember(member)
+			if !ok {
+				break
+			}
+			ints = append(ints, n)
+		}
+		if len(ints) == len(set) {
+			slices.Sort(ints)
+			buf := make([]byte, 1, 1+8*len(ints))
+			buf[0] = intsetTag
+			for _, n := range ints {
+				buf = binary.LittleEndian.AppendUint64(buf, uint64(n))
+			}
+			return buf
+		}
+	}
+
+	members := set.Members()
+	if fitsListpack(members) {
+		return append([]byte{listpackTag}, encodeStringSlice(members)...)
+	}
+
+	// A power of two buckets, about one member each
+	buckets := 1
+	for buckets < len(members) {
+		buckets <<= 1
+	}
+	grouped := make([][]string, buckets)
+	for _, member := range members {
+		b := memberHash(member) & uint64(buckets-1)
+		grouped[b] = append(grouped[b], member)
+	}
+
+	var region []byte
+	buf := binary.AppendUvarint([]byte{hashtableTag}, uint64(len(members)))
+	buf = binary.AppendUvarint(buf, uint64(buckets))
+	for _, bucket := range grouped {
+		for _, member := range bucket {
+			region = appendString(region, member)
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(region)))
+	}
+	return append(buf, region...)
+}
+
+// memberHash is FNV-1a, which places a hashtable's members in buckets. It
+// is part of the stored encoding, so it must never change.
+func memberHash(member string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(member); i++ {
+		h ^= uint64(member[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// hashtable is a decoded hashtable header
+type hashtable struct {
+	count  int
+	ends   []byte // each bucket's end offset in members, little-endian uint32s
+	region []byte // the buckets' members, length-prefixed
+}
+
+// parseHashtable reads a hashtable's header
+func parseHashtable(data []byte) (hashtable, error) {
+	r := &containerReader{data: data, off: 1}
+	count, err := r.count()
+	if err != nil {
+		return hashtable{}, err
+	}
+	buckets, err := r.count()
+	if err != nil || buckets == 0 || buckets&(buckets-1) != 0 || 4*buckets > len(data)-r.off {
+		return hashtable{}, errTruncated
+	}
+	ends := data[r.off : r.off+4*buckets]
+	region := data[r.off+4*buckets:]
+	if int(binary.LittleEndian.Uint32(ends[len(ends)-4:])) != len(region) {
+		return hashtable{}, errTruncated
+	}
+	return hashtable{count: count, ends: ends, region: region}, nil
+}
+
+// bucket returns the members of the bucket member hashes to, length-prefixed
+func (h hashtable) bucket(member string) ([]byte, error) {
+	b := int(memberHash(member) & uint64(len(h.ends)/4-1))
+	start := 0
+	if b > 0 {
+		start = int(binary.LittleEndian.Uint32(h.ends[4*(b-1):]))
+	}
+	end := int(binary.LittleEndian.Uint32(h.ends[4*b:]))
+	if start > end || end > len(h.region) {
+		return nil, errTruncated
+	}
+	return h.region[start:end], nil
+}
+
+// decodeSet decodes a set in any encoding
+func decodeSet(data []byte) (SetValue, error) {
+	var members []string
+	switch data[0] {
+	case '[':
+		if err := json.Unmarshal(data, &members); err != nil {
+			return nil, err
+		}
+	case intsetTag:
+		if (len(data)-1)%8 != 0 {
+			return nil, errTruncated
+		}
+		members = make([]string, 0, (len(data)-1)/8)
+		for off := 1; off < len(data); off += 8 {
+			members = append(members, strconv.FormatInt(int64(binary.LittleEndian.Uint64(data[off:])), 10))
+		}
+	case listpackTag:
+		var err error
+		if members, err = decodeStringSlice(data[1:]); err != nil {
+			return nil, err
+		}
+	case hashtableTag:
+		h, err := parseHashtable(data)
+		if err != nil {
+			return nil, err
+		}
+		r := &containerReader{data: h.region}
+		members = make([]string, h.count)
+		for i := range members {
+			if members[i], err = r.string(); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown set encoding %q", data[0])
+	}
+	return NewSetValue(members...), nil
+}
+
+// setCard returns the number of members of an encoded set
+func setCard(data []byte) (int, error) {
+	switch data[0] {
+	case intsetTag:
+		return (len(data) - 1) / 8, nil
+	case listpackTag, hashtableTag:
+		r := &containerReader{data: data, off: 1}
+		return r.count()
+	}
+	set, err := decodeSet(data)
+	return len(set), err
+}
+
+// setContains reports whether member is in an encoded set, without decoding
+// it: an intset is binary searched, a hashtable probed and a listpack scanned
+func setContains(data []byte, member string) (bool, error) {
+	var elements []byte
+	switch data[0] {
+	case intsetTag:
+		n, ok := parseIntsetMember(member)
+		if !ok {
+			return false, nil
+		}
+		count := (len(data) - 1) / 8
+		at := func(i int) int64 { return int64(binary.LittleEndian.Uint64(data[1+8*i:])) }
+		i, j := 0, count
+		for i < j {
+			mid := int(uint(i+j) >> 1)
+			if at(mid) < n {
+				i = mid + 1
+			} else {
+				j = mid
+			}
+		}
+		return i < count && at(i) == n, nil
+	case listpackTag:
+		r := &containerReader{data: data, off: 1}
+		if _, err := r.count(); err != nil {
+			return false, err
+		}
+		elements = data[r.off:]
+	case hashtableTag:
+		h, err := parseHashtable(data)
+		if err != nil {
+			return false, err
+		}
+		if elements, err = h.bucket(member); err != nil {
+			return false, err
+		}
+	default:
+		set, err := decodeSet(data)
+		_, ok := set[member]
+		return ok, err
+	}
+
+	r := &containerReader{data: elements}
+	for r.off < len(elements) {
+		element, err := r.bytes()
+		if err != nil {
+			return false, err
+		}
+		if string(element) == member {
+			return true, nil
+		}
+	}
+	return false, nil
+}
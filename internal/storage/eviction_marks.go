@@ -0,0 +1,44 @@
+package storage
+
+import "fmt"
+
+// Default eviction marks, as memory pressure (used / max memory)
+const (
+	DefaultEvictionHighWater     = 0.85 // memory pool warning threshold
+	DefaultEvictionCriticalWater = 0.90 // memory pool critical threshold
+	DefaultEvictionPanicWater    = 0.95 // memory pool panic threshold
+	DefaultEvictionLowWater      = 0.75 // pressure the evictor reclaims down to
+)
+
+// DefaultEvictionBatchSize is how many keys the background evictor evicts
+// per round when the config has no batch size
+const DefaultEvictionBatchSize = 1
+
+// resolveEvictionMarks fills in defaults for unset eviction marks and checks
+// they're ordered low < high < critical < panic
+func resolveEvictionMarks(config *BasicStoreConfig) error {
+	if config.EvictionHighWater == 0 {
+		config.EvictionHighWater = DefaultEvictionHighWater
+	}
+	if config.EvictionCriticalWater == 0 {
+		config.EvictionCriticalWater = DefaultEvictionCriticalWater
+	}
+	if config.EvictionPanicWater == 0 {
+		config.EvictionPanicWater = DefaultEvictionPanicWater
+	}
+	if config.EvictionLowWater == 0 {
+		config.EvictionLowWater = DefaultEvictionLowWater
+	}
+	if config.EvictionBatchSize == 0 {
+		config.EvictionBatchSize = DefaultEvictionBatchSize
+	}
+
+	if config.EvictionBatchSize < 0 {
+		return fmt.Errorf("eviction batch size cannot be negative")
+	}
+	if config.EvictionLowWater < 0 || config.EvictionLowWater >= config.EvictionHighWater {
+		return fmt.Errorf("eviction low water %.2f must be between 0 and the high water %.2f",
+			config.EvictionLowWater, config.EvictionHighWater)
+	}
+	return nil
+}
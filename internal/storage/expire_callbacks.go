@@ -0,0 +1,107 @@
+package storage
+
+import "sync"
+
+// expireQueueSize bounds the expired keys waiting for OnExpire callbacks;
+// when full, expiring a key waits for the callbacks to catch up
+const expireQueueSize = 1024
+
+// expiredKey is a key removed on expiry, queued for the OnExpire callbacks
+type expiredKey struct {
+	key  string
+	item *CacheItem
+}
+
+// expireCallbacks runs the OnExpire callbacks on their own goroutine, in
+// expiry order, so a slow callback never holds up the store
+type expireCallbacks struct {
+	mu        sync.RWMutex
+	callbacks []func(key string, value interface{})
+	queue     chan expiredKey
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// OnExpire registers fn to be called with the key and value of every key
+// that expires, whether removed by active expiry, lazily on access or as
+// part of an idle session. Callbacks run one at a time on a background
+// goroutine, after the key is gone; value is nil if it couldn't be decoded.
+// Keys deleted by clients or evicted under memory pressure are not
+// reported.
+func (s *BasicStore) OnExpire(fn func(key string, value interface{})) {
+	ec := &s.expireCallbacks
+	ec.startOnce.Do(func() {
+		ec.queue = make(chan expiredKey, expireQueueSize)
+		ec.stop = make(chan struct{})
+		ec.done = make(chan struct{})
+		go ec.run()
+	})
+	ec.mu.Lock()
+	ec.callbacks = append(ec.callbacks, fn)
+	ec.mu.Unlock()
+}
+
+// expire queues an expired key for the OnExpire callbacks, if any
+func (ec *expireCallbacks) expire(key string, item *CacheItem) {
+	// The queue is set before the first callback is registered, and stays
+	// nil if the store closed first
+	ec.mu.RLock()
+	registered := len(ec.callbacks) > 0
+	ec.mu.RUnlock()
+	if !registered || ec.queue == nil {
+		return
+	}
+	select {
+	case ec.queue <- expiredKey{key: key, item: item}:
+	case <-ec.stop:
+	}
+}
+
+// run invokes the callbacks for each queued key until close, then drains
+// the keys still queued
+func (ec *expireCallbacks) run() {
+	defer close(ec.done)
+	for {
+		select {
+		case ev := <-ec.queue:
+			ec.invoke(ev)
+		case <-ec.stop:
+			for {
+				select {
+				case ev := <-ec.queue:
+					ec.invoke(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// invoke calls every registered callback for one expired key
+func (ec *expireCallbacks) invoke(ev expiredKey) {
+	value, err := ev.item.GetValue()
+	if err != nil {
+		value = nil
+	}
+	ec.mu.RLock()
+	callbacks := ec.callbacks
+	ec.mu.RUnlock()
+	for _, fn := range callbacks {
+		fn(ev.key, value)
+	}
+}
+
+// close stops the callback goroutine after it has drained the queue
+func (ec *expireCallbacks) close() {
+	ec.startOnce.Do(func() {}) // OnExpire after Close starts nothing
+	if ec.queue == nil {
+		return
+	}
+	ec.stopOnce.Do(func() {
+		close(ec.stop)
+		<-ec.done
+	})
+}
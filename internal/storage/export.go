@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+)
+
+// ExportedItem is a key's value as stored, with the metadata needed to
+// recreate it on another node
+type ExportedItem struct {
+	Value     []byte // serialized as ValueType
+	ValueType string
+	SessionID string
+	TTL       time.Duration // remaining time to live, 0 if the key has no expiry
+	LamportTS uint64
+	Version   uint64 // see Version
+}
+
+// Export returns key's value in its stored encoding, with its metadata, so
+// RestoreRaw can recreate it elsewhere with the same type: lists, sets,
+// numbers and JSON documents included. ok is false if the key doesn't
+// exist. Unlike Get it doesn't count as a read.
+func (s *BasicStore) Export(key string) (ExportedItem, bool) {
+	s.data.LockShard(key)
+	defer s.data.UnlockShard(key)
+	item, ok := s.data.getShard(key).items[key]
+	if !ok || s.expired(item) {
+		return ExportedItem{}, false
+	}
+
+	exported := ExportedItem{
+		Value:     bytes.Clone(item.GetRawBytes()),
+		ValueType: item.ValueType,
+		SessionID: item.SessionID,
+		LamportTS: item.LamportTimestamp,
+		Version:   item.Version,
+	}
+	if !item.ExpiresAt.IsZero() {
+		// An item about to expire keeps a minimal TTL rather than none
+		exported.TTL = max(item.ExpiresAt.Sub(s.now()), time.Millisecond)
+	}
+	return exported, true
+}
+
+// serializedValue is a value already in its stored encoding, written as is
+type serializedValue struct {
+	data      []byte
+	valueType string
+}
+
+// RestoreRaw writes a value exported with Export, keeping its type. ttl 0
+// means no expiry. It is skipped, reporting false, if the key holds a write
+// with a newer Lamport timestamp; an equal one is overwritten, so a key
+// resent because it changed during a migration replaces the earlier copy.
+func (s *BasicStore) RestoreRaw(ctx context.Context, key string, raw []byte, valueType, sessionID string, ttl time.Duration, lamportTS uint64) (bool, error) {
+	// Reject anything the value type can't decode before storing it
+	if _, err := deserializeValue(raw, valueType); err != nil {
+		return false, fmt.Errorf("invalid %s value: %w", valueType, err)
+	}
+	if ttl <= 0 {
+		ttl = NoExpiry
+	}
+	value := serializedValue{data: raw, valueType: valueType}
+	return s.setIf(ctx, key, value, sessionID, ttl, lamportTS, false, func(existing *CacheItem) bool {
+		return existing == nil || s.expired(existing) || existing.LamportTimestamp <= lamportTS
+	})
+}
+
+// DeleteVersion deletes key only if it is still at version, as returned by
+// Export or Version, so a write made since isn't lost. Reports whether the
+// key was deleted. The key lives on elsewhere, so the Writer isn't told.
+func (s *BasicStore) DeleteVersion(key string, version uint64) bool {
+	return s.removeIf(key, "del", func(item *CacheItem) bool { return item.Version == version }, false) == nil
+}
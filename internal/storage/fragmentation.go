@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"hypercache/internal/logging"
+)
+
+// DefaultCompactMinRatio is the fragmentation ratio above which the
+// compactor runs when the config has none
+const DefaultCompactMinRatio = 1.5
+
+// compactMaxOpsPerSec is the store load (reads and writes per second,
+// across all stores) above which the compactor skips a cycle
+const compactMaxOpsPerSec = 1000
+
+// HeapFragmentation describes how much of the memory held for values is
+// actually in use. Values are allocated on the Go heap (MemoryPool only
+// accounts for them), so these are process-wide heap figures.
+type HeapFragmentation struct {
+	Allocated uint64  // Bytes of live and not yet collected objects
+	Active    uint64  // Bytes of heap spans holding at least one object
+	Resident  uint64  // Active plus free spans not yet returned to the OS
+	Free      uint64  // Resident - Allocated: held but unused
+	Ratio     float64 // Resident / Allocated (1.0 = no fragmentation)
+}
+
+// ReadHeapFragmentation returns the current heap fragmentation. The Go
+// runtime doesn't expose free span extents, so there's no largest
+// contiguous free figure.
+func ReadHeapFragmentation() HeapFragmentation {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	frag := HeapFragmentation{
+		Allocated: ms.HeapAlloc,
+		Active:    ms.HeapInuse,
+		Resident:  ms.HeapInuse + ms.HeapIdle - ms.HeapReleased,
+	}
+	if frag.Resident > frag.Allocated {
+		frag.Free = frag.Resident - frag.Allocated
+	}
+	if frag.Allocated > 0 {
+		frag.Ratio = float64(frag.Resident) / float64(frag.Allocated)
+	}
+	return frag
+}
+
+// CompactHeap collects freed values and returns free heap spans to the OS,
+// returning fragmentation before and after. The Go collector doesn't move
+// objects, so live values stay where they are; what shrinks is the memory
+// left behind by deleted, expired and evicted ones. Stops the world briefly.
+func CompactHeap() (before, after HeapFragmentation) {
+	before = ReadHeapFragmentation()
+	debug.FreeOSMemory()
+	after = ReadHeapFragmentation()
+	return before, after
+}
+
+// opCount returns the store's reads and writes so far, a measure of load
+func (s *BasicStore) opCount() uint64 {
+	hits, misses := s.data.ReadCounts()
+	return hits + misses + s.versionClock.Load()
+}
+
+// RunCompactor checks heap fragmentation every interval and compacts the
+// heap when the ratio is at least minRatio (0 = DefaultCompactMinRatio) and
+// the stores are under low load. Blocks until ctx is done.
+func (sm *StoreManager) RunCompactor(ctx context.Context, interval time.Duration, minRatio float64) {
+	if minRatio <= 0 {
+		minRatio = DefaultCompactMinRatio
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastOps := sm.opCount()
+	for {
+		select {
+		case <-ticker.C:
+			// A dropped store takes its counts with it
+			ops := sm.opCount()
+			rate := 0.0
+			if ops > lastOps {
+				rate = float64(ops-lastOps) / interval.Seconds()
+			}
+			lastOps = ops
+			if rate > compactMaxOpsPerSec {
+				continue
+			}
+			if frag := ReadHeapFragmentation(); frag.Ratio < minRatio {
+				continue
+			}
+			before, after := CompactHeap()
+			logging.Info(ctx, logging.ComponentStorage, logging.ActionCleanup, "Heap compacted", map[string]interface{}{
+				"ratio_before": before.Ratio,
+				"ratio_after":  after.Ratio,
+				"freed_bytes":  int64(before.Resident) - int64(after.Resident),
+			})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// opCount returns reads and writes across all stores
+func (sm *StoreManager) opCount() uint64 {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var total uint64
+	for _, store := range sm.stores {
+		total += store.opCount()
+	}
+	return total
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultHitRateWindow is the span RecentHitRate covers when the config
+// sets none
+const DefaultHitRateWindow = time.Minute
+
+// hitWindow is a ring buffer of the store's cumulative hit and miss counts,
+// sampled once a second. The per-second counts are the differences between
+// neighbouring samples; RecentHitRate compares the current counts with the
+// oldest sample. Sampling the sharded counters keeps reads free of any
+// extra shared atomic.
+type hitWindow struct {
+	mu      sync.Mutex
+	hits    []uint64
+	misses  []uint64
+	next    int  // slot the next sample goes in, the oldest once full
+	full    bool // every slot holds a sample
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newHitWindow creates a window of one sample per second over span
+func newHitWindow(span time.Duration) *hitWindow {
+	slots := int(span / time.Second)
+	if slots < 1 {
+		slots = 1
+	}
+	return &hitWindow{
+		hits:    make([]uint64, slots),
+		misses:  make([]uint64, slots),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// record stores a sample of the cumulative counts, replacing the oldest
+func (w *hitWindow) record(hits, misses uint64) {
+	w.mu.Lock()
+	w.hits[w.next] = hits
+	w.misses[w.next] = misses
+	w.next = (w.next + 1) % len(w.hits)
+	if w.next == 0 {
+		w.full = true
+	}
+	w.mu.Unlock()
+}
+
+// oldest returns the earliest sample still in the window, zero before the
+// first one
+func (w *hitWindow) oldest() (hits, misses uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.full {
+		if w.next == 0 {
+			return 0, 0
+		}
+		return w.hits[0], w.misses[0]
+	}
+	return w.hits[w.next], w.misses[w.next]
+}
+
+// sampleHitWindow records the store's hit and miss counts every second
+// until Close
+func (s *BasicStore) sampleHitWindow() {
+	defer close(s.hitWindow.stopped)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.hitWindow.record(s.data.ReadCounts())
+		case <-s.hitWindow.stop:
+			return
+		}
+	}
+}
+
+// RecentHitRate returns the hit rate, as a percentage, of reads over the
+// last HitRateWindow. Unlike BasicStoreStats.HitRate it isn't held down by
+// misses while the cache was still cold. 0 if there were no reads.
+func (s *BasicStore) RecentHitRate() float64 {
+	hits, misses := s.data.ReadCounts()
+	oldHits, oldMisses := s.hitWindow.oldest()
+	if hits < oldHits || misses < oldMisses {
+		return 0
+	}
+	hits -= oldHits
+	misses -= oldMisses
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses) * 100.0
+}
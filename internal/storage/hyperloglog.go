@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/bits"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HyperLogLog parameters: 2^14 registers give a standard error of
+// 1.04/sqrt(16384) ≈ 0.81%.
+const (
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+)
+
+// hllMagic prefixes every stored HyperLogLog so PF* commands can tell it
+// apart from an ordinary string value
+var hllMagic = []byte("HYLL")
+
+// ErrNotHyperLogLog is returned when a PF* command targets a string that is not a HyperLogLog
+var ErrNotHyperLogLog = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+
+// HyperLogLog is a dense HyperLogLog sketch with one byte per register
+type HyperLogLog struct {
+	registers [hllRegisters]uint8
+}
+
+// NewHyperLogLog creates an empty sketch
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{}
+}
+
+// Add records an element and reports whether any register changed
+func (h *HyperLogLog) Add(element []byte) bool {
+	hash := xxhash.Sum64(element)
+	index := hash & (hllRegisters - 1)
+	// Rank is the position of the first set bit in the remaining 50 bits;
+	// the sentinel bit caps it at 51 when they are all zero.
+	rank := uint8(bits.TrailingZeros64(hash>>hllPrecision|1<<(64-hllPrecision))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+		return true
+	}
+	return false
+}
+
+// Merge folds other into h, keeping the maximum of each register
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+// Count returns the estimated number of distinct elements added
+func (h *HyperLogLog) Count() uint64 {
+	const m = float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+	// Small-range correction: fall back to linear counting while registers are still empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// MarshalBinary encodes the sketch as the magic header followed by the registers
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, len(hllMagic)+hllRegisters)
+	data = append(data, hllMagic...)
+	return append(data, h.registers[:]...), nil
+}
+
+// UnmarshalBinary decodes a sketch written by MarshalBinary
+func (h *HyperLogLog) UnmarshalBinary(data []byte) error {
+	if len(data) != len(hllMagic)+hllRegisters || !bytes.HasPrefix(data, hllMagic) {
+		return ErrNotHyperLogLog
+	}
+	copy(h.registers[:], data[len(hllMagic):])
+	return nil
+}
+
+// hllValue decodes the sketch held by item, or returns a nil sketch if
+// there is no item
+func hllValue(item *CacheItem) (*HyperLogLog, error) {
+	raw, err := bitmapValue(item)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	h := NewHyperLogLog()
+	if err := h.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// getHLL returns the sketch stored at key, or a nil sketch if the key does
+// not exist
+func (s *BasicStore) getHLL(key string) (*HyperLogLog, error) {
+	return hllValue(s.liveItem(key))
+}
+
+// PFAdd adds elements to the HyperLogLog at key, creating it if needed, and
+// reports whether the estimate may have changed.
+func (s *BasicStore) PFAdd(key string, elements ...string) (bool, error) {
+	changed := false
+	err := s.updateTyped(key, func(item *CacheItem) (interface{}, bool, error) {
+		h, err := hllValue(item)
+		if err != nil {
+			return nil, false, err
+		}
+
+		changed = h == nil
+		if h == nil {
+			h = NewHyperLogLog()
+		}
+		for _, e := range elements {
+			if h.Add([]byte(e)) {
+				changed = true
+			}
+		}
+		data, _ := h.MarshalBinary()
+		return data, changed, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// PFCount returns the estimated cardinality of the union of the HyperLogLogs
+// at keys. Missing keys count as empty.
+func (s *BasicStore) PFCount(keys ...string) (uint64, error) {
+	union, err := s.mergeHLLs(keys)
+	if err != nil {
+		return 0, err
+	}
+	return union.Count(), nil
+}
+
+// PFMerge stores the union of the HyperLogLogs at dest and sources in dest
+func (s *BasicStore) PFMerge(dest string, sources ...string) error {
+	return s.updateTyped(dest, func(item *CacheItem) (interface{}, bool, error) {
+		union, err := hllValue(item)
+		if err != nil {
+			return nil, false, err
+		}
+		if union == nil {
+			union = NewHyperLogLog()
+		}
+		others, err := s.mergeHLLs(sources)
+		if err != nil {
+			return nil, false, err
+		}
+		union.Merge(others)
+		data, _ := union.MarshalBinary()
+		return data, true, nil
+	})
+}
+
+// mergeHLLs returns the union of the sketches at keys
+func (s *BasicStore) mergeHLLs(keys []string) (*HyperLogLog, error) {
+	union := NewHyperLogLog()
+	for _, key := range keys {
+		h, err := s.getHLL(key)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			union.Merge(h)
+		}
+	}
+	return union, nil
+}
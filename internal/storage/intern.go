@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bytes"
+	"hash/maphash"
+	"sync"
+	"unsafe"
+
+	"hypercache/internal/metrics"
+)
+
+// DefaultInternMinSize is the smallest value shared between keys when
+// interning is enabled; below it the hashing costs more than it saves
+const DefaultInternMinSize = 1024
+
+// internedValue is one stored copy of a value shared by refs keys
+type internedValue struct {
+	data []byte // MemoryPool allocation
+	hash uint64
+	refs int
+}
+
+// valueInterner stores identical serialized values once. The first key
+// holding a value allocates it from the pool as usual; later keys with the
+// same bytes share that allocation and only reserve their PerKeyOverhead. The
+// allocation is freed when the last key referencing it lets go. Sharing is
+// safe because stored bytes are never modified in place: every write
+// allocates a new value.
+type valueInterner struct {
+	pool    *MemoryPool
+	seed    maphash.Seed
+	minSize int
+
+	mu     sync.Mutex
+	byHash map[uint64][]*internedValue
+	byPtr  map[uintptr]*internedValue
+}
+
+// newValueInterner creates an interner for values of at least minSize bytes
+func newValueInterner(pool *MemoryPool, minSize uint64) *valueInterner {
+	if minSize == 0 {
+		minSize = DefaultInternMinSize
+	}
+	return &valueInterner{
+		pool:    pool,
+		seed:    maphash.MakeSeed(),
+		minSize: int(minSize),
+		byHash:  make(map[uint64][]*internedValue),
+		byPtr:   make(map[uintptr]*internedValue),
+	}
+}
+
+// acquire returns a pool allocation holding data, shared with other keys
+// holding the same bytes. Release it with release.
+func (in *valueInterner) acquire(data []byte) ([]byte, error) {
+	hash := maphash.Bytes(in.seed, data)
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	for _, v := range in.byHash[hash] {
+		if bytes.Equal(v.data, data) {
+			if err := in.pool.Reserve(PerKeyOverhead); err != nil {
+				return nil, err
+			}
+			v.refs++
+			metrics.Global().IncCounter("hypercache_intern_shared_total")
+			return v.data, nil
+		}
+	}
+
+	allocated, err := in.pool.Allocate(int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	copy(allocated, data)
+
+	v := &internedValue{data: allocated, hash: hash, refs: 1}
+	in.byHash[hash] = append(in.byHash[hash], v)
+	in.byPtr[uintptr(unsafe.Pointer(&allocated[0]))] = v
+	return allocated, nil
+}
+
+// release drops a key's reference to an interned allocation, freeing it
+// with the last reference. Reports false if ptr was not interned.
+func (in *valueInterner) release(ptr []byte) bool {
+	if len(ptr) < in.minSize {
+		return false
+	}
+	ptrKey := uintptr(unsafe.Pointer(&ptr[0]))
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	v, ok := in.byPtr[ptrKey]
+	if !ok {
+		return false
+	}
+	v.refs--
+	if v.refs > 0 {
+		in.pool.Unreserve(PerKeyOverhead)
+		return true
+	}
+
+	delete(in.byPtr, ptrKey)
+	shared := in.byHash[v.hash]
+	for i, candidate := range shared {
+		if candidate == v {
+			shared = append(shared[:i], shared[i+1:]...)
+			break
+		}
+	}
+	if len(shared) == 0 {
+		delete(in.byHash, v.hash)
+	} else {
+		in.byHash[v.hash] = shared
+	}
+	_ = in.pool.Free(v.data)
+	return true
+}
+
+// refCount returns how many keys share the allocation ptr (0 if not interned)
+func (in *valueInterner) refCount(ptr []byte) int {
+	if len(ptr) < in.minSize {
+		return 0
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if v, ok := in.byPtr[uintptr(unsafe.Pointer(&ptr[0]))]; ok {
+		return v.refs
+	}
+	return 0
+}
+
+// allocateValue copies a serialized value into memory from the pool, shared
+// with other keys holding the same bytes when interning is enabled
+func (s *BasicStore) allocateValue(data []byte) ([]byte, error) {
+	if s.interner != nil && len(data) >= s.interner.minSize {
+		return s.interner.acquire(data)
+	}
+	allocated, err := s.memPool.Allocate(int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	copy(allocated, data)
+	return allocated, nil
+}
+
+// freeValue returns a value's allocation to the pool immediately, or drops
+// the key's reference to it if interned
+func (s *BasicStore) freeValue(ptr []byte) {
+	if s.interner != nil && s.interner.release(ptr) {
+		return
+	}
+	_ = s.memPool.Free(ptr)
+}
+
+// RefCount returns how many keys share the stored value of key, like
+// OBJECT REFCOUNT: 1 unless value interning is enabled and other keys hold
+// identical bytes, 0 if the key does not exist
+func (s *BasicStore) RefCount(key string) int {
+	s.data.RLockShard(key)
+	defer s.data.RUnlockShard(key)
+
+	item, ok := s.data.getShard(key).items[key]
+	if !ok || s.expired(item) {
+		return 0
+	}
+	if s.interner != nil {
+		if refs := s.interner.refCount(item.ValuePtr); refs > 0 {
+			return refs
+		}
+	}
+	return 1
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrKeyTooLarge is returned for writes whose key exceeds MaxKeySize
+	ErrKeyTooLarge = errors.New("key exceeds maximum key size")
+
+	// ErrKeyInvalid is returned in strict key mode for keys with a NUL byte
+	ErrKeyInvalid = errors.New("key contains a NUL byte")
+)
+
+// checkKey rejects keys the store is configured not to accept: longer than
+// MaxKeySize, or containing a NUL byte when StrictKeys is set
+func (s *BasicStore) checkKey(key string) error {
+	if s.config.MaxKeySize > 0 && len(key) > s.config.MaxKeySize {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrKeyTooLarge, len(key), s.config.MaxKeySize)
+	}
+	if s.config.StrictKeys && strings.IndexByte(key, 0) >= 0 {
+		return ErrKeyInvalid
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// keyspaceWatchers fans key mutations out to the watchers added with Watch.
+// The list is replaced on every change, so notify never takes a lock.
+type keyspaceWatchers struct {
+	mu   sync.Mutex // serializes changes to list
+	list atomic.Pointer[[]*keyspaceWatcher]
+}
+
+// keyspaceWatcher is one Watch registration
+type keyspaceWatcher struct {
+	fn KeyspaceNotifier
+}
+
+// Watch calls fn after every key mutation, alongside the keyspace notifier,
+// until the returned cancel function is called. fn runs on the mutating
+// goroutine, so it must return quickly and not use the store.
+func (s *BasicStore) Watch(fn KeyspaceNotifier) (cancel func()) {
+	w := &keyspaceWatcher{fn: fn}
+	s.watchers.update(func(list []*keyspaceWatcher) []*keyspaceWatcher {
+		return append(list, w)
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.watchers.update(func(list []*keyspaceWatcher) []*keyspaceWatcher {
+				kept := list[:0]
+				for _, other := range list {
+					if other != w {
+						kept = append(kept, other)
+					}
+				}
+				return kept
+			})
+		})
+	}
+}
+
+// update replaces the watcher list with change applied to a copy of it
+func (kw *keyspaceWatchers) update(change func([]*keyspaceWatcher) []*keyspaceWatcher) {
+	kw.mu.Lock()
+	defer kw.mu.Unlock()
+	var list []*keyspaceWatcher
+	if current := kw.list.Load(); current != nil {
+		list = append(list, *current...)
+	}
+	list = change(list)
+	kw.list.Store(&list)
+}
+
+// notify calls every watcher with a mutation
+func (kw *keyspaceWatchers) notify(event, key string) {
+	if list := kw.list.Load(); list != nil {
+		for _, w := range *list {
+			w.fn(event, key)
+		}
+	}
+}
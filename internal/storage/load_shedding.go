@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
+)
+
+// DefaultShedLowWater is the memory pressure below which a shedding store
+// accepts writes again
+const DefaultShedLowWater = 0.80
+
+// ErrOOM is returned for writes rejected while a store is shedding load
+var ErrOOM = errors.New("OOM command not allowed when used memory > maxmemory")
+
+// sheddingStores counts stores currently shedding writes, exported as a gauge
+var sheddingStores atomic.Int64
+
+// enterShedding is the panic-pressure handler when load shedding is enabled.
+// Instead of evicting aggressively, the store refuses new writes until
+// pressure drops below the low-water mark.
+func (s *BasicStore) enterShedding(pressure float64) {
+	if !s.shedding.CompareAndSwap(false, true) {
+		return
+	}
+	metrics.Global().SetGauge("hypercache_memory_shedding_stores", sheddingStores.Add(1))
+	logging.Warn(nil, logging.ComponentStorage, logging.ActionCleanup, "Memory pressure high, shedding writes", map[string]interface{}{
+		"store":     s.config.Name,
+		"pressure":  pressure,
+		"low_water": s.config.ShedLowWater,
+	})
+}
+
+// checkShedding reports whether a write must be rejected, leaving the
+// shedding state first if memory has recovered below the low-water mark
+func (s *BasicStore) checkShedding() bool {
+	if !s.shedding.Load() {
+		return false
+	}
+	if s.memPool.MemoryPressure() >= s.config.ShedLowWater {
+		metrics.Global().IncCounter("hypercache_writes_shed_total")
+		return true
+	}
+	if s.shedding.CompareAndSwap(true, false) {
+		metrics.Global().SetGauge("hypercache_memory_shedding_stores", sheddingStores.Add(-1))
+		logging.Info(nil, logging.ComponentStorage, logging.ActionCleanup, "Memory pressure recovered, accepting writes", map[string]interface{}{
+			"store":    s.config.Name,
+			"pressure": s.memPool.MemoryPressure(),
+		})
+	}
+	return false
+}
+
+// IsShedding reports whether the store is currently rejecting writes
+func (s *BasicStore) IsShedding() bool {
+	return s.shedding.Load()
+}
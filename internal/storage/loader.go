@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
+)
+
+// Loader fetches a key missing from the cache from the backing store, for
+// read-through caching. A nil value with a nil error means the backing
+// store doesn't have the key either. ttl is the loaded key's TTL, with the
+// same meaning as for Set.
+type Loader func(key string) (value interface{}, ttl time.Duration, err error)
+
+// Writer persists a batch of writes to the backing store, for write-behind
+// caching. An error keeps the batch for the next flush, merged with any
+// newer writes of the same keys.
+type Writer func(batch []WriteBehind) error
+
+// WriteBehind is a write waiting to be passed to the Writer: a key's new
+// value, or a tombstone if the key was deleted or expired
+type WriteBehind struct {
+	Key     string
+	Value   interface{}
+	Deleted bool // tombstone; Value is nil
+}
+
+// Write-behind defaults
+const (
+	DefaultWriteBehindInterval   = time.Second // flush at least this often
+	DefaultWriteBehindBatch      = 100         // flush early once this many keys are pending
+	DefaultWriteBehindMaxPending = 100000      // drop writes of new keys past this many pending
+)
+
+// loaderWriteKey marks the context of a write that caches a loaded value,
+// so it isn't written back to the store it came from
+type loaderWriteKey struct{}
+
+// loadCall is the populate of a missing key in flight, shared by every
+// caller that missed the key meanwhile
+type loadCall struct {
+	done   chan struct{}
+	value  interface{}
+	err    error
+	shared bool // populate finished, finding the key or that there is none
+}
+
+// loadOnMiss is called for a Get that missed. With a Loader it loads the
+// key through singleFlight. Without one it returns missErr, after waiting
+// for a GetOrCompute of the key in flight if CoalesceMisses is set.
+func (s *BasicStore) loadOnMiss(key string, missErr error) (interface{}, error) {
+	if s.config.Loader == nil {
+		if s.config.CoalesceMisses {
+			s.loadMu.Lock()
+			call, ok := s.loading[key]
+			s.loadMu.Unlock()
+			if ok {
+				<-call.done
+				if call.err == nil {
+					return call.value, nil
+				}
+			}
+		}
+		return nil, missErr
+	}
+	return s.singleFlight(key, missErr, func() (interface{}, time.Duration, error) {
+		value, ttl, err := s.config.Loader(key)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to load key %s: %w", key, err)
+		}
+		return value, ttl, nil
+	})
+}
+
+// GetOrCompute returns the value of key, computing and caching it on a
+// miss. Concurrent misses of the same key share one compute call and its
+// result, so a cold key never stampedes whatever compute calls. A nil
+// value caches nothing and the miss error is returned to every caller
+// sharing the call. A compute error, or panic, is only returned to the
+// caller whose compute failed; the callers waiting on it run compute
+// again, one at a time. The value is cached with the store's default TTL
+// and isn't passed to the Writer.
+func (s *BasicStore) GetOrCompute(key string, compute func() (interface{}, error)) (interface{}, error) {
+	value, err := s.Get(key)
+	if err == nil || key == "" {
+		return value, err
+	}
+	return s.singleFlight(key, err, func() (interface{}, time.Duration, error) {
+		value, err := compute()
+		return value, 0, err
+	})
+}
+
+// singleFlight populates a missing key with populate, unless a populate of
+// the key is already in flight, in which case it waits for that one and
+// shares its result. A populate that fails or panics isn't shared: its
+// waiters try again, the first of them running populate itself. A panic
+// is passed on to the caller whose populate panicked.
+func (s *BasicStore) singleFlight(key string, missErr error, populate func() (interface{}, time.Duration, error)) (interface{}, error) {
+	s.loadMu.Lock()
+	for {
+		call, ok := s.loading[key]
+		if !ok {
+			break
+		}
+		s.loadMu.Unlock()
+		<-call.done
+		if call.shared {
+			return call.value, call.err
+		}
+		s.loadMu.Lock()
+	}
+	call := &loadCall{done: make(chan struct{})}
+	s.loading[key] = call
+	s.loadMu.Unlock()
+
+	// Release the key even if populate panics, so waiters don't block
+	defer func() {
+		s.loadMu.Lock()
+		delete(s.loading, key)
+		s.loadMu.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = s.populate(key, missErr, populate)
+	call.shared = call.err == nil || call.err == missErr
+	return call.value, call.err
+}
+
+// populate runs populate for key and caches the value it returns. A
+// populate that finished between the caller's miss and this one has
+// already cached the key, so that is checked first.
+func (s *BasicStore) populate(key string, missErr error, populate func() (interface{}, time.Duration, error)) (interface{}, error) {
+	if item, ok := s.data.Get(key); ok && !s.expired(item) {
+		if value, err := item.GetValue(); err == nil {
+			return value, nil
+		}
+	}
+
+	value, ttl, err := populate()
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, missErr
+	}
+
+	ctx := context.WithValue(context.Background(), loaderWriteKey{}, true)
+	if err := s.setWithContextInternal(ctx, key, value, "", ttl, 0); err != nil {
+		// Still serve the value; the next miss populates the key again
+		logging.Warn(ctx, logging.ComponentStorage, logging.ActionRestore, "Failed to cache loaded value", map[string]interface{}{
+			"store": s.config.Name,
+			"key":   key,
+			"error": err.Error(),
+		})
+	}
+	return value, nil
+}
+
+// writeBehind batches writes for the Writer on a background goroutine
+type writeBehind struct {
+	writer     Writer
+	interval   time.Duration
+	batch      int
+	maxPending int
+
+	mu      sync.Mutex
+	pending map[string]WriteBehind // latest write per key
+	order   []string               // keys in the order first written
+	dropped atomic.Uint64
+	flushCh chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newWriteBehind creates a write-behind queue and starts its flusher
+func newWriteBehind(writer Writer, interval time.Duration, batch, maxPending int) *writeBehind {
+	if interval <= 0 {
+		interval = DefaultWriteBehindInterval
+	}
+	if batch <= 0 {
+		batch = DefaultWriteBehindBatch
+	}
+	if maxPending <= 0 {
+		maxPending = DefaultWriteBehindMaxPending
+	}
+	wb := &writeBehind{
+		writer:     writer,
+		interval:   interval,
+		batch:      batch,
+		maxPending: maxPending,
+		pending:    make(map[string]WriteBehind),
+		flushCh:    make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go wb.run()
+	return wb
+}
+
+// add queues a write, replacing a pending write of the same key. A write of
+// a new key is dropped once maxPending keys are pending, so a Writer that
+// keeps failing can't grow the queue without bound.
+func (wb *writeBehind) add(write WriteBehind) {
+	wb.mu.Lock()
+	if _, ok := wb.pending[write.Key]; !ok {
+		if len(wb.order) >= wb.maxPending {
+			wb.mu.Unlock()
+			wb.drop(1)
+			return
+		}
+		wb.order = append(wb.order, write.Key)
+	}
+	wb.pending[write.Key] = write
+	full := len(wb.order) >= wb.batch
+	wb.mu.Unlock()
+
+	if full {
+		select {
+		case wb.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// WriteBehindDropped returns how many writes never reached the Writer
+// because the write-behind queue was full
+func (s *BasicStore) WriteBehindDropped() uint64 {
+	if s.writeBehind == nil {
+		return 0
+	}
+	return s.writeBehind.dropped.Load()
+}
+
+// drop counts writes lost to a full queue
+func (wb *writeBehind) drop(n int) {
+	total := wb.dropped.Add(uint64(n))
+	metrics.Global().AddCounter("hypercache_write_behind_dropped_total", int64(n))
+	logging.Warn(nil, logging.ComponentStorage, logging.ActionPersist, "Write-behind queue full, writes dropped", map[string]interface{}{
+		"dropped":       n,
+		"dropped_total": total,
+	})
+}
+
+// run flushes every interval, or early when a batch fills up, until close
+func (wb *writeBehind) run() {
+	defer close(wb.done)
+	ticker := time.NewTicker(wb.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			wb.flush()
+		case <-wb.flushCh:
+			wb.flush()
+		case <-wb.stop:
+			wb.flush()
+			return
+		}
+	}
+}
+
+// flush passes pending writes to the Writer in batches. On error the
+// failed batch and everything after it are put back for the next flush.
+func (wb *writeBehind) flush() {
+	wb.mu.Lock()
+	pending, order := wb.pending, wb.order
+	wb.pending, wb.order = make(map[string]WriteBehind), nil
+	wb.mu.Unlock()
+
+	for start := 0; start < len(order); start += wb.batch {
+		end := min(start+wb.batch, len(order))
+		batch := make([]WriteBehind, 0, end-start)
+		for _, key := range order[start:end] {
+			batch = append(batch, pending[key])
+		}
+		if err := wb.writer(batch); err != nil {
+			logging.Warn(nil, logging.ComponentStorage, logging.ActionPersist, "Write-behind flush failed, will retry", map[string]interface{}{
+				"keys":  len(order) - start,
+				"error": err.Error(),
+			})
+			wb.requeue(pending, order[start:])
+			return
+		}
+	}
+}
+
+// requeue puts back writes that failed to flush, unless a newer write of
+// the same key arrived meanwhile. Writes that no longer fit under
+// maxPending are dropped, the most recent first.
+func (wb *writeBehind) requeue(pending map[string]WriteBehind, keys []string) {
+	wb.mu.Lock()
+	var order []string
+	for _, key := range keys {
+		if _, newer := wb.pending[key]; !newer {
+			order = append(order, key)
+		}
+	}
+	dropped := 0
+	if room := wb.maxPending - len(wb.order); len(order) > room {
+		dropped = len(order) - max(room, 0)
+		order = order[:len(order)-dropped]
+	}
+	for _, key := range order {
+		wb.pending[key] = pending[key]
+	}
+	wb.order = append(order, wb.order...)
+	wb.mu.Unlock()
+
+	if dropped > 0 {
+		wb.drop(dropped)
+	}
+}
+
+// close flushes pending writes and stops the flusher
+func (wb *writeBehind) close() {
+	close(wb.stop)
+	<-wb.done
+}
@@ -0,0 +1,120 @@
+package storage
+
+import "runtime"
+
+// deleteMatchingBatch is how many keys DeleteMatching deletes before
+// yielding to other goroutines
+const deleteMatchingBatch = 1000
+
+// MatchPattern reports whether key matches a Redis KEYS-style glob pattern:
+// * matches any run of characters (including none), ? any one character,
+// [abc], [a-z] and [^a] character classes, and \ escapes the next character.
+// Unlike path.Match, / is an ordinary character.
+func MatchPattern(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if MatchPattern(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			matched, rest := matchClass(pattern[1:], key[0])
+			if !matched {
+				return false
+			}
+			key = key[1:]
+			pattern = rest
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass matches c against the character class at the start of pattern
+// (just after the '['). Returns whether it matched and the pattern after the
+// closing ']'; an unterminated class runs to the end of the pattern.
+func matchClass(pattern string, c byte) (bool, string) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:] // skip ']'
+	}
+	return matched != negate, pattern
+}
+
+// DeleteMatching deletes every live key matching the glob pattern (see
+// MatchPattern) and returns the deleted keys. Keys are deleted one at a
+// time, yielding between batches, so a large keyspace never holds shard
+// locks for long; keys written meanwhile may or may not be deleted.
+func (s *BasicStore) DeleteMatching(pattern string) []string {
+	var matched []string
+	for _, key := range s.Keys() {
+		if MatchPattern(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+
+	deleted := make([]string, 0, len(matched))
+	for i, key := range matched {
+		if i > 0 && i%deleteMatchingBatch == 0 {
+			runtime.Gosched()
+		}
+		if s.Delete(key) == nil {
+			deleted = append(deleted, key)
+		}
+	}
+	return deleted
+}
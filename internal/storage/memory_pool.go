@@ -18,7 +18,7 @@ const PerKeyOverhead = 500
 // This implementation provides O(1) operations and thread-safe memory management
 type MemoryPool struct {
 	name         string
-	maxSize      int64             // Maximum memory this pool can allocate
+	maxSize      int64             // Maximum memory this pool can allocate (atomic; changed by Resize)
 	currentUsage int64             // Current memory usage (atomic for thread safety)
 	allocations  map[uintptr]int64 // Track allocations for proper cleanup
 	mutex        sync.RWMutex      // Protect allocations map
@@ -72,10 +72,11 @@ func (mp *MemoryPool) Allocate(size int64) ([]byte, error) {
 
 	// Check if allocation would exceed maximum
 	currentUsage := atomic.LoadInt64(&mp.currentUsage)
-	if currentUsage+totalSize > mp.maxSize {
+	maxSize := atomic.LoadInt64(&mp.maxSize)
+	if currentUsage+totalSize > maxSize {
 		atomic.AddInt64(&mp.allocationFailures, 1)
 		return nil, fmt.Errorf("allocation would exceed pool limit: %d + %d > %d",
-			currentUsage, totalSize, mp.maxSize)
+			currentUsage, totalSize, maxSize)
 	}
 
 	// Allocate memory
@@ -92,7 +93,7 @@ func (mp *MemoryPool) Allocate(size int64) ([]byte, error) {
 	atomic.AddInt64(&mp.totalAllocations, 1)
 
 	// Check memory pressure and trigger callbacks if needed
-	mp.checkMemoryPressure(float64(newUsage) / float64(mp.maxSize))
+	mp.checkMemoryPressure(float64(newUsage) / float64(maxSize))
 
 	return data, nil
 }
@@ -121,6 +122,28 @@ func (mp *MemoryPool) Free(ptr []byte) error {
 	return nil
 }
 
+// Reserve accounts for size bytes held outside a pool allocation, such as
+// the per-key overhead of a key sharing another key's value. Undo with
+// Unreserve - O(1)
+func (mp *MemoryPool) Reserve(size int64) error {
+	maxSize := atomic.LoadInt64(&mp.maxSize)
+	newUsage := atomic.AddInt64(&mp.currentUsage, size)
+	if newUsage > maxSize {
+		atomic.AddInt64(&mp.currentUsage, -size)
+		atomic.AddInt64(&mp.allocationFailures, 1)
+		return fmt.Errorf("reservation would exceed pool limit: %d + %d > %d",
+			newUsage-size, size, maxSize)
+	}
+
+	mp.checkMemoryPressure(float64(newUsage) / float64(maxSize))
+	return nil
+}
+
+// Unreserve releases size bytes taken with Reserve - O(1)
+func (mp *MemoryPool) Unreserve(size int64) {
+	atomic.AddInt64(&mp.currentUsage, -size)
+}
+
 // CurrentUsage returns current memory usage - O(1)
 func (mp *MemoryPool) CurrentUsage() int64 {
 	return atomic.LoadInt64(&mp.currentUsage)
@@ -128,17 +151,17 @@ func (mp *MemoryPool) CurrentUsage() int64 {
 
 // MaxSize returns maximum pool size - O(1)
 func (mp *MemoryPool) MaxSize() int64 {
-	return mp.maxSize
+	return atomic.LoadInt64(&mp.maxSize)
 }
 
 // AvailableSpace returns available memory - O(1)
 func (mp *MemoryPool) AvailableSpace() int64 {
-	return mp.maxSize - atomic.LoadInt64(&mp.currentUsage)
+	return atomic.LoadInt64(&mp.maxSize) - atomic.LoadInt64(&mp.currentUsage)
 }
 
 // MemoryPressure calculates current memory pressure (0.0 to 1.0) - O(1)
 func (mp *MemoryPool) MemoryPressure() float64 {
-	return float64(atomic.LoadInt64(&mp.currentUsage)) / float64(mp.maxSize)
+	return float64(atomic.LoadInt64(&mp.currentUsage)) / float64(atomic.LoadInt64(&mp.maxSize))
 }
 
 // checkMemoryPressure evaluates current pressure and triggers appropriate callbacks
@@ -182,13 +205,14 @@ func (mp *MemoryPool) GetStats() map[string]interface{} {
 	mp.mutex.RUnlock()
 
 	currentUsage := atomic.LoadInt64(&mp.currentUsage)
-	pressure := float64(currentUsage) / float64(mp.maxSize)
+	maxSize := atomic.LoadInt64(&mp.maxSize)
+	pressure := float64(currentUsage) / float64(maxSize)
 
 	return map[string]interface{}{
 		"name":                mp.name,
-		"max_size":            mp.maxSize,
+		"max_size":            maxSize,
 		"current_usage":       currentUsage,
-		"available_space":     mp.maxSize - currentUsage,
+		"available_space":     maxSize - currentUsage,
 		"memory_pressure":     pressure,
 		"active_allocations":  activeAllocations,
 		"total_allocations":   atomic.LoadInt64(&mp.totalAllocations),
@@ -213,7 +237,7 @@ func (mp *MemoryPool) Resize(newMaxSize int64) error {
 			newMaxSize, currentUsage)
 	}
 
-	mp.maxSize = newMaxSize
+	atomic.StoreInt64(&mp.maxSize, newMaxSize)
 	return nil
 }
 
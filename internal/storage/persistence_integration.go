@@ -33,13 +33,23 @@ func (s *BasicStore) StartPersistence(ctx context.Context) error {
 	return nil
 }
 
+// SetSyncPolicy changes the AOF sync policy of the store's persistence
+// engine, if it has one
+func (s *BasicStore) SetSyncPolicy(policy string) {
+	if he, ok := s.persistEngine.(*persistence.HybridEngine); ok {
+		he.SetSyncPolicy(policy)
+	}
+}
+
 // getSnapshotData returns current cache data for snapshot/compaction use.
 // Uses per-shard RLock (non-blocking) and copies raw bytes to avoid deserialization overhead.
+// Each value is a persistence.SnapshotEntry carrying the value type, expiry
+// and session, so a snapshot restores keys exactly.
 func (s *BasicStore) getSnapshotData() map[string]interface{} {
 	items := s.data.SnapshotRawData()
 	data := make(map[string]interface{}, len(items))
 	for _, item := range items {
-		data[item.Key] = string(item.RawBytes)
+		data[item.Key] = snapshotEntry(item)
 	}
 	return data
 }
@@ -104,19 +114,26 @@ func (s *BasicStore) recoverFromPersistence() error {
 	for _, entry := range entries {
 		switch entry.Operation {
 		case "SET":
-			value := string(entry.Value)
+			expiresAt := entry.ExpiresAt
+			if expiresAt.IsZero() && entry.TTL > 0 {
+				expiresAt = entry.Timestamp.Add(time.Duration(entry.TTL) * time.Second)
+			}
+			if !expiresAt.IsZero() && s.now().After(expiresAt) {
+				continue
+			}
 
-			var ttl time.Duration
-			if entry.TTL > 0 {
-				createdAt := entry.Timestamp
-				expiresAt := createdAt.Add(time.Duration(entry.TTL) * time.Second)
-				if time.Now().After(expiresAt) {
-					continue
+			// Snapshot entries record the stored type; AOF entries are strings
+			var err error
+			if entry.ValueType != "" {
+				err = s.restoreInternal(entry.Key, entry.Value, entry.ValueType, entry.SessionID, expiresAt)
+			} else {
+				var ttl time.Duration
+				if !expiresAt.IsZero() {
+					ttl = expiresAt.Sub(s.now())
 				}
-				ttl = time.Until(expiresAt)
+				err = s.setInternal(entry.Key, string(entry.Value), entry.SessionID, ttl)
 			}
-
-			if err := s.setInternal(entry.Key, value, entry.SessionID, ttl); err != nil {
+			if err != nil {
 				logging.Warn(nil, logging.ComponentStorage, logging.ActionRestore, "Failed to recover SET", map[string]interface{}{"key": entry.Key, "error": err.Error()})
 				errorCount++
 				continue
@@ -133,6 +150,22 @@ func (s *BasicStore) recoverFromPersistence() error {
 				recoveredCount++
 			}
 
+		case "EXPIRE":
+			// TTL counts from the entry's timestamp; NoExpiry removes the expiry
+			var expiresAt time.Time
+			if entry.TTL > 0 {
+				expiresAt = entry.Timestamp.Add(time.Duration(entry.TTL) * time.Second)
+			}
+			if !expiresAt.IsZero() && s.now().After(expiresAt) {
+				if s.deleteInternal(entry.Key) == nil {
+					recoveredCount++
+				}
+				continue
+			}
+			if s.setExpiry(entry.Key, expiresAt) {
+				recoveredCount++
+			}
+
 		case "CLEAR":
 			s.clearInternal()
 			recoveredCount++
@@ -154,20 +187,30 @@ func (s *BasicStore) setInternal(key string, value interface{}, sessionID string
 		return fmt.Errorf("failed to serialize value: %w", err)
 	}
 
+	expiresAt := time.Time{}
+	if ttl > 0 {
+		expiresAt = s.now().Add(ttl)
+	}
+	return s.restoreInternal(key, serializedData, valueType, sessionID, expiresAt)
+}
+
+// restoreInternal stores an already serialized value of valueType, without
+// persistence logging (used for recovery)
+func (s *BasicStore) restoreInternal(key string, serializedData []byte, valueType, sessionID string, expiresAt time.Time) error {
 	size := uint64(len(serializedData))
 
-	allocatedMemory, err := s.memPool.Allocate(int64(size))
+	allocatedMemory, err := s.allocateValue(serializedData)
 	if err != nil {
 		return fmt.Errorf("failed to allocate memory: %w", err)
 	}
-	copy(allocatedMemory, serializedData)
 
 	// Handle existing item via ShardedMap
 	s.data.LockShard(key)
 	sh := s.data.getShard(key)
-	if existingItem, exists := sh.items[key]; exists {
+	existingItem, existed := sh.items[key]
+	if existed {
 		if oldPtr, ptrExists := sh.allocatedPtrs[key]; ptrExists {
-			_ = s.memPool.Free(oldPtr)
+			s.freeValue(oldPtr)
 		}
 		oldEntry := s.itemToEntry(key, existingItem)
 		s.evictPolicy.OnDelete(oldEntry)
@@ -177,25 +220,21 @@ func (s *BasicStore) setInternal(key string, value interface{}, sessionID string
 		})
 	}
 
-	expiresAt := time.Time{}
-	if ttl > 0 {
-		expiresAt = time.Now().Add(ttl)
-	}
-
 	item := &CacheItem{
 		Key:              key,
 		ValuePtr:         allocatedMemory,
 		ValueType:        valueType,
 		Size:             size,
-		CreatedAt:        time.Now(),
+		CreatedAt:        s.now(),
 		ExpiresAt:        expiresAt,
 		SessionID:        sessionID,
-		AccessCount:      0,
-		LastAccessed:     time.Now(),
 		LamportTimestamp: 0,
+		Version:          s.versionClock.Add(1),
 	}
+	item.lastAccessed.Store(item.CreatedAt.UnixNano())
 
 	sh.items[key] = item
+	s.data.indexKey(key)
 	sh.allocatedPtrs[key] = allocatedMemory
 	s.data.UnlockShard(key)
 
@@ -207,7 +246,7 @@ func (s *BasicStore) setInternal(key string, value interface{}, sessionID string
 	entry := s.itemToEntry(key, item)
 	s.evictPolicy.OnInsert(entry)
 
-	if s.filter != nil {
+	if s.filter != nil && !existed {
 		_ = s.filter.Add([]byte(key))
 	}
 
@@ -222,7 +261,7 @@ func (s *BasicStore) deleteInternal(key string) error {
 	}
 
 	if allocPtr != nil {
-		_ = s.memPool.Free(allocPtr)
+		s.freeValue(allocPtr)
 	}
 
 	entry := s.itemToEntry(key, item)
@@ -233,7 +272,7 @@ func (s *BasicStore) deleteInternal(key string) error {
 		s.stats.TotalMemory -= item.Size
 	})
 
-	if s.filter != nil {
+	if s.filter != nil && !s.config.FilterSkipDelete {
 		s.filter.Delete([]byte(key))
 	}
 
@@ -244,7 +283,7 @@ func (s *BasicStore) deleteInternal(key string) error {
 func (s *BasicStore) clearInternal() {
 	s.data.RangeAll(func(key string, item *CacheItem) bool {
 		if ptr, ok := s.data.GetAllocatedPtr(key); ok {
-			_ = s.memPool.Free(ptr)
+			s.freeValue(ptr)
 		}
 		return true
 	})
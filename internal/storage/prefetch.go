@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"sync"
+
+	"hypercache/internal/metrics"
+)
+
+// DefaultPrefetchMinAccess is how many reads a key needs before its
+// deserialized value is kept in the prefetch cache
+const DefaultPrefetchMinAccess = 8
+
+// prefetchEntry is a deserialized value kept for a hot key
+type prefetchEntry struct {
+	value       interface{}
+	version     uint64 // CacheItem.Version the value was decoded from
+	accessCount uint64 // CacheItem.AccessCount when the entry was stored
+}
+
+// prefetchCache keeps deserialized values of the hottest keys so Get can
+// skip decoding them. It holds at most size keys; a new key only displaces
+// the entry with the lowest access count if it has been read more often.
+// Entries are dropped on any mutation of their key and are also checked
+// against the item version, so a stale value is never returned.
+type prefetchCache struct {
+	mu        sync.RWMutex
+	entries   map[string]*prefetchEntry
+	size      int
+	minAccess uint64
+}
+
+// newPrefetchCache creates a prefetch cache holding up to size keys
+func newPrefetchCache(size int, minAccess uint64) *prefetchCache {
+	if minAccess == 0 {
+		minAccess = DefaultPrefetchMinAccess
+	}
+	return &prefetchCache{
+		entries:   make(map[string]*prefetchEntry, size),
+		size:      size,
+		minAccess: minAccess,
+	}
+}
+
+// get returns the prefetched value of key if it was decoded from version
+func (p *prefetchCache) get(key string, version uint64) (interface{}, bool) {
+	p.mu.RLock()
+	entry, ok := p.entries[key]
+	p.mu.RUnlock()
+	if !ok || entry.version != version {
+		return nil, false
+	}
+	metrics.Global().IncCounter("hypercache_prefetch_hits_total")
+	return entry.value, true
+}
+
+// offer stores a freshly decoded value if the key is hot enough
+func (p *prefetchCache) offer(key string, version, accessCount uint64, value interface{}) {
+	if accessCount < p.minAccess {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.entries[key]; !ok && len(p.entries) >= p.size {
+		coldest, coldestCount := "", accessCount
+		for k, e := range p.entries {
+			if e.accessCount < coldestCount {
+				coldest, coldestCount = k, e.accessCount
+			}
+		}
+		if coldest == "" {
+			return
+		}
+		delete(p.entries, coldest)
+	}
+	p.entries[key] = &prefetchEntry{value: value, version: version, accessCount: accessCount}
+}
+
+// invalidate drops the prefetched value of key
+func (p *prefetchCache) invalidate(key string) {
+	p.mu.RLock()
+	_, ok := p.entries[key]
+	p.mu.RUnlock()
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	delete(p.entries, key)
+	p.mu.Unlock()
+}
+
+// clear drops every prefetched value
+func (p *prefetchCache) clear() {
+	p.mu.Lock()
+	p.entries = make(map[string]*prefetchEntry, p.size)
+	p.mu.Unlock()
+}
+
+// len returns the number of prefetched keys
+func (p *prefetchCache) len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.entries)
+}
+
+// prefetchable reports whether an item's decoded value may be prefetched.
+// Strings and []byte decode to a plain copy, and lists and sets are modified
+// in place by typed commands, so only numbers and JSON documents qualify.
+func prefetchable(item *CacheItem) bool {
+	return !item.IsStringType() && item.Kind() == TypeString
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkBasicStore_GetHotKey compares GETs of a hot key holding a large
+// JSON-encoded document with and without the prefetch cache
+func BenchmarkBasicStore_GetHotKey(b *testing.B) {
+	doc := make(map[string]interface{}, 500)
+	for i := 0; i < 500; i++ {
+		doc[fmt.Sprintf("field-%d", i)] = map[string]interface{}{"id": i, "name": fmt.Sprintf("value-%d", i)}
+	}
+
+	for _, prefetchKeys := range []int{0, 16} {
+		b.Run(fmt.Sprintf("prefetch=%d", prefetchKeys), func(b *testing.B) {
+			store, err := NewBasicStore(BasicStoreConfig{
+				Name:         "bench-prefetch",
+				MaxMemory:    100 * 1024 * 1024,
+				PrefetchKeys: prefetchKeys,
+			})
+			if err != nil {
+				b.Fatalf("Failed to create store: %v", err)
+			}
+			defer store.Close()
+
+			if err := store.Set("hot", doc, "", 0); err != nil {
+				b.Fatalf("Set failed: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Get("hot"); err != nil {
+					b.Fatalf("Get failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+// DefaultScanCount is how many keys Scan examines per call when the caller
+// gives no count, like Redis SCAN's COUNT default
+const DefaultScanCount = 10
+
+// ScanOptions filters the keys Scan returns
+type ScanOptions struct {
+	Count int    // Keys to examine per call, a hint (<= 0 = DefaultScanCount)
+	Match string // Glob pattern keys must match (see MatchPattern; "" = any)
+	Type  string // Value kind keys must hold, as reported by TYPE ("" = any)
+}
+
+// Scan iterates the keyspace like Redis SCAN. Start with cursor 0 and call
+// again with the returned cursor until it is 0. The cursor is a shard
+// index: each call examines whole shards until at least opts.Count keys
+// were examined, so a key present for the whole iteration is returned
+// exactly once, while one added or removed meanwhile may or may not be.
+// MATCH and TYPE are applied after examining, so a call may return fewer
+// keys than Count, or none, before the iteration is over.
+//
+// No snapshot is taken at cursor 0 and no state is kept between calls:
+// each call reads the shards' current contents under their read locks. A
+// key deleted mid-scan is simply not seen again, overwriting a key doesn't
+// move it to another shard, and a cursor past the last shard ends the
+// iteration, so concurrent writes never make a scan fail or repeat keys.
+func (s *BasicStore) Scan(cursor uint64, opts ScanOptions) (uint64, []string) {
+	count := opts.Count
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	shards := uint64(s.data.ShardCount())
+	var keys []string
+	examined := 0
+	now := s.now()
+	for ; cursor < shards && examined < count; cursor++ {
+		s.data.RangeShard(int(cursor), func(key string, item *CacheItem) {
+			examined++
+			if item.IsExpired(now) {
+				return
+			}
+			if opts.Match != "" && !MatchPattern(opts.Match, key) {
+				return
+			}
+			if opts.Type != "" && item.Kind() != opts.Type {
+				return
+			}
+			keys = append(keys, key)
+		})
+	}
+	if cursor >= shards {
+		cursor = 0
+	}
+	return cursor, keys
+}
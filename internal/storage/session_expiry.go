@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"hypercache/internal/logging"
+	"hypercache/internal/metrics"
+)
+
+// maxSessionSweepInterval caps how long an idle session may outlive its
+// timeout before the sweeper removes it
+const maxSessionSweepInterval = time.Minute
+
+// DeleteSession deletes every live key written under sessionID, e.g. when a
+// user logs out. Each key's session is checked again under its shard lock,
+// so a key rewritten by another session in the meantime is kept. Returns the
+// number of keys deleted.
+func (s *BasicStore) DeleteSession(sessionID string) (int, error) {
+	return s.deleteSession(sessionID, "del", time.Time{})
+}
+
+// deleteSession deletes sessionID's keys, reporting each to the keyspace
+// notifier as event. With writtenBefore set, keys written at or after it are
+// kept.
+func (s *BasicStore) deleteSession(sessionID string, event string, writtenBefore time.Time) (int, error) {
+	if sessionID == "" {
+		return 0, fmt.Errorf("session ID cannot be empty")
+	}
+	if s.config.SessionIdleTimeout > 0 {
+		s.sessions.Delete(sessionID)
+	}
+
+	inSession := func(item *CacheItem) bool {
+		return item.SessionID == sessionID && (writtenBefore.IsZero() || item.CreatedAt.Before(writtenBefore))
+	}
+	var keys []string
+	now := s.now()
+	s.data.RangeAll(func(key string, item *CacheItem) bool {
+		if inSession(item) && !item.IsExpired(now) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+
+	deleted := 0
+	for _, key := range keys {
+		if s.deleteIf(key, event, inSession) == nil {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// touchSession records activity on sessionID: a write or read of one of its
+// keys. Only tracked when SessionIdleTimeout is set.
+func (s *BasicStore) touchSession(sessionID string) {
+	if sessionID == "" || s.config.SessionIdleTimeout <= 0 {
+		return
+	}
+	now := s.now().UnixNano()
+	if last, ok := s.sessions.Load(sessionID); ok {
+		last.(*atomic.Int64).Store(now)
+		return
+	}
+	last := new(atomic.Int64)
+	last.Store(now)
+	if existing, loaded := s.sessions.LoadOrStore(sessionID, last); loaded {
+		existing.(*atomic.Int64).Store(now)
+	}
+}
+
+// sweepIdleSessions periodically expires sessions idle for longer than
+// SessionIdleTimeout
+func (s *BasicStore) sweepIdleSessions() {
+	defer close(s.sessionDone)
+
+	interval := s.config.SessionIdleTimeout / 2
+	if interval > maxSessionSweepInterval {
+		interval = maxSessionSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.expireIdleSessions(s.now())
+		case <-s.sessionStop:
+			return
+		}
+	}
+}
+
+// expireIdleSessions deletes the keys of every session with no activity in
+// the SessionIdleTimeout before now. Keys the session writes from now on
+// start it afresh. Returns the number of sessions expired.
+func (s *BasicStore) expireIdleSessions(now time.Time) int {
+	cutoff := now.Add(-s.config.SessionIdleTimeout).UnixNano()
+
+	var idle []string
+	s.sessions.Range(func(key, value interface{}) bool {
+		if value.(*atomic.Int64).Load() <= cutoff {
+			idle = append(idle, key.(string))
+		}
+		return true
+	})
+
+	for _, sessionID := range idle {
+		deleted, _ := s.deleteSession(sessionID, "expired", now)
+		metrics.Global().IncCounter("hypercache_sessions_expired_total")
+		logging.Debug(nil, logging.ComponentStorage, logging.ActionCleanup, "Expired idle session", map[string]interface{}{
+			"store":        s.config.Name,
+			"session_id":   sessionID,
+			"keys_deleted": deleted,
+		})
+	}
+	return len(idle)
+}
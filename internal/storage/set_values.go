@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"sort"
+)
+
+// SetOp selects the set algebra operation for SetCombine
+type SetOp int
+
+const (
+	SetOpInter SetOp = iota // Members present in every set
+	SetOpUnion              // Members present in any set
+	SetOpDiff               // Members of the first set not present in the others
+)
+
+// NewSetValue creates a set holding members
+func NewSetValue(members ...string) SetValue {
+	set := make(SetValue, len(members))
+	for _, m := range members {
+		set[m] = struct{}{}
+	}
+	return set
+}
+
+// Members returns the set's members in sorted order
+func (set SetValue) Members() []string {
+	members := make([]string, 0, len(set))
+	for m := range set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// setValue decodes the set held by item. No item is an empty set.
+func setValue(item *CacheItem) (SetValue, error) {
+	if item == nil {
+		return SetValue{}, nil
+	}
+	if item.Kind() != TypeSet {
+		return nil, ErrWrongType
+	}
+	value, err := item.GetValue()
+	if err != nil {
+		return nil, err
+	}
+	return value.(SetValue), nil
+}
+
+// getSet returns the set stored at key. A missing key yields an empty set.
+func (s *BasicStore) getSet(key string) (SetValue, error) {
+	return setValue(s.liveItem(key))
+}
+
+// SetAdd adds members to the set at key, creating it if needed, and returns
+// how many were not already present.
+func (s *BasicStore) SetAdd(key string, members ...string) (int, error) {
+	var added int
+	err := s.updateTyped(key, func(item *CacheItem) (interface{}, bool, error) {
+		set, err := setValue(item)
+		if err != nil {
+			return nil, false, err
+		}
+
+		added = 0
+		for _, m := range members {
+			if _, ok := set[m]; !ok {
+				set[m] = struct{}{}
+				added++
+			}
+		}
+		return set, added > 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// SetRemove removes members from the set at key and returns how many were removed.
+// The key is deleted once the set is empty.
+func (s *BasicStore) SetRemove(key string, members ...string) (int, error) {
+	var removed int
+	err := s.updateTyped(key, func(item *CacheItem) (interface{}, bool, error) {
+		set, err := setValue(item)
+		if err != nil {
+			return nil, false, err
+		}
+
+		removed = 0
+		for _, m := range members {
+			if _, ok := set[m]; ok {
+				delete(set, m)
+				removed++
+			}
+		}
+		if len(set) == 0 {
+			return nil, removed > 0, nil
+		}
+		return set, removed > 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// SetMembers returns the members of the set at key in sorted order
+func (s *BasicStore) SetMembers(key string) ([]string, error) {
+	set, err := s.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+	return set.Members(), nil
+}
+
+// SetIsMember reports whether member is in the set at key, reading the
+// stored set without decoding it
+func (s *BasicStore) SetIsMember(key, member string) (bool, error) {
+	item, err := s.setItem(key)
+	if item == nil || err != nil {
+		return false, err
+	}
+	return setContains(item.ValuePtr, member)
+}
+
+// SetCard returns the number of members in the set at key
+func (s *BasicStore) SetCard(key string) (int, error) {
+	item, err := s.setItem(key)
+	if item == nil || err != nil {
+		return 0, err
+	}
+	return setCard(item.ValuePtr)
+}
+
+// setItem returns the live item holding the set at key, nil if there is
+// none
+func (s *BasicStore) setItem(key string) (*CacheItem, error) {
+	item := s.liveItem(key)
+	if item != nil && item.Kind() != TypeSet {
+		return nil, ErrWrongType
+	}
+	return item, nil
+}
+
+// SetCombine applies op across the sets at keys and returns the resulting
+// members in sorted order. Missing keys count as empty sets.
+func (s *BasicStore) SetCombine(op SetOp, keys ...string) ([]string, error) {
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return nil, err
+	}
+	return result.Members(), nil
+}
+
+// SetCombineStore applies op across the sets at keys, stores the result at
+// dest (replacing any existing value) and returns its size.
+func (s *BasicStore) SetCombineStore(op SetOp, dest string, keys ...string) (int, error) {
+	s.typedMu.Lock()
+	defer s.typedMu.Unlock()
+
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return 0, err
+	}
+	// dest is replaced whatever it held, so there is nothing to read first
+	if len(result) == 0 {
+		if s.data.Exists(dest) {
+			err = s.deleteWithEvent(dest, "del")
+		}
+	} else {
+		err = s.setWithContextInternal(nil, dest, result, "", 0, 0)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(result), nil
+}
+
+// combineSets computes op across the sets at keys
+func (s *BasicStore) combineSets(op SetOp, keys []string) (SetValue, error) {
+	sets := make([]SetValue, len(keys))
+	for i, key := range keys {
+		set, err := s.getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+
+	result := SetValue{}
+	if len(sets) == 0 {
+		return result, nil
+	}
+
+	switch op {
+	case SetOpUnion:
+		for _, set := range sets {
+			for m := range set {
+				result[m] = struct{}{}
+			}
+		}
+	case SetOpInter:
+	members:
+		for m := range sets[0] {
+			for _, set := range sets[1:] {
+				if _, ok := set[m]; !ok {
+					continue members
+				}
+			}
+			result[m] = struct{}{}
+		}
+	case SetOpDiff:
+	diffMembers:
+		for m := range sets[0] {
+			for _, set := range sets[1:] {
+				if _, ok := set[m]; ok {
+					continue diffMembers
+				}
+			}
+			result[m] = struct{}{}
+		}
+	}
+	return result, nil
+}
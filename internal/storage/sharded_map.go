@@ -1,13 +1,17 @@
 package storage
 
 import (
+	"math/bits"
 	"math/rand/v2"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cespare/xxhash/v2"
 )
 
-const numShards = 32
+// DefaultShards is the number of partitions a ShardedMap uses unless configured
+const DefaultShards = 32
 
 // shard is a single partition of the sharded map
 type shard struct {
@@ -15,17 +19,40 @@ type shard struct {
 	allocatedPtrs map[string][]byte
 	tombstones    map[string]struct{} // lightweight tombstone set (expiry managed externally)
 	mu            sync.RWMutex
+
+	// Read counters live with the shard so hits and misses on different
+	// shards never touch a shared cache line
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	_      [64]byte // pad to keep neighbouring shards' hot fields apart
 }
 
-// ShardedMap is a concurrent map split into numShards independent partitions.
-// Each shard has its own lock, eliminating the global mutex bottleneck.
+// ShardedMap is a concurrent map split into a power-of-two number of
+// independent partitions. Each shard has its own lock, eliminating the global
+// mutex bottleneck. Operations spanning all shards lock one shard at a time in
+// index order and never hold two shard locks at once, so they cannot deadlock
+// with each other or with single-key operations.
 type ShardedMap struct {
-	shards [numShards]shard
+	shards []shard
+	mask   uint64
+	slots  *slotIndex // nil unless EnableSlotIndex was called
+	clock  Clock      // Time source for expiry checks
 }
 
-// NewShardedMap creates a new sharded map
+// NewShardedMap creates a new sharded map with DefaultShards partitions
 func NewShardedMap() *ShardedMap {
-	sm := &ShardedMap{}
+	return NewShardedMapN(DefaultShards)
+}
+
+// NewShardedMapN creates a sharded map with n partitions, rounded up to a
+// power of two (n <= 0 = DefaultShards)
+func NewShardedMapN(n int) *ShardedMap {
+	if n <= 0 {
+		n = DefaultShards
+	}
+	n = 1 << bits.Len(uint(n-1))
+
+	sm := &ShardedMap{shards: make([]shard, n), mask: uint64(n - 1), clock: RealClock}
 	for i := range sm.shards {
 		sm.shards[i].items = make(map[string]*CacheItem)
 		sm.shards[i].allocatedPtrs = make(map[string][]byte)
@@ -37,7 +64,31 @@ func NewShardedMap() *ShardedMap {
 // getShard returns the shard for a given key
 func (sm *ShardedMap) getShard(key string) *shard {
 	h := xxhash.Sum64String(key)
-	return &sm.shards[h%numShards]
+	return &sm.shards[h&sm.mask]
+}
+
+// ShardCount returns the number of partitions
+func (sm *ShardedMap) ShardCount() int {
+	return len(sm.shards)
+}
+
+// RecordHit counts a read hit on the shard owning key
+func (sm *ShardedMap) RecordHit(key string) {
+	sm.getShard(key).hits.Add(1)
+}
+
+// RecordMiss counts a read miss on the shard owning key
+func (sm *ShardedMap) RecordMiss(key string) {
+	sm.getShard(key).misses.Add(1)
+}
+
+// ReadCounts returns hits and misses summed across all shards
+func (sm *ShardedMap) ReadCounts() (hits, misses uint64) {
+	for i := range sm.shards {
+		hits += sm.shards[i].hits.Load()
+		misses += sm.shards[i].misses.Load()
+	}
+	return hits, misses
 }
 
 // Get retrieves an item by key
@@ -56,6 +107,7 @@ func (sm *ShardedMap) Set(key string, item *CacheItem, allocPtr []byte) {
 	s.items[key] = item
 	s.allocatedPtrs[key] = allocPtr
 	delete(s.tombstones, key) // clear tombstone on re-creation
+	sm.indexKey(key)
 	s.mu.Unlock()
 }
 
@@ -72,6 +124,27 @@ func (sm *ShardedMap) Delete(key string) (*CacheItem, []byte, bool) {
 	delete(s.items, key)
 	delete(s.allocatedPtrs, key)
 	s.tombstones[key] = struct{}{}
+	sm.unindexKey(key)
+	s.mu.Unlock()
+	return item, ptr, true
+}
+
+// DeleteIf is like Delete, but removes the item only if cond, called under
+// the shard lock, returns true. Reports false if the key is absent or cond
+// rejects it.
+func (sm *ShardedMap) DeleteIf(key string, cond func(item *CacheItem) bool) (*CacheItem, []byte, bool) {
+	s := sm.getShard(key)
+	s.mu.Lock()
+	item, exists := s.items[key]
+	if !exists || !cond(item) {
+		s.mu.Unlock()
+		return nil, nil, false
+	}
+	ptr := s.allocatedPtrs[key]
+	delete(s.items, key)
+	delete(s.allocatedPtrs, key)
+	s.tombstones[key] = struct{}{}
+	sm.unindexKey(key)
 	s.mu.Unlock()
 	return item, ptr, true
 }
@@ -99,6 +172,24 @@ func (sm *ShardedMap) Exists(key string) bool {
 	return ok
 }
 
+// expired reports whether item has expired by the map's clock
+func (sm *ShardedMap) expired(item *CacheItem) bool {
+	if item.ExpiresAt.IsZero() {
+		return false
+	}
+	return item.IsExpired(sm.clock.Now())
+}
+
+// ExistsLive checks if a key exists and has not expired, under the shard read lock
+func (sm *ShardedMap) ExistsLive(key string) bool {
+	s := sm.getShard(key)
+	s.mu.RLock()
+	item, ok := s.items[key]
+	live := ok && !sm.expired(item)
+	s.mu.RUnlock()
+	return live
+}
+
 // IsTombstoned checks if a key was recently deleted
 func (sm *ShardedMap) IsTombstoned(key string) bool {
 	s := sm.getShard(key)
@@ -127,6 +218,23 @@ func (sm *ShardedMap) Size() int {
 	return total
 }
 
+// LiveSize returns the number of items that have not expired. Unlike Size it
+// excludes expired items the cleanup loop has not swept yet.
+func (sm *ShardedMap) LiveSize() int {
+	total := 0
+	now := sm.clock.Now()
+	for i := range sm.shards {
+		sm.shards[i].mu.RLock()
+		for _, item := range sm.shards[i].items {
+			if !item.IsExpired(now) {
+				total++
+			}
+		}
+		sm.shards[i].mu.RUnlock()
+	}
+	return total
+}
+
 // RangeAll calls fn for every item across all shards. fn must NOT modify the map.
 // If fn returns false, iteration stops.
 func (sm *ShardedMap) RangeAll(fn func(key string, item *CacheItem) bool) {
@@ -142,6 +250,16 @@ func (sm *ShardedMap) RangeAll(fn func(key string, item *CacheItem) bool) {
 	}
 }
 
+// RangeShard calls fn for every item in shard i (0 <= i < ShardCount). fn
+// must NOT modify the map.
+func (sm *ShardedMap) RangeShard(i int, fn func(key string, item *CacheItem)) {
+	sm.shards[i].mu.RLock()
+	for k, v := range sm.shards[i].items {
+		fn(k, v)
+	}
+	sm.shards[i].mu.RUnlock()
+}
+
 // DeleteFromShard deletes a key while the shard is already locked (used during eviction).
 // Caller must hold the shard write lock.
 func (sm *ShardedMap) DeleteUnsafe(key string) (*CacheItem, []byte, bool) {
@@ -153,6 +271,7 @@ func (sm *ShardedMap) DeleteUnsafe(key string) (*CacheItem, []byte, bool) {
 	ptr := s.allocatedPtrs[key]
 	delete(s.items, key)
 	delete(s.allocatedPtrs, key)
+	sm.unindexKey(key)
 	return item, ptr, true
 }
 
@@ -166,10 +285,23 @@ func (sm *ShardedMap) UnlockShard(key string) {
 	sm.getShard(key).mu.Unlock()
 }
 
+// RLockShard read-locks the shard for a given key
+func (sm *ShardedMap) RLockShard(key string) {
+	sm.getShard(key).mu.RLock()
+}
+
+// RUnlockShard read-unlocks the shard for a given key
+func (sm *ShardedMap) RUnlockShard(key string) {
+	sm.getShard(key).mu.RUnlock()
+}
+
 // Clear removes all items from all shards
 func (sm *ShardedMap) Clear() {
 	for i := range sm.shards {
 		sm.shards[i].mu.Lock()
+		for key := range sm.shards[i].items {
+			sm.unindexKey(key)
+		}
 		sm.shards[i].items = make(map[string]*CacheItem)
 		sm.shards[i].allocatedPtrs = make(map[string][]byte)
 		sm.shards[i].tombstones = make(map[string]struct{})
@@ -208,6 +340,7 @@ func (sm *ShardedMap) SampleKeys(n int) []string {
 		return nil
 	}
 	result := make([]string, 0, n)
+	numShards := len(sm.shards)
 	startShard := rand.IntN(numShards)
 	for attempt := 0; attempt < numShards && len(result) < n; attempt++ {
 		idx := (startShard + attempt) % numShards
@@ -233,6 +366,9 @@ type SnapshotItem struct {
 	RawBytes  []byte
 	ValueType string
 	Size      uint64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	SessionID string
 }
 
 // SnapshotRawData returns a copy of all keys with their raw bytes and value types.
@@ -255,6 +391,9 @@ func (sm *ShardedMap) SnapshotRawData() []SnapshotItem {
 				RawBytes:  raw,
 				ValueType: item.ValueType,
 				Size:      item.Size,
+				CreatedAt: item.CreatedAt,
+				ExpiresAt: item.ExpiresAt,
+				SessionID: item.SessionID,
 			})
 		}
 		sm.shards[i].mu.RUnlock()
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// BenchmarkBasicStore_ParallelSetGetShards runs a write-heavy parallel
+// workload (1 SET per 3 GETs) with different shard counts, showing how
+// throughput scales as the keyspace is split across more locks
+func BenchmarkBasicStore_ParallelSetGetShards(b *testing.B) {
+	const numKeys = 10000
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+
+	for _, shards := range []int{1, 4, 32, 256} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			store, err := NewBasicStore(BasicStoreConfig{
+				Name:      "bench-shards",
+				MaxMemory: 100 * 1024 * 1024,
+				Shards:    shards,
+			})
+			if err != nil {
+				b.Fatalf("Failed to create store: %v", err)
+			}
+			defer store.Close()
+
+			for _, key := range keys {
+				_ = store.Set(key, "value", "", 0)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := keys[i%numKeys]
+					if i%4 == 0 {
+						_ = store.Set(key, "value", "", 0)
+					} else {
+						_, _ = store.Get(key)
+					}
+					i += 7919 // stride over the keyspace so goroutines spread across shards
+				}
+			})
+		})
+	}
+}
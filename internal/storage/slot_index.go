@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"sync"
+
+	"hypercache/internal/cluster"
+)
+
+// slotIndexStripes is the number of locks guarding the slot index; slots
+// are spread over them so writes to different slots rarely contend
+const slotIndexStripes = 64
+
+// slotIndex maps each Redis Cluster hash slot to the keys stored in it, so
+// the keys of a slot can be listed without hashing every key in the store.
+// It is maintained by ShardedMap under the owning shard's lock, which keeps
+// it in step with the map: a key is indexed exactly while it is present
+// (including expired keys not yet swept).
+type slotIndex struct {
+	locks [slotIndexStripes]sync.RWMutex
+	slots [cluster.HashSlotCount]map[string]struct{}
+}
+
+// add indexes key under its slot
+func (x *slotIndex) add(key string) {
+	slot := cluster.GetHashSlot(key)
+	mu := &x.locks[slot%slotIndexStripes]
+	mu.Lock()
+	if x.slots[slot] == nil {
+		x.slots[slot] = make(map[string]struct{})
+	}
+	x.slots[slot][key] = struct{}{}
+	mu.Unlock()
+}
+
+// remove drops key from the index
+func (x *slotIndex) remove(key string) {
+	slot := cluster.GetHashSlot(key)
+	mu := &x.locks[slot%slotIndexStripes]
+	mu.Lock()
+	delete(x.slots[slot], key)
+	if len(x.slots[slot]) == 0 {
+		x.slots[slot] = nil
+	}
+	mu.Unlock()
+}
+
+// keys returns the keys indexed under slot
+func (x *slotIndex) keys(slot uint16) []string {
+	mu := &x.locks[slot%slotIndexStripes]
+	mu.RLock()
+	defer mu.RUnlock()
+	keys := make([]string, 0, len(x.slots[slot]))
+	for key := range x.slots[slot] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// EnableSlotIndex starts maintaining a hash slot to keys index, used by
+// KeysInSlot. Call it before the map is used.
+func (sm *ShardedMap) EnableSlotIndex() {
+	sm.slots = &slotIndex{}
+}
+
+// indexKey records that key is present. Caller must hold the key's shard
+// write lock.
+func (sm *ShardedMap) indexKey(key string) {
+	if sm.slots != nil {
+		sm.slots.add(key)
+	}
+}
+
+// unindexKey records that key was removed. Caller must hold the key's shard
+// write lock.
+func (sm *ShardedMap) unindexKey(key string) {
+	if sm.slots != nil {
+		sm.slots.remove(key)
+	}
+}
+
+// KeysInSlot returns up to count live keys (count <= 0 = all) that hash to
+// slot. With the slot index enabled this costs O(keys in the slot);
+// otherwise every key is hashed.
+func (sm *ShardedMap) KeysInSlot(slot uint16, count int) []string {
+	if slot >= cluster.HashSlotCount {
+		return nil
+	}
+
+	var keys []string
+	if sm.slots != nil {
+		for _, key := range sm.slots.keys(slot) {
+			if count > 0 && len(keys) >= count {
+				break
+			}
+			if sm.ExistsLive(key) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+
+	now := sm.clock.Now()
+	sm.RangeAll(func(key string, item *CacheItem) bool {
+		if cluster.GetHashSlot(key) == slot && !item.IsExpired(now) {
+			keys = append(keys, key)
+		}
+		return count <= 0 || len(keys) < count
+	})
+	return keys
+}
+
+// KeysInSlot returns up to count keys (count <= 0 = all) of the store that
+// hash to the Redis Cluster slot, e.g. to migrate the slot to another node.
+// Fast when the store was created with SlotIndex.
+func (s *BasicStore) KeysInSlot(slot uint16, count int) []string {
+	return s.data.KeysInSlot(slot, count)
+}
+
+// CountKeysInSlot returns the number of keys of the store in the slot
+func (s *BasicStore) CountKeysInSlot(slot uint16) int {
+	return len(s.data.KeysInSlot(slot, 0))
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// ErrSortNotNumeric is returned by Sort when a numeric sort meets a non-numeric element
+var ErrSortNotNumeric = errors.New("One or more scores can't be converted into double")
+
+// SortOptions controls Sort ordering and pagination
+type SortOptions struct {
+	Alpha  bool // Sort lexicographically instead of numerically
+	Desc   bool // Sort in descending order
+	Offset int  // Number of sorted elements to skip
+	Count  int  // Maximum elements to return (negative = all remaining)
+}
+
+// Sort returns the elements of the list or set at key in sorted order.
+// Elements are compared as numbers unless opts.Alpha is set. A missing key
+// yields an empty result; the stored value is never modified.
+func (s *BasicStore) Sort(key string, opts SortOptions) ([]string, error) {
+	var elems []string
+	switch s.Type(key) {
+	case TypeNone:
+		return []string{}, nil
+	case TypeList:
+		list, err := s.getList(key)
+		if err != nil {
+			return nil, err
+		}
+		elems = append([]string(nil), list...)
+	case TypeSet:
+		set, err := s.getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		elems = set.Members()
+	default:
+		return nil, ErrWrongType
+	}
+
+	if opts.Alpha {
+		sort.SliceStable(elems, func(i, j int) bool {
+			if opts.Desc {
+				return elems[i] > elems[j]
+			}
+			return elems[i] < elems[j]
+		})
+	} else {
+		scores := make(map[string]float64, len(elems))
+		for _, e := range elems {
+			score, err := strconv.ParseFloat(e, 64)
+			if err != nil {
+				return nil, ErrSortNotNumeric
+			}
+			scores[e] = score
+		}
+		sort.SliceStable(elems, func(i, j int) bool {
+			if opts.Desc {
+				return scores[elems[i]] > scores[elems[j]]
+			}
+			return scores[elems[i]] < scores[elems[j]]
+		})
+	}
+
+	start := opts.Offset
+	if start < 0 {
+		start = 0
+	}
+	if start > len(elems) {
+		start = len(elems)
+	}
+	end := len(elems)
+	if opts.Count >= 0 && start+opts.Count < end {
+		end = start + opts.Count
+	}
+	return elems[start:end], nil
+}
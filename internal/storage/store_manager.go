@@ -27,6 +27,13 @@ type StoreManager struct {
 	// Global config used as defaults for new stores
 	globalPersistence config.PersistenceConfig
 	globalCacheConfig config.CacheConfig
+	slotIndex         bool
+
+	// Keyspace notifier applied to every store (nil = disabled)
+	keyspaceNotifier func(storeName, event, key string)
+
+	// Active expiry paused in every store (SetActiveExpire)
+	activeExpireOff bool
 }
 
 // StoreManagerConfig holds configuration for the StoreManager.
@@ -35,6 +42,7 @@ type StoreManagerConfig struct {
 	MaxStores         int
 	GlobalPersistence config.PersistenceConfig
 	GlobalCacheConfig config.CacheConfig
+	SlotIndex         bool // Index keys by hash slot in every store (cluster mode)
 }
 
 // storeRegistryEntry is persisted to stores.json for runtime-created stores.
@@ -56,6 +64,7 @@ func NewStoreManager(cfg StoreManagerConfig) *StoreManager {
 		maxStores:         cfg.MaxStores,
 		globalPersistence: cfg.GlobalPersistence,
 		globalCacheConfig: cfg.GlobalCacheConfig,
+		slotIndex:         cfg.SlotIndex,
 	}
 }
 
@@ -88,6 +97,8 @@ func (sm *StoreManager) CreateStore(storeCfg config.StoreConfig, ctx context.Con
 			})
 	}
 
+	sm.applyKeyspaceNotifierLocked(storeCfg.Name, store)
+	store.SetActiveExpire(!sm.activeExpireOff)
 	sm.stores[storeCfg.Name] = store
 
 	logging.Info(nil, logging.ComponentStorage, logging.ActionStart, "Store created", map[string]interface{}{
@@ -101,6 +112,30 @@ func (sm *StoreManager) CreateStore(storeCfg config.StoreConfig, ctx context.Con
 	return nil
 }
 
+// SetKeyspaceNotifier installs a keyspace notifier on all current and future
+// stores. The callback receives the store name alongside the event and key.
+// Pass nil to disable notifications.
+func (sm *StoreManager) SetKeyspaceNotifier(fn func(storeName, event, key string)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.keyspaceNotifier = fn
+	for name, store := range sm.stores {
+		sm.applyKeyspaceNotifierLocked(name, store)
+	}
+}
+
+// applyKeyspaceNotifierLocked binds the manager's notifier to a single store.
+// Caller must hold sm.mu.
+func (sm *StoreManager) applyKeyspaceNotifierLocked(name string, store *BasicStore) {
+	if sm.keyspaceNotifier == nil {
+		store.SetKeyspaceNotifier(nil)
+		return
+	}
+	fn := sm.keyspaceNotifier
+	store.SetKeyspaceNotifier(func(event, key string) { fn(name, event, key) })
+}
+
 // GetStore returns a store by name. Returns nil if not found.
 func (sm *StoreManager) GetStore(name string) *BasicStore {
 	sm.mu.RLock()
@@ -173,11 +208,53 @@ func (sm *StoreManager) GetStoreConfig(name string) (*config.StoreConfig, error)
 	cfg := &config.StoreConfig{
 		Name:           store.config.Name,
 		EvictionPolicy: "lru", // BasicStoreConfig doesn't store this, but we can infer
-		MaxMemory:      fmt.Sprintf("%dB", store.config.MaxMemory),
+		MaxMemory:      fmt.Sprintf("%dB", store.MaxMemory()),
 	}
 	return cfg, nil
 }
 
+// SetStoreMaxMemory changes the memory limit of a running store. maxMemory
+// uses the config format, e.g. "512MB".
+func (sm *StoreManager) SetStoreMaxMemory(name, maxMemory string) error {
+	size, err := config.ParseSize(maxMemory)
+	if err != nil {
+		return fmt.Errorf("invalid max_memory: %w", err)
+	}
+
+	sm.mu.RLock()
+	store, exists := sm.stores[name]
+	sm.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("store '%s' not found", name)
+	}
+	return store.SetMaxMemory(size)
+}
+
+// SetSyncPolicy changes the AOF sync policy of every store, including
+// stores created afterwards.
+func (sm *StoreManager) SetSyncPolicy(policy string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.globalPersistence.SyncPolicy = policy
+	for _, store := range sm.stores {
+		store.SetSyncPolicy(policy)
+	}
+}
+
+// SetActiveExpire pauses or resumes active expiry (see
+// BasicStore.SetActiveExpire) in every store, including stores created
+// afterwards.
+func (sm *StoreManager) SetActiveExpire(enabled bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.activeExpireOff = !enabled
+	for _, store := range sm.stores {
+		store.SetActiveExpire(enabled)
+	}
+}
+
 // StoreCount returns the number of stores.
 func (sm *StoreManager) StoreCount() int {
 	sm.mu.RLock()
@@ -260,7 +337,7 @@ func (sm *StoreManager) saveRegistryLocked() {
 		entries[name] = storeRegistryEntry{
 			Name:           name,
 			EvictionPolicy: "lru", // default; in future, store eviction policy name in BasicStoreConfig
-			MaxMemory:      fmt.Sprintf("%dB", store.config.MaxMemory),
+			MaxMemory:      fmt.Sprintf("%dB", store.MaxMemory()),
 			DefaultTTL:     store.config.DefaultTTL.String(),
 			CuckooFilter:   store.filter != nil,
 			Persistence:    sm.getPersistenceMode(store),
@@ -297,9 +374,13 @@ func (sm *StoreManager) getPersistenceMode(store *BasicStore) string {
 
 // createStoreInternal creates a BasicStore from a StoreConfig. Caller must hold sm.mu.
 func (sm *StoreManager) createStoreInternal(storeCfg config.StoreConfig) (*BasicStore, error) {
-	maxMemory := parseMemorySize(storeCfg.MaxMemory)
-	if maxMemory == 0 {
-		maxMemory = 8 * 1024 * 1024 * 1024 // 8GB fallback
+	var maxMemory uint64 = 8 * 1024 * 1024 * 1024 // 8GB fallback
+	if storeCfg.MaxMemory != "" && storeCfg.MaxMemory != "0" {
+		size, err := config.ParseSize(storeCfg.MaxMemory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_memory for store %s: %w", storeCfg.Name, err)
+		}
+		maxMemory = size
 	}
 
 	defaultTTL := parseTTL(storeCfg.DefaultTTL)
@@ -311,6 +392,14 @@ func (sm *StoreManager) createStoreInternal(storeCfg config.StoreConfig) (*Basic
 	var persistCfg *persistence.PersistenceConfig
 	effectivePersistence := storeCfg.GetPersistence(sm.globalPersistence.Strategy)
 	if effectivePersistence != "disabled" && sm.globalPersistence.Enabled {
+		var maxLogSize uint64
+		if sm.globalPersistence.MaxLogSize != "" {
+			size, err := config.ParseSize(sm.globalPersistence.MaxLogSize)
+			if err != nil {
+				return nil, fmt.Errorf("invalid persistence max_log_size: %w", err)
+			}
+			maxLogSize = size
+		}
 		persistCfg = &persistence.PersistenceConfig{
 			Enabled:          true,
 			Strategy:         effectivePersistence,
@@ -319,7 +408,7 @@ func (sm *StoreManager) createStoreInternal(storeCfg config.StoreConfig) (*Basic
 			SyncPolicy:       sm.globalPersistence.SyncPolicy,
 			SyncInterval:     sm.globalPersistence.SyncInterval,
 			SnapshotInterval: sm.globalPersistence.SnapshotInterval,
-			MaxLogSize:       int64(parseMemorySize(sm.globalPersistence.MaxLogSize)),
+			MaxLogSize:       int64(maxLogSize),
 			CompressionLevel: sm.globalPersistence.CompressionLevel,
 			RetainLogs:       sm.globalPersistence.RetainLogs,
 		}
@@ -353,41 +442,32 @@ func (sm *StoreManager) createStoreInternal(storeCfg config.StoreConfig) (*Basic
 		CleanupInterval:   time.Minute,
 		PersistenceConfig: persistCfg,
 		FilterConfig:      filterCfg,
+		FilterSkipDelete:  sm.globalCacheConfig.CuckooFilterSkipDelete,
+		LazyFree:          sm.globalCacheConfig.LazyFree,
+		InternValues:      sm.globalCacheConfig.InternValues,
+		SlotIndex:         sm.slotIndex,
+		TTLJitter:         sm.globalCacheConfig.TTLJitter,
+		TTLJitterMax:      parseTTL(sm.globalCacheConfig.TTLJitterMax),
+		LoadShedding:      sm.globalCacheConfig.LoadShedding,
+		ShedLowWater:      sm.globalCacheConfig.LoadSheddingLowWater,
+		PrefetchKeys:      sm.globalCacheConfig.PrefetchKeys,
+		Shards:            sm.globalCacheConfig.Shards,
+		MaxKeySize:        sm.globalCacheConfig.MaxKeySize,
+		StrictKeys:        sm.globalCacheConfig.StrictKeys,
+
+		EvictionHighWater:     sm.globalCacheConfig.EvictionHighWater,
+		EvictionCriticalWater: sm.globalCacheConfig.EvictionCriticalWater,
+		EvictionPanicWater:    sm.globalCacheConfig.EvictionPanicWater,
+		EvictionLowWater:      sm.globalCacheConfig.EvictionLowWater,
+		EvictionBatchSize:     sm.globalCacheConfig.EvictionBatchSize,
+		VerifyInterval:        parseTTL(sm.globalCacheConfig.VerifyInterval),
+
+		SessionIdleTimeout: parseTTL(storeCfg.SessionIdleTimeout),
 	}
 
 	return NewBasicStore(bsCfg)
 }
 
-// parseMemorySize parses a size string like "4GB", "512MB", "100" into uint64 bytes.
-func parseMemorySize(s string) uint64 {
-	if s == "" || s == "0" {
-		return 0
-	}
-
-	multipliers := map[string]uint64{
-		"B":  1,
-		"KB": 1024,
-		"MB": 1024 * 1024,
-		"GB": 1024 * 1024 * 1024,
-		"TB": 1024 * 1024 * 1024 * 1024,
-	}
-
-	var size uint64
-	var unit string
-	n, err := fmt.Sscanf(s, "%d%s", &size, &unit)
-	if err != nil || n == 0 {
-		return 0
-	}
-	if n == 1 {
-		return size // raw bytes
-	}
-
-	if m, ok := multipliers[unit]; ok {
-		return size * m
-	}
-	return size
-}
-
 // parseTTL parses a duration string or "0" into time.Duration.
 // "0" and "" both mean no TTL (infinite).
 func parseTTL(s string) time.Duration {
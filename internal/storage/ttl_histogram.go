@@ -0,0 +1,69 @@
+package storage
+
+import "time"
+
+// TTLHistogramSample is the most keys TTLHistogram inspects. Larger stores
+// are sampled, so their histogram counts add up to the sample size.
+const TTLHistogramSample = 10000
+
+// TTL histogram buckets, by remaining time to live
+const (
+	TTLBucketUnder1m  = "lt_1m"
+	TTLBucketUnder1h  = "lt_1h"
+	TTLBucketUnder1d  = "lt_1d"
+	TTLBucketOver1d   = "ge_1d"
+	TTLBucketNoExpiry = "never"
+)
+
+// TTLHistogramBuckets lists the TTL histogram buckets in order
+var TTLHistogramBuckets = []string{TTLBucketUnder1m, TTLBucketUnder1h, TTLBucketUnder1d, TTLBucketOver1d, TTLBucketNoExpiry}
+
+// TTLHistogram counts live keys by remaining TTL, with every bucket present.
+// Stores of more than TTLHistogramSample keys are estimated from a random
+// sample of that many keys rather than scanned.
+func (s *BasicStore) TTLHistogram() map[string]uint64 {
+	histogram := make(map[string]uint64, len(TTLHistogramBuckets))
+	for _, bucket := range TTLHistogramBuckets {
+		histogram[bucket] = 0
+	}
+	add := func(ttl time.Duration) {
+		histogram[ttlBucket(ttl)]++
+	}
+
+	if s.data.Size() <= TTLHistogramSample {
+		now := s.now()
+		s.data.RangeAll(func(key string, item *CacheItem) bool {
+			switch {
+			case item.ExpiresAt.IsZero():
+				add(-1)
+			case item.ExpiresAt.After(now):
+				add(item.ExpiresAt.Sub(now))
+			}
+			return true
+		})
+		return histogram
+	}
+
+	for _, key := range s.data.SampleKeys(TTLHistogramSample) {
+		if ttl, ok := s.TTL(key); ok {
+			add(ttl)
+		}
+	}
+	return histogram
+}
+
+// ttlBucket returns the histogram bucket of a remaining TTL, -1 = no expiry
+func ttlBucket(ttl time.Duration) string {
+	switch {
+	case ttl < 0:
+		return TTLBucketNoExpiry
+	case ttl < time.Minute:
+		return TTLBucketUnder1m
+	case ttl < time.Hour:
+		return TTLBucketUnder1h
+	case ttl < 24*time.Hour:
+		return TTLBucketUnder1d
+	default:
+		return TTLBucketOver1d
+	}
+}
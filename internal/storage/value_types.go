@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"errors"
+)
+
+// Value kinds as reported by TYPE. Kinds group the concrete ValueType strings
+// recorded on CacheItem so commands can reject keys of the wrong kind.
+const (
+	TypeNone   = "none"
+	TypeString = "string"
+	TypeList   = "list"
+	TypeSet    = "set"
+)
+
+// CacheItem.ValueType recorded for the typed values
+const (
+	listValueType = "list"
+	setValueType  = "set"
+)
+
+// ErrWrongType is returned when a command targets a key holding another kind of value
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// ListValue is an ordered list of strings stored under a single key
+type ListValue []string
+
+// SetValue is an unordered collection of unique strings stored under a single key
+type SetValue map[string]struct{}
+
+// ValueKind maps a CacheItem.ValueType to its value kind. Scalar values
+// (strings, []byte, numbers, JSON documents) are all strings as far as
+// commands are concerned.
+func ValueKind(valueType string) string {
+	switch valueType {
+	case listValueType:
+		return TypeList
+	case setValueType:
+		return TypeSet
+	default:
+		return TypeString
+	}
+}
+
+// CheckValueKind returns ErrWrongType if valueType is not of the wanted kind
+func CheckValueKind(valueType, kind string) error {
+	if ValueKind(valueType) != kind {
+		return ErrWrongType
+	}
+	return nil
+}
+
+// Kind returns the value kind of the item
+func (item *CacheItem) Kind() string {
+	return ValueKind(item.ValueType)
+}
+
+// Type returns the value kind stored at key, or TypeNone if it does not exist
+func (s *BasicStore) Type(key string) string {
+	item, ok := s.data.Get(key)
+	if !ok || s.expired(item) {
+		return TypeNone
+	}
+	return item.Kind()
+}
+
+// CheckType returns ErrWrongType if key exists and holds a value of another kind.
+// Missing keys pass, since typed commands create them on first write.
+func (s *BasicStore) CheckType(key, kind string) error {
+	if t := s.Type(key); t != TypeNone && t != kind {
+		return ErrWrongType
+	}
+	return nil
+}
+
+// liveItem returns the item at key, or nil if it is missing or expired
+func (s *BasicStore) liveItem(key string) *CacheItem {
+	item, ok := s.data.Get(key)
+	if !ok || s.expired(item) {
+		return nil
+	}
+	return item
+}
+
+// updateTyped is the read-modify-write behind the typed commands. update
+// gets the live item at key (nil if there is none) and returns the value
+// to store, nil to delete the key, and whether anything changed. The write
+// keeps the key's expiry and session, and only happens if the key is
+// unchanged since it was read, checked under its shard lock: a SET racing
+// with the command is never overwritten, update runs again on its value
+// instead (and fails with ErrWrongType if it isn't of the command's type).
+// typedMu keeps typed commands from retrying against each other.
+func (s *BasicStore) updateTyped(key string, update func(item *CacheItem) (value interface{}, changed bool, err error)) error {
+	s.typedMu.Lock()
+	defer s.typedMu.Unlock()
+
+	for {
+		// Every write bumps the version, EXPIRE included. Reading it before
+		// the item means a write in between fails the check below.
+		version, _ := s.Version(key)
+		item := s.liveItem(key)
+
+		value, changed, err := update(item)
+		if err != nil || !changed {
+			return err
+		}
+		if written, err := s.writeTyped(key, version, item, value); written || err != nil {
+			return err
+		}
+	}
+}
+
+// writeTyped stores value at key, or deletes the key if value is nil,
+// provided it is still at version (0 = absent). Reports false, having
+// changed nothing, if the key was written since.
+func (s *BasicStore) writeTyped(key string, version uint64, item *CacheItem, value interface{}) (bool, error) {
+	unchanged := func(existing *CacheItem) bool {
+		if existing == nil || s.expired(existing) {
+			return version == 0
+		}
+		return existing.Version == version
+	}
+	if value == nil {
+		if item == nil {
+			return true, nil
+		}
+		// Fails with not found if the key changed or went away meanwhile
+		return s.deleteIf(key, "del", unchanged) == nil, nil
+	}
+
+	sessionID := ""
+	if item != nil {
+		sessionID = item.SessionID
+	}
+	return s.setIf(nil, key, value, sessionID, KeepTTL, 0, false, unchanged)
+}
+
+// listValue decodes the list held by item. No item is an empty list.
+func listValue(item *CacheItem) (ListValue, error) {
+	if item == nil {
+		return nil, nil
+	}
+	if item.Kind() != TypeList {
+		return nil, ErrWrongType
+	}
+	value, err := item.GetValue()
+	if err != nil {
+		return nil, err
+	}
+	return value.(ListValue), nil
+}
+
+// getList returns the list stored at key. A missing key yields an empty list.
+func (s *BasicStore) getList(key string) (ListValue, error) {
+	return listValue(s.liveItem(key))
+}
+
+// ListPush prepends (left) or appends values to the list at key, creating it
+// if needed, and returns the new length.
+func (s *BasicStore) ListPush(key string, left bool, values ...string) (int, error) {
+	var length int
+	err := s.updateTyped(key, func(item *CacheItem) (interface{}, bool, error) {
+		list, err := listValue(item)
+		if err != nil {
+			return nil, false, err
+		}
+
+		pushed := make(ListValue, 0, len(list)+len(values))
+		if left {
+			for i := len(values) - 1; i >= 0; i-- {
+				pushed = append(pushed, values[i])
+			}
+			pushed = append(pushed, list...)
+		} else {
+			pushed = append(append(pushed, list...), values...)
+		}
+		length = len(pushed)
+		return pushed, true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return length, nil
+}
+
+// ListPop removes and returns the first (left) or last element of the list at key.
+// The key is deleted once its last element is popped.
+func (s *BasicStore) ListPop(key string, left bool) (string, bool, error) {
+	var elem string
+	var popped bool
+	err := s.updateTyped(key, func(item *CacheItem) (interface{}, bool, error) {
+		list, err := listValue(item)
+		if err != nil || len(list) == 0 {
+			popped = false
+			return nil, false, err
+		}
+
+		if left {
+			elem, list = list[0], list[1:]
+		} else {
+			elem, list = list[len(list)-1], list[:len(list)-1]
+		}
+		popped = true
+		if len(list) == 0 {
+			return nil, true, nil
+		}
+		return list, true, nil
+	})
+	if err != nil || !popped {
+		return "", false, err
+	}
+	return elem, true, nil
+}
+
+// ListRange returns the elements between start and stop inclusive. Negative
+// indexes count from the end of the list, as in LRANGE. Of a quicklist,
+// only the nodes holding the range are decoded.
+func (s *BasicStore) ListRange(key string, start, stop int) ([]string, error) {
+	item := s.liveItem(key)
+	if item != nil && item.Kind() != TypeList {
+		return nil, ErrWrongType
+	}
+	n := 0
+	if item != nil {
+		var err error
+		if n, err = listLen(item.ValuePtr); err != nil {
+			return nil, err
+		}
+	}
+
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return []string{}, nil
+	}
+	return listRange(item.ValuePtr, start, stop)
+}
+
+// ListLen returns the length of the list at key (0 if it does not exist)
+func (s *BasicStore) ListLen(key string) (int, error) {
+	item := s.liveItem(key)
+	if item == nil {
+		return 0, nil
+	}
+	if item.Kind() != TypeList {
+		return 0, ErrWrongType
+	}
+	return listLen(item.ValuePtr)
+}
@@ -0,0 +1,78 @@
+package storage
+
+// watchedKey tracks the modification version of a key under WATCH
+type watchedKey struct {
+	refs    int    // Number of active watches on the key
+	version uint64 // Bumped on every mutation of the key
+}
+
+// WatchKey registers a watch on key and returns its current modification
+// version. Versions are only tracked while at least one watch is active.
+func (s *BasicStore) WatchKey(key string) uint64 {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	if s.watched == nil {
+		s.watched = make(map[string]*watchedKey)
+	}
+	w, ok := s.watched[key]
+	if !ok {
+		w = &watchedKey{version: s.watchClock.Add(1)}
+		s.watched[key] = w
+		s.watchCount.Add(1)
+	}
+	w.refs++
+	return w.version
+}
+
+// UnwatchKey releases a watch taken by WatchKey
+func (s *BasicStore) UnwatchKey(key string) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	w, ok := s.watched[key]
+	if !ok {
+		return
+	}
+	w.refs--
+	if w.refs <= 0 {
+		delete(s.watched, key)
+		s.watchCount.Add(-1)
+	}
+}
+
+// KeyVersion returns the modification version of a watched key, or 0 if the
+// key is not watched
+func (s *BasicStore) KeyVersion(key string) uint64 {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	if w, ok := s.watched[key]; ok {
+		return w.version
+	}
+	return 0
+}
+
+// touchKey bumps the version of a watched key after a mutation
+func (s *BasicStore) touchKey(key string) {
+	if s.watchCount.Load() == 0 {
+		return
+	}
+	s.watchMu.Lock()
+	if w, ok := s.watched[key]; ok {
+		w.version = s.watchClock.Add(1)
+	}
+	s.watchMu.Unlock()
+}
+
+// touchAllKeys bumps the version of every watched key (used by Clear)
+func (s *BasicStore) touchAllKeys() {
+	if s.watchCount.Load() == 0 {
+		return
+	}
+	s.watchMu.Lock()
+	for _, w := range s.watched {
+		w.version = s.watchClock.Add(1)
+	}
+	s.watchMu.Unlock()
+}
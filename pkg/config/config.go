@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +20,7 @@ type Config struct {
 	Cache       CacheConfig       `yaml:"cache"`
 	Persistence PersistenceConfig `yaml:"persistence"`
 	Logging     LoggingConfig     `yaml:"logging"`
+	Audit       AuditConfig       `yaml:"audit"`
 	Stores      []StoreConfig     `yaml:"stores"`
 }
 
@@ -33,14 +35,31 @@ type NetworkConfig struct {
 	// RESP API configuration
 	RESPBindAddr string `yaml:"resp_bind_addr"`
 	RESPPort     int    `yaml:"resp_port"`
+	MaxClients   int    `yaml:"max_clients"` // RESP connection limit (0 = default 1000)
+
+	// RESPErrorTokens appends "[conn=<id> cid=<correlation>]" to RESP error
+	// replies, matching the log entry for the failure. A debugging aid.
+	RESPErrorTokens bool `yaml:"resp_error_tokens"`
 
 	// HTTP API configuration
-	HTTPBindAddr string `yaml:"http_bind_addr"`
-	HTTPPort     int    `yaml:"http_port"`
+	HTTPBindAddr    string     `yaml:"http_bind_addr"`
+	HTTPPort        int        `yaml:"http_port"`
+	HTTPMaxBodySize string     `yaml:"http_max_body_size"` // Largest HTTP request body accepted (default 64MB)
+	CORS            CORSConfig `yaml:"cors"`
 
 	// Cluster gossip configuration
-	AdvertiseAddr string `yaml:"advertise_addr"` // IP that other nodes use to connect
-	GossipPort    int    `yaml:"gossip_port"`    // Serf gossip port
+	AdvertiseAddr      string `yaml:"advertise_addr"`      // IP that other nodes use to connect (empty = auto-detect)
+	AdvertiseInterface string `yaml:"advertise_interface"` // Interface to auto-detect AdvertiseAddr from (empty = first usable)
+	GossipPort         int    `yaml:"gossip_port"`         // Serf gossip port
+}
+
+// CORSConfig lets browser clients on other origins call the HTTP API
+type CORSConfig struct {
+	Enabled        bool          `yaml:"enabled"`         // Off by default
+	AllowedOrigins []string      `yaml:"allowed_origins"` // Empty or "*" = any origin
+	AllowedMethods []string      `yaml:"allowed_methods"` // Empty = GET, POST, PUT, DELETE, OPTIONS
+	AllowedHeaders []string      `yaml:"allowed_headers"` // Empty = Content-Type, X-Correlation-ID
+	MaxAge         time.Duration `yaml:"max_age"`         // How long browsers may cache a preflight (0 = browser default)
 }
 
 // ClusterConfig contains clustering configuration
@@ -50,6 +69,24 @@ type ClusterConfig struct {
 	SeedDNSPort       int      `yaml:"seed_dns_port"` // Port to use with DNS-discovered seeds (default: gossip port)
 	ReplicationFactor int      `yaml:"replication_factor"`
 	ConsistencyLevel  string   `yaml:"consistency_level"`
+
+	// KeyRouting is how RESP answers for keys another node owns: "proxy"
+	// forwards the command to the owner, "redirect" replies MOVED
+	KeyRouting string `yaml:"key_routing"`
+
+	// ReplicationLagThreshold is the lag between a primary's write and
+	// its apply here above which INFO and metrics flag the primary as
+	// lagging (0 = 1s)
+	ReplicationLagThreshold time.Duration `yaml:"replication_lag_threshold"`
+
+	// Adaptive gossip: the interval grows from min to max with cluster size
+	GossipMinInterval   time.Duration `yaml:"gossip_min_interval"`
+	GossipMaxInterval   time.Duration `yaml:"gossip_max_interval"`
+	GossipExpectedNodes int           `yaml:"gossip_expected_nodes"` // 0 = number of seeds + 1
+
+	// SlotIndex keeps a hash slot to keys index in every store, so listing
+	// a slot's keys (e.g. for migration) doesn't scan the whole keyspace
+	SlotIndex bool `yaml:"slot_index"`
 }
 
 // StorageConfig contains storage engine configuration
@@ -78,18 +115,96 @@ type CacheConfig struct {
 	DefaultTTL      string  `yaml:"default_ttl"`
 	CuckooFilterFPP float64 `yaml:"cuckoo_filter_fpp"`
 	MaxStores       int     `yaml:"max_stores"`
+
+	// CuckooFilterSkipDelete keeps deleted keys in the cuckoo filter. This
+	// trades extra lookups for deleted keys for never turning a fingerprint
+	// collision into a false negative; the filter then only shrinks on FLUSHALL.
+	CuckooFilterSkipDelete bool `yaml:"cuckoo_filter_skip_delete"`
+
+	// LazyFree reclaims memory for large deleted/overwritten values on a
+	// background goroutine instead of the request path (like lazyfree-lazy-*)
+	LazyFree bool `yaml:"lazy_free"`
+
+	// InternValues stores identical values (1KB and up) once per store,
+	// shared by every key holding them. Saves memory when many keys hold the
+	// same large value, at the cost of hashing every such write.
+	InternValues bool `yaml:"intern_values"`
+
+	// TTLJitter spreads expiry of keys written with the same TTL by extending
+	// each TTL by a random fraction (0-1) of itself; TTLJitterMax caps the
+	// added time (e.g. "30s"). 0 / empty = no jitter / no cap.
+	TTLJitter    float64 `yaml:"ttl_jitter"`
+	TTLJitterMax string  `yaml:"ttl_jitter_max"`
+
+	// LoadShedding rejects writes with an OOM error once a store reaches
+	// panic memory pressure, instead of evicting aggressively, until pressure
+	// drops below LoadSheddingLowWater (0-1, 0 = default 0.80).
+	LoadShedding         bool    `yaml:"load_shedding"`
+	LoadSheddingLowWater float64 `yaml:"load_shedding_low_water"`
+
+	// PrefetchKeys keeps the deserialized values of up to this many hot keys
+	// per store so GETs skip decoding them. 0 = disabled.
+	PrefetchKeys int `yaml:"prefetch_keys"`
+
+	// Shards is the number of lock-striped partitions of each store's
+	// keyspace, rounded up to a power of two. 0 = default (32).
+	Shards int `yaml:"shards"`
+
+	// MaxKeySize rejects writes of keys longer than this many bytes
+	// (0 = no limit). StrictKeys also rejects keys containing a NUL byte.
+	MaxKeySize int  `yaml:"max_key_size"`
+	StrictKeys bool `yaml:"strict_keys"`
+
+	// Eviction marks as memory pressure (0-1): stores start evicting at
+	// EvictionHighWater, escalate at EvictionCriticalWater and
+	// EvictionPanicWater, and reclaim down to EvictionLowWater, evicting
+	// EvictionBatchSize keys per round. 0 = defaults 0.85/0.90/0.95, 0.75, 1.
+	EvictionHighWater     float64 `yaml:"eviction_high_water"`
+	EvictionCriticalWater float64 `yaml:"eviction_critical_water"`
+	EvictionPanicWater    float64 `yaml:"eviction_panic_water"`
+	EvictionLowWater      float64 `yaml:"eviction_low_water"`
+	EvictionBatchSize     int     `yaml:"eviction_batch_size"`
+
+	// CompactInterval checks heap fragmentation this often (e.g. "1m") and,
+	// under low load, returns freed memory to the OS once the fragmentation
+	// ratio reaches CompactMinRatio (0 = default 1.5). Empty = disabled.
+	CompactInterval string  `yaml:"compact_interval"`
+	CompactMinRatio float64 `yaml:"compact_min_ratio"`
+
+	// VerifyInterval recounts each store's items and memory this often
+	// (e.g. "1m") and logs any drift from the incremental counters. A
+	// debugging aid: every check walks the whole store. Empty = disabled.
+	VerifyInterval string `yaml:"verify_interval"`
+
+	// NotifyKeyspaceEvents enables Redis-style keyspace notifications using
+	// notify-keyspace-events flags (e.g. "KEA"). Empty = disabled.
+	NotifyKeyspaceEvents string `yaml:"notify_keyspace_events"`
 }
 
 // LoggingConfig contains logging configuration
 type LoggingConfig struct {
-	Level         string `yaml:"level"`          // debug, info, warn, error, fatal
-	EnableConsole bool   `yaml:"enable_console"` // Enable console output
-	EnableFile    bool   `yaml:"enable_file"`    // Enable file output
-	LogFile       string `yaml:"log_file"`       // Log file path
-	BufferSize    int    `yaml:"buffer_size"`    // Async log buffer size
-	LogDir        string `yaml:"log_dir"`        // Log directory
-	MaxFileSize   string `yaml:"max_file_size"`  // Maximum log file size before rotation
-	MaxFiles      int    `yaml:"max_files"`      // Maximum number of log files to keep
+	Level          string `yaml:"level"`           // debug, info, warn, error, fatal
+	EnableConsole  bool   `yaml:"enable_console"`  // Enable console output
+	EnableFile     bool   `yaml:"enable_file"`     // Enable file output
+	LogFile        string `yaml:"log_file"`        // Log file path
+	BufferSize     int    `yaml:"buffer_size"`     // Async log buffer size
+	LogDir         string `yaml:"log_dir"`         // Log directory
+	MaxFileSize    string `yaml:"max_file_size"`   // Maximum log file size before rotation
+	MaxFiles       int    `yaml:"max_files"`       // Maximum number of log files to keep, including the current one
+	RotateInterval string `yaml:"rotate_interval"` // Also rotate at this interval, e.g. "24h" (0 = size only)
+	FlushInterval  string `yaml:"flush_interval"`  // Buffer file output, flushing at this interval (0 = write through)
+	SampleRate     int    `yaml:"sample_rate"`     // Log 1 in N high-frequency debug events, e.g. replicated writes (0 or 1 = all)
+}
+
+// AuditConfig configures the key-access audit log, a record of GET, SET and
+// DEL commands written separately from the operational log
+type AuditConfig struct {
+	Enabled     bool   `yaml:"enabled"`       // Off by default
+	LogFile     string `yaml:"log_file"`      // Default <log_dir>/<node id>-audit.log
+	SampleRate  int    `yaml:"sample_rate"`   // Record 1 in N accesses (0 or 1 = all)
+	BufferSize  int    `yaml:"buffer_size"`   // Records queued for writing; further records are dropped (0 = 10000)
+	MaxFileSize string `yaml:"max_file_size"` // Audit log size before rotation
+	MaxFiles    int    `yaml:"max_files"`     // Audit log files to keep, including the current one
 }
 
 // StoreConfig represents configuration for individual stores.
@@ -101,6 +216,10 @@ type StoreConfig struct {
 	DefaultTTL     string `yaml:"default_ttl"`
 	CuckooFilter   *bool  `yaml:"cuckoo_filter,omitempty"` // nil = inherit global (true)
 	Persistence    string `yaml:"persistence,omitempty"`   // "hybrid", "aof", "snapshot", "disabled"; empty = inherit global
+
+	// Delete all of a session's keys once none has been written or read for
+	// this long; empty or "0" = sessions never expire as a whole
+	SessionIdleTimeout string `yaml:"session_idle_timeout,omitempty"`
 }
 
 // Load reads and parses the configuration file
@@ -123,10 +242,13 @@ func Load(path string) (*Config, error) {
 			Seeds:             []string{},
 			ReplicationFactor: 3,
 			ConsistencyLevel:  "eventual",
+			KeyRouting:        "proxy",
+			GossipMinInterval: 200 * time.Millisecond,
+			GossipMaxInterval: 2 * time.Second,
 		},
 		Storage: StorageConfig{
 			WALSyncInterval:   10 * time.Millisecond,
-			MemTableSize:      "64MB",
+			MemTableSize:      "64MiB",
 			CompactionThreads: 4,
 		},
 		Persistence: PersistenceConfig{
@@ -136,12 +258,12 @@ func Load(path string) (*Config, error) {
 			SyncPolicy:       "everysec",
 			SyncInterval:     1 * time.Second,
 			SnapshotInterval: 15 * time.Minute,
-			MaxLogSize:       "100MB",
+			MaxLogSize:       "100MiB",
 			CompressionLevel: 6,
 			RetainLogs:       3,
 		},
 		Cache: CacheConfig{
-			MaxMemory:       "8GB",
+			MaxMemory:       "8GiB",
 			DefaultTTL:      "0",  // 0 = infinite (no expiry by default)
 			CuckooFilterFPP: 0.01, // 1% false positive rate
 			MaxStores:       16,
@@ -153,14 +275,15 @@ func Load(path string) (*Config, error) {
 			LogFile:       "", // Will be set based on node ID
 			BufferSize:    1000,
 			LogDir:        "logs",
-			MaxFileSize:   "100MB",
+			MaxFileSize:   "100MiB",
 			MaxFiles:      10,
+			FlushInterval: "1s",
 		},
 		Stores: []StoreConfig{
 			{
 				Name:           "default",
 				EvictionPolicy: "lru",
-				MaxMemory:      "8GB",
+				MaxMemory:      "8GiB",
 				DefaultTTL:     "0",
 			},
 		},
@@ -173,6 +296,10 @@ func Load(path string) (*Config, error) {
 			// File doesn't exist, use defaults
 			fmt.Printf("⚠️  Configuration file %s not found, using defaults\n", path)
 			config.applyEnvOverrides()
+			config.applyDefaults()
+			if err := config.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid configuration: %w", err)
+			}
 			return config, nil
 		}
 		return nil, fmt.Errorf("failed to read config file: %w", err)
@@ -186,6 +313,9 @@ func Load(path string) (*Config, error) {
 	// Apply environment variable overrides (highest priority — for Docker/K8s)
 	config.applyEnvOverrides()
 
+	// Fill settings the file left empty or zero
+	config.applyDefaults()
+
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -199,18 +329,51 @@ func (c *Config) Validate() error {
 	if c.Node.ID == "" {
 		return fmt.Errorf("node.id cannot be empty")
 	}
+	if c.Node.DataDir == "" {
+		return fmt.Errorf("node.data_dir cannot be empty")
+	}
 	if c.Network.RESPPort <= 0 || c.Network.RESPPort > 65535 {
 		return fmt.Errorf("network.resp_port must be between 1 and 65535")
 	}
+	if c.Network.MaxClients < 0 {
+		return fmt.Errorf("network.max_clients cannot be negative")
+	}
 	if c.Network.HTTPPort <= 0 || c.Network.HTTPPort > 65535 {
 		return fmt.Errorf("network.http_port must be between 1 and 65535")
 	}
 	if c.Network.GossipPort <= 0 || c.Network.GossipPort > 65535 {
 		return fmt.Errorf("network.gossip_port must be between 1 and 65535")
 	}
+	if err := validateSize("network.http_max_body_size", c.Network.HTTPMaxBodySize); err != nil {
+		return err
+	}
+	if c.Network.CORS.MaxAge < 0 {
+		return fmt.Errorf("network.cors.max_age cannot be negative")
+	}
+	if c.Cluster.SeedDNSPort < 0 || c.Cluster.SeedDNSPort > 65535 {
+		return fmt.Errorf("cluster.seed_dns_port must be between 1 and 65535")
+	}
 	if c.Cluster.ReplicationFactor < 1 {
 		return fmt.Errorf("cluster.replication_factor must be >= 1")
 	}
+	if c.Cluster.GossipMinInterval <= 0 || c.Cluster.GossipMaxInterval < c.Cluster.GossipMinInterval {
+		return fmt.Errorf("cluster.gossip_min_interval must be positive and no greater than cluster.gossip_max_interval")
+	}
+	if c.Cluster.GossipExpectedNodes < 0 {
+		return fmt.Errorf("cluster.gossip_expected_nodes cannot be negative")
+	}
+	if c.Cluster.KeyRouting != "" && c.Cluster.KeyRouting != "proxy" && c.Cluster.KeyRouting != "redirect" {
+		return fmt.Errorf("cluster.key_routing must be \"proxy\" or \"redirect\"")
+	}
+	if c.Cluster.ReplicationLagThreshold < 0 {
+		return fmt.Errorf("cluster.replication_lag_threshold cannot be negative")
+	}
+	if c.Storage.WALSyncInterval < 0 {
+		return fmt.Errorf("storage.wal_sync_interval cannot be negative")
+	}
+	if err := validateSize("storage.memtable_size", c.Storage.MemTableSize); err != nil {
+		return err
+	}
 	if len(c.Stores) == 0 {
 		return fmt.Errorf("at least one store must be configured")
 	}
@@ -223,6 +386,71 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("configured %d stores but cache.max_stores is %d", len(c.Stores), c.Cache.MaxStores)
 	}
 
+	if err := validateSize("cache.max_memory", c.Cache.MaxMemory); err != nil {
+		return err
+	}
+	if err := validateDuration("cache.default_ttl", c.Cache.DefaultTTL); err != nil {
+		return err
+	}
+	if c.Cache.CuckooFilterFPP <= 0 || c.Cache.CuckooFilterFPP >= 1 {
+		return fmt.Errorf("cache.cuckoo_filter_fpp must be between 0 and 1")
+	}
+
+	if c.Cache.TTLJitter < 0 || c.Cache.TTLJitter > 1 {
+		return fmt.Errorf("cache.ttl_jitter must be between 0 and 1")
+	}
+
+	if err := validateDuration("cache.ttl_jitter_max", c.Cache.TTLJitterMax); err != nil {
+		return err
+	}
+
+	if c.Cache.LoadSheddingLowWater < 0 || c.Cache.LoadSheddingLowWater >= 1 {
+		return fmt.Errorf("cache.load_shedding_low_water must be between 0 and 1")
+	}
+
+	if c.Cache.PrefetchKeys < 0 {
+		return fmt.Errorf("cache.prefetch_keys cannot be negative")
+	}
+
+	if c.Cache.Shards < 0 || c.Cache.Shards > 4096 {
+		return fmt.Errorf("cache.shards must be between 0 and 4096")
+	}
+
+	if c.Cache.MaxKeySize < 0 {
+		return fmt.Errorf("cache.max_key_size cannot be negative")
+	}
+
+	for name, mark := range map[string]float64{
+		"cache.eviction_high_water":     c.Cache.EvictionHighWater,
+		"cache.eviction_critical_water": c.Cache.EvictionCriticalWater,
+		"cache.eviction_panic_water":    c.Cache.EvictionPanicWater,
+		"cache.eviction_low_water":      c.Cache.EvictionLowWater,
+	} {
+		if mark < 0 || mark > 1 {
+			return fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+
+	if c.Cache.EvictionBatchSize < 0 {
+		return fmt.Errorf("cache.eviction_batch_size cannot be negative")
+	}
+
+	if err := validateDuration("cache.compact_interval", c.Cache.CompactInterval); err != nil {
+		return err
+	}
+
+	if c.Cache.CompactMinRatio != 0 && c.Cache.CompactMinRatio < 1 {
+		return fmt.Errorf("cache.compact_min_ratio must be at least 1")
+	}
+
+	if err := validateDuration("cache.verify_interval", c.Cache.VerifyInterval); err != nil {
+		return err
+	}
+
+	if !isValidNotifyKeyspaceEvents(c.Cache.NotifyKeyspaceEvents) {
+		return fmt.Errorf("invalid cache.notify_keyspace_events: %s (valid flags: K, E, g, $, x, e, A)", c.Cache.NotifyKeyspaceEvents)
+	}
+
 	// Validate store configurations
 	storeNames := make(map[string]bool)
 	for _, store := range c.Stores {
@@ -241,6 +469,16 @@ func (c *Config) Validate() error {
 		if store.Persistence != "" && !isValidStorePersistence(store.Persistence) {
 			return fmt.Errorf("invalid persistence for store %s: %s (valid: hybrid, aof, snapshot, disabled)", store.Name, store.Persistence)
 		}
+
+		if err := validateSize("stores."+store.Name+".max_memory", store.MaxMemory); err != nil {
+			return err
+		}
+		if err := validateDuration("stores."+store.Name+".default_ttl", store.DefaultTTL); err != nil {
+			return err
+		}
+		if err := validateDuration("stores."+store.Name+".session_idle_timeout", store.SessionIdleTimeout); err != nil {
+			return err
+		}
 	}
 
 	// Validate persistence configuration
@@ -256,8 +494,66 @@ func (c *Config) Validate() error {
 		if c.Persistence.CompressionLevel < 0 || c.Persistence.CompressionLevel > 9 {
 			return fmt.Errorf("compression level must be between 0 and 9")
 		}
+
+		if c.Persistence.SyncInterval < 0 || c.Persistence.SnapshotInterval < 0 {
+			return fmt.Errorf("persistence.sync_interval and persistence.snapshot_interval cannot be negative")
+		}
+
+		if err := validateSize("persistence.max_log_size", c.Persistence.MaxLogSize); err != nil {
+			return err
+		}
+	}
+
+	if err := validateSize("logging.max_file_size", c.Logging.MaxFileSize); err != nil {
+		return err
+	}
+	if err := validateDuration("logging.rotate_interval", c.Logging.RotateInterval); err != nil {
+		return err
+	}
+	if err := validateDuration("logging.flush_interval", c.Logging.FlushInterval); err != nil {
+		return err
+	}
+	if c.Logging.MaxFiles < 0 {
+		return fmt.Errorf("logging.max_files cannot be negative")
+	}
+	if c.Logging.SampleRate < 0 {
+		return fmt.Errorf("logging.sample_rate cannot be negative")
 	}
 
+	if err := validateSize("audit.max_file_size", c.Audit.MaxFileSize); err != nil {
+		return err
+	}
+	if c.Audit.SampleRate < 0 || c.Audit.BufferSize < 0 || c.Audit.MaxFiles < 0 {
+		return fmt.Errorf("audit.sample_rate, audit.buffer_size and audit.max_files cannot be negative")
+	}
+
+	return nil
+}
+
+// validateSize checks a size setting with ParseSize. Empty means unset.
+func validateSize(setting, size string) error {
+	if size == "" {
+		return nil
+	}
+	if _, err := ParseSize(size); err != nil {
+		return fmt.Errorf("invalid %s: %w", setting, err)
+	}
+	return nil
+}
+
+// validateDuration checks a TTL or interval setting: "0" (no expiry or
+// disabled) or a non-negative Go duration such as "30m". Empty means unset.
+func validateDuration(setting, value string) error {
+	if value == "" || value == "0" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %q (expected \"0\" or a duration like \"30m\")", setting, value)
+	}
+	if d < 0 {
+		return fmt.Errorf("%s cannot be negative", setting)
+	}
 	return nil
 }
 
@@ -303,6 +599,16 @@ func isValidStorePersistence(p string) bool {
 	return validPolicies[p]
 }
 
+// isValidNotifyKeyspaceEvents checks that every notify-keyspace-events flag is supported
+func isValidNotifyKeyspaceEvents(flags string) bool {
+	for _, c := range flags {
+		if !strings.ContainsRune("KEg$xeA", c) {
+			return false
+		}
+	}
+	return true
+}
+
 // IsCuckooFilterEnabled returns whether the cuckoo filter is enabled for a store.
 // If not explicitly set on the store, returns true (enabled by default).
 func (sc *StoreConfig) IsCuckooFilterEnabled() bool {
@@ -321,12 +627,58 @@ func (sc *StoreConfig) GetPersistence(globalStrategy string) string {
 	return sc.Persistence
 }
 
+// applyDefaults fills settings left empty or zero in the config file (or by
+// env overrides) with their defaults, so the rest of the node can use them
+// as-is. Explicitly invalid values are left for Validate to reject.
+func (c *Config) applyDefaults() {
+	if c.Network.RESPPort == 0 {
+		c.Network.RESPPort = 8080
+	}
+	if c.Network.HTTPPort == 0 {
+		c.Network.HTTPPort = 9080
+	}
+	if c.Network.GossipPort == 0 {
+		c.Network.GossipPort = 7946
+	}
+	if c.Network.HTTPMaxBodySize == "" {
+		c.Network.HTTPMaxBodySize = "64MiB"
+	}
+	if c.Cluster.SeedDNSPort == 0 {
+		c.Cluster.SeedDNSPort = c.Network.GossipPort
+	}
+	if c.Cache.MaxMemory == "" {
+		c.Cache.MaxMemory = "8GiB"
+	}
+	if c.Cache.DefaultTTL == "" {
+		c.Cache.DefaultTTL = "0"
+	}
+	if c.Cache.CuckooFilterFPP == 0 {
+		c.Cache.CuckooFilterFPP = 0.01
+	}
+	if c.Logging.Level == "" {
+		c.Logging.Level = "info"
+	}
+
+	// Stores inherit the global memory limit and TTL
+	for i := range c.Stores {
+		if c.Stores[i].EvictionPolicy == "" {
+			c.Stores[i].EvictionPolicy = "lru"
+		}
+		if c.Stores[i].MaxMemory == "" {
+			c.Stores[i].MaxMemory = c.Cache.MaxMemory
+		}
+		if c.Stores[i].DefaultTTL == "" {
+			c.Stores[i].DefaultTTL = c.Cache.DefaultTTL
+		}
+	}
+}
+
 // applyEnvOverrides applies environment variable overrides to the config.
 // Env vars have highest priority: YAML defaults < config file < env vars.
 //
 // Supported env vars:
 //
-//	HYPERCACHE_DEFAULT_MEMORY       - default store max_memory (e.g. "4GB")
+//	HYPERCACHE_DEFAULT_MEMORY       - default store max_memory (e.g. "4GiB")
 //	HYPERCACHE_DEFAULT_TTL          - default store TTL (e.g. "0", "1h", "30m")
 //	HYPERCACHE_DEFAULT_EVICTION     - default store eviction policy (lru, lfu, fifo, ttl)
 //	HYPERCACHE_DEFAULT_CUCKOO       - default store cuckoo filter (true/false)
@@ -438,5 +790,21 @@ func CheckWarnings(c *Config) []string {
 		warnings = append(warnings, fmt.Sprintf("replication_factor=%d: high replication factor increases write latency", c.Cluster.ReplicationFactor))
 	}
 
+	// Memory limits written when KB/MB/GB/TB were binary now mean less
+	memory := map[string]string{"cache.max_memory": c.Cache.MaxMemory}
+	for _, store := range c.Stores {
+		memory["stores."+store.Name+".max_memory"] = store.MaxMemory
+	}
+	settings := make([]string, 0, len(memory))
+	for setting := range memory {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+	for _, setting := range settings {
+		if binary, ok := binarySize(memory[setting]); ok {
+			warnings = append(warnings, fmt.Sprintf("%s=%s is decimal (SI); use %s for the binary size it meant before", setting, memory[setting], binary))
+		}
+	}
+
 	return warnings
 }
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Change is a setting that differs between two configurations
+type Change struct {
+	Setting string // YAML path, e.g. "logging.level" or "stores.default.max_memory"
+	Store   string // Store name for per-store settings
+	Old     string
+	New     string
+	Live    bool // Can be applied to a running node; otherwise needs a restart
+}
+
+// liveSettings are the global settings a running node applies on reload.
+// Of the per-store settings only max_memory is live.
+var liveSettings = map[string]bool{
+	"logging.level":             true,
+	"logging.sample_rate":       true,
+	"network.max_clients":       true,
+	"network.resp_error_tokens": true,
+	"persistence.sync_policy":   true,
+}
+
+// Reload reads the configuration file at path again, applies override (the
+// command-line flags; may be nil) and reports how the result differs from
+// current. The new configuration is validated; on error current stays in
+// effect.
+func Reload(path string, current *Config, override func(*Config)) (*Config, []Change, error) {
+	// Unlike Load, don't fall back to defaults if the file went away
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	next, err := Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if override != nil {
+		override(next)
+		if err := next.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+
+	return next, Diff(current, next), nil
+}
+
+// Diff lists the settings that differ between old and new
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffValue(&changes, "", "", reflect.ValueOf(*old), reflect.ValueOf(*new))
+	return changes
+}
+
+// diffValue appends the changes between a and b, found under path
+func diffValue(changes *[]Change, path, store string, a, b reflect.Value) {
+	switch {
+	case a.Kind() == reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValue(changes, name, store, a.Field(i), b.Field(i))
+		}
+
+	case a.Type() == reflect.TypeOf([]StoreConfig(nil)):
+		diffStores(changes, path, a.Interface().([]StoreConfig), b.Interface().([]StoreConfig))
+
+	case !reflect.DeepEqual(a.Interface(), b.Interface()):
+		live := liveSettings[path]
+		if store != "" {
+			live = strings.HasSuffix(path, ".max_memory")
+		}
+		*changes = append(*changes, Change{
+			Setting: path,
+			Store:   store,
+			Old:     formatSetting(a),
+			New:     formatSetting(b),
+			Live:    live,
+		})
+	}
+}
+
+// diffStores matches stores by name. Adding or removing a store needs a
+// restart.
+func diffStores(changes *[]Change, path string, old, new []StoreConfig) {
+	oldByName := make(map[string]StoreConfig, len(old))
+	for _, sc := range old {
+		oldByName[sc.Name] = sc
+	}
+	newByName := make(map[string]StoreConfig, len(new))
+	for _, sc := range new {
+		newByName[sc.Name] = sc
+	}
+
+	for _, sc := range old {
+		if _, ok := newByName[sc.Name]; !ok {
+			*changes = append(*changes, Change{Setting: path + "." + sc.Name, Store: sc.Name, Old: "defined", New: "removed"})
+		}
+	}
+	for _, sc := range new {
+		prev, ok := oldByName[sc.Name]
+		if !ok {
+			*changes = append(*changes, Change{Setting: path + "." + sc.Name, Store: sc.Name, Old: "undefined", New: "added"})
+			continue
+		}
+		diffValue(changes, path+"."+sc.Name, sc.Name, reflect.ValueOf(prev), reflect.ValueOf(sc))
+	}
+}
+
+// formatSetting renders a setting's value for logging
+func formatSetting(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return fmt.Sprint(v.Interface())
+}
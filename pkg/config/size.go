@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the unit suffixes accepted in size settings to their
+// multipliers: decimal KB/MB/GB/TB and binary KiB/MiB/GiB/TiB
+var sizeUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses a size setting such as "512MB", "4GiB" or "1024" (bytes)
+// into bytes. KB/MB/GB/TB are decimal and KiB/MiB/GiB/TiB binary; units are
+// case-insensitive and may be separated from the number by spaces. Sizes
+// must be whole numbers and fit in an int64.
+func ParseSize(s string) (uint64, error) {
+	trimmed := strings.TrimSpace(s)
+	unit := strings.TrimLeft(trimmed, "0123456789")
+	number := trimmed[:len(trimmed)-len(unit)]
+	multiplier, ok := sizeUnits[strings.ToLower(strings.TrimSpace(unit))]
+	if number == "" || !ok {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. \"512MB\", \"4GiB\" or a byte count)", s)
+	}
+
+	n, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return n * multiplier, nil
+}
+
+// binarySize returns a size in a decimal unit with the binary unit instead,
+// "8GB" as "8GiB". ok is false if s isn't a valid size in a decimal unit.
+// Before sizes followed SI the decimal units were binary, so a config
+// written then asks for less memory now than it used to.
+func binarySize(s string) (string, bool) {
+	if _, err := ParseSize(s); err != nil {
+		return "", false
+	}
+	trimmed := strings.TrimSpace(s)
+	unit := strings.TrimLeft(trimmed, "0123456789")
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "kb", "mb", "gb", "tb":
+		number := trimmed[:len(trimmed)-len(unit)]
+		return number + strings.ToUpper(strings.TrimSpace(unit)[:1]) + "iB", true
+	}
+	return "", false
+}
@@ -2,10 +2,16 @@ package cluster_test
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"hypercache/internal/cluster"
+	"hypercache/internal/network/resp"
+	"hypercache/internal/storage"
 )
 
 func TestHashRing(t *testing.T) {
@@ -608,3 +614,396 @@ func TestDistributedEventBus(t *testing.T) {
 		}
 	})
 }
+
+func TestClusterCommands(t *testing.T) {
+	t.Run("Broadcast_FlushAll", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		config1 := cluster.DefaultClusterConfig()
+		config1.NodeID = "node1"
+		config1.BindPort = 9010
+
+		config2 := cluster.DefaultClusterConfig()
+		config2.NodeID = "node2"
+		config2.BindPort = 9011
+		config2.SeedNodes = []string{"127.0.0.1:9010"}
+
+		coord1, err := cluster.NewDistributedCoordinator(config1)
+		if err != nil {
+			t.Fatalf("Failed to create node1: %v", err)
+		}
+		coord2, err := cluster.NewDistributedCoordinator(config2)
+		if err != nil {
+			t.Fatalf("Failed to create node2: %v", err)
+		}
+
+		store, err := storage.NewBasicStore(storage.BasicStoreConfig{
+			Name:            "node2-store",
+			MaxMemory:       1000000, // 1MB
+			CleanupInterval: 30 * time.Second,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		defer store.Close()
+
+		for i := 0; i < 10; i++ {
+			store.Set(fmt.Sprintf("key-%d", i), "value", "", 0)
+		}
+
+		flushed := make(chan string, 1)
+		coord2.HandleCommand(cluster.CommandFlushAll, func(ctx context.Context, cmd cluster.ClusterCommand) error {
+			err := store.Clear()
+			flushed <- cmd.NodeID
+			return err
+		})
+
+		if err := coord1.Start(ctx); err != nil {
+			t.Fatalf("Failed to start node1: %v", err)
+		}
+		defer coord1.Stop(ctx)
+		if err := coord2.Start(ctx); err != nil {
+			t.Fatalf("Failed to start node2: %v", err)
+		}
+		defer coord2.Stop(ctx)
+
+		// Wait for node1 to see node2 so the event reaches it
+		deadline := time.Now().Add(5 * time.Second)
+		for len(coord1.GetMembership().GetAliveNodes()) < 2 && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		if err := coord1.BroadcastCommand(ctx, cluster.CommandFlushAll, nil); err != nil {
+			t.Fatalf("BroadcastCommand failed: %v", err)
+		}
+
+		select {
+		case from := <-flushed:
+			if from != "node1" {
+				t.Errorf("Expected command from node1, got %s", from)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("node2 never received the flush command")
+		}
+
+		if store.Size() != 0 {
+			t.Errorf("Expected node2 store to be empty after flush, got %d keys", store.Size())
+		}
+	})
+}
+
+func TestClusterHealthQuery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config1 := cluster.DefaultClusterConfig()
+	config1.NodeID = "node1"
+	config1.BindPort = 9012
+
+	config2 := cluster.DefaultClusterConfig()
+	config2.NodeID = "node2"
+	config2.BindPort = 9013
+	config2.SeedNodes = []string{"127.0.0.1:9012"}
+
+	coord1, err := cluster.NewDistributedCoordinator(config1)
+	if err != nil {
+		t.Fatalf("Failed to create node1: %v", err)
+	}
+	coord2, err := cluster.NewDistributedCoordinator(config2)
+	if err != nil {
+		t.Fatalf("Failed to create node2: %v", err)
+	}
+
+	if err := coord1.Start(ctx); err != nil {
+		t.Fatalf("Failed to start node1: %v", err)
+	}
+	defer coord1.Stop(ctx)
+	if err := coord2.Start(ctx); err != nil {
+		t.Fatalf("Failed to start node2: %v", err)
+	}
+	defer coord2.Stop(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(coord1.GetMembership().GetAliveNodes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	queryCtx, queryCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer queryCancel()
+	health, err := coord1.QueryClusterHealth(queryCtx)
+	if err != nil {
+		t.Fatalf("QueryClusterHealth failed: %v", err)
+	}
+
+	if len(health) != 2 {
+		t.Fatalf("Expected health from 2 nodes, got %d: %+v", len(health), health)
+	}
+	for _, nodeID := range []string{"node1", "node2"} {
+		h, ok := health[nodeID]
+		if !ok {
+			t.Errorf("Missing health payload for %s", nodeID)
+			continue
+		}
+		if h.LocalNodeID != nodeID {
+			t.Errorf("Expected payload for %s to name itself, got %s", nodeID, h.LocalNodeID)
+		}
+		if h.ClusterSize != 2 {
+			t.Errorf("Expected %s to report cluster size 2, got %d", nodeID, h.ClusterSize)
+		}
+	}
+}
+
+func TestClusterDBSizeQuery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sizes := map[string]int64{"node1": 3, "node2": 5, "node3": 7}
+	var coords []*cluster.DistributedCoordinator
+	for i, nodeID := range []string{"node1", "node2", "node3"} {
+		config := cluster.DefaultClusterConfig()
+		config.NodeID = nodeID
+		config.BindPort = 9014 + i
+		if i > 0 {
+			config.SeedNodes = []string{"127.0.0.1:9014"}
+		}
+		coord, err := cluster.NewDistributedCoordinator(config)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", nodeID, err)
+		}
+		if err := coord.Start(ctx); err != nil {
+			t.Fatalf("Failed to start %s: %v", nodeID, err)
+		}
+		defer coord.Stop(ctx)
+		coords = append(coords, coord)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(coords[0].GetMembership().GetAliveNodes()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// node3 has no stores yet, so it doesn't answer
+	for _, coord := range coords[:2] {
+		nodeID := coord.GetLocalNodeID()
+		coord.SetDBSizeProvider(func(store string) int64 {
+			if store != "default" {
+				return 0
+			}
+			return sizes[nodeID]
+		})
+	}
+
+	queryCtx, queryCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer queryCancel()
+	size, err := coords[0].QueryClusterDBSize(queryCtx, "default")
+	if err != nil {
+		t.Fatalf("QueryClusterDBSize failed: %v", err)
+	}
+	if !size.Partial || size.Members != 3 {
+		t.Errorf("Expected a partial result out of 3 members, got %+v", size)
+	}
+	if size.Total != sizes["node1"]+sizes["node2"] {
+		t.Errorf("Expected total %d from the answering nodes, got %+v", sizes["node1"]+sizes["node2"], size)
+	}
+
+	coords[2].SetDBSizeProvider(func(store string) int64 { return sizes["node3"] })
+
+	queryCtx, queryCancel = context.WithTimeout(ctx, 5*time.Second)
+	defer queryCancel()
+	size, err = coords[1].QueryClusterDBSize(queryCtx, "default")
+	if err != nil {
+		t.Fatalf("QueryClusterDBSize failed: %v", err)
+	}
+	if size.Partial {
+		t.Errorf("Expected every member to answer, got %+v", size)
+	}
+	var sum int64
+	for nodeID, keys := range size.Nodes {
+		if keys != sizes[nodeID] {
+			t.Errorf("Expected %d keys from %s, got %d", sizes[nodeID], nodeID, keys)
+		}
+		sum += keys
+	}
+	if size.Total != sum || size.Total != 15 {
+		t.Errorf("Expected total 15 equal to the per-node sum %d, got %d", sum, size.Total)
+	}
+}
+
+func TestClusterBroadcastCommandSync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stores := make(map[string]*storage.BasicStore)
+	var coords []*cluster.DistributedCoordinator
+	for i, nodeID := range []string{"node1", "node2", "node3"} {
+		config := cluster.DefaultClusterConfig()
+		config.NodeID = nodeID
+		config.BindPort = 9017 + i
+		if i > 0 {
+			config.SeedNodes = []string{"127.0.0.1:9017"}
+		}
+		coord, err := cluster.NewDistributedCoordinator(config)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", nodeID, err)
+		}
+
+		store, err := storage.NewBasicStore(storage.BasicStoreConfig{
+			Name:            nodeID + "-store",
+			MaxMemory:       1024 * 1024,
+			CleanupInterval: 30 * time.Second,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		defer store.Close()
+		for k := 0; k < 10; k++ {
+			store.Set(fmt.Sprintf("key-%d", k), "value", "", 0)
+		}
+		stores[nodeID] = store
+
+		coord.HandleCommand(cluster.CommandFlushAll, func(ctx context.Context, cmd cluster.ClusterCommand) error {
+			if cmd.Args["store"] == "missing" {
+				return fmt.Errorf("store missing not found")
+			}
+			return store.Clear()
+		})
+
+		if err := coord.Start(ctx); err != nil {
+			t.Fatalf("Failed to start %s: %v", nodeID, err)
+		}
+		defer coord.Stop(ctx)
+		coords = append(coords, coord)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(coords[0].GetMembership().GetAliveNodes()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	acks, err := coords[0].BroadcastCommandSync(ctx, cluster.CommandFlushAll, nil)
+	if err != nil {
+		t.Fatalf("BroadcastCommandSync failed: %v", err)
+	}
+	if !acks.OK() || !reflect.DeepEqual(acks.Confirmed, []string{"node1", "node2", "node3"}) {
+		t.Errorf("Expected every node to confirm the flush, got %+v", acks)
+	}
+	// Confirmed means flushed: no waiting for the peers to catch up
+	for nodeID, store := range stores {
+		if store.Size() != 0 {
+			t.Errorf("Expected %s to be empty once the flush is confirmed, got %d keys", nodeID, store.Size())
+		}
+	}
+
+	// Nodes whose handler fails are reported with the reason
+	acks, err = coords[1].BroadcastCommandSync(ctx, cluster.CommandFlushAll, map[string]string{"store": "missing"})
+	if err != nil {
+		t.Fatalf("BroadcastCommandSync failed: %v", err)
+	}
+	if acks.OK() || len(acks.Failed) != 3 || acks.Failed["node3"] != "store missing not found" {
+		t.Errorf("Expected every node to report the failure, got %+v", acks)
+	}
+
+	// Nodes without a handler can't confirm
+	acks, err = coords[2].BroadcastCommandSync(ctx, "no-such-command", nil)
+	if err != nil {
+		t.Fatalf("BroadcastCommandSync failed: %v", err)
+	}
+	if acks.OK() || len(acks.Confirmed) != 0 {
+		t.Errorf("Expected an unhandled command to go unconfirmed, got %+v", acks)
+	}
+}
+
+func TestClusterConfigEpoch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newNode := func(nodeID string, port int, seeds []string) *cluster.DistributedCoordinator {
+		config := cluster.DefaultClusterConfig()
+		config.NodeID = nodeID
+		config.BindPort = port
+		config.SeedNodes = seeds
+		coord, err := cluster.NewDistributedCoordinator(config)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", nodeID, err)
+		}
+		if err := coord.Start(ctx); err != nil {
+			t.Fatalf("Failed to start %s: %v", nodeID, err)
+		}
+		return coord
+	}
+
+	node1 := newNode("node1", 9020, nil)
+	defer node1.Stop(ctx)
+	before := node1.ConfigEpoch()
+
+	node2 := newNode("node2", 9021, []string{"127.0.0.1:9020"})
+	defer node2.Stop(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for node1.GetHashRing().GetMetrics().TotalNodes < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	epoch := node1.ConfigEpoch()
+	if epoch <= before {
+		t.Fatalf("Expected the config epoch to increase when node2 joined, still %d", epoch)
+	}
+
+	// CLUSTER NODES lists the new node with the current epoch
+	store, err := storage.NewBasicStore(storage.BasicStoreConfig{Name: "default", MaxMemory: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	server := resp.NewServer("127.0.0.1:0", store, node1)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start RESP server: %v", err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	parser := resp.NewParser(conn)
+	command := func(args ...string) *resp.Value {
+		fmt.Fprintf(conn, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+		reply, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("%v: failed to read reply: %v", args, err)
+		}
+		return reply
+	}
+
+	nodes := command("CLUSTER", "NODES").Str
+	var node2Line string
+	for _, line := range strings.Split(strings.TrimSpace(nodes), "\n") {
+		if strings.HasPrefix(line, "node2 ") {
+			node2Line = line
+		}
+	}
+	if node2Line == "" {
+		t.Fatalf("Expected node2 in CLUSTER NODES, got %q", nodes)
+	}
+	if fields := strings.Fields(node2Line); len(fields) != 8 || fields[2] != "master" || fields[6] != fmt.Sprint(epoch) {
+		t.Errorf("Unexpected CLUSTER NODES line for node2: %q", node2Line)
+	}
+	if !strings.Contains(nodes, "node1 ") || !strings.Contains(nodes, "myself,master") {
+		t.Errorf("Expected node1 flagged as myself, got %q", nodes)
+	}
+	if info := command("CLUSTER", "INFO").Str; !strings.Contains(info, fmt.Sprintf("cluster_current_epoch:%d\r\n", epoch)) {
+		t.Errorf("Expected CLUSTER INFO to report epoch %d, got %q", epoch, info)
+	}
+
+	// A manual bump increments the epoch without a topology change
+	if reply := command("CLUSTER", "BUMPEPOCH").Str; reply != fmt.Sprintf("BUMPED %d", epoch+1) {
+		t.Errorf("Expected BUMPED %d, got %q", epoch+1, reply)
+	}
+	if got := node1.ConfigEpoch(); got != epoch+1 {
+		t.Errorf("Expected epoch %d after the bump, got %d", epoch+1, got)
+	}
+}
@@ -1,10 +1,15 @@
 package config_test
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"hypercache/internal/logging"
 	"hypercache/pkg/config"
 )
 
@@ -25,8 +30,8 @@ func TestConfigLoading(t *testing.T) {
 			t.Errorf("Expected default bind addr '0.0.0.0', got %s", cfg.Network.RESPBindAddr)
 		}
 
-		if cfg.Cache.MaxMemory != "8GB" {
-			t.Errorf("Expected default max memory '8GB', got %s", cfg.Cache.MaxMemory)
+		if cfg.Cache.MaxMemory != "8GiB" {
+			t.Errorf("Expected default max memory '8GiB', got %s", cfg.Cache.MaxMemory)
 		}
 
 		if cfg.Cache.DefaultTTL != "0" {
@@ -188,19 +193,19 @@ persistence:
 	})
 
 	t.Run("Memory_Size_Format", func(t *testing.T) {
-		// Test that config accepts various memory size formats
-		// Note: Actual validation of memory format is not implemented in config.Validate()
 		testCases := []struct {
 			input string
-			note  string
+			valid bool
 		}{
-			{"1024", "bytes"},
-			{"1KB", "kilobytes"},
-			{"1MB", "megabytes"},
-			{"1GB", "gigabytes"},
-			{"8GB", "current default"},
-			{"invalid", "invalid format - accepted but not validated"},
-			{"", "empty - accepted but not validated"},
+			{"1024", true},
+			{"1KB", true},
+			{"1MB", true},
+			{"1GB", true},
+			{"8GB", true},
+			{"", true}, // unset
+			{"invalid", false},
+			{"1.5GB", false},
+			{"1gigabyte", false},
 		}
 
 		for _, tc := range testCases {
@@ -211,10 +216,76 @@ persistence:
 
 			cfg.Cache.MaxMemory = tc.input
 			err = cfg.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("Expected max_memory %q to be valid, got %v", tc.input, err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("Expected validation error for max_memory %q", tc.input)
+			}
+		}
+	})
 
-			// Memory format is not validated in the current implementation
-			if err != nil {
-				t.Logf("Config with %s (%s) got validation error: %v", tc.input, tc.note, err)
+	t.Run("Missing_Ports_Use_Defaults", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hypercache.yaml")
+		yamlContent := `
+network:
+  resp_port: 0
+stores:
+  - name: "default"
+`
+		if err := os.WriteFile(path, []byte(yamlContent), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.Network.RESPPort != 8080 || cfg.Network.HTTPPort != 9080 || cfg.Network.GossipPort != 7946 {
+			t.Errorf("Expected default ports 8080/9080/7946, got %d/%d/%d",
+				cfg.Network.RESPPort, cfg.Network.HTTPPort, cfg.Network.GossipPort)
+		}
+		if cfg.Cluster.SeedDNSPort != cfg.Network.GossipPort {
+			t.Errorf("Expected seed_dns_port to default to the gossip port, got %d", cfg.Cluster.SeedDNSPort)
+		}
+
+		store := cfg.Stores[0]
+		if store.EvictionPolicy != "lru" || store.MaxMemory != cfg.Cache.MaxMemory || store.DefaultTTL != "0" {
+			t.Errorf("Expected store defaults lru/%s/0, got %s/%s/%s",
+				cfg.Cache.MaxMemory, store.EvictionPolicy, store.MaxMemory, store.DefaultTTL)
+		}
+	})
+
+	t.Run("Invalid_Values_Rejected", func(t *testing.T) {
+		testCases := map[string]string{
+			"invalid port":          "network:\n  http_port: 70000\n",
+			"negative port":         "network:\n  gossip_port: -1\n",
+			"negative interval":     "persistence:\n  sync_interval: -1s\n",
+			"unparseable ttl":       "cache:\n  default_ttl: \"forever\"\n",
+			"negative ttl":          "stores:\n  - name: \"default\"\n    default_ttl: \"-5m\"\n",
+			"unparseable size":      "stores:\n  - name: \"default\"\n    max_memory: \"lots\"\n",
+			"unparseable log cap":   "logging:\n  max_file_size: \"100 megs\"\n",
+			"bad rotate interval":   "logging:\n  rotate_interval: \"daily\"\n",
+			"negative flush":        "logging:\n  flush_interval: \"-1s\"\n",
+			"bad session timeout":   "stores:\n  - name: \"default\"\n    session_idle_timeout: \"soon\"\n",
+			"negative cors max age": "network:\n  cors:\n    max_age: -1m\n",
+			"bad max body size":     "network:\n  http_max_body_size: \"huge\"\n",
+			"negative max key size": "cache:\n  max_key_size: -1\n",
+			"bad eviction mark":     "cache:\n  eviction_low_water: 1.5\n",
+			"negative batch size":   "cache:\n  eviction_batch_size: -1\n",
+			"bad compact ratio":     "cache:\n  compact_min_ratio: 0.5\n",
+			"bad verify interval":   "cache:\n  verify_interval: \"often\"\n",
+		}
+
+		for name, yamlContent := range testCases {
+			path := filepath.Join(t.TempDir(), "hypercache.yaml")
+			if err := os.WriteFile(path, []byte(yamlContent), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			if _, err := config.Load(path); err == nil {
+				t.Errorf("%s: expected an error loading %q", name, yamlContent)
+			} else {
+				t.Logf("%s: %v", name, err)
 			}
 		}
 	})
@@ -285,6 +356,42 @@ func TestClusterConfiguration(t *testing.T) {
 			t.Errorf("Zero replication factor should fail validation")
 		}
 	})
+
+	t.Run("Cluster_Key_Routing", func(t *testing.T) {
+		cfg, err := config.Load("/non/existent/path")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.Cluster.KeyRouting != "proxy" {
+			t.Errorf("Expected key routing to default to proxy, got %q", cfg.Cluster.KeyRouting)
+		}
+
+		cfg.Cluster.KeyRouting = "redirect"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Redirect key routing should pass: %v", err)
+		}
+
+		cfg.Cluster.KeyRouting = "moved"
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Unknown key routing should fail validation")
+		}
+	})
+
+	t.Run("Cluster_Replication_Lag_Threshold", func(t *testing.T) {
+		cfg, err := config.Load("/non/existent/path")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		cfg.Cluster.ReplicationLagThreshold = 500 * time.Millisecond
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Positive replication lag threshold should pass: %v", err)
+		}
+
+		cfg.Cluster.ReplicationLagThreshold = -time.Second
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Negative replication lag threshold should fail validation")
+		}
+	})
 }
 
 func TestPersistenceConfiguration(t *testing.T) {
@@ -415,3 +522,165 @@ logging:
 		}
 	})
 }
+
+func TestConfigReload(t *testing.T) {
+	writeConfig := func(t *testing.T, path, level, maxMemory string, respPort int) {
+		t.Helper()
+		yamlContent := fmt.Sprintf(`
+network:
+  resp_port: %d
+cluster:
+  seeds: ["127.0.0.1:7946"]
+logging:
+  level: "%s"
+stores:
+  - name: "default"
+    eviction_policy: "lru"
+    max_memory: "%s"
+`, respPort, level, maxMemory)
+		if err := os.WriteFile(path, []byte(yamlContent), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+
+	t.Run("Modified_Log_Level", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hypercache.yaml")
+		writeConfig(t, path, "info", "1GB", 6379)
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+
+		writeConfig(t, path, "debug", "2GB", 6380)
+		next, changes, err := config.Reload(path, cfg, nil)
+		if err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if next.Logging.Level != "debug" {
+			t.Errorf("Expected reloaded log level 'debug', got %s", next.Logging.Level)
+		}
+
+		bySetting := make(map[string]config.Change)
+		for _, change := range changes {
+			bySetting[change.Setting] = change
+		}
+		if len(bySetting) != 3 {
+			t.Errorf("Expected 3 changed settings, got %+v", changes)
+		}
+		if c := bySetting["logging.level"]; !c.Live || c.Old != "info" || c.New != "debug" {
+			t.Errorf("Expected live logging.level change info -> debug, got %+v", c)
+		}
+		if c := bySetting["stores.default.max_memory"]; !c.Live || c.Store != "default" || c.New != "2GB" {
+			t.Errorf("Expected live max_memory change for store default, got %+v", c)
+		}
+		if c, ok := bySetting["network.resp_port"]; !ok || c.Live {
+			t.Errorf("Expected network.resp_port change to require a restart, got %+v", c)
+		}
+
+		// Apply the level the way the SIGHUP handler does
+		logger := logging.NewLogger(logging.Config{Level: logging.LogLevelFromString(cfg.Logging.Level), BufferSize: 10})
+		defer logger.Close()
+		logger.SetLevel(logging.LogLevelFromString(bySetting["logging.level"].New))
+		if logger.Level() != logging.DEBUG {
+			t.Errorf("Expected logger level DEBUG after reload, got %s", logger.Level())
+		}
+	})
+
+	t.Run("Invalid_Config_Keeps_Current", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "hypercache.yaml")
+		writeConfig(t, path, "info", "1GB", 6379)
+		cfg, err := config.Load(path)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+
+		writeConfig(t, path, "debug", "1GB", -1)
+		if _, _, err := config.Reload(path, cfg, nil); err == nil {
+			t.Error("Expected reload of an invalid config to fail")
+		}
+
+		os.Remove(path)
+		if _, _, err := config.Reload(path, cfg, nil); err == nil {
+			t.Error("Expected reload of a missing config file to fail")
+		}
+		if cfg.Logging.Level != "info" {
+			t.Errorf("Expected current config to be unchanged, got level %s", cfg.Logging.Level)
+		}
+	})
+}
+
+func TestParseSize(t *testing.T) {
+	t.Run("Units", func(t *testing.T) {
+		testCases := map[string]uint64{
+			"1024":   1024,
+			"0":      0,
+			"512B":   512,
+			"1KB":    1000,
+			"1MB":    1000 * 1000,
+			"8GB":    8 * 1000 * 1000 * 1000,
+			"2TB":    2 * 1000 * 1000 * 1000 * 1000,
+			"1KiB":   1 << 10,
+			"64MiB":  64 << 20,
+			"4GiB":   4 << 30,
+			"3TiB":   3 << 40,
+			"256mb":  256 * 1000 * 1000,
+			"2 GiB":  2 << 30,
+			" 1gib ": 1 << 30,
+		}
+
+		for input, want := range testCases {
+			got, err := config.ParseSize(input)
+			if err != nil {
+				t.Errorf("ParseSize(%q) failed: %v", input, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("ParseSize(%q) = %d, want %d", input, got, want)
+			}
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, input := range []string{"", "GB", "lots", "1.5GB", "-1MB", "12XB", "1 G B"} {
+			if _, err := config.ParseSize(input); err == nil {
+				t.Errorf("Expected an error parsing %q", input)
+			}
+		}
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		// 8388607 TiB is the largest whole TiB count that fits in an int64
+		if _, err := config.ParseSize("8388607TiB"); err != nil {
+			t.Errorf("Expected 8388607TiB to fit in an int64: %v", err)
+		}
+		for _, input := range []string{"8388608TiB", "9300000TB", "9223372036854775808", "99999999999999999999"} {
+			if _, err := config.ParseSize(input); err == nil {
+				t.Errorf("Expected an overflow error parsing %q", input)
+			}
+		}
+	})
+}
+
+func TestCheckWarnings_DecimalMemory(t *testing.T) {
+	cfg := &config.Config{
+		Cache: config.CacheConfig{MaxMemory: "8GB"},
+		Stores: []config.StoreConfig{
+			{Name: "default", MaxMemory: "8GiB"},
+			{Name: "sessions", MaxMemory: "512 mb"},
+		},
+	}
+
+	var got []string
+	for _, warning := range config.CheckWarnings(cfg) {
+		if strings.Contains(warning, "max_memory") {
+			got = append(got, warning)
+		}
+	}
+	want := []string{
+		"cache.max_memory=8GB is decimal (SI); use 8GiB for the binary size it meant before",
+		"stores.sessions.max_memory=512 mb is decimal (SI); use 512MiB for the binary size it meant before",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Memory warnings = %q, want %q", got, want)
+	}
+}